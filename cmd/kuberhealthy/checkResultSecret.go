@@ -0,0 +1,160 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/health"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// checkResultSecret is the Secret in the Kuberhealthy namespace that the
+// result of every check run is stored in, so that results are still served
+// after a restart when their khstate resources were not written or were lost
+const checkResultSecret = "kuberhealthy-check-state"
+
+// storeCheckResultSecret stores the result of a check run in the check
+// result Secret under the CRD name of the check.  The Secret is created when
+// it does not exist.
+func (k *Kuberhealthy) storeCheckResultSecret(checkName string, details health.CheckDetails) error {
+	client, err := k.KubeClient()
+	if err != nil {
+		return err
+	}
+	return setCheckResultSecret(client, os.Getenv("POD_NAMESPACE"), checkName, details)
+}
+
+// setCheckResultSecret stores the result of a check run in the check result
+// Secret in the given namespace.  An update that conflicts with another
+// writer is returned as an error to be retried.
+func setCheckResultSecret(client *kubernetes.Clientset, namespace string, checkName string, details health.CheckDetails) error {
+	b, err := json.Marshal(details)
+	if err != nil {
+		return errors.New("Error encoding result of check " + checkName + ": " + err.Error())
+	}
+	key := sanitizeCRDName(checkName)
+
+	secret, err := client.CoreV1().Secrets(namespace).Get(checkResultSecret, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		secret = &apiv1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      checkResultSecret,
+				Namespace: namespace,
+			},
+			Data: map[string][]byte{key: b},
+		}
+		_, err = client.CoreV1().Secrets(namespace).Create(secret)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	secret.Data[key] = b
+	_, err = client.CoreV1().Secrets(namespace).Update(secret)
+	return err
+}
+
+// loadCheckResultSecret reads the check results stored in the check result
+// Secret so that they can be served until the checks run again
+func (k *Kuberhealthy) loadCheckResultSecret() {
+	client, err := k.KubeClient()
+	if err != nil {
+		log.Errorln("Error creating Kubernetes client to load stored check results:", err)
+		return
+	}
+	secret, err := client.CoreV1().Secrets(os.Getenv("POD_NAMESPACE")).Get(checkResultSecret, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		log.Debugln("No stored check results were found in Secret", checkResultSecret)
+		return
+	}
+	if err != nil {
+		log.Errorln("Error reading stored check results from Secret", checkResultSecret+":", err)
+		return
+	}
+
+	results := decodeCheckResults(secret.Data)
+	log.Infoln("Loaded", len(results), "stored check results from Secret", checkResultSecret)
+
+	k.Lock()
+	defer k.Unlock()
+	k.storedResults = results
+}
+
+// decodeCheckResults decodes the check results of the check result Secret
+// by CRD name.  Results that can not be decoded are skipped.
+func decodeCheckResults(data map[string][]byte) map[string]health.CheckDetails {
+	results := make(map[string]health.CheckDetails)
+	for key, b := range data {
+		var details health.CheckDetails
+		err := json.Unmarshal(b, &details)
+		if err != nil {
+			log.Warningln("Error decoding stored result of check", key+":", err)
+			continue
+		}
+		results[key] = details
+	}
+	return results
+}
+
+// storedCheckResult returns the stored result of a check when it is still
+// fresh.  A result is fresh until the check result TTL has passed since the
+// run that produced it, or twice the check's interval when no TTL is set.
+func (k *Kuberhealthy) storedCheckResult(c KuberhealthyCheck) (health.CheckDetails, bool) {
+	k.RLock()
+	details, ok := k.storedResults[sanitizeCRDName(c.Name())]
+	k.RUnlock()
+	if !ok || !checkResultFresh(details, k.CheckResultTTL, k.checkInterval(c), time.Now()) {
+		return health.CheckDetails{}, false
+	}
+	return details, true
+}
+
+// checkResultFresh returns true when a stored check result is younger than
+// ttl, or twice interval when ttl is not set
+func checkResultFresh(details health.CheckDetails, ttl time.Duration, interval time.Duration, now time.Time) bool {
+	if details.LastRun.IsZero() {
+		return false
+	}
+	if ttl <= 0 {
+		ttl = interval * 2
+	}
+	return now.Sub(details.LastRun) <= ttl
+}
+
+// loadStoredCheckFailures marks the checks whose fresh stored result failed
+// as failing, unless their state is already known, so that the checks
+// depending on them are skipped until they run again
+func (k *Kuberhealthy) loadStoredCheckFailures() {
+	for _, c := range k.Checks {
+		details, ok := k.storedCheckResult(c)
+		if !ok {
+			continue
+		}
+		k.Lock()
+		if _, known := k.failedChecks[c.Name()]; !known {
+			k.failedChecks[c.Name()] = !details.OK || details.Skipped
+		}
+		k.Unlock()
+	}
+}
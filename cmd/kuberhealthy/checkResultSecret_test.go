@@ -0,0 +1,177 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/health"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// fakeSecretServer serves a single Secret that does not exist until it is
+// created
+type fakeSecretServer struct {
+	sync.Mutex
+	secret  *apiv1.Secret
+	creates int
+	updates int
+}
+
+func (f *fakeSecretServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodPost, http.MethodPut:
+		if r.Method == http.MethodPost {
+			f.creates++
+		} else {
+			f.updates++
+		}
+		var secret apiv1.Secret
+		json.NewDecoder(r.Body).Decode(&secret)
+		f.secret = &secret
+	case http.MethodGet:
+		if f.secret == nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(metav1.Status{
+				TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+				Status:   metav1.StatusFailure,
+				Reason:   metav1.StatusReasonNotFound,
+				Code:     http.StatusNotFound,
+			})
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(f.secret)
+}
+
+func TestSetCheckResultSecret(t *testing.T) {
+	f := &fakeSecretServer{}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := health.NewCheckDetails()
+	first.OK = true
+	first.LastRun = time.Now().Round(time.Second)
+	err = setCheckResultSecret(client, "kuberhealthy", "DaemonSetChecker", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := health.NewCheckDetails()
+	second.Errors = []string{"pods not ready"}
+	second.LastRun = first.LastRun
+	err = setCheckResultSecret(client, "kuberhealthy", "PodStatusChecker namespace kube-system", second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.creates != 1 || f.updates != 1 {
+		t.Fatal("Expected the Secret to be created once and updated once but got", f.creates, "creates and", f.updates, "updates")
+	}
+	expected := map[string]health.CheckDetails{
+		"daemonsetchecker":                       first,
+		"podstatuschecker-namespace-kube-system": second,
+	}
+	results := decodeCheckResults(f.secret.Data)
+	for key, details := range results {
+		if !details.LastRun.Equal(expected[key].LastRun) {
+			t.Fatal("Unexpected last run of stored result", key, details.LastRun)
+		}
+		details.LastRun = expected[key].LastRun
+		results[key] = details
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatal("Expected stored results", expected, "but got", results)
+	}
+}
+
+func TestDecodeCheckResults(t *testing.T) {
+	data := map[string][]byte{
+		"dnsstatuschecker": []byte(`{"OK":true,"Errors":[]}`),
+		"brokenchecker":    []byte(`not json`),
+	}
+	results := decodeCheckResults(data)
+	if len(results) != 1 || !results["dnsstatuschecker"].OK {
+		t.Fatal("Expected only the valid result to be decoded but got", results)
+	}
+}
+
+func TestCheckResultFresh(t *testing.T) {
+	now := time.Now()
+	var tests = []struct {
+		description string
+		lastRun     time.Time
+		ttl         time.Duration
+		expected    bool
+	}{
+		{"never run", time.Time{}, 0, false},
+		{"within twice the interval", now.Add(-time.Minute * 25), 0, true},
+		{"older than twice the interval", now.Add(-time.Minute * 35), 0, false},
+		{"within the TTL", now.Add(-time.Minute * 35), time.Hour, true},
+		{"older than the TTL", now.Add(-time.Minute * 25), time.Minute * 20, false},
+	}
+
+	for _, test := range tests {
+		details := health.NewCheckDetails()
+		details.LastRun = test.lastRun
+		fresh := checkResultFresh(details, test.ttl, time.Minute*15, now)
+		if fresh != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "but got", fresh)
+		}
+	}
+}
+
+func TestLoadStoredCheckFailures(t *testing.T) {
+	kh := NewKuberhealthy()
+	failing := NewFakeCheck()
+	failing.CheckName = "FailingCheck"
+	passing := NewFakeCheck()
+	passing.CheckName = "PassingCheck"
+	known := NewFakeCheck()
+	known.CheckName = "KnownCheck"
+	kh.AddCheck(failing)
+	kh.AddCheck(passing)
+	kh.AddCheck(known)
+
+	failed := health.NewCheckDetails()
+	failed.LastRun = time.Now()
+	ok := failed
+	ok.OK = true
+	kh.storedResults = map[string]health.CheckDetails{
+		"failingcheck": failed,
+		"passingcheck": ok,
+		"knowncheck":   failed,
+	}
+	kh.failedChecks["KnownCheck"] = false
+
+	kh.loadStoredCheckFailures()
+	expected := map[string]bool{"FailingCheck": true, "PassingCheck": false, "KnownCheck": false}
+	if !reflect.DeepEqual(kh.failedChecks, expected) {
+		t.Fatal("Expected", expected, "but got", kh.failedChecks)
+	}
+}
@@ -13,46 +13,61 @@ package main
 
 import (
 	"errors"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/Comcast/kuberhealthy/pkg/health"
 	"github.com/Comcast/kuberhealthy/pkg/khstatecrd"
 	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// maxCRDConflictRetries is the number of times a CRD update is attempted when
+// the resource version of the CRD changes between reading and updating it
+const maxCRDConflictRetries = 3
+
 // setCheckCRDState puts a check state's state into the specified CRD.  It sets the AuthoritivePod
-// to the server's hostname and sets the LastUpdate time to now.
+// to the server's hostname and sets the LastRun time to now if it is not set.  When the CRD
+// is changed by someone else between fetching and updating it, the update
+// is rejected because its resource version is out of date and is retried
+// with the current resource version.
 func setCheckCRDState(checkName string, client *khstatecrd.KuberhealthyStateClient, state health.CheckDetails) error {
 
 	name := sanitizeCRDName(checkName)
 
-	// we must fetch the existing state to use the current resource version
-	// int found within
-	existingState, err := client.Get(metav1.GetOptions{}, CRDResource, name)
-	if err != nil {
-		return errors.New("Error retreiving CRD for: " + name + " " + err.Error())
-	}
-	resourceVersion := existingState.GetResourceVersion()
-
 	// set ourselves as the authoritative pod here
 	myName, err := getEnvVar("POD_NAME")
 	if err != nil {
 		return err
 	}
 	state.AuthoritativePod = myName
-	state.LastRun = time.Now()
+	if state.LastRun.IsZero() {
+		state.LastRun = time.Now()
+	}
+
+	for attempt := 1; ; attempt++ {
+		// we must fetch the existing state to use the current resource version
+		// int found within
+		existingState, err := client.Get(metav1.GetOptions{}, CRDResource, name)
+		if err != nil {
+			return errors.New("Error retreiving CRD for: " + name + " " + err.Error())
+		}
+		resourceVersion := existingState.GetResourceVersion()
 
-	// TODO - if "try again" message found in error, then try again
-	log.Debugln("Writing details to CRD:", state)
+		log.Debugln("Writing details to CRD:", state)
 
-	khState := khstatecrd.NewKuberhealthyState(name, state)
-	khState.SetResourceVersion(resourceVersion)
+		khState := khstatecrd.NewKuberhealthyState(name, state)
+		khState.SetResourceVersion(resourceVersion)
 
-	log.Debugln("Updating the CRD for:", checkName, "to", khState)
-	_, err = client.Update(&khState, CRDResource, name)
-	return err
+		log.Debugln("Updating the CRD for:", checkName, "to", khState)
+		_, err = client.Update(&khState, CRDResource, name)
+		if err == nil || !apierrors.IsConflict(err) || attempt >= maxCRDConflictRetries {
+			return err
+		}
+		log.Debugln("CRD for", checkName, "changed before it could be updated. Retrying with its current resource version.")
+	}
 }
 
 // sanitizeCRDName cleans up the check names for use in CRDs.
@@ -64,7 +79,15 @@ func sanitizeCRDName(c string) string {
 
 	// the name we pass to the CRD must be lowercase
 	nameLower := strings.ToLower(c)
-	name := strings.Replace(nameLower, " ", "-", -1)
+
+	// and may only contain alphanumerics, '-', and '.', so spaces and the
+	// characters of node selectors such as '=' and '/' become dashes
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			return r
+		}
+		return '-'
+	}, nameLower)
 
 	return name
 }
@@ -116,3 +139,106 @@ func getCheckCRDState(c KuberhealthyCheck, client *khstatecrd.KuberhealthyStateC
 	log.Debugln("Successfully retrieved CRD:", name)
 	return khstate.Spec, nil
 }
+
+// loadCheckStates reads the stored state of every check when this pod
+// becomes master.  Checks that failed before a restart or a change of master
+// are known to be failing before they run again, so that the checks
+// depending on them are skipped.  The states of checks that are no longer
+// configured are removed.
+func (k *Kuberhealthy) loadCheckStates() {
+	client, err := khstatecrd.Client(CRDGroup, CRDVersion, kubeConfigFile)
+	if err != nil {
+		log.Errorln("Error creating CRD client to load check states:", err)
+		return
+	}
+	list, err := client.List(metav1.ListOptions{}, CRDResource)
+	if err != nil {
+		log.Errorln("Error listing check states:", err)
+		return
+	}
+
+	checkNames, err := k.configuredCheckNames()
+
+	// states already known in memory are newer than the stored states
+	k.Lock()
+	for checkName, failed := range failedCheckStates(list.Items, checkNames) {
+		if _, ok := k.failedChecks[checkName]; !ok {
+			k.failedChecks[checkName] = failed
+		}
+	}
+	k.Unlock()
+
+	if err != nil {
+		log.Warningln("Not removing the states of removed checks because the configured checks could not be listed:", err)
+		return
+	}
+	for _, name := range staleCheckStates(list.Items, checkNames) {
+		log.Infoln("Removing the state of a check that is no longer configured:", name)
+		_, err := client.Delete(nil, CRDResource, name)
+		if err != nil {
+			log.Errorln("Error removing check state", name+":", err)
+		}
+	}
+}
+
+// configuredCheckNames returns the names of the checks Kuberhealthy runs,
+// including external checks defined by khcheck resources.  When the external
+// checks can not be listed, the built in check names are returned along with
+// the error.
+func (k *Kuberhealthy) configuredCheckNames() ([]string, error) {
+	var names []string
+	for _, c := range k.Checks {
+		names = append(names, c.Name())
+	}
+	if !k.ExternalChecks {
+		return names, nil
+	}
+
+	khChecks, err := listExternalChecks()
+	if err != nil {
+		return names, err
+	}
+	for _, khc := range khChecks {
+		names = append(names, khc.Name)
+	}
+	return names, nil
+}
+
+// failedCheckStates returns whether each check failed or was skipped on its
+// last run from its stored state.  States of checks that have never run are
+// not included.
+func failedCheckStates(states []khstatecrd.KuberhealthyState, checkNames []string) map[string]bool {
+	failed := make(map[string]bool)
+
+	checksByCRDName := make(map[string]string)
+	for _, checkName := range checkNames {
+		checksByCRDName[sanitizeCRDName(checkName)] = checkName
+	}
+
+	for _, state := range states {
+		checkName, ok := checksByCRDName[state.Name]
+		if !ok || state.Spec.LastRun.IsZero() {
+			continue
+		}
+		failed[checkName] = !state.Spec.OK || state.Spec.Skipped
+	}
+	return failed
+}
+
+// staleCheckStates returns the sorted names of the stored states that do not
+// belong to any of the checks
+func staleCheckStates(states []khstatecrd.KuberhealthyState, checkNames []string) []string {
+	configured := make(map[string]bool)
+	for _, checkName := range checkNames {
+		configured[sanitizeCRDName(checkName)] = true
+	}
+
+	var stale []string
+	for _, state := range states {
+		if !configured[state.Name] {
+			stale = append(stale, state.Name)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
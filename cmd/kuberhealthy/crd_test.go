@@ -0,0 +1,233 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/health"
+	"github.com/Comcast/kuberhealthy/pkg/khstatecrd"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// fakeStateServer serves a single khstate resource and rejects updates with
+// an out of date resource version like the API server does
+type fakeStateServer struct {
+	sync.Mutex
+	spec            health.CheckDetails
+	resourceVersion int
+	puts            int
+	concurrentPuts  int // the number of updates that are preceded by an update from another writer
+}
+
+func (f *fakeStateServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == http.MethodPut {
+		f.puts++
+		if f.puts <= f.concurrentPuts {
+			// another writer updated the resource after it was fetched
+			f.resourceVersion++
+		}
+
+		var state khstatecrd.KuberhealthyState
+		err := json.NewDecoder(r.Body).Decode(&state)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if state.ResourceVersion != strconv.Itoa(f.resourceVersion) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(metav1.Status{
+				TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+				Status:   metav1.StatusFailure,
+				Reason:   metav1.StatusReasonConflict,
+				Code:     http.StatusConflict,
+				Message:  "the object has been modified; please apply your changes to the latest version and try again",
+			})
+			return
+		}
+		f.spec = state.Spec
+		f.resourceVersion++
+	}
+
+	state := khstatecrd.NewKuberhealthyState(name, f.spec)
+	state.SetResourceVersion(strconv.Itoa(f.resourceVersion))
+	json.NewEncoder(w).Encode(state)
+}
+
+// newFakeStateClient returns a khstate client for a fake state server
+func newFakeStateClient(t *testing.T, f *fakeStateServer) *khstatecrd.KuberhealthyStateClient {
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	client, err := khstatecrd.ClientForConfig(&rest.Config{Host: server.URL}, CRDGroup, CRDVersion, "kuberhealthy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestSetCheckCRDStateConflict(t *testing.T) {
+	os.Setenv("POD_NAME", "kuberhealthy-test")
+	defer os.Unsetenv("POD_NAME")
+
+	f := &fakeStateServer{resourceVersion: 1, concurrentPuts: 1}
+	client := newFakeStateClient(t, f)
+
+	fc := NewFakeCheck()
+	details := health.NewCheckDetails()
+	details.Errors = []string{"fake failure"}
+	err := setCheckCRDState(fc.Name(), client, details)
+	if err != nil {
+		t.Fatal("Expected update to be retried after a conflict but got error:", err)
+	}
+	if f.puts != 2 {
+		t.Fatal("Expected 2 updates but got", f.puts)
+	}
+
+	// the stored state is read back with the check's details
+	state, err := getCheckCRDState(fc, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.OK || !reflect.DeepEqual(state.Errors, details.Errors) || state.AuthoritativePod != "kuberhealthy-test" {
+		t.Fatal("Unexpected state read back:", state)
+	}
+}
+
+func TestSetCheckCRDStateConflictRetriesExhausted(t *testing.T) {
+	os.Setenv("POD_NAME", "kuberhealthy-test")
+	defer os.Unsetenv("POD_NAME")
+
+	f := &fakeStateServer{resourceVersion: 1, concurrentPuts: maxCRDConflictRetries}
+	client := newFakeStateClient(t, f)
+
+	err := setCheckCRDState("FakeCheck", client, health.NewCheckDetails())
+	if err == nil || !apierrors.IsConflict(err) {
+		t.Fatal("Expected a conflict error after", maxCRDConflictRetries, "attempts but got", err)
+	}
+	if f.puts != maxCRDConflictRetries {
+		t.Fatal("Expected", maxCRDConflictRetries, "updates but got", f.puts)
+	}
+}
+
+func TestCheckStateWriter(t *testing.T) {
+	var mu sync.Mutex
+	var attempts int
+	var stored []health.CheckDetails
+	started := make(chan bool)
+	block := make(chan bool)
+
+	store := func(checkName string, details health.CheckDetails) error {
+		mu.Lock()
+		attempts++
+		attempt := attempts
+		mu.Unlock()
+
+		// hold the first write so that more states are queued behind it
+		if attempt == 1 {
+			close(started)
+			<-block
+		}
+		// fail the second write so that it is retried
+		if attempt == 2 {
+			return errors.New("fake write failure")
+		}
+
+		mu.Lock()
+		stored = append(stored, details)
+		mu.Unlock()
+		return nil
+	}
+	w := newCheckStateWriter("test", store, 2, time.Millisecond)
+
+	first := health.NewCheckDetails()
+	first.OK = true
+	w.Write("FakeCheck", first)
+	<-started
+
+	// states queued while the first is written are replaced by newer ones
+	second := health.NewCheckDetails()
+	second.Errors = []string{"second"}
+	w.Write("FakeCheck", second)
+	third := health.NewCheckDetails()
+	third.Errors = []string{"third"}
+	w.Write("FakeCheck", third)
+
+	close(block)
+	w.Wait()
+
+	if len(stored) != 2 {
+		t.Fatal("Expected the first and latest states to be stored but got", stored)
+	}
+	if !stored[0].OK || !reflect.DeepEqual(stored[1].Errors, third.Errors) {
+		t.Fatal("Unexpected states stored:", stored)
+	}
+	if attempts != 3 {
+		t.Fatal("Expected the failed write to be retried once but there were", attempts, "attempts")
+	}
+	if stored[0].LastRun.IsZero() || stored[1].LastRun.IsZero() {
+		t.Fatal("Expected the last run time to be set when states are queued")
+	}
+}
+
+func TestFailedCheckStates(t *testing.T) {
+	makeState := func(name string, ok bool, skipped bool, ran bool) khstatecrd.KuberhealthyState {
+		details := health.NewCheckDetails()
+		details.OK = ok
+		details.Skipped = skipped
+		if ran {
+			details.LastRun = time.Now()
+		}
+		return khstatecrd.NewKuberhealthyState(name, details)
+	}
+
+	states := []khstatecrd.KuberhealthyState{
+		makeState("componentstatuschecker", true, false, true),
+		makeState("dnsstatuschecker", false, false, true),
+		makeState("podstatuschecker-namespace-kube-system", false, true, true),
+		makeState("daemonsetchecker", false, false, false),
+		makeState("removedchecker", false, false, true),
+	}
+	checkNames := []string{"ComponentStatusChecker", "DnsStatusChecker", "PodStatusChecker namespace kube-system", "DaemonSetChecker"}
+
+	expected := map[string]bool{
+		"ComponentStatusChecker":                 false,
+		"DnsStatusChecker":                       true,
+		"PodStatusChecker namespace kube-system": true,
+	}
+	failed := failedCheckStates(states, checkNames)
+	if !reflect.DeepEqual(failed, expected) {
+		t.Fatal("Expected", expected, "but got", failed)
+	}
+
+	stale := staleCheckStates(states, checkNames)
+	if !reflect.DeepEqual(stale, []string{"removedchecker"}) {
+		t.Fatal("Expected only removedchecker to be stale but got", stale)
+	}
+}
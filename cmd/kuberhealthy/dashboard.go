@@ -0,0 +1,48 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// dashboardPage is the status dashboard.  It fetches /status and renders
+// the state of every check.
+//
+//go:embed dashboard.html
+var dashboardPage string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardPage))
+
+// wantsDashboard determines if a request to the root path is from a browser
+// that should be shown the dashboard rather than the JSON status
+func wantsDashboard(r *http.Request) bool {
+	return r.URL.Path == "/" && strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// dashboardHandler writes the status dashboard.  The name of this pod is
+// included so that the dashboard can show if it is the master.
+func (k *Kuberhealthy) dashboardHandler(w http.ResponseWriter, r *http.Request) error {
+	log.Infoln("Client connected to dashboard from", r.RemoteAddr, r.UserAgent())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := dashboardTemplate.Execute(w, struct{ PodName string }{PodName: os.Getenv("POD_NAME")})
+	if err != nil {
+		log.Warningln("Error writing dashboard to caller:", err)
+	}
+	return err
+}
@@ -0,0 +1,301 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/checks/external"
+	"github.com/Comcast/kuberhealthy/pkg/khcheckcrd"
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// externalCheckRelistDelay is how long the khcheck resource watch waits
+// before listing khcheck resources again after an error
+var externalCheckRelistDelay = time.Second * 30
+
+// runningExternalCheck is an external check started from a khcheck resource
+type runningExternalCheck struct {
+	check           *external.Checker
+	resourceVersion string
+	stopChan        chan bool
+}
+
+// watchExternalChecks starts an external check for every khcheck resource.
+// Checks are restarted when their resource changes and stopped when it is
+// deleted.  The khcheck resources are listed again whenever the watch ends,
+// so that changes made while they were not being watched are applied as
+// well.  All external checks are stopped when a stop signal is received.
+func (k *Kuberhealthy) watchExternalChecks(stopChan chan bool) {
+	if len(os.Getenv("POD_IP")) == 0 {
+		log.Warnln("POD_IP is not set.  External checks will not be able to report their results.")
+	}
+
+	running := make(map[string]runningExternalCheck)
+	defer func() {
+		for name, r := range running {
+			k.stopExternalCheck(name, r)
+		}
+	}()
+
+	var client *khcheckcrd.KuberhealthyCheckClient
+	for {
+		var err error
+		if client == nil {
+			client, err = khcheckcrd.Client(CRDGroup, CRDVersion, kubeConfigFile)
+			if err != nil {
+				client = nil
+				log.Errorln("Error creating client to watch external checks:", err)
+				if !waitForRelist(stopChan, externalCheckRelistDelay) {
+					return
+				}
+				continue
+			}
+		}
+
+		list, err := client.List(metav1.ListOptions{}, ExternalCheckCRDResource)
+		if err != nil {
+			log.Errorln("Error listing external checks:", err)
+			if !waitForRelist(stopChan, externalCheckRelistDelay) {
+				return
+			}
+			continue
+		}
+		k.reloadExternalChecks(running, list.Items)
+
+		if !k.watchExternalCheckChanges(client, list.ResourceVersion, running, stopChan) {
+			return
+		}
+	}
+}
+
+// watchExternalCheckChanges applies every change of the khcheck resources
+// after resourceVersion to the running external checks until the watch
+// ends.  False is returned when a stop signal was received.
+func (k *Kuberhealthy) watchExternalCheckChanges(client *khcheckcrd.KuberhealthyCheckClient, resourceVersion string, running map[string]runningExternalCheck, stopChan chan bool) bool {
+	w, err := client.Watch(metav1.ListOptions{ResourceVersion: resourceVersion}, ExternalCheckCRDResource)
+	if err != nil {
+		log.Errorln("Error watching external checks:", err)
+		return waitForRelist(stopChan, externalCheckRelistDelay)
+	}
+	defer w.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return false
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				log.Debugln("Watch of external checks ended.  Listing them again.")
+				return true
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				khc, ok := event.Object.(*khcheckcrd.KuberhealthyCheck)
+				if !ok {
+					continue
+				}
+				k.updateExternalCheck(running, *khc)
+			case watch.Deleted:
+				khc, ok := event.Object.(*khcheckcrd.KuberhealthyCheck)
+				if !ok {
+					continue
+				}
+				k.removeExternalCheck(running, khc.Name)
+			case watch.Error:
+				log.Warningln("Error watching external checks:", apierrors.FromObject(event.Object))
+				return waitForRelist(stopChan, externalCheckRelistDelay)
+			}
+		}
+	}
+}
+
+// reloadExternalChecks starts, restarts and stops the running external
+// checks to match the listed khcheck resources
+func (k *Kuberhealthy) reloadExternalChecks(running map[string]runningExternalCheck, khChecks []khcheckcrd.KuberhealthyCheck) {
+	found := make(map[string]bool)
+	for _, khc := range khChecks {
+		found[khc.Name] = true
+		k.updateExternalCheck(running, khc)
+	}
+
+	for name := range running {
+		if !found[name] {
+			k.removeExternalCheck(running, name)
+		}
+	}
+}
+
+// updateExternalCheck starts the external check of a khcheck resource, or
+// restarts it when the resource changed since the check was started
+func (k *Kuberhealthy) updateExternalCheck(running map[string]runningExternalCheck, khc khcheckcrd.KuberhealthyCheck) {
+	r, ok := running[khc.Name]
+	if ok && r.resourceVersion == khc.ResourceVersion {
+		return
+	}
+	if ok {
+		log.Infoln("External check", khc.Name, "changed. Restarting it.")
+		k.stopExternalCheck(khc.Name, r)
+		delete(running, khc.Name)
+	}
+
+	c, err := k.newExternalCheck(khc)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	r = runningExternalCheck{
+		check:           c,
+		resourceVersion: khc.ResourceVersion,
+		stopChan:        make(chan bool, 1),
+	}
+	running[khc.Name] = r
+	k.Lock()
+	k.externalChecks[khc.Name] = c
+	k.Unlock()
+
+	log.Infoln("Starting external check:", khc.Name)
+	k.startCheck(r.stopChan, c)
+}
+
+// removeExternalCheck stops the external check of a deleted khcheck resource
+func (k *Kuberhealthy) removeExternalCheck(running map[string]runningExternalCheck, name string) {
+	r, ok := running[name]
+	if !ok {
+		return
+	}
+	log.Infoln("External check", name, "was deleted. Stopping it.")
+	k.stopExternalCheck(name, r)
+	delete(running, name)
+}
+
+// stopExternalCheck signals a running external check to stop and stops
+// accepting results for it
+func (k *Kuberhealthy) stopExternalCheck(name string, r runningExternalCheck) {
+	select {
+	case r.stopChan <- true:
+	default:
+		log.Warnln("Attempted to send signal to external check stop channel", name, "but channel did not accept send")
+	}
+
+	k.Lock()
+	defer k.Unlock()
+	if k.externalChecks[name] == r.check {
+		delete(k.externalChecks, name)
+	}
+}
+
+// newExternalCheck makes an external check from a khcheck resource
+func (k *Kuberhealthy) newExternalCheck(khc khcheckcrd.KuberhealthyCheck) (*external.Checker, error) {
+	runInterval, err := time.ParseDuration(khc.Spec.RunInterval)
+	if err != nil {
+		return nil, errors.New("Error parsing runInterval of external check " + khc.Name + ": " + err.Error())
+	}
+	if runInterval <= 0 {
+		return nil, errors.New("The runInterval of external check " + khc.Name + " must be greater than zero")
+	}
+	c := external.New(khc.Name, runInterval, khc.Spec.JobTemplate, k.externalCheckReportingURL())
+	c.CronSchedule = khc.Spec.Schedule
+	_, err = checkSchedule(c)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// externalCheckReportingURL returns the URL that external check Jobs POST
+// their results to.  The URL uses the IP of this pod rather than the
+// Kuberhealthy service so that results reach the master that is waiting for
+// them.
+func (k *Kuberhealthy) externalCheckReportingURL() string {
+	_, port, err := net.SplitHostPort(k.ListenAddr)
+	if err != nil || len(port) == 0 {
+		port = "80"
+	}
+	return "http://" + net.JoinHostPort(os.Getenv("POD_IP"), port) + "/externalCheckStatus"
+}
+
+// listExternalChecks lists the khcheck resources that define external checks
+func listExternalChecks() ([]khcheckcrd.KuberhealthyCheck, error) {
+	client, err := khcheckcrd.Client(CRDGroup, CRDVersion, kubeConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	list, err := client.List(metav1.ListOptions{}, ExternalCheckCRDResource)
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// externalCheckForRun returns the external check waiting for a result from
+// the run with the given ID, or nil when no check is waiting for it
+func (k *Kuberhealthy) externalCheckForRun(runUUID string) *external.Checker {
+	if len(runUUID) == 0 {
+		return nil
+	}
+	k.RLock()
+	defer k.RUnlock()
+	for _, c := range k.externalChecks {
+		if c.RunUUID() == runUUID {
+			return c
+		}
+	}
+	return nil
+}
+
+// externalCheckStatusHandler receives the result of an external check run.
+// Results are POSTed as JSON with the ID of the run in a header.
+func (k *Kuberhealthy) externalCheckStatusHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Results must be sent with a POST", http.StatusMethodNotAllowed)
+		return errors.New("Received an external check result with method " + r.Method + " from " + r.RemoteAddr)
+	}
+
+	runUUID := r.Header.Get(external.RunUUIDHeader)
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading result", http.StatusBadRequest)
+		return errors.New("Error reading external check result from " + r.RemoteAddr + ": " + err.Error())
+	}
+	var result external.Result
+	err = json.Unmarshal(b, &result)
+	if err != nil {
+		http.Error(w, "Error decoding result: "+err.Error(), http.StatusBadRequest)
+		return errors.New("Error decoding external check result from " + r.RemoteAddr + ": " + err.Error())
+	}
+
+	c := k.externalCheckForRun(runUUID)
+	if c == nil {
+		http.Error(w, "No check run is waiting for a result with ID "+runUUID, http.StatusNotFound)
+		return errors.New("Received an external check result from " + r.RemoteAddr + " for unknown run " + runUUID)
+	}
+	err = c.Report(runUUID, result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return err
+	}
+
+	log.Infoln("Received result of external check", c.Name(), "from", r.RemoteAddr)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
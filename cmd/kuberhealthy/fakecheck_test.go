@@ -12,6 +12,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -29,6 +30,7 @@ type FakeCheck struct {
 	FakeError               string        // the string thrown when ShouldHaveRunError or ShouldHaveShutdownError is set to true and Shutdown or Run is called
 	CheckName               string        // the name of this check
 	Namespace               string        // the namespace of the fake check
+	Dependencies            []string      // the names of the checks returned by DependsOn
 }
 
 func (fc *FakeCheck) Name() string {
@@ -51,13 +53,17 @@ func (fc *FakeCheck) CurrentStatus() (bool, []string) {
 	return fc.OK, fc.Errors
 }
 
-func (fc *FakeCheck) Run(c *kubernetes.Clientset) error {
+func (fc *FakeCheck) Run(ctx context.Context, c *kubernetes.Clientset) error {
 	if fc.ShouldHaveRunError {
 		return errors.New(fc.FakeError)
 	}
 	return nil
 }
 
+func (fc *FakeCheck) DependsOn() []string {
+	return fc.Dependencies
+}
+
 func (fc *FakeCheck) Shutdown() error {
 	if fc.ShouldHaveShutdownError {
 		return errors.New(fc.FakeError)
@@ -0,0 +1,99 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/Comcast/kuberhealthy/pkg/health"
+)
+
+// grpcHealthServer implements the grpc.health.v1.Health service with the
+// current state of Kuberhealthy.  The empty service name is the health of
+// the cluster and every other service name is the name of a check.
+type grpcHealthServer struct {
+	getState func() (health.State, error) // returns the current state of Kuberhealthy
+}
+
+// Check returns the serving status of the cluster or of the check named by
+// the requested service.  Unknown checks fail with NOT_FOUND.
+func (s *grpcHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	state, err := s.getState()
+	if err != nil {
+		log.Errorln("Error getting the current state for a gRPC health check:", err)
+	}
+	servingStatus, err := servingStatus(state, err, req.GetService())
+	if err != nil {
+		return nil, err
+	}
+	return &healthpb.HealthCheckResponse{Status: servingStatus}, nil
+}
+
+// Watch is not supported.  Clients are expected to poll Check instead.
+func (s *grpcHealthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	return status.Error(codes.Unimplemented, "watching health is not supported")
+}
+
+// servingStatus returns the serving status of a service in the current
+// state.  The empty service is SERVING when every check is OK.  A check is
+// SERVING when its last run passed, and NOT_SERVING when it failed or was
+// skipped because a check it depends on is failing.  Everything is
+// NOT_SERVING when the current state could not be determined.
+func servingStatus(state health.State, stateErr error, service string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	if stateErr != nil {
+		return healthpb.HealthCheckResponse_NOT_SERVING, nil
+	}
+
+	if len(service) == 0 {
+		if state.OK {
+			return healthpb.HealthCheckResponse_SERVING, nil
+		}
+		return healthpb.HealthCheckResponse_NOT_SERVING, nil
+	}
+
+	details, ok := state.CheckDetails[service]
+	if !ok {
+		return healthpb.HealthCheckResponse_UNKNOWN, status.Error(codes.NotFound, "unknown check "+service)
+	}
+	if details.OK && !details.Skipped {
+		return healthpb.HealthCheckResponse_SERVING, nil
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING, nil
+}
+
+// StartGRPCServer starts a gRPC server implementing the grpc.health.v1
+// health checking protocol at the gRPC listen address
+func (k *Kuberhealthy) StartGRPCServer() {
+	listener, err := net.Listen("tcp", k.GRPCListenAddr)
+	if err != nil {
+		log.Errorln("Error listening for gRPC health checks:", err)
+		os.Exit(1)
+	}
+
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, &grpcHealthServer{getState: k.getCurrentState})
+
+	log.Infoln("Starting gRPC health service on port", k.GRPCListenAddr)
+	err = server.Serve(listener)
+	if err != nil {
+		log.Errorln(err)
+	}
+	os.Exit(1)
+}
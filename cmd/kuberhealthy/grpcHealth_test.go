@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/Comcast/kuberhealthy/pkg/health"
+)
+
+func TestServingStatus(t *testing.T) {
+	state := health.NewState()
+	state.OK = false
+	state.CheckDetails["PodStatusChecker"] = health.CheckDetails{OK: true}
+	state.CheckDetails["DaemonSetChecker"] = health.CheckDetails{OK: false}
+	state.CheckDetails["DNSChecker"] = health.CheckDetails{OK: true, Skipped: true}
+
+	var tests = []struct {
+		description string
+		state       health.State
+		stateErr    error
+		service     string
+		expected    healthpb.HealthCheckResponse_ServingStatus
+		expectedErr codes.Code
+	}{
+		{"cluster healthy", health.NewState(), nil, "", healthpb.HealthCheckResponse_SERVING, codes.OK},
+		{"cluster unhealthy", state, nil, "", healthpb.HealthCheckResponse_NOT_SERVING, codes.OK},
+		{"state unavailable", health.NewState(), errors.New("no CRD client"), "", healthpb.HealthCheckResponse_NOT_SERVING, codes.OK},
+		{"passing check", state, nil, "PodStatusChecker", healthpb.HealthCheckResponse_SERVING, codes.OK},
+		{"failing check", state, nil, "DaemonSetChecker", healthpb.HealthCheckResponse_NOT_SERVING, codes.OK},
+		{"skipped check", state, nil, "DNSChecker", healthpb.HealthCheckResponse_NOT_SERVING, codes.OK},
+		{"unknown check", state, nil, "MissingChecker", healthpb.HealthCheckResponse_UNKNOWN, codes.NotFound},
+	}
+
+	for _, test := range tests {
+		servingStatus, err := servingStatus(test.state, test.stateErr, test.service)
+		if status.Code(err) != test.expectedErr {
+			t.Fatal("Test", test.description, "expected error code", test.expectedErr, "but got", err)
+		}
+		if servingStatus != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "but got", servingStatus)
+		}
+	}
+}
+
+func TestGRPCHealthServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, &grpcHealthServer{getState: func() (health.State, error) {
+		state := health.NewState()
+		state.CheckDetails["PodStatusChecker"] = health.CheckDetails{OK: true}
+		return state, nil
+	}})
+	go server.Serve(listener)
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, listener.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatal("Expected the cluster to be SERVING but got", resp, err)
+	}
+	resp, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "PodStatusChecker"})
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatal("Expected PodStatusChecker to be SERVING but got", resp, err)
+	}
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "MissingChecker"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatal("Expected an unknown check to fail with NOT_FOUND but got", err)
+	}
+}
@@ -12,7 +12,11 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"os"
 	"strconv"
@@ -20,11 +24,14 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Comcast/kuberhealthy/pkg/checks/external"
 	"github.com/Comcast/kuberhealthy/pkg/health"
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
 	"github.com/Comcast/kuberhealthy/pkg/khstatecrd"
 	"github.com/Comcast/kuberhealthy/pkg/kubeClient"
 	"github.com/Comcast/kuberhealthy/pkg/masterCalculation"
 	"github.com/Comcast/kuberhealthy/pkg/metrics"
+	"github.com/Comcast/kuberhealthy/pkg/notifiers"
 	log "github.com/sirupsen/logrus"
 	"k8s.io/client-go/kubernetes"
 )
@@ -34,9 +41,32 @@ type Kuberhealthy struct {
 	sync.RWMutex
 	Checks                []KuberhealthyCheck
 	ListenAddr            string               // the listen address, such as ":80"
+	GRPCListenAddr        string               // the listen address of the gRPC health service, such as ":9090"
 	checkShutdownChannels map[string]chan bool // a slice of channels used to signal shutdowns to checks
 	MetricForwarder       metrics.Client
+	Notifiers             []notifiers.Notifier           // sent the result of every check run in the background
+	PrometheusMetrics     *metrics.PrometheusClient      // exposed on /metrics when set
+	MetricTags            map[string]string              // added as labels to the state gauges on /metrics
+	CheckTimeout          time.Duration                  // the run timeout of checks that do not implement Timeouter
+	RetryMaxDelay         time.Duration                  // the longest delay between retries of checks that implement Retryable
+	History               *health.History                // recent check results served on /checkHistory when set
+	ExternalChecks        bool                           // run external checks defined by khcheck resources
+	RuntimeConfigMap      string                         // the ConfigMap checks are reconfigured from while running, when set
+	CheckResultTTL        time.Duration                  // how long stored check results are served after a restart, twice the check interval when zero
+	externalChecks        map[string]*external.Checker   // the running external checks by name
+	failedChecks          map[string]bool                // checks that failed or were skipped on their last run
+	checkConfigs          map[string]checkConfig         // the applied runtime configuration of each check
+	checkRunners          map[string]*checkRunner        // the running checks by name
+	pendingRestarts       map[string]chan bool           // closed when the latest restart of a check by name is done
+	unfinishedRuns        map[string]bool                // checks whose last run has not returned after it was cancelled
+	stateWriter           *checkStateWriter              // writes check states to their CRDs in the background
+	notifyQueues          []*notificationQueue           // send check results to each of the Notifiers in order
+	resultWriter          *checkStateWriter              // writes check states to the check result Secret in the background
+	storedResults         map[string]health.CheckDetails // check results read from the check result Secret at startup by CRD name
 	overrideKubeClient    *kubernetes.Clientset
+	ctx                   context.Context    // cancelled on shutdown to cancel running checks
+	cancel                context.CancelFunc // cancels ctx
+	runningChecks         sync.WaitGroup     // tracks check routines so shutdown can wait for them
 }
 
 // newKubeClient sets up a new kuberhealthy client if it does not exist
@@ -55,7 +85,17 @@ func (k *Kuberhealthy) KubeClient() (*kubernetes.Clientset, error) {
 // NewKuberhealthy creates a new kuberhealthy checker instance
 func NewKuberhealthy() *Kuberhealthy {
 	kh := &Kuberhealthy{}
+	kh.ctx, kh.cancel = context.WithCancel(context.Background())
 	kh.checkShutdownChannels = make(map[string]chan bool)
+	kh.externalChecks = make(map[string]*external.Checker)
+	kh.failedChecks = make(map[string]bool)
+	kh.checkConfigs = make(map[string]checkConfig)
+	kh.checkRunners = make(map[string]*checkRunner)
+	kh.pendingRestarts = make(map[string]chan bool)
+	kh.unfinishedRuns = make(map[string]bool)
+	kh.storedResults = make(map[string]health.CheckDetails)
+	kh.stateWriter = newCheckStateWriter("CRD", kh.storeCheckState, 5, time.Second*5)
+	kh.resultWriter = newCheckStateWriter("Secret "+checkResultSecret, kh.storeCheckResultSecret, 5, time.Second*5)
 	return kh
 }
 
@@ -75,11 +115,19 @@ func (k *Kuberhealthy) setCheckExecutionError(checkName string, exErr error) {
 	details.Errors = []string{"Check execution error: " + exErr.Error()}
 	log.Debugln("Setting execution state of check", checkName, "to", details.OK, details.Errors)
 
-	// store the check state with the CRD
-	err = k.storeCheckState(checkName, details)
-	if err != nil {
-		log.Errorln("Was unable to write an execution error to the CRD status with error:", err)
+	// store the check state in the background
+	k.writeCheckState(checkName, details)
+}
+
+// writeCheckState queues the state of a check to be written to its CRD and
+// to the check result Secret in the background.  The last run time of the
+// state is set once so that both record the same run.
+func (k *Kuberhealthy) writeCheckState(checkName string, details health.CheckDetails) {
+	if details.LastRun.IsZero() {
+		details.LastRun = time.Now()
 	}
+	k.stateWriter.Write(checkName, details)
+	k.resultWriter.Write(checkName, details)
 }
 
 // AddCheck adds a check to Kuberhealthy.  Must be done before StartChecking
@@ -88,9 +136,17 @@ func (k *Kuberhealthy) AddCheck(c KuberhealthyCheck) {
 	k.Checks = append(k.Checks, c)
 }
 
-// Shutdown causes the kuberhealthy check group to shutdown gracefully
+// Shutdown causes the kuberhealthy check group to shutdown gracefully.
+// Running checks are cancelled and shut down before it returns.
 func (k *Kuberhealthy) Shutdown() {
+	k.cancel()
 	k.StopChecks()
+	k.runningChecks.Wait()
+	k.stateWriter.Wait()
+	k.resultWriter.Wait()
+	for _, q := range k.notificationQueues() {
+		q.Wait()
+	}
 	log.Debugln("All checks shutdown!")
 	doneChan <- true
 }
@@ -105,6 +161,9 @@ func (k *Kuberhealthy) StopChecks() {
 // Start inits Kuberhealthy checks and master monitoring
 func (k *Kuberhealthy) Start() {
 
+	// stored results are served until the checks run again
+	k.loadCheckResultSecret()
+
 	becameMasterChan := make(chan bool)
 	lostMasterChan := make(chan bool)
 
@@ -126,18 +185,124 @@ func (k *Kuberhealthy) Start() {
 
 // StartChecks starts all checks concurrently and ensures they stay running
 func (k *Kuberhealthy) StartChecks() {
+	k.loadCheckStates()
+	k.loadStoredCheckFailures()
+	if len(k.RuntimeConfigMap) > 0 {
+		k.reloadRuntimeConfig(false)
+	}
+
 	for _, c := range k.Checks {
-		// create and log a stop signal channel here. pass into channel
+		if k.checkDisabled(c.Name()) {
+			log.Infoln("Check", c.Name(), "is disabled by the runtime configuration.  Not starting it.")
+			continue
+		}
+		k.startCheckRunner(c)
+	}
+
+	// external checks are started and stopped as their khcheck resources change
+	if k.ExternalChecks {
+		stopChan := make(chan bool, 1)
+		k.addCheckStopChan("externalCheckWatcher", stopChan)
+		go k.watchExternalChecks(stopChan)
+	}
+
+	// checks are reconfigured as the runtime configuration ConfigMap changes
+	if len(k.RuntimeConfigMap) > 0 {
 		stopChan := make(chan bool, 1)
-		k.addCheckStopChan(c.Name(), stopChan)
+		k.addCheckStopChan("runtimeConfigWatcher", stopChan)
+		go k.watchRuntimeConfig(stopChan)
+	}
+}
+
+// checkRunner tracks the routine running a check so that the check can be
+// restarted on its own
+type checkRunner struct {
+	stopChan chan bool
+	stopKey  string    // the key of stopChan in the check shutdown channels
+	done     chan bool // closed when the check routine returns
+}
+
+// startCheckRunner starts a check in its own routine and tracks it by name
+// so that it can be stopped without stopping other checks
+func (k *Kuberhealthy) startCheckRunner(c KuberhealthyCheck) {
+	// create and log a stop signal channel here. pass into channel
+	stopChan := make(chan bool, 1)
+	stopKey := k.addCheckStopChan(c.Name(), stopChan)
+
+	// start the check in its own routine
+	done := k.startCheck(stopChan, c)
+
+	k.Lock()
+	defer k.Unlock()
+	k.checkRunners[c.Name()] = &checkRunner{
+		stopChan: stopChan,
+		stopKey:  stopKey,
+		done:     done,
+	}
+}
+
+// stopCheckRunner signals a check started by startCheckRunner to stop
+// without waiting for it.  The returned channel is closed when its routine
+// returns, which can take until its current run ends.  A closed channel is
+// returned when the check is not running.
+func (k *Kuberhealthy) stopCheckRunner(checkName string) chan bool {
+	k.Lock()
+	r, ok := k.checkRunners[checkName]
+	if ok {
+		delete(k.checkRunners, checkName)
+		delete(k.checkShutdownChannels, r.stopKey)
+	}
+	k.Unlock()
+	if !ok {
+		done := make(chan bool)
+		close(done)
+		return done
+	}
+
+	select {
+	case r.stopChan <- true:
+	default:
+		log.Warnln("Attempted to send signal to check stop channel", checkName, "but channel did not accept send")
+	}
+	return r.done
+}
+
+// checkDisabled returns true when a check is disabled by the runtime
+// configuration
+func (k *Kuberhealthy) checkDisabled(checkName string) bool {
+	k.RLock()
+	defer k.RUnlock()
+	return k.checkConfigs[checkName].disabled
+}
 
-		// start the check in its own routine
-		go k.runCheck(stopChan, c)
+// checkInterval returns the interval a check runs at, which may be
+// overridden by the runtime configuration
+func (k *Kuberhealthy) checkInterval(c KuberhealthyCheck) time.Duration {
+	k.RLock()
+	defer k.RUnlock()
+	if interval := k.checkConfigs[c.Name()].interval; interval > 0 {
+		return interval
 	}
+	return c.Interval()
+}
+
+// startCheck runs a check in its own routine that is tracked so that
+// shutdown can wait for it.  The returned channel is closed when the
+// routine returns.
+func (k *Kuberhealthy) startCheck(stopChan chan bool, c KuberhealthyCheck) chan bool {
+	done := make(chan bool)
+	k.runningChecks.Add(1)
+	go func() {
+		defer k.runningChecks.Done()
+		defer close(done)
+		k.runCheck(stopChan, c)
+	}()
+	return done
 }
 
-// addCheckStopChan stores a check's shutdown channel in the checker
-func (k *Kuberhealthy) addCheckStopChan(checkName string, stopChan chan bool) {
+// addCheckStopChan stores a check's shutdown channel in the checker and
+// returns the key it is stored under
+func (k *Kuberhealthy) addCheckStopChan(checkName string, stopChan chan bool) string {
 	k.Lock()
 	defer k.Unlock()
 
@@ -145,6 +310,7 @@ func (k *Kuberhealthy) addCheckStopChan(checkName string, stopChan chan bool) {
 	now := strconv.Itoa(int(time.Now().Unix()))
 	checkName = checkName + "-" + now
 	k.checkShutdownChannels[checkName] = stopChan
+	return checkName
 }
 
 // sigChecks sends signals down all check shutdown chans and removes them
@@ -166,6 +332,10 @@ func (k *Kuberhealthy) sigChecks() {
 		delete(k.checkShutdownChannels, checkName)
 	}
 
+	// every check runner was just signalled to stop, and pending restarts
+	// must not start them again
+	k.checkRunners = make(map[string]*checkRunner)
+	k.pendingRestarts = make(map[string]chan bool)
 }
 
 // masterStatusMonitor calculates the master pod on a ticker.  When a
@@ -212,15 +382,42 @@ func (k *Kuberhealthy) masterStatusMonitor(becameMasterChan chan bool, lostMaste
 		// keep track of the previous runs master state
 		wasMaster = isMaster
 
+		if k.MetricForwarder != nil {
+			masterStatus := 0
+			if isMaster {
+				masterStatus = 1
+			}
+			err = k.MetricForwarder.Push(metrics.Metric{{"master": masterStatus}}, map[string]string{})
+			if err != nil {
+				log.Errorln("Error forwarding master metrics", err)
+			}
+		}
+
 		<-ticker.C
 	}
 }
 
-// runCheck runs a check on an interval and sets its status each run
+// runCheck runs a check on an interval or schedule and sets its status each
+// run
 func (k *Kuberhealthy) runCheck(stopChan chan bool, c KuberhealthyCheck) {
 
-	// run on an interval specified by the package
-	ticker := time.NewTicker(c.Interval())
+	// log with the check name as a field so that logs can be filtered by check
+	checkLog := khlog.ForCheck(c.Name())
+
+	// run on the schedule of the check when it has one, or on an interval
+	// specified by the package
+	schedule, err := checkSchedule(c)
+	if err != nil {
+		checkLog.Errorln(err, "Running it every", k.checkInterval(c), "instead.")
+	}
+	timer := newRunTimer(k.checkInterval(c), schedule)
+	defer timer.Stop()
+
+	// scheduled checks wait for the first time in their schedule
+	if schedule != nil && !k.waitForNextRun(timer, stopChan) {
+		shutdownCheck(c, checkLog)
+		return
+	}
 
 	// run the check forever and write its results to the kuberhealthy
 	// CRD resource for the check
@@ -229,38 +426,69 @@ func (k *Kuberhealthy) runCheck(stopChan chan bool, c KuberhealthyCheck) {
 		// break out if check channel is supposed to stop
 		select {
 		case <-stopChan:
-			log.Debugln("Check", c.Name(), "stop signal received. Stopping check.")
-			err := c.Shutdown()
-			if err != nil {
-				log.Errorln("Error stopping check", c.Name(), err)
-			}
+			shutdownCheck(c, checkLog)
+			return
+		case <-k.ctx.Done():
+			shutdownCheck(c, checkLog)
 			return
 		default:
 		}
 
-		log.Infoln("Running check:", c.Name())
+		// skip the check while a check it depends on is failing
+		if reason := k.dependencySkipReason(c); len(reason) > 0 {
+			checkLog.Infoln("Skipping check", c.Name()+":", reason)
+			k.setCheckSkipped(c, reason)
+			if !k.waitForNextRun(timer, stopChan) {
+				shutdownCheck(c, checkLog)
+				return
+			}
+			continue
+		}
+
+		checkLog.Infoln("Running check:", c.Name())
 		client, err := k.KubeClient()
 		if err != nil {
-			log.Errorln("Error creating Kubernetes client for check"+c.Name()+":", err)
-			<-ticker.C
+			checkLog.Errorln("Error creating Kubernetes client for check"+c.Name()+":", err)
+			if !k.waitForNextRun(timer, stopChan) {
+				shutdownCheck(c, checkLog)
+				return
+			}
 			continue
 		}
 
 		// Run the check
-		err = c.Run(client)
+		runStart := time.Now()
+		stopped, attempts, err := k.runWithRetries(stopChan, c, client, checkLog)
+		runDuration := time.Since(runStart)
+		if stopped || k.ctx.Err() != nil {
+			shutdownCheck(c, checkLog)
+			return
+		}
 		if err != nil {
 			// set any check run errors in the CRD
 			k.setCheckExecutionError(c.Name(), err)
-			log.Errorln("Error running check:", c.Name(), err)
-			<-ticker.C
+			k.setCheckFailed(c.Name(), true)
+			k.recordCheckResult(c.Name(), runDuration, false, []string{err.Error()})
+			k.notifyCheckResult(c, false, []string{err.Error()}, checkLog)
+			checkLog.Errorln("Error running check:", c.Name(), err)
+			if !k.waitForNextRun(timer, stopChan) {
+				shutdownCheck(c, checkLog)
+				return
+			}
 			continue
 		}
-		log.Debugln("Done running check:", c.Name())
+		checkLog.Debugln("Done running check:", c.Name())
 
 		// make a new state for this check and fill it from the check's current status
 		details := health.NewCheckDetails()
 		details.Namespace = c.CheckNamespace()
 		details.OK, details.Errors = c.CurrentStatus()
+		if !details.OK && attempts > 1 {
+			details.Errors = withAttemptCount(details.Errors, attempts)
+		}
+		k.setCheckFailed(c.Name(), !details.OK)
+		k.recordCheckResult(c.Name(), runDuration, details.OK, details.Errors)
+		k.notifyCheckResult(c, details.OK, details.Errors, checkLog)
 
 		if k.MetricForwarder != nil {
 			checkStatus := 0
@@ -276,22 +504,274 @@ func (k *Kuberhealthy) runCheck(stopChan chan bool, c KuberhealthyCheck) {
 			}
 			metric := metrics.Metric{
 				{c.Name() + "_status": checkStatus},
+				{c.Name() + "_duration_seconds": runDuration.Seconds()},
 			}
 			err := k.MetricForwarder.Push(metric, tags)
 			if err != nil {
-				log.Errorln("Error forwarding metrics", err)
+				checkLog.Errorln("Error forwarding metrics", err)
 			}
 		}
 
-		log.Infoln("Setting state of check", c.Name(), "to", details.OK, details.Errors)
+		checkLog.Infoln("Setting state of check", c.Name(), "to", details.OK, details.Errors)
 
-		// store the check state with the CRD
-		err = k.storeCheckState(c.Name(), details)
-		if err != nil {
-			log.Errorln("Error storing CRD state for check:", c.Name(), err)
+		// store the check state in the background
+		k.writeCheckState(c.Name(), details)
+
+		// wait for next run
+		if !k.waitForNextRun(timer, stopChan) {
+			shutdownCheck(c, checkLog)
+			return
+		}
+	}
+}
+
+// dependencySkipReason returns the reason a check should be skipped when it
+// implements Dependent and a check it depends on failed or was skipped on its
+// last run.  An empty string is returned when the check should run.
+func (k *Kuberhealthy) dependencySkipReason(c KuberhealthyCheck) string {
+	dc, ok := c.(Dependent)
+	if !ok {
+		return ""
+	}
+
+	k.RLock()
+	defer k.RUnlock()
+	for _, dependency := range dc.DependsOn() {
+		if k.failedChecks[dependency] {
+			return "dependency " + dependency + " is failing"
 		}
-		<-ticker.C // wait for next run
 	}
+	return ""
+}
+
+// setCheckFailed records whether a check failed or was skipped on its last
+// run so that checks depending on it can be skipped
+func (k *Kuberhealthy) setCheckFailed(checkName string, failed bool) {
+	k.Lock()
+	defer k.Unlock()
+	k.failedChecks[checkName] = failed
+}
+
+// setCheckSkipped marks a check as skipped in its CRD status with the
+// reason it was not run.  A skipped check is treated as failing by the
+// checks that depend on it.
+func (k *Kuberhealthy) setCheckSkipped(c KuberhealthyCheck, reason string) {
+	k.setCheckFailed(c.Name(), true)
+
+	details := health.NewCheckDetails()
+	details.Namespace = c.CheckNamespace()
+	details.Skipped = true
+	details.SkipReason = reason
+
+	// store the check state in the background
+	k.writeCheckState(c.Name(), details)
+}
+
+// recordCheckResult adds the result of a check run to the check history
+func (k *Kuberhealthy) recordCheckResult(checkName string, duration time.Duration, ok bool, errs []string) {
+	if k.History == nil {
+		return
+	}
+	k.History.Add(health.CheckResult{
+		Check:           checkName,
+		Timestamp:       time.Now(),
+		DurationSeconds: duration.Seconds(),
+		OK:              ok,
+		Errors:          errs,
+	})
+}
+
+// notifyCheckResult queues the result of a check run to be sent to every
+// notifier in the background
+func (k *Kuberhealthy) notifyCheckResult(c KuberhealthyCheck, ok bool, errs []string, checkLog *log.Entry) {
+	result := checkResult{namespace: c.CheckNamespace(), ok: ok, errs: errs, checkLog: checkLog}
+	for _, q := range k.notificationQueues() {
+		q.Queue(c.Name(), result)
+	}
+}
+
+// notificationQueues returns the queue of each notifier, creating the queues
+// of notifiers added since the last call
+func (k *Kuberhealthy) notificationQueues() []*notificationQueue {
+	k.Lock()
+	defer k.Unlock()
+	for len(k.notifyQueues) < len(k.Notifiers) {
+		k.notifyQueues = append(k.notifyQueues, newNotificationQueue(k.Notifiers[len(k.notifyQueues)]))
+	}
+	return k.notifyQueues
+}
+
+// waitForNextRun waits until a check's timer fires.  It returns false when
+// the check is stopped or Kuberhealthy shuts down while waiting.
+func (k *Kuberhealthy) waitForNextRun(timer *runTimer, stopChan chan bool) bool {
+	select {
+	case <-timer.C():
+		return true
+	case <-stopChan:
+		return false
+	case <-k.ctx.Done():
+		return false
+	}
+}
+
+// shutdownCheck shuts down a check that received a stop signal
+func shutdownCheck(c KuberhealthyCheck, checkLog *log.Entry) {
+	checkLog.Debugln("Check", c.Name(), "stop signal received. Stopping check.")
+	err := c.Shutdown()
+	if err != nil {
+		checkLog.Errorln("Error stopping check", c.Name(), err)
+	}
+}
+
+// runWithRetries runs a check, retrying checks that implement Retryable
+// with exponential backoff until they pass or their attempts are
+// exhausted.  A run fails when the check returns an error or reports itself
+// down.  Only the result of the last attempt is returned, with the attempt
+// count added to its error, along with the number of attempts made.  The
+// returned bool is true when a stop signal was received or Kuberhealthy
+// shut down while waiting to retry.  Retries are logged to checkLog.
+func (k *Kuberhealthy) runWithRetries(stopChan chan bool, c KuberhealthyCheck, client *kubernetes.Clientset, checkLog *log.Entry) (bool, int, error) {
+	maxAttempts, baseDelay := 1, time.Duration(0)
+	if r, ok := c.(Retryable); ok {
+		maxAttempts, baseDelay = r.RetryPolicy()
+	}
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := k.runWithTimeout(c, client)
+		ok, _ := c.CurrentStatus()
+		if err == nil && ok {
+			if attempt > 1 {
+				checkLog.Debugln("Check", c.Name(), "passed after", attempt-1, "retries")
+			}
+			return false, attempt, nil
+		}
+		if attempt >= maxAttempts {
+			if attempt > 1 {
+				checkLog.Debugln("Check", c.Name(), "failed after", attempt-1, "retries")
+			}
+			if err != nil && maxAttempts > 1 {
+				err = errors.New(err.Error() + attemptCountSuffix(attempt))
+			}
+			return false, attempt, err
+		}
+
+		delay := retryDelay(attempt, baseDelay, k.RetryMaxDelay, rand.Int63n)
+		checkLog.Debugln("Check", c.Name(), "failed attempt", attempt, "of", maxAttempts, "- retrying in", delay)
+		select {
+		case <-stopChan:
+			return true, attempt, err
+		case <-k.ctx.Done():
+			return true, attempt, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// attemptCountSuffix returns the text added to the final error of a check
+// that failed every attempt
+func attemptCountSuffix(attempts int) string {
+	return " (failed after " + strconv.Itoa(attempts) + " attempts)"
+}
+
+// withAttemptCount returns the errors of a check that reported itself down
+// on every attempt, with the attempt count added to the final error
+func withAttemptCount(errs []string, attempts int) []string {
+	if len(errs) == 0 {
+		return []string{"Check is down" + attemptCountSuffix(attempts)}
+	}
+	counted := make([]string, len(errs))
+	copy(counted, errs)
+	counted[len(counted)-1] += attemptCountSuffix(attempts)
+	return counted
+}
+
+// retryDelay returns how long to wait before retrying after a failed
+// attempt.  The delay doubles with each attempt from the base delay up to
+// the maximum delay.  Up to half of it again is added as jitter so that
+// checks failing together do not retry together, but never past the maximum
+// delay.  jitter returns a random number in [0, n) and is replaced in tests.
+func retryDelay(attempt int, baseDelay time.Duration, maxDelay time.Duration, jitter func(n int64) int64) time.Duration {
+	delay := baseDelay
+	for i := 1; i < attempt && (maxDelay <= 0 || delay < maxDelay); i++ {
+		delay *= 2
+	}
+	if delay/2 > 0 {
+		delay += time.Duration(jitter(int64(delay / 2)))
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// checkTimeout returns how long a single run of a check may take
+func (k *Kuberhealthy) checkTimeout(c KuberhealthyCheck) time.Duration {
+	if t, ok := c.(Timeouter); ok && t.Timeout() > 0 {
+		return t.Timeout()
+	}
+	return k.CheckTimeout
+}
+
+// cancelledRunWait is how long a check is given to return from its run
+// after its context is cancelled
+var cancelledRunWait = time.Second * 30
+
+// runWithTimeout runs a check and returns an error that names the timeout
+// when the check does not complete within it, so that a timeout is not
+// confused with a check failure.  The check is given a context that is
+// cancelled at the timeout or when Kuberhealthy shuts down, and is then
+// waited on for up to cancelledRunWait.  A check that still has not
+// returned is left to finish in the background, and is not run again until
+// it has, so that runs never use the same check at the same time.
+func (k *Kuberhealthy) runWithTimeout(c KuberhealthyCheck, client *kubernetes.Clientset) error {
+	k.Lock()
+	if k.unfinishedRuns[c.Name()] {
+		k.Unlock()
+		return errors.New("check was not run because its previous run has not returned since it timed out")
+	}
+	k.Unlock()
+
+	timeout := k.checkTimeout(c)
+	ctx, cancel := context.WithTimeout(k.ctx, timeout)
+	defer cancel()
+
+	// buffered so that a check finishing after its timeout does not block
+	doneChan := make(chan error, 1)
+	go func() {
+		doneChan <- c.Run(ctx, client)
+	}()
+
+	select {
+	case err := <-doneChan:
+		return err
+	case <-ctx.Done():
+	}
+
+	var err error
+	if k.ctx.Err() != nil {
+		err = errors.New("check was cancelled because Kuberhealthy is shutting down")
+	} else {
+		err = errors.New("check timed out after " + timeout.String() + " before it completed.  This is a timeout, not a check failure.")
+	}
+
+	select {
+	case <-doneChan:
+	case <-time.After(cancelledRunWait):
+		log.Warningln("Check", c.Name(), "did not return within", cancelledRunWait, "of being cancelled.  It will not run again until it returns.")
+		k.Lock()
+		k.unfinishedRuns[c.Name()] = true
+		k.Unlock()
+		go func() {
+			<-doneChan
+			k.Lock()
+			delete(k.unfinishedRuns, c.Name())
+			k.Unlock()
+		}()
+	}
+	return err
 }
 
 // storeCheckState stores the check state in its cluster CRD
@@ -328,14 +808,41 @@ func (k *Kuberhealthy) StartWebServer() {
 		}
 	})
 
-	// Assign all requests to be handled by the healthCheckHandler function
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/checkHistory", func(w http.ResponseWriter, r *http.Request) {
+		err := k.checkHistoryHandler(w, r)
+		if err != nil {
+			log.Errorln(err)
+		}
+	})
+
+	http.HandleFunc("/externalCheckStatus", func(w http.ResponseWriter, r *http.Request) {
+		err := k.externalCheckStatusHandler(w, r)
+		if err != nil {
+			log.Errorln(err)
+		}
+	})
+
+	http.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
 		err := k.healthCheckHandler(w, r)
 		if err != nil {
 			log.Errorln(err)
 		}
 	})
 
+	// Assign all other requests to be handled by the healthCheckHandler
+	// function, except for browsers loading the dashboard
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if wantsDashboard(r) {
+			err = k.dashboardHandler(w, r)
+		} else {
+			err = k.healthCheckHandler(w, r)
+		}
+		if err != nil {
+			log.Errorln(err)
+		}
+	})
+
 	log.Infoln("Starting web services on port", k.ListenAddr)
 	err := http.ListenAndServe(k.ListenAddr, nil)
 	if err != nil {
@@ -362,10 +869,13 @@ func (k *Kuberhealthy) prometheusMetricsHandler(w http.ResponseWriter, r *http.R
 	log.Infoln("Client connected to status page from", r.RemoteAddr, r.UserAgent())
 	state, err := k.getCurrentState()
 	if err != nil {
-		metrics.WriteMetricError(w, state)
+		metrics.WriteMetricError(w, state, k.MetricTags)
 		return err
 	}
-	metrics := metrics.GenerateMetrics(state)
+	metrics := metrics.GenerateMetrics(state, k.MetricTags)
+	if k.PrometheusMetrics != nil {
+		metrics += k.PrometheusMetrics.GenerateMetrics()
+	}
 	// write summarized health check results back to caller
 	_, err = w.Write([]byte(metrics))
 	if err != nil {
@@ -374,6 +884,53 @@ func (k *Kuberhealthy) prometheusMetricsHandler(w http.ResponseWriter, r *http.R
 	return err
 }
 
+// checkHistoryHandler writes the recent results of each check as JSON, from
+// newest to oldest.  The check query parameter limits the response to one
+// check, and the offset and limit query parameters page through each
+// check's results.
+func (k *Kuberhealthy) checkHistoryHandler(w http.ResponseWriter, r *http.Request) error {
+	log.Infoln("Client connected to check history from", r.RemoteAddr, r.UserAgent())
+
+	query := r.URL.Query()
+	var offset, limit int
+	var err error
+	if len(query.Get("offset")) > 0 {
+		offset, err = strconv.Atoi(query.Get("offset"))
+		if err != nil || offset < 0 {
+			http.Error(w, "offset must be a number of results to skip", http.StatusBadRequest)
+			return errors.New("Invalid check history offset from " + r.RemoteAddr + ": " + query.Get("offset"))
+		}
+	}
+	if len(query.Get("limit")) > 0 {
+		limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 0 {
+			http.Error(w, "limit must be a number of results to return", http.StatusBadRequest)
+			return errors.New("Invalid check history limit from " + r.RemoteAddr + ": " + query.Get("limit"))
+		}
+	}
+
+	history := make(map[string][]health.CheckResult)
+	if k.History != nil {
+		checks := k.History.Checks()
+		if len(query.Get("check")) > 0 {
+			checks = []string{query.Get("check")}
+		}
+		for _, c := range checks {
+			history[c] = k.History.Results(c, offset, limit)
+		}
+	}
+
+	b, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	if err != nil {
+		log.Warningln("Error writing check history to caller:", err)
+	}
+	return err
+}
+
 // healthCheckHandler runs health checks against kubernetes and
 // returns a status output to a web request client
 func (k *Kuberhealthy) healthCheckHandler(w http.ResponseWriter, r *http.Request) error {
@@ -415,12 +972,43 @@ func (k *Kuberhealthy) getCurrentState() (health.State, error) {
 		return state, err
 	}
 
+	// external checks only run on the master, so they are found from their
+	// khcheck resources
+	checks := append([]KuberhealthyCheck{}, k.Checks...)
+	if k.ExternalChecks {
+		khChecks, err := listExternalChecks()
+		if err != nil {
+			return state, err
+		}
+		for _, khc := range khChecks {
+			runInterval, _ := time.ParseDuration(khc.Spec.RunInterval)
+			c := external.New(khc.Name, runInterval, khc.Spec.JobTemplate, "")
+			c.CronSchedule = khc.Spec.Schedule
+			checks = append(checks, c)
+		}
+	}
+
 	// loop over every check and apply the current state to the status return
-	for _, c := range k.Checks {
+	for _, c := range checks {
+		// disabled checks are not run, so their last state is not shown
+		if k.checkDisabled(c.Name()) {
+			continue
+		}
 		log.Debugln("Getting status of check for client:", c.Name())
 
-		// get the state from the CRD that exists for this check
+		// get the state from the CRD that exists for this check.  A fresh
+		// result stored in the check result Secret is used instead when the
+		// CRD can not be read or holds an older result, such as after the CRD
+		// was recreated.
 		checkDetails, err := getCheckCRDState(c, khClient)
+		stored, storedOK := k.storedCheckResult(c)
+		if err != nil && storedOK {
+			log.Warningln("Serving the stored result of check", c.Name(), "because its CRD state could not be fetched:", err)
+			checkDetails, err = stored, nil
+		}
+		if storedOK && checkDetails.LastRun.Before(stored.LastRun) {
+			checkDetails = stored
+		}
 		if err != nil {
 			errMessage := "System error when fetching status for check " + c.Name() + ":" + err.Error()
 			log.Errorln(errMessage)
@@ -432,6 +1020,15 @@ func (k *Kuberhealthy) getCurrentState() (health.State, error) {
 			continue
 		}
 
+		// skipped checks are not failures of their own.  The failing check
+		// they depend on is already reflected in the status.
+		checkDetails.RunInterval = k.checkInterval(c).String()
+		checkDetails.NextRun = k.nextRun(c, checkDetails.LastRun, time.Now())
+		if checkDetails.Skipped {
+			state.CheckDetails[c.Name()] = checkDetails
+			continue
+		}
+
 		// parse check status from CRD and add it to the status
 		state.AddError(checkDetails.Errors...)
 		if !checkDetails.OK {
@@ -450,5 +1047,11 @@ func (k *Kuberhealthy) getCheck(name string) (KuberhealthyCheck, error) {
 			return c, nil
 		}
 	}
+
+	k.RLock()
+	defer k.RUnlock()
+	if c, ok := k.externalChecks[name]; ok {
+		return c, nil
+	}
 	return nil, fmt.Errorf("Could not find Kuberhealthy check with name %s", name)
 }
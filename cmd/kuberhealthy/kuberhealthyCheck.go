@@ -12,8 +12,10 @@
 package main
 
 import (
+	"context"
 	"time"
 
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -27,9 +29,6 @@ type KuberhealthyCheck interface {
 	// Interval returns a run interval indicating how often this check
 	// should be performed
 	Interval() time.Duration
-	// Timeout returns a duration indicating how long we should wait for
-	// this check to run
-	Timeout() time.Duration
 	// CurrentStatus returns the current status of the check and its
 	// error messages.  The bool indicates health. (true = up and false = down).
 	// This function should not do anything complex and is expected to return
@@ -38,11 +37,71 @@ type KuberhealthyCheck interface {
 	// Run fires off a single check.  It is invoked each time the Interval
 	// ticker ticks.  Results of the error are stored within the check
 	// and not tracked from the upstream worker that ticks.  Results should
-	// show up when CurrentStatus() is invoked.
-	Run(c *kubernetes.Clientset) error
+	// show up when CurrentStatus() is invoked.  The context is cancelled
+	// when the run times out or Kuberhealthy shuts down, and Run should
+	// return promptly when it is.
+	Run(ctx context.Context, c *kubernetes.Clientset) error
 	// Shutdown is called when Kuberhealthy needs to close.  The check has up
 	// to 30 seconds to clean up anything in progress and begin shutdown.
 	// When the check completes and returns, we assume it is done shutting
 	// down.
 	Shutdown() error
 }
+
+// Timeouter is optionally implemented by checks that set how long a single
+// run may take before Kuberhealthy reports it as timed out.  Checks that do
+// not implement it use the global check timeout.
+type Timeouter interface {
+	// Timeout returns a duration indicating how long we should wait for
+	// this check to run
+	Timeout() time.Duration
+}
+
+// Retryable is optionally implemented by checks that should be retried
+// before a failure is reported, such as checks that fail on transient API
+// server errors.  Checks that do not implement it are run once.
+type Retryable interface {
+	// RetryPolicy returns the maximum number of times a check is run before
+	// its failure is reported, and the delay before the first retry.  The
+	// delay doubles with each following retry.
+	RetryPolicy() (maxAttempts int, baseDelay time.Duration)
+}
+
+// Dependent is optionally implemented by checks that are only meaningful
+// when other checks pass, such as DNS checks that depend on the API server.
+// A dependent check is skipped instead of run while any of the checks it
+// depends on failed or was skipped on its last run.
+type Dependent interface {
+	// DependsOn returns the names of the checks this check depends on
+	DependsOn() []string
+}
+
+// Reconfigurable is optionally implemented by checks with thresholds that
+// can be changed while Kuberhealthy is running.  Settings are read from the
+// runtime configuration ConfigMap.
+type Reconfigurable interface {
+	// Reconfigure applies the runtime settings of the check by setting name.
+	// Settings that are not given return to the value the check was created
+	// with.  It is only called while the check is not running.
+	Reconfigure(settings map[string]string) error
+}
+
+// RBACRequirer is optionally implemented by checks that declare the RBAC
+// rules they need, so that --printRBAC can print a manifest granting only
+// those rules and --minimalRBAC can leave out checks that need access beyond
+// the checked namespaces.
+type RBACRequirer interface {
+	// RBACRules returns the rules the check needs by namespace.  Rules under
+	// the empty namespace are cluster scoped.
+	RBACRules() map[string][]rbacv1.PolicyRule
+}
+
+// Scheduled is optionally implemented by checks that run on a cron schedule
+// instead of on their interval, such as checks that should only run during
+// business hours.  Checks that do not implement it, or return an empty
+// schedule, run on their interval.
+type Scheduled interface {
+	// Schedule returns a standard five field cron expression, such as
+	// '0 9-17 * * 1-5', or a descriptor such as '@hourly'
+	Schedule() string
+}
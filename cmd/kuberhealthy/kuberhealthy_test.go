@@ -0,0 +1,439 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/Comcast/kuberhealthy/pkg/checks/componentStatus"
+	"github.com/Comcast/kuberhealthy/pkg/checks/dnsStatus"
+	"github.com/Comcast/kuberhealthy/pkg/checks/podRestarts"
+	"github.com/Comcast/kuberhealthy/pkg/checks/podStatus"
+)
+
+// slowCheck is a check without its own timeout whose runs take a while
+type slowCheck struct {
+	*FakeCheck
+	runTime time.Duration
+}
+
+// Run sleeps for the run time of the check
+func (sc *slowCheck) Run(ctx context.Context, c *kubernetes.Clientset) error {
+	time.Sleep(sc.runTime)
+	return nil
+}
+
+func TestCheckTimeout(t *testing.T) {
+	kh := NewKuberhealthy()
+	kh.CheckTimeout = time.Minute * 10
+
+	// FakeCheck implements Timeouter
+	if kh.checkTimeout(NewFakeCheck()) != time.Minute {
+		t.Fatal("Expected the timeout of the check but got", kh.checkTimeout(NewFakeCheck()))
+	}
+
+	// wrapping the check in the interface hides its Timeout method
+	var untimed struct{ KuberhealthyCheck }
+	untimed.KuberhealthyCheck = NewFakeCheck()
+	if kh.checkTimeout(untimed) != kh.CheckTimeout {
+		t.Fatal("Expected the global check timeout but got", kh.checkTimeout(untimed))
+	}
+}
+
+func TestRunWithTimeout(t *testing.T) {
+	kh := NewKuberhealthy()
+	kh.CheckTimeout = time.Millisecond * 50
+
+	var tests = []struct {
+		description string
+		runTime     time.Duration
+		timedOut    bool
+	}{
+		{"completes", 0, false},
+		{"times out", time.Millisecond * 500, true},
+	}
+
+	for _, test := range tests {
+		var c struct{ KuberhealthyCheck }
+		c.KuberhealthyCheck = &slowCheck{FakeCheck: NewFakeCheck(), runTime: test.runTime}
+
+		err := kh.runWithTimeout(c, nil)
+		if test.timedOut != (err != nil && strings.Contains(err.Error(), "timed out after 50ms")) {
+			t.Fatal("Test", test.description, "expected timed out to be", test.timedOut, "but got error", err)
+		}
+		t.Log(test.description, err)
+	}
+}
+
+func TestRunWithTimeoutShutdown(t *testing.T) {
+	kh := NewKuberhealthy()
+	kh.CheckTimeout = time.Minute
+	kh.cancel()
+
+	var c struct{ KuberhealthyCheck }
+	c.KuberhealthyCheck = &slowCheck{FakeCheck: NewFakeCheck(), runTime: time.Millisecond * 500}
+
+	err := kh.runWithTimeout(c, nil)
+	if err == nil || !strings.Contains(err.Error(), "shutting down") {
+		t.Fatal("Expected a shutdown error but got", err)
+	}
+}
+
+// stuckCheck is a check whose runs ignore their context and only return
+// once released
+type stuckCheck struct {
+	*FakeCheck
+	release chan struct{}
+	sync.Mutex
+	runs int
+}
+
+// Run counts the run and waits to be released
+func (sc *stuckCheck) Run(ctx context.Context, c *kubernetes.Clientset) error {
+	sc.Lock()
+	sc.runs++
+	sc.Unlock()
+	<-sc.release
+	return nil
+}
+
+func TestRunWithTimeoutUnfinishedRun(t *testing.T) {
+	defaultWait := cancelledRunWait
+	cancelledRunWait = time.Millisecond * 10
+	defer func() { cancelledRunWait = defaultWait }()
+
+	kh := NewKuberhealthy()
+	kh.CheckTimeout = time.Millisecond * 50
+
+	var c struct{ KuberhealthyCheck }
+	sc := &stuckCheck{FakeCheck: NewFakeCheck(), release: make(chan struct{})}
+	c.KuberhealthyCheck = sc
+
+	err := kh.runWithTimeout(c, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatal("Expected a timeout error but got", err)
+	}
+
+	// the check is not run again while its previous run has not returned
+	err = kh.runWithTimeout(c, nil)
+	if err == nil || !strings.Contains(err.Error(), "previous run has not returned") {
+		t.Fatal("Expected the check not to run again but got", err)
+	}
+	sc.Lock()
+	runs := sc.runs
+	sc.Unlock()
+	if runs != 1 {
+		t.Fatal("Expected the check to have run once but it ran", runs, "times")
+	}
+
+	// once the previous run returns, the check runs again
+	close(sc.release)
+	for i := 0; i < 100; i++ {
+		kh.RLock()
+		unfinished := kh.unfinishedRuns[sc.Name()]
+		kh.RUnlock()
+		if !unfinished {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	err = kh.runWithTimeout(c, nil)
+	if err != nil {
+		t.Fatal("Expected the check to run after its previous run returned but got", err)
+	}
+}
+
+// flakyCheck is a retryable check that fails its first runs
+type flakyCheck struct {
+	*FakeCheck
+	failures int
+	attempts int
+	runs     int
+}
+
+// Run fails until the check has run more times than its failures
+func (fc *flakyCheck) Run(ctx context.Context, c *kubernetes.Clientset) error {
+	fc.runs++
+	if fc.runs <= fc.failures {
+		return errors.New("transient error")
+	}
+	return nil
+}
+
+// RetryPolicy retries the check with a short delay
+func (fc *flakyCheck) RetryPolicy() (int, time.Duration) {
+	return fc.attempts, time.Millisecond
+}
+
+func TestRetryDelay(t *testing.T) {
+	noJitter := func(n int64) int64 { return 0 }
+	maxJitter := func(n int64) int64 { return n - 1 }
+
+	var tests = []struct {
+		description string
+		attempt     int
+		maxDelay    time.Duration
+		jitter      func(n int64) int64
+		expected    time.Duration
+	}{
+		{"first retry", 1, time.Minute, noJitter, time.Second},
+		{"third retry", 3, time.Minute, noJitter, time.Second * 4},
+		{"capped", 10, time.Second * 10, noJitter, time.Second * 10},
+		{"no cap", 8, 0, noJitter, time.Second * 128},
+		{"jitter", 2, time.Minute, maxJitter, time.Second*3 - 1},
+		{"jitter capped", 10, time.Second * 10, maxJitter, time.Second * 10},
+		{"jitter near cap", 4, time.Second * 10, maxJitter, time.Second * 10},
+	}
+
+	for _, test := range tests {
+		delay := retryDelay(test.attempt, time.Second, test.maxDelay, test.jitter)
+		if delay != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "but got", delay)
+		}
+	}
+
+	// the jittered delay never exceeds the maximum delay
+	for attempt := 1; attempt <= 10; attempt++ {
+		delay := retryDelay(attempt, time.Second, time.Second*10, maxJitter)
+		if delay > time.Second*10 {
+			t.Fatal("Expected attempt", attempt, "to wait at most 10s but got", delay)
+		}
+	}
+}
+
+func TestRunWithRetries(t *testing.T) {
+	kh := NewKuberhealthy()
+	kh.CheckTimeout = time.Second
+	kh.RetryMaxDelay = time.Millisecond * 10
+
+	var tests = []struct {
+		description  string
+		failures     int
+		attempts     int
+		expectedRuns int
+		expectError  bool
+	}{
+		{"passes first time", 0, 3, 1, false},
+		{"passes on retry", 2, 3, 3, false},
+		{"attempts exhausted", 5, 3, 3, true},
+		{"not retried", 1, 1, 1, true},
+	}
+
+	for _, test := range tests {
+		c := &flakyCheck{FakeCheck: NewFakeCheck(), failures: test.failures, attempts: test.attempts}
+		stopped, attempts, err := kh.runWithRetries(make(chan bool), c, nil, log.WithField("check", c.Name()))
+		if stopped {
+			t.Fatal("Test", test.description, "unexpectedly stopped")
+		}
+		if c.runs != test.expectedRuns || attempts != test.expectedRuns || (err != nil) != test.expectError {
+			t.Fatal("Test", test.description, "expected", test.expectedRuns, "runs and error", test.expectError, "but got", c.runs, "runs and", err)
+		}
+		if test.expectError && test.attempts > 1 && !strings.Contains(err.Error(), "after 3 attempts") {
+			t.Fatal("Test", test.description, "expected the attempt count in the error but got", err)
+		}
+		t.Log(test.description, err)
+	}
+}
+
+func TestRunWithRetriesReportedDown(t *testing.T) {
+	kh := NewKuberhealthy()
+	kh.CheckTimeout = time.Second
+	kh.RetryMaxDelay = time.Millisecond * 10
+
+	// a check that runs without error but reports itself down every attempt
+	c := &flakyCheck{FakeCheck: NewFakeCheck(), attempts: 3}
+	c.OK = false
+	c.Errors = []string{"pod not ready", "component unhealthy"}
+
+	_, attempts, err := kh.runWithRetries(make(chan bool), c, nil, log.WithField("check", c.Name()))
+	if err != nil || attempts != 3 {
+		t.Fatal("Expected 3 attempts without a run error but got", attempts, "attempts and", err)
+	}
+	errs := withAttemptCount(c.Errors, attempts)
+	if errs[1] != "component unhealthy (failed after 3 attempts)" || errs[0] != "pod not ready" {
+		t.Fatal("Expected the attempt count on the final error but got", errs)
+	}
+	if c.Errors[1] != "component unhealthy" {
+		t.Fatal("Expected the errors of the check not to be changed but got", c.Errors)
+	}
+	errs = withAttemptCount(nil, 3)
+	if len(errs) != 1 || errs[0] != "Check is down (failed after 3 attempts)" {
+		t.Fatal("Expected an error with the attempt count but got", errs)
+	}
+}
+
+func TestDependencySkipReason(t *testing.T) {
+	kh := NewKuberhealthy()
+	fc := NewFakeCheck()
+	fc.Dependencies = []string{"APIServerChecker", "DnsStatusChecker"}
+
+	if reason := kh.dependencySkipReason(fc); len(reason) != 0 {
+		t.Fatal("Expected check with dependencies that have not run to run but it was skipped:", reason)
+	}
+
+	kh.setCheckFailed("APIServerChecker", false)
+	kh.setCheckFailed("DnsStatusChecker", true)
+	reason := kh.dependencySkipReason(fc)
+	if reason != "dependency DnsStatusChecker is failing" {
+		t.Fatal("Expected check to be skipped because of DnsStatusChecker but got reason:", reason)
+	}
+
+	kh.setCheckFailed("DnsStatusChecker", false)
+	if reason := kh.dependencySkipReason(fc); len(reason) != 0 {
+		t.Fatal("Expected check to run after its dependencies recovered but it was skipped:", reason)
+	}
+}
+
+func TestBuiltInCheckDependencies(t *testing.T) {
+	kh := NewKuberhealthy()
+	csc := componentStatus.New()
+	checks := []KuberhealthyCheck{
+		dnsStatus.New([]string{"kubernetes.default"}),
+		podStatus.New("kube-system"),
+		podRestarts.New("kube-system"),
+	}
+
+	kh.setCheckFailed(csc.Name(), false)
+	for _, c := range checks {
+		if reason := kh.dependencySkipReason(c); len(reason) != 0 {
+			t.Fatal("Expected", c.Name(), "to run while component statuses are healthy but it was skipped:", reason)
+		}
+	}
+
+	// the checks are skipped while the control plane is unhealthy
+	kh.setCheckFailed(csc.Name(), true)
+	for _, c := range checks {
+		if reason := kh.dependencySkipReason(c); reason != "dependency ComponentStatusChecker is failing" {
+			t.Fatal("Expected", c.Name(), "to be skipped because of the component status checker but got reason:", reason)
+		}
+	}
+}
+
+// blockingNotifier records the results sent to it and blocks each send
+// until it is released
+type blockingNotifier struct {
+	sync.Mutex
+	release chan bool
+	sent    []bool
+}
+
+// Notify records a result once the send is released
+func (n *blockingNotifier) Notify(checkName string, namespace string, ok bool, errs []string) error {
+	<-n.release
+	n.Lock()
+	defer n.Unlock()
+	n.sent = append(n.sent, ok)
+	return nil
+}
+
+func TestNotifyCheckResult(t *testing.T) {
+	kh := NewKuberhealthy()
+	n := &blockingNotifier{release: make(chan bool)}
+	kh.Notifiers = append(kh.Notifiers, n)
+	fc := NewFakeCheck()
+	checkLog := log.WithField("check", fc.Name())
+
+	// results are queued without waiting for the notifier
+	done := make(chan bool)
+	go func() {
+		kh.notifyCheckResult(fc, false, []string{"failed"}, checkLog)
+		kh.notifyCheckResult(fc, true, []string{}, checkLog)
+		kh.notifyCheckResult(fc, false, []string{"failed again"}, checkLog)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected notifying to not wait for a blocked notifier")
+	}
+
+	// the results of a check are sent in order
+	close(n.release)
+	for _, q := range kh.notificationQueues() {
+		q.Wait()
+	}
+	expected := []bool{false, true, false}
+	if len(n.sent) != len(expected) {
+		t.Fatal("Expected results", expected, "to be sent but got", n.sent)
+	}
+	for i := range expected {
+		if n.sent[i] != expected[i] {
+			t.Fatal("Expected results", expected, "to be sent in order but got", n.sent)
+		}
+	}
+}
+
+// TestLogFormatter tests that the text and json log formats are accepted
+func TestLogFormatter(t *testing.T) {
+	f, err := logFormatter("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.(*log.JSONFormatter); !ok {
+		t.Fatal("Expected a JSON formatter for the json log format but got", f)
+	}
+	f, err = logFormatter("text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.(*log.TextFormatter); !ok {
+		t.Fatal("Expected a text formatter for the text log format but got", f)
+	}
+	_, err = logFormatter("xml")
+	if err == nil {
+		t.Fatal("Expected an error for an unknown log format")
+	}
+}
+
+func TestSplitNodeSelectorFlag(t *testing.T) {
+	var tests = []struct {
+		value            string
+		expectedSelector string
+		expectedImage    string
+	}{
+		{"node-pool=gpu", "node-pool=gpu", ""},
+		{"node-pool=gpu,lifecycle=spot", "node-pool=gpu,lifecycle=spot", ""},
+		{"node-pool=gpu; registry.local/pause:3.1", "node-pool=gpu", "registry.local/pause:3.1"},
+	}
+
+	for _, test := range tests {
+		selector, image := splitNodeSelectorFlag(test.value)
+		if selector != test.expectedSelector || image != test.expectedImage {
+			t.Fatal("Value", test.value, "expected", test.expectedSelector, test.expectedImage, "but got", selector, image)
+		}
+	}
+}
+
+func TestSanitizeCRDName(t *testing.T) {
+	var tests = []struct {
+		name     string
+		expected string
+	}{
+		{"DaemonSetChecker", "daemonsetchecker"},
+		{"PodRestartChecker namespace kube-system", "podrestartchecker-namespace-kube-system"},
+		{"DaemonSetChecker node selector cloud.google.com/gke-nodepool=gpu", "daemonsetchecker-node-selector-cloud.google.com-gke-nodepool-gpu"},
+	}
+
+	for _, test := range tests {
+		if sanitizeCRDName(test.name) != test.expected {
+			t.Fatal("Name", test.name, "expected", test.expected, "but got", sanitizeCRDName(test.name))
+		}
+	}
+}
@@ -13,21 +13,105 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/Comcast/kuberhealthy/pkg/metrics"
-	"github.com/Comcast/kuberhealthy/pkg/checks/dnsStatus"
+	"github.com/Comcast/kuberhealthy/pkg/checks/aggregatedAPIServerCerts"
+	"github.com/Comcast/kuberhealthy/pkg/checks/apiServerAuditPolicy"
+	"github.com/Comcast/kuberhealthy/pkg/checks/auditAnomalies"
+	"github.com/Comcast/kuberhealthy/pkg/checks/autoscalerAnnotations"
+	"github.com/Comcast/kuberhealthy/pkg/checks/capabilityDrop"
+	"github.com/Comcast/kuberhealthy/pkg/checks/cgroupDriver"
+	"github.com/Comcast/kuberhealthy/pkg/checks/cidrConflict"
+	"github.com/Comcast/kuberhealthy/pkg/checks/clusterAPI"
 	"github.com/Comcast/kuberhealthy/pkg/checks/componentStatus"
+	"github.com/Comcast/kuberhealthy/pkg/checks/configMapSchema"
+	"github.com/Comcast/kuberhealthy/pkg/checks/controllerManagerLease"
+	"github.com/Comcast/kuberhealthy/pkg/checks/corsConfig"
+	"github.com/Comcast/kuberhealthy/pkg/checks/crdSchemas"
+	"github.com/Comcast/kuberhealthy/pkg/checks/crdStoredVersions"
+	"github.com/Comcast/kuberhealthy/pkg/checks/csrBacklog"
 	"github.com/Comcast/kuberhealthy/pkg/checks/daemonSet"
+	"github.com/Comcast/kuberhealthy/pkg/checks/daemonSetImage"
+	"github.com/Comcast/kuberhealthy/pkg/checks/deploymentStatus"
+	"github.com/Comcast/kuberhealthy/pkg/checks/deprecatedAPIUsage"
+	"github.com/Comcast/kuberhealthy/pkg/checks/dnsStatus"
+	"github.com/Comcast/kuberhealthy/pkg/checks/dnsTTL"
+	"github.com/Comcast/kuberhealthy/pkg/checks/egressConnectivity"
+	"github.com/Comcast/kuberhealthy/pkg/checks/endpointStatus"
+	"github.com/Comcast/kuberhealthy/pkg/checks/ephemeralStorage"
+	"github.com/Comcast/kuberhealthy/pkg/checks/eventQuality"
+	"github.com/Comcast/kuberhealthy/pkg/checks/execPermissions"
+	"github.com/Comcast/kuberhealthy/pkg/checks/haDeployments"
+	"github.com/Comcast/kuberhealthy/pkg/checks/hpaDeploymentConflict"
+	"github.com/Comcast/kuberhealthy/pkg/checks/hpaStatus"
+	"github.com/Comcast/kuberhealthy/pkg/checks/hugepages"
+	"github.com/Comcast/kuberhealthy/pkg/checks/imageManifestV2"
+	"github.com/Comcast/kuberhealthy/pkg/checks/imagePolicyWebhook"
+	"github.com/Comcast/kuberhealthy/pkg/checks/imagePull"
+	"github.com/Comcast/kuberhealthy/pkg/checks/imagePullPolicy"
+	"github.com/Comcast/kuberhealthy/pkg/checks/ingressCheck"
+	"github.com/Comcast/kuberhealthy/pkg/checks/ingressControllerHealth"
+	"github.com/Comcast/kuberhealthy/pkg/checks/ipv6Connectivity"
+	"github.com/Comcast/kuberhealthy/pkg/checks/kernelModules"
+	"github.com/Comcast/kuberhealthy/pkg/checks/kubeProxySync"
+	"github.com/Comcast/kuberhealthy/pkg/checks/kubeletConfig"
+	"github.com/Comcast/kuberhealthy/pkg/checks/namespaceEscape"
+	"github.com/Comcast/kuberhealthy/pkg/checks/namingConvention"
+	"github.com/Comcast/kuberhealthy/pkg/checks/networkMTU"
+	"github.com/Comcast/kuberhealthy/pkg/checks/networkPolicy"
+	"github.com/Comcast/kuberhealthy/pkg/checks/nodeArchitecture"
+	"github.com/Comcast/kuberhealthy/pkg/checks/nodeAutoRepair"
+	"github.com/Comcast/kuberhealthy/pkg/checks/nodeMemoryCapacity"
+	"github.com/Comcast/kuberhealthy/pkg/checks/nodePressureToggle"
+	"github.com/Comcast/kuberhealthy/pkg/checks/nodeStatus"
+	"github.com/Comcast/kuberhealthy/pkg/checks/nodeSystemd"
+	"github.com/Comcast/kuberhealthy/pkg/checks/nodeTaints"
+	"github.com/Comcast/kuberhealthy/pkg/checks/ntpSync"
+	"github.com/Comcast/kuberhealthy/pkg/checks/pdbValidity"
+	"github.com/Comcast/kuberhealthy/pkg/checks/podIPAssignment"
+	"github.com/Comcast/kuberhealthy/pkg/checks/podPreset"
+	"github.com/Comcast/kuberhealthy/pkg/checks/podQoS"
 	"github.com/Comcast/kuberhealthy/pkg/checks/podRestarts"
 	"github.com/Comcast/kuberhealthy/pkg/checks/podStatus"
+	"github.com/Comcast/kuberhealthy/pkg/checks/privilegedJustification"
+	"github.com/Comcast/kuberhealthy/pkg/checks/pvcStatus"
+	"github.com/Comcast/kuberhealthy/pkg/checks/quotaStatus"
+	"github.com/Comcast/kuberhealthy/pkg/checks/rateLimitConfig"
+	"github.com/Comcast/kuberhealthy/pkg/checks/readinessGates"
+	"github.com/Comcast/kuberhealthy/pkg/checks/registryMirror"
+	"github.com/Comcast/kuberhealthy/pkg/checks/rolloutConsistency"
+	"github.com/Comcast/kuberhealthy/pkg/checks/runtimeConcurrency"
+	"github.com/Comcast/kuberhealthy/pkg/checks/schedulingBalance"
+	"github.com/Comcast/kuberhealthy/pkg/checks/seccompProfile"
+	"github.com/Comcast/kuberhealthy/pkg/checks/secretRBAC"
+	"github.com/Comcast/kuberhealthy/pkg/checks/selfNamespace"
+	"github.com/Comcast/kuberhealthy/pkg/checks/serviceTrafficHealth"
+	"github.com/Comcast/kuberhealthy/pkg/checks/statefulSetStatus"
+	"github.com/Comcast/kuberhealthy/pkg/checks/swapDisabled"
+	"github.com/Comcast/kuberhealthy/pkg/checks/terminationMessage"
+	"github.com/Comcast/kuberhealthy/pkg/checks/tlsCertExpiry"
+	"github.com/Comcast/kuberhealthy/pkg/checks/uidRanges"
+	"github.com/Comcast/kuberhealthy/pkg/checks/upgradePDBs"
+	"github.com/Comcast/kuberhealthy/pkg/checks/watchConnections"
+	"github.com/Comcast/kuberhealthy/pkg/checks/webhookIdempotency"
+	"github.com/Comcast/kuberhealthy/pkg/checks/webhookNamespaceScope"
+	"github.com/Comcast/kuberhealthy/pkg/checks/zoneLabels"
+	"github.com/Comcast/kuberhealthy/pkg/health"
 	"github.com/Comcast/kuberhealthy/pkg/masterCalculation"
+	"github.com/Comcast/kuberhealthy/pkg/metrics"
+	"github.com/Comcast/kuberhealthy/pkg/notifiers/alertmanager"
+	"github.com/Comcast/kuberhealthy/pkg/notifiers/opsgenie"
+	"github.com/Comcast/kuberhealthy/pkg/notifiers/pagerduty"
+	"github.com/Comcast/kuberhealthy/pkg/notifiers/slack"
 	"github.com/integrii/flaggy"
 	log "github.com/sirupsen/logrus"
 )
@@ -35,6 +119,8 @@ import (
 // status represents the current Kuberhealthy OK:Error state
 var kubeConfigFile = filepath.Join(os.Getenv("HOME"), ".kube", "config")
 var listenAddress = ":8080"
+var enableGRPC = false
+var grpcListenAddress = ":9090"
 var podCheckNamespaces = "kube-system"
 var dnsEndpoints []string
 
@@ -42,17 +128,215 @@ var dnsEndpoints []string
 var sigChan chan os.Signal
 var doneChan chan bool
 var terminationGracePeriodSeconds = time.Minute * 5 // keep calibrated with kubernetes terminationGracePeriodSeconds
+var checkTimeout = time.Minute * 10                 // the run timeout of checks that do not set their own
+var checkRetryMaxDelay = time.Minute * 1            // the longest delay between retries of retryable checks
+var checkHistoryDepth = 100                         // the number of recent results kept for each check
+var checkResultTTL time.Duration                    // how long stored check results are served after a restart
+var enableExternalChecks bool                       // run external checks defined by khcheck resources
+var runtimeConfigMap = "kuberhealthy-config"        // the ConfigMap checks are reconfigured from while running
+var minimalRBAC bool                                // only run checks that need no access beyond the checked namespaces
+var printRBAC bool                                  // print the RBAC resources needed by the enabled checks and exit
 
 // flags indicating that checks of specific types should be used
 var enableForceMaster bool               // force master mode - for debugging
 var enableDebug bool                     // enable debug logging
 var DSPauseContainerImageOverride string // specify an alternate location for the DSC pause container - see #114
+var daemonSetNodeSelectors []string      // node selectors of daemonset checks, each optionally followed by ;<pause image>
 var logLevel = "info"
+var logFormat = "text"
 var enableComponentStatusChecks = true
 var enableDaemonSetChecks = true
 var enablePodRestartChecks = true
 var enablePodStatusChecks = true
 var enableDnsStatusChecks = true
+var componentStatusCheckInterval = time.Minute * 2
+var daemonSetCheckInterval = time.Minute * 15
+var podRestartCheckInterval = time.Minute * 5
+var podStatusCheckInterval = time.Minute * 2
+var dnsCheckInterval = time.Second * 15
+var enableWebhookNamespaceScopeChecks = false
+var enableControllerManagerLeaseChecks = false
+var enableSchedulingBalanceChecks = false
+var schedulingImbalanceThreshold = 0.5
+var enableNodePressureToggleChecks = false
+var pressureToggleThreshold = 3
+var pressureToggleWindow = time.Minute * 30
+var enableClusterAPIChecks = false
+var enableCSRBacklogChecks = false
+var csrBacklogThreshold = 10
+var csrPendingThreshold = time.Hour
+var enableSecretRBACChecks = false
+var secretRBACNamespaces string
+var enablePodPresetChecks = false
+var enableImageManifestChecks = false
+var imageManifestCheckNamespaces string
+var imageManifestRegistries string
+var registryCredentials string
+var enableKubeletConfigChecks = false
+var expectedKubeletConfig = "kubelet-config-expected"
+var enableWatchConnectionChecks = false
+var watchResetThreshold = 5
+var watchResetWindow = time.Minute * 10
+var enableCRDSchemaChecks = false
+var crdSchemaExceptions string
+var enableExecPermissionChecks = false
+var execPermissionAllowedSubjects string
+var enableNodeSystemdChecks = false
+var requiredSystemdServices = "containerd,kubelet"
+var enableNamingConventionChecks = false
+var namingConventionConfigMap = "naming-conventions"
+var enableAuditPolicyChecks = false
+var auditPolicyPath string
+var enableZoneLabelChecks = false
+var zonePattern = "[a-z]+-[a-z]+-[0-9][a-z]"
+var enablePDBValidityChecks = false
+var pdbCheckNamespaces string
+var enableServiceTrafficChecks = false
+var serviceTrafficCheckNamespaces string
+var serviceTrafficTimeout = time.Second * 5
+var enableUIDRangeChecks = false
+var enableEgressConnectivityChecks = false
+var egressCheckEndpoints string
+var enableSeccompProfileChecks = false
+var seccompCheckNamespaces string
+var requiredSeccompProfile = "RuntimeDefault"
+var enableCIDRConflictChecks = false
+var corporateCIDRs string
+var enableSwapDisabledChecks = false
+var allowSwap = false
+var enableSelfNamespaceChecks = true
+var enableRuntimeConcurrencyChecks = false
+var runtimeLatencyRatio = 5.0
+var enablePodIPAssignmentChecks = false
+var podIPCheckNamespaces string
+var podIPGracePeriod = time.Minute * 2
+var enableDNSTTLChecks = false
+var dnsTTLNames string
+var minDNSTTL = time.Second * 5
+var maxDNSTTL = time.Second * 300
+var minNodeMemoryGi float64
+var nodeMemoryLabelSelector string
+var enableIngressControllerChecks = false
+var ingressControllerSelector = "app.kubernetes.io/name=ingress-nginx"
+var enableKubeProxySyncChecks = false
+var kubeProxySyncThreshold = time.Second * 5
+var enableRolloutConsistencyChecks = false
+var rolloutConsistencyTimeout = time.Minute * 15
+var enableWebhookIdempotencyChecks = false
+var enableImagePolicyWebhookChecks = false
+var prohibitedTestImage = "docker.io/library/ubuntu:latest"
+var enableAuditAnomalyChecks = false
+var auditLogPath = "/var/log/kubernetes/audit.log"
+var auditLogQueryURL string
+var auditSecretReadThreshold = 100
+var auditAuthFailureThreshold = 20
+var auditKnownCIDRs string
+var registryMirrorURL string
+var enableIPv6ConnectivityChecks = false
+var enableNodeArchitectureChecks = false
+var expectedArchitecture = "amd64"
+var enableCgroupDriverChecks = false
+var enableTerminationMessageChecks = false
+var terminationMessageCheckNamespaces string
+var terminationMessageEnforcing = false
+var enableNodeTaintChecks = false
+var requiredNodeTaints string
+var enableConfigMapSchemaChecks = false
+var configMapSchemaConfigMap = "kuberhealthy-configmap-schemas"
+var enablePodQoSChecks = false
+var podQoSCheckNamespaces string
+var enableEventQualityChecks = false
+var allowedEventSources string
+var maxEventReasonLength = 64
+var enableKernelModuleChecks = false
+var requiredKernelModules = "br_netfilter,ip_vs,nf_conntrack"
+var enableHPAConflictChecks = false
+var hpaConflictTolerance = 0
+var enableCapabilityDropChecks = false
+var capabilityCheckNamespaces string
+var capabilityCheckExcludeNamespaces = "kube-system,kube-public,kube-node-lease"
+var requiredDropCapabilities = "ALL"
+var prohibitedAddCapabilities = "NET_ADMIN,SYS_ADMIN,SYS_PTRACE"
+var enableAutoscalerAnnotationChecks = false
+var enableDNSServiceChecks = false
+var dnsServiceNamespaces = "default,kube-system"
+var dnsServicesPerNamespace = 3
+var dnsClusterDomain = "cluster.local"
+var enableAggAPIServerCertChecks = false
+var aggAPIServerCertExpiryDays = 30
+var enableHugepagesChecks = false
+var enableDeprecatedAPIUsageChecks = false
+var deprecatedAPIThreshold = 0
+var enablePvcStatusChecks = false
+var pvcCheckNamespaces = "default"
+var pvcPendingThreshold = time.Minute * 5
+var enableDaemonSetImageChecks = false
+var daemonSetImageCheckNamespaces string
+var enableNodeStatusChecks = false
+var nodeStatusGracePeriod = time.Minute * 2
+var nodeStatusExcludeMasters = false
+var enableImagePullPolicyChecks = false
+var imagePullPolicyCheckNamespaces string
+var enableTLSCertExpiryChecks = false
+var tlsCheckEndpoints string
+var tlsCertWarningDays = 14
+var tlsDialTimeout = time.Second * 10
+var enableNetworkMTUChecks = false
+var expectedMTU = 1450
+var enableIngressChecks = false
+var ingressCheckURLs string
+var ingressCheckTimeout = time.Second * 10
+var ingressCheckStatusRange = "200-299"
+var ingressCheckMaxRedirects = 10
+var ingressCheckBearerTokenFile string
+var enableCRDStoredVersionChecks = false
+var enablePrivilegedJustificationChecks = false
+var privilegedJustificationNamespaces string
+var privilegedJustificationCreateEvent = false
+var enableNodeAutoRepairChecks = false
+var nodeRepairThreshold = 3
+var nodeRepairWindow = time.Hour * 24
+var enableEphemeralStorageChecks = false
+var ephemeralStorageWarningPercent = 80.0
+var enableReadinessGateChecks = false
+var readinessGateTimeout = time.Minute * 5
+var enableStatefulSetStatusChecks = false
+var statefulSetCheckNamespaces string
+var statefulSetGracePeriod = time.Minute * 10
+var enableNTPSyncChecks = false
+var maxNTPOffset = time.Second
+var enableCORSConfigChecks = false
+var enableRateLimitConfigChecks = false
+var internetFacingIngressClasses string
+var maxIngressRPS = 1000.0
+var enableHADeploymentChecks = false
+var haDeploymentCheckNamespaces string
+var haMinReplicas = 2
+var enableNamespaceEscapeChecks = false
+var namespaceEscapeConfig = "namespace-escape"
+var enableQuotaStatusChecks = false
+var quotaCheckNamespaces string
+var quotaWarningThreshold = 0.9
+var enableUpgradePDBChecks = false
+var upgradePDBNamespaces = "kube-system,monitoring"
+var enableNetworkPolicyChecks = false
+var networkPolicyConfigMap = "network-policy-matrix"
+var enableDeploymentStatusChecks = false
+var deploymentCheckNamespaces string
+var deploymentGracePeriod = time.Minute * 5
+var enableHPAStatusChecks = false
+var hpaCheckNamespaces string
+var enableEndpointStatusChecks = false
+var endpointCheckNamespaces string
+var endpointGracePeriod = time.Minute * 5
+var enableImagePullChecks = false
+var imagePullTestImage = "gcr.io/google_containers/pause:0.8.0"
+var imagePullSecret string
+var imagePullTimeout = time.Minute * 5
+
+// Metric flags
+var clusterName = ""
+var metricTags = ""
 
 // InfluxDB flags
 var enableInflux = false
@@ -60,6 +344,36 @@ var influxUrl = ""
 var influxUsername = ""
 var influxPassword = ""
 var influxDB = "http://localhost:8086"
+
+// Prometheus flags
+var enablePrometheus = false
+
+// Datadog flags
+var enableDatadog = false
+var datadogApiKey = ""
+var datadogSite = "datadoghq.com"
+var datadogTags = ""
+
+// Alertmanager flags
+var alertmanagerURL = ""
+var alertmanagerLabels = ""
+
+// Slack flags
+var slackWebhookURL = ""
+var slackChannel = ""
+var slackClusterName = ""
+var slackStatusURL = ""
+
+// PagerDuty flags
+var pagerdutyRoutingKey = ""
+var pagerdutyServiceName = "kuberhealthy"
+
+// OpsGenie flags
+var opsgenieAPIKey = ""
+var opsgenieAPIURL = opsgenie.DefaultAPIURL
+var opsgenieTeam = ""
+var opsgenieStatusURL = ""
+
 var kuberhealthy *Kuberhealthy
 
 // CRDGroup is a custom resource group name
@@ -71,6 +385,9 @@ const CRDVersion = "v1"
 // CRDResource is a custom resource name
 const CRDResource = "khstates"
 
+// ExternalCheckCRDResource is the custom resource name of external checks
+const ExternalCheckCRDResource = "khchecks"
+
 var masterCalculationInterval = time.Second * 10
 
 func getAllLogLevel() string {
@@ -82,27 +399,267 @@ func getAllLogLevel() string {
 	return strings.Join(levelStrings, ",")
 }
 
+// logFormatter returns the log formatter for a log format of text or json
+func logFormatter(format string) (log.Formatter, error) {
+	switch format {
+	case "text":
+		return &log.TextFormatter{}, nil
+	case "json":
+		return &log.JSONFormatter{}, nil
+	}
+	return nil, errors.New("unknown log format " + format + ", must be text or json")
+}
+
 func init() {
 	flaggy.SetDescription("Kuberhealthy is an in-cluster synthetic health checker for Kubernetes.")
 	flaggy.String(&kubeConfigFile, "", "kubecfg", "(optional) absolute path to the kubeconfig file")
 	flaggy.String(&listenAddress, "l", "listenAddress", "The port for kuberhealthy to listen on for web requests")
+	flaggy.Bool(&enableGRPC, "", "enableGRPC", "Set to true to serve check results with the gRPC health checking protocol.")
+	flaggy.String(&grpcListenAddress, "", "grpcListenAddress", "The port for kuberhealthy to listen on for gRPC health checks, if enabled")
+	flaggy.Duration(&checkTimeout, "", "checkTimeout", "The maximum run time of checks that do not set their own timeout.")
+	flaggy.Duration(&checkRetryMaxDelay, "", "checkRetryMaxDelay", "The longest delay between retries of checks that retry before reporting a failure.")
+	flaggy.Int(&checkHistoryDepth, "", "checkHistoryDepth", "The number of recent results of each check served on /checkHistory.")
+	flaggy.Duration(&checkResultTTL, "", "checkResultTTL", "How long check results stored in the kuberhealthy-check-state Secret are served after a restart. Defaults to twice the interval of each check.")
+	flaggy.Bool(&enableExternalChecks, "", "externalChecks", "Set to true to run external checks defined by khcheck resources.")
+	flaggy.Bool(&minimalRBAC, "", "minimalRBAC", "Set to true to only run checks that can run with namespace scoped roles in the Kuberhealthy namespace and the namespaces in podCheckNamespaces.")
+	flaggy.Bool(&printRBAC, "", "printRBAC", "Set to true to print the RBAC resources needed by the enabled checks to stdout and exit.")
+	flaggy.String(&runtimeConfigMap, "", "configConfigMap", "The ConfigMap in the Kuberhealthy namespace that check intervals, thresholds and enablement are reloaded from while running. Set to an empty string to disable reloading.")
 	flaggy.Bool(&enableComponentStatusChecks, "", "componentStatusChecks", "Set to false to disable daemonset deployment checking.")
 	flaggy.Bool(&enableDaemonSetChecks, "", "daemonsetChecks", "Set to false to disable cluster daemonset deployment and termination checking.")
 	flaggy.Bool(&enablePodRestartChecks, "", "podRestartChecks", "Set to false to disable pod restart checking.")
 	flaggy.Bool(&enablePodStatusChecks, "", "podStatusChecks", "Set to false to disable pod lifecycle phase checking.")
 	flaggy.Bool(&enableDnsStatusChecks, "", "dnsStatusChecks", "Set to false to disable DNS checks.")
+	flaggy.Duration(&componentStatusCheckInterval, "", "componentStatusCheckInterval", "The interval at which component statuses are checked.")
+	flaggy.Duration(&daemonSetCheckInterval, "", "daemonsetCheckInterval", "The interval at which daemonset deployment and termination is checked.")
+	flaggy.Duration(&podRestartCheckInterval, "", "podRestartCheckInterval", "The interval at which pod restarts are checked.")
+	flaggy.Duration(&podStatusCheckInterval, "", "podStatusCheckInterval", "The interval at which pod lifecycle phases are checked.")
+	flaggy.Duration(&dnsCheckInterval, "", "dnsCheckInterval", "The interval at which DNS resolution is checked.")
+	flaggy.Bool(&enableWebhookNamespaceScopeChecks, "", "webhookNamespaceScopeChecks", "Set to true to enable checking for mutating webhooks that intercept the kuberhealthy namespace.")
+	flaggy.Bool(&enableControllerManagerLeaseChecks, "", "controllerManagerLeaseChecks", "Set to true to enable kube-controller-manager and kube-scheduler leader lease checking.")
+	flaggy.Bool(&enableSchedulingBalanceChecks, "", "schedulingBalanceChecks", "Set to true to enable checking for nodes running significantly more pods than their peers.")
+	flaggy.Float64(&schedulingImbalanceThreshold, "", "schedulingImbalanceThreshold", "The coefficient of variation of pods per node above which scheduling is considered imbalanced.")
+	flaggy.Bool(&enableNodePressureToggleChecks, "", "nodePressureToggleChecks", "Set to true to enable checking for node pressure conditions that are flapping.")
+	flaggy.Int(&pressureToggleThreshold, "", "pressureToggleThreshold", "The number of node pressure condition transitions allowed within the pressure toggle window.")
+	flaggy.Duration(&pressureToggleWindow, "", "pressureToggleWindow", "The window of time in which node pressure condition transitions are counted.")
+	flaggy.Bool(&enableClusterAPIChecks, "", "clusterAPIChecks", "Set to true to enable Cluster API cluster and provider checking.")
+	flaggy.Bool(&enableCSRBacklogChecks, "", "csrBacklogChecks", "Set to true to enable certificate signing request backlog checking.")
+	flaggy.Int(&csrBacklogThreshold, "", "csrBacklogThreshold", "The number of pending certificate signing requests above which the queue is considered backlogged.")
+	flaggy.Duration(&csrPendingThreshold, "", "csrPendingThreshold", "The maximum amount of time a certificate signing request may be pending.")
+	flaggy.Bool(&enableSecretRBACChecks, "", "secretRBACChecks", "Set to true to enable checking for roles that grant overly broad access to secrets.")
+	flaggy.String(&secretRBACNamespaces, "", "secretRBACNamespaces", "The comma separated list of namespaces in which to check roles for secret access. Defaults to all namespaces.")
+	flaggy.Bool(&enablePodPresetChecks, "", "podPresetChecks", "Set to true to enable checking for conflicting PodPresets.")
+	flaggy.Bool(&enableImageManifestChecks, "", "imageManifestChecks", "Set to true to enable checking that running images are not served with deprecated V1 manifests.")
+	flaggy.String(&imageManifestCheckNamespaces, "", "imageManifestCheckNamespaces", "The comma separated list of namespaces in which to check image manifests. Defaults to all namespaces.")
+	flaggy.String(&imageManifestRegistries, "", "imageManifestRegistries", "The comma separated list of registries whose images should have their manifests checked.")
+	flaggy.String(&registryCredentials, "", "registryCredentials", "The name of a kubernetes.io/dockerconfigjson secret holding registry credentials for image manifest checks, as 'name' or 'namespace/name'.")
+	flaggy.Bool(&enableKubeletConfigChecks, "", "kubeletConfigChecks", "Set to true to enable checking that every kubelet runs the expected configuration.")
+	flaggy.String(&expectedKubeletConfig, "", "expectedKubeletConfig", "The ConfigMap holding expected kubelet configuration values, as 'name' or 'namespace/name'.")
+	flaggy.Bool(&enableWatchConnectionChecks, "", "watchConnectionChecks", "Set to true to enable checking for excessive watch connection resets.")
+	flaggy.Int(&watchResetThreshold, "", "watchResetThreshold", "The number of watch connection resets allowed within the watch reset window.")
+	flaggy.Duration(&watchResetWindow, "", "watchResetWindow", "The window of time in which watch connection resets are counted.")
+	flaggy.Bool(&enableCRDSchemaChecks, "", "crdSchemaChecks", "Set to true to enable checking that all CustomResourceDefinitions have OpenAPI schemas.")
+	flaggy.String(&crdSchemaExceptions, "", "crdSchemaExceptions", "The comma separated list of CustomResourceDefinition names to skip when checking schemas.")
+	flaggy.Bool(&enableExecPermissionChecks, "", "execPermissionChecks", "Set to true to enable auditing RBAC bindings that grant pod exec and attach.")
+	flaggy.String(&execPermissionAllowedSubjects, "", "execPermissionAllowedSubjects", "The comma separated list of operator users, groups, and service accounts (as system:serviceaccount:<namespace>:<name>) allowed cluster-wide pod exec and attach.")
+	flaggy.Bool(&enableNodeSystemdChecks, "", "nodeSystemdChecks", "Set to true to enable checking that required systemd services are running on every node.")
+	flaggy.String(&requiredSystemdServices, "", "requiredSystemdServices", "The comma separated list of systemd services that must be active and running on every node.")
+	flaggy.Bool(&enableNamingConventionChecks, "", "namingConventionChecks", "Set to true to enable the advisory check of resource names against naming conventions.")
+	flaggy.String(&namingConventionConfigMap, "", "namingConventionConfigMap", "The ConfigMap holding naming convention patterns per resource type, as 'name' or 'namespace/name'.")
+	flaggy.Bool(&enableAuditPolicyChecks, "", "auditPolicyChecks", "Set to true to enable validation of the kube-apiserver audit policy.")
+	flaggy.String(&auditPolicyPath, "", "auditPolicyPath", "The path to the kube-apiserver audit policy file. Defaults to the path found by inspecting the kube-apiserver static pods.")
+	flaggy.Bool(&enableZoneLabelChecks, "", "zoneLabelChecks", "Set to true to enable checking that all nodes have zone and region topology labels.")
+	flaggy.String(&zonePattern, "", "zonePattern", "The regular expression that node zone label values must match.")
+	flaggy.Bool(&enablePDBValidityChecks, "", "pdbValidityChecks", "Set to true to enable checking for PodDisruptionBudgets that provide no protection.")
+	flaggy.String(&pdbCheckNamespaces, "", "pdbCheckNamespaces", "The comma separated list of namespaces in which to check PodDisruptionBudgets. Defaults to all namespaces.")
+	flaggy.Bool(&enableServiceTrafficChecks, "", "serviceTrafficChecks", "Set to true to enable checking that ClusterIP services have ready endpoints and accept TCP connections from a test pod.")
+	flaggy.String(&serviceTrafficCheckNamespaces, "", "serviceTrafficCheckNamespaces", "The comma separated list of namespaces in which to check services. Defaults to all namespaces.")
+	flaggy.Duration(&serviceTrafficTimeout, "", "serviceTrafficTimeout", "The timeout of each TCP connection made to a service by the service traffic check.")
+	flaggy.Bool(&enableUIDRangeChecks, "", "uidRangeChecks", "Set to true to enable checking for namespaces with overlapping UID or supplemental group ranges.")
+	flaggy.Bool(&enableEgressConnectivityChecks, "", "egressConnectivityChecks", "Set to true to enable checking that pods can connect to required external endpoints.")
+	flaggy.String(&egressCheckEndpoints, "", "egressCheckEndpoints", "The comma separated list of external endpoints to connect to in the form tcp://<host>:<port>, http://<host>/<path>, or https://<host>/<path>.")
+	flaggy.Bool(&enableSeccompProfileChecks, "", "seccompProfileChecks", "Set to true to enable checking that pods run with a seccomp profile.")
+	flaggy.String(&seccompCheckNamespaces, "", "seccompCheckNamespaces", "The comma separated list of namespaces in which to check pod seccomp profiles. Defaults to all namespaces.")
+	flaggy.String(&requiredSeccompProfile, "", "requiredSeccompProfile", "The seccomp profile pods must run with. Either RuntimeDefault, which also accepts Localhost profiles, or Localhost.")
+	flaggy.Bool(&enableCIDRConflictChecks, "", "cidrConflictChecks", "Set to true to enable checking that the cluster pod and service CIDRs do not overlap corporate network ranges.")
+	flaggy.String(&corporateCIDRs, "", "corporateCIDRs", "The comma separated list of corporate network CIDRs that the cluster CIDRs must not overlap.")
+	flaggy.Bool(&enableSwapDisabledChecks, "", "swapDisabledChecks", "Set to true to enable checking that swap is disabled on all nodes.")
+	flaggy.Bool(&allowSwap, "", "allowSwap", "Set to true to allow swap on nodes of Kubernetes 1.28 and later clusters when the kubelet is configured with failSwapOn false.")
+	flaggy.Bool(&enableSelfNamespaceChecks, "", "selfNamespaceChecks", "Set to false to disable checking that the Kuberhealthy namespace is active and shutting down when it is terminating.")
+	flaggy.Bool(&enableRuntimeConcurrencyChecks, "", "runtimeConcurrencyChecks", "Set to true to enable checking for container runtimes that are saturated with concurrent operations.")
+	flaggy.Float64(&runtimeLatencyRatio, "", "runtimeLatencyRatio", "The ratio of p99 to p50 container runtime operation latency above which operations are considered to be queuing.")
+	flaggy.Bool(&enablePodIPAssignmentChecks, "", "podIPAssignmentChecks", "Set to true to enable checking for running pods without an IP address.")
+	flaggy.String(&podIPCheckNamespaces, "", "podIPCheckNamespaces", "The comma separated list of namespaces in which to check pod IP addresses. Defaults to all namespaces.")
+	flaggy.Duration(&podIPGracePeriod, "", "podIPGracePeriod", "How long a pod may run without an IP address before it is reported.")
+	flaggy.Bool(&enableDNSTTLChecks, "", "dnsTTLChecks", "Set to true to enable checking that cluster DNS records are served with reasonable TTLs.")
+	flaggy.String(&dnsTTLNames, "", "dnsTTLNames", "The comma separated list of names to look up when checking DNS TTLs. Defaults to kubernetes.default.")
+	flaggy.Duration(&minDNSTTL, "", "minDNSTTL", "The shortest TTL allowed for cluster DNS records.")
+	flaggy.Duration(&maxDNSTTL, "", "maxDNSTTL", "The longest TTL allowed for cluster DNS records.")
+	flaggy.Float64(&minNodeMemoryGi, "", "minNodeMemoryGi", "The minimum memory capacity in Gi expected on nodes.  Set above 0 to enable node memory capacity checking.")
+	flaggy.String(&nodeMemoryLabelSelector, "", "nodeMemoryLabelSelector", "The label selector of the nodes to check the memory capacity of.  All nodes are checked when empty.")
+	flaggy.Bool(&enableIngressControllerChecks, "", "ingressControllerChecks", "Set to true to enable checking that ingress controllers are processing traffic.")
+	flaggy.String(&ingressControllerSelector, "", "ingressControllerSelector", "The label selector of ingress controller pods.")
+	flaggy.Bool(&enableKubeProxySyncChecks, "", "kubeProxySyncChecks", "Set to true to enable checking that kube-proxy synchronizes proxy rules quickly.")
+	flaggy.Duration(&kubeProxySyncThreshold, "", "kubeProxySyncThreshold", "The p99 kube-proxy proxy rule sync and network programming latency above which nodes are reported.")
+	flaggy.Bool(&enableRolloutConsistencyChecks, "", "rolloutConsistencyChecks", "Set to true to enable checking for Deployments stuck in a rolling update.")
+	flaggy.Duration(&rolloutConsistencyTimeout, "", "rolloutConsistencyTimeout", "How long a Deployment may run pods from more than one pod template before it is reported.")
+	flaggy.Bool(&enableWebhookIdempotencyChecks, "", "webhookIdempotencyChecks", "Set to true to enable checking that mutating admission webhooks are idempotent.")
+	flaggy.Bool(&enableImagePolicyWebhookChecks, "", "imagePolicyWebhookChecks", "Set to true to enable checking that the image policy webhook rejects prohibited images.")
+	flaggy.String(&prohibitedTestImage, "", "prohibitedTestImage", "An image the image policy webhook is expected to reject.")
+	flaggy.Bool(&enableAuditAnomalyChecks, "", "auditAnomalyChecks", "Set to true to enable checking audit events for anomalous request patterns.")
+	flaggy.String(&auditLogPath, "", "auditLogPath", "The path of the audit log file to read when no audit log query URL is set.")
+	flaggy.String(&auditLogQueryURL, "", "auditLogQueryURL", "A Loki or Elasticsearch query URL that returns audit events.  Used instead of the audit log file when set.")
+	flaggy.Int(&auditSecretReadThreshold, "", "auditSecretReadThreshold", "The number of secret reads by a single service account allowed per check run.")
+	flaggy.Int(&auditAuthFailureThreshold, "", "auditAuthFailureThreshold", "The number of authentication failures from a single source IP allowed per check run.")
+	flaggy.String(&auditKnownCIDRs, "", "auditKnownCIDRs", "A comma separated list of CIDRs API requests are expected from.  Requests from other IPs are reported when set.")
+	flaggy.String(&registryMirrorURL, "", "registryMirrorURL", "The URL of a Docker Hub pull-through registry mirror.  Set to enable registry mirror checking.")
+	flaggy.Bool(&enableIPv6ConnectivityChecks, "", "ipv6ConnectivityChecks", "Set to true to enable checking IPv6 pod addressing and connectivity on dual-stack clusters.")
+	flaggy.Bool(&enableNodeArchitectureChecks, "", "nodeArchitectureChecks", "Set to true to enable checking that nodes run the expected CPU architectures.")
+	flaggy.String(&expectedArchitecture, "", "expectedArchitecture", "A comma separated list of the CPU architectures nodes are expected to run.")
+	flaggy.Bool(&enableCgroupDriverChecks, "", "cgroupDriverChecks", "Set to true to enable checking that the kubelet and container runtime cgroup drivers match on every node.")
+	flaggy.Bool(&enableTerminationMessageChecks, "", "terminationMessageChecks", "Set to true to enable checking the termination message policy of critical containers.")
+	flaggy.String(&terminationMessageCheckNamespaces, "", "terminationMessageCheckNamespaces", "The comma separated list of namespaces in which to check termination message policies. Defaults to all namespaces.")
+	flaggy.Bool(&terminationMessageEnforcing, "", "terminationMessageEnforcing", "Set to true to fail the termination message check on violations instead of only logging them.")
+	flaggy.Bool(&enableNodeTaintChecks, "", "nodeTaintChecks", "Set to true to enable checking that node taints are applied and tolerated.")
+	flaggy.String(&requiredNodeTaints, "", "requiredNodeTaints", "A comma separated list of key=value:Effect taints required on nodes labeled with the same key and value.")
+	flaggy.Bool(&enableConfigMapSchemaChecks, "", "configMapSchemaChecks", "Set to true to enable validating ConfigMaps against JSON schemas.")
+	flaggy.String(&configMapSchemaConfigMap, "", "configMapSchemaConfigMap", "The ConfigMap holding JSON schemas keyed by ConfigMap name, as 'name' or 'namespace/name'.")
+	flaggy.Bool(&enablePodQoSChecks, "", "podQoSChecks", "Set to true to enable checking that pods run in a suitable QoS class.")
+	flaggy.String(&podQoSCheckNamespaces, "", "podQoSCheckNamespaces", "The comma separated list of namespaces in which to check pod QoS classes. Defaults to all namespaces.")
+	flaggy.Bool(&enableEventQualityChecks, "", "eventQualityChecks", "Set to true to enable checking events for misuse of the events API.")
+	flaggy.String(&allowedEventSources, "", "allowedEventSources", "A comma separated list of event source components allowed in addition to the Kubernetes components.")
+	flaggy.Int(&maxEventReasonLength, "", "maxEventReasonLength", "The maximum length of an event reason.")
+	flaggy.Bool(&enableKernelModuleChecks, "", "kernelModuleChecks", "Set to true to enable checking that required kernel modules are loaded on every node.")
+	flaggy.String(&requiredKernelModules, "", "requiredKernelModules", "A comma separated list of kernel modules required on every node.")
+	flaggy.Bool(&enableHPAConflictChecks, "", "hpaConflictChecks", "Set to true to enable checking for Deployments whose replicas conflict with their HorizontalPodAutoscaler.")
+	flaggy.Int(&hpaConflictTolerance, "", "hpaConflictTolerance", "The number of replicas a Deployment may differ from the replicas desired by its HorizontalPodAutoscaler.")
+	flaggy.Bool(&enableCapabilityDropChecks, "", "capabilityDropChecks", "Set to true to enable checking that containers drop required capabilities and do not add prohibited ones.")
+	flaggy.String(&capabilityCheckNamespaces, "", "capabilityCheckNamespaces", "The comma separated list of namespaces in which to check container capabilities. Defaults to all namespaces.")
+	flaggy.String(&capabilityCheckExcludeNamespaces, "", "capabilityCheckExcludeNamespaces", "The comma separated list of namespaces excluded from capability checks.")
+	flaggy.String(&requiredDropCapabilities, "", "requiredDropCapabilities", "The comma separated list of capabilities every container must drop.")
+	flaggy.String(&prohibitedAddCapabilities, "", "prohibitedAddCapabilities", "The comma separated list of capabilities containers must not add.")
+	flaggy.Bool(&enableAutoscalerAnnotationChecks, "", "autoscalerAnnotationChecks", "Set to true to enable checking that the cluster autoscaler respects scale down protection annotations.")
+	flaggy.Bool(&enableDNSServiceChecks, "", "dnsServiceChecks", "Set to true to enable checking that Service names resolve to their ClusterIPs from a pod.")
+	flaggy.String(&dnsServiceNamespaces, "", "dnsServiceNamespaces", "The comma separated list of namespaces from which to resolve Service names.")
+	flaggy.Int(&dnsServicesPerNamespace, "", "dnsServicesPerNamespace", "The number of Services to resolve from each namespace.")
+	flaggy.String(&dnsClusterDomain, "", "dnsClusterDomain", "The cluster domain Service names are qualified with.")
+	flaggy.Bool(&enableAggAPIServerCertChecks, "", "aggAPIServerCertChecks", "Set to true to enable checking that aggregated API server certificates match the caBundle of their APIService.")
+	flaggy.Int(&aggAPIServerCertExpiryDays, "", "aggAPIServerCertExpiryDays", "The minimum number of days aggregated API server certificates must remain valid.")
+	flaggy.Bool(&enableHugepagesChecks, "", "hugepagesChecks", "Set to true to enable checking that nodes have the hugepages allocated that their pods request.")
+	flaggy.Bool(&enableDeprecatedAPIUsageChecks, "", "deprecatedAPIUsageChecks", "Set to true to enable checking for requests to deprecated APIs.")
+	flaggy.Int(&deprecatedAPIThreshold, "", "deprecatedAPIThreshold", "The number of requests to a deprecated API allowed between check runs.")
+	flaggy.Bool(&enablePvcStatusChecks, "", "enablePvcStatusChecks", "Set to true to enable checking for PersistentVolumeClaims that are not bound.")
+	flaggy.String(&pvcCheckNamespaces, "", "pvcCheckNamespaces", "The comma separated list of namespaces on which to check PersistentVolumeClaim binding status, if enabled.")
+	flaggy.Duration(&pvcPendingThreshold, "", "pvcPendingThreshold", "How long a PersistentVolumeClaim may be Pending before an error is shown.")
+	flaggy.Bool(&enableDaemonSetImageChecks, "", "daemonSetImageChecks", "Set to true to enable checking that the pods of each DaemonSet run the same images.")
+	flaggy.String(&daemonSetImageCheckNamespaces, "", "daemonSetImageCheckNamespaces", "The comma separated list of namespaces in which to check DaemonSet images. Defaults to all namespaces.")
+	flaggy.Bool(&enableNodeStatusChecks, "", "nodeStatusChecks", "Set to true to enable checking for nodes that are NotReady.")
+	flaggy.Duration(&nodeStatusGracePeriod, "", "nodeStatusGracePeriod", "How long a node may be NotReady before an error is shown.")
+	flaggy.Bool(&nodeStatusExcludeMasters, "", "nodeStatusExcludeMasters", "Set to true to skip master nodes when checking for NotReady nodes.")
+	flaggy.Bool(&enableImagePullPolicyChecks, "", "imagePullPolicyChecks", "Set to true to enable checking that container pull policies suit their image references.")
+	flaggy.String(&imagePullPolicyCheckNamespaces, "", "imagePullPolicyCheckNamespaces", "The comma separated list of namespaces in which to check image pull policies. Defaults to all namespaces.")
+	flaggy.Bool(&enableTLSCertExpiryChecks, "", "tlsCertExpiryChecks", "Set to true to enable checking TLS endpoint certificates for upcoming expiry.")
+	flaggy.String(&tlsCheckEndpoints, "", "tlsCheckEndpoints", "The comma separated list of host:port TLS endpoints whose certificates are checked for expiry.")
+	flaggy.Int(&tlsCertWarningDays, "", "tlsCertWarningDays", "The number of days before a TLS endpoint certificate expires that an error is shown.")
+	flaggy.Duration(&tlsDialTimeout, "", "tlsDialTimeout", "The timeout for connecting to each TLS endpoint.")
+	flaggy.Bool(&enableNetworkMTUChecks, "", "networkMTUChecks", "Set to true to enable checking that large requests cross the pod network between nodes.")
+	flaggy.Int(&expectedMTU, "", "expectedMTU", "The MTU the pod network is expected to carry.")
+	flaggy.Bool(&enableIngressChecks, "", "ingressChecks", "Set to true to enable checking that Ingress URLs respond with an acceptable status.")
+	flaggy.String(&ingressCheckURLs, "", "ingressCheckURLs", "The comma separated list of http or https Ingress URLs to request.")
+	flaggy.Duration(&ingressCheckTimeout, "", "ingressCheckTimeout", "The timeout for each Ingress URL request.")
+	flaggy.String(&ingressCheckStatusRange, "", "ingressCheckStatusRange", "The range of acceptable Ingress URL response codes given as min-max.")
+	flaggy.Int(&ingressCheckMaxRedirects, "", "ingressCheckMaxRedirects", "The maximum number of redirects followed for each Ingress URL.")
+	flaggy.String(&ingressCheckBearerTokenFile, "", "ingressCheckBearerTokenFile", "The path of a file holding a bearer token sent with every Ingress URL request.")
+	flaggy.Bool(&enableCRDStoredVersionChecks, "", "crdStoredVersionChecks", "Set to true to enable checking CustomResourceDefinition storage versions and conversion strategies.")
+	flaggy.Bool(&enablePrivilegedJustificationChecks, "", "privilegedJustificationChecks", "Set to true to enable checking that privileged pods have a justification annotation.")
+	flaggy.String(&privilegedJustificationNamespaces, "", "privilegedJustificationNamespaces", "The comma separated list of namespaces in which to check privileged pods.  All namespaces are checked when empty.")
+	flaggy.Bool(&privilegedJustificationCreateEvent, "", "privilegedJustificationCreateEvent", "Set to true to create a warning Event on privileged pods without a justification annotation.")
+	flaggy.Bool(&enableNodeAutoRepairChecks, "", "nodeAutoRepairChecks", "Set to true to enable checking GKE node auto-repair and repeated node repairs.")
+	flaggy.Int(&nodeRepairThreshold, "", "nodeRepairThreshold", "The number of repairs of a single node within the node repair window above which an error is shown.")
+	flaggy.Duration(&nodeRepairWindow, "", "nodeRepairWindow", "The window in which node repairs are counted.")
+	flaggy.Bool(&enableEphemeralStorageChecks, "", "ephemeralStorageChecks", "Set to true to enable checking for pods close to their ephemeral storage limit.")
+	flaggy.Float64(&ephemeralStorageWarningPercent, "", "ephemeralStorageWarningPercent", "The percent of its ephemeral storage limit above which a pod is reported.")
+	flaggy.Bool(&enableReadinessGateChecks, "", "readinessGateChecks", "Set to true to enable checking that pod readiness gate conditions are set.")
+	flaggy.Duration(&readinessGateTimeout, "", "readinessGateTimeout", "How long after a pod is created its readiness gate conditions may be missing before it is reported.")
+	flaggy.Bool(&enableStatefulSetStatusChecks, "", "statefulSetStatusChecks", "Set to true to enable checking that all StatefulSet replicas are ready.")
+	flaggy.String(&statefulSetCheckNamespaces, "", "statefulSetCheckNamespaces", "The comma separated list of namespaces in which to check StatefulSets. Defaults to all namespaces.")
+	flaggy.Duration(&statefulSetGracePeriod, "", "statefulSetGracePeriod", "How long a StatefulSet may have fewer ready replicas than desired before it is reported.")
+	flaggy.Bool(&enableNTPSyncChecks, "", "ntpSyncChecks", "Set to true to enable checking that node clocks are synchronized with NTP.")
+	flaggy.Duration(&maxNTPOffset, "", "maxNTPOffset", "The largest clock offset from NTP allowed on a node.")
+	flaggy.Bool(&enableCORSConfigChecks, "", "corsConfigChecks", "Set to true to enable checking Ingresses for unsafe CORS configuration.")
+	flaggy.Bool(&enableRateLimitConfigChecks, "", "rateLimitConfigChecks", "Set to true to enable checking that internet facing Ingresses are rate limited.")
+	flaggy.String(&internetFacingIngressClasses, "", "internetFacingIngressClasses", "The comma separated list of ingress classes whose Ingresses are internet facing.")
+	flaggy.Float64(&maxIngressRPS, "", "maxIngressRPS", "The highest rate limit in requests per second allowed on internet facing Ingresses.")
+	flaggy.Bool(&enableHADeploymentChecks, "", "haDeploymentChecks", "Set to true to enable checking that Deployments spread their replicas with pod anti-affinity.")
+	flaggy.String(&haDeploymentCheckNamespaces, "", "haDeploymentCheckNamespaces", "The comma separated list of namespaces in which to check Deployment anti-affinity. Defaults to all namespaces.")
+	flaggy.Int(&haMinReplicas, "", "haMinReplicas", "The number of replicas at which Deployments must have pod anti-affinity rules.")
+	flaggy.Bool(&enableNamespaceEscapeChecks, "", "namespaceEscapeChecks", "Set to true to enable checking that pods only run in their expected namespaces.")
+	flaggy.String(&namespaceEscapeConfig, "", "namespaceEscapeConfig", "The ConfigMap holding the expected namespaces of pods by label selector, as 'name' or 'namespace/name'.")
+	flaggy.Bool(&enableQuotaStatusChecks, "", "quotaStatusChecks", "Set to true to enable checking for ResourceQuotas that are close to being exhausted.")
+	flaggy.String(&quotaCheckNamespaces, "", "quotaCheckNamespaces", "The comma separated list of namespaces in which to check ResourceQuotas. Defaults to all namespaces.")
+	flaggy.Float64(&quotaWarningThreshold, "", "quotaWarningThreshold", "The fraction of a ResourceQuota hard limit, such as 0.9, at which its usage is reported.")
+	flaggy.Bool(&enableUpgradePDBChecks, "", "upgradePDBChecks", "Set to true to enable checking for PodDisruptionBudgets in critical namespaces that would block node drains during upgrades.")
+	flaggy.String(&upgradePDBNamespaces, "", "upgradePDBNamespaces", "The comma separated list of critical namespaces in which to check that PodDisruptionBudgets allow node drains.")
+	flaggy.Bool(&enableNetworkPolicyChecks, "", "networkPolicyChecks", "Set to true to enable checking that NetworkPolicies allow and block connections between namespaces as expected.")
+	flaggy.String(&networkPolicyConfigMap, "", "networkPolicyConfigMap", "The ConfigMap holding the expected connectivity between namespaces, as 'name' or 'namespace/name'.")
+	flaggy.Bool(&enableDeploymentStatusChecks, "", "deploymentStatusChecks", "Set to true to enable checking that Deployment rollouts are not stuck.")
+	flaggy.String(&deploymentCheckNamespaces, "", "deploymentCheckNamespaces", "The comma separated list of namespaces in which to check Deployments. Defaults to all namespaces.")
+	flaggy.Duration(&deploymentGracePeriod, "", "deploymentGracePeriod", "How long a Deployment may have fewer updated or available replicas than desired before it is reported.")
+	flaggy.Bool(&enableHPAStatusChecks, "", "hpaStatusChecks", "Set to true to enable checking that HorizontalPodAutoscalers are able to scale their targets.")
+	flaggy.String(&hpaCheckNamespaces, "", "hpaCheckNamespaces", "The comma separated list of namespaces in which to check HorizontalPodAutoscalers. Defaults to all namespaces.")
+	flaggy.Bool(&enableEndpointStatusChecks, "", "endpointStatusChecks", "Set to true to enable checking that Services have ready endpoints.")
+	flaggy.String(&endpointCheckNamespaces, "", "endpointCheckNamespaces", "The comma separated list of namespaces in which to check Service endpoints. Defaults to all namespaces.")
+	flaggy.Duration(&endpointGracePeriod, "", "endpointGracePeriod", "How long a Service may have no ready endpoints before it is reported.")
+	flaggy.Bool(&enableImagePullChecks, "", "imagePullChecks", "Set to true to enable checking that a test image can be pulled on every node.")
+	flaggy.String(&imagePullTestImage, "", "imagePullTestImage", "The image pulled on every node by the image pull check.")
+	flaggy.String(&imagePullSecret, "", "imagePullSecret", "The name of an image pull secret in the Kuberhealthy namespace used to pull the image pull test image.")
+	flaggy.Duration(&imagePullTimeout, "", "imagePullTimeout", "How long every node has to pull the image pull test image.")
 	flaggy.Bool(&enableForceMaster, "", "forceMaster", "Set to true to enable local testing, forced master mode.")
 	flaggy.Bool(&enableDebug, "d", "debug", "Set to true to enable debug.")
 	flaggy.String(&DSPauseContainerImageOverride, "", "dsPauseContainerImageOverride", "Set an alternate image location for the pause container the daemon set checker uses for its daemon set configuration.")
+	flaggy.StringSlice(&daemonSetNodeSelectors, "", "daemonsetNodeSelector", "A node selector, such as node-pool=gpu, to run a separate daemon set check on the matching nodes.  May be repeated.  A pause container image for the check can follow the selector after a ';'.")
 	flaggy.String(&podCheckNamespaces, "", "podCheckNamespaces", "The comma separated list of namespaces on which to check for pod status and restarts, if enabled.")
 	flaggy.String(&logLevel, "", "log-level", fmt.Sprintf("Log level to be used one of [%s].", getAllLogLevel()))
+	flaggy.String(&logFormat, "", "logFormat", "Log format to be used, either text or json.")
 	flaggy.StringSlice(&dnsEndpoints, "", "dnsEndpoints", "The comma separated list of dns endpoints to check, if enabled. Defaults to kubernetes.default")
+	// Metric flags
+	flaggy.String(&clusterName, "", "clusterName", "The name of this cluster, added as the cluster tag to every forwarded metric and included in Slack messages, PagerDuty incidents and OpsGenie alerts")
+	flaggy.String(&metricTags, "", "metricTags", "The comma separated list of key=value tags added to every forwarded metric")
+
 	// Influx flags
 	flaggy.String(&influxUsername, "", "influxUser", "Username for the InfluxDB instance")
 	flaggy.String(&influxPassword, "", "influxPassword", "Password for the InfluxDB instance")
 	flaggy.String(&influxUrl, "", "influxUrl", "Address for the InfluxDB instance")
 	flaggy.String(&influxDB, "", "influxDB", "Name of the InfluxDB database")
 	flaggy.Bool(&enableInflux, "", "enableInflux", "Set to true to enable metric forwarding to Influx DB.")
+
+	// Prometheus flags
+	flaggy.Bool(&enablePrometheus, "", "enablePrometheus", "Set to true to enable exposing check status, duration, and master metrics to Prometheus on /metrics.")
+
+	// Datadog flags
+	flaggy.String(&datadogApiKey, "", "datadogApiKey", "API key for the Datadog account")
+	flaggy.String(&datadogSite, "", "datadogSite", "The Datadog site to submit metrics to, such as datadoghq.com or datadoghq.eu")
+	flaggy.String(&datadogTags, "", "datadogTags", "The comma separated list of key:value tags added to every metric submitted to Datadog")
+	flaggy.Bool(&enableDatadog, "", "enableDatadog", "Set to true to enable metric forwarding to Datadog.")
+
+	// Alertmanager flags
+	flaggy.String(&alertmanagerURL, "", "alertmanagerURL", "The URL of an Alertmanager to raise alerts for failing checks in, such as http://alertmanager.monitoring:9093")
+	flaggy.String(&alertmanagerLabels, "", "alertmanagerLabels", "The comma separated list of key=value labels added to every alert raised in Alertmanager")
+
+	// Slack flags
+	flaggy.String(&slackWebhookURL, "", "slackWebhookURL", "The URL of a Slack incoming webhook to post a message to when a check starts failing or recovers")
+	flaggy.String(&slackChannel, "", "slackChannel", "The Slack channel to post messages to instead of the default channel of the webhook, such as #ops")
+	flaggy.String(&slackClusterName, "", "slackClusterName", "The name of this cluster included in every Slack message.  Defaults to clusterName.")
+	flaggy.String(&slackStatusURL, "", "slackStatusURL", "The URL of the Kuberhealthy status page linked in every Slack message.  Defaults to the Kuberhealthy service in the Kuberhealthy namespace.")
+
+	// PagerDuty flags
+	flaggy.String(&pagerdutyRoutingKey, "", "pagerdutyRoutingKey", "The integration routing key of a PagerDuty service to open an incident in when a check starts failing")
+	flaggy.String(&pagerdutyServiceName, "", "pagerdutyServiceName", "The source named in every PagerDuty incident")
+
+	// OpsGenie flags
+	flaggy.String(&opsgenieAPIKey, "", "opsgenieAPIKey", "The API key of an OpsGenie API integration to create an alert with when a check starts failing")
+	flaggy.String(&opsgenieAPIURL, "", "opsgenieAPIURL", "The OpsGenie API to send alerts to, such as https://api.eu.opsgenie.com for the EU instance")
+	flaggy.String(&opsgenieTeam, "", "opsgenieTeam", "The name of the OpsGenie team alerts are routed to")
+	flaggy.String(&opsgenieStatusURL, "", "opsgenieStatusURL", "The URL of the Kuberhealthy status page linked in every OpsGenie alert.  Defaults to the Kuberhealthy service in the Kuberhealthy namespace.")
 	flaggy.Parse()
 
 	parsedLogLevel, err := log.ParseLevel(logLevel)
@@ -110,8 +667,14 @@ func init() {
 		log.Fatalln("Unable to parse log-level flag: ", err)
 	}
 
+	formatter, err := logFormatter(logFormat)
+	if err != nil {
+		log.Fatalln("Unable to parse logFormat flag: ", err)
+	}
+
 	// log to stdout and set the level to info by default
 	log.SetOutput(os.Stdout)
+	log.SetFormatter(formatter)
 	log.SetLevel(parsedLogLevel)
 	log.Infoln("Startup Arguments:", os.Args)
 
@@ -141,13 +704,20 @@ func main() {
 	// Create a new Kuberhealthy struct
 	kuberhealthy = NewKuberhealthy()
 	kuberhealthy.ListenAddr = listenAddress
-	var metricClient metrics.Client
+	kuberhealthy.GRPCListenAddr = grpcListenAddress
+	kuberhealthy.CheckTimeout = checkTimeout
+	kuberhealthy.RetryMaxDelay = checkRetryMaxDelay
+	kuberhealthy.History = health.NewHistory(checkHistoryDepth)
+	kuberhealthy.CheckResultTTL = checkResultTTL
+	kuberhealthy.ExternalChecks = enableExternalChecks
+	kuberhealthy.RuntimeConfigMap = runtimeConfigMap
+	var metricClients metrics.MultiClient
 	if enableInflux {
 		influxUrlParsed, err := url.Parse(influxUrl)
 		if err != nil {
 			log.Fatalln("Unable to parse influxUrl", err)
 		}
-		metricClient, err = metrics.NewInfluxClient(metrics.InfluxClientInput{
+		influxClient, err := metrics.NewInfluxClient(metrics.InfluxClientInput{
 			Config: metrics.InfluxConfig{
 				URL:      *influxUrlParsed,
 				Password: influxPassword,
@@ -158,8 +728,74 @@ func main() {
 		if err != nil {
 			log.Fatalln("Unable to parse initialize connection with InfluxDB", err)
 		}
+		metricClients = append(metricClients, influxClient)
+	}
+	if enablePrometheus {
+		prometheusClient := metrics.NewPrometheusClient()
+		kuberhealthy.PrometheusMetrics = prometheusClient
+		metricClients = append(metricClients, prometheusClient)
+	}
+	if enableDatadog {
+		datadogClient, err := metrics.NewDatadogClient(datadogApiKey, datadogSite, splitFlagList(datadogTags))
+		if err != nil {
+			log.Fatalln("Unable to initialize Datadog metric forwarding", err)
+		}
+		metricClients = append(metricClients, datadogClient)
 	}
+	var metricClient metrics.Client
+	switch len(metricClients) {
+	case 0:
+	case 1:
+		metricClient = metricClients[0]
+	default:
+		metricClient = metricClients
+	}
+	globalTags, err := parseMetricTags(metricTags, clusterName)
+	if err != nil {
+		log.Fatalln("Unable to parse metricTags", err)
+	}
+	if metricClient != nil {
+		metricClient.SetGlobalTags(globalTags)
+	}
+	kuberhealthy.MetricTags = globalTags
 	kuberhealthy.MetricForwarder = metricClient
+	if len(alertmanagerURL) > 0 {
+		alertmanagerNotifier, err := alertmanager.New(alertmanagerURL, splitFlagList(alertmanagerLabels))
+		if err != nil {
+			log.Fatalln("Unable to initialize Alertmanager notifications", err)
+		}
+		kuberhealthy.Notifiers = append(kuberhealthy.Notifiers, alertmanagerNotifier)
+	}
+	if len(slackWebhookURL) > 0 {
+		if len(slackStatusURL) == 0 {
+			slackStatusURL = "http://kuberhealthy." + os.Getenv("POD_NAMESPACE") + "/status"
+		}
+		if len(slackClusterName) == 0 {
+			slackClusterName = clusterName
+		}
+		slackNotifier, err := slack.New(slackWebhookURL, slackChannel, slackClusterName, slackStatusURL)
+		if err != nil {
+			log.Fatalln("Unable to initialize Slack notifications", err)
+		}
+		kuberhealthy.Notifiers = append(kuberhealthy.Notifiers, slackNotifier)
+	}
+	if len(pagerdutyRoutingKey) > 0 {
+		pagerdutyNotifier, err := pagerduty.New(pagerdutyRoutingKey, pagerdutyServiceName, clusterName)
+		if err != nil {
+			log.Fatalln("Unable to initialize PagerDuty notifications", err)
+		}
+		kuberhealthy.Notifiers = append(kuberhealthy.Notifiers, pagerdutyNotifier)
+	}
+	if len(opsgenieAPIKey) > 0 {
+		if len(opsgenieStatusURL) == 0 {
+			opsgenieStatusURL = "http://kuberhealthy." + os.Getenv("POD_NAMESPACE") + "/status"
+		}
+		opsgenieNotifier, err := opsgenie.New(opsgenieAPIURL, opsgenieAPIKey, opsgenieTeam, clusterName, opsgenieStatusURL)
+		if err != nil {
+			log.Fatalln("Unable to initialize OpsGenie notifications", err)
+		}
+		kuberhealthy.Notifiers = append(kuberhealthy.Notifiers, opsgenieNotifier)
+	}
 
 	// Split the podCheckNamespaces into a []string
 	namespaces := strings.Split(podCheckNamespaces, ",")
@@ -168,21 +804,47 @@ func main() {
 
 	// componentstatus checking
 	if enableComponentStatusChecks {
-		kuberhealthy.AddCheck(componentStatus.New())
+		csc := componentStatus.New()
+		csc.SetInterval(componentStatusCheckInterval)
+		kuberhealthy.AddCheck(csc)
 	}
 
-	// daemonset checking
+	// daemonset checking.  A check is run on all nodes, or a separate check
+	// is run on the nodes matching each daemonset node selector.
 	if enableDaemonSetChecks {
-		dsc, err := daemonSet.New()
-		// allow the user to override the image used by the DSC - see #114
-		if len(DSPauseContainerImageOverride) > 0 {
-			log.Info("Setting DS pause container override image to:", DSPauseContainerImageOverride)
-			dsc.PauseContainerImage = DSPauseContainerImageOverride
+		var checkers []*daemonSet.Checker
+		var imageOverrides []string
+		if len(daemonSetNodeSelectors) == 0 {
+			dsc, err := daemonSet.New()
+			if err != nil {
+				log.Fatalln("unable to create daemonset checker:", err)
+			}
+			checkers = append(checkers, dsc)
+			imageOverrides = append(imageOverrides, "")
 		}
-		if err != nil {
-			log.Fatalln("unable to create daemonset checker:", err)
+		for _, s := range daemonSetNodeSelectors {
+			selector, image := splitNodeSelectorFlag(s)
+			dsc, err := daemonSet.NewWithNodeSelector(selector)
+			if err != nil {
+				log.Fatalln("unable to create daemonset checker:", err)
+			}
+			checkers = append(checkers, dsc)
+			imageOverrides = append(imageOverrides, image)
+		}
+
+		for i, dsc := range checkers {
+			// allow the user to override the image used by the DSC - see #114
+			image := DSPauseContainerImageOverride
+			if len(imageOverrides[i]) > 0 {
+				image = imageOverrides[i]
+			}
+			if len(image) > 0 {
+				log.Info("Setting DS pause container override image for ", dsc.Name(), " to: ", image)
+				dsc.PauseContainerImage = image
+			}
+			dsc.SetInterval(daemonSetCheckInterval)
+			kuberhealthy.AddCheck(dsc)
 		}
-		kuberhealthy.AddCheck(dsc)
 	}
 
 	// pod restart checking
@@ -190,7 +852,9 @@ func main() {
 		for _, namespace := range namespaces {
 			n := strings.TrimSpace(namespace)
 			if len(n) > 0 {
-				kuberhealthy.AddCheck(podRestarts.New(n))
+				prc := podRestarts.New(n)
+				prc.SetInterval(podRestartCheckInterval)
+				kuberhealthy.AddCheck(prc)
 			}
 		}
 	}
@@ -200,19 +864,489 @@ func main() {
 		for _, namespace := range namespaces {
 			n := strings.TrimSpace(namespace)
 			if len(n) > 0 {
-				kuberhealthy.AddCheck(podStatus.New(n))
+				psc := podStatus.New(n)
+				psc.SetInterval(podStatusCheckInterval)
+				kuberhealthy.AddCheck(psc)
 			}
 		}
 	}
 
 	// dns resolution checking
 	if enableDnsStatusChecks {
-		kuberhealthy.AddCheck(dnsStatus.New(dnsEndpoints))
+		dc := dnsStatus.New(dnsEndpoints)
+		dc.SetInterval(dnsCheckInterval)
+		kuberhealthy.AddCheck(dc)
+	}
+
+	// dns service name resolution checking
+	if enableDNSServiceChecks {
+		kuberhealthy.AddCheck(dnsStatus.NewServiceChecker(splitFlagList(dnsServiceNamespaces), dnsServicesPerNamespace, dnsClusterDomain))
+	}
+
+	// mutating webhook namespace scope checking
+	if enableWebhookNamespaceScopeChecks {
+		kuberhealthy.AddCheck(webhookNamespaceScope.New())
+	}
+
+	// controller manager and scheduler leader lease checking
+	if enableControllerManagerLeaseChecks {
+		kuberhealthy.AddCheck(controllerManagerLease.New())
+	}
+
+	// pod scheduling balance checking
+	if enableSchedulingBalanceChecks {
+		kuberhealthy.AddCheck(schedulingBalance.New(schedulingImbalanceThreshold))
+	}
+
+	// node pressure condition flapping checking
+	if enableNodePressureToggleChecks {
+		kuberhealthy.AddCheck(nodePressureToggle.New(pressureToggleThreshold, pressureToggleWindow))
+	}
+
+	// cluster api checking
+	if enableClusterAPIChecks {
+		kuberhealthy.AddCheck(clusterAPI.New())
+	}
+
+	// certificate signing request backlog checking
+	if enableCSRBacklogChecks {
+		kuberhealthy.AddCheck(csrBacklog.New(csrBacklogThreshold, csrPendingThreshold))
+	}
+
+	// secret rbac checking
+	if enableSecretRBACChecks {
+		kuberhealthy.AddCheck(secretRBAC.New(splitFlagList(secretRBACNamespaces)))
+	}
+
+	// pod preset checking
+	if enablePodPresetChecks {
+		kuberhealthy.AddCheck(podPreset.New())
+	}
+
+	// image manifest checking
+	if enableImageManifestChecks {
+		kuberhealthy.AddCheck(imageManifestV2.New(splitFlagList(imageManifestCheckNamespaces), splitFlagList(imageManifestRegistries), registryCredentials))
+	}
+
+	// kubelet configuration checking
+	if enableKubeletConfigChecks {
+		kuberhealthy.AddCheck(kubeletConfig.New(expectedKubeletConfig))
+	}
+
+	// watch connection checking
+	if enableWatchConnectionChecks {
+		kuberhealthy.AddCheck(watchConnections.New(watchResetThreshold, watchResetWindow))
+	}
+
+	// crd schema checking
+	if enableCRDSchemaChecks {
+		kuberhealthy.AddCheck(crdSchemas.New(splitFlagList(crdSchemaExceptions)))
+	}
+
+	// exec permission checking
+	if enableExecPermissionChecks {
+		kuberhealthy.AddCheck(execPermissions.New(splitFlagList(execPermissionAllowedSubjects)))
+	}
+
+	// node systemd service checking
+	if enableNodeSystemdChecks {
+		kuberhealthy.AddCheck(nodeSystemd.New(splitFlagList(requiredSystemdServices)))
+	}
+
+	// naming convention checking
+	if enableNamingConventionChecks {
+		kuberhealthy.AddCheck(namingConvention.New(namingConventionConfigMap))
+	}
+
+	// api server audit policy checking
+	if enableAuditPolicyChecks {
+		kuberhealthy.AddCheck(apiServerAuditPolicy.New(auditPolicyPath))
+	}
+
+	// zone label checking
+	if enableZoneLabelChecks {
+		zlc, err := zoneLabels.New(zonePattern)
+		if err != nil {
+			log.Fatalln("unable to create zone label checker:", err)
+		}
+		kuberhealthy.AddCheck(zlc)
+	}
+
+	// pod disruption budget checking
+	if enablePDBValidityChecks {
+		kuberhealthy.AddCheck(pdbValidity.New(splitFlagList(pdbCheckNamespaces)))
+	}
+
+	// service traffic checking
+	if enableServiceTrafficChecks {
+		kuberhealthy.AddCheck(serviceTrafficHealth.New(splitFlagList(serviceTrafficCheckNamespaces), serviceTrafficTimeout))
+	}
+
+	// namespace uid range checking
+	if enableUIDRangeChecks {
+		kuberhealthy.AddCheck(uidRanges.New())
+	}
+
+	// egress connectivity checking
+	if enableEgressConnectivityChecks {
+		ecc, err := egressConnectivity.New(splitFlagList(egressCheckEndpoints))
+		if err != nil {
+			log.Fatalln("unable to create egress connectivity checker:", err)
+		}
+		kuberhealthy.AddCheck(ecc)
+	}
+
+	// seccomp profile checking
+	if enableSeccompProfileChecks {
+		spc, err := seccompProfile.New(splitFlagList(seccompCheckNamespaces), requiredSeccompProfile)
+		if err != nil {
+			log.Fatalln("unable to create seccomp profile checker:", err)
+		}
+		kuberhealthy.AddCheck(spc)
+	}
+
+	// cluster cidr conflict checking
+	if enableCIDRConflictChecks {
+		ccc, err := cidrConflict.New(splitFlagList(corporateCIDRs))
+		if err != nil {
+			log.Fatalln("unable to create cidr conflict checker:", err)
+		}
+		kuberhealthy.AddCheck(ccc)
+	}
+
+	// node swap checking
+	if enableSwapDisabledChecks {
+		kuberhealthy.AddCheck(swapDisabled.New(allowSwap))
+	}
+
+	// kuberhealthy namespace checking
+	if enableSelfNamespaceChecks {
+		snc := selfNamespace.New(func() {
+			sigChan <- os.Interrupt
+		})
+		// check the namespace at startup so that every instance shuts down
+		// when it is terminating, not just the master
+		client, err := kuberhealthy.KubeClient()
+		if err != nil {
+			log.Errorln("unable to create kubernetes client to check the kuberhealthy namespace at startup:", err)
+		} else if err := snc.Run(context.Background(), client); err != nil {
+			log.Errorln("error checking the kuberhealthy namespace at startup:", err)
+		}
+		kuberhealthy.AddCheck(snc)
+	}
+
+	// container runtime concurrency checking
+	if enableRuntimeConcurrencyChecks {
+		kuberhealthy.AddCheck(runtimeConcurrency.New(runtimeLatencyRatio, metricClient))
+	}
+
+	// pod ip assignment checking
+	if enablePodIPAssignmentChecks {
+		kuberhealthy.AddCheck(podIPAssignment.New(splitFlagList(podIPCheckNamespaces), podIPGracePeriod))
+	}
+
+	// dns ttl checking
+	if enableDNSTTLChecks {
+		kuberhealthy.AddCheck(dnsTTL.New(splitFlagList(dnsTTLNames), minDNSTTL, maxDNSTTL))
+	}
+
+	// node memory capacity checking
+	if minNodeMemoryGi > 0 {
+		kuberhealthy.AddCheck(nodeMemoryCapacity.New(minNodeMemoryGi, nodeMemoryLabelSelector))
+	}
+
+	// ingress controller health checking
+	if enableIngressControllerChecks {
+		kuberhealthy.AddCheck(ingressControllerHealth.New(ingressControllerSelector))
+	}
+
+	// kube-proxy sync checking
+	if enableKubeProxySyncChecks {
+		kuberhealthy.AddCheck(kubeProxySync.New(kubeProxySyncThreshold, metricClient))
+	}
+
+	// rollout consistency checking
+	if enableRolloutConsistencyChecks {
+		kuberhealthy.AddCheck(rolloutConsistency.New(rolloutConsistencyTimeout))
+	}
+
+	// webhook idempotency checking
+	if enableWebhookIdempotencyChecks {
+		kuberhealthy.AddCheck(webhookIdempotency.New())
+	}
+
+	// image policy webhook checking
+	if enableImagePolicyWebhookChecks {
+		kuberhealthy.AddCheck(imagePolicyWebhook.New(prohibitedTestImage))
+	}
+
+	// audit anomaly checking
+	if enableAuditAnomalyChecks {
+		var source auditAnomalies.AuditSource = auditAnomalies.NewFileSource(auditLogPath)
+		if len(auditLogQueryURL) > 0 {
+			source = auditAnomalies.NewQuerySource(auditLogQueryURL)
+		}
+		aac, err := auditAnomalies.New(source, auditSecretReadThreshold, auditAuthFailureThreshold, splitFlagList(auditKnownCIDRs))
+		if err != nil {
+			log.Fatalln("unable to create audit anomalies checker:", err)
+		}
+		kuberhealthy.AddCheck(aac)
+	}
+
+	// registry mirror checking
+	if len(registryMirrorURL) > 0 {
+		rmc, err := registryMirror.New(registryMirrorURL)
+		if err != nil {
+			log.Fatalln("unable to create registry mirror checker:", err)
+		}
+		kuberhealthy.AddCheck(rmc)
+	}
+
+	// ipv6 connectivity checking
+	if enableIPv6ConnectivityChecks {
+		kuberhealthy.AddCheck(ipv6Connectivity.New())
+	}
+
+	// node architecture checking
+	if enableNodeArchitectureChecks {
+		kuberhealthy.AddCheck(nodeArchitecture.New(splitFlagList(expectedArchitecture)))
+	}
+
+	// cgroup driver checking
+	if enableCgroupDriverChecks {
+		kuberhealthy.AddCheck(cgroupDriver.New())
+	}
+
+	// termination message policy checking
+	if enableTerminationMessageChecks {
+		kuberhealthy.AddCheck(terminationMessage.New(splitFlagList(terminationMessageCheckNamespaces), terminationMessageEnforcing))
+	}
+
+	// node taint checking
+	if enableNodeTaintChecks {
+		nodeTaintChecker, err := nodeTaints.New(splitFlagList(requiredNodeTaints))
+		if err != nil {
+			log.Fatalln("unable to create node taint checker:", err)
+		}
+		kuberhealthy.AddCheck(nodeTaintChecker)
+	}
+
+	// ConfigMap schema checking
+	if enableConfigMapSchemaChecks {
+		kuberhealthy.AddCheck(configMapSchema.New(configMapSchemaConfigMap))
+	}
+
+	// pod QoS class checking
+	if enablePodQoSChecks {
+		kuberhealthy.AddCheck(podQoS.New(splitFlagList(podQoSCheckNamespaces)))
+	}
+
+	// event quality checking
+	if enableEventQualityChecks {
+		kuberhealthy.AddCheck(eventQuality.New(splitFlagList(allowedEventSources), maxEventReasonLength))
+	}
+
+	// kernel module checking
+	if enableKernelModuleChecks {
+		kuberhealthy.AddCheck(kernelModules.New(splitFlagList(requiredKernelModules)))
+	}
+
+	// HorizontalPodAutoscaler conflict checking
+	if enableHPAConflictChecks {
+		kuberhealthy.AddCheck(hpaDeploymentConflict.New(hpaConflictTolerance))
+	}
+
+	// container capability checking
+	if enableCapabilityDropChecks {
+		kuberhealthy.AddCheck(capabilityDrop.New(splitFlagList(capabilityCheckNamespaces), splitFlagList(capabilityCheckExcludeNamespaces), splitFlagList(requiredDropCapabilities), splitFlagList(prohibitedAddCapabilities)))
+	}
+
+	// cluster autoscaler annotation checking
+	if enableAutoscalerAnnotationChecks {
+		kuberhealthy.AddCheck(autoscalerAnnotations.New())
+	}
+
+	// aggregated API server certificate checking
+	if enableAggAPIServerCertChecks {
+		kuberhealthy.AddCheck(aggregatedAPIServerCerts.New(aggAPIServerCertExpiryDays))
+	}
+
+	// hugepages checking
+	if enableHugepagesChecks {
+		kuberhealthy.AddCheck(hugepages.New())
+	}
+
+	// deprecated API usage checking
+	if enableDeprecatedAPIUsageChecks {
+		kuberhealthy.AddCheck(deprecatedAPIUsage.New(deprecatedAPIThreshold, metricClient))
+	}
+
+	// persistent volume claim status checking
+	if enablePvcStatusChecks {
+		for _, namespace := range splitFlagList(pvcCheckNamespaces) {
+			kuberhealthy.AddCheck(pvcStatus.New(namespace, pvcPendingThreshold))
+		}
+	}
+
+	// DaemonSet image consistency checking
+	if enableDaemonSetImageChecks {
+		kuberhealthy.AddCheck(daemonSetImage.New(splitFlagList(daemonSetImageCheckNamespaces)))
+	}
+
+	// node NotReady checking
+	if enableNodeStatusChecks {
+		kuberhealthy.AddCheck(nodeStatus.New(nodeStatusGracePeriod, nodeStatusExcludeMasters))
+	}
+
+	// image pull policy checking
+	if enableImagePullPolicyChecks {
+		kuberhealthy.AddCheck(imagePullPolicy.New(splitFlagList(imagePullPolicyCheckNamespaces)))
+	}
+
+	// TLS certificate expiry checking
+	if enableTLSCertExpiryChecks {
+		tlsCertExpiryChecker, err := tlsCertExpiry.New(splitFlagList(tlsCheckEndpoints), tlsCertWarningDays, tlsDialTimeout, metricClient)
+		if err != nil {
+			log.Fatalln("unable to create TLS certificate expiry checker:", err)
+		}
+		kuberhealthy.AddCheck(tlsCertExpiryChecker)
+	}
+
+	// network MTU checking
+	if enableNetworkMTUChecks {
+		kuberhealthy.AddCheck(networkMTU.New(expectedMTU))
+	}
+
+	// ingress URL checking
+	if enableIngressChecks {
+		icc, err := ingressCheck.New(splitFlagList(ingressCheckURLs), ingressCheckTimeout, ingressCheckStatusRange, ingressCheckMaxRedirects, ingressCheckBearerTokenFile)
+		if err != nil {
+			log.Fatalln("unable to create ingress checker:", err)
+		}
+		kuberhealthy.AddCheck(icc)
+	}
+
+	// CRD stored version checking
+	if enableCRDStoredVersionChecks {
+		kuberhealthy.AddCheck(crdStoredVersions.New())
+	}
+
+	// privileged pod justification checking
+	if enablePrivilegedJustificationChecks {
+		kuberhealthy.AddCheck(privilegedJustification.New(splitFlagList(privilegedJustificationNamespaces), privilegedJustificationCreateEvent))
+	}
+
+	// GKE node auto-repair checking
+	if enableNodeAutoRepairChecks {
+		kuberhealthy.AddCheck(nodeAutoRepair.New(nodeRepairThreshold, nodeRepairWindow))
+	}
+
+	// ephemeral storage usage checking
+	if enableEphemeralStorageChecks {
+		kuberhealthy.AddCheck(ephemeralStorage.New(ephemeralStorageWarningPercent))
+	}
+
+	// readiness gate checking
+	if enableReadinessGateChecks {
+		kuberhealthy.AddCheck(readinessGates.New(readinessGateTimeout))
+	}
+
+	// StatefulSet replica readiness checking
+	if enableStatefulSetStatusChecks {
+		kuberhealthy.AddCheck(statefulSetStatus.New(splitFlagList(statefulSetCheckNamespaces), statefulSetGracePeriod))
+	}
+
+	// node NTP time sync checking
+	if enableNTPSyncChecks {
+		kuberhealthy.AddCheck(ntpSync.New(maxNTPOffset))
+	}
+
+	// Ingress CORS configuration checking
+	if enableCORSConfigChecks {
+		kuberhealthy.AddCheck(corsConfig.New())
+	}
+
+	// internet facing Ingress rate limit checking
+	if enableRateLimitConfigChecks {
+		kuberhealthy.AddCheck(rateLimitConfig.New(splitFlagList(internetFacingIngressClasses), maxIngressRPS))
+	}
+
+	// Deployment anti-affinity checking
+	if enableHADeploymentChecks {
+		kuberhealthy.AddCheck(haDeployments.New(splitFlagList(haDeploymentCheckNamespaces), haMinReplicas))
+	}
+
+	// pod namespace escape checking
+	if enableNamespaceEscapeChecks {
+		kuberhealthy.AddCheck(namespaceEscape.New(namespaceEscapeConfig))
+	}
+
+	// ResourceQuota utilization checking
+	if enableQuotaStatusChecks {
+		kuberhealthy.AddCheck(quotaStatus.New(splitFlagList(quotaCheckNamespaces), quotaWarningThreshold))
+	}
+
+	// upgrade blocking PodDisruptionBudget checking
+	if enableUpgradePDBChecks {
+		kuberhealthy.AddCheck(upgradePDBs.New(splitFlagList(upgradePDBNamespaces)))
+	}
+
+	// network policy connectivity checking
+	if enableNetworkPolicyChecks {
+		kuberhealthy.AddCheck(networkPolicy.New(networkPolicyConfigMap))
+	}
+
+	// Deployment rollout checking
+	if enableDeploymentStatusChecks {
+		kuberhealthy.AddCheck(deploymentStatus.New(splitFlagList(deploymentCheckNamespaces), deploymentGracePeriod))
+	}
+
+	// HorizontalPodAutoscaler checking
+	if enableHPAStatusChecks {
+		kuberhealthy.AddCheck(hpaStatus.New(splitFlagList(hpaCheckNamespaces)))
+	}
+
+	// Service endpoint checking
+	if enableEndpointStatusChecks {
+		kuberhealthy.AddCheck(endpointStatus.New(splitFlagList(endpointCheckNamespaces), endpointGracePeriod))
+	}
+
+	// image pull checking
+	if enableImagePullChecks {
+		ipc, err := imagePull.New(imagePullTestImage, imagePullSecret, imagePullTimeout)
+		if err != nil {
+			log.Fatalln("unable to create image pull checker:", err)
+		}
+		kuberhealthy.AddCheck(ipc)
+	}
+
+	// in minimal RBAC mode, checks that need access beyond the Kuberhealthy
+	// namespace and the checked namespaces are not run
+	if minimalRBAC {
+		allowedNamespaces := append(splitFlagList(podCheckNamespaces), os.Getenv("POD_NAMESPACE"))
+		kuberhealthy.Checks = minimalRBACChecks(kuberhealthy.Checks, allowedNamespaces)
+	}
+
+	// print the RBAC resources needed by the enabled checks instead of running them
+	if printRBAC {
+		if len(os.Getenv("POD_NAMESPACE")) == 0 {
+			log.Fatalln("POD_NAMESPACE must be set to the namespace Kuberhealthy runs in to print its RBAC resources")
+		}
+		err := kuberhealthy.printRBAC(os.Stdout, os.Getenv("POD_NAMESPACE"))
+		if err != nil {
+			log.Fatalln("Error printing RBAC resources:", err)
+		}
+		os.Exit(0)
 	}
 
 	// Tell Kuberhealthy to start all checks and master change monitoring
 	go kuberhealthy.Start()
 
+	// Start the gRPC health service when it is enabled
+	if enableGRPC {
+		go kuberhealthy.StartGRPCServer()
+	}
+
 	// Start the web server and restart it if it crashes
 	kuberhealthy.StartWebServer()
 
@@ -220,7 +1354,7 @@ func main() {
 
 // listenForInterrupts watches for termination singnals and acts on them
 func listenForInterrupts() {
-	signal.Notify(sigChan, os.Interrupt, os.Kill)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	<-sigChan
 	log.Infoln("Shutting down...")
 	go kuberhealthy.Shutdown()
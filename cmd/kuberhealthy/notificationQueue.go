@@ -0,0 +1,104 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"sync"
+
+	"github.com/Comcast/kuberhealthy/pkg/notifiers"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxQueuedNotifications is the number of results kept for a check while
+// its earlier results are being sent to a notifier
+const maxQueuedNotifications = 10
+
+// checkResult is the result of a check run sent to a notifier
+type checkResult struct {
+	namespace string
+	ok        bool
+	errs      []string
+	checkLog  *log.Entry // logs errors sending the result
+}
+
+// notificationQueue sends check results to a notifier in the background so
+// that a slow or unreachable notifier does not delay the check run loop.
+// The results of each check are sent one at a time in the order they were
+// queued.  When a check has too many results waiting, the oldest is
+// dropped.  Notifiers act on changes of a check's state, so a later result
+// still reports the change.
+type notificationQueue struct {
+	sync.Mutex
+	notifier notifiers.Notifier
+	pending  map[string][]checkResult // the results waiting to be sent for each check
+	sending  map[string]bool          // checks with a send in progress
+	inflight sync.WaitGroup           // tracks send routines so shutdown can wait for them
+}
+
+// newNotificationQueue creates a notificationQueue that sends results to
+// notifier
+func newNotificationQueue(notifier notifiers.Notifier) *notificationQueue {
+	return &notificationQueue{
+		notifier: notifier,
+		pending:  make(map[string][]checkResult),
+		sending:  make(map[string]bool),
+	}
+}
+
+// Queue queues the result of a check to be sent and returns immediately
+func (q *notificationQueue) Queue(checkName string, result checkResult) {
+	q.Lock()
+	defer q.Unlock()
+
+	pending := append(q.pending[checkName], result)
+	if len(pending) > maxQueuedNotifications {
+		result.checkLog.Warningln("Dropping the oldest queued notification of check", checkName, "because the notifier is not keeping up")
+		pending = pending[1:]
+	}
+	q.pending[checkName] = pending
+	if q.sending[checkName] {
+		return
+	}
+	q.sending[checkName] = true
+	q.inflight.Add(1)
+	go q.sendCheck(checkName)
+}
+
+// Wait blocks until every queued result has been sent
+func (q *notificationQueue) Wait() {
+	q.inflight.Wait()
+}
+
+// sendCheck sends the pending results of a check in order until none are
+// left
+func (q *notificationQueue) sendCheck(checkName string) {
+	defer q.inflight.Done()
+
+	for {
+		q.Lock()
+		pending := q.pending[checkName]
+		if len(pending) == 0 {
+			delete(q.pending, checkName)
+			q.sending[checkName] = false
+			q.Unlock()
+			return
+		}
+		result := pending[0]
+		q.pending[checkName] = pending[1:]
+		q.Unlock()
+
+		err := q.notifier.Notify(checkName, result.namespace, result.ok, result.errs)
+		if err != nil {
+			result.checkLog.Errorln("Error notifying of check result", err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// rbacName is the name of the RBAC resources printed by --printRBAC and of
+// the service account they are bound to
+const rbacName = "kuberhealthy"
+
+// kuberhealthyRBACRules returns the RBAC rules Kuberhealthy needs in its own
+// namespace regardless of the checks it runs
+func (k *Kuberhealthy) kuberhealthyRBACRules() []rbacv1.PolicyRule {
+	rules := []rbacv1.PolicyRule{
+		// pods are listed to calculate the master
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}},
+		{APIGroups: []string{CRDGroup}, Resources: []string{CRDResource}, Verbs: []string{"create", "delete", "get", "list", "update"}},
+		{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"create", "get", "update"}},
+	}
+	if len(k.RuntimeConfigMap) > 0 {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "list", "watch"}})
+	}
+	if k.ExternalChecks {
+		rules = append(rules,
+			rbacv1.PolicyRule{APIGroups: []string{CRDGroup}, Resources: []string{ExternalCheckCRDResource}, Verbs: []string{"get", "list"}},
+			rbacv1.PolicyRule{APIGroups: []string{"batch"}, Resources: []string{"jobs"}, Verbs: []string{"create", "delete"}},
+		)
+	}
+	return rules
+}
+
+// collectRBACRules returns the RBAC rules needed by Kuberhealthy and its
+// checks by namespace, with cluster scoped rules under the empty namespace.
+// The names of checks that do not implement RBACRequirer are returned so
+// that they can be reported, because the rules they need are not known.
+func (k *Kuberhealthy) collectRBACRules(namespace string) (map[string][]rbacv1.PolicyRule, []string) {
+	rules := map[string][]rbacv1.PolicyRule{
+		namespace: k.kuberhealthyRBACRules(),
+	}
+	var undeclared []string
+	for _, c := range k.Checks {
+		r, ok := c.(RBACRequirer)
+		if !ok {
+			undeclared = append(undeclared, c.Name())
+			continue
+		}
+		for ns, checkRules := range r.RBACRules() {
+			rules[ns] = append(rules[ns], checkRules...)
+		}
+	}
+	for ns := range rules {
+		rules[ns] = mergeRBACRules(rules[ns])
+	}
+	return rules, undeclared
+}
+
+// mergeRBACRules combines rules for the same API groups, resources, resource
+// names and non-resource URLs into a single rule with the verbs of all of
+// them, so that the printed roles list each resource once
+func mergeRBACRules(rules []rbacv1.PolicyRule) []rbacv1.PolicyRule {
+	verbs := make(map[string]map[string]bool)
+	merged := make(map[string]rbacv1.PolicyRule)
+	for _, r := range rules {
+		key := strings.Join(r.APIGroups, ",") + "/" + strings.Join(r.Resources, ",") + "/" + strings.Join(r.ResourceNames, ",") + "/" + strings.Join(r.NonResourceURLs, ",")
+		if _, ok := merged[key]; !ok {
+			merged[key] = rbacv1.PolicyRule{APIGroups: r.APIGroups, Resources: r.Resources, ResourceNames: r.ResourceNames, NonResourceURLs: r.NonResourceURLs}
+			verbs[key] = make(map[string]bool)
+		}
+		for _, v := range r.Verbs {
+			verbs[key][v] = true
+		}
+	}
+
+	var keys []string
+	for key := range merged {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var result []rbacv1.PolicyRule
+	for _, key := range keys {
+		r := merged[key]
+		for v := range verbs[key] {
+			r.Verbs = append(r.Verbs, v)
+		}
+		sort.Strings(r.Verbs)
+		result = append(result, r)
+	}
+	return result
+}
+
+// rbacManifest returns the RBAC resources that grant the service account in
+// namespace the given rules.  A Role and RoleBinding are made for every
+// namespace, and a ClusterRole and ClusterRoleBinding for cluster scoped
+// rules.
+func rbacManifest(rules map[string][]rbacv1.PolicyRule, namespace string) []interface{} {
+	subjects := []rbacv1.Subject{
+		{Kind: rbacv1.ServiceAccountKind, Name: rbacName, Namespace: namespace},
+	}
+
+	var namespaces []string
+	for ns := range rules {
+		if len(ns) > 0 && len(rules[ns]) > 0 {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	sort.Strings(namespaces)
+
+	var objects []interface{}
+	for _, ns := range namespaces {
+		objects = append(objects,
+			rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+				ObjectMeta: metav1.ObjectMeta{Name: rbacName, Namespace: ns},
+				Rules:      rules[ns],
+			},
+			rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: rbacName, Namespace: ns},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: rbacName},
+				Subjects:   subjects,
+			},
+		)
+	}
+
+	if len(rules[""]) > 0 {
+		objects = append(objects,
+			rbacv1.ClusterRole{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+				ObjectMeta: metav1.ObjectMeta{Name: rbacName},
+				Rules:      rules[""],
+			},
+			rbacv1.ClusterRoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: rbacName},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: rbacName},
+				Subjects:   subjects,
+			},
+		)
+	}
+	return objects
+}
+
+// printRBAC writes a YAML manifest of the RBAC resources needed by
+// Kuberhealthy and its enabled checks when it runs in namespace.  Checks
+// that do not declare the rules they need are listed in a comment at the top
+// of the manifest.
+func (k *Kuberhealthy) printRBAC(w io.Writer, namespace string) error {
+	rules, undeclared := k.collectRBACRules(namespace)
+
+	var b strings.Builder
+	b.WriteString("# RBAC resources needed by Kuberhealthy and its enabled checks\n")
+	if len(undeclared) > 0 {
+		b.WriteString("# The rules needed by the following enabled checks are not known and are not included:\n")
+		for _, name := range undeclared {
+			b.WriteString("#   " + name + "\n")
+		}
+	}
+	for _, o := range rbacManifest(rules, namespace) {
+		y, err := yaml.Marshal(o)
+		if err != nil {
+			return err
+		}
+		b.WriteString("---\n")
+		b.Write(y)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// minimalRBACChecks returns the checks that can run with roles in the given
+// namespaces only.  Checks that need cluster scoped access or access to
+// other namespaces are left out and logged, and so are checks that do not
+// declare the rules they need, since they can not be shown to fit.
+func minimalRBACChecks(checks []KuberhealthyCheck, namespaces []string) []KuberhealthyCheck {
+	allowed := make(map[string]bool)
+	for _, ns := range namespaces {
+		allowed[ns] = true
+	}
+
+	var kept []KuberhealthyCheck
+	for _, c := range checks {
+		r, ok := c.(RBACRequirer)
+		if !ok {
+			log.Infoln("Not running check", c.Name(), "in minimal RBAC mode because it does not declare the RBAC rules it needs")
+			continue
+		}
+
+		var outside []string
+		for ns, rules := range r.RBACRules() {
+			if len(rules) == 0 || allowed[ns] {
+				continue
+			}
+			if len(ns) == 0 {
+				outside = append(outside, "cluster scoped resources")
+				continue
+			}
+			outside = append(outside, "namespace "+ns)
+		}
+		if len(outside) > 0 {
+			sort.Strings(outside)
+			log.Infoln("Not running check", c.Name(), "in minimal RBAC mode because it needs access to", strings.Join(outside, " and "))
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
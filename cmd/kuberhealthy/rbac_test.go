@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// fakeRBACCheck is a FakeCheck that declares the RBAC rules it needs
+type fakeRBACCheck struct {
+	*FakeCheck
+	rules map[string][]rbacv1.PolicyRule
+}
+
+// RBACRules returns the RBAC rules of the fake check
+func (f *fakeRBACCheck) RBACRules() map[string][]rbacv1.PolicyRule {
+	return f.rules
+}
+
+// newFakeRBACCheck creates a fake check that needs to list pods in each of
+// the supplied namespaces
+func newFakeRBACCheck(name string, namespaces ...string) *fakeRBACCheck {
+	fc := NewFakeCheck()
+	fc.CheckName = name
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		}
+	}
+	return &fakeRBACCheck{FakeCheck: fc, rules: rules}
+}
+
+func TestMergeRBACRules(t *testing.T) {
+	rules := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"list"}},
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "delete", "list"}},
+		{APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"kuberhealthy-0"}, Verbs: []string{"patch"}},
+		{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}},
+	}
+	expected := []rbacv1.PolicyRule{
+		{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}},
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"delete", "get", "list"}},
+		{APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"kuberhealthy-0"}, Verbs: []string{"patch"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"list"}},
+	}
+	merged := mergeRBACRules(rules)
+	if !reflect.DeepEqual(merged, expected) {
+		t.Fatal("Expected merged rules", expected, "but got", merged)
+	}
+}
+
+func TestRBACManifest(t *testing.T) {
+	rules := map[string][]rbacv1.PolicyRule{
+		"kuberhealthy": {{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}}},
+		"kube-system":  {{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}}},
+		"default":      nil,
+	}
+	objects := rbacManifest(rules, "kuberhealthy")
+	if len(objects) != 4 {
+		t.Fatal("Expected a Role and RoleBinding for 2 namespaces but got", objects)
+	}
+	role, ok := objects[0].(rbacv1.Role)
+	if !ok || role.Namespace != "kube-system" {
+		t.Fatal("Expected the first object to be a Role in kube-system but got", objects[0])
+	}
+	binding, ok := objects[1].(rbacv1.RoleBinding)
+	if !ok || binding.Namespace != "kube-system" || binding.Subjects[0].Namespace != "kuberhealthy" {
+		t.Fatal("Expected a RoleBinding in kube-system for the kuberhealthy service account but got", objects[1])
+	}
+
+	rules[""] = []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}}}
+	objects = rbacManifest(rules, "kuberhealthy")
+	if len(objects) != 6 {
+		t.Fatal("Expected a ClusterRole and ClusterRoleBinding for cluster scoped rules but got", objects)
+	}
+	if _, ok := objects[4].(rbacv1.ClusterRole); !ok {
+		t.Fatal("Expected a ClusterRole but got", objects[4])
+	}
+}
+
+func TestPrintRBAC(t *testing.T) {
+	kh := NewKuberhealthy()
+	kh.AddCheck(newFakeRBACCheck("PodCheck", "kube-system"))
+	kh.AddCheck(NewFakeCheck())
+
+	var b bytes.Buffer
+	err := kh.printRBAC(&b, "kuberhealthy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	t.Log(out)
+	for _, expected := range []string{"#   FakeCheck\n", "kind: Role\n", "namespace: kube-system\n", "namespace: kuberhealthy\n", "- " + CRDResource + "\n"} {
+		if !strings.Contains(out, expected) {
+			t.Fatal("Expected the printed manifest to contain", expected)
+		}
+	}
+	if strings.Contains(out, "kind: ClusterRole") {
+		t.Fatal("Expected no ClusterRole without cluster scoped rules")
+	}
+}
+
+func TestMinimalRBACChecks(t *testing.T) {
+	checks := []KuberhealthyCheck{
+		newFakeRBACCheck("AllowedCheck", "kube-system", "kuberhealthy"),
+		newFakeRBACCheck("ClusterCheck", "kuberhealthy", ""),
+		newFakeRBACCheck("OtherNamespaceCheck", "production"),
+		NewFakeCheck(),
+	}
+	kept := minimalRBACChecks(checks, []string{"kube-system", "kuberhealthy"})
+
+	var names []string
+	for _, c := range kept {
+		names = append(names, c.Name())
+	}
+	expected := []string{"AllowedCheck"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatal("Expected only checks", expected, "to be kept but got", names)
+	}
+}
@@ -0,0 +1,372 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// runtimeConfigRelistDelay is how long the runtime configuration ConfigMap
+// watch waits before listing the ConfigMap again after an error
+var runtimeConfigRelistDelay = time.Second * 30
+
+// checkConfig is the runtime configuration of a check.  The zero value
+// leaves the check as it was configured by flags at startup.
+type checkConfig struct {
+	interval time.Duration     // overrides the interval of the check when set
+	disabled bool              // stops the check from running
+	settings map[string]string // thresholds passed to checks that implement Reconfigurable
+}
+
+// watchRuntimeConfig watches the runtime configuration ConfigMap and
+// restarts the checks whose configuration changed until a stop signal is
+// received.  The ConfigMap is listed again whenever the watch ends, so that
+// changes made while it was not being watched are applied as well.
+func (k *Kuberhealthy) watchRuntimeConfig(stopChan chan bool) {
+	var client *kubernetes.Clientset
+	for {
+		var err error
+		if client == nil {
+			client, err = k.KubeClient()
+			if err != nil {
+				log.Errorln("Error creating Kubernetes client to watch runtime configuration ConfigMap", k.RuntimeConfigMap+":", err)
+				if !waitForRelist(stopChan, runtimeConfigRelistDelay) {
+					return
+				}
+				continue
+			}
+		}
+
+		data, resourceVersion, err := k.fetchRuntimeConfig(client)
+		if err != nil {
+			log.Errorln("Error reading runtime configuration ConfigMap", k.RuntimeConfigMap+":", err)
+			if !waitForRelist(stopChan, runtimeConfigRelistDelay) {
+				return
+			}
+			continue
+		}
+		k.applyRuntimeConfig(data, true)
+
+		if !k.watchRuntimeConfigChanges(client, resourceVersion, stopChan) {
+			return
+		}
+	}
+}
+
+// watchRuntimeConfigChanges applies every change of the runtime
+// configuration ConfigMap after resourceVersion until the watch ends.  False
+// is returned when a stop signal was received.
+func (k *Kuberhealthy) watchRuntimeConfigChanges(client *kubernetes.Clientset, resourceVersion string, stopChan chan bool) bool {
+	w, err := client.CoreV1().ConfigMaps(os.Getenv("POD_NAMESPACE")).Watch(metav1.ListOptions{
+		FieldSelector:   fields.OneTermEqualSelector("metadata.name", k.RuntimeConfigMap).String(),
+		ResourceVersion: resourceVersion,
+	})
+	if err != nil {
+		log.Errorln("Error watching runtime configuration ConfigMap", k.RuntimeConfigMap+":", err)
+		return waitForRelist(stopChan, runtimeConfigRelistDelay)
+	}
+	defer w.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return false
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				log.Debugln("Watch of runtime configuration ConfigMap", k.RuntimeConfigMap, "ended.  Listing it again.")
+				return true
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				cm, ok := event.Object.(*apiv1.ConfigMap)
+				if !ok {
+					continue
+				}
+				k.applyRuntimeConfig(cm.Data, true)
+			case watch.Deleted:
+				k.applyRuntimeConfig(map[string]string{}, true)
+			case watch.Error:
+				log.Warningln("Error watching runtime configuration ConfigMap", k.RuntimeConfigMap+":", apierrors.FromObject(event.Object))
+				return waitForRelist(stopChan, runtimeConfigRelistDelay)
+			}
+		}
+	}
+}
+
+// waitForRelist waits for delay before a watched resource is listed again.
+// False is returned when a stop signal was received instead.
+func waitForRelist(stopChan chan bool, delay time.Duration) bool {
+	select {
+	case <-stopChan:
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// reloadRuntimeConfig reads the runtime configuration ConfigMap and applies
+// it to every check whose configuration changed since it was last applied
+func (k *Kuberhealthy) reloadRuntimeConfig(restart bool) {
+	client, err := k.KubeClient()
+	if err != nil {
+		log.Errorln("Error creating Kubernetes client to read runtime configuration ConfigMap", k.RuntimeConfigMap+":", err)
+		return
+	}
+	data, _, err := k.fetchRuntimeConfig(client)
+	if err != nil {
+		log.Errorln("Error reading runtime configuration ConfigMap", k.RuntimeConfigMap+":", err)
+		return
+	}
+	k.applyRuntimeConfig(data, restart)
+}
+
+// applyRuntimeConfig applies the data of the runtime configuration ConfigMap
+// to every check whose configuration changed since it was last applied.
+// When restart is set, the changed checks are restarted so that they run
+// with their new configuration without affecting other running checks.
+func (k *Kuberhealthy) applyRuntimeConfig(data map[string]string, restart bool) {
+	var checkNames []string
+	for _, c := range k.Checks {
+		checkNames = append(checkNames, c.Name())
+	}
+	configs, errs := parseRuntimeConfig(data, checkNames)
+	for _, err := range errs {
+		log.Warningln("Runtime configuration ConfigMap", k.RuntimeConfigMap+":", err)
+	}
+
+	for _, c := range k.Checks {
+		k.RLock()
+		current := k.checkConfigs[c.Name()]
+		k.RUnlock()
+
+		changes := changedCheckConfigFields(current, configs[c.Name()])
+		if len(changes) == 0 {
+			continue
+		}
+		for _, change := range changes {
+			log.Infoln("Runtime configuration of check", c.Name(), "changed:", change)
+		}
+		k.applyCheckConfig(c, configs[c.Name()], restart)
+	}
+}
+
+// applyCheckConfig applies the runtime configuration of a check.  When
+// restart is set, the check is restarted in the background so that a check
+// in the middle of a long run does not hold up other checks.
+func (k *Kuberhealthy) applyCheckConfig(c KuberhealthyCheck, config checkConfig, restart bool) {
+	k.Lock()
+	k.checkConfigs[c.Name()] = config
+	if config.disabled {
+		// checks that depend on a disabled check are not skipped because of it
+		k.failedChecks[c.Name()] = false
+	}
+	k.Unlock()
+
+	if !restart {
+		reconfigureCheck(c, config.settings)
+		return
+	}
+	k.restartCheckRunner(c, config)
+}
+
+// restartCheckRunner signals the runner of a check to stop and starts it
+// again with its new settings once it returned, unless the check was
+// disabled.  Restarts of the same check wait for each other, and only the
+// newest one starts the check, which none does after checks were stopped.
+func (k *Kuberhealthy) restartCheckRunner(c KuberhealthyCheck, config checkConfig) {
+	restarted := make(chan bool)
+	k.Lock()
+	previous := k.pendingRestarts[c.Name()]
+	k.pendingRestarts[c.Name()] = restarted
+	k.Unlock()
+
+	stopped := k.stopCheckRunner(c.Name())
+
+	go func() {
+		defer close(restarted)
+		if previous != nil {
+			<-previous
+		}
+		<-stopped
+		reconfigureCheck(c, config.settings)
+
+		k.Lock()
+		latest := k.pendingRestarts[c.Name()] == restarted
+		if latest {
+			delete(k.pendingRestarts, c.Name())
+		}
+		k.Unlock()
+		if latest && !config.disabled {
+			k.startCheckRunner(c)
+		}
+	}()
+}
+
+// reconfigureCheck passes the runtime settings of a check to it when it
+// implements Reconfigurable
+func reconfigureCheck(c KuberhealthyCheck, settings map[string]string) {
+	if r, ok := c.(Reconfigurable); ok {
+		err := r.Reconfigure(settings)
+		if err != nil {
+			log.Errorln("Error applying runtime settings to check", c.Name()+":", err)
+		}
+	} else if len(settings) > 0 {
+		log.Warningln("Check", c.Name(), "does not support runtime settings.  Its settings are ignored.")
+	}
+}
+
+// fetchRuntimeConfig returns the data and the resource version of the
+// runtime configuration ConfigMap in the namespace of this pod.  The
+// ConfigMap is listed by name, so that the resource version can be watched
+// from even when it does not exist, in which case no data is returned.
+func (k *Kuberhealthy) fetchRuntimeConfig(client *kubernetes.Clientset) (map[string]string, string, error) {
+	list, err := client.CoreV1().ConfigMaps(os.Getenv("POD_NAMESPACE")).List(metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", k.RuntimeConfigMap).String(),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(list.Items) == 0 {
+		log.Debugln("Runtime configuration ConfigMap", k.RuntimeConfigMap, "does not exist")
+		return map[string]string{}, list.ResourceVersion, nil
+	}
+	return list.Items[0].Data, list.ResourceVersion, nil
+}
+
+// parseRuntimeConfig parses the data of the runtime configuration ConfigMap
+// into the configuration of each check by name.  Keys are the CRD name of a
+// check and a field separated by a '.', such as
+// 'deploymentstatuschecker.interval'.  The 'interval' and 'enabled' fields
+// are applied by Kuberhealthy and any other field is a setting of the check.
+// Keys that are invalid or name a check that is not configured are returned
+// as errors and ignored.
+func parseRuntimeConfig(data map[string]string, checkNames []string) (map[string]checkConfig, []error) {
+	configs := make(map[string]checkConfig)
+	var errs []error
+
+	names := make(map[string]string)
+	for _, name := range checkNames {
+		names[sanitizeCRDName(name)] = name
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := strings.TrimSpace(data[key])
+		i := strings.LastIndex(key, ".")
+		if i < 1 || i == len(key)-1 {
+			errs = append(errs, errors.New("key "+key+" is not in the form '<check>.<field>'"))
+			continue
+		}
+		name, ok := names[key[:i]]
+		if !ok {
+			errs = append(errs, errors.New("key "+key+" does not name a configured check.  Adding checks requires a restart"))
+			continue
+		}
+		field := key[i+1:]
+
+		config := configs[name]
+		switch field {
+		case "interval":
+			interval, err := time.ParseDuration(value)
+			if err != nil || interval <= 0 {
+				errs = append(errs, errors.New("key "+key+" must be a duration greater than zero but is '"+value+"'"))
+				continue
+			}
+			config.interval = interval
+		case "enabled":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, errors.New("key "+key+" must be true or false but is '"+value+"'"))
+				continue
+			}
+			config.disabled = !enabled
+		default:
+			if config.settings == nil {
+				config.settings = make(map[string]string)
+			}
+			config.settings[field] = value
+		}
+		configs[name] = config
+	}
+
+	return configs, errs
+}
+
+// changedCheckConfigFields describes every field that differs between two
+// configurations of a check
+func changedCheckConfigFields(old checkConfig, new checkConfig) []string {
+	var changes []string
+
+	if old.interval != new.interval {
+		changes = append(changes, "interval from "+describeInterval(old.interval)+" to "+describeInterval(new.interval))
+	}
+	if old.disabled != new.disabled {
+		changes = append(changes, "enabled from "+strconv.FormatBool(!old.disabled)+" to "+strconv.FormatBool(!new.disabled))
+	}
+
+	fields := make(map[string]bool)
+	for field := range old.settings {
+		fields[field] = true
+	}
+	for field := range new.settings {
+		fields[field] = true
+	}
+	var sorted []string
+	for field := range fields {
+		sorted = append(sorted, field)
+	}
+	sort.Strings(sorted)
+	for _, field := range sorted {
+		oldValue, oldOK := old.settings[field]
+		newValue, newOK := new.settings[field]
+		if oldOK == newOK && oldValue == newValue {
+			continue
+		}
+		changes = append(changes, field+" from "+describeSetting(oldValue, oldOK)+" to "+describeSetting(newValue, newOK))
+	}
+
+	return changes
+}
+
+// describeInterval describes an interval override for logging
+func describeInterval(interval time.Duration) string {
+	if interval == 0 {
+		return "the default"
+	}
+	return interval.String()
+}
+
+// describeSetting describes a setting value for logging
+func describeSetting(value string, ok bool) string {
+	if !ok {
+		return "the default"
+	}
+	return "'" + value + "'"
+}
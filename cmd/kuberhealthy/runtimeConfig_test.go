@@ -0,0 +1,156 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRuntimeConfig(t *testing.T) {
+	data := map[string]string{
+		"deploymentstatuschecker.interval":               "5m",
+		"deploymentstatuschecker.gracePeriod":            "10m",
+		"podstatuschecker-namespace-kube-system.enabled": "false",
+		"dnsstatuschecker.interval":                      "often",
+		"dnsstatuschecker.enabled":                       "maybe",
+		"removedchecker.interval":                        "1m",
+		"nofield":                                        "1",
+	}
+	checkNames := []string{"DeploymentStatusChecker", "PodStatusChecker namespace kube-system", "DnsStatusChecker"}
+
+	configs, errs := parseRuntimeConfig(data, checkNames)
+	expected := map[string]checkConfig{
+		"DeploymentStatusChecker": {
+			interval: time.Minute * 5,
+			settings: map[string]string{"gracePeriod": "10m"},
+		},
+		"PodStatusChecker namespace kube-system": {disabled: true},
+	}
+	if !reflect.DeepEqual(configs, expected) {
+		t.Fatal("Expected", expected, "but got", configs)
+	}
+	if len(errs) != 4 {
+		t.Fatal("Expected an error for each of the 4 invalid keys but got", errs)
+	}
+}
+
+func TestChangedCheckConfigFields(t *testing.T) {
+	var tests = []struct {
+		description string
+		old         checkConfig
+		new         checkConfig
+		expected    []string
+	}{
+		{"unchanged", checkConfig{interval: time.Minute}, checkConfig{interval: time.Minute}, nil},
+		{"interval set", checkConfig{}, checkConfig{interval: time.Minute}, []string{"interval from the default to 1m0s"}},
+		{"interval removed", checkConfig{interval: time.Minute}, checkConfig{}, []string{"interval from 1m0s to the default"}},
+		{"disabled", checkConfig{}, checkConfig{disabled: true}, []string{"enabled from true to false"}},
+		{
+			"settings changed",
+			checkConfig{settings: map[string]string{"gracePeriod": "5m", "threshold": "3"}},
+			checkConfig{settings: map[string]string{"gracePeriod": "10m", "maxAge": "1h"}},
+			[]string{"gracePeriod from '5m' to '10m'", "maxAge from the default to '1h'", "threshold from '3' to the default"},
+		},
+	}
+
+	for _, test := range tests {
+		changes := changedCheckConfigFields(test.old, test.new)
+		if !reflect.DeepEqual(changes, test.expected) {
+			t.Fatal("Test", test.description, "expected", test.expected, "but got", changes)
+		}
+	}
+}
+
+func TestApplyCheckConfig(t *testing.T) {
+	kh := NewKuberhealthy()
+	defer kh.cancel()
+	fc := NewFakeCheck()
+	kh.AddCheck(fc)
+	kh.startCheckRunner(fc)
+
+	// disabling a check stops only its runner
+	kh.applyCheckConfig(fc, checkConfig{disabled: true}, true)
+	waitForRestart(kh, fc.Name())
+	if !kh.checkDisabled(fc.Name()) {
+		t.Fatal("Expected the check to be disabled")
+	}
+	if len(kh.checkRunners) != 0 || len(kh.checkShutdownChannels) != 0 {
+		t.Fatal("Expected the check runner to be stopped but got", kh.checkRunners, kh.checkShutdownChannels)
+	}
+
+	// enabling it with a new interval starts it again
+	kh.applyCheckConfig(fc, checkConfig{interval: time.Minute}, true)
+	waitForRestart(kh, fc.Name())
+	if kh.checkDisabled(fc.Name()) || kh.checkInterval(fc) != time.Minute {
+		t.Fatal("Expected the check to be enabled with a 1m interval but got", kh.checkConfigs[fc.Name()])
+	}
+	if kh.checkRunners[fc.Name()] == nil || len(kh.checkShutdownChannels) != 1 {
+		t.Fatal("Expected the check runner to be started but got", kh.checkRunners, kh.checkShutdownChannels)
+	}
+
+	kh.StopChecks()
+	kh.runningChecks.Wait()
+}
+
+func TestApplyCheckConfigRunningCheck(t *testing.T) {
+	kh := NewKuberhealthy()
+	defer kh.cancel()
+	fc := NewFakeCheck()
+	kh.AddCheck(fc)
+
+	// a runner that is in the middle of a run
+	stopChan := make(chan bool, 1)
+	done := make(chan bool)
+	kh.checkRunners[fc.Name()] = &checkRunner{stopChan: stopChan, done: done}
+
+	// the restart does not wait for the run to end
+	kh.applyCheckConfig(fc, checkConfig{interval: time.Minute}, true)
+	select {
+	case <-stopChan:
+	default:
+		t.Fatal("Expected the check runner to be signalled to stop")
+	}
+	if kh.checkInterval(fc) != time.Minute {
+		t.Fatal("Expected the new interval to be applied right away but got", kh.checkInterval(fc))
+	}
+	kh.RLock()
+	running := kh.checkRunners[fc.Name()]
+	kh.RUnlock()
+	if running != nil {
+		t.Fatal("Expected the check runner not to be started again while its run has not ended")
+	}
+
+	// the check is started again once its run ends
+	close(done)
+	waitForRestart(kh, fc.Name())
+	kh.RLock()
+	running = kh.checkRunners[fc.Name()]
+	kh.RUnlock()
+	if running == nil {
+		t.Fatal("Expected the check runner to be started again after its run ended")
+	}
+
+	kh.StopChecks()
+	kh.runningChecks.Wait()
+}
+
+// waitForRestart waits until the pending restarts of a check are done
+func waitForRestart(kh *Kuberhealthy, checkName string) {
+	kh.RLock()
+	restarted := kh.pendingRestarts[checkName]
+	kh.RUnlock()
+	if restarted != nil {
+		<-restarted
+	}
+}
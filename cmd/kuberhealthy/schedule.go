@@ -0,0 +1,83 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// checkSchedule returns the parsed cron schedule of a check, or nil when
+// the check runs on its interval
+func checkSchedule(c KuberhealthyCheck) (cron.Schedule, error) {
+	sc, ok := c.(Scheduled)
+	if !ok || len(sc.Schedule()) == 0 {
+		return nil, nil
+	}
+	schedule, err := cron.ParseStandard(sc.Schedule())
+	if err != nil {
+		return nil, errors.New("Error parsing schedule '" + sc.Schedule() + "' of check " + c.Name() + ": " + err.Error())
+	}
+	return schedule, nil
+}
+
+// nextRun returns when a check runs next.  Scheduled checks run at the next
+// time in their schedule, and other checks one interval after their last
+// run.  The zero time is returned when it is not known.
+func (k *Kuberhealthy) nextRun(c KuberhealthyCheck, lastRun time.Time, now time.Time) time.Time {
+	schedule, err := checkSchedule(c)
+	if err == nil && schedule != nil {
+		return schedule.Next(now)
+	}
+	if lastRun.IsZero() {
+		return time.Time{}
+	}
+	return lastRun.Add(k.checkInterval(c))
+}
+
+// runTimer signals when a check should run next, either on the interval of
+// the check or at the times in its cron schedule
+type runTimer struct {
+	ticker   *time.Ticker
+	schedule cron.Schedule
+}
+
+// newRunTimer creates a runTimer that fires at the times in schedule, or
+// every interval when schedule is nil
+func newRunTimer(interval time.Duration, schedule cron.Schedule) *runTimer {
+	if schedule != nil {
+		return &runTimer{schedule: schedule}
+	}
+	return &runTimer{ticker: time.NewTicker(interval)}
+}
+
+// C returns a channel that receives when the check should run next.  A
+// schedule that never fires again returns a channel that never receives.
+func (t *runTimer) C() <-chan time.Time {
+	if t.schedule == nil {
+		return t.ticker.C
+	}
+	next := t.schedule.Next(time.Now())
+	if next.IsZero() {
+		return nil
+	}
+	return time.After(time.Until(next))
+}
+
+// Stop stops the interval ticker of the timer
+func (t *runTimer) Stop() {
+	if t.ticker != nil {
+		t.ticker.Stop()
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// scheduledCheck is a FakeCheck that runs on a cron schedule
+type scheduledCheck struct {
+	*FakeCheck
+	schedule string
+}
+
+// Schedule returns the cron schedule of the fake check
+func (sc *scheduledCheck) Schedule() string {
+	return sc.schedule
+}
+
+func TestCheckSchedule(t *testing.T) {
+	var tests = []struct {
+		description string
+		check       KuberhealthyCheck
+		scheduled   bool
+		expectErr   bool
+	}{
+		{"interval check", NewFakeCheck(), false, false},
+		{"empty schedule", &scheduledCheck{NewFakeCheck(), ""}, false, false},
+		{"business hours", &scheduledCheck{NewFakeCheck(), "0 9-17 * * 1-5"}, true, false},
+		{"descriptor", &scheduledCheck{NewFakeCheck(), "@hourly"}, true, false},
+		{"invalid schedule", &scheduledCheck{NewFakeCheck(), "every day"}, false, true},
+	}
+
+	for _, test := range tests {
+		schedule, err := checkSchedule(test.check)
+		if (err != nil) != test.expectErr {
+			t.Fatal("Test", test.description, "expected an error", test.expectErr, "but got", err)
+		}
+		if (schedule != nil) != test.scheduled {
+			t.Fatal("Test", test.description, "expected a schedule", test.scheduled, "but got", schedule)
+		}
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	kh := NewKuberhealthy()
+	lastRun := time.Date(2019, 4, 5, 16, 20, 0, 0, time.Local)
+	now := lastRun.Add(time.Minute)
+
+	fc := NewFakeCheck()
+	fc.IntervalValue = time.Minute * 15
+	if next := kh.nextRun(fc, lastRun, now); !next.Equal(lastRun.Add(time.Minute * 15)) {
+		t.Fatal("Expected an interval check to run one interval after its last run but got", next)
+	}
+	if next := kh.nextRun(fc, time.Time{}, now); !next.IsZero() {
+		t.Fatal("Expected no next run for an interval check that has not run but got", next)
+	}
+
+	// Friday at 16:21 runs next at 17:00, and Friday at 17:01 on Monday at 9:00
+	sc := &scheduledCheck{NewFakeCheck(), "0 9-17 * * 1-5"}
+	if next := kh.nextRun(sc, lastRun, now); !next.Equal(time.Date(2019, 4, 5, 17, 0, 0, 0, time.Local)) {
+		t.Fatal("Expected the scheduled check to run next at 17:00 but got", next)
+	}
+	if next := kh.nextRun(sc, lastRun, now.Add(time.Minute*40)); !next.Equal(time.Date(2019, 4, 8, 9, 0, 0, 0, time.Local)) {
+		t.Fatal("Expected the scheduled check to run next on Monday at 9:00 but got", next)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/health"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkStateWriter writes check states to their CRDs or the check result
+// Secret in the background so that a slow API server does not delay the
+// check run loop.  While a state is being written for a check, only the
+// latest new state of that check is kept to be written next.  Failed writes
+// are retried.
+type checkStateWriter struct {
+	sync.Mutex
+	target      string                                                    // where states are written, for logging
+	store       func(checkName string, details health.CheckDetails) error // writes a state to the target
+	maxAttempts int                                                       // the number of times a state write is attempted
+	retryDelay  time.Duration                                             // the delay before the first retry, which grows with each retry
+	pending     map[string]health.CheckDetails                            // the next state to write for each check
+	writing     map[string]bool                                           // checks with a write in progress
+	inflight    sync.WaitGroup                                            // tracks write routines so shutdown can wait for them
+}
+
+// newCheckStateWriter creates a checkStateWriter that writes states to
+// target with the store func
+func newCheckStateWriter(target string, store func(checkName string, details health.CheckDetails) error, maxAttempts int, retryDelay time.Duration) *checkStateWriter {
+	return &checkStateWriter{
+		target:      target,
+		store:       store,
+		maxAttempts: maxAttempts,
+		retryDelay:  retryDelay,
+		pending:     make(map[string]health.CheckDetails),
+		writing:     make(map[string]bool),
+	}
+}
+
+// Write queues the state of a check to be written and returns immediately.
+// A state that is queued while an earlier state of the same check is still
+// waiting to be written replaces it.  The last run time of the state is set
+// when it is queued so that it is not moved by slow or retried writes.
+func (w *checkStateWriter) Write(checkName string, details health.CheckDetails) {
+	if details.LastRun.IsZero() {
+		details.LastRun = time.Now()
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	w.pending[checkName] = details
+	if w.writing[checkName] {
+		return
+	}
+	w.writing[checkName] = true
+	w.inflight.Add(1)
+	go w.writeCheck(checkName)
+}
+
+// Wait blocks until every queued state has been written or has exhausted
+// its attempts
+func (w *checkStateWriter) Wait() {
+	w.inflight.Wait()
+}
+
+// writeCheck writes the pending states of a check until none are left
+func (w *checkStateWriter) writeCheck(checkName string) {
+	defer w.inflight.Done()
+
+	for {
+		w.Lock()
+		details, ok := w.pending[checkName]
+		if !ok {
+			w.writing[checkName] = false
+			w.Unlock()
+			return
+		}
+		delete(w.pending, checkName)
+		w.Unlock()
+
+		w.writeWithRetries(checkName, details)
+	}
+}
+
+// writeWithRetries attempts to write a state until it succeeds or its
+// attempts are exhausted, waiting longer between each attempt
+func (w *checkStateWriter) writeWithRetries(checkName string, details health.CheckDetails) {
+	for attempt := 1; ; attempt++ {
+		err := w.store(checkName, details)
+		if err == nil {
+			return
+		}
+		if attempt >= w.maxAttempts {
+			log.Errorln("Error storing state of check", checkName, "in", w.target, "after", attempt, "attempts:", err)
+			return
+		}
+		log.Warningln("Error storing state of check", checkName, "in", w.target+". Retrying:", err)
+		time.Sleep(w.retryDelay * time.Duration(attempt))
+	}
+}
@@ -14,6 +14,7 @@ package main
 import (
 	"errors"
 	"os"
+	"strings"
 )
 
 // getEnvVar attempts to retrieve and then validates an environmental variable
@@ -25,3 +26,46 @@ func getEnvVar(v string) (string, error) {
 	}
 	return envVar, err
 }
+
+// splitFlagList splits a comma separated flag value into its entries,
+// dropping any empty entries
+func splitFlagList(v string) []string {
+	var list []string
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if len(s) > 0 {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// parseMetricTags parses a comma separated list of key=value metric tags.
+// The cluster name is added as the cluster tag when it is set, unless the
+// list sets the cluster tag itself.
+func parseMetricTags(v string, clusterName string) (map[string]string, error) {
+	tags := make(map[string]string)
+	if len(clusterName) > 0 {
+		tags["cluster"] = clusterName
+	}
+	for _, t := range splitFlagList(v) {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+			return nil, errors.New("metric tag " + t + " is not in key=value form")
+		}
+		tags[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return tags, nil
+}
+
+// splitNodeSelectorFlag splits a daemonset node selector flag value into its
+// node selector and the pause container image that may follow it after a
+// ';'.  The image is empty when none is given.
+func splitNodeSelectorFlag(v string) (string, string) {
+	parts := strings.SplitN(v, ";", 2)
+	selector := strings.TrimSpace(parts[0])
+	if len(parts) == 1 {
+		return selector, ""
+	}
+	return selector, strings.TrimSpace(parts[1])
+}
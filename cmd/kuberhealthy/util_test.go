@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMetricTags(t *testing.T) {
+	var tests = []struct {
+		description string
+		tags        string
+		clusterName string
+		expected    map[string]string
+		expectErr   bool
+	}{
+		{"no tags", "", "", map[string]string{}, false},
+		{"tags", "region=us-east-1, env=prod", "", map[string]string{"region": "us-east-1", "env": "prod"}, false},
+		{"cluster name", "env=prod", "prod-east", map[string]string{"cluster": "prod-east", "env": "prod"}, false},
+		{"cluster tag set explicitly", "cluster=east", "prod-east", map[string]string{"cluster": "east"}, false},
+		{"missing value", "region", "", nil, true},
+		{"missing key", "=prod", "", nil, true},
+	}
+
+	for _, test := range tests {
+		tags, err := parseMetricTags(test.tags, test.clusterName)
+		if test.expectErr {
+			if err == nil {
+				t.Fatal("Test", test.description, "expected an error but got", tags)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal("Test", test.description, "unexpected error", err)
+		}
+		if !reflect.DeepEqual(tags, test.expected) {
+			t.Fatal("Test", test.description, "expected", test.expected, "but got", tags)
+		}
+	}
+}
@@ -17,9 +17,12 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/Comcast/kuberhealthy/pkg/checks/external"
 	"github.com/Comcast/kuberhealthy/pkg/health"
 	"github.com/Pallinder/go-randomdata"
 	"k8s.io/client-go/kubernetes"
@@ -153,3 +156,157 @@ func TestWebServerNotOK(t *testing.T) {
 	}
 
 }
+
+// TestExternalCheckStatusHandler tests that invalid external check results
+// are rejected
+func TestExternalCheckStatusHandler(t *testing.T) {
+	kh := NewKuberhealthy()
+
+	var tests = []struct {
+		description  string
+		method       string
+		runUUID      string
+		body         string
+		expectedCode int
+	}{
+		{"wrong method", http.MethodGet, "abc", "", http.StatusMethodNotAllowed},
+		{"invalid body", http.MethodPost, "abc", "not json", http.StatusBadRequest},
+		{"unknown run", http.MethodPost, "abc", `{"ok": true, "errors": []}`, http.StatusNotFound},
+		{"missing run ID", http.MethodPost, "", `{"ok": true, "errors": []}`, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, "/externalCheckStatus", bytes.NewBufferString(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(external.RunUUIDHeader, test.runUUID)
+		recorder := httptest.NewRecorder()
+
+		err = kh.externalCheckStatusHandler(recorder, req)
+		if err == nil || recorder.Code != test.expectedCode {
+			t.Fatal("Test", test.description, "expected status", test.expectedCode, "and an error but got", recorder.Code, err)
+		}
+		t.Log(test.description, err)
+	}
+}
+
+// TestExternalCheckReportingURL tests that external checks report to the
+// listen port on the IP of this pod
+func TestExternalCheckReportingURL(t *testing.T) {
+	os.Setenv("POD_IP", "10.0.0.1")
+	defer os.Unsetenv("POD_IP")
+
+	kh := NewKuberhealthy()
+	kh.ListenAddr = ":8080"
+	url := kh.externalCheckReportingURL()
+	if url != "http://10.0.0.1:8080/externalCheckStatus" {
+		t.Fatal("Unexpected reporting URL", url)
+	}
+}
+
+// TestCheckHistoryHandler tests that recent check results are served newest
+// first and can be paged through
+func TestCheckHistoryHandler(t *testing.T) {
+	kh := NewKuberhealthy()
+	kh.History = health.NewHistory(10)
+	for i := 0; i < 3; i++ {
+		kh.recordCheckResult("DaemonSetChecker", time.Second, i != 1, []string{})
+	}
+	kh.recordCheckResult("ComponentStatusChecker", time.Second, true, []string{})
+
+	var tests = []struct {
+		description  string
+		query        string
+		expectedCode int
+		expectedOK   map[string][]bool
+	}{
+		{"all checks", "", http.StatusOK, map[string][]bool{"DaemonSetChecker": {true, false, true}, "ComponentStatusChecker": {true}}},
+		{"one check", "?check=DaemonSetChecker", http.StatusOK, map[string][]bool{"DaemonSetChecker": {true, false, true}}},
+		{"paged", "?check=DaemonSetChecker&offset=1&limit=1", http.StatusOK, map[string][]bool{"DaemonSetChecker": {false}}},
+		{"invalid offset", "?offset=-1", http.StatusBadRequest, nil},
+		{"invalid limit", "?limit=ten", http.StatusBadRequest, nil},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodGet, "/checkHistory"+test.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorder := httptest.NewRecorder()
+		kh.checkHistoryHandler(recorder, req)
+		if recorder.Code != test.expectedCode {
+			t.Fatal("Test", test.description, "expected status", test.expectedCode, "but got", recorder.Code)
+		}
+		if test.expectedCode != http.StatusOK {
+			continue
+		}
+
+		var history map[string][]health.CheckResult
+		err = json.Unmarshal(recorder.Body.Bytes(), &history)
+		if err != nil {
+			t.Fatal("Test", test.description, "error decoding response:", err)
+		}
+		if len(history) != len(test.expectedOK) {
+			t.Fatal("Test", test.description, "expected", test.expectedOK, "but got", history)
+		}
+		for check, expected := range test.expectedOK {
+			results := history[check]
+			if len(results) != len(expected) {
+				t.Fatal("Test", test.description, "expected", expected, "for", check, "but got", results)
+			}
+			for i := range expected {
+				if results[i].OK != expected[i] || results[i].Check != check || results[i].DurationSeconds != 1 {
+					t.Fatal("Test", test.description, "expected", expected, "for", check, "but got", results)
+				}
+			}
+		}
+		t.Log(test.description, history)
+	}
+}
+
+func TestWantsDashboard(t *testing.T) {
+	var tests = []struct {
+		description string
+		path        string
+		accept      string
+		expected    bool
+	}{
+		{"browser", "/", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", true},
+		{"json client", "/", "application/json", false},
+		{"no accept header", "/", "", false},
+		{"browser on another path", "/healthz", "text/html", false},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodGet, test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", test.accept)
+		if wantsDashboard(req) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "but got", wantsDashboard(req))
+		}
+	}
+}
+
+func TestDashboardHandler(t *testing.T) {
+	os.Setenv("POD_NAME", "kuberhealthy-test")
+	kh := NewKuberhealthy()
+
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	err = kh.dashboardHandler(recorder, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/html") {
+		t.Fatal("Expected an HTML content type but got", recorder.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(recorder.Body.String(), `data-pod="kuberhealthy-test"`) {
+		t.Fatal("Expected the pod name in the dashboard")
+	}
+}
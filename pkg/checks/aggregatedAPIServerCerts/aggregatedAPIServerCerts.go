@@ -0,0 +1,309 @@
+// Package aggregatedAPIServerCerts implements a checker that ensures the
+// serving certificates of aggregated API servers match the CA bundles of
+// their APIService objects.  When a certificate is rotated without updating
+// the caBundle, or expires, the API server can no longer reach the extension
+// and every request to its API group fails.
+package aggregatedAPIServerCerts // import "github.com/Comcast/kuberhealthy/pkg/checks/aggregatedAPIServerCerts"
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const apiRegistrationGroup = "apiregistration.k8s.io"
+
+// apiRegistrationVersions are the versions of the APIService API in order of
+// preference
+var apiRegistrationVersions = []string{"v1", "v1beta1"}
+
+// defaultServicePort is the port the API server uses to reach an extension
+// service when the APIService does not set one
+const defaultServicePort = 443
+
+// apiServiceList is the subset of an APIServiceList used by this check.  The
+// aggregator client is not vendored, so the fields are decoded here.
+type apiServiceList struct {
+	Items []apiService `json:"items"`
+}
+
+// apiService is the subset of an APIService used by this check
+type apiService struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Service               *serviceReference `json:"service"`
+		CABundle              []byte            `json:"caBundle"`
+		InsecureSkipTLSVerify bool              `json:"insecureSkipTLSVerify"`
+	} `json:"spec"`
+}
+
+// serviceReference is the extension service an APIService is backed by
+type serviceReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Port      *int   `json:"port"`
+}
+
+// Checker validates the serving certificates of aggregated API servers
+type Checker struct {
+	Errors     []string
+	ExpiryDays int
+	client     *kubernetes.Clientset
+	// dial is replaced in tests to inject the certificates an extension
+	// server presents
+	dial     func(address string, serverName string) ([]*x509.Certificate, error)
+	interval time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that also requires certificates to be valid for
+// at least expiryDays
+func New(expiryDays int) *Checker {
+	return &Checker{
+		Errors:     []string{},
+		ExpiryDays: expiryDays,
+		dial:       dialTLS,
+	}
+}
+
+// Name returns the name of this checker
+func (acc *Checker) Name() string {
+	return "AggregatedAPIServerCertsChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (acc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (acc *Checker) Interval() time.Duration {
+	if acc.interval > 0 {
+		return acc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (acc *Checker) SetInterval(d time.Duration) {
+	acc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  APIServices are
+// cluster scoped.
+func (acc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{apiRegistrationGroup}, Resources: []string{"apiservices"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (acc *Checker) Timeout() time.Duration {
+	return time.Minute * 2
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (acc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (acc *Checker) CurrentStatus() (bool, []string) {
+	if len(acc.Errors) > 0 {
+		return false, acc.Errors
+	}
+	return true, acc.Errors
+}
+
+// clearErrors clears all errors
+func (acc *Checker) clearErrors() {
+	acc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (acc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	acc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := acc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(acc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + acc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(acc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + acc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + acc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists APIServices and sets an error for every extension server
+// whose certificate does not match its caBundle or expires soon
+func (acc *Checker) doChecks() error {
+
+	version, found := acc.findAPIRegistrationVersion()
+	if !found {
+		return errors.New("Unable to find a served version of the " + apiRegistrationGroup + " API")
+	}
+
+	b, err := acc.client.CoreV1().RESTClient().Get().AbsPath("/apis", apiRegistrationGroup, version, "apiservices").DoRaw()
+	if err != nil {
+		return err
+	}
+	var apiServices apiServiceList
+	err = json.Unmarshal(b, &apiServices)
+	if err != nil {
+		return errors.New("Error decoding APIServices: " + err.Error())
+	}
+
+	certErrors := acc.evaluateAPIServices(apiServices.Items, time.Now())
+
+	if len(certErrors) > 0 {
+		for _, e := range certErrors {
+			khlog.ForCheck(acc.Name()).Warningln(e)
+		}
+		acc.Errors = certErrors
+		return nil
+	}
+
+	acc.clearErrors()
+	return nil
+}
+
+// findAPIRegistrationVersion uses discovery to find the preferred served
+// version of the APIService API
+func (acc *Checker) findAPIRegistrationVersion() (string, bool) {
+	for _, v := range apiRegistrationVersions {
+		resources, err := acc.client.Discovery().ServerResourcesForGroupVersion(apiRegistrationGroup + "/" + v)
+		if err != nil {
+			continue
+		}
+		for _, r := range resources.APIResources {
+			if r.Name == "apiservices" {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+// evaluateAPIServices dials the extension server of every APIService that
+// is not served locally and returns an error for every server that can not
+// be reached, whose certificate is not signed by the caBundle for the
+// service name the API server uses, or whose certificate expires within the
+// configured number of days
+func (acc *Checker) evaluateAPIServices(apiServices []apiService, now time.Time) []string {
+	var certErrors []string
+
+	for _, s := range apiServices {
+		if s.Spec.Service == nil {
+			continue
+		}
+		name := s.Metadata.Name
+
+		port := defaultServicePort
+		if s.Spec.Service.Port != nil {
+			port = *s.Spec.Service.Port
+		}
+		serverName := s.Spec.Service.Name + "." + s.Spec.Service.Namespace + ".svc"
+		chain, err := acc.dial(net.JoinHostPort(serverName, strconv.Itoa(port)), serverName)
+		if err != nil {
+			certErrors = append(certErrors, "Unable to connect to the extension server of APIService "+name+": "+err.Error())
+			continue
+		}
+		if len(chain) == 0 {
+			certErrors = append(certErrors, "The extension server of APIService "+name+" presented no certificate")
+			continue
+		}
+		leaf := chain[0]
+
+		if s.Spec.InsecureSkipTLSVerify {
+			certErrors = append(certErrors, "APIService "+name+" skips TLS verification of its extension server with certificate fingerprint "+fingerprint(leaf))
+		} else if e := verifyChain(s.Spec.CABundle, chain, serverName, now); len(e) > 0 {
+			certErrors = append(certErrors, "APIService "+name+" extension server certificate with fingerprint "+fingerprint(leaf)+" does not match its caBundle: "+e)
+		}
+
+		expiry := now.Add(time.Hour * 24 * time.Duration(acc.ExpiryDays))
+		if leaf.NotAfter.Before(expiry) {
+			certErrors = append(certErrors, "APIService "+name+" extension server certificate with fingerprint "+fingerprint(leaf)+" expires at "+leaf.NotAfter.Format(time.RFC3339))
+		}
+	}
+	return certErrors
+}
+
+// verifyChain verifies a certificate chain against a PEM encoded CA bundle
+// and returns a description of the failure, or an empty string if the chain
+// is valid
+func verifyChain(caBundle []byte, chain []*x509.Certificate, serverName string, now time.Time) string {
+	if len(caBundle) == 0 {
+		return "no caBundle is set"
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caBundle) {
+		return "caBundle contains no PEM certificates"
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       serverName,
+		CurrentTime:   now,
+	})
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// fingerprint returns the SHA-256 fingerprint of a certificate
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// dialTLS connects to a server and returns the certificates it presents
+// without verifying them
+func dialTLS(address string, serverName string) ([]*x509.Certificate, error) {
+	dialer := &net.Dialer{Timeout: time.Second * 10}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		ServerName: serverName,
+		// the certificates are verified against the caBundle by the check
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates, nil
+}
@@ -0,0 +1,147 @@
+package aggregatedAPIServerCerts
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// makeCA returns a self signed CA certificate, its key, and its PEM encoding
+func makeCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "extension-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour * 24 * 365),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// makeServerCert returns a serving certificate for the DNS name signed by
+// the CA and valid for the duration
+func makeServerCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, dnsName string, validFor time.Duration) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validFor),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// makeAPIService decodes an APIService backed by metrics-server
+func makeAPIService(t *testing.T, caBundle []byte, insecure bool) apiService {
+	spec := map[string]interface{}{
+		"service":               map[string]interface{}{"namespace": "kube-system", "name": "metrics-server"},
+		"caBundle":              caBundle,
+		"insecureSkipTLSVerify": insecure,
+	}
+	b, err := json.Marshal(map[string]interface{}{"metadata": map[string]string{"name": "v1beta1.metrics.k8s.io"}, "spec": spec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s apiService
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestEvaluateAPIServices(t *testing.T) {
+	ca, caKey, caPEM := makeCA(t)
+	otherCA, otherKey, otherPEM := makeCA(t)
+	const serverName = "metrics-server.kube-system.svc"
+
+	valid := makeServerCert(t, ca, caKey, serverName, time.Hour*24*90)
+	expiring := makeServerCert(t, ca, caKey, serverName, time.Hour*24*10)
+	wrongName := makeServerCert(t, ca, caKey, "metrics-server.default.svc", time.Hour*24*90)
+	rotated := makeServerCert(t, otherCA, otherKey, serverName, time.Hour*24*90)
+
+	var tests = []struct {
+		description string
+		apiService  apiService
+		chain       []*x509.Certificate
+		dialErr     error
+		expected    int
+	}{
+		{"matching certificate", makeAPIService(t, caPEM, false), []*x509.Certificate{valid}, nil, 0},
+		{"expiring certificate", makeAPIService(t, caPEM, false), []*x509.Certificate{expiring}, nil, 1},
+		{"wrong server name", makeAPIService(t, caPEM, false), []*x509.Certificate{wrongName}, nil, 1},
+		{"rotated without caBundle", makeAPIService(t, caPEM, false), []*x509.Certificate{rotated}, nil, 1},
+		{"caBundle of other CA", makeAPIService(t, otherPEM, false), []*x509.Certificate{valid}, nil, 1},
+		{"no caBundle", makeAPIService(t, nil, false), []*x509.Certificate{valid}, nil, 1},
+		{"insecure", makeAPIService(t, nil, true), []*x509.Certificate{valid}, nil, 1},
+		{"unreachable", makeAPIService(t, caPEM, false), nil, errors.New("connection refused"), 1},
+	}
+
+	for _, test := range tests {
+		checker := New(30)
+		chain := test.chain
+		dialErr := test.dialErr
+		var dialed string
+		checker.dial = func(address string, name string) ([]*x509.Certificate, error) {
+			dialed = address
+			return chain, dialErr
+		}
+
+		certErrors := checker.evaluateAPIServices([]apiService{test.apiService}, time.Now())
+		if len(certErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", certErrors)
+		}
+		if dialed != serverName+":443" {
+			t.Fatal("Test", test.description, "dialed", dialed)
+		}
+		t.Log(test.description, certErrors)
+	}
+
+	// local APIServices are not dialed
+	var local apiService
+	local.Metadata.Name = "v1.apps"
+	checker := New(30)
+	checker.dial = func(address string, name string) ([]*x509.Certificate, error) {
+		t.Fatal("Local APIService was dialed")
+		return nil, nil
+	}
+	if certErrors := checker.evaluateAPIServices([]apiService{local}, time.Now()); len(certErrors) != 0 {
+		t.Fatal("Expected no errors for a local APIService but got", certErrors)
+	}
+}
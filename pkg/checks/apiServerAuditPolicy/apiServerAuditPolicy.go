@@ -0,0 +1,202 @@
+// Package apiServerAuditPolicy implements a checker that validates the audit
+// policy of the kube-apiserver.  Requests to secrets must be audited with
+// their request bodies for non-system users, and high-throughput resources
+// such as events must not be audited with their response bodies.
+package apiServerAuditPolicy // import "github.com/Comcast/kuberhealthy/pkg/checks/apiServerAuditPolicy"
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// auditPolicyFlag is the kube-apiserver flag that sets the audit policy file
+const auditPolicyFlag = "--audit-policy-file"
+
+// Checker validates the kube-apiserver audit policy
+type Checker struct {
+	Errors     []string
+	PolicyPath string
+	client     *kubernetes.Clientset
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reads the audit policy from policyPath.  If
+// policyPath is empty, the path is found by inspecting the --audit-policy-file
+// flag of the kube-apiserver static pods and read from the same path, which
+// must be mounted into the kuberhealthy pod.
+func New(policyPath string) *Checker {
+	return &Checker{
+		Errors:     []string{},
+		PolicyPath: policyPath,
+	}
+}
+
+// Name returns the name of this checker
+func (apc *Checker) Name() string {
+	return "APIServerAuditPolicyChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (apc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (apc *Checker) Interval() time.Duration {
+	if apc.interval > 0 {
+		return apc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (apc *Checker) SetInterval(d time.Duration) {
+	apc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  The kube-apiserver
+// pods are only listed when no policy path is set.
+func (apc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	if len(apc.PolicyPath) > 0 {
+		return map[string][]rbacv1.PolicyRule{}
+	}
+	return map[string][]rbacv1.PolicyRule{
+		metav1.NamespaceSystem: {
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (apc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (apc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (apc *Checker) CurrentStatus() (bool, []string) {
+	if len(apc.Errors) > 0 {
+		return false, apc.Errors
+	}
+	return true, apc.Errors
+}
+
+// clearErrors clears all errors
+func (apc *Checker) clearErrors() {
+	apc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (apc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	apc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := apc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(apc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + apc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(apc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + apc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + apc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks reads and evaluates the audit policy
+func (apc *Checker) doChecks() error {
+
+	policyPath := apc.PolicyPath
+	if len(policyPath) == 0 {
+		var err error
+		policyPath, err = apc.findPolicyPath()
+		if err != nil {
+			return err
+		}
+		if len(policyPath) == 0 {
+			apc.Errors = []string{"kube-apiserver is not configured with " + auditPolicyFlag + " so requests are not audited"}
+			return nil
+		}
+	}
+
+	b, err := ioutil.ReadFile(policyPath)
+	if err != nil {
+		return errors.New("Error reading audit policy " + policyPath + ": " + err.Error())
+	}
+	var p policy
+	err = yaml.Unmarshal(b, &p)
+	if err != nil {
+		return errors.New("Error parsing audit policy " + policyPath + ": " + err.Error())
+	}
+
+	violations := evaluatePolicy(p)
+	if len(violations) > 0 {
+		for _, v := range violations {
+			khlog.ForCheck(apc.Name()).Warningln(v)
+		}
+		apc.Errors = violations
+		return nil
+	}
+
+	apc.clearErrors()
+	return nil
+}
+
+// findPolicyPath inspects the kube-apiserver static pods for the audit policy
+// file flag.  An empty path is returned if the flag is not set.
+func (apc *Checker) findPolicyPath() (string, error) {
+	pods, err := apc.client.CoreV1().Pods(metav1.NamespaceSystem).List(metav1.ListOptions{
+		LabelSelector: "component=kube-apiserver",
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(pods.Items) == 0 {
+		return "", errors.New("Unable to find kube-apiserver static pods to inspect for the audit policy. Set the audit policy path instead")
+	}
+	return policyPathFromPod(pods.Items[0]), nil
+}
+
+// policyPathFromPod returns the value of the audit policy file flag from the
+// kube-apiserver container of a pod
+func policyPathFromPod(pod apiv1.Pod) string {
+	for _, c := range pod.Spec.Containers {
+		args := append(append([]string{}, c.Command...), c.Args...)
+		for i, a := range args {
+			if strings.HasPrefix(a, auditPolicyFlag+"=") {
+				return strings.TrimPrefix(a, auditPolicyFlag+"=")
+			}
+			if a == auditPolicyFlag && i+1 < len(args) {
+				return args[i+1]
+			}
+		}
+	}
+	return ""
+}
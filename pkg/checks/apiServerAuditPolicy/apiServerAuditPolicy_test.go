@@ -0,0 +1,121 @@
+package apiServerAuditPolicy
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestEvaluatePolicy(t *testing.T) {
+
+	var tests = []struct {
+		description string
+		policy      string
+		expected    int
+	}{
+		{
+			"compliant policy",
+			`apiVersion: audit.k8s.io/v1
+kind: Policy
+rules:
+- level: Request
+  resources:
+  - group: ""
+    resources: ["secrets"]
+- level: Metadata
+  resources:
+  - group: ""
+    resources: ["events"]
+  - group: "events.k8s.io"
+    resources: ["events"]
+- level: RequestResponse`,
+			0,
+		},
+		{
+			"secrets only audited as metadata",
+			`rules:
+- level: Metadata
+  resources:
+  - group: ""
+    resources: ["secrets", "configmaps"]
+- level: Metadata`,
+			7,
+		},
+		{
+			"secrets writes audited but reads ignored",
+			`rules:
+- level: None
+  verbs: ["get", "list", "watch"]
+  resources:
+  - group: ""
+    resources: ["secrets"]
+- level: Request
+  resources:
+  - group: ""
+    resources: ["secrets"]
+- level: None`,
+			3,
+		},
+		{
+			"catch-all RequestResponse includes events",
+			`rules:
+- level: RequestResponse`,
+			24,
+		},
+		{
+			"system users excluded before secrets rule",
+			`rules:
+- level: None
+  userGroups: ["system:nodes"]
+- level: RequestResponse
+  resources:
+  - group: ""
+    resources: ["secrets"]
+- level: RequestResponse
+  users: ["system:kube-controller-manager"]
+  resources:
+  - group: ""
+    resources: ["events"]`,
+			6,
+		},
+		{
+			"empty policy audits nothing",
+			`rules: []`,
+			7,
+		},
+	}
+
+	for _, test := range tests {
+		var p policy
+		err := yaml.Unmarshal([]byte(test.policy), &p)
+		if err != nil {
+			t.Fatal("Test", test.description, "failed to parse:", err)
+		}
+		violations := evaluatePolicy(p)
+		if len(violations) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "violations but got", len(violations), violations)
+		}
+		t.Log(test.description, violations)
+	}
+}
+
+func TestPolicyPathFromPod(t *testing.T) {
+	pod := apiv1.Pod{Spec: apiv1.PodSpec{Containers: []apiv1.Container{{
+		Command: []string{"kube-apiserver", "--advertise-address=10.0.0.1", "--audit-policy-file=/etc/kubernetes/audit-policy.yaml"},
+	}}}}
+	if path := policyPathFromPod(pod); path != "/etc/kubernetes/audit-policy.yaml" {
+		t.Fatal("Unexpected audit policy path:", path)
+	}
+
+	pod.Spec.Containers[0].Command = []string{"kube-apiserver"}
+	pod.Spec.Containers[0].Args = []string{"--audit-policy-file", "/srv/audit.yaml"}
+	if path := policyPathFromPod(pod); path != "/srv/audit.yaml" {
+		t.Fatal("Unexpected audit policy path:", path)
+	}
+
+	pod.Spec.Containers[0].Args = nil
+	if path := policyPathFromPod(pod); path != "" {
+		t.Fatal("Expected no audit policy path but got", path)
+	}
+}
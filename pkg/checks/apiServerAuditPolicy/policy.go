@@ -0,0 +1,175 @@
+package apiServerAuditPolicy
+
+import (
+	"strconv"
+	"strings"
+)
+
+// audit levels
+const (
+	levelNone            = "None"
+	levelRequest         = "Request"
+	levelRequestResponse = "RequestResponse"
+)
+
+// policy is the subset of an audit.k8s.io Policy used by this check.  The
+// audit API types live in k8s.io/apiserver which is not vendored, so the
+// fields are decoded here.
+type policy struct {
+	Rules []policyRule `json:"rules"`
+}
+
+// policyRule is a single rule of an audit policy
+type policyRule struct {
+	Level           string           `json:"level"`
+	Users           []string         `json:"users"`
+	UserGroups      []string         `json:"userGroups"`
+	Verbs           []string         `json:"verbs"`
+	Resources       []groupResources `json:"resources"`
+	Namespaces      []string         `json:"namespaces"`
+	NonResourceURLs []string         `json:"nonResourceURLs"`
+}
+
+// groupResources selects resources of an API group
+type groupResources struct {
+	Group         string   `json:"group"`
+	Resources     []string `json:"resources"`
+	ResourceNames []string `json:"resourceNames"`
+}
+
+// request is a representative API request evaluated against the policy
+type request struct {
+	User      string
+	Groups    []string
+	Verb      string
+	Group     string
+	Resource  string
+	Namespace string
+}
+
+// nonSystemUser represents requests made by people and applications
+var nonSystemUser = request{User: "kuberhealthy-audit-probe", Groups: []string{"system:authenticated"}}
+
+// systemUser represents requests made by cluster components
+var systemUser = request{User: "system:kube-controller-manager", Groups: []string{"system:authenticated"}}
+
+// secretVerbs are the verbs that must be audited on secrets
+var secretVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+
+// highThroughputResources are resources that must not be audited with
+// response bodies, keyed by API group
+var highThroughputResources = map[string]string{
+	"":              "events",
+	"events.k8s.io": "events",
+}
+
+// evaluatePolicy returns a violation for each representative request that is
+// audited at the wrong level
+func evaluatePolicy(p policy) []string {
+	var violations []string
+
+	for _, verb := range secretVerbs {
+		r := nonSystemUser
+		r.Verb = verb
+		r.Resource = "secrets"
+		r.Namespace = "default"
+		level, index := p.levelFor(r)
+		if level != levelRequest && level != levelRequestResponse {
+			violations = append(violations, verb+" requests to secrets by non-system users are audited at level "+level+" by "+describeRule(index)+" instead of Request or RequestResponse")
+		}
+	}
+
+	for _, group := range []string{"", "events.k8s.io"} {
+		for _, user := range []request{nonSystemUser, systemUser} {
+			for _, verb := range []string{"create", "update", "patch", "get", "list", "watch"} {
+				r := user
+				r.Verb = verb
+				r.Group = group
+				r.Resource = highThroughputResources[group]
+				r.Namespace = "default"
+				level, index := p.levelFor(r)
+				if level == levelRequestResponse {
+					violations = append(violations, verb+" requests to "+describeResource(group, r.Resource)+" by "+r.User+" are audited at level RequestResponse by "+describeRule(index)+" which is too verbose for a high-throughput resource")
+				}
+			}
+		}
+	}
+	return violations
+}
+
+// levelFor returns the audit level of the first rule matching the request
+// along with the index of that rule, or None and -1 if no rule matches
+func (p policy) levelFor(r request) (string, int) {
+	for i, rule := range p.Rules {
+		if rule.matches(r) {
+			return rule.Level, i
+		}
+	}
+	return levelNone, -1
+}
+
+// matches determines if a rule applies to a resource request
+func (rule policyRule) matches(r request) bool {
+	if len(rule.Users) > 0 && !contains(rule.Users, r.User) {
+		return false
+	}
+	if len(rule.UserGroups) > 0 {
+		var found bool
+		for _, g := range r.Groups {
+			if contains(rule.UserGroups, g) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(rule.Verbs) > 0 && !contains(rule.Verbs, r.Verb) {
+		return false
+	}
+	// rules that only select non-resource urls never match resource requests
+	if len(rule.NonResourceURLs) > 0 && len(rule.Resources) == 0 {
+		return false
+	}
+	if len(rule.Namespaces) > 0 && !contains(rule.Namespaces, r.Namespace) {
+		return false
+	}
+	if len(rule.Resources) == 0 {
+		return true
+	}
+	for _, gr := range rule.Resources {
+		if gr.Group != r.Group || len(gr.ResourceNames) > 0 {
+			continue
+		}
+		if len(gr.Resources) == 0 || contains(gr.Resources, r.Resource) || contains(gr.Resources, "*") {
+			return true
+		}
+	}
+	return false
+}
+
+// contains determines if a string is in a slice
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// describeRule formats a rule index for violation messages
+func describeRule(index int) string {
+	if index < 0 {
+		return "no rule"
+	}
+	return "rule " + strconv.Itoa(index+1)
+}
+
+// describeResource formats a resource and its group for violation messages
+func describeResource(group string, resource string) string {
+	if len(group) == 0 {
+		return resource
+	}
+	return strings.Join([]string{resource, group}, ".")
+}
@@ -0,0 +1,243 @@
+// Package auditAnomalies implements a checker that reads Kubernetes audit
+// events and reports anomalous request patterns.  Bulk secret reads by a
+// single service account, repeated authentication failures, and API access
+// from unknown IP ranges are counted for each run and compared against
+// thresholds.
+package auditAnomalies // import "github.com/Comcast/kuberhealthy/pkg/checks/auditAnomalies"
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// secretReadVerbs are the verbs that read secrets
+var secretReadVerbs = []string{"get", "list", "watch"}
+
+// Checker finds anomalous request patterns in audit events
+type Checker struct {
+	Errors               []string
+	Source               AuditSource
+	SecretReadThreshold  int
+	AuthFailureThreshold int
+	KnownCIDRs           []*net.IPNet
+	client               *kubernetes.Clientset
+	// lastRun is the time the previous run read events up to
+	lastRun  time.Time
+	interval time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reads events from source.  Service accounts
+// reading more than secretReadThreshold secrets and source IPs failing
+// authentication more than authFailureThreshold times in a run are reported.
+// When knownCIDRs are supplied, requests from any other IP are reported.
+func New(source AuditSource, secretReadThreshold int, authFailureThreshold int, knownCIDRs []string) (*Checker, error) {
+	aac := &Checker{
+		Errors:               []string{},
+		Source:               source,
+		SecretReadThreshold:  secretReadThreshold,
+		AuthFailureThreshold: authFailureThreshold,
+	}
+	for _, c := range knownCIDRs {
+		_, cidr, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, errors.New("invalid known CIDR " + c + ": " + err.Error())
+		}
+		aac.KnownCIDRs = append(aac.KnownCIDRs, cidr)
+	}
+	return aac, nil
+}
+
+// Name returns the name of this checker
+func (aac *Checker) Name() string {
+	return "AuditAnomaliesChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (aac *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (aac *Checker) Interval() time.Duration {
+	if aac.interval > 0 {
+		return aac.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (aac *Checker) SetInterval(d time.Duration) {
+	aac.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Audit events are read
+// from a file or a log store instead of the Kubernetes API, so none are
+// needed.
+func (aac *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (aac *Checker) Timeout() time.Duration {
+	return time.Minute * 2
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (aac *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (aac *Checker) CurrentStatus() (bool, []string) {
+	if len(aac.Errors) > 0 {
+		return false, aac.Errors
+	}
+	return true, aac.Errors
+}
+
+// clearErrors clears all errors
+func (aac *Checker) clearErrors() {
+	aac.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (aac *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	aac.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := aac.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(aac.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + aac.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(aac.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + aac.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + aac.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks reads the audit events since the previous run and sets an error
+// for every anomalous pattern found
+func (aac *Checker) doChecks() error {
+
+	now := time.Now()
+	since := aac.lastRun
+	if since.IsZero() {
+		since = now.Add(-aac.Interval())
+	}
+
+	events, err := aac.Source.Events(since)
+	if err != nil {
+		return err
+	}
+	aac.lastRun = now
+
+	anomalyErrors := aac.findAnomalies(events)
+	if len(anomalyErrors) > 0 {
+		for _, e := range anomalyErrors {
+			khlog.ForCheck(aac.Name()).Warningln(e)
+		}
+		aac.Errors = anomalyErrors
+		return nil
+	}
+
+	aac.clearErrors()
+	return nil
+}
+
+// findAnomalies counts secret reads by service account, authentication
+// failures by source IP, and requests from unknown source IPs, and returns
+// an error for every count above its threshold
+func (aac *Checker) findAnomalies(events []Event) []string {
+	secretReads := make(map[string]int)
+	authFailures := make(map[string]int)
+	unknownSources := make(map[string]int)
+
+	for _, e := range events {
+		if strings.HasPrefix(e.User.Username, "system:serviceaccount:") && e.ObjectRef != nil && e.ObjectRef.Resource == "secrets" && containsString(secretReadVerbs, e.Verb) {
+			secretReads[e.User.Username]++
+		}
+		for _, ip := range e.SourceIPs {
+			if e.ResponseStatus != nil && e.ResponseStatus.Code == 401 {
+				authFailures[ip]++
+			}
+			if len(aac.KnownCIDRs) > 0 && !aac.known(ip) {
+				unknownSources[ip]++
+			}
+		}
+	}
+
+	var anomalyErrors []string
+	for _, user := range sortedKeys(secretReads) {
+		if secretReads[user] > aac.SecretReadThreshold {
+			anomalyErrors = append(anomalyErrors, "Service account "+strings.TrimPrefix(user, "system:serviceaccount:")+" read secrets "+strconv.Itoa(secretReads[user])+" times, more than the threshold of "+strconv.Itoa(aac.SecretReadThreshold))
+		}
+	}
+	for _, ip := range sortedKeys(authFailures) {
+		if authFailures[ip] > aac.AuthFailureThreshold {
+			anomalyErrors = append(anomalyErrors, "Source IP "+ip+" failed authentication "+strconv.Itoa(authFailures[ip])+" times, more than the threshold of "+strconv.Itoa(aac.AuthFailureThreshold))
+		}
+	}
+	for _, ip := range sortedKeys(unknownSources) {
+		anomalyErrors = append(anomalyErrors, "Source IP "+ip+" outside of the known CIDRs made "+strconv.Itoa(unknownSources[ip])+" API requests")
+	}
+	return anomalyErrors
+}
+
+// known returns true if an IP is within one of the known CIDRs.  Unparsable
+// IPs are treated as unknown.
+func (aac *Checker) known(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, cidr := range aac.KnownCIDRs {
+		if cidr.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString returns true if s is in list
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of a count map in sorted order
+func sortedKeys(counts map[string]int) []string {
+	var keys []string
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,127 @@
+package auditAnomalies
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockSource returns injected audit events
+type mockSource struct {
+	events []Event
+	since  []time.Time
+}
+
+// Events records the requested time and returns the injected events
+func (m *mockSource) Events(since time.Time) ([]Event, error) {
+	m.since = append(m.since, since)
+	return m.events, nil
+}
+
+// makeEvents returns count audit events decoded from a JSON template
+func makeEvents(t *testing.T, count int, event string) []Event {
+	var e Event
+	err := json.Unmarshal([]byte(event), &e)
+	if err != nil {
+		t.Fatal("Unable to decode event:", err)
+	}
+	var events []Event
+	for i := 0; i < count; i++ {
+		events = append(events, e)
+	}
+	return events
+}
+
+const (
+	secretRead   = `{"stage":"ResponseComplete","verb":"get","user":{"username":"system:serviceaccount:default:builder"},"sourceIPs":["10.0.0.5"],"objectRef":{"resource":"secrets","namespace":"default","name":"token"},"responseStatus":{"code":200}}`
+	userRead     = `{"stage":"ResponseComplete","verb":"list","user":{"username":"admin@example.com"},"sourceIPs":["10.0.0.6"],"objectRef":{"resource":"secrets"},"responseStatus":{"code":200}}`
+	authFailure  = `{"stage":"ResponseComplete","verb":"list","user":{},"sourceIPs":["10.0.0.7"],"responseStatus":{"code":401}}`
+	externalList = `{"stage":"ResponseComplete","verb":"list","user":{"username":"admin@example.com"},"sourceIPs":["203.0.113.9"],"objectRef":{"resource":"pods"},"responseStatus":{"code":200}}`
+)
+
+func TestDoChecks(t *testing.T) {
+	var tests = []struct {
+		description string
+		events      []Event
+		knownCIDRs  []string
+		expected    int
+	}{
+		{"no events", nil, nil, 0},
+		{"secret reads below threshold", makeEvents(t, 10, secretRead), nil, 0},
+		{"bulk secret reads", makeEvents(t, 11, secretRead), nil, 1},
+		{"bulk secret reads by a user", makeEvents(t, 50, userRead), nil, 0},
+		{"repeated authentication failures", makeEvents(t, 6, authFailure), nil, 1},
+		{"known source", makeEvents(t, 1, externalList), []string{"203.0.113.0/24"}, 0},
+		{"unknown source", append(makeEvents(t, 1, externalList), makeEvents(t, 1, secretRead)...), []string{"10.0.0.0/8"}, 1},
+		{"unknown sources not checked", makeEvents(t, 1, externalList), nil, 0},
+	}
+
+	for _, test := range tests {
+		source := &mockSource{events: test.events}
+		checker, err := New(source, 10, 5, test.knownCIDRs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = checker.doChecks()
+		if err != nil {
+			t.Fatal("Test", test.description, "returned an error:", err)
+		}
+		if len(checker.Errors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", checker.Errors)
+		}
+		t.Log(test.description, checker.Errors)
+
+		// the next run reads events since this run
+		err = checker.doChecks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !source.since[1].After(source.since[0]) {
+			t.Fatal("Test", test.description, "expected the second run to read events since the first run")
+		}
+	}
+
+	_, err := New(&mockSource{}, 10, 5, []string{"10.0.0.0"})
+	if err == nil {
+		t.Fatal("Expected an error for an invalid known CIDR")
+	}
+}
+
+func TestParseQueryResponse(t *testing.T) {
+	loki := `{"status":"success","data":{"resultType":"streams","result":[{"stream":{"job":"audit"},"values":[["1571126400000000000",` + jsonQuote(authFailure) + `],["1571126401000000000",` + jsonQuote(secretRead) + `]]}]}}`
+	elasticsearch := `{"took":3,"hits":{"total":2,"hits":[{"_index":"audit","_source":` + authFailure + `},{"_index":"audit","_source":` + secretRead + `}]}}`
+	lines := authFailure + "\n\n" + secretRead + "\n"
+
+	for description, response := range map[string]string{"loki": loki, "elasticsearch": elasticsearch, "json lines": lines} {
+		events, err := parseQueryResponse([]byte(response))
+		if err != nil {
+			t.Fatal("Test", description, "returned an error:", err)
+		}
+		if len(events) != 2 || events[0].ResponseStatus.Code != 401 || events[1].ObjectRef.Resource != "secrets" {
+			t.Fatal("Test", description, "decoded unexpected events:", events)
+		}
+	}
+
+	if _, err := parseQueryResponse([]byte("not json")); err == nil {
+		t.Fatal("Expected an error for an invalid response")
+	}
+}
+
+func TestFilterEvents(t *testing.T) {
+	now := time.Now()
+	events := []Event{
+		{Stage: "ResponseComplete", StageTimestamp: now.Add(-time.Minute)},
+		{Stage: "RequestReceived", StageTimestamp: now.Add(-time.Minute)},
+		{Stage: "ResponseComplete", StageTimestamp: now.Add(-time.Hour)},
+	}
+	if filtered := filterEvents(events, now.Add(-time.Minute*5)); len(filtered) != 1 {
+		t.Fatal("Expected 1 event but got", filtered)
+	}
+}
+
+// jsonQuote quotes a string as a JSON string
+func jsonQuote(s string) string {
+	b, _ := json.Marshal(strings.TrimSpace(s))
+	return string(b)
+}
@@ -0,0 +1,195 @@
+package auditAnomalies
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Event is the subset of a Kubernetes audit event used to find anomalies
+type Event struct {
+	Stage string `json:"stage"`
+	Verb  string `json:"verb"`
+	User  struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	SourceIPs []string `json:"sourceIPs"`
+	ObjectRef *struct {
+		Resource  string `json:"resource"`
+		Namespace string `json:"namespace"`
+		Name      string `json:"name"`
+	} `json:"objectRef"`
+	ResponseStatus *struct {
+		Code int `json:"code"`
+	} `json:"responseStatus"`
+	StageTimestamp time.Time `json:"stageTimestamp"`
+}
+
+// AuditSource provides audit events
+type AuditSource interface {
+	// Events returns the audit events that completed after since
+	Events(since time.Time) ([]Event, error)
+}
+
+// FileSource reads audit events from a JSON lines audit log file, such as one
+// mounted into the Kuberhealthy pod from the host
+type FileSource struct {
+	Path string
+}
+
+// NewFileSource returns an AuditSource that reads the audit log file at path
+func NewFileSource(path string) *FileSource {
+	return &FileSource{Path: path}
+}
+
+// Events reads the audit log file and returns the events that completed
+// after since
+func (fs *FileSource) Events(since time.Time) ([]Event, error) {
+	f, err := os.Open(fs.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	events, err := parseEventLines(f)
+	if err != nil {
+		return nil, errors.New("Error reading audit log " + fs.Path + ": " + err.Error())
+	}
+	return filterEvents(events, since), nil
+}
+
+// QuerySource fetches audit events from a log aggregation query URL.  Loki
+// query_range responses, Elasticsearch search responses, and JSON lines are
+// supported.
+type QuerySource struct {
+	URL    string
+	client *http.Client
+}
+
+// NewQuerySource returns an AuditSource that fetches audit events from url.
+// The query should return at least the events of the last check interval.
+func NewQuerySource(url string) *QuerySource {
+	return &QuerySource{
+		URL:    url,
+		client: &http.Client{Timeout: time.Second * 30},
+	}
+}
+
+// Events fetches the query URL and returns the events that completed after
+// since
+func (qs *QuerySource) Events(since time.Time) ([]Event, error) {
+	resp, err := qs.client.Get(qs.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("Audit log query returned status " + strconv.Itoa(resp.StatusCode))
+	}
+
+	events, err := parseQueryResponse(b)
+	if err != nil {
+		return nil, errors.New("Error parsing audit log query response: " + err.Error())
+	}
+	return filterEvents(events, since), nil
+}
+
+// lokiResponse is a Loki query_range response whose log lines are audit
+// events
+type lokiResponse struct {
+	Data *struct {
+		Result []struct {
+			Values [][]string `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// elasticsearchResponse is an Elasticsearch search response whose documents
+// are audit events
+type elasticsearchResponse struct {
+	Hits *struct {
+		Hits []struct {
+			Source Event `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// parseQueryResponse decodes the audit events in a Loki query_range response,
+// an Elasticsearch search response, or JSON lines
+func parseQueryResponse(b []byte) ([]Event, error) {
+	var loki lokiResponse
+	if json.Unmarshal(b, &loki) == nil && loki.Data != nil {
+		var events []Event
+		for _, stream := range loki.Data.Result {
+			for _, value := range stream.Values {
+				if len(value) < 2 {
+					continue
+				}
+				var e Event
+				err := json.Unmarshal([]byte(value[1]), &e)
+				if err != nil {
+					return nil, err
+				}
+				events = append(events, e)
+			}
+		}
+		return events, nil
+	}
+
+	var es elasticsearchResponse
+	if json.Unmarshal(b, &es) == nil && es.Hits != nil {
+		var events []Event
+		for _, hit := range es.Hits.Hits {
+			events = append(events, hit.Source)
+		}
+		return events, nil
+	}
+
+	return parseEventLines(bytes.NewReader(b))
+}
+
+// parseEventLines decodes one audit event per line
+func parseEventLines(r io.Reader) ([]Event, error) {
+	var events []Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e Event
+		err := json.Unmarshal(line, &e)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+// filterEvents returns the events that completed after since.  Events are
+// logged at several stages, so only the ResponseComplete stage is kept to
+// count every request once.
+func filterEvents(events []Event, since time.Time) []Event {
+	var filtered []Event
+	for _, e := range events {
+		if e.Stage != "ResponseComplete" || !e.StageTimestamp.After(since) {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+	return filtered
+}
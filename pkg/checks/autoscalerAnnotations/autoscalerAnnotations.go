@@ -0,0 +1,270 @@
+// Package autoscalerAnnotations implements a checker that ensures the
+// cluster autoscaler respects the annotations that protect pods and nodes
+// from scale down.  Pods annotated as unsafe to evict must not be evicted and
+// nodes with scale down disabled must not be removed.  Evictions are found in
+// pod events, and nodes are remembered between runs so that the removal of a
+// protected node can be detected.
+package autoscalerAnnotations // import "github.com/Comcast/kuberhealthy/pkg/checks/autoscalerAnnotations"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Cluster autoscaler annotations that protect pods and nodes from scale down
+const (
+	SafeToEvictAnnotation       = "cluster-autoscaler.kubernetes.io/safe-to-evict"
+	ScaleDownDisabledAnnotation = "cluster-autoscaler.kubernetes.io/scale-down-disabled"
+)
+
+// evictionReasons are the pod event reasons recorded when a pod is evicted
+// by the cluster autoscaler or the kubelet
+var evictionReasons = []string{"ScaleDown", "Evicted"}
+
+// recentWindow is how long evictions and node removals are reported for
+const recentWindow = time.Hour
+
+// Checker validates that autoscaler annotations are respected
+type Checker struct {
+	Errors []string
+	client *kubernetes.Clientset
+	// protectedNodes holds when each node with scale down disabled was
+	// last seen
+	protectedNodes map[string]time.Time
+	// removedNodes holds when each protected node was found to be removed
+	removedNodes map[string]time.Time
+	interval     time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker
+func New() *Checker {
+	return &Checker{
+		Errors:         []string{},
+		protectedNodes: make(map[string]time.Time),
+		removedNodes:   make(map[string]time.Time),
+	}
+}
+
+// Name returns the name of this checker
+func (aac *Checker) Name() string {
+	return "AutoscalerAnnotationsChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (aac *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (aac *Checker) Interval() time.Duration {
+	if aac.interval > 0 {
+		return aac.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (aac *Checker) SetInterval(d time.Duration) {
+	aac.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Nodes are cluster
+// scoped, and pods and their events are listed in every namespace.
+func (aac *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes", "pods", "events"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (aac *Checker) Timeout() time.Duration {
+	return time.Minute * 2
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (aac *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (aac *Checker) CurrentStatus() (bool, []string) {
+	if len(aac.Errors) > 0 {
+		return false, aac.Errors
+	}
+	return true, aac.Errors
+}
+
+// clearErrors clears all errors
+func (aac *Checker) clearErrors() {
+	aac.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (aac *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	aac.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := aac.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(aac.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + aac.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(aac.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + aac.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + aac.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists nodes, pods and pod events and sets an error for every
+// protected pod that was evicted and every protected node that was removed
+// recently
+func (aac *Checker) doChecks() error {
+
+	nodes, err := aac.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing nodes: " + err.Error())
+	}
+	pods, err := aac.client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing pods: " + err.Error())
+	}
+	events, err := aac.client.CoreV1().Events(metav1.NamespaceAll).List(metav1.ListOptions{
+		FieldSelector: "involvedObject.kind=Pod",
+	})
+	if err != nil {
+		return errors.New("Error listing events: " + err.Error())
+	}
+
+	now := time.Now()
+	annotationErrors := aac.evaluateNodes(nodes.Items, now)
+	annotationErrors = append(annotationErrors, evaluatePods(pods.Items, events.Items, now.Add(-recentWindow))...)
+
+	if len(annotationErrors) > 0 {
+		for _, e := range annotationErrors {
+			khlog.ForCheck(aac.Name()).Warningln(e)
+		}
+		aac.Errors = annotationErrors
+		return nil
+	}
+
+	aac.clearErrors()
+	return nil
+}
+
+// evaluateNodes records the nodes that have scale down disabled and returns
+// an error for every such node that was removed within the recent window.
+// Only nodes seen by an earlier run can be detected as removed.
+func (aac *Checker) evaluateNodes(nodes []apiv1.Node, now time.Time) []string {
+	current := make(map[string]bool)
+	for _, n := range nodes {
+		current[n.Name] = true
+		if n.Annotations[ScaleDownDisabledAnnotation] == "true" {
+			aac.protectedNodes[n.Name] = now
+			delete(aac.removedNodes, n.Name)
+		}
+	}
+
+	for name := range aac.protectedNodes {
+		if !current[name] {
+			aac.removedNodes[name] = now
+			delete(aac.protectedNodes, name)
+		}
+	}
+
+	var annotationErrors []string
+	var removed []string
+	for name, removedAt := range aac.removedNodes {
+		if now.Sub(removedAt) > recentWindow {
+			delete(aac.removedNodes, name)
+			continue
+		}
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		annotationErrors = append(annotationErrors, "Node "+name+" was removed at "+aac.removedNodes[name].Format(time.RFC3339)+" but has "+ScaleDownDisabledAnnotation+" set to true")
+	}
+	return annotationErrors
+}
+
+// evaluatePods returns an error for every pod annotated as not safe to evict
+// that has an eviction event seen after since, or that was evicted by the
+// kubelet.  Events are matched by pod name so that StatefulSet pods
+// recreated with the same name are still found.
+func evaluatePods(pods []apiv1.Pod, events []apiv1.Event, since time.Time) []string {
+	var annotationErrors []string
+
+	evictions := make(map[string]apiv1.Event)
+	for _, e := range events {
+		if e.InvolvedObject.Kind != "Pod" || !containsString(evictionReasons, e.Reason) {
+			continue
+		}
+		if lastSeen(e).Before(since) {
+			continue
+		}
+		evictions[e.InvolvedObject.Namespace+"/"+e.InvolvedObject.Name] = e
+	}
+
+	for _, p := range pods {
+		if p.Annotations[SafeToEvictAnnotation] != "false" {
+			continue
+		}
+
+		name := p.Namespace + "/" + p.Name
+		if e, ok := evictions[name]; ok {
+			annotationErrors = append(annotationErrors, "Pod "+name+" was evicted at "+lastSeen(e).Format(time.RFC3339)+" ("+e.Reason+": "+e.Message+") but has "+SafeToEvictAnnotation+" set to false")
+			continue
+		}
+		if p.Status.Reason == "Evicted" {
+			annotationErrors = append(annotationErrors, "Pod "+name+" was evicted ("+p.Status.Message+") but has "+SafeToEvictAnnotation+" set to false")
+		}
+	}
+	return annotationErrors
+}
+
+// lastSeen returns the last time an event occurred
+func lastSeen(e apiv1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	case !e.FirstTimestamp.IsZero():
+		return e.FirstTimestamp.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+// containsString determines if a string is in a slice
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
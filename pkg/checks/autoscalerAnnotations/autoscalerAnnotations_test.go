@@ -0,0 +1,104 @@
+package autoscalerAnnotations
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makeNode(name string, scaleDownDisabled bool) apiv1.Node {
+	node := apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{}}}
+	if scaleDownDisabled {
+		node.Annotations[ScaleDownDisabledAnnotation] = "true"
+	}
+	return node
+}
+
+func TestEvaluateNodes(t *testing.T) {
+	now := time.Now()
+	checker := New()
+
+	// the first run only records the protected node
+	nodeErrors := checker.evaluateNodes([]apiv1.Node{makeNode("node-a", true), makeNode("node-b", false)}, now)
+	if len(nodeErrors) != 0 {
+		t.Fatal("Expected no errors on the first run but got", nodeErrors)
+	}
+
+	// an unprotected node can be removed
+	nodeErrors = checker.evaluateNodes([]apiv1.Node{makeNode("node-a", true)}, now.Add(time.Minute*5))
+	if len(nodeErrors) != 0 {
+		t.Fatal("Expected no errors for removing an unprotected node but got", nodeErrors)
+	}
+
+	// the protected node is removed and reported for the recent window
+	nodeErrors = checker.evaluateNodes(nil, now.Add(time.Minute*10))
+	if len(nodeErrors) != 1 {
+		t.Fatal("Expected an error for the removed protected node but got", nodeErrors)
+	}
+	nodeErrors = checker.evaluateNodes(nil, now.Add(time.Minute*50))
+	if len(nodeErrors) != 1 {
+		t.Fatal("Expected the removed protected node to still be reported but got", nodeErrors)
+	}
+	nodeErrors = checker.evaluateNodes(nil, now.Add(time.Minute*75))
+	if len(nodeErrors) != 0 {
+		t.Fatal("Expected the removed protected node to expire but got", nodeErrors)
+	}
+
+	// a protected node that returns is no longer reported
+	checker.evaluateNodes([]apiv1.Node{makeNode("node-c", true)}, now)
+	checker.evaluateNodes(nil, now.Add(time.Minute))
+	nodeErrors = checker.evaluateNodes([]apiv1.Node{makeNode("node-c", true)}, now.Add(time.Minute*2))
+	if len(nodeErrors) != 0 {
+		t.Fatal("Expected no errors for a protected node that returned but got", nodeErrors)
+	}
+}
+
+func TestEvaluatePods(t *testing.T) {
+	now := time.Now()
+
+	makePod := func(name string, safeToEvict string) apiv1.Pod {
+		pod := apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Annotations: map[string]string{}}}
+		if len(safeToEvict) > 0 {
+			pod.Annotations[SafeToEvictAnnotation] = safeToEvict
+		}
+		return pod
+	}
+	makeEvent := func(podName string, reason string, age time.Duration) apiv1.Event {
+		return apiv1.Event{
+			InvolvedObject: apiv1.ObjectReference{Kind: "Pod", Namespace: "default", Name: podName},
+			Reason:         reason,
+			Message:        "deleting pod for node scale down",
+			LastTimestamp:  metav1.NewTime(now.Add(-age)),
+		}
+	}
+
+	kubeletEvicted := makePod("db-0", "false")
+	kubeletEvicted.Status.Reason = "Evicted"
+	kubeletEvicted.Status.Message = "The node was low on resource: memory."
+
+	var tests = []struct {
+		description string
+		pod         apiv1.Pod
+		event       apiv1.Event
+		expected    int
+	}{
+		{"protected pod scaled down", makePod("db-0", "false"), makeEvent("db-0", "ScaleDown", time.Minute*10), 1},
+		{"protected pod evicted", makePod("db-0", "false"), makeEvent("db-0", "Evicted", time.Minute*10), 1},
+		{"protected pod old eviction", makePod("db-0", "false"), makeEvent("db-0", "ScaleDown", time.Hour*2), 0},
+		{"protected pod other event", makePod("db-0", "false"), makeEvent("db-0", "Pulled", time.Minute*10), 0},
+		{"protected pod other pod evicted", makePod("db-0", "false"), makeEvent("db-1", "ScaleDown", time.Minute*10), 0},
+		{"unprotected pod scaled down", makePod("web-0", ""), makeEvent("web-0", "ScaleDown", time.Minute*10), 0},
+		{"safe to evict pod scaled down", makePod("web-0", "true"), makeEvent("web-0", "ScaleDown", time.Minute*10), 0},
+		{"protected pod evicted by kubelet", kubeletEvicted, makeEvent("db-0", "Pulled", time.Minute), 1},
+	}
+
+	for _, test := range tests {
+		podErrors := evaluatePods([]apiv1.Pod{test.pod}, []apiv1.Event{test.event}, now.Add(-recentWindow))
+		if len(podErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", podErrors)
+		}
+		t.Log(test.description, podErrors)
+	}
+}
@@ -0,0 +1,237 @@
+// Package capabilityDrop implements a checker that ensures containers drop
+// Linux capabilities they do not need.  Containers that keep the default
+// capabilities, or add back dangerous ones such as SYS_ADMIN, give an
+// attacker who compromises them far more power over the node.
+package capabilityDrop // import "github.com/Comcast/kuberhealthy/pkg/checks/capabilityDrop"
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// allCapabilities is the capability name that refers to every capability
+const allCapabilities = "ALL"
+
+// Checker validates the capabilities of containers
+type Checker struct {
+	Errors            []string
+	Namespaces        []string
+	ExcludeNamespaces []string
+	RequiredDrop      []string
+	ProhibitedAdd     []string
+	client            *kubernetes.Clientset
+	interval          time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied, skipping the excluded namespaces.  Containers must
+// drop every capability in requiredDrop and must not add any capability in
+// prohibitedAdd.
+func New(namespaces []string, excludeNamespaces []string, requiredDrop []string, prohibitedAdd []string) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		Errors:            []string{},
+		Namespaces:        namespaces,
+		ExcludeNamespaces: excludeNamespaces,
+		RequiredDrop:      normalizeCapabilities(requiredDrop),
+		ProhibitedAdd:     normalizeCapabilities(prohibitedAdd),
+	}
+}
+
+// Name returns the name of this checker
+func (cdc *Checker) Name() string {
+	return "CapabilityDropChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (cdc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (cdc *Checker) Interval() time.Duration {
+	if cdc.interval > 0 {
+		return cdc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (cdc *Checker) SetInterval(d time.Duration) {
+	cdc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to read pod specs in
+// every checked namespace.  The default of all namespaces makes them cluster
+// scoped.
+func (cdc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range cdc.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		}
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (cdc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (cdc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (cdc *Checker) CurrentStatus() (bool, []string) {
+	if len(cdc.Errors) > 0 {
+		return false, cdc.Errors
+	}
+	return true, cdc.Errors
+}
+
+// clearErrors clears all errors
+func (cdc *Checker) clearErrors() {
+	cdc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (cdc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	cdc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := cdc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(cdc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + cdc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(cdc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + cdc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + cdc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists pods in each namespace and sets an error for every
+// container that keeps a required capability or adds a prohibited one
+func (cdc *Checker) doChecks() error {
+
+	var capabilityErrors []string
+	for _, ns := range cdc.Namespaces {
+		pods, err := cdc.client.CoreV1().Pods(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		capabilityErrors = append(capabilityErrors, cdc.evaluatePods(pods.Items)...)
+	}
+
+	if len(capabilityErrors) > 0 {
+		for _, e := range capabilityErrors {
+			khlog.ForCheck(cdc.Name()).Warningln(e)
+		}
+		cdc.Errors = capabilityErrors
+		return nil
+	}
+
+	cdc.clearErrors()
+	return nil
+}
+
+// evaluatePods returns an error for every container that does not drop a
+// required capability and every container that adds a prohibited capability.
+// Pods in excluded namespaces are skipped.
+func (cdc *Checker) evaluatePods(pods []apiv1.Pod) []string {
+	var capabilityErrors []string
+
+	for _, p := range pods {
+		if containsString(cdc.ExcludeNamespaces, p.Namespace) {
+			continue
+		}
+
+		containers := append(append([]apiv1.Container{}, p.Spec.InitContainers...), p.Spec.Containers...)
+		for _, c := range containers {
+			var drop, add []string
+			if c.SecurityContext != nil && c.SecurityContext.Capabilities != nil {
+				drop = toStrings(c.SecurityContext.Capabilities.Drop)
+				add = toStrings(c.SecurityContext.Capabilities.Add)
+			}
+
+			description := "Pod " + p.Namespace + "/" + p.Name + " container " + c.Name
+			var missing []string
+			for _, required := range cdc.RequiredDrop {
+				// dropping ALL also drops every individual capability
+				if !containsString(drop, required) && !containsString(drop, allCapabilities) {
+					missing = append(missing, required)
+				}
+			}
+			if len(missing) > 0 {
+				capabilityErrors = append(capabilityErrors, description+" does not drop capabilities "+strings.Join(missing, ", "))
+			}
+
+			var prohibited []string
+			for _, a := range add {
+				if containsString(cdc.ProhibitedAdd, a) || (a == allCapabilities && len(cdc.ProhibitedAdd) > 0) {
+					prohibited = append(prohibited, a)
+				}
+			}
+			if len(prohibited) > 0 {
+				capabilityErrors = append(capabilityErrors, description+" adds prohibited capabilities "+strings.Join(prohibited, ", "))
+			}
+		}
+	}
+	return capabilityErrors
+}
+
+// toStrings converts capabilities to normalized names
+func toStrings(capabilities []apiv1.Capability) []string {
+	var names []string
+	for _, c := range capabilities {
+		names = append(names, string(c))
+	}
+	return normalizeCapabilities(names)
+}
+
+// normalizeCapabilities upper cases capability names and removes the CAP_
+// prefix, which container runtimes accept but Kubernetes does not require
+func normalizeCapabilities(capabilities []string) []string {
+	var normalized []string
+	for _, c := range capabilities {
+		normalized = append(normalized, strings.TrimPrefix(strings.ToUpper(c), "CAP_"))
+	}
+	return normalized
+}
+
+// containsString determines if a string is in a slice
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package capabilityDrop
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makePod(ns string, capabilities *apiv1.Capabilities) apiv1.Pod {
+	container := apiv1.Container{Name: "app"}
+	if capabilities != nil {
+		container.SecurityContext = &apiv1.SecurityContext{Capabilities: capabilities}
+	}
+	return apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "web"},
+		Spec:       apiv1.PodSpec{Containers: []apiv1.Container{container}},
+	}
+}
+
+func capabilities(drop []apiv1.Capability, add []apiv1.Capability) *apiv1.Capabilities {
+	return &apiv1.Capabilities{Drop: drop, Add: add}
+}
+
+func TestEvaluatePods(t *testing.T) {
+	withInit := makePod("default", capabilities([]apiv1.Capability{"ALL"}, nil))
+	withInit.Spec.InitContainers = []apiv1.Container{{Name: "init"}}
+
+	var tests = []struct {
+		description string
+		pod         apiv1.Pod
+		expected    int
+	}{
+		{"drops all", makePod("default", capabilities([]apiv1.Capability{"ALL"}, nil)), 0},
+		{"no security context", makePod("default", nil), 1},
+		{"drops some", makePod("default", capabilities([]apiv1.Capability{"NET_RAW"}, nil)), 1},
+		{"drops all and adds allowed", makePod("default", capabilities([]apiv1.Capability{"ALL"}, []apiv1.Capability{"NET_BIND_SERVICE"})), 0},
+		{"drops all and adds prohibited", makePod("default", capabilities([]apiv1.Capability{"ALL"}, []apiv1.Capability{"SYS_ADMIN"})), 1},
+		{"prefixed prohibited", makePod("default", capabilities([]apiv1.Capability{"ALL"}, []apiv1.Capability{"CAP_SYS_PTRACE"})), 1},
+		{"adds all", makePod("default", capabilities([]apiv1.Capability{"ALL"}, []apiv1.Capability{"ALL"})), 1},
+		{"keeps all and adds prohibited", makePod("default", capabilities(nil, []apiv1.Capability{"NET_ADMIN"})), 2},
+		{"init container without drop", withInit, 1},
+		{"excluded namespace", makePod("kube-system", nil), 0},
+	}
+
+	for _, test := range tests {
+		checker := New(nil, []string{"kube-system"}, []string{"ALL"}, []string{"NET_ADMIN", "SYS_ADMIN", "SYS_PTRACE"})
+		capabilityErrors := checker.evaluatePods([]apiv1.Pod{test.pod})
+		if len(capabilityErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", capabilityErrors)
+		}
+		t.Log(test.description, capabilityErrors)
+	}
+
+	// individual required capabilities are satisfied by dropping them or ALL
+	checker := New(nil, nil, []string{"net_raw", "SYS_CHROOT"}, nil)
+	capabilityErrors := checker.evaluatePods([]apiv1.Pod{
+		makePod("default", capabilities([]apiv1.Capability{"NET_RAW", "SYS_CHROOT"}, nil)),
+		makePod("default", capabilities([]apiv1.Capability{"ALL"}, nil)),
+		makePod("default", capabilities([]apiv1.Capability{"NET_RAW"}, nil)),
+	})
+	if len(capabilityErrors) != 1 {
+		t.Fatal("Expected one error for the container keeping SYS_CHROOT but got", capabilityErrors)
+	}
+}
@@ -0,0 +1,338 @@
+// Package cgroupDriver implements a checker that ensures the kubelet and the
+// container runtime of every node use the same cgroup driver.  When one uses
+// systemd and the other cgroupfs, pods are placed in cgroup hierarchies that
+// the kubelet does not manage, which leads to resource accounting problems
+// and unstable nodes under pressure.  The kubelet driver is read through the
+// API server node proxy and the runtime driver is read by a DaemonSet pod on
+// each node.
+package cgroupDriver // import "github.com/Comcast/kuberhealthy/pkg/checks/cgroupDriver"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// the cgroup drivers supported by the kubelet
+const (
+	cgroupfs = "cgroupfs"
+	systemd  = "systemd"
+)
+
+// runtimeScript prints the runtime configuration of each supported container
+// runtime in a section of its own.  Runtimes that are not installed print an
+// empty section.
+const runtimeScript = `echo "=== docker"
+if [ -S /host/var/run/docker.sock ]; then docker -H unix:///host/var/run/docker.sock info --format '{{.CgroupDriver}}'; fi
+echo "=== containerd"
+grep -h SystemdCgroup /host/etc/containerd/config.toml 2>/dev/null
+echo "=== cri-o"
+grep -h cgroup_manager /host/etc/crio/crio.conf /host/etc/crio/crio.conf.d/* 2>/dev/null
+true`
+
+// Checker validates that the kubelet and container runtime cgroup drivers
+// match on all nodes
+type Checker struct {
+	Errors []string
+	Image  string
+	client *kubernetes.Clientset
+	// runOnNodes is replaced in tests to inject runtime information
+	runOnNodes func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (map[string]string, error)
+	// kubeletDriver is replaced in tests to inject kubelet configuration
+	kubeletDriver func(client *kubernetes.Clientset, nodeName string) (string, error)
+	interval      time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker
+func New() *Checker {
+	return &Checker{
+		Errors:        []string{},
+		Image:         "docker:18.09",
+		runOnNodes:    podRunner.RunOnNodes,
+		kubeletDriver: fetchKubeletDriver,
+	}
+}
+
+// Name returns the name of this checker
+func (cdc *Checker) Name() string {
+	return "CgroupDriverChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (cdc *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (cdc *Checker) Interval() time.Duration {
+	if cdc.interval > 0 {
+		return cdc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (cdc *Checker) SetInterval(d time.Duration) {
+	cdc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  The kubelet
+// configuration is read through the node proxy, which is cluster scoped like
+// nodes themselves.
+func (cdc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		namespace: podRunner.RunOnNodesRBACRules(),
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}},
+			{APIGroups: []string{""}, Resources: []string{"nodes/proxy"}, Verbs: []string{"get"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (cdc *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (cdc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (cdc *Checker) CurrentStatus() (bool, []string) {
+	if len(cdc.Errors) > 0 {
+		return false, cdc.Errors
+	}
+	return true, cdc.Errors
+}
+
+// clearErrors clears all errors
+func (cdc *Checker) clearErrors() {
+	cdc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (cdc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	cdc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := cdc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(cdc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + cdc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(cdc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + cdc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + cdc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks reads the runtime configuration on every node and sets an error
+// for every node whose runtime cgroup driver differs from its kubelet's
+func (cdc *Checker) doChecks() error {
+
+	nodes, err := cdc.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing nodes: " + err.Error())
+	}
+	runtimes := make(map[string]string)
+	for _, n := range nodes.Items {
+		runtimes[n.Name] = runtimeName(n.Status.NodeInfo.ContainerRuntimeVersion)
+	}
+
+	script := podRunner.Script{
+		Name:   "cgroup-driver",
+		Image:  cdc.Image,
+		Script: runtimeScript,
+		HostPaths: map[string]string{
+			"/var/run": "/host/var/run",
+			"/etc":     "/host/etc",
+		},
+	}
+
+	// leave time to clean up the DaemonSet before the check times out
+	output, err := cdc.runOnNodes(cdc.client, namespace, script, cdc.Timeout()-time.Minute)
+	if err != nil && len(output) == 0 {
+		return err
+	}
+
+	var driverErrors []string
+	if err != nil {
+		driverErrors = append(driverErrors, err.Error())
+	}
+	driverErrors = append(driverErrors, cdc.evaluateNodes(output, runtimes)...)
+
+	if len(driverErrors) > 0 {
+		for _, e := range driverErrors {
+			khlog.ForCheck(cdc.Name()).Warningln(e)
+		}
+		cdc.Errors = driverErrors
+		return nil
+	}
+
+	cdc.clearErrors()
+	return nil
+}
+
+// evaluateNodes returns an error for every node whose runtime cgroup driver
+// differs from its kubelet's or could not be determined.  Runtimes are keyed
+// by node name.
+func (cdc *Checker) evaluateNodes(output map[string]string, runtimes map[string]string) []string {
+	var driverErrors []string
+
+	var nodes []string
+	for node := range output {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	for _, node := range nodes {
+		runtime := runtimes[node]
+		runtimeDriver, err := parseRuntimeDriver(output[node], runtime)
+		if err != nil {
+			driverErrors = append(driverErrors, "Error reading container runtime cgroup driver of node "+node+": "+err.Error())
+			continue
+		}
+
+		kubeletDriver, err := cdc.kubeletDriver(cdc.client, node)
+		if err != nil {
+			driverErrors = append(driverErrors, "Error reading kubelet configuration of node "+node+": "+err.Error())
+			continue
+		}
+
+		if kubeletDriver != runtimeDriver {
+			driverErrors = append(driverErrors, "Node "+node+" kubelet uses cgroup driver "+kubeletDriver+" but container runtime "+runtime+" uses "+runtimeDriver)
+		}
+	}
+	return driverErrors
+}
+
+// runtimeName returns the name of a container runtime from the runtime
+// version reported by a node, such as docker://18.9.1
+func runtimeName(version string) string {
+	if i := strings.Index(version, "://"); i >= 0 {
+		return version[:i]
+	}
+	return version
+}
+
+// parseRuntimeDriver returns the cgroup driver of a container runtime from
+// the output of runtimeScript.  containerd defaults to cgroupfs and cri-o
+// defaults to systemd when their configuration does not set a driver.
+func parseRuntimeDriver(output string, runtime string) (string, error) {
+	sections := make(map[string][]string)
+	var section string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "=== ") {
+			section = strings.TrimPrefix(line, "=== ")
+			sections[section] = []string{}
+			continue
+		}
+		if len(line) == 0 || strings.HasPrefix(line, "#") || len(section) == 0 {
+			continue
+		}
+		sections[section] = append(sections[section], line)
+	}
+
+	if len(sections) == 0 {
+		return "", errors.New("no runtime information was reported")
+	}
+	lines, ok := sections[runtime]
+	if !ok {
+		return "", errors.New("unsupported container runtime " + runtime)
+	}
+
+	switch runtime {
+	case "docker":
+		if len(lines) == 0 {
+			return "", errors.New("docker info was not available")
+		}
+		return lines[0], nil
+	case "containerd":
+		driver := cgroupfs
+		for _, l := range lines {
+			if configValue(l, "SystemdCgroup") == "true" {
+				driver = systemd
+			}
+		}
+		return driver, nil
+	case "cri-o":
+		driver := systemd
+		for _, l := range lines {
+			if v := configValue(l, "cgroup_manager"); len(v) > 0 {
+				driver = v
+			}
+		}
+		return driver, nil
+	}
+	return "", errors.New("unsupported container runtime " + runtime)
+}
+
+// configValue returns the unquoted value of a TOML key = value line, or an
+// empty string if the line sets a different key
+func configValue(line string, key string) string {
+	parts := strings.SplitN(line, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) != key {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+}
+
+// fetchKubeletDriver reads the cgroupDriver setting of a node's kubelet
+// through the API server node proxy.  The kubelet defaults to cgroupfs when
+// unset.
+func fetchKubeletDriver(client *kubernetes.Clientset, nodeName string) (string, error) {
+	b, err := client.CoreV1().RESTClient().Get().AbsPath("/api/v1/nodes", nodeName, "proxy", "configz").DoRaw()
+	if err != nil {
+		return "", err
+	}
+	return parseKubeletDriver(b)
+}
+
+// parseKubeletDriver returns the cgroup driver of a configz response
+func parseKubeletDriver(b []byte) (string, error) {
+	var configz struct {
+		KubeletConfig *struct {
+			CgroupDriver string `json:"cgroupDriver"`
+		} `json:"kubeletconfig"`
+	}
+	err := json.Unmarshal(b, &configz)
+	if err != nil {
+		return "", errors.New("Error decoding kubelet configuration: " + err.Error())
+	}
+	if configz.KubeletConfig == nil {
+		return "", errors.New("configz response did not contain a kubeletconfig")
+	}
+	if len(configz.KubeletConfig.CgroupDriver) == 0 {
+		return cgroupfs, nil
+	}
+	return configz.KubeletConfig.CgroupDriver, nil
+}
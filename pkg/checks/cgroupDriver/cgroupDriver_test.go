@@ -0,0 +1,102 @@
+package cgroupDriver
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+const dockerSystemd = "=== docker\nsystemd\n=== containerd\n=== cri-o\n"
+
+const containerdDefault = "=== docker\n=== containerd\n=== cri-o\n"
+
+const containerdSystemd = "=== docker\n=== containerd\n            SystemdCgroup = true\n=== cri-o\n"
+
+const crioCgroupfs = "=== docker\n=== containerd\n=== cri-o\n# cgroup_manager = \"systemd\"\ncgroup_manager = \"cgroupfs\"\n"
+
+func TestParseRuntimeDriver(t *testing.T) {
+	var tests = []struct {
+		description string
+		output      string
+		runtime     string
+		expected    string
+		expectErr   bool
+	}{
+		{"docker", dockerSystemd, "docker", "systemd", false},
+		{"docker unavailable", containerdDefault, "docker", "", true},
+		{"containerd default", containerdDefault, "containerd", "cgroupfs", false},
+		{"containerd systemd", containerdSystemd, "containerd", "systemd", false},
+		{"cri-o default", containerdDefault, "cri-o", "systemd", false},
+		{"cri-o cgroupfs", crioCgroupfs, "cri-o", "cgroupfs", false},
+		{"unknown runtime", dockerSystemd, "rkt", "", true},
+		{"no output", "", "containerd", "", true},
+	}
+
+	for _, test := range tests {
+		driver, err := parseRuntimeDriver(test.output, test.runtime)
+		if (err != nil) != test.expectErr {
+			t.Fatal("Test", test.description, "expected error", test.expectErr, "but got", err)
+		}
+		if driver != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "but got", driver)
+		}
+	}
+}
+
+func TestParseKubeletDriver(t *testing.T) {
+	var tests = []struct {
+		description string
+		configz     string
+		expected    string
+		expectErr   bool
+	}{
+		{"systemd", `{"kubeletconfig":{"cgroupDriver":"systemd"}}`, "systemd", false},
+		{"unset", `{"kubeletconfig":{}}`, "cgroupfs", false},
+		{"no kubeletconfig", `{}`, "", true},
+		{"invalid", `not json`, "", true},
+	}
+
+	for _, test := range tests {
+		driver, err := parseKubeletDriver([]byte(test.configz))
+		if (err != nil) != test.expectErr {
+			t.Fatal("Test", test.description, "expected error", test.expectErr, "but got", err)
+		}
+		if driver != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "but got", driver)
+		}
+	}
+}
+
+func TestEvaluateNodes(t *testing.T) {
+	var tests = []struct {
+		description   string
+		output        string
+		runtime       string
+		kubeletDriver string
+		kubeletErr    error
+		expected      int
+	}{
+		{"docker match", dockerSystemd, "docker", "systemd", nil, 0},
+		{"docker mismatch", dockerSystemd, "docker", "cgroupfs", nil, 1},
+		{"containerd match", containerdDefault, "containerd", "cgroupfs", nil, 0},
+		{"containerd mismatch", containerdSystemd, "containerd", "cgroupfs", nil, 1},
+		{"cri-o mismatch", crioCgroupfs, "cri-o", "systemd", nil, 1},
+		{"unreadable runtime", containerdDefault, "docker", "systemd", nil, 1},
+		{"unreadable kubelet", dockerSystemd, "docker", "", errors.New("forbidden"), 1},
+	}
+
+	for _, test := range tests {
+		checker := New()
+		kubeletDriver := test.kubeletDriver
+		kubeletErr := test.kubeletErr
+		checker.kubeletDriver = func(client *kubernetes.Clientset, nodeName string) (string, error) {
+			return kubeletDriver, kubeletErr
+		}
+		driverErrors := checker.evaluateNodes(map[string]string{"node-a": test.output}, map[string]string{"node-a": test.runtime})
+		if len(driverErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", driverErrors)
+		}
+		t.Log(test.description, driverErrors)
+	}
+}
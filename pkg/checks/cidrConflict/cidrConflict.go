@@ -0,0 +1,304 @@
+// Package cidrConflict implements a checker that ensures the pod and service
+// CIDRs of the cluster do not overlap with corporate network ranges.  An
+// overlap causes traffic from pods to corporate services to be routed inside
+// the cluster instead.
+package cidrConflict // import "github.com/Comcast/kuberhealthy/pkg/checks/cidrConflict"
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// cidrFlags are the controller manager flags that hold the cluster CIDRs
+var cidrFlags = []struct {
+	flag        string
+	description string
+}{
+	{"--cluster-cidr", "pod"},
+	{"--service-cluster-ip-range", "service"},
+}
+
+// kubeadmConfigMap is read for the cluster CIDRs when the controller manager
+// pods can not be inspected
+const kubeadmConfigMap = "kubeadm-config"
+
+// clusterCIDR is a pod or service network of the cluster
+type clusterCIDR struct {
+	Description string
+	Network     *net.IPNet
+}
+
+// kubeadmClusterConfiguration holds the networking section of the kubeadm
+// ClusterConfiguration
+type kubeadmClusterConfiguration struct {
+	Networking struct {
+		PodSubnet     string `json:"podSubnet"`
+		ServiceSubnet string `json:"serviceSubnet"`
+	} `json:"networking"`
+}
+
+// Checker validates that cluster CIDRs do not overlap corporate CIDRs
+type Checker struct {
+	Errors         []string
+	CorporateCIDRs []*net.IPNet
+	client         *kubernetes.Clientset
+	interval       time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that compares cluster CIDRs against the supplied
+// corporate CIDRs.  An error is returned if a CIDR can not be parsed.
+func New(corporateCIDRs []string) (*Checker, error) {
+	var networks []*net.IPNet
+	for _, c := range corporateCIDRs {
+		_, network, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, errors.New("unable to parse corporate CIDR " + c + ": " + err.Error())
+		}
+		networks = append(networks, network)
+	}
+	return &Checker{
+		Errors:         []string{},
+		CorporateCIDRs: networks,
+	}, nil
+}
+
+// Name returns the name of this checker
+func (ccc *Checker) Name() string {
+	return "CIDRConflictChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (ccc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (ccc *Checker) Interval() time.Duration {
+	if ccc.interval > 0 {
+		return ccc.interval
+	}
+	return time.Minute * 30
+}
+
+// SetInterval overrides the interval at which this check runs
+func (ccc *Checker) SetInterval(d time.Duration) {
+	ccc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to read the cluster
+// CIDRs from kube-system
+func (ccc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		metav1.NamespaceSystem: {
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+			{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (ccc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (ccc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (ccc *Checker) CurrentStatus() (bool, []string) {
+	if len(ccc.Errors) > 0 {
+		return false, ccc.Errors
+	}
+	return true, ccc.Errors
+}
+
+// clearErrors clears all errors
+func (ccc *Checker) clearErrors() {
+	ccc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (ccc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	ccc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := ccc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(ccc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + ccc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(ccc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + ccc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + ccc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks finds the cluster CIDRs and sets an error for every corporate CIDR
+// that they overlap
+func (ccc *Checker) doChecks() error {
+
+	cidrs, err := ccc.findClusterCIDRs()
+	if err != nil {
+		return err
+	}
+
+	conflicts := findConflicts(cidrs, ccc.CorporateCIDRs)
+	if len(conflicts) > 0 {
+		for _, c := range conflicts {
+			khlog.ForCheck(ccc.Name()).Warningln(c)
+		}
+		ccc.Errors = conflicts
+		return nil
+	}
+
+	ccc.clearErrors()
+	return nil
+}
+
+// findClusterCIDRs reads the cluster CIDRs from the flags of the
+// kube-controller-manager static pods, falling back to the kubeadm
+// ClusterConfiguration
+func (ccc *Checker) findClusterCIDRs() ([]clusterCIDR, error) {
+	pods, err := ccc.client.CoreV1().Pods(metav1.NamespaceSystem).List(metav1.ListOptions{
+		LabelSelector: "component=kube-controller-manager",
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pods.Items) > 0 {
+		cidrs, err := cidrsFromPod(pods.Items[0])
+		if err != nil {
+			return nil, err
+		}
+		if len(cidrs) > 0 {
+			return cidrs, nil
+		}
+	}
+
+	cm, err := ccc.client.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(kubeadmConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.New("Unable to find the cluster CIDRs in kube-controller-manager pods or the " + kubeadmConfigMap + " ConfigMap: " + err.Error())
+	}
+	cidrs, err := cidrsFromKubeadmConfig(cm.Data["ClusterConfiguration"])
+	if err != nil {
+		return nil, err
+	}
+	if len(cidrs) == 0 {
+		return nil, errors.New("Unable to find the cluster CIDRs in kube-controller-manager pods or the " + kubeadmConfigMap + " ConfigMap")
+	}
+	return cidrs, nil
+}
+
+// cidrsFromPod returns the pod and service CIDRs set in the flags of a
+// kube-controller-manager pod
+func cidrsFromPod(pod apiv1.Pod) ([]clusterCIDR, error) {
+	var cidrs []clusterCIDR
+	for _, c := range pod.Spec.Containers {
+		args := append(append([]string{}, c.Command...), c.Args...)
+		for i, a := range args {
+			for _, f := range cidrFlags {
+				var value string
+				if strings.HasPrefix(a, f.flag+"=") {
+					value = strings.TrimPrefix(a, f.flag+"=")
+				} else if a == f.flag && i+1 < len(args) {
+					value = args[i+1]
+				} else {
+					continue
+				}
+				parsed, err := parseCIDRs(f.description, value)
+				if err != nil {
+					return nil, err
+				}
+				cidrs = append(cidrs, parsed...)
+			}
+		}
+	}
+	return cidrs, nil
+}
+
+// cidrsFromKubeadmConfig returns the pod and service CIDRs set in a kubeadm
+// ClusterConfiguration
+func cidrsFromKubeadmConfig(clusterConfiguration string) ([]clusterCIDR, error) {
+	var config kubeadmClusterConfiguration
+	err := yaml.Unmarshal([]byte(clusterConfiguration), &config)
+	if err != nil {
+		return nil, errors.New("Error parsing kubeadm ClusterConfiguration: " + err.Error())
+	}
+
+	var cidrs []clusterCIDR
+	podCIDRs, err := parseCIDRs("pod", config.Networking.PodSubnet)
+	if err != nil {
+		return nil, err
+	}
+	cidrs = append(cidrs, podCIDRs...)
+	serviceCIDRs, err := parseCIDRs("service", config.Networking.ServiceSubnet)
+	if err != nil {
+		return nil, err
+	}
+	return append(cidrs, serviceCIDRs...), nil
+}
+
+// parseCIDRs parses a comma separated list of CIDRs, as used by dual stack
+// clusters
+func parseCIDRs(description string, value string) ([]clusterCIDR, error) {
+	var cidrs []clusterCIDR
+	for _, c := range strings.Split(value, ",") {
+		c = strings.TrimSpace(c)
+		if len(c) == 0 {
+			continue
+		}
+		_, network, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, errors.New("Unable to parse " + description + " CIDR " + c + ": " + err.Error())
+		}
+		cidrs = append(cidrs, clusterCIDR{Description: description, Network: network})
+	}
+	return cidrs, nil
+}
+
+// findConflicts returns an error for every pair of cluster and corporate
+// CIDRs that overlap
+func findConflicts(cidrs []clusterCIDR, corporateCIDRs []*net.IPNet) []string {
+	var conflicts []string
+	for _, c := range cidrs {
+		for _, corporate := range corporateCIDRs {
+			if overlaps(c.Network, corporate) {
+				conflicts = append(conflicts, "Cluster "+c.Description+" CIDR "+c.Network.String()+" overlaps corporate CIDR "+corporate.String())
+			}
+		}
+	}
+	return conflicts
+}
+
+// overlaps determines if two networks share any addresses.  Since networks
+// are aligned to their prefix, they overlap only if one contains the other's
+// network address.
+func overlaps(a *net.IPNet, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
@@ -0,0 +1,93 @@
+package cidrConflict
+
+import (
+	"net"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func mustParseCIDR(t *testing.T, cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return network
+}
+
+func TestOverlaps(t *testing.T) {
+	var tests = []struct {
+		a        string
+		b        string
+		expected bool
+	}{
+		{"10.0.0.0/8", "10.32.0.0/16", true},
+		{"10.32.0.0/16", "10.0.0.0/8", true},
+		{"10.244.0.0/16", "10.244.0.0/16", true},
+		{"10.244.0.0/16", "10.245.0.0/16", false},
+		{"192.168.0.0/24", "192.168.1.0/24", false},
+		{"172.16.0.0/12", "172.31.255.0/24", true},
+		{"172.16.0.0/12", "172.32.0.0/16", false},
+		{"fd00::/8", "fd00:10::/32", true},
+		{"fd00::/8", "10.0.0.0/8", false},
+	}
+
+	for _, test := range tests {
+		result := overlaps(mustParseCIDR(t, test.a), mustParseCIDR(t, test.b))
+		if result != test.expected {
+			t.Fatal("Test", test.a, test.b, "expected", test.expected, "but got", result)
+		}
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	checker, err := New([]string{"10.0.0.0/8", "192.168.0.0/16"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cidrs := []clusterCIDR{
+		{Description: "pod", Network: mustParseCIDR(t, "10.244.0.0/16")},
+		{Description: "service", Network: mustParseCIDR(t, "172.20.0.0/16")},
+	}
+	conflicts := findConflicts(cidrs, checker.CorporateCIDRs)
+	if len(conflicts) != 1 {
+		t.Fatal("Expected the pod CIDR to conflict but got", conflicts)
+	}
+	t.Log(conflicts)
+
+	_, err = New([]string{"10.0.0.0"})
+	if err == nil {
+		t.Fatal("Expected an error for a corporate CIDR without a prefix length")
+	}
+}
+
+func TestCIDRsFromPod(t *testing.T) {
+	pod := apiv1.Pod{Spec: apiv1.PodSpec{Containers: []apiv1.Container{{
+		Command: []string{"kube-controller-manager", "--cluster-cidr=10.244.0.0/16,fd00:10:244::/56", "--service-cluster-ip-range", "10.96.0.0/12"},
+	}}}}
+	cidrs, err := cidrsFromPod(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cidrs) != 3 || cidrs[0].Description != "pod" || cidrs[2].Network.String() != "10.96.0.0/12" {
+		t.Fatal("Unexpected CIDRs from pod:", cidrs)
+	}
+}
+
+func TestCIDRsFromKubeadmConfig(t *testing.T) {
+	config := `apiVersion: kubeadm.k8s.io/v1beta1
+kind: ClusterConfiguration
+networking:
+  dnsDomain: cluster.local
+  podSubnet: 10.244.0.0/16
+  serviceSubnet: 10.96.0.0/12
+`
+	cidrs, err := cidrsFromKubeadmConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cidrs) != 2 || cidrs[0].Network.String() != "10.244.0.0/16" || cidrs[1].Description != "service" {
+		t.Fatal("Unexpected CIDRs from kubeadm config:", cidrs)
+	}
+}
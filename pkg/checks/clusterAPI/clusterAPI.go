@@ -0,0 +1,256 @@
+// Package clusterAPI implements a checker for management clusters running
+// Cluster API.  Workload clusters are checked for a provisioned phase and a
+// healthy Ready condition, and the Cluster API provider controllers are
+// checked for readiness.  Clusters without the Cluster API installed are
+// skipped.
+package clusterAPI // import "github.com/Comcast/kuberhealthy/pkg/checks/clusterAPI"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	appsv1 "k8s.io/api/apps/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// clusterAPIGroup is the API group of the Cluster API
+const clusterAPIGroup = "cluster.x-k8s.io"
+
+// providerLabel is the label set on all Cluster API provider components
+const providerLabel = "cluster.x-k8s.io/provider"
+
+// provisionedPhase is the phase of a fully provisioned cluster
+const provisionedPhase = "Provisioned"
+
+// clusterAPIVersions are the Cluster API versions searched for, in order of
+// preference
+var clusterAPIVersions = []string{"v1beta1", "v1alpha4", "v1alpha3", "v1alpha2"}
+
+// clusterList is a list of Cluster API clusters
+type clusterList struct {
+	Items []cluster `json:"items"`
+}
+
+// cluster is a Cluster API cluster with only the fields we inspect
+type cluster struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Status            clusterStatus `json:"status,omitempty"`
+}
+
+// clusterStatus is the status of a Cluster API cluster
+type clusterStatus struct {
+	Phase      string             `json:"phase,omitempty"`
+	Conditions []clusterCondition `json:"conditions,omitempty"`
+}
+
+// clusterCondition is a single Cluster API cluster condition
+type clusterCondition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Checker validates Cluster API clusters and providers
+type Checker struct {
+	Errors   []string
+	client   *kubernetes.Clientset
+	interval time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker
+func New() *Checker {
+	return &Checker{
+		Errors: []string{},
+	}
+}
+
+// Name returns the name of this checker
+func (cac *Checker) Name() string {
+	return "ClusterAPIChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (cac *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (cac *Checker) Interval() time.Duration {
+	if cac.interval > 0 {
+		return cac.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (cac *Checker) SetInterval(d time.Duration) {
+	cac.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Clusters and provider
+// deployments are listed in every namespace.
+func (cac *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{clusterAPIGroup}, Resources: []string{"clusters"}, Verbs: []string{"list"}},
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (cac *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (cac *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (cac *Checker) CurrentStatus() (bool, []string) {
+	if len(cac.Errors) > 0 {
+		return false, cac.Errors
+	}
+	return true, cac.Errors
+}
+
+// clearErrors clears all errors
+func (cac *Checker) clearErrors() {
+	cac.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (cac *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	cac.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := cac.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(cac.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + cac.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(cac.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + cac.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + cac.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks validates all Cluster API clusters and provider deployments
+func (cac *Checker) doChecks() error {
+
+	version, found := cac.findClusterAPIVersion()
+	if !found {
+		khlog.ForCheck(cac.Name()).Debugln("Cluster API is not installed. Skipping check.")
+		cac.clearErrors()
+		return nil
+	}
+
+	b, err := cac.client.CoreV1().RESTClient().Get().AbsPath("/apis", clusterAPIGroup, version, "clusters").DoRaw()
+	if err != nil {
+		return err
+	}
+	var clusters clusterList
+	err = json.Unmarshal(b, &clusters)
+	if err != nil {
+		return errors.New("Error decoding Cluster API clusters: " + err.Error())
+	}
+	clusterErrors := evaluateClusters(clusters.Items)
+
+	providers, err := cac.client.AppsV1().Deployments(metav1.NamespaceAll).List(metav1.ListOptions{
+		LabelSelector: providerLabel,
+	})
+	if err != nil {
+		return err
+	}
+	clusterErrors = append(clusterErrors, evaluateProviders(providers.Items)...)
+
+	if len(clusterErrors) > 0 {
+		cac.Errors = clusterErrors
+		return nil
+	}
+
+	cac.clearErrors()
+	return nil
+}
+
+// findClusterAPIVersion uses discovery to find the installed version of the
+// Cluster API.  False is returned when no version is installed.
+func (cac *Checker) findClusterAPIVersion() (string, bool) {
+	for _, v := range clusterAPIVersions {
+		resources, err := cac.client.Discovery().ServerResourcesForGroupVersion(clusterAPIGroup + "/" + v)
+		if err != nil {
+			continue
+		}
+		for _, r := range resources.APIResources {
+			if r.Name == "clusters" {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+// evaluateClusters returns an error message for every cluster that is not
+// provisioned or that has a Ready condition that is False
+func evaluateClusters(clusters []cluster) []string {
+	var clusterErrors []string
+	for _, c := range clusters {
+		if c.Status.Phase != provisionedPhase {
+			clusterErrors = append(clusterErrors, fmt.Sprintf("Cluster %s/%s is in phase %q instead of %s",
+				c.Namespace, c.Name, c.Status.Phase, provisionedPhase))
+		}
+		for _, condition := range c.Status.Conditions {
+			if condition.Type != "Ready" || condition.Status != "False" {
+				continue
+			}
+			clusterErrors = append(clusterErrors, fmt.Sprintf("Cluster %s/%s is not ready: %s %s",
+				c.Namespace, c.Name, condition.Reason, condition.Message))
+		}
+	}
+	return clusterErrors
+}
+
+// evaluateProviders returns an error message for every Cluster API provider
+// deployment that does not have all replicas ready.  An error is returned if
+// no providers are found at all.
+func evaluateProviders(deployments []appsv1.Deployment) []string {
+	if len(deployments) == 0 {
+		return []string{"Cluster API is installed but no provider controller deployments were found"}
+	}
+	var providerErrors []string
+	for _, d := range deployments {
+		desired := int32(1)
+		if d.Spec.Replicas != nil {
+			desired = *d.Spec.Replicas
+		}
+		if d.Status.ReadyReplicas < desired {
+			providerErrors = append(providerErrors, fmt.Sprintf("Cluster API provider %s deployment %s/%s has %d of %d replicas ready",
+				strings.TrimSpace(d.Labels[providerLabel]), d.Namespace, d.Name, d.Status.ReadyReplicas, desired))
+		}
+	}
+	return providerErrors
+}
@@ -0,0 +1,58 @@
+package clusterAPI
+
+import (
+	"encoding/json"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateClusters(t *testing.T) {
+	raw := `{"items": [
+		{"metadata": {"name": "healthy", "namespace": "default"}, "status": {"phase": "Provisioned", "conditions": [{"type": "Ready", "status": "True"}]}},
+		{"metadata": {"name": "provisioning", "namespace": "default"}, "status": {"phase": "Provisioning"}},
+		{"metadata": {"name": "unready", "namespace": "prod"}, "status": {"phase": "Provisioned", "conditions": [{"type": "Ready", "status": "False", "reason": "MachinesNotReady", "message": "0 of 3 ready"}]}},
+		{"metadata": {"name": "failed", "namespace": "prod"}, "status": {"phase": "Failed", "conditions": [{"type": "Ready", "status": "False"}]}}
+	]}`
+
+	var clusters clusterList
+	err := json.Unmarshal([]byte(raw), &clusters)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clusterErrors := evaluateClusters(clusters.Items)
+	for _, e := range clusterErrors {
+		t.Log(e)
+	}
+	// provisioning (1) + unready (1) + failed (2)
+	if len(clusterErrors) != 4 {
+		t.Fatal("Expected 4 cluster errors but got", len(clusterErrors))
+	}
+}
+
+func TestEvaluateProviders(t *testing.T) {
+	if len(evaluateProviders(nil)) != 1 {
+		t.Fatal("Expected an error when no providers are found")
+	}
+
+	replicas := int32(2)
+	deployments := []appsv1.Deployment{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "capi-controller-manager", Labels: map[string]string{providerLabel: "cluster-api"}},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     appsv1.DeploymentStatus{ReadyReplicas: 2},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "capa-controller-manager", Labels: map[string]string{providerLabel: "infrastructure-aws"}},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     appsv1.DeploymentStatus{ReadyReplicas: 1},
+		},
+	}
+	providerErrors := evaluateProviders(deployments)
+	if len(providerErrors) != 1 {
+		t.Fatal("Expected one provider error but got", providerErrors)
+	}
+	t.Log(providerErrors)
+}
@@ -2,6 +2,7 @@
 package componentStatus // import "github.com/Comcast/kuberhealthy/pkg/checks/componentStatus"
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -10,18 +11,24 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 
 	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func init() {
 }
 
+// CheckName is the name of the component status checker, which checks that
+// need a healthy control plane depend on
+const CheckName = "ComponentStatusChecker"
+
 // Checker validates componentstatus objects within the cluster.
 type Checker struct {
 	Errors           []string
 	FailureTimeStamp map[string]time.Time
 	MaxTimeInFailure float64 // TODO - make configurable
 	client           *kubernetes.Clientset
+	interval         time.Duration // overrides the default interval when set
 }
 
 // New returns a new Checker
@@ -35,7 +42,7 @@ func New() *Checker {
 
 // Name returns the name of this checker
 func (csc *Checker) Name() string {
-	return "ComponentStatusChecker"
+	return CheckName
 }
 
 // CheckNamespace returns the namespace of this checker
@@ -45,14 +52,39 @@ func (csc *Checker) CheckNamespace() string {
 
 // Interval returns the interval at which this check runs
 func (csc *Checker) Interval() time.Duration {
+	if csc.interval > 0 {
+		return csc.interval
+	}
 	return time.Minute * 2
 }
 
+// SetInterval overrides the interval at which this check runs
+func (csc *Checker) SetInterval(d time.Duration) {
+	csc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Component statuses
+// are cluster scoped.
+func (csc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"componentstatuses"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
 // Timeout returns the maximum run time for this check before it times out
 func (csc *Checker) Timeout() time.Duration {
 	return time.Minute * 1
 }
 
+// RetryPolicy retries a failing run up to 3 times, starting 5 seconds
+// apart, so that a short API server outage is not reported as unhealthy
+// components
+func (csc *Checker) RetryPolicy() (int, time.Duration) {
+	return 3, time.Second * 5
+}
+
 // Shutdown is implemented to satisfy the KuberhealthyCheck interface, but
 // no action is necessary.
 func (csc *Checker) Shutdown() error {
@@ -73,7 +105,7 @@ func (csc *Checker) clearErrors() {
 }
 
 // Run implements the entrypoint for check execution
-func (csc *Checker) Run(client *kubernetes.Clientset) error {
+func (csc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
 	doneChan := make(chan error)
 	csc.client = client
 	// run the check in a goroutine and notify the doneChan when completed
@@ -90,6 +122,9 @@ func (csc *Checker) Run(client *kubernetes.Clientset) error {
 	case <-time.After(csc.Timeout()):
 		// The check has timed out after its specified timeout period
 		return errors.New("Failed to complete checks for " + csc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + csc.Name() + ".  The check was cancelled.")
 	case err := <-doneChan:
 		return err
 	}
@@ -0,0 +1,279 @@
+// Package configMapSchema implements a checker that validates ConfigMaps
+// against JSON schemas.  This is useful for operator-managed configuration
+// with a known structure, where a malformed ConfigMap is otherwise only
+// noticed when the operator fails to read it.  Schemas are read from a
+// ConfigMap keyed by the names of the ConfigMaps they validate.
+package configMapSchema // import "github.com/Comcast/kuberhealthy/pkg/checks/configMapSchema"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// Checker validates ConfigMaps against JSON schemas
+type Checker struct {
+	Errors          []string
+	SchemaConfigMap string
+	client          *kubernetes.Clientset
+	interval        time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reads schemas from the supplied ConfigMap,
+// given as name in the Kuberhealthy namespace or as namespace/name
+func New(schemaConfigMap string) *Checker {
+	return &Checker{
+		Errors:          []string{},
+		SchemaConfigMap: schemaConfigMap,
+	}
+}
+
+// Name returns the name of this checker
+func (csc *Checker) Name() string {
+	return "ConfigMapSchemaChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (csc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (csc *Checker) Interval() time.Duration {
+	if csc.interval > 0 {
+		return csc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (csc *Checker) SetInterval(d time.Duration) {
+	csc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  ConfigMaps are listed
+// in every namespace, which also covers getting the schema ConfigMap.
+func (csc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (csc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (csc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (csc *Checker) CurrentStatus() (bool, []string) {
+	if len(csc.Errors) > 0 {
+		return false, csc.Errors
+	}
+	return true, csc.Errors
+}
+
+// clearErrors clears all errors
+func (csc *Checker) clearErrors() {
+	csc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (csc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	csc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := csc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(csc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + csc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(csc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + csc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + csc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks loads the schemas, lists ConfigMaps, and sets an error for every
+// ConfigMap that does not conform to its schema
+func (csc *Checker) doChecks() error {
+
+	schemaNamespace := namespace
+	schemaName := csc.SchemaConfigMap
+	if strings.Contains(schemaName, "/") {
+		parts := strings.SplitN(schemaName, "/", 2)
+		schemaNamespace = parts[0]
+		schemaName = parts[1]
+	}
+	cm, err := csc.client.CoreV1().ConfigMaps(schemaNamespace).Get(schemaName, metav1.GetOptions{})
+	if err != nil {
+		return errors.New("Error getting ConfigMap schemas " + schemaNamespace + "/" + schemaName + ": " + err.Error())
+	}
+	schemas, err := parseSchemas(cm.Data)
+	if err != nil {
+		return err
+	}
+
+	configMaps, err := csc.client.CoreV1().ConfigMaps(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing ConfigMaps: " + err.Error())
+	}
+
+	violations := evaluateConfigMaps(schemas, configMaps.Items)
+
+	if len(violations) > 0 {
+		for _, v := range violations {
+			khlog.ForCheck(csc.Name()).Warningln(v)
+		}
+		csc.Errors = violations
+		return nil
+	}
+
+	csc.clearErrors()
+	return nil
+}
+
+// parseSchemas decodes the JSON schema stored under each key of the schema
+// ConfigMap
+func parseSchemas(data map[string]string) (map[string]map[string]interface{}, error) {
+	schemas := make(map[string]map[string]interface{})
+	for key, value := range data {
+		var schema map[string]interface{}
+		err := json.Unmarshal([]byte(value), &schema)
+		if err != nil {
+			return nil, errors.New("Error decoding schema for ConfigMap " + key + ": " + err.Error())
+		}
+		schemas[key] = schema
+	}
+	return schemas, nil
+}
+
+// evaluateConfigMaps returns a violation for every way a ConfigMap does not
+// conform to its schema.  Schema keys are ConfigMap names, which match in
+// every namespace, or namespace.name, which match in one namespace.  Schema
+// keys that match no ConfigMap are also reported.
+func evaluateConfigMaps(schemas map[string]map[string]interface{}, configMaps []apiv1.ConfigMap) []string {
+	var violations []string
+
+	var keys []string
+	for key := range schemas {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var ns string
+		name := key
+		if strings.Contains(key, ".") {
+			parts := strings.SplitN(key, ".", 2)
+			ns = parts[0]
+			name = parts[1]
+		}
+
+		var found bool
+		for _, cm := range configMaps {
+			if cm.Name != name || (len(ns) > 0 && cm.Namespace != ns) {
+				continue
+			}
+			found = true
+			for _, v := range validateConfigMap(schemas[key], cm) {
+				violations = append(violations, "ConfigMap "+cm.Namespace+"/"+cm.Name+" "+v)
+			}
+		}
+		if !found {
+			violations = append(violations, "No ConfigMap found for schema "+key)
+		}
+	}
+	return violations
+}
+
+// validateConfigMap validates the data of a ConfigMap as an object keyed by
+// data key.  Values are strings unless the schema of their key expects
+// structured content, in which case they are decoded as JSON or YAML.
+func validateConfigMap(schema map[string]interface{}, cm apiv1.ConfigMap) []string {
+	var violations []string
+	var undecoded []string
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	instance := make(map[string]interface{})
+	for key, value := range cm.Data {
+		property, _ := properties[key].(map[string]interface{})
+		if !isStructured(property) {
+			instance[key] = value
+			continue
+		}
+		var decoded interface{}
+		err := yaml.Unmarshal([]byte(value), &decoded)
+		if err != nil {
+			violations = append(violations, key+": value is not valid JSON or YAML: "+err.Error())
+			undecoded = append(undecoded, key)
+			continue
+		}
+		instance[key] = decoded
+	}
+
+	// values that could not be decoded are already reported and would
+	// otherwise also be reported as missing
+	for _, v := range validate(schema, instance, "") {
+		var reported bool
+		for _, key := range undecoded {
+			if strings.HasPrefix(v, key+":") {
+				reported = true
+			}
+		}
+		if !reported {
+			violations = append(violations, v)
+		}
+	}
+	return violations
+}
+
+// isStructured determines if a property schema expects content other than
+// a plain string
+func isStructured(property map[string]interface{}) bool {
+	types := schemaTypes(property["type"])
+	if len(types) == 0 {
+		_, hasProperties := property["properties"]
+		_, hasItems := property["items"]
+		return hasProperties || hasItems
+	}
+	for _, t := range types {
+		if t == "string" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package configMapSchema
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const operatorSchema = `{
+  "type": "object",
+  "required": ["mode", "config.json"],
+  "additionalProperties": false,
+  "properties": {
+    "mode": {"type": "string", "enum": ["active", "standby"]},
+    "owner": {"type": "string", "pattern": "^[a-z-]+$"},
+    "config.json": {
+      "type": "object",
+      "required": ["replicas"],
+      "properties": {
+        "replicas": {"type": "integer", "minimum": 1, "maximum": 10},
+        "endpoints": {"type": "array", "minItems": 1, "items": {"type": "string", "minLength": 1}}
+      }
+    }
+  }
+}`
+
+func makeConfigMap(ns string, name string, data map[string]string) apiv1.ConfigMap {
+	return apiv1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+		Data:       data,
+	}
+}
+
+func TestEvaluateConfigMaps(t *testing.T) {
+	schemas, err := parseSchemas(map[string]string{"operator-config": operatorSchema})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		description string
+		data        map[string]string
+		expected    int
+	}{
+		{"valid json", map[string]string{"mode": "active", "config.json": `{"replicas": 3, "endpoints": ["a", "b"]}`}, 0},
+		{"valid yaml", map[string]string{"mode": "standby", "owner": "platform-team", "config.json": "replicas: 3\n"}, 0},
+		{"missing key", map[string]string{"config.json": `{"replicas": 3}`}, 1},
+		{"unexpected key", map[string]string{"mode": "active", "config.json": `{"replicas": 3}`, "extra": "x"}, 1},
+		{"enum mismatch", map[string]string{"mode": "paused", "config.json": `{"replicas": 3}`}, 1},
+		{"pattern mismatch", map[string]string{"mode": "active", "owner": "Platform Team", "config.json": `{"replicas": 3}`}, 1},
+		{"invalid structured value", map[string]string{"mode": "active", "config.json": `{"replicas": `}, 1},
+		{"wrong nested type", map[string]string{"mode": "active", "config.json": `{"replicas": "three"}`}, 1},
+		{"non integer", map[string]string{"mode": "active", "config.json": `{"replicas": 2.5}`}, 1},
+		{"above maximum", map[string]string{"mode": "active", "config.json": `{"replicas": 11}`}, 1},
+		{"missing nested property", map[string]string{"mode": "active", "config.json": `{}`}, 1},
+		{"bad array items", map[string]string{"mode": "active", "config.json": `{"replicas": 3, "endpoints": ["", 5]}`}, 2},
+		{"empty array", map[string]string{"mode": "active", "config.json": `{"replicas": 3, "endpoints": []}`}, 1},
+	}
+
+	for _, test := range tests {
+		violations := evaluateConfigMaps(schemas, []apiv1.ConfigMap{makeConfigMap("operators", "operator-config", test.data)})
+		if len(violations) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "violations but got", violations)
+		}
+		t.Log(test.description, violations)
+	}
+}
+
+func TestEvaluateConfigMapsMatching(t *testing.T) {
+	schemas, err := parseSchemas(map[string]string{
+		"settings":         `{"required": ["level"]}`,
+		"operators.limits": `{"required": ["max"]}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configMaps := []apiv1.ConfigMap{
+		makeConfigMap("a", "settings", map[string]string{"level": "1"}),
+		makeConfigMap("b", "settings", map[string]string{}),
+		makeConfigMap("default", "limits", map[string]string{}),
+	}
+
+	// settings is checked in every namespace and limits only in operators
+	violations := evaluateConfigMaps(schemas, configMaps)
+	if len(violations) != 2 {
+		t.Fatal("Expected a violation for b/settings and a missing operators/limits but got", violations)
+	}
+	t.Log(violations)
+}
+
+func TestParseSchemas(t *testing.T) {
+	_, err := parseSchemas(map[string]string{"broken": `{"type": `})
+	if err == nil {
+		t.Fatal("Expected an error for an invalid schema")
+	}
+}
@@ -0,0 +1,193 @@
+package configMapSchema
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// validate returns a description of every way a decoded JSON value does not
+// conform to a JSON schema.  The type, enum, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// pattern, minimum and maximum keywords are supported and other keywords are
+// ignored.
+func validate(schema map[string]interface{}, value interface{}, path string) []string {
+	var violations []string
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		actual := jsonType(value)
+		var matched bool
+		for _, t := range types {
+			if t == actual || (t == "number" && actual == "integer") {
+				matched = true
+			}
+		}
+		if !matched {
+			// further keywords would only repeat the type mismatch
+			return append(violations, path+": expected type "+joinTypes(types)+" but got "+actual)
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		var matched bool
+		for _, e := range enum {
+			if reflect.DeepEqual(e, value) {
+				matched = true
+			}
+		}
+		if !matched {
+			violations = append(violations, path+": value "+describe(value)+" is not one of "+describe(enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		violations = append(violations, validateObject(schema, v, path)...)
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				violations = append(violations, validate(items, item, path+"["+strconv.Itoa(i)+"]")...)
+			}
+		}
+		if min, ok := schema["minItems"].(float64); ok && float64(len(v)) < min {
+			violations = append(violations, path+": expected at least "+describe(min)+" items but got "+strconv.Itoa(len(v)))
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(v)) > max {
+			violations = append(violations, path+": expected at most "+describe(max)+" items but got "+strconv.Itoa(len(v)))
+		}
+	case string:
+		length := len([]rune(v))
+		if min, ok := schema["minLength"].(float64); ok && float64(length) < min {
+			violations = append(violations, path+": expected at least "+describe(min)+" characters but got "+strconv.Itoa(length))
+		}
+		if max, ok := schema["maxLength"].(float64); ok && float64(length) > max {
+			violations = append(violations, path+": expected at most "+describe(max)+" characters but got "+strconv.Itoa(length))
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				violations = append(violations, path+": schema pattern "+pattern+" is invalid: "+err.Error())
+			} else if !re.MatchString(v) {
+				violations = append(violations, path+": value "+describe(v)+" does not match pattern "+pattern)
+			}
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && v < min {
+			violations = append(violations, path+": value "+describe(v)+" is less than the minimum of "+describe(min))
+		}
+		if max, ok := schema["maximum"].(float64); ok && v > max {
+			violations = append(violations, path+": value "+describe(v)+" is greater than the maximum of "+describe(max))
+		}
+	}
+	return violations
+}
+
+// validateObject validates the required, properties and
+// additionalProperties keywords against an object
+func validateObject(schema map[string]interface{}, object map[string]interface{}, path string) []string {
+	var violations []string
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, ok := r.(string)
+			if !ok {
+				continue
+			}
+			if _, ok := object[name]; !ok {
+				violations = append(violations, joinPath(path, name)+": required property is missing")
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	var names []string
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if property, ok := properties[name].(map[string]interface{}); ok {
+			violations = append(violations, validate(property, object[name], joinPath(path, name))...)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				violations = append(violations, joinPath(path, name)+": property is not allowed")
+			}
+		case map[string]interface{}:
+			violations = append(violations, validate(additional, object[name], joinPath(path, name))...)
+		}
+	}
+	return violations
+}
+
+// schemaTypes returns the types allowed by a type keyword, which may be a
+// single type or a list of types
+func schemaTypes(t interface{}) []string {
+	switch v := t.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var types []string
+		for _, s := range v {
+			if str, ok := s.(string); ok {
+				types = append(types, str)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// jsonType returns the JSON schema type of a decoded JSON value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+// joinTypes formats a list of types for an error message
+func joinTypes(types []string) string {
+	s := types[0]
+	for _, t := range types[1:] {
+		s += " or " + t
+	}
+	return s
+}
+
+// joinPath appends a property name to a path
+func joinPath(path string, name string) string {
+	if len(path) == 0 {
+		return name
+	}
+	return path + "." + name
+}
+
+// describe formats a value as JSON for an error message
+func describe(value interface{}) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "<invalid>"
+	}
+	return string(b)
+}
@@ -0,0 +1,219 @@
+// Package controllerManagerLease implements a checker that verifies the
+// kube-controller-manager and kube-scheduler leader leases are being
+// renewed.  When a leader stops renewing its lease, no controllers or
+// scheduling take place in the cluster.
+package controllerManagerLease // import "github.com/Comcast/kuberhealthy/pkg/checks/controllerManagerLease"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// leaseNamespace is the namespace where control plane leader leases are kept
+const leaseNamespace = "kube-system"
+
+// leaderAnnotation is the annotation used to record leader election on
+// endpoints objects by control planes that do not use Lease objects
+const leaderAnnotation = "control-plane.alpha.kubernetes.io/leader"
+
+// defaultRenewalBuffer is the time allowed beyond the lease duration before
+// a lease is considered stale
+const defaultRenewalBuffer = time.Second * 30
+
+// leaderRecord holds the information needed to evaluate a leader lease
+type leaderRecord struct {
+	HolderIdentity       string    `json:"holderIdentity"`
+	LeaseDurationSeconds int       `json:"leaseDurationSeconds"`
+	RenewTime            time.Time `json:"renewTime"`
+}
+
+// Checker validates that control plane leader leases are being renewed
+type Checker struct {
+	Errors        []string
+	Leases        []string
+	RenewalBuffer time.Duration
+	client        *kubernetes.Clientset
+	interval      time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker
+func New() *Checker {
+	return &Checker{
+		Errors:        []string{},
+		Leases:        []string{"kube-controller-manager", "kube-scheduler"},
+		RenewalBuffer: defaultRenewalBuffer,
+	}
+}
+
+// Name returns the name of this checker
+func (lc *Checker) Name() string {
+	return "ControllerManagerLeaseChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (lc *Checker) CheckNamespace() string {
+	return leaseNamespace
+}
+
+// Interval returns the interval at which this check runs
+func (lc *Checker) Interval() time.Duration {
+	if lc.interval > 0 {
+		return lc.interval
+	}
+	return time.Minute * 1
+}
+
+// SetInterval overrides the interval at which this check runs
+func (lc *Checker) SetInterval(d time.Duration) {
+	lc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to read the leader
+// records of the checked leases
+func (lc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		leaseNamespace: {
+			{APIGroups: []string{"coordination.k8s.io"}, Resources: []string{"leases"}, ResourceNames: lc.Leases, Verbs: []string{"get"}},
+			{APIGroups: []string{""}, Resources: []string{"endpoints"}, ResourceNames: lc.Leases, Verbs: []string{"get"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (lc *Checker) Timeout() time.Duration {
+	return time.Second * 30
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (lc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (lc *Checker) CurrentStatus() (bool, []string) {
+	if len(lc.Errors) > 0 {
+		return false, lc.Errors
+	}
+	return true, lc.Errors
+}
+
+// clearErrors clears all errors
+func (lc *Checker) clearErrors() {
+	lc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (lc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	lc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := lc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(lc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + lc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(lc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + lc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + lc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks fetches each leader lease and validates it was renewed recently
+func (lc *Checker) doChecks() error {
+
+	var leaseErrors []string
+	for _, name := range lc.Leases {
+		record, err := lc.fetchLeaderRecord(name)
+		if err != nil {
+			leaseErrors = append(leaseErrors, "Unable to fetch leader lease for "+name+": "+err.Error())
+			continue
+		}
+		leaseError := evaluateLeaderRecord(name, record, time.Now(), lc.RenewalBuffer)
+		if len(leaseError) > 0 {
+			khlog.ForCheck(lc.Name()).Warningln(leaseError)
+			leaseErrors = append(leaseErrors, leaseError)
+		}
+	}
+
+	if len(leaseErrors) > 0 {
+		lc.Errors = leaseErrors
+		return nil
+	}
+
+	lc.clearErrors()
+	return nil
+}
+
+// fetchLeaderRecord reads the Lease object with the specified name.  If no
+// Lease exists, the leader annotation on the endpoints object of the same
+// name is used instead.
+func (lc *Checker) fetchLeaderRecord(name string) (leaderRecord, error) {
+	var record leaderRecord
+
+	lease, err := lc.client.CoordinationV1beta1().Leases(leaseNamespace).Get(name, metav1.GetOptions{})
+	if err == nil {
+		if lease.Spec.HolderIdentity != nil {
+			record.HolderIdentity = *lease.Spec.HolderIdentity
+		}
+		if lease.Spec.LeaseDurationSeconds != nil {
+			record.LeaseDurationSeconds = int(*lease.Spec.LeaseDurationSeconds)
+		}
+		if lease.Spec.RenewTime != nil {
+			record.RenewTime = lease.Spec.RenewTime.Time
+		}
+		return record, nil
+	}
+	if !strings.Contains(err.Error(), "not found") {
+		return record, err
+	}
+
+	khlog.ForCheck(lc.Name()).Debugln("No lease found for", name, "falling back to endpoints leader annotation")
+	endpoints, err := lc.client.CoreV1().Endpoints(leaseNamespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return record, err
+	}
+	annotation, ok := endpoints.Annotations[leaderAnnotation]
+	if !ok {
+		return record, errors.New("no lease or leader annotation found")
+	}
+	err = json.Unmarshal([]byte(annotation), &record)
+	return record, err
+}
+
+// evaluateLeaderRecord returns an error message if the leader record has not
+// been renewed within its lease duration plus the supplied buffer
+func evaluateLeaderRecord(name string, record leaderRecord, now time.Time, buffer time.Duration) string {
+	if record.RenewTime.IsZero() {
+		return "Leader lease for " + name + " held by " + record.HolderIdentity + " has never been renewed"
+	}
+	elapsed := now.Sub(record.RenewTime)
+	allowed := time.Duration(record.LeaseDurationSeconds)*time.Second + buffer
+	if elapsed > allowed {
+		return fmt.Sprintf("Leader lease for %s held by %s was last renewed %s ago which is longer than the allowed %s",
+			name, record.HolderIdentity, elapsed.Round(time.Second), allowed)
+	}
+	return ""
+}
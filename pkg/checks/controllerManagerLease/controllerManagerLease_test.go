@@ -0,0 +1,49 @@
+package controllerManagerLease
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEvaluateLeaderRecord(t *testing.T) {
+
+	now := time.Now()
+
+	var tests = []struct {
+		description string
+		record      leaderRecord
+		expectError bool
+	}{
+		{"freshly renewed lease", leaderRecord{"master-1", 15, now.Add(-time.Second * 5)}, false},
+		{"lease renewed within buffer", leaderRecord{"master-1", 15, now.Add(-time.Second * 40)}, false},
+		{"stale lease", leaderRecord{"master-2", 15, now.Add(-time.Minute * 5)}, true},
+		{"never renewed lease", leaderRecord{"master-3", 15, time.Time{}}, true},
+	}
+
+	for _, test := range tests {
+		result := evaluateLeaderRecord("kube-controller-manager", test.record, now, defaultRenewalBuffer)
+		if test.expectError && len(result) == 0 {
+			t.Fatal("Expected an error for", test.description, "but got none")
+		}
+		if !test.expectError && len(result) > 0 {
+			t.Fatal("Expected no error for", test.description, "but got:", result)
+		}
+		t.Log(test.description, result)
+	}
+}
+
+func TestLeaderAnnotationDecode(t *testing.T) {
+	annotation := `{"holderIdentity":"master-1_1234","leaseDurationSeconds":15,"acquireTime":"2019-03-01T15:04:05Z","renewTime":"2019-03-01T16:04:05Z","leaderTransitions":3}`
+	var record leaderRecord
+	err := json.Unmarshal([]byte(annotation), &record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.HolderIdentity != "master-1_1234" || record.LeaseDurationSeconds != 15 {
+		t.Fatal("Leader annotation decoded incorrectly:", record)
+	}
+	if record.RenewTime.Hour() != 16 {
+		t.Fatal("Renew time decoded incorrectly:", record.RenewTime)
+	}
+}
@@ -0,0 +1,212 @@
+// Package corsConfig implements a checker that finds Ingresses with unsafe
+// CORS configuration.  The ingress-nginx CORS annotations allow any origin by
+// default, which exposes services in sensitive namespaces to cross-origin
+// requests, and allowing credentials from any origin is rejected by browsers.
+package corsConfig // import "github.com/Comcast/kuberhealthy/pkg/checks/corsConfig"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// SensitiveAnnotation marks a namespace as sensitive when set to "true"
+	// on the namespace.  Ingresses in sensitive namespaces may not allow
+	// CORS requests from any origin.
+	SensitiveAnnotation = "kuberhealthy.io/sensitive"
+	// EnableCORSAnnotation enables CORS on an ingress-nginx Ingress
+	EnableCORSAnnotation = "nginx.ingress.kubernetes.io/enable-cors"
+	// AllowOriginAnnotation sets the origins allowed to make CORS requests.
+	// ingress-nginx allows any origin when it is not set.
+	AllowOriginAnnotation = "nginx.ingress.kubernetes.io/cors-allow-origin"
+	// AllowCredentialsAnnotation sets whether CORS requests may include
+	// credentials.  ingress-nginx allows credentials when it is not set.
+	AllowCredentialsAnnotation = "nginx.ingress.kubernetes.io/cors-allow-credentials"
+)
+
+// Checker validates the CORS configuration of Ingresses
+type Checker struct {
+	Errors   []string
+	client   *kubernetes.Clientset
+	interval time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker
+func New() *Checker {
+	return &Checker{
+		Errors: []string{},
+	}
+}
+
+// Name returns the name of this checker
+func (ccc *Checker) Name() string {
+	return "CORSConfigChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (ccc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (ccc *Checker) Interval() time.Duration {
+	if ccc.interval > 0 {
+		return ccc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (ccc *Checker) SetInterval(d time.Duration) {
+	ccc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Namespaces are cluster
+// scoped and Ingresses are listed in every namespace.
+func (ccc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"list"}},
+			{APIGroups: []string{"extensions"}, Resources: []string{"ingresses"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (ccc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (ccc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (ccc *Checker) CurrentStatus() (bool, []string) {
+	if len(ccc.Errors) > 0 {
+		return false, ccc.Errors
+	}
+	return true, ccc.Errors
+}
+
+// clearErrors clears all errors
+func (ccc *Checker) clearErrors() {
+	ccc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (ccc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	ccc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := ccc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(ccc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + ccc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(ccc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + ccc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + ccc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists namespaces and Ingresses and sets an error for every
+// Ingress with unsafe CORS configuration
+func (ccc *Checker) doChecks() error {
+
+	namespaces, err := ccc.client.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing namespaces: " + err.Error())
+	}
+	ingresses, err := ccc.client.ExtensionsV1beta1().Ingresses(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing Ingresses: " + err.Error())
+	}
+
+	corsErrors := evaluateIngresses(ingresses.Items, namespaces.Items)
+
+	if len(corsErrors) > 0 {
+		for _, e := range corsErrors {
+			khlog.ForCheck(ccc.Name()).Warningln(e)
+		}
+		ccc.Errors = corsErrors
+		return nil
+	}
+
+	ccc.clearErrors()
+	return nil
+}
+
+// evaluateIngresses returns an error for every Ingress with CORS enabled
+// that allows any origin in a sensitive namespace, or that allows
+// credentials from any origin.  Unset annotations take the ingress-nginx
+// defaults of allowing any origin with credentials.
+func evaluateIngresses(ingresses []extensionsv1beta1.Ingress, namespaces []apiv1.Namespace) []string {
+	var corsErrors []string
+
+	sensitive := make(map[string]bool)
+	for _, ns := range namespaces {
+		if ns.Annotations[SensitiveAnnotation] == "true" {
+			sensitive[ns.Name] = true
+		}
+	}
+
+	sort.Slice(ingresses, func(i, j int) bool {
+		if ingresses[i].Namespace != ingresses[j].Namespace {
+			return ingresses[i].Namespace < ingresses[j].Namespace
+		}
+		return ingresses[i].Name < ingresses[j].Name
+	})
+
+	for _, ing := range ingresses {
+		if ing.Annotations[EnableCORSAnnotation] != "true" {
+			continue
+		}
+
+		origin := strings.TrimSpace(ing.Annotations[AllowOriginAnnotation])
+		if len(origin) == 0 {
+			origin = "*"
+		}
+		credentials := strings.TrimSpace(ing.Annotations[AllowCredentialsAnnotation])
+		if len(credentials) == 0 {
+			credentials = "true"
+		}
+		if origin != "*" {
+			continue
+		}
+
+		name := "Ingress " + ing.Name + " in namespace " + ing.Namespace
+		if sensitive[ing.Namespace] {
+			corsErrors = append(corsErrors, name+" allows CORS requests from any origin in a namespace marked sensitive with "+SensitiveAnnotation)
+		}
+		if credentials == "true" {
+			corsErrors = append(corsErrors, name+" allows CORS requests with credentials from any origin, which browsers reject.  Set "+AllowOriginAnnotation+" to the allowed origins or "+AllowCredentialsAnnotation+" to false.")
+		}
+	}
+	return corsErrors
+}
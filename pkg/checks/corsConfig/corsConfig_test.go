@@ -0,0 +1,50 @@
+package corsConfig
+
+import (
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateIngresses(t *testing.T) {
+	namespaces := []apiv1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "payments", Annotations: map[string]string{SensitiveAnnotation: "true"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+	}
+
+	makeIngress := func(namespace string, annotations map[string]string) extensionsv1beta1.Ingress {
+		return extensionsv1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace, Annotations: annotations}}
+	}
+
+	var tests = []struct {
+		description    string
+		ingress        extensionsv1beta1.Ingress
+		expectedErrors []string
+	}{
+		{"CORS not enabled", makeIngress("payments", map[string]string{AllowOriginAnnotation: "*"}), nil},
+		{"specific origin in sensitive namespace", makeIngress("payments", map[string]string{EnableCORSAnnotation: "true", AllowOriginAnnotation: "https://example.com"}), nil},
+		{"any origin without credentials", makeIngress("default", map[string]string{EnableCORSAnnotation: "true", AllowOriginAnnotation: "*", AllowCredentialsAnnotation: "false"}), nil},
+		{"any origin in sensitive namespace", makeIngress("payments", map[string]string{EnableCORSAnnotation: "true", AllowOriginAnnotation: "*", AllowCredentialsAnnotation: "false"}),
+			[]string{"Ingress web in namespace payments allows CORS requests from any origin in a namespace marked sensitive"}},
+		{"any origin with credentials", makeIngress("default", map[string]string{EnableCORSAnnotation: "true", AllowOriginAnnotation: "*", AllowCredentialsAnnotation: "true"}),
+			[]string{"allows CORS requests with credentials from any origin"}},
+		{"defaults in sensitive namespace", makeIngress("payments", map[string]string{EnableCORSAnnotation: "true"}),
+			[]string{"namespace marked sensitive", "with credentials from any origin"}},
+	}
+
+	for _, test := range tests {
+		corsErrors := evaluateIngresses([]extensionsv1beta1.Ingress{test.ingress}, namespaces)
+		if len(corsErrors) != len(test.expectedErrors) {
+			t.Fatal("Test", test.description, "expected", len(test.expectedErrors), "errors but got", corsErrors)
+		}
+		for i, expected := range test.expectedErrors {
+			if !strings.Contains(corsErrors[i], expected) {
+				t.Fatal("Test", test.description, "expected an error containing", expected, "but got", corsErrors[i])
+			}
+		}
+		t.Log(test.description, corsErrors)
+	}
+}
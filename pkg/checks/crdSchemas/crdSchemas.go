@@ -0,0 +1,263 @@
+// Package crdSchemas implements a checker that ensures every
+// CustomResourceDefinition has an OpenAPI schema that validates its custom
+// resources.  CustomResourceDefinitions without a schema accept arbitrary
+// content, which can be a security issue.
+package crdSchemas // import "github.com/Comcast/kuberhealthy/pkg/checks/crdSchemas"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	log "github.com/sirupsen/logrus"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const apiExtensionsGroup = "apiextensions.k8s.io"
+
+// apiExtensionsVersions are the versions of the CustomResourceDefinition API
+// in order of preference
+var apiExtensionsVersions = []string{"v1", "v1beta1"}
+
+// preserveUnknownFields is the schema extension that disables pruning and
+// validation of unknown fields
+const preserveUnknownFields = "x-kubernetes-preserve-unknown-fields"
+
+// crdList is the subset of a CustomResourceDefinitionList used by this check.
+// The apiextensions client is not vendored, so the fields are decoded here.
+type crdList struct {
+	Items []crd `json:"items"`
+}
+
+// crd is the subset of a CustomResourceDefinition used by this check
+type crd struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Validation *crdValidation `json:"validation"`
+		Versions   []crdVersion   `json:"versions"`
+	} `json:"spec"`
+}
+
+// crdVersion is a single version served by a CustomResourceDefinition
+type crdVersion struct {
+	Name   string         `json:"name"`
+	Served bool           `json:"served"`
+	Schema *crdValidation `json:"schema"`
+}
+
+// crdValidation holds the OpenAPI schema of a CustomResourceDefinition
+type crdValidation struct {
+	OpenAPIV3Schema map[string]interface{} `json:"openAPIV3Schema"`
+}
+
+// Checker validates that CustomResourceDefinitions have schemas
+type Checker struct {
+	Errors     []string
+	Exceptions []string
+	client     *kubernetes.Clientset
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that skips the CustomResourceDefinitions named
+// in exceptions
+func New(exceptions []string) *Checker {
+	return &Checker{
+		Errors:     []string{},
+		Exceptions: exceptions,
+	}
+}
+
+// Name returns the name of this checker
+func (csc *Checker) Name() string {
+	return "CRDSchemaChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (csc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (csc *Checker) Interval() time.Duration {
+	if csc.interval > 0 {
+		return csc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (csc *Checker) SetInterval(d time.Duration) {
+	csc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Custom resource
+// definitions are cluster scoped.
+func (csc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{apiExtensionsGroup}, Resources: []string{"customresourcedefinitions"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (csc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (csc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (csc *Checker) CurrentStatus() (bool, []string) {
+	if len(csc.Errors) > 0 {
+		return false, csc.Errors
+	}
+	return true, csc.Errors
+}
+
+// clearErrors clears all errors
+func (csc *Checker) clearErrors() {
+	csc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (csc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	csc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := csc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(csc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + csc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(csc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + csc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + csc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists all CustomResourceDefinitions and sets an error for every
+// one that does not validate its custom resources
+func (csc *Checker) doChecks() error {
+
+	version, found := csc.findAPIExtensionsVersion()
+	if !found {
+		return errors.New("Unable to find a served version of the " + apiExtensionsGroup + " API")
+	}
+
+	b, err := csc.client.CoreV1().RESTClient().Get().AbsPath("/apis", apiExtensionsGroup, version, "customresourcedefinitions").DoRaw()
+	if err != nil {
+		return err
+	}
+	var crds crdList
+	err = json.Unmarshal(b, &crds)
+	if err != nil {
+		return errors.New("Error decoding CustomResourceDefinitions: " + err.Error())
+	}
+
+	schemaErrors := evaluateCRDs(crds.Items, csc.Exceptions, khlog.ForCheck(csc.Name()))
+	if len(schemaErrors) > 0 {
+		for _, e := range schemaErrors {
+			khlog.ForCheck(csc.Name()).Warningln(e)
+		}
+		csc.Errors = schemaErrors
+		return nil
+	}
+
+	csc.clearErrors()
+	return nil
+}
+
+// findAPIExtensionsVersion uses discovery to find the preferred served
+// version of the CustomResourceDefinition API
+func (csc *Checker) findAPIExtensionsVersion() (string, bool) {
+	for _, v := range apiExtensionsVersions {
+		resources, err := csc.client.Discovery().ServerResourcesForGroupVersion(apiExtensionsGroup + "/" + v)
+		if err != nil {
+			continue
+		}
+		for _, r := range resources.APIResources {
+			if r.Name == "customresourcedefinitions" {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+// evaluateCRDs returns an error for each CustomResourceDefinition version
+// that has no OpenAPI schema or whose schema preserves unknown fields at the
+// top level
+func evaluateCRDs(crds []crd, exceptions []string, checkLog *log.Entry) []string {
+	var schemaErrors []string
+
+	skip := make(map[string]bool)
+	for _, e := range exceptions {
+		skip[strings.TrimSpace(e)] = true
+	}
+
+	for _, c := range crds {
+		name := c.Metadata.Name
+		if skip[name] {
+			checkLog.Debugln("Skipping schema validation of CustomResourceDefinition", name)
+			continue
+		}
+
+		// a top level validation applies to every version
+		if c.Spec.Validation != nil && c.Spec.Validation.OpenAPIV3Schema != nil {
+			if preservesUnknownFields(c.Spec.Validation.OpenAPIV3Schema) {
+				schemaErrors = append(schemaErrors, "CustomResourceDefinition "+name+" sets "+preserveUnknownFields+": true at the top level of its schema which disables validation")
+			}
+			continue
+		}
+
+		var versionChecked bool
+		for _, v := range c.Spec.Versions {
+			if !v.Served {
+				continue
+			}
+			versionChecked = true
+			if v.Schema == nil || v.Schema.OpenAPIV3Schema == nil {
+				schemaErrors = append(schemaErrors, "CustomResourceDefinition "+name+" version "+v.Name+" has no openAPIV3Schema")
+				continue
+			}
+			if preservesUnknownFields(v.Schema.OpenAPIV3Schema) {
+				schemaErrors = append(schemaErrors, "CustomResourceDefinition "+name+" version "+v.Name+" sets "+preserveUnknownFields+": true at the top level of its schema which disables validation")
+			}
+		}
+		if !versionChecked {
+			schemaErrors = append(schemaErrors, "CustomResourceDefinition "+name+" has no openAPIV3Schema")
+		}
+	}
+	return schemaErrors
+}
+
+// preservesUnknownFields determines if a schema preserves unknown fields at
+// its top level
+func preservesUnknownFields(schema map[string]interface{}) bool {
+	preserve, ok := schema[preserveUnknownFields].(bool)
+	return ok && preserve
+}
@@ -0,0 +1,40 @@
+package crdSchemas
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+)
+
+const crdsJSON = `{"items": [
+	{"metadata": {"name": "khstates.comcast.github.io"}, "spec": {"validation": {"openAPIV3Schema": {"type": "object"}}}},
+	{"metadata": {"name": "noschema.example.com"}, "spec": {"versions": [{"name": "v1", "served": true}]}},
+	{"metadata": {"name": "noversions.example.com"}, "spec": {}},
+	{"metadata": {"name": "preserved.example.com"}, "spec": {"validation": {"openAPIV3Schema": {"type": "object", "x-kubernetes-preserve-unknown-fields": true}}}},
+	{"metadata": {"name": "nested.example.com"}, "spec": {"versions": [{"name": "v1", "served": true, "schema": {"openAPIV3Schema": {"type": "object", "properties": {"spec": {"x-kubernetes-preserve-unknown-fields": true}}}}}]}},
+	{"metadata": {"name": "mixed.example.com"}, "spec": {"versions": [
+		{"name": "v1", "served": true, "schema": {"openAPIV3Schema": {"type": "object"}}},
+		{"name": "v1beta1", "served": true, "schema": {"openAPIV3Schema": {"x-kubernetes-preserve-unknown-fields": true}}},
+		{"name": "v1alpha1", "served": false}
+	]}},
+	{"metadata": {"name": "excepted.example.com"}, "spec": {"versions": [{"name": "v1", "served": true}]}}
+]}`
+
+func TestEvaluateCRDs(t *testing.T) {
+	var crds crdList
+	err := json.Unmarshal([]byte(crdsJSON), &crds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schemaErrors := evaluateCRDs(crds.Items, []string{"excepted.example.com"}, khlog.ForCheck("test"))
+	for _, e := range schemaErrors {
+		t.Log(e)
+	}
+
+	// noschema, noversions, preserved, and the v1beta1 version of mixed
+	if len(schemaErrors) != 4 {
+		t.Fatal("Expected 4 schema errors but got", len(schemaErrors))
+	}
+}
@@ -0,0 +1,280 @@
+// Package crdStoredVersions implements a checker that ensures
+// CustomResourceDefinitions serving several versions can convert between
+// them.  When a served version differs from the storage version and the
+// conversion strategy is None, requests for the served version return
+// objects that were never converted to its schema.
+package crdStoredVersions // import "github.com/Comcast/kuberhealthy/pkg/checks/crdStoredVersions"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/kubernetes"
+)
+
+const apiExtensionsGroup = "apiextensions.k8s.io"
+
+// apiExtensionsVersions are the versions of the CustomResourceDefinition API
+// in order of preference
+var apiExtensionsVersions = []string{"v1", "v1beta1"}
+
+// conversionNone is the conversion strategy that only changes the apiVersion
+// of objects.  It is the default when no strategy is set.
+const conversionNone = "None"
+
+// crdList is the subset of a CustomResourceDefinitionList used by this check.
+// The apiextensions client is not vendored, so the fields are decoded here.
+type crdList struct {
+	Items []crd `json:"items"`
+}
+
+// crd is the subset of a CustomResourceDefinition used by this check
+type crd struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Versions   []crdVersion `json:"versions"`
+		Conversion *struct {
+			Strategy string `json:"strategy"`
+		} `json:"conversion"`
+	} `json:"spec"`
+	Status struct {
+		StoredVersions []string `json:"storedVersions"`
+	} `json:"status"`
+}
+
+// crdVersion is a single version of a CustomResourceDefinition
+type crdVersion struct {
+	Name       string `json:"name"`
+	Served     bool   `json:"served"`
+	Storage    bool   `json:"storage"`
+	Deprecated bool   `json:"deprecated"`
+}
+
+// Checker validates the versions and conversion strategies of
+// CustomResourceDefinitions
+type Checker struct {
+	Errors   []string
+	client   *kubernetes.Clientset
+	interval time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker
+func New() *Checker {
+	return &Checker{
+		Errors: []string{},
+	}
+}
+
+// Name returns the name of this checker
+func (cvc *Checker) Name() string {
+	return "CRDStoredVersionsChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (cvc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (cvc *Checker) Interval() time.Duration {
+	if cvc.interval > 0 {
+		return cvc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (cvc *Checker) SetInterval(d time.Duration) {
+	cvc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to list the cluster
+// scoped custom resource definitions
+func (cvc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{apiExtensionsGroup}, Resources: []string{"customresourcedefinitions"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (cvc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (cvc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (cvc *Checker) CurrentStatus() (bool, []string) {
+	if len(cvc.Errors) > 0 {
+		return false, cvc.Errors
+	}
+	return true, cvc.Errors
+}
+
+// clearErrors clears all errors
+func (cvc *Checker) clearErrors() {
+	cvc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (cvc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	cvc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := cvc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(cvc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + cvc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(cvc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + cvc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + cvc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists all CustomResourceDefinitions and sets an error for every
+// version configuration that can not serve its custom resources correctly
+func (cvc *Checker) doChecks() error {
+
+	apiVersion, found := cvc.findAPIExtensionsVersion()
+	if !found {
+		return errors.New("Unable to find a served version of the " + apiExtensionsGroup + " API")
+	}
+
+	b, err := cvc.client.CoreV1().RESTClient().Get().AbsPath("/apis", apiExtensionsGroup, apiVersion, "customresourcedefinitions").DoRaw()
+	if err != nil {
+		return err
+	}
+	var crds crdList
+	err = json.Unmarshal(b, &crds)
+	if err != nil {
+		return errors.New("Error decoding CustomResourceDefinitions: " + err.Error())
+	}
+
+	versionErrors := evaluateCRDs(crds.Items)
+	if len(versionErrors) > 0 {
+		for _, e := range versionErrors {
+			khlog.ForCheck(cvc.Name()).Warningln(e)
+		}
+		cvc.Errors = versionErrors
+		return nil
+	}
+
+	cvc.clearErrors()
+	return nil
+}
+
+// findAPIExtensionsVersion uses discovery to find the preferred served
+// version of the CustomResourceDefinition API
+func (cvc *Checker) findAPIExtensionsVersion() (string, bool) {
+	for _, v := range apiExtensionsVersions {
+		resources, err := cvc.client.Discovery().ServerResourcesForGroupVersion(apiExtensionsGroup + "/" + v)
+		if err != nil {
+			continue
+		}
+		for _, r := range resources.APIResources {
+			if r.Name == "customresourcedefinitions" {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+// evaluateCRDs returns an error for every CustomResourceDefinition that does
+// not have exactly one storage version, serves or has stored versions other
+// than its storage version without a conversion strategy, or serves versions
+// older than its storage version without marking them deprecated
+func evaluateCRDs(crds []crd) []string {
+	var versionErrors []string
+
+	sort.Slice(crds, func(i, j int) bool {
+		return crds[i].Metadata.Name < crds[j].Metadata.Name
+	})
+	for _, c := range crds {
+		name := c.Metadata.Name
+		// CustomResourceDefinitions with a single version set in the
+		// deprecated version field have nothing to convert
+		if len(c.Spec.Versions) == 0 {
+			continue
+		}
+
+		var storage []string
+		for _, v := range c.Spec.Versions {
+			if v.Storage {
+				storage = append(storage, v.Name)
+			}
+		}
+		if len(storage) != 1 {
+			found := "none"
+			if len(storage) > 0 {
+				found = strconv.Itoa(len(storage)) + ": " + strings.Join(storage, ", ")
+			}
+			versionErrors = append(versionErrors, "CustomResourceDefinition "+name+" must have exactly one storage version but has "+found)
+			continue
+		}
+		storageVersion := storage[0]
+
+		strategy := conversionNone
+		if c.Spec.Conversion != nil && len(c.Spec.Conversion.Strategy) > 0 {
+			strategy = c.Spec.Conversion.Strategy
+		}
+
+		for _, v := range c.Spec.Versions {
+			if !v.Served || v.Name == storageVersion {
+				continue
+			}
+			if strategy == conversionNone {
+				versionErrors = append(versionErrors, "CustomResourceDefinition "+name+" serves version "+v.Name+" which differs from storage version "+
+					storageVersion+" but has conversion strategy "+conversionNone)
+			}
+			if !v.Deprecated && version.CompareKubeAwareVersionStrings(storageVersion, v.Name) > 0 {
+				versionErrors = append(versionErrors, "CustomResourceDefinition "+name+" serves version "+v.Name+" which is older than storage version "+
+					storageVersion+" but is not marked deprecated")
+			}
+		}
+
+		if strategy == conversionNone {
+			var old []string
+			for _, v := range c.Status.StoredVersions {
+				if v != storageVersion {
+					old = append(old, v)
+				}
+			}
+			if len(old) > 0 {
+				versionErrors = append(versionErrors, "CustomResourceDefinition "+name+" has objects stored as "+strings.Join(old, ", ")+
+					" as well as storage version "+storageVersion+" but has conversion strategy "+conversionNone)
+			}
+		}
+	}
+	return versionErrors
+}
@@ -0,0 +1,65 @@
+package crdStoredVersions
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateCRDs(t *testing.T) {
+	var tests = []struct {
+		description   string
+		crdJSON       string
+		expectedError string
+	}{
+		{"single version", `{"metadata": {"name": "a.example.com"}, "spec": {"versions": [
+			{"name": "v1", "served": true, "storage": true}]}}`, ""},
+		{"legacy version field", `{"metadata": {"name": "a.example.com"}, "spec": {"version": "v1"}}`, ""},
+		{"no storage version", `{"metadata": {"name": "a.example.com"}, "spec": {"versions": [
+			{"name": "v1", "served": true}]}}`, "exactly one storage version but has none"},
+		{"two storage versions", `{"metadata": {"name": "a.example.com"}, "spec": {"versions": [
+			{"name": "v1", "served": true, "storage": true},
+			{"name": "v2", "served": true, "storage": true}]}}`, "exactly one storage version but has 2: v1, v2"},
+		{"webhook conversion", `{"metadata": {"name": "a.example.com"}, "spec": {"conversion": {"strategy": "Webhook"}, "versions": [
+			{"name": "v1", "served": true, "storage": true},
+			{"name": "v1beta1", "served": true, "deprecated": true}]}}`, ""},
+		{"served version without conversion", `{"metadata": {"name": "a.example.com"}, "spec": {"versions": [
+			{"name": "v1", "served": true, "storage": true},
+			{"name": "v1beta1", "served": true, "deprecated": true}]}}`, "serves version v1beta1 which differs from storage version v1"},
+		{"explicit None conversion", `{"metadata": {"name": "a.example.com"}, "spec": {"conversion": {"strategy": "None"}, "versions": [
+			{"name": "v1", "served": true, "storage": true},
+			{"name": "v1beta1", "served": true, "deprecated": true}]}}`, "but has conversion strategy None"},
+		{"unserved version without conversion", `{"metadata": {"name": "a.example.com"}, "spec": {"versions": [
+			{"name": "v1", "served": true, "storage": true},
+			{"name": "v1beta1", "served": false}]}}`, ""},
+		{"older version not deprecated", `{"metadata": {"name": "a.example.com"}, "spec": {"conversion": {"strategy": "Webhook"}, "versions": [
+			{"name": "v1", "served": true, "storage": true},
+			{"name": "v1beta1", "served": true}]}}`, "older than storage version v1 but is not marked deprecated"},
+		{"newer version not deprecated", `{"metadata": {"name": "a.example.com"}, "spec": {"conversion": {"strategy": "Webhook"}, "versions": [
+			{"name": "v1", "served": true, "storage": true},
+			{"name": "v2", "served": true}]}}`, ""},
+		{"old stored versions without conversion", `{"metadata": {"name": "a.example.com"}, "spec": {"versions": [
+			{"name": "v1", "served": true, "storage": true},
+			{"name": "v1beta1", "served": false}]}, "status": {"storedVersions": ["v1beta1", "v1"]}}`, "has objects stored as v1beta1"},
+	}
+
+	for _, test := range tests {
+		var c crd
+		err := json.Unmarshal([]byte(test.crdJSON), &c)
+		if err != nil {
+			t.Fatal("Test", test.description, "invalid CRD JSON:", err)
+		}
+
+		versionErrors := evaluateCRDs([]crd{c})
+		if len(test.expectedError) == 0 {
+			if len(versionErrors) != 0 {
+				t.Fatal("Test", test.description, "expected no errors but got", versionErrors)
+			}
+			continue
+		}
+		if len(versionErrors) != 1 || !strings.Contains(versionErrors[0], test.expectedError) {
+			t.Fatal("Test", test.description, "expected an error containing", test.expectedError, "but got", versionErrors)
+		}
+		t.Log(test.description, versionErrors)
+	}
+}
@@ -0,0 +1,191 @@
+// Package csrBacklog implements a checker that ensures certificate signing
+// requests are being approved or denied in a timely manner.  A backlog of
+// certificate signing requests can prevent new nodes from joining the
+// cluster.
+package csrBacklog // import "github.com/Comcast/kuberhealthy/pkg/checks/csrBacklog"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Checker validates that the certificate signing request queue is not backlogged
+type Checker struct {
+	Errors           []string
+	BacklogThreshold int
+	PendingThreshold time.Duration
+	client           *kubernetes.Clientset
+	interval         time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reports an error when more than
+// backlogThreshold requests are pending or when any request has been pending
+// for longer than pendingThreshold
+func New(backlogThreshold int, pendingThreshold time.Duration) *Checker {
+	return &Checker{
+		Errors:           []string{},
+		BacklogThreshold: backlogThreshold,
+		PendingThreshold: pendingThreshold,
+	}
+}
+
+// Name returns the name of this checker
+func (cbc *Checker) Name() string {
+	return "CSRBacklogChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (cbc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (cbc *Checker) Interval() time.Duration {
+	if cbc.interval > 0 {
+		return cbc.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (cbc *Checker) SetInterval(d time.Duration) {
+	cbc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Certificate signing
+// requests are cluster scoped.
+func (cbc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{"certificates.k8s.io"}, Resources: []string{"certificatesigningrequests"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (cbc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (cbc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (cbc *Checker) CurrentStatus() (bool, []string) {
+	if len(cbc.Errors) > 0 {
+		return false, cbc.Errors
+	}
+	return true, cbc.Errors
+}
+
+// clearErrors clears all errors
+func (cbc *Checker) clearErrors() {
+	cbc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (cbc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	cbc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := cbc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(cbc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + cbc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(cbc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + cbc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + cbc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists all certificate signing requests and sets errors if the
+// pending requests exceed the configured thresholds
+func (cbc *Checker) doChecks() error {
+
+	csrs, err := cbc.client.CertificatesV1beta1().CertificateSigningRequests().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	backlogErrors := evaluateBacklog(csrs.Items, time.Now(), cbc.BacklogThreshold, cbc.PendingThreshold)
+	if len(backlogErrors) > 0 {
+		for _, e := range backlogErrors {
+			khlog.ForCheck(cbc.Name()).Warningln(e)
+		}
+		cbc.Errors = backlogErrors
+		return nil
+	}
+
+	cbc.clearErrors()
+	return nil
+}
+
+// evaluateBacklog returns error messages when the count of pending requests
+// is above the backlog threshold or the oldest pending request has been
+// pending for longer than the pending threshold
+func evaluateBacklog(csrs []certificatesv1beta1.CertificateSigningRequest, now time.Time, backlogThreshold int, pendingThreshold time.Duration) []string {
+	var backlogErrors []string
+
+	var pendingCount int
+	var oldest *certificatesv1beta1.CertificateSigningRequest
+	for i := range csrs {
+		if !isPending(csrs[i]) {
+			continue
+		}
+		pendingCount++
+		if oldest == nil || csrs[i].CreationTimestamp.Before(&oldest.CreationTimestamp) {
+			oldest = &csrs[i]
+		}
+	}
+
+	if oldest == nil {
+		return backlogErrors
+	}
+
+	oldestAge := now.Sub(oldest.CreationTimestamp.Time).Round(time.Second)
+	if pendingCount > backlogThreshold {
+		backlogErrors = append(backlogErrors, fmt.Sprintf("%d certificate signing requests are pending which exceeds the threshold of %d. The oldest pending request is %s which has been pending for %s",
+			pendingCount, backlogThreshold, oldest.Name, oldestAge))
+	}
+	if oldestAge > pendingThreshold {
+		backlogErrors = append(backlogErrors, fmt.Sprintf("Certificate signing request %s has been pending for %s which exceeds the threshold of %s. %d requests are pending in total",
+			oldest.Name, oldestAge, pendingThreshold, pendingCount))
+	}
+	return backlogErrors
+}
+
+// isPending determines if a certificate signing request has been neither
+// approved nor denied
+func isPending(csr certificatesv1beta1.CertificateSigningRequest) bool {
+	for _, c := range csr.Status.Conditions {
+		if c.Type == certificatesv1beta1.CertificateApproved || c.Type == certificatesv1beta1.CertificateDenied {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package csrBacklog
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makeCSR(name string, created time.Time, conditions ...certificatesv1beta1.RequestConditionType) certificatesv1beta1.CertificateSigningRequest {
+	csr := certificatesv1beta1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(created)},
+	}
+	for _, c := range conditions {
+		csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1beta1.CertificateSigningRequestCondition{Type: c})
+	}
+	return csr
+}
+
+func TestEvaluateBacklog(t *testing.T) {
+	now := time.Now()
+
+	// approved and denied requests are never counted
+	csrs := []certificatesv1beta1.CertificateSigningRequest{
+		makeCSR("approved", now.Add(-time.Hour*5), certificatesv1beta1.CertificateApproved),
+		makeCSR("denied", now.Add(-time.Hour*5), certificatesv1beta1.CertificateDenied),
+		makeCSR("fresh", now.Add(-time.Minute)),
+	}
+	backlogErrors := evaluateBacklog(csrs, now, 10, time.Hour)
+	if len(backlogErrors) != 0 {
+		t.Fatal("Expected no errors but got", backlogErrors)
+	}
+
+	// a single request pending too long
+	csrs = append(csrs, makeCSR("stale", now.Add(-time.Hour*2)))
+	backlogErrors = evaluateBacklog(csrs, now, 10, time.Hour)
+	if len(backlogErrors) != 1 {
+		t.Fatal("Expected one error for a stale request but got", backlogErrors)
+	}
+	t.Log(backlogErrors)
+
+	// a backlog of fresh requests
+	csrs = []certificatesv1beta1.CertificateSigningRequest{}
+	for i := 0; i < 11; i++ {
+		csrs = append(csrs, makeCSR("node-csr-"+strconv.Itoa(i), now.Add(-time.Minute*time.Duration(i))))
+	}
+	backlogErrors = evaluateBacklog(csrs, now, 10, time.Hour)
+	if len(backlogErrors) != 1 {
+		t.Fatal("Expected one error for a backlog but got", backlogErrors)
+	}
+	t.Log(backlogErrors)
+}
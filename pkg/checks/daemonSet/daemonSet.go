@@ -6,6 +6,9 @@ package daemonSet // import "github.com/Comcast/kuberhealthy/pkg/checks/daemonSe
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"os"
 	"strconv"
 	"strings"
@@ -14,10 +17,14 @@ import (
 	log "github.com/sirupsen/logrus"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 
+	"github.com/Comcast/kuberhealthy/pkg/checks/componentStatus"
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
 	apiv1 "k8s.io/api/core/v1"
 	betaapiv1 "k8s.io/api/extensions/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/typed/extensions/v1beta1"
 )
@@ -37,13 +44,24 @@ type Checker struct {
 	DaemonSetDeployed   bool
 	DaemonSetName       string
 	PauseContainerImage string
+	NodeSelector        map[string]string // limits the daemonset to matching nodes when set
+	ImagePullPolicy     apiv1.PullPolicy  // the pull policy of the pause container image, or the Kubernetes default when empty
+	ImagePullSecrets    []apiv1.LocalObjectReference
 	hostname            string
 	tolerations         []apiv1.Toleration
 	client              *kubernetes.Clientset
+	interval            time.Duration // overrides the default interval when set
 }
 
 // New creates a new Checker object
 func New() (*Checker, error) {
+	return NewWithBaseName(daemonSetBaseName)
+}
+
+// NewWithBaseName creates a new Checker object whose daemonset name starts
+// with baseName instead of the default.  This lets other checks deploy
+// their own daemonsets with this checker.
+func NewWithBaseName(baseName string) (*Checker, error) {
 
 	hostname := getHostname()
 	var tolerations []apiv1.Toleration
@@ -51,7 +69,7 @@ func New() (*Checker, error) {
 	testDS := Checker{
 		ErrorMessages:       []string{},
 		Namespace:           namespace,
-		DaemonSetName:       daemonSetBaseName + "-" + hostname + "-" + strconv.Itoa(int(time.Now().Unix())),
+		DaemonSetName:       baseName + "-" + hostname + "-" + strconv.Itoa(int(time.Now().Unix())),
 		hostname:            hostname,
 		PauseContainerImage: "gcr.io/google_containers/pause:0.8.0",
 		tolerations:         tolerations,
@@ -60,22 +78,58 @@ func New() (*Checker, error) {
 	return &testDS, nil
 }
 
+// NewWithNodeSelector creates a new Checker object that deploys its
+// daemonset only to nodes matching the node selector, such as
+// "node-pool=gpu" or "node-pool=gpu,lifecycle=spot".  The node selector is
+// included in the name of the check.
+func NewWithNodeSelector(selector string) (*Checker, error) {
+	nodeSelector, err := labels.ConvertSelectorToLabelsMap(selector)
+	if err != nil {
+		return nil, errors.New("Invalid daemonset node selector " + selector + ": " + err.Error())
+	}
+	if len(nodeSelector) == 0 {
+		return nil, errors.New("The daemonset node selector must not be empty")
+	}
+
+	dsc, err := New()
+	if err != nil {
+		return nil, err
+	}
+	dsc.NodeSelector = nodeSelector
+
+	// daemonsets of checks created at the same time are told apart by a hash
+	// of their node selector, which can not be used in a name itself
+	h := fnv.New32a()
+	h.Write([]byte(dsc.nodeSelectorString()))
+	dsc.DaemonSetName = dsc.DaemonSetName + "-" + fmt.Sprintf("%08x", h.Sum32())
+	return dsc, nil
+}
+
+// nodeSelectorString returns the node selector in key=value form, or an
+// empty string when the checker has no node selector
+func (dsc *Checker) nodeSelectorString() string {
+	if len(dsc.NodeSelector) == 0 {
+		return ""
+	}
+	return labels.SelectorFromSet(dsc.NodeSelector).String()
+}
+
 // generateDaemonSetSpec generates a daemon set spec to deploy into the cluster
 func (dsc *Checker) generateDaemonSetSpec() {
 
 	terminationGracePeriod := int64(1)
 	runAsUser := int64(1000)
-	log.Debug("Running daemon set as user 1000.")
+	khlog.ForCheck(dsc.Name()).Debug("Running daemon set as user 1000.")
 
 	// find all the taints in the cluster and create a toleration for each
 	var err error
-	dsc.tolerations, err = findAllUniqueTolerations(dsc.client)
+	dsc.tolerations, err = findAllUniqueTolerations(dsc.client, khlog.ForCheck(dsc.Name()))
 	if err != nil {
-		log.Warningln("Unable to generate list of pod scheduling tolerations", err)
+		khlog.ForCheck(dsc.Name()).Warningln("Unable to generate list of pod scheduling tolerations", err)
 	}
 
 	//create the DS object
-	log.Infoln("Generating daemon set kubernetes spec.")
+	khlog.ForCheck(dsc.Name()).Infoln("Generating daemon set kubernetes spec.")
 	dsc.DaemonSet = &betaapiv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: dsc.DaemonSetName,
@@ -106,10 +160,13 @@ func (dsc *Checker) generateDaemonSetSpec() {
 				Spec: apiv1.PodSpec{
 					TerminationGracePeriodSeconds: &terminationGracePeriod,
 					Tolerations:                   []apiv1.Toleration{},
+					NodeSelector:                  dsc.NodeSelector,
+					ImagePullSecrets:              dsc.ImagePullSecrets,
 					Containers: []apiv1.Container{
 						{
-							Name:  "sleep",
-							Image: dsc.PauseContainerImage,
+							Name:            "sleep",
+							Image:           dsc.PauseContainerImage,
+							ImagePullPolicy: dsc.ImagePullPolicy,
 							SecurityContext: &apiv1.SecurityContext{
 								RunAsUser: &runAsUser,
 							},
@@ -128,11 +185,14 @@ func (dsc *Checker) generateDaemonSetSpec() {
 
 	// Add our generated list of tolerations or any the user input via flag
 	dsc.DaemonSet.Spec.Template.Spec.Tolerations = append(dsc.DaemonSet.Spec.Template.Spec.Tolerations, dsc.tolerations...)
-	log.Infoln("Deploying daemon set with tolerations: ", dsc.DaemonSet.Spec.Template.Spec.Tolerations)
+	khlog.ForCheck(dsc.Name()).Infoln("Deploying daemon set with tolerations: ", dsc.DaemonSet.Spec.Template.Spec.Tolerations)
 }
 
 // Name returns the name of this checker
 func (dsc *Checker) Name() string {
+	if len(dsc.NodeSelector) > 0 {
+		return "DaemonSetChecker node selector " + dsc.nodeSelectorString()
+	}
 	return "DaemonSetChecker"
 }
 
@@ -143,14 +203,43 @@ func (dsc *Checker) CheckNamespace() string {
 
 // Interval returns the interval at which this check runs
 func (dsc *Checker) Interval() time.Duration {
+	if dsc.interval > 0 {
+		return dsc.interval
+	}
 	return time.Minute * 15
 }
 
+// SetInterval overrides the interval at which this check runs
+func (dsc *Checker) SetInterval(d time.Duration) {
+	dsc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Nodes are listed to
+// find the nodes the daemonset should be scheduled to, which requires
+// cluster scoped access.
+func (dsc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		dsc.Namespace: {
+			{APIGroups: []string{"extensions"}, Resources: []string{"daemonsets"}, Verbs: []string{"create", "delete", "deletecollection", "get", "list"}},
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"delete", "get", "list"}},
+		},
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
 // Timeout returns the maximum run time for this check before it times out
 func (dsc *Checker) Timeout() time.Duration {
 	return time.Minute * 10
 }
 
+// DependsOn returns the component status checker.  Daemonset pods are not
+// created or scheduled while the controller manager or scheduler is down.
+func (dsc *Checker) DependsOn() []string {
+	return []string{componentStatus.CheckName}
+}
+
 // Shutdown signals the DS to begin a cleanup
 func (dsc *Checker) Shutdown() error {
 	dsc.shuttingDown = true
@@ -171,7 +260,7 @@ func (dsc *Checker) Shutdown() error {
 		dsc.waitForPodRemoval(ctx)
 	}
 
-	log.Infoln(dsc.Name(), "Daemonset "+dsc.DaemonSetName+" ready for shutdown.")
+	khlog.ForCheck(dsc.Name()).Infoln("Daemonset " + dsc.DaemonSetName + " ready for shutdown.")
 	return nil
 
 }
@@ -179,7 +268,7 @@ func (dsc *Checker) Shutdown() error {
 // findAllUniqueTolerations returns a list of all taints present on any node group in the cluster
 // this is exportable because of a chicken/egg.  We need to determine the taints before
 // we construct the testDS in New() and pass them into New()
-func findAllUniqueTolerations(client *kubernetes.Clientset) ([]apiv1.Toleration, error) {
+func findAllUniqueTolerations(client *kubernetes.Clientset, checkLog *log.Entry) ([]apiv1.Toleration, error) {
 
 	var uniqueTolerations []apiv1.Toleration
 
@@ -188,7 +277,7 @@ func findAllUniqueTolerations(client *kubernetes.Clientset) ([]apiv1.Toleration,
 	if err != nil {
 		return uniqueTolerations, err
 	}
-	log.Infoln("Searching for unique taints on the cluster.")
+	checkLog.Infoln("Searching for unique taints on the cluster.")
 	// this keeps track of the unique taint values
 	keys := make(map[string]bool)
 	// get a list of all taints
@@ -203,7 +292,7 @@ func findAllUniqueTolerations(client *kubernetes.Clientset) ([]apiv1.Toleration,
 			}
 		}
 	}
-	log.Infoln("Found taints to tolerate:", uniqueTolerations)
+	checkLog.Infoln("Found taints to tolerate:", uniqueTolerations)
 	return uniqueTolerations, nil
 }
 
@@ -241,24 +330,24 @@ func (dsc *Checker) cleanupOrphans() error {
 func (dsc *Checker) cleanupOrphanedPods() error {
 	pods, err := dsc.getAllPods()
 	if err != nil {
-		log.Errorln("Error fetching pods:", err)
+		khlog.ForCheck(dsc.Name()).Errorln("Error fetching pods:", err)
 		return err
 	}
 
 	// loop on all the daemonsets and ensure that daemonset's creating pod exists.
 	// if the creating pod does not exist, then we delete the daemonset.
 	for _, p := range pods {
-		log.Infoln("Checking if pod is orphaned:", p.Name, "creatingInstance:", p.Labels["creatingInstance"])
+		khlog.ForCheck(dsc.Name()).Infoln("Checking if pod is orphaned:", p.Name, "creatingInstance:", p.Labels["creatingInstance"])
 
 		// fetch the creatingInstance label
 		creatingDSInstance := p.Labels["app"]
 
 		// if there isnt a creatingInstance label, we assume its an old generation and remove it.
 		if len(creatingDSInstance) == 0 {
-			log.Warningln("Unable to find app label on pod", p.Name, "assuming orphaned and removing!")
+			khlog.ForCheck(dsc.Name()).Warningln("Unable to find app label on pod", p.Name, "assuming orphaned and removing!")
 			err := dsc.deletePod(p.Name)
 			if err != nil {
-				log.Warningln("error when removing orphaned pod due to missing label", p.Name+": ", err)
+				khlog.ForCheck(dsc.Name()).Warningln("error when removing orphaned pod due to missing label", p.Name+": ", err)
 			}
 			continue
 		}
@@ -266,16 +355,16 @@ func (dsc *Checker) cleanupOrphanedPods() error {
 		// check if the creatingInstance exists
 		exists := dsc.checkIfDSExists(creatingDSInstance)
 		if err != nil {
-			log.Errorln("error checking if kuberhealthy daemonset exists:", err)
+			khlog.ForCheck(dsc.Name()).Errorln("error checking if kuberhealthy daemonset exists:", err)
 			return err
 		}
 
 		// if the owning kuberhealthy pod of the DS does not exist, then we delete the daemonset
 		if !exists {
-			log.Infoln("Removing orphaned pod", p.Name, "because kuberhealthy ds", creatingDSInstance, "does not exist")
+			khlog.ForCheck(dsc.Name()).Infoln("Removing orphaned pod", p.Name, "because kuberhealthy ds", creatingDSInstance, "does not exist")
 			err := dsc.deletePod(p.Name)
 			if err != nil {
-				log.Warningln("error when removing orphaned pod", p.Name+": ", err)
+				khlog.ForCheck(dsc.Name()).Warningln("error when removing orphaned pod", p.Name+": ", err)
 				return err
 			}
 		}
@@ -289,24 +378,24 @@ func (dsc *Checker) cleanupOrphanedDaemonsets() error {
 
 	daemonSets, err := dsc.getAllDaemonsets()
 	if err != nil {
-		log.Errorln("Error fetching daemonsets for cleanup:", err)
+		khlog.ForCheck(dsc.Name()).Errorln("Error fetching daemonsets for cleanup:", err)
 		return err
 	}
 
 	// loop on all the daemonsets and ensure that daemonset's creating pod exists.
 	// if the creating pod does not exist, then we delete the daemonset.
 	for _, ds := range daemonSets {
-		log.Infoln("Checking if daemonset is orphaned:", ds.Name, "creatingInstance:", ds.Labels["creatingInstance"])
+		khlog.ForCheck(dsc.Name()).Infoln("Checking if daemonset is orphaned:", ds.Name, "creatingInstance:", ds.Labels["creatingInstance"])
 
 		// fetch the creatingInstance label
 		creatingInstance := ds.Labels["creatingInstance"]
 
 		// if there isnt a creatingInstance label, we assume its an old generation and remove it.
 		if len(creatingInstance) == 0 {
-			log.Warningln("Unable to find hostname with creatingInstance label on ds", ds.Name, "assuming orphaned and removing!")
+			khlog.ForCheck(dsc.Name()).Warningln("Unable to find hostname with creatingInstance label on ds", ds.Name, "assuming orphaned and removing!")
 			err := dsc.deleteDS(ds.Name)
 			if err != nil {
-				log.Warningln("error when removing orphaned daemonset due to missing label", ds.Name+": ", err)
+				khlog.ForCheck(dsc.Name()).Warningln("error when removing orphaned daemonset due to missing label", ds.Name+": ", err)
 				return err
 			}
 			continue
@@ -315,16 +404,16 @@ func (dsc *Checker) cleanupOrphanedDaemonsets() error {
 		// check if the creatingInstance exists
 		exists := dsc.checkIfPodExists(creatingInstance)
 		if err != nil {
-			log.Errorln("error checking if kuberhealthy ds exists:", err)
+			khlog.ForCheck(dsc.Name()).Errorln("error checking if kuberhealthy ds exists:", err)
 			return err
 		}
 
 		// if the owning kuberhealthy pod of the DS does not exist, then we delete the daemonset
 		if !exists {
-			log.Infoln("Removing orphaned daemonset", ds.Name, "because creating kuberhealthy instance", creatingInstance, "does not exist")
+			khlog.ForCheck(dsc.Name()).Infoln("Removing orphaned daemonset", ds.Name, "because creating kuberhealthy instance", creatingInstance, "does not exist")
 			err := dsc.deleteDS(ds.Name)
 			if err != nil {
-				log.Warningln("error when removing orphaned daemonset", ds.Name+": ", err)
+				khlog.ForCheck(dsc.Name()).Warningln("error when removing orphaned daemonset", ds.Name+": ", err)
 				return err
 			}
 		}
@@ -384,7 +473,7 @@ func (dsc *Checker) getAllPods() ([]apiv1.Pod, error) {
 			LabelSelector: "source=kuberhealthy",
 		})
 		if err != nil {
-			log.Warningln("Unable to get all pods:", err)
+			khlog.ForCheck(dsc.Name()).Warningln("Unable to get all pods:", err)
 		}
 		cont = podList.Continue
 
@@ -418,7 +507,7 @@ func (dsc *Checker) getAllDaemonsets() ([]betaapiv1.DaemonSet, error) {
 			LabelSelector: "source=kuberhealthy",
 		})
 		if err != nil {
-			log.Warningln("Unable to get all Daemon Sets:", err)
+			khlog.ForCheck(dsc.Name()).Warningln("Unable to get all Daemon Sets:", err)
 		}
 		cont = dsList.Continue
 
@@ -437,18 +526,21 @@ func (dsc *Checker) getAllDaemonsets() ([]betaapiv1.DaemonSet, error) {
 }
 
 // Run implements the entrypoint for check execution
-func (dsc *Checker) Run(client *kubernetes.Clientset) error {
+func (dsc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
 
-	// make a context for this run
-	ctx, cancelCtx := context.WithCancel(context.Background())
+	// make a context for this run that is also cancelled when the context
+	// of the check is cancelled
+	runCtx, cancelCtx := context.WithCancel(ctx)
 
-	doneChan := make(chan error)
+	// buffered so that doChecks can always send its result, even after Run
+	// stopped waiting for it
+	doneChan := make(chan error, 1)
 
 	dsc.client = client
 
 	// run the check in a goroutine and notify the doneChan when completed
 	go func(doneChan chan error) {
-		err := dsc.doChecks(ctx)
+		err := dsc.doChecks(runCtx)
 		doneChan <- err
 	}(doneChan)
 
@@ -459,13 +551,20 @@ func (dsc *Checker) Run(client *kubernetes.Clientset) error {
 		cancelCtx() // cancel context
 		errorMessage := "Failed to complete checks for " + dsc.Name() + " in time!  Next run came up but check was still running."
 		dsc.ErrorMessages = []string{errorMessage}
-		log.Errorln(dsc.Name(), errorMessage)
+		khlog.ForCheck(dsc.Name()).Errorln(errorMessage)
+		dsc.cleanUpCancelledRun(doneChan)
 	case <-time.After(dsc.Timeout()):
 		// The check has timed out after its specified timeout period
 		cancelCtx() // cancel context
 		errorMessage := "Failed to complete checks for " + dsc.Name() + " in time!  Timeout was reached."
 		dsc.ErrorMessages = []string{errorMessage}
-		log.Errorln(dsc.Name(), errorMessage)
+		khlog.ForCheck(dsc.Name()).Errorln(errorMessage)
+		dsc.cleanUpCancelledRun(doneChan)
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		cancelCtx() // cancel context
+		dsc.cleanUpCancelledRun(doneChan)
+		return errors.New("Failed to complete checks for " + dsc.Name() + ".  The check was cancelled.")
 	case err := <-doneChan:
 		cancelCtx()
 		return err
@@ -474,6 +573,24 @@ func (dsc *Checker) Run(client *kubernetes.Clientset) error {
 	return nil
 }
 
+// cleanUpCancelledRun waits a bounded time for doChecks to stop after its
+// context was cancelled, then removes the daemonset it may have left behind
+func (dsc *Checker) cleanUpCancelledRun(doneChan chan error) {
+	select {
+	case <-doneChan:
+	case <-time.After(time.Second * 30):
+		khlog.ForCheck(dsc.Name()).Warningln("Checks did not stop within 30 seconds of being cancelled.")
+	}
+
+	// the context of the run is cancelled, so cleanup gets its own
+	ctx, cancelCtx := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancelCtx()
+	err := dsc.cleanUp(ctx)
+	if err != nil {
+		khlog.ForCheck(dsc.Name()).Errorln("Error removing daemonset "+dsc.DaemonSetName+" after the check was cancelled:", err)
+	}
+}
+
 // doChecks actually runs checking procedures
 func (dsc *Checker) doChecks(ctx context.Context) error {
 
@@ -522,7 +639,7 @@ func (dsc *Checker) cleanUp(ctx context.Context) error {
 
 	// if a DS exists, then clean it up
 	if ds.Name != "" {
-		log.Warningln("Rogue or leftover daemonset.  Removing before running checks")
+		khlog.ForCheck(dsc.Name()).Warningln("Rogue or leftover daemonset.  Removing before running checks")
 
 		// if there wasnt an error, the DS exists and we need to clean it up.
 		err = dsc.remove()
@@ -602,7 +719,7 @@ func (dsc *Checker) doDeploy(ctx context.Context) error {
 	dsc.DaemonSetDeployed = true
 	err := dsc.deploy()
 	if err != nil {
-		log.Error("Something went wrong with daemonset deployment, cleaning things up...")
+		khlog.ForCheck(dsc.Name()).Error("Something went wrong with daemonset deployment, cleaning things up...")
 		dsc.doRemove(ctx)
 		return err
 	}
@@ -612,6 +729,64 @@ func (dsc *Checker) doDeploy(ctx context.Context) error {
 	return err
 }
 
+// Deploy removes any leftover daemonset of the checker and creates a new
+// one without waiting for its pods to come online, for checks that inspect
+// the pods of the daemonset themselves.  The daemonset is removed again if
+// it could not be created.
+func (dsc *Checker) Deploy(ctx context.Context, client *kubernetes.Clientset) error {
+	dsc.client = client
+	dsc.cleanUp(ctx)
+
+	err := dsc.deploy()
+	if err != nil {
+		dsc.doRemove(ctx)
+	}
+	return err
+}
+
+// Remove removes the daemonset from the cluster and waits for its pods to
+// be removed
+func (dsc *Checker) Remove(ctx context.Context) error {
+	return dsc.doRemove(ctx)
+}
+
+// CleanUp removes the daemonset if it exists and waits for its pods to be
+// removed
+func (dsc *Checker) CleanUp(ctx context.Context) error {
+	return dsc.cleanUp(ctx)
+}
+
+// Pods returns the pods of the daemonset
+func (dsc *Checker) Pods() ([]apiv1.Pod, error) {
+	pods, err := dsc.client.CoreV1().Pods(dsc.Namespace).List(metav1.ListOptions{
+		IncludeUninitialized: true,
+		LabelSelector:        "app=" + dsc.DaemonSetName + ",source=kuberhealthy",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// Nodes returns the nodes the daemonset should have a pod on.  These are
+// the nodes that match the node selector and whose taints are tolerated.
+func (dsc *Checker) Nodes() ([]apiv1.Node, error) {
+	nodes, err := dsc.client.CoreV1().Nodes().List(metav1.ListOptions{
+		LabelSelector: dsc.nodeSelectorString(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var scheduled []apiv1.Node
+	for _, n := range nodes.Items {
+		if taintsAreTolerated(n.Spec.Taints, dsc.tolerations) {
+			scheduled = append(scheduled, n)
+		}
+	}
+	return scheduled, nil
+}
+
 // doRemove remotes the daemonset from the cluster
 func (dsc *Checker) doRemove(ctx context.Context) error {
 	// delete ds
@@ -642,14 +817,14 @@ func (dsc *Checker) waitForPodsToComeOnline(ctx context.Context) error {
 	for {
 		ctxErr := ctx.Err()
 		if ctxErr != nil {
-			log.Infoln(dsc.Name(), "Nodes which were unable to schedule before context was cancelled:", nodesMissingDSPod)
+			khlog.ForCheck(dsc.Name()).Infoln("Nodes which were unable to schedule before context was cancelled:", nodesMissingDSPod)
 			return ctxErr
 		}
 		time.Sleep(time.Second)
 
 		// if we need to shut down, stop waiting entirely
 		if dsc.shuttingDown {
-			log.Infoln(dsc.Name(), "Nodes which were unable to schedule before shutdown signal was received:", nodesMissingDSPod)
+			khlog.ForCheck(dsc.Name()).Infoln("Nodes which were unable to schedule before shutdown signal was received:", nodesMissingDSPod)
 			return nil
 		}
 
@@ -659,7 +834,7 @@ func (dsc *Checker) waitForPodsToComeOnline(ctx context.Context) error {
 		// find nodes missing pods from this daemonset
 		nodesMissingDSPod, err := dsc.getNodesMissingDSPod()
 		if err != nil {
-			log.Warningln(dsc.Name(), "Error determining which node was unschedulable. Retrying.", err)
+			khlog.ForCheck(dsc.Name()).Warningln("Error determining which node was unschedulable. Retrying.", err)
 			continue
 		}
 
@@ -672,9 +847,9 @@ func (dsc *Checker) waitForPodsToComeOnline(ctx context.Context) error {
 		readySeconds := 5
 		if len(nodesMissingDSPod) <= 0 {
 			counter++
-			log.Infoln("All daemonset pods have been ready for", counter, "/", readySeconds, "seconds.")
+			khlog.ForCheck(dsc.Name()).Infoln("All daemonset pods have been ready for", counter, "/", readySeconds, "seconds.")
 			if counter >= readySeconds {
-				log.Infoln(dsc.Name(), "Daemonset "+dsc.DaemonSetName+" done deploying pods.")
+				khlog.ForCheck(dsc.Name()).Infoln("Daemonset " + dsc.DaemonSetName + " done deploying pods.")
 				return nil
 			}
 			continue
@@ -682,11 +857,11 @@ func (dsc *Checker) waitForPodsToComeOnline(ctx context.Context) error {
 		// else if we've started counting up but there is a DS pod that went unready
 		// reset the counter
 		if counter > 0 {
-			log.Infoln(dsc.Name(), "Daemonset "+dsc.DaemonSetName+" was ready for", counter, "out of,", readySeconds, "seconds but has left the ready state. Restarting", readySeconds, "second timer.")
+			khlog.ForCheck(dsc.Name()).Infoln("Daemonset "+dsc.DaemonSetName+" was ready for", counter, "out of,", readySeconds, "seconds but has left the ready state. Restarting", readySeconds, "second timer.")
 			counter = 0
 		}
 		// If the counter isnt iterating up or being reset, we are still waiting for pods to come online
-		log.Infoln(dsc.Name(), "Daemonset check waiting for", len(nodesMissingDSPod), "pods to come up on nodes", nodesMissingDSPod)
+		khlog.ForCheck(dsc.Name()).Infoln("Daemonset check waiting for", len(nodesMissingDSPod), "pods to come up on nodes", nodesMissingDSPod)
 	}
 }
 
@@ -696,8 +871,11 @@ func (dsc *Checker) getNodesMissingDSPod() ([]string, error) {
 	// nodesMissingDSPods holds the final list of nodes missing pods
 	var nodesMissingDSPods []string
 
-	// get a list of all the nodes in the cluster
-	nodes, err := dsc.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	// get a list of all the nodes in the cluster that the daemonset is
+	// deployed to
+	nodes, err := dsc.client.CoreV1().Nodes().List(metav1.ListOptions{
+		LabelSelector: dsc.nodeSelectorString(),
+	})
 	if err != nil {
 		return nodesMissingDSPods, err
 	}
@@ -783,7 +961,7 @@ func (dsc *Checker) deploy() error {
 	daemonSetClient := dsc.getDaemonSetClient()
 	_, err := daemonSetClient.Create(dsc.DaemonSet)
 	if err != nil {
-		log.Error("Failed to create daemon set:", err)
+		khlog.ForCheck(dsc.Name()).Error("Failed to create daemon set:", err)
 	}
 	dsc.DaemonSetDeployed = true
 	return err
@@ -801,26 +979,26 @@ func (dsc *Checker) remove() error {
 	if err != nil {
 		return err
 	}
-	log.Infoln(dsc.Name(), "removing", len(pods.Items), "daemonset pods")
+	khlog.ForCheck(dsc.Name()).Infoln("removing", len(pods.Items), "daemonset pods")
 
 	// delete the daemonset
-	log.Infoln(dsc.Name(), "removing daemonset")
+	khlog.ForCheck(dsc.Name()).Infoln("removing daemonset")
 	daemonSetClient := dsc.getDaemonSetClient()
 	err = daemonSetClient.Delete(dsc.DaemonSetName, &metav1.DeleteOptions{})
 	if err != nil {
-		log.Error("Failed to delete daemonset:", err)
+		khlog.ForCheck(dsc.Name()).Error("Failed to delete daemonset:", err)
 		return err
 	}
 
 	// issue a delete to every pod. removing the DS alone does not ensure all
 	// pods are removed
-	log.Infoln(dsc.Name(), "removing daemonset pods")
+	khlog.ForCheck(dsc.Name()).Infoln("removing daemonset pods")
 	err = podsClient.DeleteCollection(&metav1.DeleteOptions{}, metav1.ListOptions{
 		IncludeUninitialized: true,
 		LabelSelector:        "app=" + dsc.DaemonSetName + ",source=kuberhealthy",
 	})
 	if err != nil {
-		log.Error("Failed to delete daemonset pods:", err)
+		khlog.ForCheck(dsc.Name()).Error("Failed to delete daemonset pods:", err)
 		return err
 	}
 	dsc.DaemonSetDeployed = false
@@ -853,13 +1031,13 @@ func (dsc *Checker) waitForPodRemoval(ctx context.Context) error {
 			return err
 		}
 
-		log.Infoln(dsc.Name(), "using LabelSelector: app="+dsc.DaemonSetName+",source=kuberhealthy")
+		khlog.ForCheck(dsc.Name()).Infoln("using LabelSelector: app=" + dsc.DaemonSetName + ",source=kuberhealthy")
 
 		// if the delete ticker has ticked, then issue a repeat request
 		// for pods to be deleted.  See kuberhealthy issue #74
 		select {
 		case <-deleteTicker.C:
-			log.Infoln(dsc.Name(), "Re-issuing a pod delete command for daemonset checkers.")
+			khlog.ForCheck(dsc.Name()).Infoln("Re-issuing a pod delete command for daemonset checkers.")
 			err = podsClient.DeleteCollection(&metav1.DeleteOptions{}, metav1.ListOptions{
 				IncludeUninitialized: true,
 				LabelSelector:        "app=" + dsc.DaemonSetName + ",source=kuberhealthy",
@@ -871,13 +1049,13 @@ func (dsc *Checker) waitForPodRemoval(ctx context.Context) error {
 		}
 
 		// check all pods for any kuberhealthy test daemonset pods that still exist
-		log.Infoln(dsc.Name(), "Daemonset check waiting for", len(pods.Items), "pods to delete")
+		khlog.ForCheck(dsc.Name()).Infoln("Daemonset check waiting for", len(pods.Items), "pods to delete")
 		for _, p := range pods.Items {
-			log.Infoln(dsc.Name(), "Test daemonset pod is still removing:", p.Namespace, p.Name, "on node", p.Spec.NodeName)
+			khlog.ForCheck(dsc.Name()).Infoln("Test daemonset pod is still removing:", p.Namespace, p.Name, "on node", p.Spec.NodeName)
 		}
 
 		if len(pods.Items) == 0 {
-			log.Infoln(dsc.Name(), "Test daemonset has finished removing pods")
+			khlog.ForCheck(dsc.Name()).Infoln("Test daemonset has finished removing pods")
 			return nil
 		}
 		time.Sleep(time.Second * 1)
@@ -887,6 +1065,6 @@ func (dsc *Checker) waitForPodRemoval(ctx context.Context) error {
 
 // getDaemonSetClient returns a daemon set client, useful for interacting with daemonsets
 func (dsc *Checker) getDaemonSetClient() v1beta1.DaemonSetInterface {
-	log.Debug("Creating Daemonset client.")
+	khlog.ForCheck(dsc.Name()).Debug("Creating Daemonset client.")
 	return dsc.client.ExtensionsV1beta1().DaemonSets(dsc.Namespace)
 }
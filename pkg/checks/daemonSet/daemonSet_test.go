@@ -1,6 +1,7 @@
 package daemonSet
 
 import (
+	"context"
 	"strconv"
 	"testing"
 	"time"
@@ -57,6 +58,53 @@ func TestPauseContainerOverride(t *testing.T) {
 	}
 }
 
+func TestSetInterval(t *testing.T) {
+	dsc, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dsc.Interval() != time.Minute*15 {
+		t.Fatal("Default interval is an unexpected value, actual value:", dsc.Interval())
+	}
+
+	dsc.SetInterval(time.Hour)
+	if dsc.Interval() != time.Hour {
+		t.Fatal("Overridden interval is not set or an unexpected value, actual value:", dsc.Interval())
+	}
+}
+
+func TestNewWithNodeSelector(t *testing.T) {
+	dsc, err := NewWithNodeSelector("node-pool=gpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dsc.Name() != "DaemonSetChecker node selector node-pool=gpu" {
+		t.Fatal("Unexpected check name:", dsc.Name())
+	}
+	if dsc.NodeSelector["node-pool"] != "gpu" {
+		t.Fatal("Unexpected node selector:", dsc.NodeSelector)
+	}
+
+	// checkers for different node pools created at the same time must not
+	// share a daemonset name
+	spot, err := NewWithNodeSelector("node-pool=spot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spot.DaemonSetName == dsc.DaemonSetName {
+		t.Fatal("Expected unique daemonset names but both were", dsc.DaemonSetName)
+	}
+
+	_, err = NewWithNodeSelector("node-pool in (gpu)")
+	if err == nil {
+		t.Fatal("Expected an error for a node selector that is not key=value")
+	}
+	_, err = NewWithNodeSelector("")
+	if err == nil {
+		t.Fatal("Expected an error for an empty node selector")
+	}
+}
+
 func TestGetAllDaemonsets(t *testing.T) {
 	checker, err := New()
 	if err != nil {
@@ -83,7 +131,7 @@ func TestChecker(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = checker.Run(client)
+	err = checker.Run(context.Background(), client)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,266 @@
+// Package daemonSetImage implements a checker that ensures the pods of each
+// DaemonSet run the same container images.  A partial rollout, or a mutable
+// tag that was pushed again while nodes pulled it, leaves some nodes running
+// a different image than the rest of the DaemonSet.
+package daemonSetImage // import "github.com/Comcast/kuberhealthy/pkg/checks/daemonSetImage"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Checker validates that the pods of each DaemonSet run the same images
+type Checker struct {
+	Errors     []string
+	Namespaces []string
+	client     *kubernetes.Clientset
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied
+func New(namespaces []string) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		Errors:     []string{},
+		Namespaces: namespaces,
+	}
+}
+
+// Name returns the name of this checker
+func (dic *Checker) Name() string {
+	return "DaemonSetImageChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (dic *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (dic *Checker) Interval() time.Duration {
+	if dic.interval > 0 {
+		return dic.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (dic *Checker) SetInterval(d time.Duration) {
+	dic.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to compare daemonset
+// images with those of their pods in the checked namespaces
+func (dic *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range dic.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{"apps"}, Resources: []string{"daemonsets"}, Verbs: []string{"list"}},
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		}
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (dic *Checker) Timeout() time.Duration {
+	return time.Minute * 2
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (dic *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (dic *Checker) CurrentStatus() (bool, []string) {
+	if len(dic.Errors) > 0 {
+		return false, dic.Errors
+	}
+	return true, dic.Errors
+}
+
+// clearErrors clears all errors
+func (dic *Checker) clearErrors() {
+	dic.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (dic *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	dic.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := dic.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(dic.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + dic.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(dic.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + dic.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + dic.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists DaemonSets and pods and sets an error for every DaemonSet
+// pod running a different image than the majority of its DaemonSet
+func (dic *Checker) doChecks() error {
+
+	var imageErrors []string
+	for _, ns := range dic.Namespaces {
+		daemonSets, err := dic.client.AppsV1().DaemonSets(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return errors.New("Error listing daemon sets: " + err.Error())
+		}
+		pods, err := dic.client.CoreV1().Pods(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return errors.New("Error listing pods: " + err.Error())
+		}
+		imageErrors = append(imageErrors, evaluateDaemonSets(daemonSets.Items, pods.Items)...)
+	}
+
+	if len(imageErrors) > 0 {
+		for _, e := range imageErrors {
+			khlog.ForCheck(dic.Name()).Warningln(e)
+		}
+		dic.Errors = imageErrors
+		return nil
+	}
+
+	dic.clearErrors()
+	return nil
+}
+
+// evaluateDaemonSets returns an error for every DaemonSet pod whose
+// container runs a different image than the majority of the DaemonSet's
+// pods.  Images are compared by digest when the runtime reports one.
+func evaluateDaemonSets(daemonSets []appsv1.DaemonSet, pods []apiv1.Pod) []string {
+	var imageErrors []string
+
+	// group the running pods by the DaemonSet that controls them
+	owned := make(map[string][]apiv1.Pod)
+	for _, p := range pods {
+		if p.DeletionTimestamp != nil || p.Status.Phase != apiv1.PodRunning {
+			continue
+		}
+		owner := metav1.GetControllerOf(&p)
+		if owner == nil || owner.Kind != "DaemonSet" {
+			continue
+		}
+		owned[string(owner.UID)] = append(owned[string(owner.UID)], p)
+	}
+
+	sort.Slice(daemonSets, func(i, j int) bool {
+		return daemonSets[i].Namespace+"/"+daemonSets[i].Name < daemonSets[j].Namespace+"/"+daemonSets[j].Name
+	})
+	for _, ds := range daemonSets {
+		dsPods := owned[string(ds.UID)]
+		sort.Slice(dsPods, func(i, j int) bool {
+			return dsPods[i].Name < dsPods[j].Name
+		})
+
+		for _, c := range ds.Spec.Template.Spec.Containers {
+			// the image each pod runs for the container
+			images := make(map[string]string)
+			counts := make(map[string]int)
+			for _, p := range dsPods {
+				image, ok := runningImage(p, c.Name)
+				if !ok {
+					continue
+				}
+				images[p.Name] = image
+				counts[image]++
+			}
+			if len(counts) < 2 {
+				continue
+			}
+
+			majority := majorityImage(counts, c.Image)
+			for _, p := range dsPods {
+				image, ok := images[p.Name]
+				if !ok || image == majority {
+					continue
+				}
+				imageErrors = append(imageErrors, "DaemonSet "+ds.Namespace+"/"+ds.Name+" rollout is inconsistent: pod "+p.Name+" on node "+p.Spec.NodeName+
+					" runs image "+image+" in container "+c.Name+" while "+strconv.Itoa(counts[majority])+" of "+strconv.Itoa(len(images))+" pods run "+majority)
+			}
+		}
+	}
+	return imageErrors
+}
+
+// runningImage returns the image a container of a pod is running.  The image
+// is identified by its digest when the runtime reports an image ID with one,
+// and by the image name otherwise.
+func runningImage(p apiv1.Pod, container string) (string, bool) {
+	for _, s := range p.Status.ContainerStatuses {
+		if s.Name != container {
+			continue
+		}
+		if i := strings.LastIndex(s.ImageID, "@"); i >= 0 {
+			return imageName(s.Image) + "@" + s.ImageID[i+1:], true
+		}
+		if len(s.Image) == 0 {
+			return "", false
+		}
+		return s.Image, true
+	}
+	return "", false
+}
+
+// majorityImage returns the image run by the most pods.  Ties are broken in
+// favor of the image in the pod template, then by name.
+func majorityImage(counts map[string]int, templateImage string) string {
+	var images []string
+	for image := range counts {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	var majority string
+	for _, image := range images {
+		if len(majority) == 0 || counts[image] > counts[majority] {
+			majority = image
+			continue
+		}
+		if counts[image] == counts[majority] && imageName(image) == templateImage && imageName(majority) != templateImage {
+			majority = image
+		}
+	}
+	return majority
+}
+
+// imageName strips the digest from an image
+func imageName(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i]
+	}
+	return image
+}
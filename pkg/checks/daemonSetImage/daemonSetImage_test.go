@@ -0,0 +1,116 @@
+package daemonSetImage
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	digestA = "sha256:aaaa"
+	digestB = "sha256:bbbb"
+)
+
+func makeDaemonSet(image string) appsv1.DaemonSet {
+	return appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "kube-proxy", UID: types.UID("ds-uid")},
+		Spec: appsv1.DaemonSetSpec{
+			Template: apiv1.PodTemplateSpec{
+				Spec: apiv1.PodSpec{Containers: []apiv1.Container{{Name: "kube-proxy", Image: image}}},
+			},
+		},
+	}
+}
+
+func makePod(name string, image string, digest string) apiv1.Pod {
+	controller := true
+	imageID := ""
+	if len(digest) > 0 {
+		imageID = "docker-pullable://k8s.gcr.io/kube-proxy@" + digest
+	}
+	return apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "kube-system",
+			Name:      name,
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "DaemonSet", Name: "kube-proxy", UID: types.UID("ds-uid"), Controller: &controller},
+			},
+		},
+		Spec: apiv1.PodSpec{NodeName: "node-" + name},
+		Status: apiv1.PodStatus{
+			Phase: apiv1.PodRunning,
+			ContainerStatuses: []apiv1.ContainerStatus{
+				{Name: "kube-proxy", Image: image, ImageID: imageID},
+			},
+		},
+	}
+}
+
+func TestEvaluateDaemonSets(t *testing.T) {
+	pending := makePod("d", "k8s.gcr.io/kube-proxy:v1.13.1", digestB)
+	pending.Status.Phase = apiv1.PodPending
+
+	orphan := makePod("d", "k8s.gcr.io/kube-proxy:v1.13.1", digestB)
+	orphan.OwnerReferences = nil
+
+	var tests = []struct {
+		description string
+		template    string
+		pods        []apiv1.Pod
+		expected    int
+	}{
+		{"consistent", "k8s.gcr.io/kube-proxy:v1.13.2", []apiv1.Pod{
+			makePod("a", "k8s.gcr.io/kube-proxy:v1.13.2", digestA),
+			makePod("b", "k8s.gcr.io/kube-proxy:v1.13.2", digestA),
+		}, 0},
+		{"divergent tag", "k8s.gcr.io/kube-proxy:v1.13.2", []apiv1.Pod{
+			makePod("a", "k8s.gcr.io/kube-proxy:v1.13.2", digestA),
+			makePod("b", "k8s.gcr.io/kube-proxy:v1.13.2", digestA),
+			makePod("c", "k8s.gcr.io/kube-proxy:v1.13.1", digestB),
+		}, 1},
+		{"same tag different digest", "k8s.gcr.io/kube-proxy:v1.13.2", []apiv1.Pod{
+			makePod("a", "k8s.gcr.io/kube-proxy:v1.13.2", digestA),
+			makePod("b", "k8s.gcr.io/kube-proxy:v1.13.2", digestA),
+			makePod("c", "k8s.gcr.io/kube-proxy:v1.13.2", digestB),
+		}, 1},
+		{"no digests", "k8s.gcr.io/kube-proxy:v1.13.2", []apiv1.Pod{
+			makePod("a", "k8s.gcr.io/kube-proxy:v1.13.2", ""),
+			makePod("b", "k8s.gcr.io/kube-proxy:v1.13.1", ""),
+			makePod("c", "k8s.gcr.io/kube-proxy:v1.13.1", ""),
+		}, 1},
+		{"tie favors template", "k8s.gcr.io/kube-proxy:v1.13.2", []apiv1.Pod{
+			makePod("a", "k8s.gcr.io/kube-proxy:v1.13.1", digestB),
+			makePod("b", "k8s.gcr.io/kube-proxy:v1.13.2", digestA),
+		}, 1},
+		{"pending pod skipped", "k8s.gcr.io/kube-proxy:v1.13.2", []apiv1.Pod{
+			makePod("a", "k8s.gcr.io/kube-proxy:v1.13.2", digestA),
+			pending,
+		}, 0},
+		{"pod without controller skipped", "k8s.gcr.io/kube-proxy:v1.13.2", []apiv1.Pod{
+			makePod("a", "k8s.gcr.io/kube-proxy:v1.13.2", digestA),
+			orphan,
+		}, 0},
+	}
+
+	for _, test := range tests {
+		imageErrors := evaluateDaemonSets([]appsv1.DaemonSet{makeDaemonSet(test.template)}, test.pods)
+		if len(imageErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", imageErrors)
+		}
+		t.Log(test.description, imageErrors)
+	}
+}
+
+func TestMajorityImage(t *testing.T) {
+	counts := map[string]int{
+		"k8s.gcr.io/kube-proxy:v1.13.1@" + digestB: 1,
+		"k8s.gcr.io/kube-proxy:v1.13.2@" + digestA: 1,
+	}
+	majority := majorityImage(counts, "k8s.gcr.io/kube-proxy:v1.13.2")
+	if majority != "k8s.gcr.io/kube-proxy:v1.13.2@"+digestA {
+		t.Fatal("Expected the template image to win a tie but got", majority)
+	}
+}
@@ -0,0 +1,257 @@
+// Package deploymentStatus implements a checker that finds Deployments with
+// rollouts that are stuck.  When the new pods of a rollout never become
+// Ready, the Deployment has fewer updated or available replicas than desired
+// while the pods that are running look healthy to other checks.
+package deploymentStatus // import "github.com/Comcast/kuberhealthy/pkg/checks/deploymentStatus"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// progressDeadlineExceeded is the reason of the Progressing condition of a
+// Deployment whose rollout has not progressed within its deadline
+const progressDeadlineExceeded = "ProgressDeadlineExceeded"
+
+// Checker validates that Deployments have all of their replicas updated and
+// available
+type Checker struct {
+	FailureTimeStamp   map[string]time.Time
+	Errors             []string
+	Namespaces         []string
+	GracePeriod        time.Duration
+	defaultGracePeriod time.Duration // the grace period the check was created with
+	client             *kubernetes.Clientset
+	interval           time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied.  Deployments are reported once they have had fewer
+// updated or available replicas than desired for longer than gracePeriod.
+func New(namespaces []string, gracePeriod time.Duration) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		FailureTimeStamp:   make(map[string]time.Time),
+		Errors:             []string{},
+		Namespaces:         namespaces,
+		GracePeriod:        gracePeriod,
+		defaultGracePeriod: gracePeriod,
+	}
+}
+
+// Name returns the name of this checker
+func (dsc *Checker) Name() string {
+	return "DeploymentStatusChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (dsc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (dsc *Checker) Interval() time.Duration {
+	if dsc.interval > 0 {
+		return dsc.interval
+	}
+	return time.Minute * 2
+}
+
+// SetInterval overrides the interval at which this check runs
+func (dsc *Checker) SetInterval(d time.Duration) {
+	dsc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to list deployments in
+// the checked namespaces, which is a cluster wide list when no namespace is
+// given
+func (dsc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range dsc.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"list"}},
+		}
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (dsc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Reconfigure sets the grace period from the 'gracePeriod' runtime setting,
+// or back to the grace period the check was created with when it is not
+// given
+func (dsc *Checker) Reconfigure(settings map[string]string) error {
+	gracePeriod := dsc.defaultGracePeriod
+	for setting, value := range settings {
+		if setting != "gracePeriod" {
+			return errors.New("unknown setting " + setting)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return errors.New("gracePeriod must be a duration but is '" + value + "'")
+		}
+		gracePeriod = d
+	}
+	dsc.GracePeriod = gracePeriod
+	return nil
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (dsc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (dsc *Checker) CurrentStatus() (bool, []string) {
+	if len(dsc.Errors) > 0 {
+		return false, dsc.Errors
+	}
+	return true, dsc.Errors
+}
+
+// clearErrors clears all errors
+func (dsc *Checker) clearErrors() {
+	dsc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (dsc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	dsc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := dsc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(dsc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + dsc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(dsc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + dsc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + dsc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists Deployments in each namespace and sets an error for every
+// Deployment that has had fewer updated or available replicas than desired
+// for longer than the grace period
+func (dsc *Checker) doChecks() error {
+
+	var deployments []appsv1.Deployment
+	for _, ns := range dsc.Namespaces {
+		list, err := dsc.client.AppsV1().Deployments(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return errors.New("Error listing Deployments in namespace " + ns + ": " + err.Error())
+		}
+		deployments = append(deployments, list.Items...)
+	}
+
+	statusErrors := evaluateDeployments(deployments, dsc.FailureTimeStamp, dsc.GracePeriod, time.Now())
+
+	if len(statusErrors) > 0 {
+		for _, e := range statusErrors {
+			khlog.ForCheck(dsc.Name()).Warningln(e)
+		}
+		dsc.Errors = statusErrors
+		return nil
+	}
+
+	dsc.clearErrors()
+	return nil
+}
+
+// evaluateDeployments returns an error for every Deployment that has had
+// fewer updated or available replicas than desired for longer than
+// gracePeriod.  The updated replicas of paused Deployments are not checked
+// because their rollouts are stopped on purpose.  The time each Deployment
+// was first seen in this state is tracked in failureTimeStamp, and
+// Deployments that recovered or no longer exist are removed from it.
+func evaluateDeployments(deployments []appsv1.Deployment, failureTimeStamp map[string]time.Time, gracePeriod time.Duration, now time.Time) []string {
+	var statusErrors []string
+
+	sort.Slice(deployments, func(i, j int) bool {
+		if deployments[i].Namespace != deployments[j].Namespace {
+			return deployments[i].Namespace < deployments[j].Namespace
+		}
+		return deployments[i].Name < deployments[j].Name
+	})
+
+	stuck := make(map[string]bool)
+	for _, d := range deployments {
+		// replicas defaults to 1 when it is not set
+		var desired int32 = 1
+		if d.Spec.Replicas != nil {
+			desired = *d.Spec.Replicas
+		}
+		updatedBehind := !d.Spec.Paused && d.Status.UpdatedReplicas < desired
+		if !updatedBehind && d.Status.AvailableReplicas >= desired {
+			continue
+		}
+
+		key := d.Namespace + "/" + d.Name
+		stuck[key] = true
+		if _, ok := failureTimeStamp[key]; !ok {
+			failureTimeStamp[key] = now
+		}
+		stuckFor := now.Sub(failureTimeStamp[key])
+		if stuckFor <= gracePeriod {
+			continue
+		}
+
+		e := "Deployment " + d.Name + " in namespace " + d.Namespace + " has " + strconv.Itoa(int(d.Status.UpdatedReplicas)) + " updated and " +
+			strconv.Itoa(int(d.Status.AvailableReplicas)) + " available of " + strconv.Itoa(int(desired)) + " desired replicas for " + stuckFor.Round(time.Second).String() + "."
+		progressing := progressingCondition(d)
+		if progressing != nil && !progressing.LastTransitionTime.IsZero() {
+			e += " The rollout started at " + progressing.LastTransitionTime.UTC().Format(time.RFC3339) + "."
+		}
+		if progressing != nil && progressing.Reason == progressDeadlineExceeded {
+			e += " The rollout has exceeded its progress deadline."
+		}
+		statusErrors = append(statusErrors, e)
+	}
+
+	for key := range failureTimeStamp {
+		if !stuck[key] {
+			delete(failureTimeStamp, key)
+		}
+	}
+	return statusErrors
+}
+
+// progressingCondition returns the Progressing condition of a Deployment,
+// which changes when a rollout starts, or nil when it has none
+func progressingCondition(d appsv1.Deployment) *appsv1.DeploymentCondition {
+	for i, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Status != apiv1.ConditionUnknown {
+			return &d.Status.Conditions[i]
+		}
+	}
+	return nil
+}
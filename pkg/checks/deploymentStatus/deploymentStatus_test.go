@@ -0,0 +1,123 @@
+package deploymentStatus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateDeployments(t *testing.T) {
+	now := time.Now()
+	rolloutStart := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	makeDeployment := func(replicas *int32, updated int32, available int32, paused bool, progressingReason string) appsv1.Deployment {
+		d := appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec:       appsv1.DeploymentSpec{Replicas: replicas, Paused: paused},
+			Status:     appsv1.DeploymentStatus{UpdatedReplicas: updated, AvailableReplicas: available},
+		}
+		if len(progressingReason) > 0 {
+			d.Status.Conditions = []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentAvailable, Status: apiv1.ConditionFalse},
+				{Type: appsv1.DeploymentProgressing, Status: apiv1.ConditionTrue, Reason: progressingReason, LastTransitionTime: metav1.NewTime(rolloutStart)},
+			}
+		}
+		return d
+	}
+	three := int32(3)
+
+	var tests = []struct {
+		description   string
+		deployment    appsv1.Deployment
+		stuckFor      time.Duration // how long the Deployment was already tracked as stuck
+		expectedError string
+	}{
+		{"all replicas updated and available", makeDeployment(&three, 3, 3, false, ""), 0, ""},
+		{"replicas not set", makeDeployment(nil, 1, 1, false, ""), 0, ""},
+		{"newly stuck", makeDeployment(&three, 1, 2, false, "ReplicaSetUpdated"), 0, ""},
+		{"stuck within grace period", makeDeployment(&three, 1, 2, false, "ReplicaSetUpdated"), time.Minute, ""},
+		{"stuck past grace period", makeDeployment(&three, 1, 2, false, "ReplicaSetUpdated"), time.Minute * 10, "Deployment web in namespace default has 1 updated and 2 available of 3 desired replicas for 10m0s. The rollout started at 2019-03-01T12:00:00Z."},
+		{"updated but not available", makeDeployment(&three, 3, 2, false, "ReplicaSetUpdated"), time.Minute * 10, "has 3 updated and 2 available of 3 desired replicas"},
+		{"progress deadline exceeded", makeDeployment(&three, 1, 3, false, progressDeadlineExceeded), time.Minute * 10, "The rollout has exceeded its progress deadline."},
+		{"no progressing condition", makeDeployment(&three, 1, 3, false, ""), time.Minute * 10, "of 3 desired replicas for 10m0s."},
+		{"paused with replicas available", makeDeployment(&three, 1, 3, true, ""), time.Minute * 10, ""},
+		{"paused without replicas available", makeDeployment(&three, 1, 2, true, ""), time.Minute * 10, "has 1 updated and 2 available of 3 desired replicas"},
+	}
+
+	for _, test := range tests {
+		failureTimeStamp := make(map[string]time.Time)
+		if test.stuckFor > 0 {
+			failureTimeStamp["default/web"] = now.Add(-test.stuckFor)
+		}
+		statusErrors := evaluateDeployments([]appsv1.Deployment{test.deployment}, failureTimeStamp, time.Minute*5, now)
+		if len(test.expectedError) == 0 {
+			if len(statusErrors) != 0 {
+				t.Fatal("Test", test.description, "expected no errors but got", statusErrors)
+			}
+			continue
+		}
+		if len(statusErrors) != 1 || !strings.Contains(statusErrors[0], test.expectedError) {
+			t.Fatal("Test", test.description, "expected an error containing", test.expectedError, "but got", statusErrors)
+		}
+		t.Log(test.description, statusErrors)
+	}
+}
+
+func TestFailureTimeStamp(t *testing.T) {
+	now := time.Now()
+	three := int32(3)
+	stuck := appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &three},
+		Status:     appsv1.DeploymentStatus{UpdatedReplicas: 1, AvailableReplicas: 3},
+	}
+	failureTimeStamp := map[string]time.Time{"default/deleted": now.Add(-time.Hour)}
+
+	evaluateDeployments([]appsv1.Deployment{stuck}, failureTimeStamp, time.Minute*5, now)
+	if !failureTimeStamp["default/web"].Equal(now) {
+		t.Fatal("Expected a stuck Deployment to be tracked from now but got", failureTimeStamp)
+	}
+	if _, ok := failureTimeStamp["default/deleted"]; ok {
+		t.Fatal("Expected a Deployment that no longer exists to stop being tracked")
+	}
+
+	// the first time is kept while the Deployment stays stuck
+	evaluateDeployments([]appsv1.Deployment{stuck}, failureTimeStamp, time.Minute*5, now.Add(time.Minute))
+	if !failureTimeStamp["default/web"].Equal(now) {
+		t.Fatal("Expected the first stuck time to be kept but got", failureTimeStamp)
+	}
+
+	stuck.Status.UpdatedReplicas = 3
+	evaluateDeployments([]appsv1.Deployment{stuck}, failureTimeStamp, time.Minute*5, now.Add(time.Minute*2))
+	if len(failureTimeStamp) != 0 {
+		t.Fatal("Expected a recovered Deployment to stop being tracked but got", failureTimeStamp)
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	dsc := New([]string{}, time.Minute*5)
+
+	err := dsc.Reconfigure(map[string]string{"gracePeriod": "10m"})
+	if err != nil || dsc.GracePeriod != time.Minute*10 {
+		t.Fatal("Expected the grace period to be set to 10m but got", dsc.GracePeriod, err)
+	}
+
+	err = dsc.Reconfigure(map[string]string{"gracePeriod": "ten minutes"})
+	if err == nil || dsc.GracePeriod != time.Minute*10 {
+		t.Fatal("Expected an invalid grace period to be rejected but got", dsc.GracePeriod, err)
+	}
+
+	err = dsc.Reconfigure(map[string]string{"threshold": "3"})
+	if err == nil {
+		t.Fatal("Expected an unknown setting to be rejected")
+	}
+
+	err = dsc.Reconfigure(map[string]string{})
+	if err != nil || dsc.GracePeriod != time.Minute*5 {
+		t.Fatal("Expected the grace period to return to 5m but got", dsc.GracePeriod, err)
+	}
+}
@@ -0,0 +1,255 @@
+// Package deprecatedAPIUsage implements a checker that watches for clients
+// using deprecated APIs.  Deprecated APIs are removed in later Kubernetes
+// releases, and any client still using them breaks when the cluster is
+// upgraded.  The API server counts requests to deprecated APIs in its
+// metrics, and the checker reports the APIs requested since its last run.
+package deprecatedAPIUsage // import "github.com/Comcast/kuberhealthy/pkg/checks/deprecatedAPIUsage"
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/metrics"
+	"github.com/Comcast/kuberhealthy/pkg/promParser"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// deprecatedAPIMetric is the API server counter of requests to deprecated
+// APIs
+const deprecatedAPIMetric = "apiserver_requested_deprecated_apis_total"
+
+// Checker validates that deprecated APIs are not being requested
+type Checker struct {
+	Errors       []string
+	Threshold    int
+	client       *kubernetes.Clientset
+	metricClient metrics.Client
+	// previous holds the request count of each deprecated API from the last
+	// run so that each run evaluates only new requests
+	previous map[string]float64
+	// fetchMetrics is replaced in tests to inject API server metrics
+	fetchMetrics func(client *kubernetes.Clientset) ([]byte, error)
+	interval     time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reports deprecated APIs requested more than
+// threshold times between runs.  Request counts are pushed to the metric
+// client when it is not nil.
+func New(threshold int, metricClient metrics.Client) *Checker {
+	return &Checker{
+		Errors:       []string{},
+		Threshold:    threshold,
+		metricClient: metricClient,
+		fetchMetrics: fetchAPIServerMetrics,
+	}
+}
+
+// Name returns the name of this checker
+func (dac *Checker) Name() string {
+	return "DeprecatedAPIUsageChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (dac *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (dac *Checker) Interval() time.Duration {
+	if dac.interval > 0 {
+		return dac.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (dac *Checker) SetInterval(d time.Duration) {
+	dac.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to scrape the metrics
+// endpoint of the API server, which is not a resource and can only be
+// granted cluster wide
+func (dac *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (dac *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (dac *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (dac *Checker) CurrentStatus() (bool, []string) {
+	if len(dac.Errors) > 0 {
+		return false, dac.Errors
+	}
+	return true, dac.Errors
+}
+
+// clearErrors clears all errors
+func (dac *Checker) clearErrors() {
+	dac.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (dac *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	dac.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := dac.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(dac.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + dac.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(dac.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + dac.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + dac.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks fetches the API server metrics and sets an error for every
+// deprecated API requested more than the threshold since the last run
+func (dac *Checker) doChecks() error {
+
+	b, err := dac.fetchMetrics(dac.client)
+	if err != nil {
+		return errors.New("Error fetching API server metrics: " + err.Error())
+	}
+	samples, err := promParser.Parse(bytes.NewReader(b))
+	if err != nil {
+		return errors.New("Error parsing API server metrics: " + err.Error())
+	}
+
+	usageErrors := dac.evaluateSamples(samples)
+
+	if len(usageErrors) > 0 {
+		for _, e := range usageErrors {
+			khlog.ForCheck(dac.Name()).Warningln(e)
+		}
+		dac.Errors = usageErrors
+		return nil
+	}
+
+	dac.clearErrors()
+	return nil
+}
+
+// deprecatedAPI is a deprecated group, version, and resource
+type deprecatedAPI struct {
+	Group    string
+	Version  string
+	Resource string
+}
+
+// String formats the API as resource.group/version, or resource/version for
+// the core group
+func (d deprecatedAPI) String() string {
+	if len(d.Group) == 0 {
+		return d.Resource + "/" + d.Version
+	}
+	return d.Resource + "." + d.Group + "/" + d.Version
+}
+
+// evaluateSamples returns an error for every deprecated API requested more
+// than the threshold since the previous run.  The first run only records the
+// request counts, as the API server counts requests since it started.  A
+// count lower than the previous run means the API server restarted and the
+// whole count is new.
+func (dac *Checker) evaluateSamples(samples []promParser.Sample) []string {
+	var usageErrors []string
+
+	// subresources and removed releases are reported as separate series
+	counts := make(map[string]float64)
+	apis := make(map[string]deprecatedAPI)
+	for _, s := range promParser.Filter(samples, deprecatedAPIMetric) {
+		api := deprecatedAPI{
+			Group:    s.Labels["group"],
+			Version:  s.Labels["version"],
+			Resource: s.Labels["resource"],
+		}
+		counts[api.String()] += s.Value
+		apis[api.String()] = api
+	}
+
+	firstRun := dac.previous == nil
+	previous := dac.previous
+	dac.previous = counts
+	if firstRun {
+		khlog.ForCheck(dac.Name()).Debugln("Recorded request counts of", len(counts), "deprecated APIs")
+		return usageErrors
+	}
+
+	var names []string
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		requests := counts[name]
+		if previousRequests, ok := previous[name]; ok && requests >= previousRequests {
+			requests -= previousRequests
+		}
+
+		dac.pushRequests(apis[name], requests)
+		if requests > float64(dac.Threshold) {
+			usageErrors = append(usageErrors, "Deprecated API "+name+" was requested "+strconv.FormatFloat(requests, 'f', -1, 64)+
+				" times since the last check which exceeds the threshold of "+strconv.Itoa(dac.Threshold))
+		}
+	}
+	return usageErrors
+}
+
+// pushRequests sends the requests to a deprecated API since the last run to
+// the metric client
+func (dac *Checker) pushRequests(api deprecatedAPI, requests float64) {
+	if dac.metricClient == nil {
+		return
+	}
+	metric := metrics.Metric{
+		{dac.Name() + "_requests": requests},
+	}
+	tags := map[string]string{
+		"Group":    api.Group,
+		"Version":  api.Version,
+		"Resource": api.Resource,
+	}
+	err := dac.metricClient.Push(metric, tags)
+	if err != nil {
+		khlog.ForCheck(dac.Name()).Errorln("Error forwarding metrics", err)
+	}
+}
+
+// fetchAPIServerMetrics fetches the metrics of the API server
+func fetchAPIServerMetrics(client *kubernetes.Clientset) ([]byte, error) {
+	return client.CoreV1().RESTClient().Get().AbsPath("/metrics").DoRaw()
+}
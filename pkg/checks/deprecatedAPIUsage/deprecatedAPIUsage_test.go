@@ -0,0 +1,99 @@
+package deprecatedAPIUsage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/kuberhealthy/pkg/metrics"
+	"k8s.io/client-go/kubernetes"
+)
+
+// fakeMetricClient records pushed metrics
+type fakeMetricClient struct {
+	pushed []map[string]string
+}
+
+// Push records the tags of each push
+func (f *fakeMetricClient) Push(points metrics.Metric, tags map[string]string) error {
+	f.pushed = append(f.pushed, tags)
+	return nil
+}
+
+// SetGlobalTags is implemented to satisfy metrics.Client but is not used
+func (f *fakeMetricClient) SetGlobalTags(tags map[string]string) {}
+
+// makeMetrics renders deprecated API counters in Prometheus text format.
+// Counts are keyed by group/version/resource/subresource.
+func makeMetrics(counts map[string]float64) []byte {
+	lines := []string{
+		"# HELP " + deprecatedAPIMetric + " Counter of deprecated APIs that have been requested",
+		"# TYPE " + deprecatedAPIMetric + " counter",
+		`apiserver_request_total{verb="GET",resource="pods"} 100`,
+	}
+	for api, count := range counts {
+		parts := strings.Split(api, "/")
+		lines = append(lines, fmt.Sprintf(`%s{group="%s",version="%s",resource="%s",subresource="%s",removed_release="1.16"} %v`,
+			deprecatedAPIMetric, parts[0], parts[1], parts[2], parts[3], count))
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+func TestDoChecks(t *testing.T) {
+	var tests = []struct {
+		description string
+		threshold   int
+		previous    map[string]float64
+		current     map[string]float64
+		expected    int
+	}{
+		{"no new requests", 0, map[string]float64{"extensions/v1beta1/deployments/": 5}, map[string]float64{"extensions/v1beta1/deployments/": 5}, 0},
+		{"new requests", 0, map[string]float64{"extensions/v1beta1/deployments/": 5}, map[string]float64{"extensions/v1beta1/deployments/": 6}, 1},
+		{"new deprecated API", 0, map[string]float64{}, map[string]float64{"apps/v1beta2/statefulsets/": 1}, 1},
+		{"within threshold", 5, map[string]float64{"extensions/v1beta1/deployments/": 5}, map[string]float64{"extensions/v1beta1/deployments/": 10}, 0},
+		{"exceeds threshold", 5, map[string]float64{"extensions/v1beta1/deployments/": 5}, map[string]float64{"extensions/v1beta1/deployments/": 11}, 1},
+		{"subresources are combined", 5, map[string]float64{"extensions/v1beta1/deployments/": 5, "extensions/v1beta1/deployments/scale": 5}, map[string]float64{"extensions/v1beta1/deployments/": 8, "extensions/v1beta1/deployments/scale": 8}, 1},
+		{"API server restarted", 5, map[string]float64{"extensions/v1beta1/deployments/": 50}, map[string]float64{"extensions/v1beta1/deployments/": 3}, 0},
+		{"core group", 0, map[string]float64{"/v1/componentstatuses/": 1}, map[string]float64{"/v1/componentstatuses/": 2}, 1},
+	}
+
+	for _, test := range tests {
+		metricClient := &fakeMetricClient{}
+		checker := New(test.threshold, metricClient)
+		output := makeMetrics(test.previous)
+		checker.fetchMetrics = func(client *kubernetes.Clientset) ([]byte, error) {
+			return output, nil
+		}
+
+		// the first run only records the request counts
+		err := checker.doChecks()
+		if err != nil {
+			t.Fatal("Test", test.description, "failed on the first run:", err)
+		}
+		if len(checker.Errors) != 0 || len(metricClient.pushed) != 0 {
+			t.Fatal("Test", test.description, "expected the first run to only record counts but got", checker.Errors, metricClient.pushed)
+		}
+
+		output = makeMetrics(test.current)
+		err = checker.doChecks()
+		if err != nil {
+			t.Fatal("Test", test.description, "failed:", err)
+		}
+		if len(checker.Errors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", checker.Errors)
+		}
+		t.Log(test.description, checker.Errors, metricClient.pushed)
+	}
+}
+
+func TestDoChecksFetchError(t *testing.T) {
+	checker := New(0, nil)
+	checker.fetchMetrics = func(client *kubernetes.Clientset) ([]byte, error) {
+		return nil, errors.New("forbidden")
+	}
+	err := checker.doChecks()
+	if err == nil {
+		t.Fatal("Expected an error when the API server metrics can not be fetched")
+	}
+}
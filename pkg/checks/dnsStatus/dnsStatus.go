@@ -3,15 +3,17 @@
 package dnsStatus
 
 import (
+	"context"
 	"errors"
 	"net"
 	"time"
 
-	log "github.com/sirupsen/logrus"
-
 	// required for oidc kubectl testing
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 
+	"github.com/Comcast/kuberhealthy/pkg/checks/componentStatus"
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -24,6 +26,7 @@ type Checker struct {
 	client           *kubernetes.Clientset
 	MaxTimeInFailure time.Duration
 	Endpoints        []string
+	interval         time.Duration // overrides the default interval when set
 }
 
 // New returns a new Checker.  Pass in a blank slice to use the default
@@ -55,14 +58,42 @@ func (dc *Checker) CheckNamespace() string {
 
 // Interval returns the interval at which this check runs
 func (dc *Checker) Interval() time.Duration {
+	if dc.interval > 0 {
+		return dc.interval
+	}
 	return time.Second * 15
 }
 
+// SetInterval overrides the interval at which this check runs
+func (dc *Checker) SetInterval(d time.Duration) {
+	dc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  DNS lookups do not use
+// the Kubernetes API, so none are needed.
+func (dc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{}
+}
+
 // Timeout returns the maximum run time for this check before it times out
 func (dc *Checker) Timeout() time.Duration {
 	return time.Minute * 1
 }
 
+// RetryPolicy returns the attempts and base retry delay of a failing run.
+// A single dropped DNS packet fails a lookup, so lookups are retried
+// quickly.
+func (dc *Checker) RetryPolicy() (int, time.Duration) {
+	return 3, time.Second
+}
+
+// DependsOn returns the component status checker.  Lookup failures are not
+// reported while the control plane is unhealthy, because cluster DNS can not
+// pick up changes to Services without it.
+func (dc *Checker) DependsOn() []string {
+	return []string{componentStatus.CheckName}
+}
+
 // Shutdown is implemented to satisfy KuberhealthyCheck but is not used
 func (dc *Checker) Shutdown() error {
 	return nil
@@ -71,10 +102,10 @@ func (dc *Checker) Shutdown() error {
 // CurrentStatus returns the status of the check as of right now
 func (dc *Checker) CurrentStatus() (bool, []string) {
 	if len(dc.Errors) > 0 {
-		log.Debug("DNS check returning current status of FALSE.", len(dc.Errors), "errors")
+		khlog.ForCheck(dc.Name()).Debug("DNS check returning current status of FALSE.", len(dc.Errors), "errors")
 		return false, dc.Errors
 	}
-	log.Debug("DNS check returning current status of FALSE.", len(dc.Errors), "errors")
+	khlog.ForCheck(dc.Name()).Debug("DNS check returning current status of FALSE.", len(dc.Errors), "errors")
 	return true, dc.Errors
 }
 
@@ -84,8 +115,8 @@ func (dc *Checker) clearErrors() {
 }
 
 // Run implements the entrypoint for check execution
-func (dc *Checker) Run(client *kubernetes.Clientset) error {
-	log.Infoln("Running DNS checker")
+func (dc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	khlog.ForCheck(dc.Name()).Infoln("Running DNS checker")
 	doneChan := make(chan error)
 
 	dc.client = client
@@ -104,6 +135,9 @@ func (dc *Checker) Run(client *kubernetes.Clientset) error {
 	case <-time.After(dc.Timeout()):
 		// The check has timed out after its specified timeout period
 		return errors.New("Failed to complete checks for " + dc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + dc.Name() + ".  The check was cancelled.")
 	case err := <-doneChan:
 		return err
 	}
@@ -113,30 +147,30 @@ func (dc *Checker) Run(client *kubernetes.Clientset) error {
 func (dc *Checker) doChecks() error {
 	dnsErrors := []string{}
 	for _, address := range dc.Endpoints {
-		log.Infoln("DNS Checker testing", address)
+		khlog.ForCheck(dc.Name()).Infoln("DNS Checker testing", address)
 		_, err := net.LookupHost(address)
 		if err == nil {
-			log.Infoln("DNS Checker determined that", address, "was OK.")
+			khlog.ForCheck(dc.Name()).Infoln("DNS Checker determined that", address, "was OK.")
 			delete(dc.FailureTimeStamp, address)
 			continue
 		}
 		timestamp, exists := dc.FailureTimeStamp[address]
 		if !exists {
-			log.Warningln("DNS Checker determined that", address, "was DOWN.")
+			khlog.ForCheck(dc.Name()).Warningln("DNS Checker determined that", address, "was DOWN.")
 			dc.FailureTimeStamp[address] = time.Now()
 			continue
 		}
 		if time.Now().Sub(timestamp).Seconds() > dc.MaxTimeInFailure.Seconds() {
-			log.Warningln("DNS Checker determined that", address, "was DOWN for too long and is now indicating a check ERROR:", err)
+			khlog.ForCheck(dc.Name()).Warningln("DNS Checker determined that", address, "was DOWN for too long and is now indicating a check ERROR:", err)
 			dnsErrors = append(dnsErrors, err.Error())
 		}
 
 	}
 	if len(dnsErrors) > 0 {
-		log.Debugln("Setting errors to", dnsErrors)
+		khlog.ForCheck(dc.Name()).Debugln("Setting errors to", dnsErrors)
 		dc.Errors = dnsErrors
 	} else {
-		log.Debugln("Clearing DNS errors")
+		khlog.ForCheck(dc.Name()).Debugln("Clearing DNS errors")
 		dc.clearErrors()
 	}
 	return nil
@@ -0,0 +1,317 @@
+package dnsStatus
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/checks/componentStatus"
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// lookupMarker precedes the nslookup output of each name in the lookup pod
+// output
+const lookupMarker = "=== "
+
+// ServiceChecker validates that Service names resolve to their ClusterIPs
+// from a newly created pod, which exercises the same DNS path as any
+// workload in the cluster
+type ServiceChecker struct {
+	Errors               []string
+	Namespaces           []string
+	ServicesPerNamespace int
+	ClusterDomain        string
+	Image                string
+	client               *kubernetes.Clientset
+	// runPod is replaced in tests to inject lookup pod output
+	runPod   func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (string, error)
+	interval time.Duration // overrides the default interval when set
+}
+
+// NewServiceChecker returns a new ServiceChecker that resolves
+// kubernetes.default and up to servicesPerNamespace Services from each of
+// the supplied namespaces
+func NewServiceChecker(namespaces []string, servicesPerNamespace int, clusterDomain string) *ServiceChecker {
+	return &ServiceChecker{
+		Errors:               []string{},
+		Namespaces:           namespaces,
+		ServicesPerNamespace: servicesPerNamespace,
+		ClusterDomain:        clusterDomain,
+		Image:                "busybox:1.30",
+		runPod:               podRunner.RunPod,
+	}
+}
+
+// Name returns the name of this checker
+func (sc *ServiceChecker) Name() string {
+	return "DnsServiceChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (sc *ServiceChecker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (sc *ServiceChecker) Interval() time.Duration {
+	if sc.interval > 0 {
+		return sc.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (sc *ServiceChecker) SetInterval(d time.Duration) {
+	sc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to read the kubernetes
+// Service, list Services in the checked namespaces and run the lookup pod
+func (sc *ServiceChecker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := map[string][]rbacv1.PolicyRule{
+		namespace: podRunner.RunPodRBACRules(),
+	}
+	rules[metav1.NamespaceDefault] = append(rules[metav1.NamespaceDefault], rbacv1.PolicyRule{
+		APIGroups: []string{""}, Resources: []string{"services"}, ResourceNames: []string{"kubernetes"}, Verbs: []string{"get"},
+	})
+	for _, ns := range sc.Namespaces {
+		rules[ns] = append(rules[ns], rbacv1.PolicyRule{
+			APIGroups: []string{""}, Resources: []string{"services"}, Verbs: []string{"list"},
+		})
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (sc *ServiceChecker) Timeout() time.Duration {
+	return time.Minute * 3
+}
+
+// DependsOn returns the component status checker, since Services are listed
+// and the lookup pod is created through the API server
+func (sc *ServiceChecker) DependsOn() []string {
+	return []string{componentStatus.CheckName}
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (sc *ServiceChecker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (sc *ServiceChecker) CurrentStatus() (bool, []string) {
+	if len(sc.Errors) > 0 {
+		return false, sc.Errors
+	}
+	return true, sc.Errors
+}
+
+// clearErrors clears all errors
+func (sc *ServiceChecker) clearErrors() {
+	sc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (sc *ServiceChecker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	sc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := sc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(sc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + sc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(sc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + sc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + sc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks resolves the Service names from a pod and sets an error for
+// every name that does not resolve to its Service's ClusterIP
+func (sc *ServiceChecker) doChecks() error {
+
+	apiServer, err := sc.client.CoreV1().Services(metav1.NamespaceDefault).Get("kubernetes", metav1.GetOptions{})
+	if err != nil {
+		return errors.New("Error getting the kubernetes service: " + err.Error())
+	}
+	services := []apiv1.Service{*apiServer}
+	for _, ns := range sc.Namespaces {
+		list, err := sc.client.CoreV1().Services(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return errors.New("Error listing services in namespace " + ns + ": " + err.Error())
+		}
+		services = append(services, selectServices(list.Items, sc.ServicesPerNamespace)...)
+	}
+	expected := sc.expectedAddresses(services)
+
+	lookupErrors, err := sc.lookupServices(expected)
+	if err != nil {
+		return err
+	}
+
+	if len(lookupErrors) > 0 {
+		for _, e := range lookupErrors {
+			khlog.ForCheck(sc.Name()).Warningln(e)
+		}
+		sc.Errors = lookupErrors
+		return nil
+	}
+
+	sc.clearErrors()
+	return nil
+}
+
+// lookupServices runs a pod that looks up each name and returns an error
+// for every name that does not resolve to its expected address.  The pod is
+// removed by the pod runner when it completes.
+func (sc *ServiceChecker) lookupServices(expected map[string]string) ([]string, error) {
+	var names []string
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	script := podRunner.Script{
+		Name:   "dns-service-lookup",
+		Image:  sc.Image,
+		Script: "for name in " + strings.Join(names, " ") + "; do echo \"" + lookupMarker + "$name\"; nslookup \"$name\" 2>&1; done; true",
+	}
+	output, err := sc.runPod(sc.client, namespace, script, sc.Timeout()-time.Minute)
+	if err != nil {
+		return nil, errors.New("Error running DNS lookup pod: " + err.Error())
+	}
+
+	results := parseLookups(output)
+	var lookupErrors []string
+	for _, name := range names {
+		result, ok := results[name]
+		switch {
+		case !ok:
+			lookupErrors = append(lookupErrors, "No DNS lookup result was reported for "+name)
+		case len(result.Addresses) == 0:
+			lookupErrors = append(lookupErrors, "Service name "+name+" did not resolve: "+result.Error)
+		case !containsString(result.Addresses, expected[name]):
+			lookupErrors = append(lookupErrors, "Service name "+name+" resolved to "+strings.Join(result.Addresses, ", ")+" instead of its ClusterIP "+expected[name])
+		}
+	}
+	return lookupErrors, nil
+}
+
+// expectedAddresses returns the ClusterIP each Service name should resolve
+// to, keyed by fully qualified Service name
+func (sc *ServiceChecker) expectedAddresses(services []apiv1.Service) map[string]string {
+	expected := make(map[string]string)
+	for _, s := range services {
+		expected[s.Name+"."+s.Namespace+".svc."+sc.ClusterDomain] = s.Spec.ClusterIP
+	}
+	return expected
+}
+
+// selectServices returns up to limit Services that resolve to a ClusterIP,
+// in name order.  Headless and ExternalName Services are skipped.
+func selectServices(services []apiv1.Service, limit int) []apiv1.Service {
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+
+	var selected []apiv1.Service
+	for _, s := range services {
+		if len(selected) >= limit {
+			break
+		}
+		if s.Spec.Type == apiv1.ServiceTypeExternalName || len(s.Spec.ClusterIP) == 0 || s.Spec.ClusterIP == apiv1.ClusterIPNone {
+			continue
+		}
+		selected = append(selected, s)
+	}
+	return selected
+}
+
+// lookupResult is the outcome of looking up a single name
+type lookupResult struct {
+	Addresses []string
+	Error     string
+}
+
+// parseLookups returns the result of each lookup in the pod output, keyed by
+// name.  Each lookup is the lookupMarker and name followed by the output of
+// busybox nslookup, where the addresses of the name follow the Name line and
+// the nameserver address precedes it.
+func parseLookups(output string) map[string]lookupResult {
+	results := make(map[string]lookupResult)
+
+	var name string
+	var answered bool
+	var messages []string
+	var result lookupResult
+	finish := func() {
+		if len(name) == 0 {
+			return
+		}
+		result.Error = strings.Join(messages, "; ")
+		results[name] = result
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, lookupMarker) {
+			finish()
+			name = strings.TrimPrefix(line, lookupMarker)
+			answered = false
+			messages = nil
+			result = lookupResult{}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "Name:"):
+			answered = true
+		case strings.HasPrefix(line, "Address") && answered:
+			// "Address: 10.96.0.1" or "Address 1: 10.96.0.1 kubernetes.default..."
+			parts := strings.SplitN(line, ":", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			fields := strings.Fields(parts[1])
+			if len(fields) > 0 && !containsString(result.Addresses, fields[0]) {
+				result.Addresses = append(result.Addresses, fields[0])
+			}
+		case strings.HasPrefix(line, "***") || strings.HasPrefix(line, "nslookup:"):
+			messages = append(messages, strings.TrimSpace(strings.TrimLeft(line, "*")))
+		}
+	}
+	finish()
+	return results
+}
+
+// containsString determines if a string is in a slice
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package dnsStatus
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// nslookupOutput builds busybox nslookup output for a name resolving to the
+// supplied addresses
+func nslookupOutput(name string, addresses ...string) string {
+	output := lookupMarker + name + "\nServer:\t\t10.96.0.10\nAddress:\t10.96.0.10:53\n\n"
+	if len(addresses) == 0 {
+		return output + "** server can't find " + name + ": NXDOMAIN\n\n*** Can't find " + name + ": No answer\n\n"
+	}
+	output += "Name:\t" + name + "\n"
+	for _, a := range addresses {
+		output += "Address: " + a + "\n"
+	}
+	return output + "\n*** Can't find " + name + ": No answer\n\n"
+}
+
+func TestParseLookups(t *testing.T) {
+	output := nslookupOutput("kubernetes.default.svc.cluster.local", "10.96.0.1") +
+		nslookupOutput("missing.default.svc.cluster.local") +
+		lookupMarker + "kube-dns.kube-system.svc.cluster.local\nServer:    10.96.0.10\nAddress 1: 10.96.0.10 kube-dns.kube-system.svc.cluster.local\n\nName:      kube-dns.kube-system.svc.cluster.local\nAddress 1: 10.96.0.10 kube-dns.kube-system.svc.cluster.local\n"
+
+	results := parseLookups(output)
+	if r := results["kubernetes.default.svc.cluster.local"]; len(r.Addresses) != 1 || r.Addresses[0] != "10.96.0.1" {
+		t.Fatal("Unexpected result for kubernetes.default:", r)
+	}
+	if r := results["missing.default.svc.cluster.local"]; len(r.Addresses) != 0 || !strings.Contains(r.Error, "Can't find") {
+		t.Fatal("Unexpected result for missing:", r)
+	}
+	if r := results["kube-dns.kube-system.svc.cluster.local"]; len(r.Addresses) != 1 || r.Addresses[0] != "10.96.0.10" {
+		t.Fatal("Unexpected result for the older busybox format:", r)
+	}
+}
+
+func TestSelectServices(t *testing.T) {
+	makeService := func(name string, serviceType apiv1.ServiceType, clusterIP string) apiv1.Service {
+		return apiv1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec:       apiv1.ServiceSpec{Type: serviceType, ClusterIP: clusterIP},
+		}
+	}
+
+	services := []apiv1.Service{
+		makeService("web", apiv1.ServiceTypeClusterIP, "10.96.1.3"),
+		makeService("db", apiv1.ServiceTypeClusterIP, apiv1.ClusterIPNone),
+		makeService("external", apiv1.ServiceTypeExternalName, ""),
+		makeService("api", apiv1.ServiceTypeClusterIP, "10.96.1.1"),
+		makeService("cache", apiv1.ServiceTypeNodePort, "10.96.1.2"),
+	}
+
+	selected := selectServices(services, 2)
+	if len(selected) != 2 || selected[0].Name != "api" || selected[1].Name != "cache" {
+		t.Fatal("Unexpected selected services:", selected)
+	}
+}
+
+func TestLookupServices(t *testing.T) {
+	expected := map[string]string{
+		"kubernetes.default.svc.cluster.local": "10.96.0.1",
+		"web.default.svc.cluster.local":        "10.96.1.3",
+	}
+
+	var tests = []struct {
+		description string
+		output      string
+		runErr      error
+		expected    int
+		expectErr   bool
+	}{
+		{"all resolve", nslookupOutput("kubernetes.default.svc.cluster.local", "10.96.0.1") + nslookupOutput("web.default.svc.cluster.local", "10.96.1.3"), nil, 0, false},
+		{"wrong address", nslookupOutput("kubernetes.default.svc.cluster.local", "10.96.0.1") + nslookupOutput("web.default.svc.cluster.local", "10.96.9.9"), nil, 1, false},
+		{"not found", nslookupOutput("kubernetes.default.svc.cluster.local", "10.96.0.1") + nslookupOutput("web.default.svc.cluster.local"), nil, 1, false},
+		{"missing result", nslookupOutput("kubernetes.default.svc.cluster.local", "10.96.0.1"), nil, 1, false},
+		{"pod failure", "", errors.New("Timed out waiting for pod"), 0, true},
+	}
+
+	for _, test := range tests {
+		checker := NewServiceChecker([]string{"default"}, 3, "cluster.local")
+		output := test.output
+		runErr := test.runErr
+		var script string
+		checker.runPod = func(client *kubernetes.Clientset, namespace string, s podRunner.Script, timeout time.Duration) (string, error) {
+			script = s.Script
+			return output, runErr
+		}
+
+		lookupErrors, err := checker.lookupServices(expected)
+		if (err != nil) != test.expectErr {
+			t.Fatal("Test", test.description, "expected error", test.expectErr, "but got", err)
+		}
+		if len(lookupErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", lookupErrors)
+		}
+		if !strings.Contains(script, "kubernetes.default.svc.cluster.local web.default.svc.cluster.local") {
+			t.Fatal("Test", test.description, "did not look up every name:", script)
+		}
+		t.Log(test.description, lookupErrors)
+	}
+}
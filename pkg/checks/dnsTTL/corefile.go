@@ -0,0 +1,93 @@
+package dnsTTL
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corefileTTL is a TTL setting found in a Corefile
+type corefileTTL struct {
+	Description string
+	TTL         time.Duration
+}
+
+// parseCorefileTTLs returns the TTL settings of the kubernetes and cache
+// plugins in every server block of a Corefile.  These are the ttl option of
+// the kubernetes plugin, which sets the TTL of cluster records, and the
+// maximum TTL of the cache plugin, either inline or in its success and
+// denial options.
+func parseCorefileTTLs(corefile string) ([]corefileTTL, error) {
+	var settings []corefileTTL
+
+	var depth int
+	var server, plugin string
+	for _, line := range strings.Split(corefile, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		opensBlock := fields[len(fields)-1] == "{"
+		if opensBlock {
+			fields = fields[:len(fields)-1]
+		}
+
+		switch {
+		case len(fields) == 1 && fields[0] == "}":
+			depth--
+			if depth < 0 {
+				return nil, errors.New("unexpected closing brace")
+			}
+			if depth < 2 {
+				plugin = ""
+			}
+			continue
+		case depth == 0:
+			server = strings.Join(fields, " ")
+		case depth == 1:
+			plugin = fields[0]
+			if plugin == "cache" && len(fields) > 1 {
+				ttl, err := parseSeconds(fields[1])
+				if err != nil {
+					return nil, err
+				}
+				settings = append(settings, corefileTTL{Description: server + " cache ttl", TTL: ttl})
+			}
+		case depth == 2 && plugin == "kubernetes" && fields[0] == "ttl" && len(fields) > 1:
+			ttl, err := parseSeconds(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			settings = append(settings, corefileTTL{Description: server + " kubernetes ttl", TTL: ttl})
+		case depth == 2 && plugin == "cache" && (fields[0] == "success" || fields[0] == "denial") && len(fields) > 2:
+			ttl, err := parseSeconds(fields[2])
+			if err != nil {
+				return nil, err
+			}
+			settings = append(settings, corefileTTL{Description: server + " cache " + fields[0] + " ttl", TTL: ttl})
+		}
+
+		if opensBlock {
+			depth++
+		}
+	}
+
+	if depth != 0 {
+		return nil, errors.New("unbalanced braces")
+	}
+	return settings, nil
+}
+
+// parseSeconds parses a TTL in seconds
+func parseSeconds(s string) (time.Duration, error) {
+	seconds, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.New("invalid TTL " + s + ": " + err.Error())
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
@@ -0,0 +1,340 @@
+// Package dnsTTL implements a checker that ensures cluster DNS records are
+// served with reasonable TTLs.  Very short TTLs cause excessive DNS queries
+// and very long TTLs cause slow failovers.  Records are queried directly from
+// the cluster DNS server so that their TTL can be read, and the TTL settings
+// of the CoreDNS Corefile are validated when CoreDNS is in use.
+package dnsTTL // import "github.com/Comcast/kuberhealthy/pkg/checks/dnsTTL"
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"golang.org/x/net/dns/dnsmessage"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// coreDNSConfigMap holds the CoreDNS Corefile in kube-system
+const coreDNSConfigMap = "coredns"
+
+// Checker validates the TTLs of cluster DNS records
+type Checker struct {
+	Errors     []string
+	Names      []string
+	MinTTL     time.Duration
+	MaxTTL     time.Duration
+	ResolvConf string
+	client     *kubernetes.Clientset
+	// exchange is replaced in tests to inject DNS responses
+	exchange func(server string, query []byte) ([]byte, error)
+	interval time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that looks up the supplied names, or
+// kubernetes.default when none are supplied, and requires their TTLs to be
+// between minTTL and maxTTL
+func New(names []string, minTTL time.Duration, maxTTL time.Duration) *Checker {
+	if len(names) == 0 {
+		names = []string{"kubernetes.default"}
+	}
+	return &Checker{
+		Errors:     []string{},
+		Names:      names,
+		MinTTL:     minTTL,
+		MaxTTL:     maxTTL,
+		ResolvConf: "/etc/resolv.conf",
+		exchange:   exchangeUDP,
+	}
+}
+
+// Name returns the name of this checker
+func (dtc *Checker) Name() string {
+	return "DNSTTLChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (dtc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (dtc *Checker) Interval() time.Duration {
+	if dtc.interval > 0 {
+		return dtc.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (dtc *Checker) SetInterval(d time.Duration) {
+	dtc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to read the Corefile
+// from the CoreDNS ConfigMap in kube-system
+func (dtc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		metav1.NamespaceSystem: {
+			{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{coreDNSConfigMap}, Verbs: []string{"get"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (dtc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (dtc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (dtc *Checker) CurrentStatus() (bool, []string) {
+	if len(dtc.Errors) > 0 {
+		return false, dtc.Errors
+	}
+	return true, dtc.Errors
+}
+
+// clearErrors clears all errors
+func (dtc *Checker) clearErrors() {
+	dtc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (dtc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	dtc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := dtc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(dtc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + dtc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(dtc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + dtc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + dtc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks looks up each name and reads the Corefile, setting an error for
+// every TTL outside of the allowed range
+func (dtc *Checker) doChecks() error {
+
+	b, err := ioutil.ReadFile(dtc.ResolvConf)
+	if err != nil {
+		return errors.New("Error reading " + dtc.ResolvConf + ": " + err.Error())
+	}
+	server, search := parseResolvConf(string(b))
+	if len(server) == 0 {
+		return errors.New("No nameserver found in " + dtc.ResolvConf)
+	}
+
+	var ttlErrors []string
+	for _, name := range dtc.Names {
+		ttl, err := dtc.lookupTTL(server, name, search)
+		if err != nil {
+			ttlErrors = append(ttlErrors, "Error looking up "+name+": "+err.Error())
+			continue
+		}
+		if e := evaluateTTL("DNS record "+name, ttl, dtc.MinTTL, dtc.MaxTTL); len(e) > 0 {
+			ttlErrors = append(ttlErrors, e)
+		}
+	}
+
+	corefileErrors, err := dtc.checkCorefile()
+	if err != nil {
+		return err
+	}
+	ttlErrors = append(ttlErrors, corefileErrors...)
+
+	if len(ttlErrors) > 0 {
+		for _, e := range ttlErrors {
+			khlog.ForCheck(dtc.Name()).Warningln(e)
+		}
+		dtc.Errors = ttlErrors
+		return nil
+	}
+
+	dtc.clearErrors()
+	return nil
+}
+
+// checkCorefile returns an error for every TTL setting in the CoreDNS
+// Corefile outside of the allowed range.  Clusters without the CoreDNS
+// ConfigMap are skipped.
+func (dtc *Checker) checkCorefile() ([]string, error) {
+	cm, err := dtc.client.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(coreDNSConfigMap, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		khlog.ForCheck(dtc.Name()).Debugln("CoreDNS ConfigMap not found. Skipping Corefile TTL check.")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := parseCorefileTTLs(cm.Data["Corefile"])
+	if err != nil {
+		return nil, errors.New("Error parsing CoreDNS Corefile: " + err.Error())
+	}
+	var ttlErrors []string
+	for _, s := range settings {
+		if e := evaluateTTL("CoreDNS "+s.Description, s.TTL, dtc.MinTTL, dtc.MaxTTL); len(e) > 0 {
+			ttlErrors = append(ttlErrors, e)
+		}
+	}
+	return ttlErrors, nil
+}
+
+// lookupTTL queries the server for the A records of a name and returns the
+// lowest TTL of the answers.  Names without a trailing dot are qualified with
+// each search domain in turn, like the system resolver does.
+func (dtc *Checker) lookupTTL(server string, name string, search []string) (time.Duration, error) {
+	candidates := []string{name}
+	if !strings.HasSuffix(name, ".") {
+		candidates = nil
+		for _, domain := range search {
+			candidates = append(candidates, name+"."+domain+".")
+		}
+		candidates = append(candidates, name+".")
+	}
+
+	var lastErr error
+	for _, fqdn := range candidates {
+		query, err := buildQuery(fqdn)
+		if err != nil {
+			return 0, err
+		}
+		response, err := dtc.exchange(server, query)
+		if err != nil {
+			return 0, err
+		}
+		ttl, err := answerTTL(response)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return ttl, nil
+	}
+	return 0, lastErr
+}
+
+// buildQuery builds a recursive query for the A records of a fully qualified
+// name
+func buildQuery(fqdn string) ([]byte, error) {
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, err
+	}
+	message := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: uint16(time.Now().UnixNano()), RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET},
+		},
+	}
+	return message.Pack()
+}
+
+// answerTTL returns the lowest TTL of the answers in a DNS response
+func answerTTL(response []byte) (time.Duration, error) {
+	var message dnsmessage.Message
+	err := message.Unpack(response)
+	if err != nil {
+		return 0, errors.New("unable to parse DNS response: " + err.Error())
+	}
+	if message.Header.RCode != dnsmessage.RCodeSuccess {
+		return 0, errors.New("DNS response code was " + message.Header.RCode.String())
+	}
+	if len(message.Answers) == 0 {
+		return 0, errors.New("DNS response had no answers")
+	}
+
+	lowest := message.Answers[0].Header.TTL
+	for _, a := range message.Answers[1:] {
+		if a.Header.TTL < lowest {
+			lowest = a.Header.TTL
+		}
+	}
+	return time.Duration(lowest) * time.Second, nil
+}
+
+// exchangeUDP sends a DNS query to the server over UDP and returns the
+// response
+func exchangeUDP(server string, query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(server, "53"), time.Second*5)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(time.Second * 5))
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.Write(query)
+	if err != nil {
+		return nil, err
+	}
+	response := make([]byte, 4096)
+	n, err := conn.Read(response)
+	if err != nil {
+		return nil, err
+	}
+	return response[:n], nil
+}
+
+// parseResolvConf returns the first nameserver and the search domains of a
+// resolv.conf file
+func parseResolvConf(resolvConf string) (string, []string) {
+	var server string
+	var search []string
+	for _, line := range strings.Split(resolvConf, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			if len(server) == 0 {
+				server = fields[1]
+			}
+		case "search":
+			search = fields[1:]
+		}
+	}
+	return server, search
+}
+
+// evaluateTTL returns an error if a TTL is outside of the allowed range
+func evaluateTTL(description string, ttl time.Duration, minTTL time.Duration, maxTTL time.Duration) string {
+	if ttl < minTTL {
+		return description + " TTL " + ttl.String() + " is shorter than the minimum of " + minTTL.String() + " and causes excessive DNS queries"
+	}
+	if ttl > maxTTL {
+		return description + " TTL " + ttl.String() + " is longer than the maximum of " + maxTTL.String() + " and slows failovers"
+	}
+	return ""
+}
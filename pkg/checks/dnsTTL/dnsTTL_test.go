@@ -0,0 +1,161 @@
+package dnsTTL
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// makeResponse builds a DNS response to a query with an A record answer for
+// each supplied TTL
+func makeResponse(t *testing.T, query []byte, rcode dnsmessage.RCode, ttls ...uint32) []byte {
+	var q dnsmessage.Message
+	err := q.Unpack(query)
+	if err != nil {
+		t.Fatal("Unable to parse query:", err)
+	}
+
+	response := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: q.Header.ID, Response: true, RCode: rcode},
+		Questions: q.Questions,
+	}
+	for _, ttl := range ttls {
+		response.Answers = append(response.Answers, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: q.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: ttl},
+			Body:   &dnsmessage.AResource{A: [4]byte{10, 96, 0, 1}},
+		})
+	}
+	b, err := response.Pack()
+	if err != nil {
+		t.Fatal("Unable to build response:", err)
+	}
+	return b
+}
+
+func TestLookupTTL(t *testing.T) {
+	var tests = []struct {
+		description string
+		ttls        []uint32
+		expected    time.Duration
+	}{
+		{"single answer", []uint32{30}, time.Second * 30},
+		{"lowest answer", []uint32{30, 5, 60}, time.Second * 5},
+		{"zero ttl", []uint32{0}, 0},
+	}
+
+	for _, test := range tests {
+		checker := New(nil, time.Second*5, time.Second*300)
+		var queried []string
+		checker.exchange = func(server string, query []byte) ([]byte, error) {
+			var q dnsmessage.Message
+			err := q.Unpack(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			queried = append(queried, q.Questions[0].Name.String())
+			// only the service search domain resolves
+			if q.Questions[0].Name.String() != "kubernetes.default.svc.cluster.local." {
+				return makeResponse(t, query, dnsmessage.RCodeNameError), nil
+			}
+			return makeResponse(t, query, dnsmessage.RCodeSuccess, test.ttls...), nil
+		}
+
+		ttl, err := checker.lookupTTL("10.96.0.10", "kubernetes.default", []string{"kuberhealthy.svc.cluster.local", "svc.cluster.local", "cluster.local"})
+		if err != nil {
+			t.Fatal("Test", test.description, "returned an error:", err)
+		}
+		if ttl != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "but got", ttl)
+		}
+		if len(queried) != 2 {
+			t.Fatal("Test", test.description, "expected to stop at the first resolving search domain but queried", queried)
+		}
+	}
+
+	// a name that never resolves returns the last error
+	checker := New(nil, time.Second*5, time.Second*300)
+	checker.exchange = func(server string, query []byte) ([]byte, error) {
+		return makeResponse(t, query, dnsmessage.RCodeNameError), nil
+	}
+	_, err := checker.lookupTTL("10.96.0.10", "missing.default", []string{"svc.cluster.local"})
+	if err == nil {
+		t.Fatal("Expected an error for a name that does not resolve")
+	}
+}
+
+func TestEvaluateTTL(t *testing.T) {
+	var tests = []struct {
+		ttl      time.Duration
+		expected bool
+	}{
+		{0, true},
+		{time.Second, true},
+		{time.Second * 5, false},
+		{time.Second * 30, false},
+		{time.Second * 300, false},
+		{time.Second * 3600, true},
+	}
+
+	for _, test := range tests {
+		e := evaluateTTL("record", test.ttl, time.Second*5, time.Second*300)
+		if (len(e) > 0) != test.expected {
+			t.Fatal("Test", test.ttl, "expected error", test.expected, "but got", e)
+		}
+	}
+}
+
+func TestParseResolvConf(t *testing.T) {
+	server, search := parseResolvConf("nameserver 10.96.0.10\nnameserver 10.96.0.11\nsearch kuberhealthy.svc.cluster.local svc.cluster.local cluster.local\noptions ndots:5\n")
+	if server != "10.96.0.10" || len(search) != 3 || search[1] != "svc.cluster.local" {
+		t.Fatal("Unexpected resolv.conf parsing:", server, search)
+	}
+}
+
+func TestParseCorefileTTLs(t *testing.T) {
+	corefile := `.:53 {
+    errors
+    health
+    kubernetes cluster.local in-addr.arpa ip6.arpa {
+       pods insecure
+       fallthrough in-addr.arpa ip6.arpa
+       ttl 30
+    }
+    prometheus :9153
+    forward . /etc/resolv.conf
+    cache 30
+    loop
+    reload
+}
+example.com:53 {
+    cache {
+        success 9984 3600 # long lived external records
+        denial 9984 1
+    }
+    forward . 8.8.8.8
+}
+`
+	settings, err := parseCorefileTTLs(corefile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []corefileTTL{
+		{".:53 kubernetes ttl", time.Second * 30},
+		{".:53 cache ttl", time.Second * 30},
+		{"example.com:53 cache success ttl", time.Second * 3600},
+		{"example.com:53 cache denial ttl", time.Second},
+	}
+	if len(settings) != len(expected) {
+		t.Fatal("Expected", expected, "but got", settings)
+	}
+	for i := range settings {
+		if settings[i] != expected[i] {
+			t.Fatal("Expected", expected[i], "but got", settings[i])
+		}
+	}
+
+	_, err = parseCorefileTTLs(".:53 {\n    cache 30\n")
+	if err == nil {
+		t.Fatal("Expected an error for unbalanced braces")
+	}
+}
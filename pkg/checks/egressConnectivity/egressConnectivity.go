@@ -0,0 +1,274 @@
+// Package egressConnectivity implements a checker that ensures pods can reach
+// required services outside of the cluster, such as cloud provider APIs and
+// OCSP responders.  A test pod is run on the pod network so that connections
+// take the same egress path as other workloads.
+package egressConnectivity // import "github.com/Comcast/kuberhealthy/pkg/checks/egressConnectivity"
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// endpoint is an external service that the test pod connects to
+type endpoint struct {
+	// Raw is the endpoint as it was configured
+	Raw string
+	// Scheme is tcp, http, or https
+	Scheme string
+	// Host and Port are set for tcp endpoints
+	Host string
+	Port string
+}
+
+// Checker validates that the test pod can connect to external endpoints
+type Checker struct {
+	Errors         []string
+	Endpoints      []endpoint
+	ConnectTimeout time.Duration
+	Image          string
+	client         *kubernetes.Clientset
+	// runPod is replaced in tests to inject test pod output
+	runPod   func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (string, error)
+	interval time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied endpoints.  Endpoints are URLs in
+// the form tcp://<host>:<port>, http://<host>/<path>, or https://<host>/<path>.
+// An error is returned if no endpoints are supplied or an endpoint is invalid.
+func New(endpoints []string) (*Checker, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("no egress endpoints were supplied")
+	}
+
+	var parsed []endpoint
+	for _, e := range endpoints {
+		p, err := parseEndpoint(e)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, p)
+	}
+
+	return &Checker{
+		Errors:         []string{},
+		Endpoints:      parsed,
+		ConnectTimeout: time.Second * 10,
+		Image:          "curlimages/curl:7.66.0",
+		runPod:         podRunner.RunPod,
+	}, nil
+}
+
+// Name returns the name of this checker
+func (ecc *Checker) Name() string {
+	return "EgressConnectivityChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (ecc *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (ecc *Checker) Interval() time.Duration {
+	if ecc.interval > 0 {
+		return ecc.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (ecc *Checker) SetInterval(d time.Duration) {
+	ecc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to run its test pod in
+// the Kuberhealthy namespace
+func (ecc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		namespace: podRunner.RunPodRBACRules(),
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (ecc *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (ecc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (ecc *Checker) CurrentStatus() (bool, []string) {
+	if len(ecc.Errors) > 0 {
+		return false, ecc.Errors
+	}
+	return true, ecc.Errors
+}
+
+// clearErrors clears all errors
+func (ecc *Checker) clearErrors() {
+	ecc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (ecc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	ecc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := ecc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(ecc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + ecc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(ecc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + ecc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + ecc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks connects to every endpoint from a test pod and sets an error for
+// each endpoint that is unreachable or returns an unexpected status
+func (ecc *Checker) doChecks() error {
+
+	script := podRunner.Script{
+		Name:   "egress-connectivity",
+		Image:  ecc.Image,
+		Script: connectScript(ecc.Endpoints, ecc.ConnectTimeout),
+	}
+	// leave time to clean up the test pod before the check times out
+	output, err := ecc.runPod(ecc.client, namespace, script, ecc.Timeout()-time.Minute)
+	if err != nil {
+		return err
+	}
+
+	egressErrors := evaluateResults(output, ecc.Endpoints)
+	if len(egressErrors) > 0 {
+		for _, e := range egressErrors {
+			khlog.ForCheck(ecc.Name()).Warningln(e)
+		}
+		ecc.Errors = egressErrors
+		return nil
+	}
+
+	ecc.clearErrors()
+	return nil
+}
+
+// parseEndpoint validates an endpoint URL
+func parseEndpoint(raw string) (endpoint, error) {
+	// the url is quoted in the connection script
+	if strings.ContainsAny(raw, "' ") {
+		return endpoint{}, errors.New("egress endpoint " + raw + " must not contain quotes or spaces")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return endpoint{}, errors.New("unable to parse egress endpoint " + raw + ": " + err.Error())
+	}
+	if len(u.Host) == 0 {
+		return endpoint{}, errors.New("egress endpoint " + raw + " has no host")
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return endpoint{Raw: raw, Scheme: u.Scheme}, nil
+	case "tcp":
+		host, port, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			return endpoint{}, errors.New("egress endpoint " + raw + " must be in the form tcp://<host>:<port>")
+		}
+		return endpoint{Raw: raw, Scheme: u.Scheme, Host: host, Port: port}, nil
+	}
+	return endpoint{}, errors.New("egress endpoint " + raw + " must use the tcp, http, or https scheme")
+}
+
+// connectScript builds a script that connects to each endpoint and prints
+// one line per endpoint in the form "<index> <result>".  The result of a tcp
+// endpoint is "ok" or "failed", and the result of an http or https endpoint is
+// the status code returned, or 000 if no response was received.
+func connectScript(endpoints []endpoint, connectTimeout time.Duration) string {
+	seconds := strconv.Itoa(int(connectTimeout.Seconds()))
+	if connectTimeout < time.Second {
+		seconds = "1"
+	}
+
+	var lines []string
+	for i, e := range endpoints {
+		index := strconv.Itoa(i)
+		if e.Scheme == "tcp" {
+			lines = append(lines, "if nc -z -w "+seconds+" "+e.Host+" "+e.Port+"; then echo '"+index+" ok'; else echo '"+index+" failed'; fi")
+			continue
+		}
+		lines = append(lines, "echo \""+index+" $(curl -s -o /dev/null -w '%{http_code}' --max-time "+seconds+" '"+e.Raw+"')\"")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// evaluateResults parses the output of the connection script and returns an
+// error for every endpoint that could not be reached.  HTTP endpoints must
+// return a 2xx or 3xx status.
+func evaluateResults(output string, endpoints []endpoint) []string {
+	var egressErrors []string
+
+	results := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		results[fields[0]] = fields[1]
+	}
+
+	for i, e := range endpoints {
+		result, ok := results[strconv.Itoa(i)]
+		if !ok {
+			egressErrors = append(egressErrors, "Egress endpoint "+e.Raw+" was not checked by the test pod")
+			continue
+		}
+
+		if e.Scheme == "tcp" {
+			if result != "ok" {
+				egressErrors = append(egressErrors, "Egress endpoint "+e.Raw+" did not accept a TCP connection from the test pod")
+			}
+			continue
+		}
+
+		status, err := strconv.Atoi(result)
+		if err != nil || status == 0 {
+			egressErrors = append(egressErrors, "Egress endpoint "+e.Raw+" is unreachable from the test pod")
+			continue
+		}
+		if status < 200 || status >= 400 {
+			egressErrors = append(egressErrors, "Egress endpoint "+e.Raw+" returned unexpected HTTP status "+result+" to the test pod")
+		}
+	}
+	return egressErrors
+}
@@ -0,0 +1,106 @@
+package egressConnectivity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestNew(t *testing.T) {
+	var tests = []struct {
+		endpoints []string
+		valid     bool
+	}{
+		{[]string{"https://sts.amazonaws.com", "tcp://ocsp.example.com:80"}, true},
+		{[]string{"http://example.com/health"}, true},
+		{[]string{}, false},
+		{[]string{"tcp://example.com"}, false},
+		{[]string{"ftp://example.com"}, false},
+		{[]string{"example.com:443"}, false},
+		{[]string{"https://example.com/'; rm"}, false},
+	}
+
+	for _, test := range tests {
+		_, err := New(test.endpoints)
+		if test.valid != (err == nil) {
+			t.Fatal("Test", test.endpoints, "expected valid to be", test.valid, "but got error", err)
+		}
+	}
+}
+
+func TestEvaluateResults(t *testing.T) {
+	checker, err := New([]string{"https://sts.amazonaws.com", "tcp://ocsp.example.com:80"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		description string
+		output      string
+		expected    int
+	}{
+		{"all reachable", "0 200\n1 ok", 0},
+		{"redirect", "0 302\n1 ok", 0},
+		{"server error", "0 503\n1 ok", 1},
+		{"no response", "0 000\n1 ok", 1},
+		{"tcp failed", "0 200\n1 failed", 1},
+		{"missing result", "0 200", 1},
+		{"no output", "", 2},
+	}
+
+	for _, test := range tests {
+		egressErrors := evaluateResults(test.output, checker.Endpoints)
+		if len(egressErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", egressErrors)
+		}
+		t.Log(test.description, egressErrors)
+	}
+}
+
+func TestConnectScript(t *testing.T) {
+	checker, err := New([]string{"https://sts.amazonaws.com", "tcp://ocsp.example.com:80"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := connectScript(checker.Endpoints, checker.ConnectTimeout)
+	if !strings.Contains(script, "--max-time 10 'https://sts.amazonaws.com'") || !strings.Contains(script, "nc -z -w 10 ocsp.example.com 80") {
+		t.Fatal("Unexpected connection script:", script)
+	}
+	t.Log(script)
+}
+
+func TestDoChecks(t *testing.T) {
+	checker, err := New([]string{"https://sts.amazonaws.com", "tcp://ocsp.example.com:80"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker.runPod = func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (string, error) {
+		if script.HostNetwork {
+			t.Fatal("Expected the test pod to use the pod network")
+		}
+		return "0 403\n1 ok", nil
+	}
+
+	err = checker.doChecks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, checkErrors := checker.CurrentStatus()
+	if ok || len(checkErrors) != 1 {
+		t.Fatal("Expected one error for the forbidden endpoint but got", checkErrors)
+	}
+	t.Log(checkErrors)
+
+	// a test pod that fails to run is a system error
+	checker.runPod = func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (string, error) {
+		return "", errors.New("Timed out waiting for pod to complete its script")
+	}
+	err = checker.doChecks()
+	if err == nil {
+		t.Fatal("Expected an error when the test pod fails to run")
+	}
+}
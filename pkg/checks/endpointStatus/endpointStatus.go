@@ -0,0 +1,264 @@
+// Package endpointStatus implements a checker that finds Services without
+// ready endpoints.  When the pods behind a Service are Running but fail their
+// readiness probes, the Endpoints of the Service are empty and all traffic
+// sent to it is dropped while the pods look healthy to other checks.
+package endpointStatus // import "github.com/Comcast/kuberhealthy/pkg/checks/endpointStatus"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// IgnoreAnnotation excludes a Service from the check when set to true, such
+// as for Services that are intentionally scaled to zero
+const IgnoreAnnotation = "kuberhealthy.io/ignore"
+
+// Checker validates that Services have ready endpoints
+type Checker struct {
+	FailureTimeStamp   map[string]time.Time
+	Errors             []string
+	Namespaces         []string
+	GracePeriod        time.Duration
+	defaultGracePeriod time.Duration // the grace period the check was created with
+	client             *kubernetes.Clientset
+	interval           time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied.  Services are reported once they have had no ready
+// endpoints for longer than gracePeriod.
+func New(namespaces []string, gracePeriod time.Duration) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		FailureTimeStamp:   make(map[string]time.Time),
+		Errors:             []string{},
+		Namespaces:         namespaces,
+		GracePeriod:        gracePeriod,
+		defaultGracePeriod: gracePeriod,
+	}
+}
+
+// Name returns the name of this checker
+func (esc *Checker) Name() string {
+	return "EndpointStatusChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (esc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (esc *Checker) Interval() time.Duration {
+	if esc.interval > 0 {
+		return esc.interval
+	}
+	return time.Minute * 2
+}
+
+// SetInterval overrides the interval at which this check runs
+func (esc *Checker) SetInterval(d time.Duration) {
+	esc.interval = d
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (esc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Reconfigure sets the grace period from the 'gracePeriod' runtime setting,
+// or back to the grace period the check was created with when it is not
+// given
+func (esc *Checker) Reconfigure(settings map[string]string) error {
+	gracePeriod := esc.defaultGracePeriod
+	for setting, value := range settings {
+		if setting != "gracePeriod" {
+			return errors.New("unknown setting " + setting)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return errors.New("gracePeriod must be a duration but is '" + value + "'")
+		}
+		gracePeriod = d
+	}
+	esc.GracePeriod = gracePeriod
+	return nil
+}
+
+// RBACRules returns the RBAC rules this check needs in each checked
+// namespace, or cluster wide when all namespaces are checked
+func (esc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range esc.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"services", "endpoints"}, Verbs: []string{"list"}},
+		}
+	}
+	return rules
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (esc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (esc *Checker) CurrentStatus() (bool, []string) {
+	if len(esc.Errors) > 0 {
+		return false, esc.Errors
+	}
+	return true, esc.Errors
+}
+
+// clearErrors clears all errors
+func (esc *Checker) clearErrors() {
+	esc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (esc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	esc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := esc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(esc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + esc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(esc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + esc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + esc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists Services and Endpoints in each namespace and sets an error
+// for every Service that has had no ready endpoints for longer than the
+// grace period
+func (esc *Checker) doChecks() error {
+
+	var services []apiv1.Service
+	var endpoints []apiv1.Endpoints
+	for _, ns := range esc.Namespaces {
+		serviceList, err := esc.client.CoreV1().Services(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return errors.New("Error listing Services in namespace " + ns + ": " + err.Error())
+		}
+		services = append(services, serviceList.Items...)
+
+		endpointsList, err := esc.client.CoreV1().Endpoints(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return errors.New("Error listing Endpoints in namespace " + ns + ": " + err.Error())
+		}
+		endpoints = append(endpoints, endpointsList.Items...)
+	}
+
+	statusErrors := evaluateServices(services, endpoints, esc.FailureTimeStamp, esc.GracePeriod, time.Now())
+
+	if len(statusErrors) > 0 {
+		for _, e := range statusErrors {
+			khlog.ForCheck(esc.Name()).Warningln(e)
+		}
+		esc.Errors = statusErrors
+		return nil
+	}
+
+	esc.clearErrors()
+	return nil
+}
+
+// evaluateServices returns an error for every ClusterIP and LoadBalancer
+// Service that has had no ready endpoints for longer than gracePeriod.
+// Services without a selector manage their own Endpoints and are skipped, as
+// are Services with the ignore annotation.  The time each Service was first
+// seen without ready endpoints is tracked in failureTimeStamp, and Services
+// that recovered or no longer exist are removed from it.
+func evaluateServices(services []apiv1.Service, endpoints []apiv1.Endpoints, failureTimeStamp map[string]time.Time, gracePeriod time.Duration, now time.Time) []string {
+	var statusErrors []string
+
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Namespace != services[j].Namespace {
+			return services[i].Namespace < services[j].Namespace
+		}
+		return services[i].Name < services[j].Name
+	})
+
+	// Endpoints have the same name as their Service
+	endpointsByService := make(map[string]apiv1.Endpoints)
+	for _, e := range endpoints {
+		endpointsByService[e.Namespace+"/"+e.Name] = e
+	}
+
+	empty := make(map[string]bool)
+	for _, s := range services {
+		if s.Spec.Type != apiv1.ServiceTypeClusterIP && s.Spec.Type != apiv1.ServiceTypeLoadBalancer {
+			continue
+		}
+		if len(s.Spec.Selector) == 0 || s.Annotations[IgnoreAnnotation] == "true" {
+			continue
+		}
+
+		key := s.Namespace + "/" + s.Name
+		ready, notReady := countAddresses(endpointsByService[key])
+		if ready > 0 {
+			continue
+		}
+
+		empty[key] = true
+		if _, ok := failureTimeStamp[key]; !ok {
+			failureTimeStamp[key] = now
+		}
+		emptyFor := now.Sub(failureTimeStamp[key])
+		if emptyFor <= gracePeriod {
+			continue
+		}
+
+		e := "Service " + s.Name + " in namespace " + s.Namespace + " has had no ready endpoints for " + emptyFor.Round(time.Second).String() + "."
+		if notReady > 0 {
+			e += " " + strconv.Itoa(notReady) + " endpoints are not ready."
+		}
+		statusErrors = append(statusErrors, e)
+	}
+
+	for key := range failureTimeStamp {
+		if !empty[key] {
+			delete(failureTimeStamp, key)
+		}
+	}
+	return statusErrors
+}
+
+// countAddresses returns the number of ready and not ready addresses in
+// Endpoints
+func countAddresses(e apiv1.Endpoints) (int, int) {
+	var ready, notReady int
+	for _, subset := range e.Subsets {
+		ready += len(subset.Addresses)
+		notReady += len(subset.NotReadyAddresses)
+	}
+	return ready, notReady
+}
@@ -0,0 +1,101 @@
+package endpointStatus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateServices(t *testing.T) {
+	now := time.Now()
+
+	makeService := func(serviceType apiv1.ServiceType, selector map[string]string, annotations map[string]string) apiv1.Service {
+		return apiv1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: annotations},
+			Spec:       apiv1.ServiceSpec{Type: serviceType, Selector: selector},
+		}
+	}
+	makeEndpoints := func(ready int, notReady int) []apiv1.Endpoints {
+		subset := apiv1.EndpointSubset{}
+		for i := 0; i < ready; i++ {
+			subset.Addresses = append(subset.Addresses, apiv1.EndpointAddress{IP: "10.0.0.1"})
+		}
+		for i := 0; i < notReady; i++ {
+			subset.NotReadyAddresses = append(subset.NotReadyAddresses, apiv1.EndpointAddress{IP: "10.0.0.2"})
+		}
+		return []apiv1.Endpoints{
+			{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}, Subsets: []apiv1.EndpointSubset{subset}},
+		}
+	}
+	selector := map[string]string{"app": "web"}
+
+	var tests = []struct {
+		description   string
+		service       apiv1.Service
+		endpoints     []apiv1.Endpoints
+		emptyFor      time.Duration // how long the Service was already tracked without ready endpoints
+		expectedError string
+	}{
+		{"ready endpoints", makeService(apiv1.ServiceTypeClusterIP, selector, nil), makeEndpoints(2, 1), time.Minute * 10, ""},
+		{"newly empty", makeService(apiv1.ServiceTypeClusterIP, selector, nil), makeEndpoints(0, 2), 0, ""},
+		{"empty within grace period", makeService(apiv1.ServiceTypeClusterIP, selector, nil), makeEndpoints(0, 2), time.Minute, ""},
+		{"empty past grace period", makeService(apiv1.ServiceTypeClusterIP, selector, nil), makeEndpoints(0, 2), time.Minute * 10, "Service web in namespace default has had no ready endpoints for 10m0s. 2 endpoints are not ready."},
+		{"no Endpoints object", makeService(apiv1.ServiceTypeLoadBalancer, selector, nil), nil, time.Minute * 10, "Service web in namespace default has had no ready endpoints for 10m0s."},
+		{"NodePort is not checked", makeService(apiv1.ServiceTypeNodePort, selector, nil), nil, time.Minute * 10, ""},
+		{"no selector", makeService(apiv1.ServiceTypeClusterIP, nil, nil), nil, time.Minute * 10, ""},
+		{"ignored", makeService(apiv1.ServiceTypeClusterIP, selector, map[string]string{IgnoreAnnotation: "true"}), nil, time.Minute * 10, ""},
+	}
+
+	for _, test := range tests {
+		failureTimeStamp := make(map[string]time.Time)
+		if test.emptyFor > 0 {
+			failureTimeStamp["default/web"] = now.Add(-test.emptyFor)
+		}
+		statusErrors := evaluateServices([]apiv1.Service{test.service}, test.endpoints, failureTimeStamp, time.Minute*5, now)
+		if len(test.expectedError) == 0 {
+			if len(statusErrors) != 0 {
+				t.Fatal("Test", test.description, "expected no errors but got", statusErrors)
+			}
+			continue
+		}
+		if len(statusErrors) != 1 || !strings.Contains(statusErrors[0], test.expectedError) {
+			t.Fatal("Test", test.description, "expected an error containing", test.expectedError, "but got", statusErrors)
+		}
+		t.Log(test.description, statusErrors)
+	}
+}
+
+func TestFailureTimeStamp(t *testing.T) {
+	now := time.Now()
+	service := apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       apiv1.ServiceSpec{Type: apiv1.ServiceTypeClusterIP, Selector: map[string]string{"app": "web"}},
+	}
+	failureTimeStamp := map[string]time.Time{"default/deleted": now.Add(-time.Hour)}
+
+	evaluateServices([]apiv1.Service{service}, nil, failureTimeStamp, time.Minute*5, now)
+	if !failureTimeStamp["default/web"].Equal(now) {
+		t.Fatal("Expected a Service without ready endpoints to be tracked from now but got", failureTimeStamp)
+	}
+	if _, ok := failureTimeStamp["default/deleted"]; ok {
+		t.Fatal("Expected a Service that no longer exists to stop being tracked")
+	}
+
+	// the first time is kept while the Service stays without ready endpoints
+	evaluateServices([]apiv1.Service{service}, nil, failureTimeStamp, time.Minute*5, now.Add(time.Minute))
+	if !failureTimeStamp["default/web"].Equal(now) {
+		t.Fatal("Expected the first empty time to be kept but got", failureTimeStamp)
+	}
+
+	endpoints := []apiv1.Endpoints{{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Subsets:    []apiv1.EndpointSubset{{Addresses: []apiv1.EndpointAddress{{IP: "10.0.0.1"}}}},
+	}}
+	evaluateServices([]apiv1.Service{service}, endpoints, failureTimeStamp, time.Minute*5, now.Add(time.Minute*2))
+	if len(failureTimeStamp) != 0 {
+		t.Fatal("Expected a recovered Service to stop being tracked but got", failureTimeStamp)
+	}
+}
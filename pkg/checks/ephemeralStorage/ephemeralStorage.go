@@ -0,0 +1,289 @@
+// Package ephemeralStorage implements a checker that warns about pods that
+// are close to their ephemeral storage limit.  Pods that exceed the limit
+// are evicted, but nothing flags them before then.  A DaemonSet pod on each
+// node reads pod ephemeral storage usage from the kubelet's summary API.
+package ephemeralStorage // import "github.com/Comcast/kuberhealthy/pkg/checks/ephemeralStorage"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// summaryScript fetches the stats summary from the kubelet on the node the
+// pod runs on, authenticating with the pod's service account
+const summaryScript = `curl -sk -H "Authorization: Bearer $(cat /var/run/secrets/kubernetes.io/serviceaccount/token)" https://127.0.0.1:10250/stats/summary`
+
+// summary is the subset of the kubelet stats summary used by this check
+type summary struct {
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		EphemeralStorage *struct {
+			UsedBytes *int64 `json:"usedBytes"`
+		} `json:"ephemeral-storage"`
+	} `json:"pods"`
+}
+
+// Checker validates that pods are not close to their ephemeral storage
+// limits
+type Checker struct {
+	Errors         []string
+	WarningPercent float64
+	Image          string
+	client         *kubernetes.Clientset
+	// runOnNodes is replaced in tests to inject kubelet summary responses
+	runOnNodes func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (map[string]string, error)
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reports pods using more than
+// warningPercent of their ephemeral storage limit
+func New(warningPercent float64) *Checker {
+	return &Checker{
+		Errors:         []string{},
+		WarningPercent: warningPercent,
+		Image:          "curlimages/curl:7.66.0",
+		runOnNodes:     podRunner.RunOnNodes,
+	}
+}
+
+// Name returns the name of this checker
+func (esc *Checker) Name() string {
+	return "EphemeralStorageChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (esc *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (esc *Checker) Interval() time.Duration {
+	if esc.interval > 0 {
+		return esc.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (esc *Checker) SetInterval(d time.Duration) {
+	esc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Besides listing pods
+// in every namespace, the kubelet authorizes the summary requests made with
+// Kuberhealthy's service account against the stats of nodes.
+func (esc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		namespace: append(podRunner.RunOnNodesRBACRules(),
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+		),
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+			{APIGroups: []string{""}, Resources: []string{"nodes/stats"}, Verbs: []string{"get"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (esc *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (esc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (esc *Checker) CurrentStatus() (bool, []string) {
+	if len(esc.Errors) > 0 {
+		return false, esc.Errors
+	}
+	return true, esc.Errors
+}
+
+// clearErrors clears all errors
+func (esc *Checker) clearErrors() {
+	esc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (esc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	esc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := esc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(esc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + esc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(esc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + esc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + esc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks reads the kubelet summary of every node and sets an error for
+// every pod using more than the warning percent of its ephemeral storage
+// limit
+func (esc *Checker) doChecks() error {
+
+	pods, err := esc.client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing pods: " + err.Error())
+	}
+
+	// the kubelet authorizes the summary request with kuberhealthy's own
+	// service account
+	serviceAccount, err := podRunner.CurrentServiceAccount(esc.client, namespace)
+	if err != nil {
+		return err
+	}
+
+	script := podRunner.Script{
+		Name:           "ephemeral-storage",
+		Image:          esc.Image,
+		Script:         summaryScript,
+		HostNetwork:    true,
+		ServiceAccount: serviceAccount,
+	}
+
+	// leave time to clean up the DaemonSet before the check times out
+	output, err := esc.runOnNodes(esc.client, namespace, script, esc.Timeout()-time.Minute)
+	if err != nil && len(output) == 0 {
+		return err
+	}
+
+	var storageErrors []string
+	if err != nil {
+		storageErrors = append(storageErrors, err.Error())
+	}
+	storageErrors = append(storageErrors, evaluateNodes(output, pods.Items, esc.WarningPercent)...)
+
+	if len(storageErrors) > 0 {
+		for _, e := range storageErrors {
+			khlog.ForCheck(esc.Name()).Warningln(e)
+		}
+		esc.Errors = storageErrors
+		return nil
+	}
+
+	esc.clearErrors()
+	return nil
+}
+
+// evaluateNodes decodes the kubelet summary of each node and returns an
+// error for every pod using more than warningPercent of its ephemeral
+// storage limit.  Pods without a limit are compared to their request, and
+// pods with neither are skipped.
+func evaluateNodes(output map[string]string, pods []apiv1.Pod, warningPercent float64) []string {
+	var storageErrors []string
+
+	podsByName := make(map[string]apiv1.Pod)
+	for _, p := range pods {
+		podsByName[p.Namespace+"/"+p.Name] = p
+	}
+
+	var nodes []string
+	for node := range output {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	for _, node := range nodes {
+		var s summary
+		err := json.Unmarshal([]byte(output[node]), &s)
+		if err != nil {
+			storageErrors = append(storageErrors, "Error decoding kubelet summary from node "+node+": "+err.Error())
+			continue
+		}
+
+		for _, ps := range s.Pods {
+			if ps.EphemeralStorage == nil || ps.EphemeralStorage.UsedBytes == nil {
+				continue
+			}
+			name := ps.PodRef.Namespace + "/" + ps.PodRef.Name
+			p, ok := podsByName[name]
+			if !ok {
+				continue
+			}
+			allowed, kind := ephemeralStorageAllowance(p)
+			if allowed <= 0 {
+				continue
+			}
+
+			used := *ps.EphemeralStorage.UsedBytes
+			percent := float64(used) / float64(allowed) * 100
+			if percent > warningPercent {
+				storageErrors = append(storageErrors, "Pod "+name+" on node "+node+" is using "+strconv.FormatFloat(percent, 'f', 1, 64)+
+					"% of its ephemeral storage "+kind+" ("+mebibytes(used)+" of "+mebibytes(allowed)+")")
+			}
+		}
+	}
+	return storageErrors
+}
+
+// ephemeralStorageAllowance returns the ephemeral storage limit of a pod in
+// bytes and "limit", or its request and "request" when none of its
+// containers have a limit.  Init containers run one at a time, so the
+// largest init container value is used when it exceeds the containers' sum.
+func ephemeralStorageAllowance(p apiv1.Pod) (int64, string) {
+	limit := podEphemeralStorage(p, func(c apiv1.Container) apiv1.ResourceList { return c.Resources.Limits })
+	if limit > 0 {
+		return limit, "limit"
+	}
+	return podEphemeralStorage(p, func(c apiv1.Container) apiv1.ResourceList { return c.Resources.Requests }), "request"
+}
+
+// podEphemeralStorage returns the effective ephemeral storage of a pod for
+// the resource list returned by resources
+func podEphemeralStorage(p apiv1.Pod, resources func(c apiv1.Container) apiv1.ResourceList) int64 {
+	var total int64
+	for _, c := range p.Spec.Containers {
+		if q, ok := resources(c)[apiv1.ResourceEphemeralStorage]; ok {
+			total += q.Value()
+		}
+	}
+	for _, c := range p.Spec.InitContainers {
+		if q, ok := resources(c)[apiv1.ResourceEphemeralStorage]; ok && q.Value() > total {
+			total = q.Value()
+		}
+	}
+	return total
+}
+
+// mebibytes formats a number of bytes in whole mebibytes
+func mebibytes(b int64) string {
+	return strconv.FormatInt(b/(1024*1024), 10) + "Mi"
+}
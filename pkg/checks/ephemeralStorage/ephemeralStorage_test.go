@@ -0,0 +1,78 @@
+package ephemeralStorage
+
+import (
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// summaryJSON is a kubelet stats summary reporting the ephemeral storage
+// used by pods on a node
+const summaryJSON = `{"node": {"nodeName": "node-a"}, "pods": [
+	{"podRef": {"name": "web", "namespace": "default"}, "ephemeral-storage": {"usedBytes": 900000000}},
+	{"podRef": {"name": "batch", "namespace": "default"}, "ephemeral-storage": {"usedBytes": 500000000}},
+	{"podRef": {"name": "cache", "namespace": "default"}, "ephemeral-storage": {"usedBytes": 950000000}},
+	{"podRef": {"name": "unbounded", "namespace": "default"}, "ephemeral-storage": {"usedBytes": 50000000000}},
+	{"podRef": {"name": "nostats", "namespace": "default"}}
+]}`
+
+func TestEvaluateNodes(t *testing.T) {
+	makePod := func(name string, limit string, request string) apiv1.Pod {
+		c := apiv1.Container{Name: name, Resources: apiv1.ResourceRequirements{
+			Limits:   apiv1.ResourceList{},
+			Requests: apiv1.ResourceList{},
+		}}
+		if len(limit) > 0 {
+			c.Resources.Limits[apiv1.ResourceEphemeralStorage] = resource.MustParse(limit)
+		}
+		if len(request) > 0 {
+			c.Resources.Requests[apiv1.ResourceEphemeralStorage] = resource.MustParse(request)
+		}
+		return apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec:       apiv1.PodSpec{Containers: []apiv1.Container{c}},
+		}
+	}
+
+	pods := []apiv1.Pod{
+		makePod("web", "1G", ""),
+		makePod("batch", "1G", ""),
+		makePod("cache", "", "1G"),
+		makePod("unbounded", "", ""),
+		makePod("nostats", "1G", ""),
+	}
+
+	var tests = []struct {
+		description    string
+		output         map[string]string
+		warningPercent float64
+		expected       []string
+	}{
+		{"default threshold", map[string]string{"node-a": summaryJSON}, 80, []string{"default/web on node node-a is using 90.0% of its ephemeral storage limit", "default/cache on node node-a is using 95.0% of its ephemeral storage request"}},
+		{"high threshold", map[string]string{"node-a": summaryJSON}, 99, nil},
+		{"low threshold", map[string]string{"node-a": summaryJSON}, 40, []string{"default/batch", "default/cache", "default/web"}},
+		{"invalid summary", map[string]string{"node-b": "Unauthorized"}, 80, []string{"Error decoding kubelet summary from node node-b"}},
+	}
+
+	for _, test := range tests {
+		storageErrors := evaluateNodes(test.output, pods, test.warningPercent)
+		if len(storageErrors) != len(test.expected) {
+			t.Fatal("Test", test.description, "expected", len(test.expected), "errors but got", storageErrors)
+		}
+		for _, expected := range test.expected {
+			found := false
+			for _, e := range storageErrors {
+				if strings.Contains(e, expected) {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatal("Test", test.description, "expected an error containing", expected, "but got", storageErrors)
+			}
+		}
+		t.Log(test.description, storageErrors)
+	}
+}
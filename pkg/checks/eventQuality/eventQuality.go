@@ -0,0 +1,292 @@
+// Package eventQuality implements a checker that finds applications misusing
+// the Kubernetes events API for logging.  Recent events are reported when
+// they come from a component that is not allowed, or when their reason looks
+// like application output rather than a short machine readable reason, such
+// as excessively long reasons or reasons containing SQL injection patterns.
+package eventQuality // import "github.com/Comcast/kuberhealthy/pkg/checks/eventQuality"
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultSources are the Kubernetes components that are always allowed to
+// emit events
+var DefaultSources = []string{
+	"kubelet",
+	"default-scheduler",
+	"kube-proxy",
+	"kube-controller-manager",
+	"attachdetach-controller",
+	"cloud-node-controller",
+	"cronjob-controller",
+	"daemonset-controller",
+	"deployment-controller",
+	"endpoint-controller",
+	"horizontal-pod-autoscaler",
+	"job-controller",
+	"node-controller",
+	"persistentvolume-controller",
+	"replicaset-controller",
+	"replication-controller",
+	"service-controller",
+	"statefulset-controller",
+	"taint-controller",
+	"cluster-autoscaler",
+}
+
+// sqlInjectionPatterns match reasons that contain SQL injection attempts or
+// SQL statements
+var sqlInjectionPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)['"]\s*(or|and)\s+['"]?\w+['"]?\s*=\s*['"]?\w+`),
+	regexp.MustCompile(`(?i)\bunion\s+(all\s+)?select\b`),
+	regexp.MustCompile(`(?i)\b(drop|truncate|alter)\s+table\b`),
+	regexp.MustCompile(`(?i)\b(select\s+.+\s+from|insert\s+into|delete\s+from|update\s+\w+\s+set)\b`),
+	regexp.MustCompile(`(;|'|")\s*--`),
+}
+
+// maxReportedReason is the length reasons are truncated to in errors
+const maxReportedReason = 64
+
+// Checker validates the source and reason of recent events
+type Checker struct {
+	Errors          []string
+	AllowedSources  []string
+	MaxReasonLength int
+	client          *kubernetes.Clientset
+	interval        time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that allows events from the default sources and
+// the supplied sources, with reasons no longer than maxReasonLength
+func New(allowedSources []string, maxReasonLength int) *Checker {
+	return &Checker{
+		Errors:          []string{},
+		AllowedSources:  append(append([]string{}, DefaultSources...), allowedSources...),
+		MaxReasonLength: maxReasonLength,
+	}
+}
+
+// Name returns the name of this checker
+func (eqc *Checker) Name() string {
+	return "EventQualityChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (eqc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (eqc *Checker) Interval() time.Duration {
+	if eqc.interval > 0 {
+		return eqc.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (eqc *Checker) SetInterval(d time.Duration) {
+	eqc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to list events in
+// every namespace
+func (eqc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (eqc *Checker) Timeout() time.Duration {
+	return time.Minute * 2
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (eqc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (eqc *Checker) CurrentStatus() (bool, []string) {
+	if len(eqc.Errors) > 0 {
+		return false, eqc.Errors
+	}
+	return true, eqc.Errors
+}
+
+// clearErrors clears all errors
+func (eqc *Checker) clearErrors() {
+	eqc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (eqc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	eqc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := eqc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(eqc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + eqc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(eqc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + eqc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + eqc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists the events seen within the last interval and sets an error
+// for every misused source or reason
+func (eqc *Checker) doChecks() error {
+
+	events, err := eqc.client.CoreV1().Events(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing events: " + err.Error())
+	}
+
+	eventErrors := eqc.evaluateEvents(events.Items, time.Now().Add(-eqc.Interval()))
+
+	if len(eventErrors) > 0 {
+		for _, e := range eventErrors {
+			khlog.ForCheck(eqc.Name()).Warningln(e)
+		}
+		eqc.Errors = eventErrors
+		return nil
+	}
+
+	eqc.clearErrors()
+	return nil
+}
+
+// evaluateEvents returns an error for every source that is not allowed and
+// every reason that is too long or contains SQL, among the events last seen
+// after since.  Events with the same source or reason are reported once with
+// their count.
+func (eqc *Checker) evaluateEvents(events []apiv1.Event, since time.Time) []string {
+	unknownSources := make(map[string]int)
+	longReasons := make(map[string]int)
+	sqlReasons := make(map[string]int)
+
+	for _, e := range events {
+		if lastSeen(e).Before(since) {
+			continue
+		}
+
+		source := eventSource(e)
+		if !containsString(eqc.AllowedSources, source) {
+			unknownSources[source]++
+		}
+
+		switch {
+		case eqc.MaxReasonLength > 0 && len(e.Reason) > eqc.MaxReasonLength:
+			longReasons[e.Reason]++
+		case looksLikeSQL(e.Reason):
+			sqlReasons[e.Reason]++
+		}
+	}
+
+	var eventErrors []string
+	for _, source := range sortedKeys(unknownSources) {
+		name := source
+		if len(name) == 0 {
+			name = "<empty>"
+		}
+		eventErrors = append(eventErrors, strconv.Itoa(unknownSources[source])+" events from unknown source "+name)
+	}
+	for _, reason := range sortedKeys(longReasons) {
+		eventErrors = append(eventErrors, strconv.Itoa(longReasons[reason])+" events with a reason of "+strconv.Itoa(len(reason))+" characters, longer than the maximum of "+strconv.Itoa(eqc.MaxReasonLength)+": "+truncate(reason))
+	}
+	for _, reason := range sortedKeys(sqlReasons) {
+		eventErrors = append(eventErrors, strconv.Itoa(sqlReasons[reason])+" events with a reason containing SQL: "+truncate(reason))
+	}
+	return eventErrors
+}
+
+// eventSource returns the component that emitted an event.  Events created
+// through the events.k8s.io API set the reporting controller instead of the
+// source.
+func eventSource(e apiv1.Event) string {
+	if len(e.Source.Component) > 0 {
+		return e.Source.Component
+	}
+	return e.ReportingController
+}
+
+// lastSeen returns the last time an event occurred
+func lastSeen(e apiv1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	case !e.FirstTimestamp.IsZero():
+		return e.FirstTimestamp.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+// looksLikeSQL determines if a reason matches any SQL injection pattern
+func looksLikeSQL(reason string) bool {
+	for _, re := range sqlInjectionPatterns {
+		if re.MatchString(reason) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate shortens a reason for display in an error
+func truncate(reason string) string {
+	if len(reason) <= maxReportedReason {
+		return reason
+	}
+	return reason[:maxReportedReason] + "..."
+}
+
+// sortedKeys returns the keys of a count map in order
+func sortedKeys(counts map[string]int) []string {
+	var keys []string
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// containsString determines if a string is in a slice
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
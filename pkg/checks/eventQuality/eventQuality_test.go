@@ -0,0 +1,73 @@
+package eventQuality
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateEvents(t *testing.T) {
+	now := time.Now()
+
+	makeEvent := func(component string, reason string, age time.Duration) apiv1.Event {
+		return apiv1.Event{
+			ObjectMeta:    metav1.ObjectMeta{Namespace: "default", Name: "web.15a"},
+			Source:        apiv1.EventSource{Component: component},
+			Reason:        reason,
+			LastTimestamp: metav1.NewTime(now.Add(-age)),
+		}
+	}
+
+	reportingController := makeEvent("", "Scheduled", time.Minute)
+	reportingController.ReportingController = "default-scheduler"
+
+	var tests = []struct {
+		description string
+		event       apiv1.Event
+		expected    int
+	}{
+		{"kubelet", makeEvent("kubelet", "Pulled", time.Minute), 0},
+		{"configured source", makeEvent("cert-manager", "Issued", time.Minute), 0},
+		{"unknown source", makeEvent("payments-api", "Processed", time.Minute), 1},
+		{"empty source", makeEvent("", "Processed", time.Minute), 1},
+		{"reporting controller", reportingController, 0},
+		{"old event", makeEvent("payments-api", "Processed", time.Hour), 0},
+		{"long reason", makeEvent("kubelet", strings.Repeat("Failed", 20), time.Minute), 1},
+		{"sql tautology", makeEvent("kubelet", "' OR '1'='1", time.Minute), 1},
+		{"sql union", makeEvent("kubelet", "x UNION SELECT password FROM users", time.Minute), 1},
+		{"sql drop", makeEvent("kubelet", "Robert'); DROP TABLE students;--", time.Minute), 1},
+		{"camel case reason", makeEvent("kubelet", "FailedCreatePodSandBox", time.Minute), 0},
+		{"unknown source with long reason", makeEvent("payments-api", strings.Repeat("x", 65), time.Minute), 2},
+	}
+
+	for _, test := range tests {
+		checker := New([]string{"cert-manager"}, 64)
+		eventErrors := checker.evaluateEvents([]apiv1.Event{test.event}, now.Add(-checker.Interval()))
+		if len(eventErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", eventErrors)
+		}
+		t.Log(test.description, eventErrors)
+	}
+}
+
+func TestEvaluateEventsGrouping(t *testing.T) {
+	now := time.Now()
+
+	var events []apiv1.Event
+	for i := 0; i < 3; i++ {
+		events = append(events, apiv1.Event{
+			Source:        apiv1.EventSource{Component: "payments-api"},
+			Reason:        "Processed",
+			LastTimestamp: metav1.NewTime(now),
+		})
+	}
+
+	checker := New(nil, 64)
+	eventErrors := checker.evaluateEvents(events, now.Add(-time.Minute))
+	if len(eventErrors) != 1 || !strings.HasPrefix(eventErrors[0], "3 events") {
+		t.Fatal("Expected one error counting 3 events but got", eventErrors)
+	}
+}
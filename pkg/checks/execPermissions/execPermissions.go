@@ -0,0 +1,291 @@
+// Package execPermissions implements a checker that audits which subjects are
+// able to exec into or attach to pods.  Pod exec and attach are often used for
+// lateral movement, so cluster-wide grants to subjects that are not operators
+// are reported as critical.  Grants limited to a namespace are acceptable for
+// developers and are only logged.
+package execPermissions // import "github.com/Comcast/kuberhealthy/pkg/checks/execPermissions"
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// execSubresources are the pod subresources that allow running commands in
+// or attaching to a container
+var execSubresources = []string{"pods/exec", "pods/attach"}
+
+// Checker audits RBAC bindings that grant pod exec and attach
+type Checker struct {
+	Errors          []string
+	AllowedSubjects []string
+	client          *kubernetes.Clientset
+	interval        time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker.  Subjects in allowedSubjects are treated as
+// operators.  Users and groups are listed by name and service accounts are
+// listed as system:serviceaccount:<namespace>:<name>.
+func New(allowedSubjects []string) *Checker {
+	return &Checker{
+		Errors:          []string{},
+		AllowedSubjects: allowedSubjects,
+	}
+}
+
+// Name returns the name of this checker
+func (epc *Checker) Name() string {
+	return "ExecPermissionsChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (epc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (epc *Checker) Interval() time.Duration {
+	if epc.interval > 0 {
+		return epc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (epc *Checker) SetInterval(d time.Duration) {
+	epc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Every role and
+// binding in the cluster is listed.
+func (epc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{rbacv1.GroupName}, Resources: []string{"clusterroles", "roles", "clusterrolebindings", "rolebindings"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (epc *Checker) Timeout() time.Duration {
+	return time.Minute * 2
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (epc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (epc *Checker) CurrentStatus() (bool, []string) {
+	if len(epc.Errors) > 0 {
+		return false, epc.Errors
+	}
+	return true, epc.Errors
+}
+
+// clearErrors clears all errors
+func (epc *Checker) clearErrors() {
+	epc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (epc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	epc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := epc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(epc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + epc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(epc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + epc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + epc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists all roles and bindings and sets an error for every
+// cluster-wide exec or attach grant to a subject that is not an operator
+func (epc *Checker) doChecks() error {
+
+	clusterRoles, err := epc.client.RbacV1().ClusterRoles().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	roles, err := epc.client.RbacV1().Roles(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	clusterRoleBindings, err := epc.client.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	roleBindings, err := epc.client.RbacV1().RoleBindings(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	critical, namespaced := evaluateBindings(clusterRoles.Items, roles.Items, clusterRoleBindings.Items, roleBindings.Items, epc.AllowedSubjects)
+	for _, n := range namespaced {
+		khlog.ForCheck(epc.Name()).Infoln(n)
+	}
+	if len(critical) > 0 {
+		for _, c := range critical {
+			khlog.ForCheck(epc.Name()).Warningln(c)
+		}
+		epc.Errors = critical
+		return nil
+	}
+
+	epc.clearErrors()
+	return nil
+}
+
+// evaluateBindings finds bindings of roles that grant exec or attach to
+// subjects that are not operators.  Cluster-wide grants are returned as
+// critical and grants limited to a namespace are returned separately.
+func evaluateBindings(clusterRoles []rbacv1.ClusterRole, roles []rbacv1.Role, clusterRoleBindings []rbacv1.ClusterRoleBinding, roleBindings []rbacv1.RoleBinding, allowedSubjects []string) ([]string, []string) {
+	var critical []string
+	var namespaced []string
+
+	allowed := make(map[string]bool)
+	for _, s := range allowedSubjects {
+		allowed[strings.TrimSpace(s)] = true
+	}
+
+	// find which roles grant exec or attach
+	clusterRoleGrants := make(map[string][]string)
+	for _, cr := range clusterRoles {
+		if grants := execGrants(cr.Rules); len(grants) > 0 {
+			clusterRoleGrants[cr.Name] = grants
+		}
+	}
+	roleGrants := make(map[string][]string)
+	for _, r := range roles {
+		if grants := execGrants(r.Rules); len(grants) > 0 {
+			roleGrants[r.Namespace+"/"+r.Name] = grants
+		}
+	}
+
+	for _, crb := range clusterRoleBindings {
+		if crb.RoleRef.Kind != "ClusterRole" {
+			continue
+		}
+		grants, ok := clusterRoleGrants[crb.RoleRef.Name]
+		if !ok {
+			continue
+		}
+		for _, s := range crb.Subjects {
+			if isOperator(s, "", allowed) {
+				continue
+			}
+			critical = append(critical, "ClusterRoleBinding "+crb.Name+" grants cluster-wide "+strings.Join(grants, ",")+" through ClusterRole "+crb.RoleRef.Name+" to "+describeSubject(s, ""))
+		}
+	}
+
+	for _, rb := range roleBindings {
+		var grants []string
+		switch rb.RoleRef.Kind {
+		case "ClusterRole":
+			grants = clusterRoleGrants[rb.RoleRef.Name]
+		case "Role":
+			grants = roleGrants[rb.Namespace+"/"+rb.RoleRef.Name]
+		}
+		if len(grants) == 0 {
+			continue
+		}
+		for _, s := range rb.Subjects {
+			if isOperator(s, rb.Namespace, allowed) {
+				continue
+			}
+			namespaced = append(namespaced, "RoleBinding "+rb.Namespace+"/"+rb.Name+" grants "+strings.Join(grants, ",")+" in namespace "+rb.Namespace+" through "+rb.RoleRef.Kind+" "+rb.RoleRef.Name+" to "+describeSubject(s, rb.Namespace))
+		}
+	}
+
+	return critical, namespaced
+}
+
+// execGrants returns the exec subresources on which the rules grant create
+func execGrants(rules []rbacv1.PolicyRule) []string {
+	var grants []string
+	for _, sub := range execSubresources {
+		for _, rule := range rules {
+			if ruleGrantsCreate(rule, sub) {
+				grants = append(grants, sub)
+				break
+			}
+		}
+	}
+	return grants
+}
+
+// ruleGrantsCreate determines if a rule grants create on a core subresource
+func ruleGrantsCreate(rule rbacv1.PolicyRule, subresource string) bool {
+	var group, resource, verb bool
+	for _, g := range rule.APIGroups {
+		if g == "" || g == rbacv1.APIGroupAll {
+			group = true
+		}
+	}
+	for _, r := range rule.Resources {
+		if r == subresource || r == rbacv1.ResourceAll || r == "pods/*" {
+			resource = true
+		}
+	}
+	for _, v := range rule.Verbs {
+		if v == "create" || v == rbacv1.VerbAll {
+			verb = true
+		}
+	}
+	return group && resource && verb
+}
+
+// isOperator determines if a subject is an operator.  The system:masters
+// group and service accounts in kube-system are always operators.
+func isOperator(s rbacv1.Subject, bindingNamespace string, allowed map[string]bool) bool {
+	switch s.Kind {
+	case rbacv1.ServiceAccountKind:
+		ns := s.Namespace
+		if len(ns) == 0 {
+			ns = bindingNamespace
+		}
+		return ns == metav1.NamespaceSystem || allowed["system:serviceaccount:"+ns+":"+s.Name]
+	case rbacv1.GroupKind:
+		return s.Name == "system:masters" || allowed[s.Name]
+	default:
+		return allowed[s.Name]
+	}
+}
+
+// describeSubject formats a subject for error messages
+func describeSubject(s rbacv1.Subject, bindingNamespace string) string {
+	if s.Kind == rbacv1.ServiceAccountKind {
+		ns := s.Namespace
+		if len(ns) == 0 {
+			ns = bindingNamespace
+		}
+		return "ServiceAccount " + ns + "/" + s.Name
+	}
+	return s.Kind + " " + s.Name
+}
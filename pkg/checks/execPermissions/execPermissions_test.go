@@ -0,0 +1,76 @@
+package execPermissions
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var execRule = rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods/exec"}, Verbs: []string{"create"}}
+var attachRule = rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods/attach"}, Verbs: []string{"*"}}
+var readRule = rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods", "pods/log"}, Verbs: []string{"get", "list"}}
+
+func TestEvaluateBindings(t *testing.T) {
+	clusterRoles := []rbacv1.ClusterRole{
+		{ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"}, Rules: []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "debugger"}, Rules: []rbacv1.PolicyRule{execRule, attachRule}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "viewer"}, Rules: []rbacv1.PolicyRule{readRule}},
+	}
+	roles := []rbacv1.Role{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "dev-exec"}, Rules: []rbacv1.PolicyRule{execRule}},
+	}
+
+	clusterRoleBindings := []rbacv1.ClusterRoleBinding{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:masters"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "ci-debugger"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "debugger"},
+			Subjects: []rbacv1.Subject{
+				{Kind: rbacv1.ServiceAccountKind, Namespace: "ci", Name: "runner"},
+				{Kind: rbacv1.GroupKind, Name: "sre"},
+				{Kind: rbacv1.ServiceAccountKind, Namespace: "kube-system", Name: "operator"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "everyone-views"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "viewer"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:authenticated"}},
+		},
+	}
+	roleBindings := []rbacv1.RoleBinding{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "developers"},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "dev-exec"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "developers"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "debug-sa"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "debugger"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "tools"}},
+		},
+	}
+
+	critical, namespaced := evaluateBindings(clusterRoles, roles, clusterRoleBindings, roleBindings, []string{"sre"})
+	t.Log(critical)
+	t.Log(namespaced)
+
+	// only the ci runner service account should be critical since sre is
+	// allowed and kube-system service accounts are operators
+	if len(critical) != 1 {
+		t.Fatal("Expected 1 critical exec grant but got", critical)
+	}
+	if len(namespaced) != 2 {
+		t.Fatal("Expected 2 namespaced exec grants but got", namespaced)
+	}
+
+	// allowing the service account by its username clears the critical grant
+	critical, _ = evaluateBindings(clusterRoles, roles, clusterRoleBindings, roleBindings, []string{"sre", "system:serviceaccount:ci:runner"})
+	if len(critical) != 0 {
+		t.Fatal("Expected no critical exec grants but got", critical)
+	}
+}
@@ -0,0 +1,291 @@
+// Package external implements a checker that runs a check shipped
+// separately from Kuberhealthy as a Kubernetes Job.  The Job reports its
+// result by POSTing it back to Kuberhealthy.  External checks are defined by
+// KuberhealthyCheck (khcheck) resources.
+package external // import "github.com/Comcast/kuberhealthy/pkg/checks/external"
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+const (
+	// ReportingURLEnv is set on every container of a check Job to the URL
+	// that its result is POSTed to
+	ReportingURLEnv = "KH_REPORTING_URL"
+	// RunUUIDEnv is set on every container of a check Job to the ID of the
+	// check run.  It must be sent in the RunUUIDHeader with the result.
+	RunUUIDEnv = "KH_RUN_UUID"
+	// RunUUIDHeader identifies the check run that a result belongs to
+	RunUUIDHeader = "kh-run-uuid"
+	// CheckNameLabel is set on check Jobs to the name of their check
+	CheckNameLabel = "kuberhealthy-check-name"
+	// DefaultDeadline is the time a check Job has to report its result when
+	// its template does not set activeDeadlineSeconds
+	DefaultDeadline = time.Minute * 5
+)
+
+// Result is the payload a check Job POSTs to report its result
+type Result struct {
+	OK     bool     `json:"ok"`
+	Errors []string `json:"errors"`
+}
+
+// Checker runs an external check Job and waits for it to report its result
+type Checker struct {
+	sync.Mutex
+	Errors       []string
+	CheckName    string
+	RunInterval  time.Duration
+	CronSchedule string // runs the check on a cron schedule instead of the interval when set
+	JobTemplate  batchv1beta1.JobTemplateSpec
+	ReportingURL string
+	runUUID      string
+	resultChan   chan Result
+	client       *kubernetes.Clientset
+	// createJob and deleteJob are replaced in tests to avoid a cluster
+	createJob func(client *kubernetes.Clientset, job *batchv1.Job) error
+	deleteJob func(client *kubernetes.Clientset, name string) error
+}
+
+// New returns a new Checker that runs a Job from jobTemplate every
+// runInterval.  The Job reports its result to reportingURL.
+func New(checkName string, runInterval time.Duration, jobTemplate batchv1beta1.JobTemplateSpec, reportingURL string) *Checker {
+	return &Checker{
+		Errors:       []string{},
+		CheckName:    checkName,
+		RunInterval:  runInterval,
+		JobTemplate:  jobTemplate,
+		ReportingURL: reportingURL,
+		createJob:    apiCreateJob,
+		deleteJob:    apiDeleteJob,
+	}
+}
+
+// Name returns the name of this checker
+func (ext *Checker) Name() string {
+	return ext.CheckName
+}
+
+// CheckNamespace returns the namespace of this checker
+func (ext *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (ext *Checker) Interval() time.Duration {
+	return ext.RunInterval
+}
+
+// SetInterval overrides the interval at which this check runs
+func (ext *Checker) SetInterval(d time.Duration) {
+	ext.RunInterval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to run its Job in the
+// Kuberhealthy namespace.  The rules the Job itself needs are up to the
+// service account in its template.
+func (ext *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		namespace: {
+			{APIGroups: []string{"batch"}, Resources: []string{"jobs"}, Verbs: []string{"create", "delete"}},
+		},
+	}
+}
+
+// Schedule returns the cron schedule this check runs on, or an empty string
+// when it runs on its interval
+func (ext *Checker) Schedule() string {
+	return ext.CronSchedule
+}
+
+// Timeout returns the maximum run time for this check before it times out.
+// The Job's deadline is extended by a minute to leave time to create and
+// delete it.
+func (ext *Checker) Timeout() time.Duration {
+	return ext.deadline() + time.Minute
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (ext *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (ext *Checker) CurrentStatus() (bool, []string) {
+	if len(ext.Errors) > 0 {
+		return false, ext.Errors
+	}
+	return true, ext.Errors
+}
+
+// clearErrors clears all errors
+func (ext *Checker) clearErrors() {
+	ext.Errors = []string{}
+}
+
+// RunUUID returns the ID of the check run waiting for a result, or an empty
+// string when no run is waiting
+func (ext *Checker) RunUUID() string {
+	ext.Lock()
+	defer ext.Unlock()
+	return ext.runUUID
+}
+
+// Report delivers the result of the check run with the given ID.  An error
+// is returned when that run is not waiting for a result.
+func (ext *Checker) Report(runUUID string, result Result) error {
+	ext.Lock()
+	defer ext.Unlock()
+	if len(runUUID) == 0 || runUUID != ext.runUUID {
+		return errors.New("Check " + ext.CheckName + " has no run waiting for a result with ID " + runUUID)
+	}
+	ext.runUUID = ""
+	ext.resultChan <- result
+	return nil
+}
+
+// Run implements the entrypoint for check execution.  A Job is created from
+// the check's template and its result is awaited until the Job's deadline.
+func (ext *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	ext.client = client
+
+	runUUID, err := newRunUUID()
+	if err != nil {
+		return errors.New("Error generating a run ID for " + ext.Name() + ": " + err.Error())
+	}
+	job := ext.buildJob(runUUID)
+
+	// the result channel is buffered so that reporting never blocks
+	ext.Lock()
+	ext.runUUID = runUUID
+	ext.resultChan = make(chan Result, 1)
+	resultChan := ext.resultChan
+	ext.Unlock()
+	defer func() {
+		ext.Lock()
+		ext.runUUID = ""
+		ext.Unlock()
+	}()
+
+	khlog.ForCheck(ext.Name()).Debugln("Creating Job", job.Name, "for external check", ext.Name())
+	err = ext.createJob(client, job)
+	if err != nil {
+		return errors.New("Error creating Job for " + ext.Name() + ": " + err.Error())
+	}
+	defer func() {
+		err := ext.deleteJob(client, job.Name)
+		if err != nil {
+			khlog.ForCheck(ext.Name()).Errorln("Error deleting Job", job.Name, "for external check", ext.Name(), err)
+		}
+	}()
+
+	select {
+	case result := <-resultChan:
+		ext.setResult(result)
+	case <-time.After(ext.deadline()):
+		ext.Errors = []string{"Check " + ext.Name() + " did not report a result within its deadline of " + ext.deadline().String()}
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + ext.Name() + ".  The check was cancelled.")
+	}
+
+	for _, e := range ext.Errors {
+		khlog.ForCheck(ext.Name()).Warningln(e)
+	}
+	return nil
+}
+
+// setResult applies a reported result as the check's status.  A failure
+// reported without errors is given one so that it is shown as a failure.
+func (ext *Checker) setResult(result Result) {
+	if result.OK {
+		ext.clearErrors()
+		return
+	}
+	if len(result.Errors) == 0 {
+		ext.Errors = []string{"Check " + ext.Name() + " reported a failure without any errors"}
+		return
+	}
+	ext.Errors = result.Errors
+}
+
+// deadline returns the time a check Job has to report its result
+func (ext *Checker) deadline() time.Duration {
+	if ext.JobTemplate.Spec.ActiveDeadlineSeconds != nil && *ext.JobTemplate.Spec.ActiveDeadlineSeconds > 0 {
+		return time.Duration(*ext.JobTemplate.Spec.ActiveDeadlineSeconds) * time.Second
+	}
+	return DefaultDeadline
+}
+
+// buildJob makes the Job for a check run from the check's template.  The
+// reporting URL and run ID are added to the environment of every container,
+// and the deadline is set on the Job so that its pods stop when it expires.
+func (ext *Checker) buildJob(runUUID string) *batchv1.Job {
+	job := &batchv1.Job{}
+	ext.JobTemplate.ObjectMeta.DeepCopyInto(&job.ObjectMeta)
+	ext.JobTemplate.Spec.DeepCopyInto(&job.Spec)
+
+	job.Name = ext.CheckName + "-" + runUUID[:8]
+	job.Namespace = namespace
+	if job.Labels == nil {
+		job.Labels = make(map[string]string)
+	}
+	job.Labels[CheckNameLabel] = ext.CheckName
+
+	deadline := int64(ext.deadline().Seconds())
+	job.Spec.ActiveDeadlineSeconds = &deadline
+	if len(job.Spec.Template.Spec.RestartPolicy) == 0 {
+		job.Spec.Template.Spec.RestartPolicy = apiv1.RestartPolicyNever
+	}
+
+	env := []apiv1.EnvVar{
+		{Name: ReportingURLEnv, Value: ext.ReportingURL},
+		{Name: RunUUIDEnv, Value: runUUID},
+	}
+	for i := range job.Spec.Template.Spec.Containers {
+		c := &job.Spec.Template.Spec.Containers[i]
+		c.Env = append(c.Env, env...)
+	}
+	return job
+}
+
+// newRunUUID returns a random ID for a check run
+func newRunUUID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// apiCreateJob creates a Job in the check namespace
+func apiCreateJob(client *kubernetes.Clientset, job *batchv1.Job) error {
+	_, err := client.BatchV1().Jobs(namespace).Create(job)
+	return err
+}
+
+// apiDeleteJob deletes a Job and its pods from the check namespace
+func apiDeleteJob(client *kubernetes.Clientset, name string) error {
+	propagation := metav1.DeletePropagationBackground
+	return client.BatchV1().Jobs(namespace).Delete(name, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+}
@@ -0,0 +1,103 @@
+package external
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// newTestChecker returns a Checker that sends the Jobs it creates down
+// jobChan instead of creating them in a cluster
+func newTestChecker(deadlineSeconds int64, jobChan chan *batchv1.Job) *Checker {
+	template := batchv1beta1.JobTemplateSpec{}
+	template.Spec.ActiveDeadlineSeconds = &deadlineSeconds
+	template.Spec.Template.Spec.Containers = []apiv1.Container{{Name: "check", Image: "check:latest"}}
+
+	ext := New("example-check", time.Minute, template, "http://10.0.0.1:8080/externalCheckStatus")
+	ext.createJob = func(client *kubernetes.Clientset, job *batchv1.Job) error {
+		jobChan <- job
+		return nil
+	}
+	ext.deleteJob = func(client *kubernetes.Clientset, name string) error {
+		return nil
+	}
+	return ext
+}
+
+func TestBuildJob(t *testing.T) {
+	ext := newTestChecker(60, nil)
+	job := ext.buildJob("0123456789abcdef")
+
+	if job.Name != "example-check-01234567" || job.Labels[CheckNameLabel] != "example-check" {
+		t.Fatal("Unexpected Job name or labels", job.Name, job.Labels)
+	}
+	if *job.Spec.ActiveDeadlineSeconds != 60 || job.Spec.Template.Spec.RestartPolicy != apiv1.RestartPolicyNever {
+		t.Fatal("Unexpected Job deadline or restart policy", *job.Spec.ActiveDeadlineSeconds, job.Spec.Template.Spec.RestartPolicy)
+	}
+	env := job.Spec.Template.Spec.Containers[0].Env
+	if len(env) != 2 || env[0].Value != ext.ReportingURL || env[1].Value != "0123456789abcdef" {
+		t.Fatal("Expected the reporting URL and run ID in the container environment but got", env)
+	}
+	if len(ext.JobTemplate.Spec.Template.Spec.Containers[0].Env) != 0 {
+		t.Fatal("Expected the Job template not to be modified")
+	}
+}
+
+func TestRun(t *testing.T) {
+	var tests = []struct {
+		description   string
+		result        *Result
+		expectedOK    bool
+		expectedError string
+	}{
+		{"check passed", &Result{OK: true}, true, ""},
+		{"check failed", &Result{OK: false, Errors: []string{"service is down"}}, false, "service is down"},
+		{"check failed without errors", &Result{OK: false}, false, "reported a failure without any errors"},
+		{"no result before deadline", nil, false, "did not report a result within its deadline of 1s"},
+	}
+
+	for _, test := range tests {
+		jobChan := make(chan *batchv1.Job, 1)
+		ext := newTestChecker(1, jobChan)
+
+		doneChan := make(chan error)
+		go func() {
+			doneChan <- ext.Run(context.Background(), nil)
+		}()
+
+		job := <-jobChan
+		runUUID := job.Spec.Template.Spec.Containers[0].Env[1].Value
+		if ext.RunUUID() != runUUID {
+			t.Fatal("Test", test.description, "expected the run to wait for", runUUID, "but got", ext.RunUUID())
+		}
+		if test.result != nil {
+			err := ext.Report("wrong-id", *test.result)
+			if err == nil {
+				t.Fatal("Test", test.description, "expected an error reporting a result for another run")
+			}
+			err = ext.Report(runUUID, *test.result)
+			if err != nil {
+				t.Fatal("Test", test.description, "error reporting result:", err)
+			}
+		}
+
+		err := <-doneChan
+		if err != nil {
+			t.Fatal("Test", test.description, "error running check:", err)
+		}
+		ok, errs := ext.CurrentStatus()
+		if ok != test.expectedOK || (len(test.expectedError) > 0 && (len(errs) != 1 || !strings.Contains(errs[0], test.expectedError))) {
+			t.Fatal("Test", test.description, "expected", test.expectedOK, test.expectedError, "but got", ok, errs)
+		}
+		if len(ext.RunUUID()) != 0 {
+			t.Fatal("Test", test.description, "expected no run to be waiting after the check completed")
+		}
+		t.Log(test.description, ok, errs)
+	}
+}
@@ -0,0 +1,239 @@
+// Package haDeployments implements a checker that finds Deployments whose
+// replicas can all be taken down by a single node or zone failure.
+// Deployments with several replicas should have pod anti-affinity rules that
+// spread their replicas across nodes, and across zones once they have
+// enough replicas to do so.
+package haDeployments // import "github.com/Comcast/kuberhealthy/pkg/checks/haDeployments"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// zoneSpreadReplicas is the number of replicas at which a Deployment is
+// expected to spread its replicas across zones
+const zoneSpreadReplicas = 3
+
+// zoneTopologyKeys are the node labels that identify the zone of a node
+var zoneTopologyKeys = []string{
+	"topology.kubernetes.io/zone",
+	"failure-domain.beta.kubernetes.io/zone",
+}
+
+// Checker validates that Deployments with several replicas spread them
+// with pod anti-affinity
+type Checker struct {
+	Errors      []string
+	Namespaces  []string
+	MinReplicas int
+	client      *kubernetes.Clientset
+	interval    time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied.  Deployments with at least minReplicas replicas
+// must have pod anti-affinity rules.
+func New(namespaces []string, minReplicas int) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		Errors:      []string{},
+		Namespaces:  namespaces,
+		MinReplicas: minReplicas,
+	}
+}
+
+// Name returns the name of this checker
+func (hdc *Checker) Name() string {
+	return "HADeploymentsChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (hdc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (hdc *Checker) Interval() time.Duration {
+	if hdc.interval > 0 {
+		return hdc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (hdc *Checker) SetInterval(d time.Duration) {
+	hdc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Deployments are listed
+// per namespace, or across the cluster when every namespace is checked.
+func (hdc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range hdc.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"list"}},
+		}
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (hdc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (hdc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (hdc *Checker) CurrentStatus() (bool, []string) {
+	if len(hdc.Errors) > 0 {
+		return false, hdc.Errors
+	}
+	return true, hdc.Errors
+}
+
+// clearErrors clears all errors
+func (hdc *Checker) clearErrors() {
+	hdc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (hdc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	hdc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := hdc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(hdc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + hdc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(hdc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + hdc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + hdc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists Deployments in each namespace and sets an error for every
+// Deployment that does not spread its replicas
+func (hdc *Checker) doChecks() error {
+
+	var deployments []appsv1.Deployment
+	for _, ns := range hdc.Namespaces {
+		list, err := hdc.client.AppsV1().Deployments(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return errors.New("Error listing deployments in namespace " + ns + ": " + err.Error())
+		}
+		deployments = append(deployments, list.Items...)
+	}
+
+	haErrors := evaluateDeployments(deployments, hdc.MinReplicas)
+
+	if len(haErrors) > 0 {
+		for _, e := range haErrors {
+			khlog.ForCheck(hdc.Name()).Warningln(e)
+		}
+		hdc.Errors = haErrors
+		return nil
+	}
+
+	hdc.clearErrors()
+	return nil
+}
+
+// evaluateDeployments returns an error for every Deployment with at least
+// minReplicas replicas and no pod anti-affinity rules, and for every
+// Deployment with zoneSpreadReplicas or more replicas and no pod
+// anti-affinity rule that spreads across zones.  Both required and
+// preferred anti-affinity rules are accepted.
+func evaluateDeployments(deployments []appsv1.Deployment, minReplicas int) []string {
+	var haErrors []string
+
+	sort.Slice(deployments, func(i, j int) bool {
+		if deployments[i].Namespace != deployments[j].Namespace {
+			return deployments[i].Namespace < deployments[j].Namespace
+		}
+		return deployments[i].Name < deployments[j].Name
+	})
+
+	for _, d := range deployments {
+		// spec.replicas defaults to 1 when unset
+		replicas := 1
+		if d.Spec.Replicas != nil {
+			replicas = int(*d.Spec.Replicas)
+		}
+		if replicas <= 1 {
+			continue
+		}
+
+		topologyKeys := antiAffinityTopologyKeys(d.Spec.Template.Spec.Affinity)
+		if len(topologyKeys) == 0 {
+			if replicas >= minReplicas {
+				haErrors = append(haErrors, "Deployment "+d.Name+" in namespace "+d.Namespace+" has "+strconv.Itoa(replicas)+" replicas but no pod anti-affinity rules to spread them across nodes")
+			}
+			continue
+		}
+
+		if replicas >= zoneSpreadReplicas && !spreadsAcrossZones(topologyKeys) {
+			haErrors = append(haErrors, "Deployment "+d.Name+" in namespace "+d.Namespace+" has "+strconv.Itoa(replicas)+" replicas but no pod anti-affinity rule to spread them across zones")
+		}
+	}
+	return haErrors
+}
+
+// antiAffinityTopologyKeys returns the topology keys of all required and
+// preferred pod anti-affinity terms
+func antiAffinityTopologyKeys(affinity *apiv1.Affinity) []string {
+	if affinity == nil || affinity.PodAntiAffinity == nil {
+		return nil
+	}
+
+	var topologyKeys []string
+	for _, term := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		topologyKeys = append(topologyKeys, term.TopologyKey)
+	}
+	for _, term := range affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+		topologyKeys = append(topologyKeys, term.PodAffinityTerm.TopologyKey)
+	}
+	return topologyKeys
+}
+
+// spreadsAcrossZones determines if any of the topology keys is a zone label
+func spreadsAcrossZones(topologyKeys []string) bool {
+	for _, key := range topologyKeys {
+		for _, zoneKey := range zoneTopologyKeys {
+			if key == zoneKey {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package haDeployments
+
+import (
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateDeployments(t *testing.T) {
+
+	makeDeployment := func(replicas *int32, affinity *apiv1.Affinity) appsv1.Deployment {
+		d := appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec:       appsv1.DeploymentSpec{Replicas: replicas},
+		}
+		d.Spec.Template.Spec.Affinity = affinity
+		return d
+	}
+	preferred := func(topologyKey string) *apiv1.Affinity {
+		return &apiv1.Affinity{PodAntiAffinity: &apiv1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []apiv1.WeightedPodAffinityTerm{
+				{Weight: 100, PodAffinityTerm: apiv1.PodAffinityTerm{TopologyKey: topologyKey}},
+			},
+		}}
+	}
+	required := func(topologyKey string) *apiv1.Affinity {
+		return &apiv1.Affinity{PodAntiAffinity: &apiv1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []apiv1.PodAffinityTerm{
+				{TopologyKey: topologyKey},
+			},
+		}}
+	}
+	one := int32(1)
+	two := int32(2)
+	three := int32(3)
+
+	var tests = []struct {
+		description   string
+		deployment    appsv1.Deployment
+		minReplicas   int
+		expectedError string
+	}{
+		{"replicas not set", makeDeployment(nil, nil), 2, ""},
+		{"single replica", makeDeployment(&one, nil), 2, ""},
+		{"no anti-affinity", makeDeployment(&two, nil), 2, "Deployment web in namespace default has 2 replicas but no pod anti-affinity rules to spread them across nodes"},
+		{"no anti-affinity below minimum replicas", makeDeployment(&two, nil), 3, ""},
+		{"only node affinity", makeDeployment(&two, &apiv1.Affinity{NodeAffinity: &apiv1.NodeAffinity{}}), 2, "no pod anti-affinity rules"},
+		{"preferred host anti-affinity", makeDeployment(&two, preferred("kubernetes.io/hostname")), 2, ""},
+		{"required host anti-affinity", makeDeployment(&two, required("kubernetes.io/hostname")), 2, ""},
+		{"three replicas spread across nodes only", makeDeployment(&three, preferred("kubernetes.io/hostname")), 2, "Deployment web in namespace default has 3 replicas but no pod anti-affinity rule to spread them across zones"},
+		{"three replicas spread across zones", makeDeployment(&three, preferred("topology.kubernetes.io/zone")), 2, ""},
+		{"three replicas spread across deprecated zone label", makeDeployment(&three, required("failure-domain.beta.kubernetes.io/zone")), 2, ""},
+		{"three replicas without anti-affinity", makeDeployment(&three, nil), 2, "no pod anti-affinity rules to spread them across nodes"},
+	}
+
+	for _, test := range tests {
+		haErrors := evaluateDeployments([]appsv1.Deployment{test.deployment}, test.minReplicas)
+		if len(test.expectedError) == 0 {
+			if len(haErrors) != 0 {
+				t.Fatal("Test", test.description, "expected no errors but got", haErrors)
+			}
+			continue
+		}
+		if len(haErrors) != 1 || !strings.Contains(haErrors[0], test.expectedError) {
+			t.Fatal("Test", test.description, "expected error", test.expectedError, "but got", haErrors)
+		}
+	}
+}
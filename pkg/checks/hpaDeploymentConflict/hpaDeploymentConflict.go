@@ -0,0 +1,196 @@
+// Package hpaDeploymentConflict implements a checker that finds Deployments
+// whose replica count conflicts with the HorizontalPodAutoscaler that scales
+// them.  When the replicas of an autoscaled Deployment are changed by hand,
+// for example by applying a manifest that sets spec.replicas, the
+// HorizontalPodAutoscaler immediately overrides the change.
+package hpaDeploymentConflict // import "github.com/Comcast/kuberhealthy/pkg/checks/hpaDeploymentConflict"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Checker validates that autoscaled Deployments agree with their
+// HorizontalPodAutoscalers
+type Checker struct {
+	Errors    []string
+	Tolerance int
+	client    *kubernetes.Clientset
+	interval  time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that allows Deployment replicas to differ from
+// the replicas desired by their HorizontalPodAutoscaler by the tolerance
+func New(tolerance int) *Checker {
+	return &Checker{
+		Errors:    []string{},
+		Tolerance: tolerance,
+	}
+}
+
+// Name returns the name of this checker
+func (hdc *Checker) Name() string {
+	return "HPADeploymentConflictChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (hdc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (hdc *Checker) Interval() time.Duration {
+	if hdc.interval > 0 {
+		return hdc.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (hdc *Checker) SetInterval(d time.Duration) {
+	hdc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  HorizontalPodAutoscalers
+// and deployments are listed in every namespace.
+func (hdc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{"autoscaling"}, Resources: []string{"horizontalpodautoscalers"}, Verbs: []string{"list"}},
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (hdc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (hdc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (hdc *Checker) CurrentStatus() (bool, []string) {
+	if len(hdc.Errors) > 0 {
+		return false, hdc.Errors
+	}
+	return true, hdc.Errors
+}
+
+// clearErrors clears all errors
+func (hdc *Checker) clearErrors() {
+	hdc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (hdc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	hdc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := hdc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(hdc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + hdc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(hdc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + hdc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + hdc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists HorizontalPodAutoscalers and Deployments and sets an error
+// for every Deployment whose replicas conflict with its autoscaler
+func (hdc *Checker) doChecks() error {
+
+	hpas, err := hdc.client.AutoscalingV1().HorizontalPodAutoscalers(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing HorizontalPodAutoscalers: " + err.Error())
+	}
+	deployments, err := hdc.client.AppsV1().Deployments(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing deployments: " + err.Error())
+	}
+
+	conflictErrors := evaluateDeployments(hpas.Items, deployments.Items, hdc.Tolerance)
+
+	if len(conflictErrors) > 0 {
+		for _, e := range conflictErrors {
+			khlog.ForCheck(hdc.Name()).Warningln(e)
+		}
+		hdc.Errors = conflictErrors
+		return nil
+	}
+
+	hdc.clearErrors()
+	return nil
+}
+
+// evaluateDeployments returns an error for every Deployment whose
+// spec.replicas differs from the desired replicas of the
+// HorizontalPodAutoscaler targeting it by more than the tolerance.
+// Autoscalers that have not calculated their desired replicas yet, or whose
+// target does not exist, are skipped.
+func evaluateDeployments(hpas []autoscalingv1.HorizontalPodAutoscaler, deployments []appsv1.Deployment, tolerance int) []string {
+	var conflictErrors []string
+
+	byName := make(map[string]appsv1.Deployment)
+	for _, d := range deployments {
+		byName[d.Namespace+"/"+d.Name] = d
+	}
+
+	sort.Slice(hpas, func(i, j int) bool {
+		return hpas[i].Namespace+"/"+hpas[i].Name < hpas[j].Namespace+"/"+hpas[j].Name
+	})
+	for _, hpa := range hpas {
+		if hpa.Spec.ScaleTargetRef.Kind != "Deployment" || hpa.Status.DesiredReplicas == 0 {
+			continue
+		}
+		d, ok := byName[hpa.Namespace+"/"+hpa.Spec.ScaleTargetRef.Name]
+		if !ok {
+			continue
+		}
+
+		// spec.replicas defaults to 1 when unset
+		replicas := int32(1)
+		if d.Spec.Replicas != nil {
+			replicas = *d.Spec.Replicas
+		}
+		difference := int(replicas - hpa.Status.DesiredReplicas)
+		if difference < 0 {
+			difference = -difference
+		}
+		if difference <= tolerance {
+			continue
+		}
+
+		conflictErrors = append(conflictErrors, "Deployment "+d.Namespace+"/"+d.Name+" has "+strconv.Itoa(int(replicas))+" replicas but HorizontalPodAutoscaler "+hpa.Name+" desires "+strconv.Itoa(int(hpa.Status.DesiredReplicas))+" and will override manual replica changes")
+	}
+	return conflictErrors
+}
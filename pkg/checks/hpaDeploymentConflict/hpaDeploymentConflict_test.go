@@ -0,0 +1,59 @@
+package hpaDeploymentConflict
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makeHPA(kind string, target string, desired int32) autoscalingv1.HorizontalPodAutoscaler {
+	return autoscalingv1.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: target + "-hpa"},
+		Spec: autoscalingv1.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv1.CrossVersionObjectReference{Kind: kind, Name: target, APIVersion: "apps/v1"},
+			MaxReplicas:    10,
+		},
+		Status: autoscalingv1.HorizontalPodAutoscalerStatus{DesiredReplicas: desired},
+	}
+}
+
+func makeDeployment(name string, replicas *int32) appsv1.Deployment {
+	return appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       appsv1.DeploymentSpec{Replicas: replicas},
+	}
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func TestEvaluateDeployments(t *testing.T) {
+	var tests = []struct {
+		description string
+		hpa         autoscalingv1.HorizontalPodAutoscaler
+		deployment  appsv1.Deployment
+		tolerance   int
+		expected    int
+	}{
+		{"matching replicas", makeHPA("Deployment", "web", 4), makeDeployment("web", int32Ptr(4)), 0, 0},
+		{"manual scale up", makeHPA("Deployment", "web", 4), makeDeployment("web", int32Ptr(8)), 0, 1},
+		{"manual scale down", makeHPA("Deployment", "web", 4), makeDeployment("web", int32Ptr(2)), 0, 1},
+		{"within tolerance", makeHPA("Deployment", "web", 4), makeDeployment("web", int32Ptr(5)), 1, 0},
+		{"outside tolerance", makeHPA("Deployment", "web", 4), makeDeployment("web", int32Ptr(6)), 1, 1},
+		{"unset replicas", makeHPA("Deployment", "web", 3), makeDeployment("web", nil), 0, 1},
+		{"not yet calculated", makeHPA("Deployment", "web", 0), makeDeployment("web", int32Ptr(2)), 0, 0},
+		{"different target", makeHPA("Deployment", "api", 4), makeDeployment("web", int32Ptr(2)), 0, 0},
+		{"statefulset target", makeHPA("StatefulSet", "web", 4), makeDeployment("web", int32Ptr(2)), 0, 0},
+	}
+
+	for _, test := range tests {
+		conflictErrors := evaluateDeployments([]autoscalingv1.HorizontalPodAutoscaler{test.hpa}, []appsv1.Deployment{test.deployment}, test.tolerance)
+		if len(conflictErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", conflictErrors)
+		}
+		t.Log(test.description, conflictErrors)
+	}
+}
@@ -0,0 +1,222 @@
+// Package hpaStatus implements a checker that finds HorizontalPodAutoscalers
+// that can not scale.  An HPA whose metrics are unavailable or whose target
+// does not exist fails silently and leaves its workload at a fixed size.
+package hpaStatus // import "github.com/Comcast/kuberhealthy/pkg/checks/hpaStatus"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Checker validates that HorizontalPodAutoscalers are able to scale their
+// targets
+type Checker struct {
+	Errors     []string
+	Namespaces []string
+	client     *kubernetes.Clientset
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied
+func New(namespaces []string) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		Errors:     []string{},
+		Namespaces: namespaces,
+	}
+}
+
+// Name returns the name of this checker
+func (hsc *Checker) Name() string {
+	return "HPAStatusChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (hsc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (hsc *Checker) Interval() time.Duration {
+	if hsc.interval > 0 {
+		return hsc.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (hsc *Checker) SetInterval(d time.Duration) {
+	hsc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to read autoscalers and
+// their target deployments in the checked namespaces
+func (hsc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range hsc.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{"autoscaling"}, Resources: []string{"horizontalpodautoscalers"}, Verbs: []string{"list"}},
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"list"}},
+		}
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (hsc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (hsc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (hsc *Checker) CurrentStatus() (bool, []string) {
+	if len(hsc.Errors) > 0 {
+		return false, hsc.Errors
+	}
+	return true, hsc.Errors
+}
+
+// clearErrors clears all errors
+func (hsc *Checker) clearErrors() {
+	hsc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (hsc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	hsc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := hsc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(hsc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + hsc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(hsc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + hsc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + hsc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists HPAs and Deployments in each namespace and sets an error
+// for every HPA that can not scale its target
+func (hsc *Checker) doChecks() error {
+
+	var hpas []autoscalingv2beta1.HorizontalPodAutoscaler
+	var deployments []appsv1.Deployment
+	for _, ns := range hsc.Namespaces {
+		hpaList, err := hsc.client.AutoscalingV2beta1().HorizontalPodAutoscalers(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return errors.New("Error listing HorizontalPodAutoscalers in namespace " + ns + ": " + err.Error())
+		}
+		hpas = append(hpas, hpaList.Items...)
+
+		deploymentList, err := hsc.client.AppsV1().Deployments(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return errors.New("Error listing Deployments in namespace " + ns + ": " + err.Error())
+		}
+		deployments = append(deployments, deploymentList.Items...)
+	}
+
+	statusErrors := evaluateHPAs(hpas, deployments)
+
+	if len(statusErrors) > 0 {
+		for _, e := range statusErrors {
+			khlog.ForCheck(hsc.Name()).Warningln(e)
+		}
+		hsc.Errors = statusErrors
+		return nil
+	}
+
+	hsc.clearErrors()
+	return nil
+}
+
+// evaluateHPAs returns an error for every HPA that has no current replicas
+// while it desires some, that is not able to scale, that can not compute a
+// scale from its metrics, that is limited from scaling, or that targets a
+// Deployment that does not exist
+func evaluateHPAs(hpas []autoscalingv2beta1.HorizontalPodAutoscaler, deployments []appsv1.Deployment) []string {
+	var statusErrors []string
+
+	sort.Slice(hpas, func(i, j int) bool {
+		if hpas[i].Namespace != hpas[j].Namespace {
+			return hpas[i].Namespace < hpas[j].Namespace
+		}
+		return hpas[i].Name < hpas[j].Name
+	})
+
+	existing := make(map[string]bool)
+	for _, d := range deployments {
+		existing[d.Namespace+"/"+d.Name] = true
+	}
+
+	for _, hpa := range hpas {
+		prefix := "HPA " + hpa.Name + " in namespace " + hpa.Namespace
+
+		target := hpa.Spec.ScaleTargetRef
+		if target.Kind == "Deployment" && !existing[hpa.Namespace+"/"+target.Name] {
+			statusErrors = append(statusErrors, prefix+" targets Deployment "+target.Name+" which does not exist.")
+		}
+
+		if hpa.Status.CurrentReplicas == 0 && hpa.Status.DesiredReplicas > 0 {
+			statusErrors = append(statusErrors, prefix+" has 0 current replicas but desires "+strconv.Itoa(int(hpa.Status.DesiredReplicas))+".")
+		}
+
+		for _, c := range hpa.Status.Conditions {
+			switch {
+			case c.Type == autoscalingv2beta1.AbleToScale && c.Status == apiv1.ConditionFalse:
+				statusErrors = append(statusErrors, prefix+" has condition AbleToScale=False"+describeCondition(c))
+			case c.Type == autoscalingv2beta1.ScalingActive && c.Status == apiv1.ConditionFalse:
+				statusErrors = append(statusErrors, prefix+" has condition ScalingActive=False"+describeCondition(c))
+			case c.Type == autoscalingv2beta1.ScalingLimited && c.Status == apiv1.ConditionTrue:
+				statusErrors = append(statusErrors, prefix+" has condition ScalingLimited=True"+describeCondition(c))
+			}
+		}
+	}
+	return statusErrors
+}
+
+// describeCondition returns the reason and message of an HPA condition to
+// end an error with
+func describeCondition(c autoscalingv2beta1.HorizontalPodAutoscalerCondition) string {
+	var s string
+	if len(c.Reason) > 0 {
+		s += " (" + c.Reason + ")"
+	}
+	if len(c.Message) > 0 {
+		s += ": " + c.Message
+	}
+	return s + "."
+}
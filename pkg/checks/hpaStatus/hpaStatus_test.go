@@ -0,0 +1,88 @@
+package hpaStatus
+
+import (
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateHPAs(t *testing.T) {
+	makeHPA := func(targetKind string, targetName string, current int32, desired int32, conditions ...autoscalingv2beta1.HorizontalPodAutoscalerCondition) autoscalingv2beta1.HorizontalPodAutoscaler {
+		return autoscalingv2beta1.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: autoscalingv2beta1.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv2beta1.CrossVersionObjectReference{Kind: targetKind, Name: targetName},
+			},
+			Status: autoscalingv2beta1.HorizontalPodAutoscalerStatus{
+				CurrentReplicas: current,
+				DesiredReplicas: desired,
+				Conditions:      conditions,
+			},
+		}
+	}
+	condition := func(conditionType autoscalingv2beta1.HorizontalPodAutoscalerConditionType, status apiv1.ConditionStatus, reason string, message string) autoscalingv2beta1.HorizontalPodAutoscalerCondition {
+		return autoscalingv2beta1.HorizontalPodAutoscalerCondition{Type: conditionType, Status: status, Reason: reason, Message: message}
+	}
+	deployments := []appsv1.Deployment{
+		{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+	}
+
+	var tests = []struct {
+		description   string
+		hpa           autoscalingv2beta1.HorizontalPodAutoscaler
+		expectedError string
+	}{
+		{"healthy", makeHPA("Deployment", "web", 2, 2,
+			condition(autoscalingv2beta1.AbleToScale, apiv1.ConditionTrue, "ReadyForNewScale", ""),
+			condition(autoscalingv2beta1.ScalingActive, apiv1.ConditionTrue, "ValidMetricFound", ""),
+			condition(autoscalingv2beta1.ScalingLimited, apiv1.ConditionFalse, "DesiredWithinRange", "")), ""},
+		{"missing Deployment", makeHPA("Deployment", "api", 2, 2), "HPA web in namespace default targets Deployment api which does not exist."},
+		{"StatefulSet target is not looked up", makeHPA("StatefulSet", "database", 2, 2), ""},
+		{"no current replicas", makeHPA("Deployment", "web", 0, 3), "HPA web in namespace default has 0 current replicas but desires 3."},
+		{"scaled to zero on purpose", makeHPA("Deployment", "web", 0, 0), ""},
+		{"not able to scale", makeHPA("Deployment", "web", 2, 2,
+			condition(autoscalingv2beta1.AbleToScale, apiv1.ConditionFalse, "FailedGetScale", "the HPA controller was unable to get the target's current scale")),
+			"HPA web in namespace default has condition AbleToScale=False (FailedGetScale): the HPA controller was unable to get the target's current scale."},
+		{"metrics unavailable", makeHPA("Deployment", "web", 2, 2,
+			condition(autoscalingv2beta1.ScalingActive, apiv1.ConditionFalse, "FailedGetResourceMetric", "unable to get metrics for resource cpu")),
+			"has condition ScalingActive=False (FailedGetResourceMetric): unable to get metrics for resource cpu."},
+		{"scaling limited", makeHPA("Deployment", "web", 10, 10,
+			condition(autoscalingv2beta1.ScalingLimited, apiv1.ConditionTrue, "TooManyReplicas", "")),
+			"has condition ScalingLimited=True (TooManyReplicas)."},
+	}
+
+	for _, test := range tests {
+		statusErrors := evaluateHPAs([]autoscalingv2beta1.HorizontalPodAutoscaler{test.hpa}, deployments)
+		if len(test.expectedError) == 0 {
+			if len(statusErrors) != 0 {
+				t.Fatal("Test", test.description, "expected no errors but got", statusErrors)
+			}
+			continue
+		}
+		if len(statusErrors) != 1 || !strings.Contains(statusErrors[0], test.expectedError) {
+			t.Fatal("Test", test.description, "expected an error containing", test.expectedError, "but got", statusErrors)
+		}
+		t.Log(test.description, statusErrors)
+	}
+}
+
+func TestEvaluateHPAsOrder(t *testing.T) {
+	hpas := []autoscalingv2beta1.HorizontalPodAutoscaler{
+		{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "production"}, Status: autoscalingv2beta1.HorizontalPodAutoscalerStatus{DesiredReplicas: 1}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default"}, Status: autoscalingv2beta1.HorizontalPodAutoscalerStatus{DesiredReplicas: 1}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}, Status: autoscalingv2beta1.HorizontalPodAutoscalerStatus{DesiredReplicas: 1}},
+	}
+	statusErrors := evaluateHPAs(hpas, nil)
+	if len(statusErrors) != 3 {
+		t.Fatal("Expected 3 errors but got", statusErrors)
+	}
+	for i, prefix := range []string{"HPA api in namespace default", "HPA worker in namespace default", "HPA web in namespace production"} {
+		if !strings.HasPrefix(statusErrors[i], prefix) {
+			t.Fatal("Expected error", i, "to start with", prefix, "but got", statusErrors[i])
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Package hugepages implements a checker that ensures nodes have the
+// hugepages allocated that the pods scheduled to them request.  Hugepages
+// are preallocated by the kernel at boot, so a node that was rebuilt without
+// its hugepages configuration silently stops being able to run the workloads
+// that depend on them.
+package hugepages // import "github.com/Comcast/kuberhealthy/pkg/checks/hugepages"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Checker validates the hugepages of nodes running pods that request them
+type Checker struct {
+	Errors   []string
+	client   *kubernetes.Clientset
+	interval time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker
+func New() *Checker {
+	return &Checker{
+		Errors: []string{},
+	}
+}
+
+// Name returns the name of this checker
+func (hc *Checker) Name() string {
+	return "HugepagesChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (hc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (hc *Checker) Interval() time.Duration {
+	if hc.interval > 0 {
+		return hc.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (hc *Checker) SetInterval(d time.Duration) {
+	hc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to list nodes and the
+// pods of every namespace
+func (hc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes", "pods"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (hc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (hc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (hc *Checker) CurrentStatus() (bool, []string) {
+	if len(hc.Errors) > 0 {
+		return false, hc.Errors
+	}
+	return true, hc.Errors
+}
+
+// clearErrors clears all errors
+func (hc *Checker) clearErrors() {
+	hc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (hc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	hc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := hc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(hc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + hc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(hc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + hc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + hc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists nodes and pods and sets an error for every node that does
+// not have the hugepages its pods request
+func (hc *Checker) doChecks() error {
+
+	nodes, err := hc.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing nodes: " + err.Error())
+	}
+	pods, err := hc.client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing pods: " + err.Error())
+	}
+
+	hugepagesErrors := evaluateNodes(nodes.Items, pods.Items)
+
+	if len(hugepagesErrors) > 0 {
+		for _, e := range hugepagesErrors {
+			khlog.ForCheck(hc.Name()).Warningln(e)
+		}
+		hc.Errors = hugepagesErrors
+		return nil
+	}
+
+	hc.clearErrors()
+	return nil
+}
+
+// evaluateNodes returns an error for every node and hugepage size where
+// pods on the node request hugepages the node has no capacity or
+// allocatable for, or where the pods together request more than is
+// allocatable.  Completed and unscheduled pods are skipped.
+func evaluateNodes(nodes []apiv1.Node, pods []apiv1.Pod) []string {
+	var hugepagesErrors []string
+
+	// total the hugepages requested on each node by size
+	requested := make(map[string]apiv1.ResourceList)
+	for _, p := range pods {
+		if len(p.Spec.NodeName) == 0 {
+			continue
+		}
+		if p.Status.Phase == apiv1.PodSucceeded || p.Status.Phase == apiv1.PodFailed {
+			continue
+		}
+		for size, quantity := range podHugepages(p) {
+			if _, ok := requested[p.Spec.NodeName]; !ok {
+				requested[p.Spec.NodeName] = apiv1.ResourceList{}
+			}
+			total := requested[p.Spec.NodeName][size]
+			total.Add(quantity)
+			requested[p.Spec.NodeName][size] = total
+		}
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	for _, n := range nodes {
+		nodeRequested, ok := requested[n.Name]
+		if !ok {
+			continue
+		}
+
+		var sizes []string
+		for size := range nodeRequested {
+			sizes = append(sizes, string(size))
+		}
+		sort.Strings(sizes)
+
+		for _, s := range sizes {
+			size := apiv1.ResourceName(s)
+			total := nodeRequested[size]
+			capacity, hasCapacity := n.Status.Capacity[size]
+			allocatable, hasAllocatable := n.Status.Allocatable[size]
+
+			switch {
+			case !hasCapacity || capacity.IsZero():
+				hugepagesErrors = append(hugepagesErrors, "Node "+n.Name+" runs pods requesting "+total.String()+" of "+s+" but has no "+s+" capacity")
+			case !hasAllocatable || allocatable.IsZero():
+				hugepagesErrors = append(hugepagesErrors, "Node "+n.Name+" runs pods requesting "+total.String()+" of "+s+" but has no "+s+" allocatable")
+			case total.Cmp(allocatable) > 0:
+				hugepagesErrors = append(hugepagesErrors, "Node "+n.Name+" runs pods requesting "+total.String()+" of "+s+" but only "+allocatable.String()+" is allocatable")
+			}
+		}
+	}
+	return hugepagesErrors
+}
+
+// podHugepages returns the hugepages a pod requests by size.  The request
+// of each container is used, falling back to its limit, as hugepages can not
+// be overcommitted and the two must be equal.  Init containers run one at a
+// time, so the pod requests the larger of the sum of its containers and its
+// largest init container.
+func podHugepages(p apiv1.Pod) apiv1.ResourceList {
+	hugepages := apiv1.ResourceList{}
+
+	for _, c := range p.Spec.Containers {
+		for size, quantity := range containerHugepages(c) {
+			total := hugepages[size]
+			total.Add(quantity)
+			hugepages[size] = total
+		}
+	}
+	for _, c := range p.Spec.InitContainers {
+		for size, quantity := range containerHugepages(c) {
+			total, ok := hugepages[size]
+			if !ok || quantity.Cmp(total) > 0 {
+				hugepages[size] = quantity
+			}
+		}
+	}
+	return hugepages
+}
+
+// containerHugepages returns the hugepages a container requests by size
+func containerHugepages(c apiv1.Container) apiv1.ResourceList {
+	hugepages := apiv1.ResourceList{}
+	for _, resources := range []apiv1.ResourceList{c.Resources.Limits, c.Resources.Requests} {
+		for name, quantity := range resources {
+			if !strings.HasPrefix(string(name), apiv1.ResourceHugePagesPrefix) || quantity.IsZero() {
+				continue
+			}
+			hugepages[name] = quantity.DeepCopy()
+		}
+	}
+	return hugepages
+}
@@ -0,0 +1,97 @@
+package hugepages
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	hugepages2Mi = apiv1.ResourceName("hugepages-2Mi")
+	hugepages1Gi = apiv1.ResourceName("hugepages-1Gi")
+)
+
+func makeNode(name string, capacity apiv1.ResourceList, allocatable apiv1.ResourceList) apiv1.Node {
+	return apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     apiv1.NodeStatus{Capacity: capacity, Allocatable: allocatable},
+	}
+}
+
+func makePod(name string, nodeName string, requests ...apiv1.ResourceList) apiv1.Pod {
+	pod := apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       apiv1.PodSpec{NodeName: nodeName},
+		Status:     apiv1.PodStatus{Phase: apiv1.PodRunning},
+	}
+	for _, r := range requests {
+		pod.Spec.Containers = append(pod.Spec.Containers, apiv1.Container{
+			Resources: apiv1.ResourceRequirements{Requests: r, Limits: r},
+		})
+	}
+	return pod
+}
+
+func hugepages(size apiv1.ResourceName, quantity string) apiv1.ResourceList {
+	return apiv1.ResourceList{
+		apiv1.ResourceCPU: resource.MustParse("100m"),
+		size:              resource.MustParse(quantity),
+	}
+}
+
+func TestEvaluateNodes(t *testing.T) {
+	configured := makeNode("node-a", hugepages(hugepages2Mi, "1Gi"), hugepages(hugepages2Mi, "1Gi"))
+	unconfigured := makeNode("node-a", apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("4")}, apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("4")})
+	unallocatable := makeNode("node-a", hugepages(hugepages2Mi, "1Gi"), hugepages(hugepages2Mi, "0"))
+
+	completed := makePod("job", "node-a", hugepages(hugepages2Mi, "2Gi"))
+	completed.Status.Phase = apiv1.PodSucceeded
+
+	var tests = []struct {
+		description string
+		node        apiv1.Node
+		pods        []apiv1.Pod
+		expected    int
+	}{
+		{"within allocatable", configured, []apiv1.Pod{makePod("db", "node-a", hugepages(hugepages2Mi, "512Mi"))}, 0},
+		{"exactly allocatable", configured, []apiv1.Pod{makePod("db", "node-a", hugepages(hugepages2Mi, "512Mi")), makePod("cache", "node-a", hugepages(hugepages2Mi, "512Mi"))}, 0},
+		{"exceeds allocatable", configured, []apiv1.Pod{makePod("db", "node-a", hugepages(hugepages2Mi, "768Mi")), makePod("cache", "node-a", hugepages(hugepages2Mi, "512Mi"))}, 1},
+		{"containers exceed allocatable", configured, []apiv1.Pod{makePod("db", "node-a", hugepages(hugepages2Mi, "768Mi"), hugepages(hugepages2Mi, "512Mi"))}, 1},
+		{"no capacity", unconfigured, []apiv1.Pod{makePod("db", "node-a", hugepages(hugepages2Mi, "512Mi"))}, 1},
+		{"no allocatable", unallocatable, []apiv1.Pod{makePod("db", "node-a", hugepages(hugepages2Mi, "512Mi"))}, 1},
+		{"other size", configured, []apiv1.Pod{makePod("db", "node-a", hugepages(hugepages1Gi, "2Gi"))}, 1},
+		{"no hugepages requested", unconfigured, []apiv1.Pod{makePod("web", "node-a", apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("1")})}, 0},
+		{"other node", unconfigured, []apiv1.Pod{makePod("db", "node-b", hugepages(hugepages2Mi, "512Mi"))}, 0},
+		{"unscheduled", unconfigured, []apiv1.Pod{makePod("db", "", hugepages(hugepages2Mi, "512Mi"))}, 0},
+		{"completed", configured, []apiv1.Pod{completed}, 0},
+	}
+
+	for _, test := range tests {
+		hugepagesErrors := evaluateNodes([]apiv1.Node{test.node}, test.pods)
+		if len(hugepagesErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", hugepagesErrors)
+		}
+		t.Log(test.description, hugepagesErrors)
+	}
+}
+
+func TestPodHugepages(t *testing.T) {
+	pod := makePod("db", "node-a", hugepages(hugepages2Mi, "256Mi"), hugepages(hugepages2Mi, "256Mi"))
+	pod.Spec.InitContainers = []apiv1.Container{
+		{Resources: apiv1.ResourceRequirements{Limits: hugepages(hugepages2Mi, "768Mi")}},
+		{Resources: apiv1.ResourceRequirements{Limits: hugepages(hugepages1Gi, "1Gi")}},
+	}
+
+	requested := podHugepages(pod)
+	if q := requested[hugepages2Mi]; q.Cmp(resource.MustParse("768Mi")) != 0 {
+		t.Fatal("Expected the largest init container request of 768Mi but got", q.String())
+	}
+	if q := requested[hugepages1Gi]; q.Cmp(resource.MustParse("1Gi")) != 0 {
+		t.Fatal("Expected the init container request of 1Gi but got", q.String())
+	}
+	if _, ok := requested[apiv1.ResourceCPU]; ok {
+		t.Fatal("Expected only hugepages to be returned but got", requested)
+	}
+}
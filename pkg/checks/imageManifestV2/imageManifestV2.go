@@ -0,0 +1,258 @@
+// Package imageManifestV2 implements a checker that queries container
+// registries for the manifests of images running in the cluster and reports
+// any images that are only available with the deprecated Docker V1 manifest
+// format.  Images with V1 manifests may not be pullable by newer container
+// runtimes.
+package imageManifestV2 // import "github.com/Comcast/kuberhealthy/pkg/checks/imageManifestV2"
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/registry"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// Checker validates that images from the configured registries are served
+// with V2 or OCI manifests
+type Checker struct {
+	Errors            []string
+	Namespaces        []string
+	Registries        []string
+	CredentialsSecret string
+	client            *kubernetes.Clientset
+	httpClient        *http.Client
+	interval          time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that checks images from the supplied registries
+// in the supplied namespaces, or all namespaces when none are supplied.
+// credentialsSecret optionally names a kubernetes.io/dockerconfigjson secret
+// as either "name" in the kuberhealthy namespace or "namespace/name".
+func New(namespaces []string, registries []string, credentialsSecret string) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		Errors:            []string{},
+		Namespaces:        namespaces,
+		Registries:        registries,
+		CredentialsSecret: credentialsSecret,
+		httpClient:        &http.Client{Timeout: time.Second * 30},
+	}
+}
+
+// Name returns the name of this checker
+func (imc *Checker) Name() string {
+	return "ImageManifestV2Checker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (imc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (imc *Checker) Interval() time.Duration {
+	if imc.interval > 0 {
+		return imc.interval
+	}
+	return time.Minute * 30
+}
+
+// SetInterval overrides the interval at which this check runs
+func (imc *Checker) SetInterval(d time.Duration) {
+	imc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to list pods in the
+// checked namespaces, and to read the registry credentials secret in its own
+// namespace when one is set
+func (imc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range imc.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		}
+	}
+	if len(imc.CredentialsSecret) > 0 {
+		secretNamespace, secretName := imc.credentialsSecretName()
+		rules[secretNamespace] = append(rules[secretNamespace],
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{secretName}, Verbs: []string{"get"}},
+		)
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (imc *Checker) Timeout() time.Duration {
+	return time.Minute * 10
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (imc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (imc *Checker) CurrentStatus() (bool, []string) {
+	if len(imc.Errors) > 0 {
+		return false, imc.Errors
+	}
+	return true, imc.Errors
+}
+
+// clearErrors clears all errors
+func (imc *Checker) clearErrors() {
+	imc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (imc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	imc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := imc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(imc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + imc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(imc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + imc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + imc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks finds the images from the configured registries that are running
+// in the cluster and sets an error for each image served with a V1 manifest
+func (imc *Checker) doChecks() error {
+
+	credentials, err := imc.loadCredentials()
+	if err != nil {
+		return err
+	}
+
+	images := make(map[string]imageRef)
+	for _, ns := range imc.Namespaces {
+		pods, err := imc.client.CoreV1().Pods(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for _, image := range podImages(pods.Items) {
+			ref := parseImage(image)
+			if !imc.registryEnabled(ref.Registry) {
+				continue
+			}
+			images[image] = ref
+		}
+	}
+
+	var names []string
+	for name := range images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var manifestErrors []string
+	for _, name := range names {
+		mediaType, err := fetchManifestType(imc.httpClient, images[name], credentials)
+		if err != nil {
+			khlog.ForCheck(imc.Name()).Warningln("Error fetching manifest for image", name+":", err)
+			manifestErrors = append(manifestErrors, "Error fetching manifest for image "+name+": "+err.Error())
+			continue
+		}
+		khlog.ForCheck(imc.Name()).Debugln("Image", name, "is served with manifest type", mediaType)
+		if isV1Manifest(mediaType) {
+			manifestErrors = append(manifestErrors, "Image "+name+" is only available with a deprecated V1 manifest ("+mediaType+")")
+		}
+	}
+
+	if len(manifestErrors) > 0 {
+		imc.Errors = manifestErrors
+		return nil
+	}
+
+	imc.clearErrors()
+	return nil
+}
+
+// registryEnabled determines if images from the registry should be checked
+func (imc *Checker) registryEnabled(registry string) bool {
+	for _, r := range imc.Registries {
+		if strings.TrimSpace(r) == registry {
+			return true
+		}
+	}
+	return false
+}
+
+// credentialsSecretName returns the namespace and name of the registry
+// credentials secret, which is in the Kuberhealthy namespace unless it is
+// given as namespace/name
+func (imc *Checker) credentialsSecretName() (string, string) {
+	if strings.Contains(imc.CredentialsSecret, "/") {
+		parts := strings.SplitN(imc.CredentialsSecret, "/", 2)
+		return parts[0], parts[1]
+	}
+	return namespace, imc.CredentialsSecret
+}
+
+// loadCredentials reads registry credentials from the configured
+// dockerconfigjson secret, if any
+func (imc *Checker) loadCredentials() (map[string]registry.Auth, error) {
+	credentials := make(map[string]registry.Auth)
+	if len(imc.CredentialsSecret) == 0 {
+		return credentials, nil
+	}
+
+	secretNamespace, secretName := imc.credentialsSecretName()
+	secret, err := imc.client.CoreV1().Secrets(secretNamespace).Get(secretName, metav1.GetOptions{})
+	if err != nil {
+		return credentials, errors.New("Error getting registry credentials secret " + secretNamespace + "/" + secretName + ": " + err.Error())
+	}
+
+	data, ok := secret.Data[apiv1.DockerConfigJsonKey]
+	if !ok {
+		return credentials, errors.New("Registry credentials secret " + secretNamespace + "/" + secretName + " has no " + apiv1.DockerConfigJsonKey + " key")
+	}
+	return parseDockerConfig(data)
+}
+
+// podImages returns the images of all containers and init containers in the pods
+func podImages(pods []apiv1.Pod) []string {
+	var images []string
+	for _, p := range pods {
+		for _, c := range p.Spec.InitContainers {
+			images = append(images, c.Image)
+		}
+		for _, c := range p.Spec.Containers {
+			images = append(images, c.Image)
+		}
+	}
+	return images
+}
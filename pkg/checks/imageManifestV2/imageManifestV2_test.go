@@ -0,0 +1,124 @@
+package imageManifestV2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/kuberhealthy/pkg/registry"
+)
+
+func TestParseImage(t *testing.T) {
+
+	var tests = []struct {
+		image    string
+		expected imageRef
+	}{
+		{"nginx", imageRef{"docker.io", "library/nginx", "latest"}},
+		{"nginx:1.15", imageRef{"docker.io", "library/nginx", "1.15"}},
+		{"quay.io/comcast/kuberhealthy:v1.0.2", imageRef{"quay.io", "comcast/kuberhealthy", "v1.0.2"}},
+		{"localhost:5000/app", imageRef{"localhost:5000", "app", "latest"}},
+		{"gcr.io/google-containers/pause@sha256:abc123", imageRef{"gcr.io", "google-containers/pause", "sha256:abc123"}},
+		{"user/app:dev", imageRef{"docker.io", "user/app", "dev"}},
+	}
+
+	for _, test := range tests {
+		ref := parseImage(test.image)
+		if ref != test.expected {
+			t.Fatal("Parsing", test.image, "expected", test.expected, "but got", ref)
+		}
+	}
+}
+
+func TestParseDockerConfig(t *testing.T) {
+	data := []byte(`{"auths": {
+		"https://index.docker.io/v1/": {"auth": "dXNlcjpwYXNz"},
+		"quay.io": {"username": "robot", "password": "secret"}
+	}}`)
+	credentials, err := parseDockerConfig(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials["docker.io"].Username != "user" || credentials["docker.io"].Password != "pass" {
+		t.Fatal("Expected decoded docker hub credentials but got", credentials["docker.io"])
+	}
+	if credentials["quay.io"].Username != "robot" {
+		t.Fatal("Expected quay.io credentials but got", credentials["quay.io"])
+	}
+}
+
+func TestFetchManifestType(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			user, pass, ok := r.BasicAuth()
+			if !ok || user != "robot" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"token": "abc"}`))
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer abc" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+srv.URL+`/token",service="registry",scope="repository:`+strings.Split(r.URL.Path, "/")[2]+`:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v2/legacy/manifests/latest":
+			w.Header().Set("Content-Type", mediaTypeV1Signed)
+			w.Write([]byte(`{"schemaVersion": 1}`))
+		case "/v2/modern/manifests/latest":
+			if !strings.Contains(r.Header.Get("Accept"), mediaTypeV2) {
+				t.Error("Manifest request did not accept V2 manifests:", r.Header.Get("Accept"))
+			}
+			w.Header().Set("Content-Type", mediaTypeV2)
+			w.Write([]byte(`{"schemaVersion": 2}`))
+		case "/v2/generic/manifests/latest":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"schemaVersion": 1}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	registryHost := strings.TrimPrefix(srv.URL, "https://")
+	credentials := map[string]registry.Auth{registryHost: {Username: "robot", Password: "secret"}}
+
+	var tests = []struct {
+		image     string
+		expectV1  bool
+		expectErr bool
+	}{
+		{registryHost + "/legacy", true, false},
+		{registryHost + "/modern", false, false},
+		{registryHost + "/generic", true, false},
+		{registryHost + "/missing", false, true},
+	}
+
+	for _, test := range tests {
+		mediaType, err := fetchManifestType(srv.Client(), parseImage(test.image), credentials)
+		if test.expectErr {
+			if err == nil {
+				t.Fatal("Expected an error fetching", test.image)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal("Error fetching", test.image, err)
+		}
+		if isV1Manifest(mediaType) != test.expectV1 {
+			t.Fatal("Image", test.image, "returned unexpected media type", mediaType)
+		}
+	}
+
+	// without credentials the token endpoint refuses to issue a token
+	_, err := fetchManifestType(srv.Client(), parseImage(registryHost+"/modern"), map[string]registry.Auth{})
+	if err == nil {
+		t.Fatal("Expected an error fetching a manifest without credentials")
+	}
+}
@@ -0,0 +1,167 @@
+package imageManifestV2
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Comcast/kuberhealthy/pkg/registry"
+)
+
+// manifest media types requested from registries
+const (
+	mediaTypeV1          = "application/vnd.docker.distribution.manifest.v1+json"
+	mediaTypeV1Signed    = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+	mediaTypeV2          = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeV2List      = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex    = "application/vnd.oci.image.index.v1+json"
+)
+
+const dockerHubRegistry = "docker.io"
+const dockerHubHost = "registry-1.docker.io"
+
+// imageRef is a container image split into the parts needed to query its
+// registry
+type imageRef struct {
+	Registry   string
+	Repository string
+	Reference  string
+}
+
+// dockerConfig is the format of a kubernetes.io/dockerconfigjson secret
+type dockerConfig struct {
+	Auths map[string]registry.Auth `json:"auths"`
+}
+
+// parseImage splits an image into registry, repository, and tag or digest
+// using the same defaults as the docker cli
+func parseImage(image string) imageRef {
+	ref := imageRef{Registry: dockerHubRegistry, Reference: "latest"}
+
+	remainder := image
+	if i := strings.Index(remainder, "@"); i >= 0 {
+		ref.Reference = remainder[i+1:]
+		remainder = remainder[:i]
+	} else if i := strings.LastIndex(remainder, ":"); i > strings.LastIndex(remainder, "/") {
+		ref.Reference = remainder[i+1:]
+		remainder = remainder[:i]
+	}
+
+	parts := strings.SplitN(remainder, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.Registry = parts[0]
+		remainder = parts[1]
+	}
+
+	if ref.Registry == dockerHubRegistry && !strings.Contains(remainder, "/") {
+		remainder = "library/" + remainder
+	}
+	ref.Repository = remainder
+	return ref
+}
+
+// host returns the host to contact for the image's registry
+func (ref imageRef) host() string {
+	if ref.Registry == dockerHubRegistry {
+		return dockerHubHost
+	}
+	return ref.Registry
+}
+
+// parseDockerConfig parses docker config json into credentials keyed by
+// registry host
+func parseDockerConfig(data []byte) (map[string]registry.Auth, error) {
+	credentials := make(map[string]registry.Auth)
+
+	var config dockerConfig
+	err := json.Unmarshal(data, &config)
+	if err != nil {
+		return credentials, errors.New("Error decoding docker config json: " + err.Error())
+	}
+
+	for server, auth := range config.Auths {
+		if len(auth.Auth) > 0 && len(auth.Username) == 0 {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return credentials, errors.New("Error decoding auth for registry " + server + ": " + err.Error())
+			}
+			userPass := strings.SplitN(string(decoded), ":", 2)
+			if len(userPass) == 2 {
+				auth.Username = userPass[0]
+				auth.Password = userPass[1]
+			}
+		}
+		credentials[registryHostFromServer(server)] = auth
+	}
+	return credentials, nil
+}
+
+// registryHostFromServer normalizes a docker config server entry such as
+// https://index.docker.io/v1/ into the registry name used by images
+func registryHostFromServer(server string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	host = strings.SplitN(host, "/", 2)[0]
+	if host == "index.docker.io" || host == dockerHubHost {
+		return dockerHubRegistry
+	}
+	return host
+}
+
+// fetchManifestType requests the manifest of an image and returns the media
+// type the registry served.  Bearer token authentication is performed when
+// the registry asks for it.
+func fetchManifestType(httpClient *http.Client, ref imageRef, credentials map[string]registry.Auth) (string, error) {
+	manifestURL := "https://" + ref.host() + "/v2/" + ref.Repository + "/manifests/" + ref.Reference
+	var auth *registry.Auth
+	if a, ok := credentials[ref.Registry]; ok {
+		auth = &a
+	}
+
+	resp, err := registry.Get(httpClient, manifestURL, strings.Join([]string{mediaTypeV2, mediaTypeV2List, mediaTypeOCIManifest, mediaTypeOCIIndex}, ", "), auth)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("registry returned status " + strconv.Itoa(resp.StatusCode) + " for " + manifestURL)
+	}
+
+	mediaType := strings.TrimSpace(strings.SplitN(resp.Header.Get("Content-Type"), ";", 2)[0])
+	switch mediaType {
+	case mediaTypeV1, mediaTypeV1Signed, mediaTypeV2, mediaTypeV2List, mediaTypeOCIManifest, mediaTypeOCIIndex:
+		return mediaType, nil
+	}
+
+	// some registries serve a generic content type, so fall back to the
+	// schema version of the manifest itself
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var manifest struct {
+		SchemaVersion int    `json:"schemaVersion"`
+		MediaType     string `json:"mediaType"`
+	}
+	err = json.Unmarshal(b, &manifest)
+	if err != nil {
+		return "", errors.New("Error decoding manifest from " + manifestURL + ": " + err.Error())
+	}
+	if len(manifest.MediaType) > 0 {
+		return manifest.MediaType, nil
+	}
+	if manifest.SchemaVersion == 1 {
+		return mediaTypeV1, nil
+	}
+	return mediaTypeV2, nil
+}
+
+// isV1Manifest determines if a media type is a deprecated V1 manifest
+func isV1Manifest(mediaType string) bool {
+	return mediaType == mediaTypeV1 || mediaType == mediaTypeV1Signed
+}
@@ -0,0 +1,254 @@
+// Package imagePolicyWebhook implements a checker that ensures the image
+// policy webhook admission plugin is enforcing policy.  When the plugin is
+// enabled on the API server, a pod using a prohibited image is created with a
+// dry run and the admission must be rejected.
+package imagePolicyWebhook // import "github.com/Comcast/kuberhealthy/pkg/checks/imagePolicyWebhook"
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+const (
+	// pluginName is the name of the image policy webhook admission plugin
+	pluginName = "ImagePolicyWebhook"
+	// canaryName is the name of the canary pod
+	canaryName = "kuberhealthy-image-policy-canary"
+)
+
+// admissionPluginFlags are the API server flags that enable admission
+// plugins.  --admission-control is the deprecated form.
+var admissionPluginFlags = []string{"--enable-admission-plugins", "--admission-control"}
+
+// Checker validates that the image policy webhook rejects prohibited images
+type Checker struct {
+	Errors          []string
+	ProhibitedImage string
+	client          *kubernetes.Clientset
+	// dryRunCreate is replaced in tests to inject admission responses
+	dryRunCreate func(pod *apiv1.Pod) error
+	interval     time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that expects pods using prohibitedImage to be
+// rejected
+func New(prohibitedImage string) *Checker {
+	ipc := &Checker{
+		Errors:          []string{},
+		ProhibitedImage: prohibitedImage,
+	}
+	ipc.dryRunCreate = ipc.apiDryRunCreate
+	return ipc
+}
+
+// Name returns the name of this checker
+func (ipc *Checker) Name() string {
+	return "ImagePolicyWebhookChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (ipc *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (ipc *Checker) Interval() time.Duration {
+	if ipc.interval > 0 {
+		return ipc.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (ipc *Checker) SetInterval(d time.Duration) {
+	ipc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  The kube-apiserver
+// pods are inspected for the admission plugin, and the test pod is created
+// with a dry run in the Kuberhealthy namespace, which is authorized like a
+// real create.
+func (ipc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		metav1.NamespaceSystem: {
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		},
+		namespace: {
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"create"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (ipc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (ipc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (ipc *Checker) CurrentStatus() (bool, []string) {
+	if len(ipc.Errors) > 0 {
+		return false, ipc.Errors
+	}
+	return true, ipc.Errors
+}
+
+// clearErrors clears all errors
+func (ipc *Checker) clearErrors() {
+	ipc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (ipc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	ipc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := ipc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(ipc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + ipc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(ipc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + ipc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + ipc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks skips clusters without the image policy webhook and otherwise
+// sets an error when a pod using the prohibited image is admitted
+func (ipc *Checker) doChecks() error {
+
+	pods, err := ipc.client.CoreV1().Pods(metav1.NamespaceSystem).List(metav1.ListOptions{
+		LabelSelector: "component=kube-apiserver",
+	})
+	if err != nil {
+		return err
+	}
+	var enabled bool
+	for _, p := range pods.Items {
+		if pluginEnabled(p) {
+			enabled = true
+			break
+		}
+	}
+	if !enabled {
+		khlog.ForCheck(ipc.Name()).Debugln("no kube-apiserver pods enable the", pluginName, "admission plugin. Skipping image policy check.")
+		ipc.clearErrors()
+		return nil
+	}
+
+	policyErrors, err := ipc.checkPolicy()
+	if err != nil {
+		return err
+	}
+
+	if len(policyErrors) > 0 {
+		for _, e := range policyErrors {
+			khlog.ForCheck(ipc.Name()).Warningln(e)
+		}
+		ipc.Errors = policyErrors
+		return nil
+	}
+
+	ipc.clearErrors()
+	return nil
+}
+
+// checkPolicy creates the canary pod with a dry run and returns an error when
+// it is admitted.  Rejections other than a Forbidden response are returned
+// as check failures.
+func (ipc *Checker) checkPolicy() ([]string, error) {
+	err := ipc.dryRunCreate(ipc.canaryPod())
+	if k8sErrors.IsForbidden(err) {
+		khlog.ForCheck(ipc.Name()).Debugln("pod using prohibited image", ipc.ProhibitedImage, "was rejected:", err)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New("Error creating canary pod: " + err.Error())
+	}
+	return []string{"CRITICAL: The " + pluginName + " admission plugin is enabled but admitted a pod using the prohibited image " + ipc.ProhibitedImage}, nil
+}
+
+// apiDryRunCreate creates a pod in the Kuberhealthy namespace with a dry run
+// so that it passes through admission without being persisted
+func (ipc *Checker) apiDryRunCreate(pod *apiv1.Pod) error {
+	return ipc.client.CoreV1().RESTClient().Post().
+		Namespace(namespace).
+		Resource("pods").
+		Param("dryRun", "All").
+		Body(pod).
+		Do().
+		Error()
+}
+
+// canaryPod returns a pod that uses the prohibited image
+func (ipc *Checker) canaryPod() *apiv1.Pod {
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      canaryName,
+			Namespace: namespace,
+			Labels:    map[string]string{"app": "kuberhealthy", "source": "kuberhealthy"},
+		},
+		Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{
+				{Name: "canary", Image: ipc.ProhibitedImage},
+			},
+		},
+	}
+}
+
+// pluginEnabled returns true if the flags of a kube-apiserver pod enable the
+// image policy webhook admission plugin
+func pluginEnabled(pod apiv1.Pod) bool {
+	for _, c := range pod.Spec.Containers {
+		args := append(append([]string{}, c.Command...), c.Args...)
+		for i, a := range args {
+			for _, flag := range admissionPluginFlags {
+				var value string
+				if strings.HasPrefix(a, flag+"=") {
+					value = strings.TrimPrefix(a, flag+"=")
+				} else if a == flag && i+1 < len(args) {
+					value = args[i+1]
+				} else {
+					continue
+				}
+				for _, plugin := range strings.Split(value, ",") {
+					if strings.TrimSpace(plugin) == pluginName {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package imagePolicyWebhook
+
+import (
+	"errors"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCheckPolicy(t *testing.T) {
+	var tests = []struct {
+		description    string
+		response       error
+		expectedErrors int
+		expectedErr    bool
+	}{
+		{"rejected", k8sErrors.NewForbidden(schema.GroupResource{Resource: "pods"}, canaryName, errors.New("image policy webhook backend denied one or more images")), 0, false},
+		{"admitted", nil, 1, false},
+		{"invalid", k8sErrors.NewBadRequest("dry run is not supported"), 0, true},
+		{"unreachable", errors.New("connection refused"), 0, true},
+	}
+
+	for _, test := range tests {
+		checker := New("docker.io/library/ubuntu:latest")
+		checker.dryRunCreate = func(pod *apiv1.Pod) error {
+			if pod.Spec.Containers[0].Image != "docker.io/library/ubuntu:latest" {
+				t.Fatal("Test", test.description, "expected the prohibited image but got", pod.Spec.Containers[0].Image)
+			}
+			return test.response
+		}
+
+		policyErrors, err := checker.checkPolicy()
+		if (err != nil) != test.expectedErr {
+			t.Fatal("Test", test.description, "expected error", test.expectedErr, "but got", err)
+		}
+		if len(policyErrors) != test.expectedErrors {
+			t.Fatal("Test", test.description, "expected", test.expectedErrors, "errors but got", policyErrors)
+		}
+		t.Log(test.description, policyErrors, err)
+	}
+}
+
+func TestPluginEnabled(t *testing.T) {
+	var tests = []struct {
+		description string
+		command     []string
+		expected    bool
+	}{
+		{"enabled", []string{"kube-apiserver", "--enable-admission-plugins=NodeRestriction,ImagePolicyWebhook"}, true},
+		{"enabled with separate value", []string{"kube-apiserver", "--enable-admission-plugins", "ImagePolicyWebhook"}, true},
+		{"deprecated flag", []string{"kube-apiserver", "--admission-control=NamespaceLifecycle,ImagePolicyWebhook"}, true},
+		{"not enabled", []string{"kube-apiserver", "--enable-admission-plugins=NodeRestriction"}, false},
+		{"disabled flag only", []string{"kube-apiserver", "--disable-admission-plugins=ImagePolicyWebhook"}, false},
+	}
+
+	for _, test := range tests {
+		pod := apiv1.Pod{Spec: apiv1.PodSpec{Containers: []apiv1.Container{{Command: test.command}}}}
+		if pluginEnabled(pod) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+// Package imagePull implements a checker that pulls a test image on every
+// node.  A daemonset running the image is deployed with the daemonset
+// checker and the image is always pulled from its registry, which verifies
+// registry credentials and the connectivity of each node to the registry
+// before real workloads end up in ImagePullBackOff.
+package imagePull // import "github.com/Comcast/kuberhealthy/pkg/checks/imagePull"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/checks/componentStatus"
+	"github.com/Comcast/kuberhealthy/pkg/checks/daemonSet"
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const daemonSetBaseName = "image-pull-test"
+
+// pullErrorReasons are the reasons a container waits when its image can not
+// be pulled
+var pullErrorReasons = map[string]bool{
+	"ErrImagePull":        true,
+	"ImagePullBackOff":    true,
+	"InvalidImageName":    true,
+	"ErrImageNeverPull":   true,
+	"RegistryUnavailable": true,
+}
+
+// Checker validates that a test image can be pulled on every node
+type Checker struct {
+	Image       string
+	PullTimeout time.Duration // how long every node has to pull the image
+	Errors      []string
+	ds          *daemonSet.Checker
+	client      *kubernetes.Clientset
+	interval    time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that pulls image on every node within
+// pullTimeout.  The image is pulled with the supplied image pull secret
+// when one is given.
+func New(image string, pullSecret string, pullTimeout time.Duration) (*Checker, error) {
+	ds, err := daemonSet.NewWithBaseName(daemonSetBaseName)
+	if err != nil {
+		return nil, err
+	}
+	ds.PauseContainerImage = image
+	ds.ImagePullPolicy = apiv1.PullAlways
+	if len(pullSecret) > 0 {
+		ds.ImagePullSecrets = []apiv1.LocalObjectReference{{Name: pullSecret}}
+	}
+
+	return &Checker{
+		Image:       image,
+		PullTimeout: pullTimeout,
+		Errors:      []string{},
+		ds:          ds,
+	}, nil
+}
+
+// Name returns the name of this checker
+func (ipc *Checker) Name() string {
+	return "ImagePullChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (ipc *Checker) CheckNamespace() string {
+	return ipc.ds.Namespace
+}
+
+// Interval returns the interval at which this check runs
+func (ipc *Checker) Interval() time.Duration {
+	if ipc.interval > 0 {
+		return ipc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (ipc *Checker) SetInterval(d time.Duration) {
+	ipc.interval = d
+}
+
+// Timeout returns the maximum run time for this check before it times out.
+// This leaves time to remove the daemonset after the pull timeout.
+func (ipc *Checker) Timeout() time.Duration {
+	return ipc.PullTimeout + time.Minute*5
+}
+
+// RBACRules returns the RBAC rules this check needs, which are the rules of
+// the daemonset checker that deploys the image
+func (ipc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return ipc.ds.RBACRules()
+}
+
+// DependsOn returns the component status checker, because the test
+// daemonset can not be deployed while the control plane is unhealthy
+func (ipc *Checker) DependsOn() []string {
+	return []string{componentStatus.CheckName}
+}
+
+// Shutdown removes the daemonset if it is deployed
+func (ipc *Checker) Shutdown() error {
+	return ipc.ds.Shutdown()
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (ipc *Checker) CurrentStatus() (bool, []string) {
+	if len(ipc.Errors) > 0 {
+		return false, ipc.Errors
+	}
+	return true, ipc.Errors
+}
+
+// clearErrors clears all errors
+func (ipc *Checker) clearErrors() {
+	ipc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (ipc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+
+	// make a context for this run that is also cancelled when the context
+	// of the check is cancelled
+	runCtx, cancelCtx := context.WithCancel(ctx)
+	defer cancelCtx()
+
+	// buffered so that doChecks can always send its result, even after Run
+	// stopped waiting for it
+	doneChan := make(chan error, 1)
+
+	ipc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := ipc.doChecks(runCtx)
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(ipc.Interval()):
+		// The check has timed out because its time to run again
+		cancelCtx()
+		ipc.cleanUpCancelledRun(doneChan)
+		return errors.New("Failed to complete checks for " + ipc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(ipc.Timeout()):
+		// The check has timed out after its specified timeout period
+		cancelCtx()
+		ipc.cleanUpCancelledRun(doneChan)
+		return errors.New("Failed to complete checks for " + ipc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		cancelCtx()
+		ipc.cleanUpCancelledRun(doneChan)
+		return errors.New("Failed to complete checks for " + ipc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// cleanUpCancelledRun waits a bounded time for doChecks to stop after its
+// context was cancelled, then removes the daemonset it may have left behind
+func (ipc *Checker) cleanUpCancelledRun(doneChan chan error) {
+	select {
+	case <-doneChan:
+	case <-time.After(time.Second * 30):
+		khlog.ForCheck(ipc.Name()).Warningln("Checks did not stop within 30 seconds of being cancelled.")
+	}
+
+	// the context of the run is cancelled, so cleanup gets its own
+	ctx, cancelCtx := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancelCtx()
+	err := ipc.ds.CleanUp(ctx)
+	if err != nil {
+		khlog.ForCheck(ipc.Name()).Errorln("Error removing the image pull daemonset after the check was cancelled:", err)
+	}
+}
+
+// doChecks deploys the daemonset, waits for every node to pull the image,
+// and removes the daemonset again
+func (ipc *Checker) doChecks(ctx context.Context) error {
+
+	err := ipc.ds.Deploy(ctx, ipc.client)
+	if err != nil {
+		return errors.New("Error deploying the image pull daemonset: " + err.Error())
+	}
+
+	statusErrors := ipc.waitForPulls(ctx)
+
+	// the daemonset is removed after every run, even when the image could
+	// not be pulled
+	err = ipc.ds.Remove(ctx)
+	if err != nil {
+		return errors.New("Error removing the image pull daemonset: " + err.Error())
+	}
+
+	if len(statusErrors) > 0 {
+		for _, e := range statusErrors {
+			khlog.ForCheck(ipc.Name()).Warningln(e)
+		}
+		ipc.Errors = statusErrors
+		return nil
+	}
+
+	ipc.clearErrors()
+	return nil
+}
+
+// waitForPulls waits until every node has pulled the image and returns the
+// nodes that have not when the pull timeout is reached
+func (ipc *Checker) waitForPulls(ctx context.Context) []string {
+	pullCtx, cancelCtx := context.WithTimeout(ctx, ipc.PullTimeout)
+	defer cancelCtx()
+
+	statusErrors := []string{"Image " + ipc.Image + " was not pulled within " + ipc.PullTimeout.String() + "."}
+	for {
+		select {
+		case <-pullCtx.Done():
+			return statusErrors
+		case <-time.After(time.Second):
+		}
+
+		nodes, err := ipc.ds.Nodes()
+		if err != nil {
+			khlog.ForCheck(ipc.Name()).Warningln("Error listing nodes. Retrying.", err)
+			continue
+		}
+		pods, err := ipc.ds.Pods()
+		if err != nil {
+			khlog.ForCheck(ipc.Name()).Warningln("Error listing image pull pods. Retrying.", err)
+			continue
+		}
+
+		statusErrors = evaluatePulls(ipc.Image, nodes, pods)
+		if len(statusErrors) == 0 {
+			khlog.ForCheck(ipc.Name()).Infoln("Image", ipc.Image, "was pulled on", len(nodes), "nodes")
+			return nil
+		}
+		khlog.ForCheck(ipc.Name()).Infoln("Waiting for", len(statusErrors), "nodes to pull image", ipc.Image)
+	}
+}
+
+// evaluatePulls returns an error for every node that has not pulled the
+// image.  An image is pulled once a container was created from it, even if
+// the container exited since.  Nodes whose container can not pull the image
+// show the reason and message of the kubelet.
+func evaluatePulls(image string, nodes []apiv1.Node, pods []apiv1.Pod) []string {
+	var statusErrors []string
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+
+	podsByNode := make(map[string]apiv1.Pod)
+	for _, p := range pods {
+		podsByNode[p.Spec.NodeName] = p
+	}
+
+	for _, n := range nodes {
+		p, ok := podsByNode[n.Name]
+		if !ok {
+			statusErrors = append(statusErrors, "Image "+image+" was not pulled on node "+n.Name+" because no pod was scheduled to it.")
+			continue
+		}
+		if len(p.Status.ContainerStatuses) == 0 {
+			statusErrors = append(statusErrors, "Image "+image+" was not pulled on node "+n.Name+".")
+			continue
+		}
+
+		for _, cs := range p.Status.ContainerStatuses {
+			if len(cs.ImageID) > 0 || cs.State.Running != nil || cs.State.Terminated != nil {
+				continue
+			}
+			waiting := cs.State.Waiting
+			if waiting == nil {
+				statusErrors = append(statusErrors, "Image "+image+" was not pulled on node "+n.Name+".")
+				continue
+			}
+			if pullErrorReasons[waiting.Reason] {
+				statusErrors = append(statusErrors, "Image "+image+" could not be pulled on node "+n.Name+": "+waiting.Reason+": "+waiting.Message)
+				continue
+			}
+			statusErrors = append(statusErrors, "Image "+image+" was not pulled on node "+n.Name+": "+waiting.Reason)
+		}
+	}
+	return statusErrors
+}
@@ -0,0 +1,69 @@
+package imagePull
+
+import (
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluatePulls(t *testing.T) {
+	const image = "gcr.io/google_containers/pause:0.8.0"
+
+	nodes := []apiv1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}}
+	makePods := func(status apiv1.ContainerStatus) []apiv1.Pod {
+		return []apiv1.Pod{{
+			ObjectMeta: metav1.ObjectMeta{Name: "image-pull-test-abcde"},
+			Spec:       apiv1.PodSpec{NodeName: "node-1"},
+			Status:     apiv1.PodStatus{ContainerStatuses: []apiv1.ContainerStatus{status}},
+		}}
+	}
+	waiting := func(reason string, message string) apiv1.ContainerStatus {
+		return apiv1.ContainerStatus{State: apiv1.ContainerState{Waiting: &apiv1.ContainerStateWaiting{Reason: reason, Message: message}}}
+	}
+
+	var tests = []struct {
+		description   string
+		pods          []apiv1.Pod
+		expectedError string
+	}{
+		{"running", makePods(apiv1.ContainerStatus{ImageID: "docker-pullable://pause@sha256:abc", State: apiv1.ContainerState{Running: &apiv1.ContainerStateRunning{}}}), ""},
+		{"pulled but crashing", makePods(apiv1.ContainerStatus{ImageID: "docker-pullable://pause@sha256:abc", State: apiv1.ContainerState{Waiting: &apiv1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}}), ""},
+		{"pull backoff", makePods(waiting("ImagePullBackOff", "Back-off pulling image")), "Image " + image + " could not be pulled on node node-1: ImagePullBackOff: Back-off pulling image"},
+		{"pull error", makePods(waiting("ErrImagePull", "unauthorized")), "could not be pulled on node node-1: ErrImagePull: unauthorized"},
+		{"still creating", makePods(waiting("ContainerCreating", "")), "Image " + image + " was not pulled on node node-1: ContainerCreating"},
+		{"no container status", makePods(apiv1.ContainerStatus{}), "was not pulled on node node-1"},
+		{"no pod", nil, "was not pulled on node node-1 because no pod was scheduled to it."},
+	}
+
+	for _, test := range tests {
+		statusErrors := evaluatePulls(image, nodes, test.pods)
+		if len(test.expectedError) == 0 {
+			if len(statusErrors) != 0 {
+				t.Fatal("Test", test.description, "expected no errors but got", statusErrors)
+			}
+			continue
+		}
+		if len(statusErrors) != 1 || !strings.Contains(statusErrors[0], test.expectedError) {
+			t.Fatal("Test", test.description, "expected an error containing", test.expectedError, "but got", statusErrors)
+		}
+		t.Log(test.description, statusErrors)
+	}
+}
+
+func TestNew(t *testing.T) {
+	ipc, err := New("registry.example.com/pause:3.1", "registry-credentials", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(ipc.ds.DaemonSetName, daemonSetBaseName+"-") {
+		t.Fatal("Expected the daemonset name to start with", daemonSetBaseName, "but got", ipc.ds.DaemonSetName)
+	}
+	if ipc.ds.PauseContainerImage != "registry.example.com/pause:3.1" || ipc.ds.ImagePullPolicy != apiv1.PullAlways {
+		t.Fatal("Expected the daemonset to always pull the test image but got", ipc.ds.PauseContainerImage, ipc.ds.ImagePullPolicy)
+	}
+	if len(ipc.ds.ImagePullSecrets) != 1 || ipc.ds.ImagePullSecrets[0].Name != "registry-credentials" {
+		t.Fatal("Expected the image pull secret to be used but got", ipc.ds.ImagePullSecrets)
+	}
+}
@@ -0,0 +1,185 @@
+// Package imagePullPolicy implements a checker that ensures containers use a
+// pull policy suited to how their image is referenced.  A mutable tag pulled
+// only when not present can run stale code on nodes that pulled the tag
+// before it was updated, while an immutable digest pulled on every start
+// only adds registry traffic.
+package imagePullPolicy // import "github.com/Comcast/kuberhealthy/pkg/checks/imagePullPolicy"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Checker validates the image pull policies of containers
+type Checker struct {
+	Errors     []string
+	Namespaces []string
+	client     *kubernetes.Clientset
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied
+func New(namespaces []string) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		Errors:     []string{},
+		Namespaces: namespaces,
+	}
+}
+
+// Name returns the name of this checker
+func (ipc *Checker) Name() string {
+	return "ImagePullPolicyChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (ipc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (ipc *Checker) Interval() time.Duration {
+	if ipc.interval > 0 {
+		return ipc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (ipc *Checker) SetInterval(d time.Duration) {
+	ipc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Only pods are read, in
+// whichever namespaces are checked.
+func (ipc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range ipc.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		}
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (ipc *Checker) Timeout() time.Duration {
+	return time.Minute * 2
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (ipc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (ipc *Checker) CurrentStatus() (bool, []string) {
+	if len(ipc.Errors) > 0 {
+		return false, ipc.Errors
+	}
+	return true, ipc.Errors
+}
+
+// clearErrors clears all errors
+func (ipc *Checker) clearErrors() {
+	ipc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (ipc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	ipc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := ipc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(ipc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + ipc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(ipc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + ipc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + ipc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists pods and sets an error for every container whose pull
+// policy does not suit its image reference
+func (ipc *Checker) doChecks() error {
+
+	var pods []apiv1.Pod
+	for _, ns := range ipc.Namespaces {
+		podList, err := ipc.client.CoreV1().Pods(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return errors.New("Error listing pods: " + err.Error())
+		}
+		pods = append(pods, podList.Items...)
+	}
+
+	policyErrors := evaluatePods(pods)
+
+	if len(policyErrors) > 0 {
+		for _, e := range policyErrors {
+			khlog.ForCheck(ipc.Name()).Warningln(e)
+		}
+		ipc.Errors = policyErrors
+		return nil
+	}
+
+	ipc.clearErrors()
+	return nil
+}
+
+// evaluatePods returns an error for every container that references its
+// image by a mutable tag with the IfNotPresent pull policy, or by an
+// immutable digest with the Always pull policy
+func evaluatePods(pods []apiv1.Pod) []string {
+	var policyErrors []string
+
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Namespace+"/"+pods[i].Name < pods[j].Namespace+"/"+pods[j].Name
+	})
+	for _, p := range pods {
+		containers := append(append([]apiv1.Container{}, p.Spec.InitContainers...), p.Spec.Containers...)
+		for _, c := range containers {
+			switch {
+			case !isDigest(c.Image) && c.ImagePullPolicy == apiv1.PullIfNotPresent:
+				policyErrors = append(policyErrors, "Container "+c.Name+" in pod "+p.Namespace+"/"+p.Name+" uses mutable image tag "+c.Image+
+					" with pull policy IfNotPresent and may run a stale image after the tag is updated")
+			case isDigest(c.Image) && c.ImagePullPolicy == apiv1.PullAlways:
+				policyErrors = append(policyErrors, "Container "+c.Name+" in pod "+p.Namespace+"/"+p.Name+" uses immutable image digest "+c.Image+
+					" with pull policy Always which pulls from the registry needlessly")
+			}
+		}
+	}
+	return policyErrors
+}
+
+// isDigest determines if an image is referenced by digest
+func isDigest(image string) bool {
+	return strings.Contains(image, "@sha256:")
+}
@@ -0,0 +1,47 @@
+package imagePullPolicy
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const digestImage = "nginx@sha256:4a5573037f358b6cdfa2f3e8a9c33a5cf11bcd1675ca72ca76fbe5bd77d0d682"
+
+func TestEvaluatePods(t *testing.T) {
+	makePod := func(image string, policy apiv1.PullPolicy) apiv1.Pod {
+		return apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+			Spec: apiv1.PodSpec{
+				Containers: []apiv1.Container{{Name: "web", Image: image, ImagePullPolicy: policy}},
+			},
+		}
+	}
+
+	initContainer := makePod(digestImage, apiv1.PullIfNotPresent)
+	initContainer.Spec.InitContainers = []apiv1.Container{{Name: "setup", Image: "busybox:1.30", ImagePullPolicy: apiv1.PullIfNotPresent}}
+
+	var tests = []struct {
+		description string
+		pod         apiv1.Pod
+		expected    int
+	}{
+		{"tag with Always", makePod("nginx:1.15", apiv1.PullAlways), 0},
+		{"tag with IfNotPresent", makePod("nginx:1.15", apiv1.PullIfNotPresent), 1},
+		{"registry port with IfNotPresent", makePod("registry.local:5000/nginx:1.15", apiv1.PullIfNotPresent), 1},
+		{"tag with Never", makePod("nginx:1.15", apiv1.PullNever), 0},
+		{"digest with IfNotPresent", makePod(digestImage, apiv1.PullIfNotPresent), 0},
+		{"digest with Always", makePod(digestImage, apiv1.PullAlways), 1},
+		{"tag and digest with Always", makePod("nginx:1.15@sha256:4a5573037f358b6cdfa2f3e8a9c33a5cf11bcd1675ca72ca76fbe5bd77d0d682", apiv1.PullAlways), 1},
+		{"init container tag with IfNotPresent", initContainer, 1},
+	}
+
+	for _, test := range tests {
+		policyErrors := evaluatePods([]apiv1.Pod{test.pod})
+		if len(policyErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", policyErrors)
+		}
+		t.Log(test.description, policyErrors)
+	}
+}
@@ -0,0 +1,246 @@
+// Package ingressCheck implements a checker that ensures HTTP and HTTPS
+// traffic reaches cluster services through their Ingress URLs.  DNS for an
+// Ingress host can resolve while a misconfigured Ingress controller leaves
+// the service behind it unreachable.
+package ingressCheck // import "github.com/Comcast/kuberhealthy/pkg/checks/ingressCheck"
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Checker validates that Ingress URLs respond with an acceptable status
+type Checker struct {
+	Errors       []string
+	URLs         []string
+	MinStatus    int
+	MaxStatus    int
+	MaxRedirects int
+	// TokenFile is the path of a file holding a bearer token sent with every
+	// request.  No token is sent when it is empty.
+	TokenFile  string
+	httpClient *http.Client
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that requests each URL with the supplied
+// timeout, following up to maxRedirects redirects, and accepts response
+// codes in statusRange given as min-max, such as 200-299
+func New(urls []string, timeout time.Duration, statusRange string, maxRedirects int, tokenFile string) (*Checker, error) {
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return nil, errors.New("invalid ingress check URL " + u + ": " + err.Error())
+		}
+		if (parsed.Scheme != "http" && parsed.Scheme != "https") || len(parsed.Host) == 0 {
+			return nil, errors.New("invalid ingress check URL " + u + ": an http or https URL is required")
+		}
+	}
+	minStatus, maxStatus, err := parseStatusRange(statusRange)
+	if err != nil {
+		return nil, err
+	}
+	if maxRedirects < 0 {
+		return nil, errors.New("the maximum number of ingress check redirects can not be negative")
+	}
+
+	return &Checker{
+		Errors:       []string{},
+		URLs:         urls,
+		MinStatus:    minStatus,
+		MaxStatus:    maxStatus,
+		MaxRedirects: maxRedirects,
+		TokenFile:    tokenFile,
+		httpClient: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > maxRedirects {
+					return errors.New("stopped after " + strconv.Itoa(maxRedirects) + " redirects")
+				}
+				return nil
+			},
+		},
+	}, nil
+}
+
+// Name returns the name of this checker
+func (icc *Checker) Name() string {
+	return "IngressChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (icc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (icc *Checker) Interval() time.Duration {
+	if icc.interval > 0 {
+		return icc.interval
+	}
+	return time.Minute * 1
+}
+
+// SetInterval overrides the interval at which this check runs
+func (icc *Checker) SetInterval(d time.Duration) {
+	icc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Ingress URLs are
+// requested directly, so none are needed.
+func (icc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (icc *Checker) Timeout() time.Duration {
+	return time.Minute * 2
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (icc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (icc *Checker) CurrentStatus() (bool, []string) {
+	if len(icc.Errors) > 0 {
+		return false, icc.Errors
+	}
+	return true, icc.Errors
+}
+
+// clearErrors clears all errors
+func (icc *Checker) clearErrors() {
+	icc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (icc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := icc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(icc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + icc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(icc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + icc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + icc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks requests every URL and sets an error for every URL that can not
+// be reached or responds with a status outside of the acceptable range
+func (icc *Checker) doChecks() error {
+
+	token, err := icc.readToken()
+	if err != nil {
+		return err
+	}
+
+	var ingressErrors []string
+	for _, u := range icc.URLs {
+		status, err := icc.get(u, token)
+		if err != nil {
+			ingressErrors = append(ingressErrors, "Error requesting ingress URL "+u+": "+err.Error())
+			continue
+		}
+		khlog.ForCheck(icc.Name()).Debugln("ingress URL", u, "responded with status", status)
+		if status < icc.MinStatus || status > icc.MaxStatus {
+			ingressErrors = append(ingressErrors, "Ingress URL "+u+" responded with status "+strconv.Itoa(status)+" "+http.StatusText(status)+
+				" which is outside of the acceptable range "+strconv.Itoa(icc.MinStatus)+"-"+strconv.Itoa(icc.MaxStatus))
+		}
+	}
+
+	if len(ingressErrors) > 0 {
+		for _, e := range ingressErrors {
+			khlog.ForCheck(icc.Name()).Warningln(e)
+		}
+		icc.Errors = ingressErrors
+		return nil
+	}
+
+	icc.clearErrors()
+	return nil
+}
+
+// get requests a URL with the bearer token when one is set and returns the
+// status code of the final response after redirects
+func (icc *Checker) get(u string, token string) (int, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := icc.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// drain the body so that the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// readToken returns the bearer token from the token file, which is read on
+// every run so that rotated tokens are picked up
+func (icc *Checker) readToken() (string, error) {
+	if len(icc.TokenFile) == 0 {
+		return "", nil
+	}
+	b, err := ioutil.ReadFile(icc.TokenFile)
+	if err != nil {
+		return "", errors.New("Error reading ingress check bearer token file: " + err.Error())
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// parseStatusRange parses a range of status codes given as min-max, or a
+// single status code
+func parseStatusRange(s string) (int, int, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), "-", 2)
+	if len(parts) == 1 {
+		parts = append(parts, parts[0])
+	}
+	minStatus, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, errors.New("invalid ingress check status range " + s + ": " + err.Error())
+	}
+	maxStatus, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, errors.New("invalid ingress check status range " + s + ": " + err.Error())
+	}
+	if minStatus < 100 || maxStatus > 599 || minStatus > maxStatus {
+		return 0, 0, errors.New("invalid ingress check status range " + s + ": status codes must be ascending and between 100 and 599")
+	}
+	return minStatus, maxStatus, nil
+}
@@ -0,0 +1,126 @@
+package ingressCheck
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ingressServer returns a server that responds to /ok, /missing, and
+// /redirect/n, which redirects n times before reaching /ok.  /private
+// requires the bearer token "secret".
+func ingressServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/ok":
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/private":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/redirect/0":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		case strings.HasPrefix(r.URL.Path, "/redirect/"):
+			n := strings.TrimPrefix(r.URL.Path, "/redirect/")
+			next := string(n[0] - 1)
+			http.Redirect(w, r, "/redirect/"+next, http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestParseStatusRange(t *testing.T) {
+	var tests = []struct {
+		description string
+		statusRange string
+		min         int
+		max         int
+		valid       bool
+	}{
+		{"range", "200-299", 200, 299, true},
+		{"single status", "204", 204, 204, true},
+		{"spaces", " 200 - 399 ", 200, 399, true},
+		{"descending", "299-200", 0, 0, false},
+		{"out of bounds", "0-999", 0, 0, false},
+		{"not a number", "2xx", 0, 0, false},
+	}
+
+	for _, test := range tests {
+		min, max, err := parseStatusRange(test.statusRange)
+		if (err == nil) != test.valid || min != test.min || max != test.max {
+			t.Fatal("Test", test.description, "expected", test.min, test.max, test.valid, "but got", min, max, err)
+		}
+	}
+}
+
+func TestDoChecks(t *testing.T) {
+	server := ingressServer()
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "ingressCheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	err = ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		description   string
+		path          string
+		statusRange   string
+		tokenFile     string
+		expectedError string
+	}{
+		{"ok", "/ok", "200-299", "", ""},
+		{"not found", "/missing", "200-299", "", "status 404"},
+		{"not found accepted", "/missing", "200-499", "", ""},
+		{"redirects followed", "/redirect/2", "200-299", "", ""},
+		{"too many redirects", "/redirect/4", "200-299", "", "stopped after 3 redirects"},
+		{"missing token", "/private", "200-299", "", "status 401"},
+		{"token", "/private", "200-299", tokenFile, ""},
+	}
+
+	for _, test := range tests {
+		checker, err := New([]string{server.URL + test.path}, time.Second*5, test.statusRange, 3, test.tokenFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = checker.doChecks()
+		if err != nil {
+			t.Fatal("Test", test.description, "unexpected error:", err)
+		}
+		if len(test.expectedError) == 0 {
+			if len(checker.Errors) != 0 {
+				t.Fatal("Test", test.description, "expected no errors but got", checker.Errors)
+			}
+			continue
+		}
+		if len(checker.Errors) != 1 || !strings.Contains(checker.Errors[0], test.expectedError) {
+			t.Fatal("Test", test.description, "expected an error containing", test.expectedError, "but got", checker.Errors)
+		}
+		t.Log(test.description, checker.Errors)
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New([]string{"ftp://example.com"}, time.Second, "200-299", 3, "")
+	if err == nil {
+		t.Fatal("Expected an error for a URL that is not http or https")
+	}
+	_, err = New([]string{"https://example.com"}, time.Second, "200-299", -1, "")
+	if err == nil {
+		t.Fatal("Expected an error for a negative redirect count")
+	}
+}
@@ -0,0 +1,336 @@
+// Package ingressControllerHealth implements a checker that ensures ingress
+// controller pods are running, ready and processing traffic.  A canary
+// Ingress routing to the Kuberhealthy service is created for every run and a
+// request is made through each ingress controller pod to verify that it is
+// routed.
+package ingressControllerHealth // import "github.com/Comcast/kuberhealthy/pkg/checks/ingressControllerHealth"
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// namespace is where the canary Ingress is created
+var namespace = os.Getenv("POD_NAMESPACE")
+
+const (
+	// canaryName is the name of the canary Ingress
+	canaryName = "kuberhealthy-ingress-canary"
+	// canaryHost is the host the canary Ingress routes
+	canaryHost = "kuberhealthy-ingress-canary.local"
+	// canaryPath is a path on the Kuberhealthy service that always responds
+	// with 200 OK, regardless of the state of other checks
+	canaryPath = "/metrics"
+	// backendService is the Kuberhealthy service the canary Ingress routes to
+	backendService = "kuberhealthy"
+	// backendPort is the port of the Kuberhealthy service
+	backendPort = 80
+)
+
+// Checker validates that ingress controllers are processing traffic
+type Checker struct {
+	Errors       []string
+	Selector     string
+	SyncTimeout  time.Duration
+	client       *kubernetes.Clientset
+	pollInterval time.Duration
+	// createIngress, deleteIngress and request are replaced in tests to
+	// mock the canary Ingress lifecycle and HTTP requests
+	createIngress func(ingress *v1beta1.Ingress) error
+	deleteIngress func(name string) error
+	request       func(ip string, host string, path string) (int, error)
+	interval      time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the ingress controller pods matching the
+// label selector
+func New(selector string) *Checker {
+	ic := &Checker{
+		Errors:       []string{},
+		Selector:     selector,
+		SyncTimeout:  time.Minute * 1,
+		pollInterval: time.Second * 5,
+		request:      request,
+	}
+	ic.createIngress = ic.apiCreateIngress
+	ic.deleteIngress = ic.apiDeleteIngress
+	return ic
+}
+
+// Name returns the name of this checker
+func (ic *Checker) Name() string {
+	return "IngressControllerHealthChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (ic *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (ic *Checker) Interval() time.Duration {
+	if ic.interval > 0 {
+		return ic.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (ic *Checker) SetInterval(d time.Duration) {
+	ic.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Ingress controller
+// pods are found in every namespace, and the canary Ingress is managed in
+// the Kuberhealthy namespace.
+func (ic *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		},
+		namespace: {
+			{APIGroups: []string{"extensions"}, Resources: []string{"ingresses"}, Verbs: []string{"create", "delete"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (ic *Checker) Timeout() time.Duration {
+	return time.Minute * 3
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (ic *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (ic *Checker) CurrentStatus() (bool, []string) {
+	if len(ic.Errors) > 0 {
+		return false, ic.Errors
+	}
+	return true, ic.Errors
+}
+
+// clearErrors clears all errors
+func (ic *Checker) clearErrors() {
+	ic.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (ic *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	ic.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := ic.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(ic.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + ic.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(ic.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + ic.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + ic.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks finds the ingress controller pods, sets an error for every pod
+// that is not running and ready, and then sends a canary request through
+// every ready pod
+func (ic *Checker) doChecks() error {
+
+	pods, err := ic.client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{
+		LabelSelector: ic.Selector,
+	})
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 {
+		return errors.New("No ingress controller pods found matching selector " + ic.Selector)
+	}
+
+	ingressErrors, ready := evaluatePods(pods.Items)
+	if len(ready) > 0 {
+		canaryErrors, err := ic.probeControllers(ready)
+		if err != nil {
+			return err
+		}
+		ingressErrors = append(ingressErrors, canaryErrors...)
+	}
+
+	if len(ingressErrors) > 0 {
+		for _, e := range ingressErrors {
+			khlog.ForCheck(ic.Name()).Warningln(e)
+		}
+		ic.Errors = ingressErrors
+		return nil
+	}
+
+	ic.clearErrors()
+	return nil
+}
+
+// probeControllers creates the canary Ingress, requests it through each
+// ingress controller pod until the request is routed or the sync timeout is
+// reached, and then deletes the canary Ingress
+func (ic *Checker) probeControllers(pods []apiv1.Pod) ([]string, error) {
+	err := ic.createIngress(canaryIngress())
+	if err != nil {
+		return nil, errors.New("Error creating canary Ingress: " + err.Error())
+	}
+	defer func() {
+		err := ic.deleteIngress(canaryName)
+		if err != nil {
+			khlog.ForCheck(ic.Name()).Errorln("error deleting canary Ingress:", err)
+		}
+	}()
+
+	var canaryErrors []string
+	deadline := time.Now().Add(ic.SyncTimeout)
+	for _, p := range pods {
+		var status int
+		var err error
+		for {
+			status, err = ic.request(p.Status.PodIP, canaryHost, canaryPath)
+			if (err == nil && status == http.StatusOK) || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(ic.pollInterval)
+		}
+
+		switch {
+		case err != nil:
+			canaryErrors = append(canaryErrors, "Ingress controller pod "+p.Namespace+"/"+p.Name+" did not route the canary request: "+err.Error())
+		case status != http.StatusOK:
+			canaryErrors = append(canaryErrors, "Ingress controller pod "+p.Namespace+"/"+p.Name+" responded to the canary request with status "+strconv.Itoa(status))
+		}
+	}
+	return canaryErrors, nil
+}
+
+// apiCreateIngress creates an Ingress in the Kuberhealthy namespace,
+// replacing one left behind by an interrupted run
+func (ic *Checker) apiCreateIngress(ingress *v1beta1.Ingress) error {
+	ingresses := ic.client.ExtensionsV1beta1().Ingresses(namespace)
+	_, err := ingresses.Create(ingress)
+	if k8sErrors.IsAlreadyExists(err) {
+		khlog.ForCheck(ic.Name()).Infoln("removing canary Ingress left behind by a previous run")
+		err = ingresses.Delete(ingress.Name, &metav1.DeleteOptions{})
+		if err != nil {
+			return err
+		}
+		_, err = ingresses.Create(ingress)
+	}
+	return err
+}
+
+// apiDeleteIngress deletes an Ingress from the Kuberhealthy namespace
+func (ic *Checker) apiDeleteIngress(name string) error {
+	return ic.client.ExtensionsV1beta1().Ingresses(namespace).Delete(name, &metav1.DeleteOptions{})
+}
+
+// canaryIngress returns the canary Ingress that routes the canary host to the
+// Kuberhealthy service
+func canaryIngress() *v1beta1.Ingress {
+	return &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   canaryName,
+			Labels: map[string]string{"app": "kuberhealthy", "source": "kuberhealthy"},
+		},
+		Spec: v1beta1.IngressSpec{
+			Rules: []v1beta1.IngressRule{
+				{
+					Host: canaryHost,
+					IngressRuleValue: v1beta1.IngressRuleValue{
+						HTTP: &v1beta1.HTTPIngressRuleValue{
+							Paths: []v1beta1.HTTPIngressPath{
+								{
+									Path: canaryPath,
+									Backend: v1beta1.IngressBackend{
+										ServiceName: backendService,
+										ServicePort: intstr.FromInt(backendPort),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// request makes an HTTP request for the path to port 80 of the ip with the
+// supplied Host header and returns the response status code
+func request(ip string, host string, path string) (int, error) {
+	req, err := http.NewRequest(http.MethodGet, "http://"+net.JoinHostPort(ip, "80")+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Host = host
+
+	client := http.Client{Timeout: time.Second * 10}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// evaluatePods returns an error for every ingress controller pod that is not
+// running and ready, along with the pods that are
+func evaluatePods(pods []apiv1.Pod) ([]string, []apiv1.Pod) {
+	var podErrors []string
+	var ready []apiv1.Pod
+
+	for _, p := range pods {
+		if p.Status.Phase != apiv1.PodRunning {
+			podErrors = append(podErrors, "Ingress controller pod "+p.Namespace+"/"+p.Name+" is in phase "+string(p.Status.Phase))
+			continue
+		}
+		if !podReady(p) {
+			podErrors = append(podErrors, "Ingress controller pod "+p.Namespace+"/"+p.Name+" is running but not ready")
+			continue
+		}
+		ready = append(ready, p)
+	}
+	return podErrors, ready
+}
+
+// podReady returns true if the pod has a true Ready condition
+func podReady(pod apiv1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == apiv1.PodReady {
+			return c.Status == apiv1.ConditionTrue
+		}
+	}
+	return false
+}
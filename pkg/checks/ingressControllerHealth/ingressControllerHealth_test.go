@@ -0,0 +1,122 @@
+package ingressControllerHealth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// makePod returns an ingress controller pod with the supplied phase and
+// readiness
+func makePod(name string, ip string, phase apiv1.PodPhase, ready bool) apiv1.Pod {
+	readyStatus := apiv1.ConditionFalse
+	if ready {
+		readyStatus = apiv1.ConditionTrue
+	}
+	return apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ingress-nginx", Name: name},
+		Status: apiv1.PodStatus{
+			Phase:      phase,
+			PodIP:      ip,
+			Conditions: []apiv1.PodCondition{{Type: apiv1.PodReady, Status: readyStatus}},
+		},
+	}
+}
+
+func TestEvaluatePods(t *testing.T) {
+	var tests = []struct {
+		description    string
+		pod            apiv1.Pod
+		expectedErrors int
+		expectedReady  int
+	}{
+		{"running and ready", makePod("controller-a", "10.244.1.5", apiv1.PodRunning, true), 0, 1},
+		{"running but not ready", makePod("controller-a", "10.244.1.5", apiv1.PodRunning, false), 1, 0},
+		{"pending", makePod("controller-a", "", apiv1.PodPending, false), 1, 0},
+		{"failed", makePod("controller-a", "", apiv1.PodFailed, false), 1, 0},
+	}
+
+	for _, test := range tests {
+		podErrors, ready := evaluatePods([]apiv1.Pod{test.pod})
+		if len(podErrors) != test.expectedErrors || len(ready) != test.expectedReady {
+			t.Fatal("Test", test.description, "expected", test.expectedErrors, "errors and", test.expectedReady, "ready pods but got", podErrors, ready)
+		}
+		t.Log(test.description, podErrors)
+	}
+}
+
+func TestProbeControllers(t *testing.T) {
+	var tests = []struct {
+		description string
+		responses   map[string][]int
+		expected    int
+	}{
+		{"all routed", map[string][]int{"10.244.1.5": {200}, "10.244.2.5": {200}}, 0},
+		{"routed once synced", map[string][]int{"10.244.1.5": {404, 503, 200}, "10.244.2.5": {200}}, 0},
+		{"one never routed", map[string][]int{"10.244.1.5": {200}, "10.244.2.5": {404}}, 1},
+		{"one unreachable", map[string][]int{"10.244.1.5": {200}}, 1},
+	}
+
+	for _, test := range tests {
+		var created, deleted []string
+		checker := New("app.kubernetes.io/name=ingress-nginx")
+		checker.SyncTimeout = time.Millisecond * 50
+		checker.pollInterval = time.Millisecond
+		checker.createIngress = func(ingress *v1beta1.Ingress) error {
+			created = append(created, ingress.Name)
+			return nil
+		}
+		checker.deleteIngress = func(name string) error {
+			deleted = append(deleted, name)
+			return nil
+		}
+		checker.request = func(ip string, host string, path string) (int, error) {
+			if host != canaryHost || path != canaryPath {
+				t.Fatal("Test", test.description, "requested unexpected host and path", host, path)
+			}
+			responses, ok := test.responses[ip]
+			if !ok {
+				return 0, errors.New("connection refused")
+			}
+			status := responses[0]
+			if len(responses) > 1 {
+				test.responses[ip] = responses[1:]
+			}
+			return status, nil
+		}
+
+		pods := []apiv1.Pod{
+			makePod("controller-a", "10.244.1.5", apiv1.PodRunning, true),
+			makePod("controller-b", "10.244.2.5", apiv1.PodRunning, true),
+		}
+		canaryErrors, err := checker.probeControllers(pods)
+		if err != nil {
+			t.Fatal("Test", test.description, "returned an error:", err)
+		}
+		if len(canaryErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", canaryErrors)
+		}
+		if len(created) != 1 || len(deleted) != 1 || created[0] != deleted[0] {
+			t.Fatal("Test", test.description, "expected the canary Ingress to be created and deleted once but created", created, "and deleted", deleted)
+		}
+		t.Log(test.description, canaryErrors)
+	}
+
+	// a canary Ingress that can not be created is a check failure
+	checker := New("app.kubernetes.io/name=ingress-nginx")
+	checker.createIngress = func(ingress *v1beta1.Ingress) error {
+		return errors.New("forbidden")
+	}
+	checker.deleteIngress = func(name string) error {
+		t.Fatal("Expected no delete when the canary Ingress was not created")
+		return nil
+	}
+	_, err := checker.probeControllers([]apiv1.Pod{makePod("controller-a", "10.244.1.5", apiv1.PodRunning, true)})
+	if err == nil {
+		t.Fatal("Expected an error when the canary Ingress can not be created")
+	}
+}
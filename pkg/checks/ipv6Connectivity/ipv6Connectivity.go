@@ -0,0 +1,318 @@
+// Package ipv6Connectivity implements a checker that ensures IPv6 pod
+// networking works on dual-stack clusters.  When nodes have IPv6 addresses, a
+// test server pod must receive an IPv6 address and a client pod on another
+// node must be able to connect to it over IPv6.
+package ipv6Connectivity // import "github.com/Comcast/kuberhealthy/pkg/checks/ipv6Connectivity"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+const (
+	// serverName is the base name of the server pod
+	serverName = "kuberhealthy-ipv6-server"
+	// serverPort is the port the server pod listens on
+	serverPort = 8080
+)
+
+// podStatus is the subset of a pod's status used to find its addresses.  It
+// is decoded by hand because the client API predates the podIPs field.
+type podStatus struct {
+	Phase  apiv1.PodPhase `json:"phase"`
+	PodIP  string         `json:"podIP"`
+	PodIPs []struct {
+		IP string `json:"ip"`
+	} `json:"podIPs"`
+}
+
+// Checker validates IPv6 pod addressing and connectivity
+type Checker struct {
+	Errors []string
+	Image  string
+	client *kubernetes.Clientset
+	// runPod is replaced in tests to inject client pod output
+	runPod   func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (string, error)
+	interval time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker
+func New() *Checker {
+	return &Checker{
+		Errors: []string{},
+		Image:  "busybox:1.30",
+		runPod: podRunner.RunPod,
+	}
+}
+
+// Name returns the name of this checker
+func (ic *Checker) Name() string {
+	return "IPv6ConnectivityChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (ic *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (ic *Checker) Interval() time.Duration {
+	if ic.interval > 0 {
+		return ic.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (ic *Checker) SetInterval(d time.Duration) {
+	ic.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Nodes are listed to
+// find IPv6 nodes for the server and client pods, which run in the
+// Kuberhealthy namespace.
+func (ic *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		namespace: podRunner.RunPodRBACRules(),
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (ic *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (ic *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (ic *Checker) CurrentStatus() (bool, []string) {
+	if len(ic.Errors) > 0 {
+		return false, ic.Errors
+	}
+	return true, ic.Errors
+}
+
+// clearErrors clears all errors
+func (ic *Checker) clearErrors() {
+	ic.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (ic *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	ic.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := ic.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(ic.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + ic.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(ic.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + ic.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + ic.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks skips clusters without IPv6 node addresses and otherwise starts a
+// server pod, verifies it has an IPv6 address, and connects to it over IPv6
+// from a client pod on another node
+func (ic *Checker) doChecks() error {
+
+	nodes, err := ic.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	ipv6Nodes := ipv6NodeNames(nodes.Items)
+	if len(ipv6Nodes) == 0 {
+		khlog.ForCheck(ic.Name()).Debugln("no nodes have IPv6 addresses. Skipping IPv6 connectivity check.")
+		ic.clearErrors()
+		return nil
+	}
+
+	server, err := ic.startServer(ipv6Nodes[0])
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := ic.client.CoreV1().Pods(namespace).Delete(server, &metav1.DeleteOptions{})
+		if err != nil {
+			khlog.ForCheck(ic.Name()).Errorln("error deleting server pod", server+":", err)
+		}
+	}()
+
+	status, err := ic.waitForServer(server)
+	if err != nil {
+		return err
+	}
+
+	// connect from another node when there is one
+	clientNode := ipv6Nodes[0]
+	if len(ipv6Nodes) > 1 {
+		clientNode = ipv6Nodes[1]
+	}
+	ipv6Errors, err := ic.evaluateServer(status, ipv6Nodes[0], clientNode)
+	if err != nil {
+		return err
+	}
+
+	if len(ipv6Errors) > 0 {
+		for _, e := range ipv6Errors {
+			khlog.ForCheck(ic.Name()).Warningln(e)
+		}
+		ic.Errors = ipv6Errors
+		return nil
+	}
+
+	ic.clearErrors()
+	return nil
+}
+
+// evaluateServer returns an error if the server pod has no IPv6 address or
+// a client pod on clientNode can not connect to it over IPv6
+func (ic *Checker) evaluateServer(status podStatus, serverNode string, clientNode string) ([]string, error) {
+	ip := podIPv6(status)
+	if len(ip) == 0 {
+		return []string{"IPv6 is configured on cluster nodes but test pod on node " + serverNode + " did not receive an IPv6 address"}, nil
+	}
+
+	script := podRunner.Script{
+		Name:     "kuberhealthy-ipv6-client",
+		Image:    ic.Image,
+		Script:   "if nc -z -w 5 " + ip + " " + strconv.Itoa(serverPort) + "; then echo connected; else echo failed; fi",
+		NodeName: clientNode,
+	}
+	output, err := ic.runPod(ic.client, namespace, script, time.Minute*2)
+	if err != nil {
+		return nil, errors.New("Error running IPv6 client pod: " + err.Error())
+	}
+	if strings.TrimSpace(output) != "connected" {
+		return []string{"IPv6 TCP connection from node " + clientNode + " to test pod " + ip + " on node " + serverNode + " failed"}, nil
+	}
+	return nil, nil
+}
+
+// startServer creates a pod on the node that accepts TCP connections and
+// returns its name
+func (ic *Checker) startServer(nodeName string) (string, error) {
+	terminationGracePeriod := int64(1)
+	pod := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   serverName + "-" + strconv.Itoa(int(time.Now().Unix())),
+			Labels: map[string]string{"app": serverName, "source": "kuberhealthy"},
+		},
+		Spec: apiv1.PodSpec{
+			NodeName:                      nodeName,
+			TerminationGracePeriodSeconds: &terminationGracePeriod,
+			Containers: []apiv1.Container{
+				{
+					Name:    "server",
+					Image:   ic.Image,
+					Command: []string{"httpd", "-f", "-p", strconv.Itoa(serverPort)},
+				},
+			},
+		},
+	}
+	created, err := ic.client.CoreV1().Pods(namespace).Create(pod)
+	if err != nil {
+		return "", errors.New("Error creating IPv6 server pod: " + err.Error())
+	}
+	return created.Name, nil
+}
+
+// waitForServer waits for the server pod to run and returns its status
+func (ic *Checker) waitForServer(name string) (podStatus, error) {
+	var status podStatus
+	deadline := time.Now().Add(time.Minute * 2)
+	for time.Now().Before(deadline) {
+		b, err := ic.client.CoreV1().RESTClient().Get().Namespace(namespace).Resource("pods").Name(name).DoRaw()
+		if err != nil {
+			return status, err
+		}
+		var pod struct {
+			Status podStatus `json:"status"`
+		}
+		err = json.Unmarshal(b, &pod)
+		if err != nil {
+			return status, errors.New("Error decoding IPv6 server pod: " + err.Error())
+		}
+		status = pod.Status
+		if status.Phase == apiv1.PodRunning {
+			return status, nil
+		}
+		time.Sleep(time.Second * 5)
+	}
+	return status, errors.New("IPv6 server pod " + name + " did not start running in time")
+}
+
+// ipv6NodeNames returns the names of nodes with an IPv6 internal address
+func ipv6NodeNames(nodes []apiv1.Node) []string {
+	var names []string
+	for _, n := range nodes {
+		if n.Spec.Unschedulable {
+			continue
+		}
+		for _, a := range n.Status.Addresses {
+			if a.Type == apiv1.NodeInternalIP && isIPv6(a.Address) {
+				names = append(names, n.Name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// podIPv6 returns the IPv6 address of a pod, or an empty string when it has
+// none.  Clusters without dual-stack support only set podIP.
+func podIPv6(status podStatus) string {
+	ips := []string{status.PodIP}
+	for _, ip := range status.PodIPs {
+		ips = append(ips, ip.IP)
+	}
+	for _, ip := range ips {
+		if isIPv6(ip) {
+			return ip
+		}
+	}
+	return ""
+}
+
+// isIPv6 returns true if s is an IPv6 address
+func isIPv6(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() == nil
+}
@@ -0,0 +1,101 @@
+package ipv6Connectivity
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// decodeStatus decodes a pod status from JSON
+func decodeStatus(t *testing.T, s string) podStatus {
+	var status podStatus
+	err := json.Unmarshal([]byte(s), &status)
+	if err != nil {
+		t.Fatal("Unable to decode pod status:", err)
+	}
+	return status
+}
+
+const (
+	ipv4Only  = `{"phase":"Running","podIP":"10.244.1.5","podIPs":[{"ip":"10.244.1.5"}]}`
+	dualStack = `{"phase":"Running","podIP":"10.244.1.5","podIPs":[{"ip":"10.244.1.5"},{"ip":"fd00:10:244:1::5"}]}`
+	ipv6Only  = `{"phase":"Running","podIP":"fd00:10:244:1::5"}`
+)
+
+func TestPodIPv6(t *testing.T) {
+	var tests = []struct {
+		description string
+		status      string
+		expected    string
+	}{
+		{"ipv4 only", ipv4Only, ""},
+		{"dual stack", dualStack, "fd00:10:244:1::5"},
+		{"ipv6 only", ipv6Only, "fd00:10:244:1::5"},
+		{"no addresses", `{"phase":"Pending"}`, ""},
+	}
+
+	for _, test := range tests {
+		if ip := podIPv6(decodeStatus(t, test.status)); ip != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "but got", ip)
+		}
+	}
+}
+
+func TestEvaluateServer(t *testing.T) {
+	var tests = []struct {
+		description string
+		status      string
+		output      string
+		expected    int
+	}{
+		{"dual stack connected", dualStack, "connected\n", 0},
+		{"dual stack connection failed", dualStack, "failed\n", 1},
+		{"ipv4 only", ipv4Only, "", 1},
+	}
+
+	for _, test := range tests {
+		checker := New()
+		checker.runPod = func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (string, error) {
+			if script.NodeName != "node-b" || !strings.Contains(script.Script, "fd00:10:244:1::5") {
+				t.Fatal("Test", test.description, "ran an unexpected client pod:", script)
+			}
+			return test.output, nil
+		}
+
+		ipv6Errors, err := checker.evaluateServer(decodeStatus(t, test.status), "node-a", "node-b")
+		if err != nil {
+			t.Fatal("Test", test.description, "returned an error:", err)
+		}
+		if len(ipv6Errors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", ipv6Errors)
+		}
+		t.Log(test.description, ipv6Errors)
+	}
+}
+
+func TestIPv6NodeNames(t *testing.T) {
+	makeNode := func(name string, unschedulable bool, addresses ...string) apiv1.Node {
+		node := apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: apiv1.NodeSpec{Unschedulable: unschedulable}}
+		for _, a := range addresses {
+			node.Status.Addresses = append(node.Status.Addresses, apiv1.NodeAddress{Type: apiv1.NodeInternalIP, Address: a})
+		}
+		return node
+	}
+
+	nodes := []apiv1.Node{
+		makeNode("ipv4", false, "192.168.1.10"),
+		makeNode("dual-stack", false, "192.168.1.11", "fd00::11"),
+		makeNode("cordoned", true, "192.168.1.12", "fd00::12"),
+		makeNode("ipv6", false, "fd00::13"),
+	}
+	names := ipv6NodeNames(nodes)
+	if len(names) != 2 || names[0] != "dual-stack" || names[1] != "ipv6" {
+		t.Fatal("Unexpected IPv6 nodes:", names)
+	}
+}
@@ -0,0 +1,225 @@
+// Package kernelModules implements a checker that ensures required kernel
+// modules are loaded on every node.  Missing modules such as br_netfilter or
+// ip_vs cause CNI, kube-proxy or storage failures that are hard to trace back
+// to the node.  A DaemonSet pod is run on each node to read /proc/modules and
+// the list of modules built into the kernel.
+package kernelModules // import "github.com/Comcast/kuberhealthy/pkg/checks/kernelModules"
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// builtinMarker separates /proc/modules from modules.builtin in the pod
+// output
+const builtinMarker = "=== builtin"
+
+// Checker validates that required kernel modules are loaded on all nodes
+type Checker struct {
+	Errors          []string
+	RequiredModules []string
+	Image           string
+	client          *kubernetes.Clientset
+	// runOnNodes is replaced in tests to inject pod output
+	runOnNodes func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (map[string]string, error)
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that requires the supplied kernel modules
+func New(requiredModules []string) *Checker {
+	return &Checker{
+		Errors:          []string{},
+		RequiredModules: requiredModules,
+		Image:           "busybox:1.30",
+		runOnNodes:      podRunner.RunOnNodes,
+	}
+}
+
+// Name returns the name of this checker
+func (kmc *Checker) Name() string {
+	return "KernelModulesChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (kmc *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (kmc *Checker) Interval() time.Duration {
+	if kmc.interval > 0 {
+		return kmc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (kmc *Checker) SetInterval(d time.Duration) {
+	kmc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to run its DaemonSet in
+// the Kuberhealthy namespace
+func (kmc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		namespace: podRunner.RunOnNodesRBACRules(),
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (kmc *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (kmc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (kmc *Checker) CurrentStatus() (bool, []string) {
+	if len(kmc.Errors) > 0 {
+		return false, kmc.Errors
+	}
+	return true, kmc.Errors
+}
+
+// clearErrors clears all errors
+func (kmc *Checker) clearErrors() {
+	kmc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (kmc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	kmc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := kmc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(kmc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + kmc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(kmc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + kmc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + kmc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks reads the kernel modules of every node and sets an error for
+// every node missing a required module
+func (kmc *Checker) doChecks() error {
+
+	// the pod shares the host's kernel, so uname reports the host kernel
+	script := podRunner.Script{
+		Name:      "kernel-modules",
+		Image:     kmc.Image,
+		Script:    "cat /proc/modules; echo '" + builtinMarker + "'; cat /host/lib/modules/$(uname -r)/modules.builtin 2>/dev/null; true",
+		HostPaths: map[string]string{"/lib/modules": "/host/lib/modules"},
+	}
+
+	// leave time to clean up the DaemonSet before the check times out
+	output, err := kmc.runOnNodes(kmc.client, namespace, script, kmc.Timeout()-time.Minute)
+	if err != nil && len(output) == 0 {
+		return err
+	}
+
+	var moduleErrors []string
+	if err != nil {
+		moduleErrors = append(moduleErrors, err.Error())
+	}
+	moduleErrors = append(moduleErrors, evaluateNodes(output, kmc.RequiredModules)...)
+
+	if len(moduleErrors) > 0 {
+		for _, e := range moduleErrors {
+			khlog.ForCheck(kmc.Name()).Warningln(e)
+		}
+		kmc.Errors = moduleErrors
+		return nil
+	}
+
+	kmc.clearErrors()
+	return nil
+}
+
+// evaluateNodes returns an error for every required module that is neither
+// loaded nor built into the kernel of a node
+func evaluateNodes(output map[string]string, requiredModules []string) []string {
+	var moduleErrors []string
+
+	var nodes []string
+	for node := range output {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	for _, node := range nodes {
+		modules := parseModules(output[node])
+		for _, m := range requiredModules {
+			if !modules[normalizeModule(m)] {
+				moduleErrors = append(moduleErrors, "Node "+node+" is missing required kernel module "+m)
+			}
+		}
+	}
+	return moduleErrors
+}
+
+// parseModules returns the set of modules available in the pod output,
+// which is the content of /proc/modules followed by the builtinMarker and the
+// content of modules.builtin
+func parseModules(output string) map[string]bool {
+	modules := make(map[string]bool)
+
+	var builtin bool
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == builtinMarker {
+			builtin = true
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if builtin {
+			// modules.builtin lists paths such as kernel/net/netfilter/nf_conntrack.ko
+			modules[normalizeModule(strings.TrimSuffix(path.Base(fields[0]), ".ko"))] = true
+			continue
+		}
+		// /proc/modules lists the module name first
+		modules[normalizeModule(fields[0])] = true
+	}
+	return modules
+}
+
+// normalizeModule converts a module name to the form the kernel uses, which
+// treats dashes and underscores the same
+func normalizeModule(name string) string {
+	return strings.Replace(name, "-", "_", -1)
+}
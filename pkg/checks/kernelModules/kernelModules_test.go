@@ -0,0 +1,79 @@
+package kernelModules
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	"k8s.io/client-go/kubernetes"
+)
+
+const allLoaded = "br_netfilter 24576 0 - Live 0x0000000000000000\n" +
+	"bridge 155648 1 br_netfilter, Live 0x0000000000000000\n" +
+	"ip_vs 151552 0 - Live 0x0000000000000000\n" +
+	"nf_conntrack 135168 1 ip_vs, Live 0x0000000000000000\n" +
+	builtinMarker + "\n"
+
+const noIPVS = "br_netfilter 24576 0 - Live 0x0000000000000000\n" +
+	"nf_conntrack 135168 0 - Live 0x0000000000000000\n" +
+	builtinMarker + "\n"
+
+const builtinConntrack = "br_netfilter 24576 0 - Live 0x0000000000000000\n" +
+	"ip_vs 151552 0 - Live 0x0000000000000000\n" +
+	builtinMarker + "\n" +
+	"kernel/net/netfilter/nf_conntrack.ko\n" +
+	"kernel/drivers/md/dm-mod.ko\n"
+
+var required = []string{"br_netfilter", "ip_vs", "nf_conntrack"}
+
+func TestParseModules(t *testing.T) {
+	modules := parseModules(builtinConntrack)
+	for _, m := range []string{"br_netfilter", "ip_vs", "nf_conntrack", "dm_mod"} {
+		if !modules[m] {
+			t.Fatal("Expected module", m, "in", modules)
+		}
+	}
+	if modules["bridge"] {
+		t.Fatal("Unexpected module bridge in", modules)
+	}
+}
+
+func TestEvaluateNodes(t *testing.T) {
+	var tests = []struct {
+		description string
+		output      string
+		required    []string
+		expected    int
+	}{
+		{"all loaded", allLoaded, required, 0},
+		{"missing ip_vs", noIPVS, required, 1},
+		{"builtin module", builtinConntrack, required, 0},
+		{"dash in module name", builtinConntrack, []string{"dm-mod"}, 0},
+		{"nothing loaded", "", required, 3},
+	}
+
+	for _, test := range tests {
+		moduleErrors := evaluateNodes(map[string]string{"node-a": test.output}, test.required)
+		if len(moduleErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", moduleErrors)
+		}
+		t.Log(test.description, moduleErrors)
+	}
+}
+
+func TestDoChecks(t *testing.T) {
+	checker := New(required)
+	checker.runOnNodes = func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (map[string]string, error) {
+		return map[string]string{"node-a": allLoaded, "node-b": noIPVS}, errors.New("node-c pod did not complete")
+	}
+
+	err := checker.doChecks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checker.Errors) != 2 {
+		t.Fatal("Expected errors for the incomplete pod and node-b but got", checker.Errors)
+	}
+	t.Log(checker.Errors)
+}
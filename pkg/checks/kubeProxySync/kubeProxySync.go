@@ -0,0 +1,257 @@
+// Package kubeProxySync implements a checker that ensures kube-proxy is
+// synchronizing its proxy rules quickly.  Slow iptables rule synchronization
+// delays service routing changes.  A DaemonSet pod on each node reads
+// kube-proxy's sync and network programming latency histograms and their p99
+// latency is compared against a threshold.
+package kubeProxySync // import "github.com/Comcast/kuberhealthy/pkg/checks/kubeProxySync"
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/metrics"
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	"github.com/Comcast/kuberhealthy/pkg/promParser"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+const (
+	// syncMetric is the kube-proxy histogram of proxy rule sync latency
+	syncMetric = "kubeproxy_sync_proxy_rules_duration_seconds"
+	// programmingMetric is the kube-proxy histogram of the latency between a
+	// service or endpoint change and its proxy rules being programmed
+	programmingMetric = "kubeproxy_network_programming_duration_seconds"
+)
+
+// metricsScript fetches the sync and network programming buckets from the
+// kube-proxy on the node the pod runs on
+const metricsScript = `curl -s http://127.0.0.1:10249/metrics | grep -e '^` + syncMetric + `_bucket' -e '^` + programmingMetric + `_bucket'`
+
+// Checker validates that kube-proxy synchronizes proxy rules quickly
+type Checker struct {
+	Errors        []string
+	SyncThreshold time.Duration
+	Image         string
+	client        *kubernetes.Clientset
+	metricClient  metrics.Client
+	// previous holds the histograms of the last run by node and metric so
+	// that each run evaluates only new syncs
+	previous map[string]map[string]promParser.Histogram
+	// runOnNodes is replaced in tests to inject pod output
+	runOnNodes func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (map[string]string, error)
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reports nodes where the p99 kube-proxy sync
+// or network programming latency exceeds syncThreshold.  Sync durations are
+// pushed to the metric client when it is not nil.
+func New(syncThreshold time.Duration, metricClient metrics.Client) *Checker {
+	return &Checker{
+		Errors:        []string{},
+		SyncThreshold: syncThreshold,
+		Image:         "curlimages/curl:7.66.0",
+		metricClient:  metricClient,
+		previous:      make(map[string]map[string]promParser.Histogram),
+		runOnNodes:    podRunner.RunOnNodes,
+	}
+}
+
+// Name returns the name of this checker
+func (kpc *Checker) Name() string {
+	return "KubeProxySyncChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (kpc *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (kpc *Checker) Interval() time.Duration {
+	if kpc.interval > 0 {
+		return kpc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (kpc *Checker) SetInterval(d time.Duration) {
+	kpc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  The kube-proxy
+// metrics are read from the host network of each node and need no rules of
+// their own.
+func (kpc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		namespace: podRunner.RunOnNodesRBACRules(),
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (kpc *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (kpc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (kpc *Checker) CurrentStatus() (bool, []string) {
+	if len(kpc.Errors) > 0 {
+		return false, kpc.Errors
+	}
+	return true, kpc.Errors
+}
+
+// clearErrors clears all errors
+func (kpc *Checker) clearErrors() {
+	kpc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (kpc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	kpc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := kpc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(kpc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + kpc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(kpc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + kpc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + kpc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks reads the kube-proxy histograms of every node and sets an error
+// for every node with slow proxy rule syncs
+func (kpc *Checker) doChecks() error {
+
+	script := podRunner.Script{
+		Name:        "kube-proxy-sync",
+		Image:       kpc.Image,
+		Script:      metricsScript,
+		HostNetwork: true,
+	}
+
+	// leave time to clean up the DaemonSet before the check times out
+	output, err := kpc.runOnNodes(kpc.client, namespace, script, kpc.Timeout()-time.Minute)
+	if err != nil && len(output) == 0 {
+		return err
+	}
+
+	var syncErrors []string
+	if err != nil {
+		syncErrors = append(syncErrors, err.Error())
+	}
+	syncErrors = append(syncErrors, kpc.evaluateNodes(output)...)
+
+	if len(syncErrors) > 0 {
+		for _, e := range syncErrors {
+			khlog.ForCheck(kpc.Name()).Warningln(e)
+		}
+		kpc.Errors = syncErrors
+		return nil
+	}
+
+	kpc.clearErrors()
+	return nil
+}
+
+// evaluateNodes calculates the p99 sync and network programming latency of
+// each node since the previous run, pushes the sync durations to the metric
+// client, and returns an error for every latency above the threshold
+func (kpc *Checker) evaluateNodes(output map[string]string) []string {
+	var syncErrors []string
+
+	var nodes []string
+	for node := range output {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	for _, node := range nodes {
+		samples, err := promParser.Parse(strings.NewReader(output[node]))
+		if err != nil {
+			syncErrors = append(syncErrors, "Error parsing kube-proxy metrics of node "+node+": "+err.Error())
+			continue
+		}
+
+		histograms := make(map[string]promParser.Histogram)
+		for _, metric := range []string{syncMetric, programmingMetric} {
+			h, ok := promParser.ParseHistograms(promParser.Filter(samples, metric+"_bucket"), "")[""]
+			if !ok {
+				// network programming latency is only reported by newer
+				// kube-proxy versions
+				if metric == syncMetric {
+					syncErrors = append(syncErrors, "Node "+node+" kube-proxy did not report "+syncMetric)
+				}
+				continue
+			}
+			histograms[metric] = h
+
+			delta := h.Since(kpc.previous[node][metric])
+			if metric == syncMetric {
+				kpc.pushBuckets(node, delta)
+			}
+			p99 := delta.Quantile(0.99)
+			if math.IsNaN(p99) {
+				continue
+			}
+			if p99 > kpc.SyncThreshold.Seconds() {
+				syncErrors = append(syncErrors, "Node "+node+" kube-proxy "+metric+" p99 of "+strconv.FormatFloat(p99, 'f', 3, 64)+"s exceeds the threshold of "+kpc.SyncThreshold.String())
+			}
+		}
+		kpc.previous[node] = histograms
+	}
+	return syncErrors
+}
+
+// pushBuckets sends the buckets of a sync duration histogram to the metric
+// client, tagged with their upper bound
+func (kpc *Checker) pushBuckets(node string, h promParser.Histogram) {
+	if kpc.metricClient == nil {
+		return
+	}
+	for _, b := range h {
+		metric := metrics.Metric{
+			{kpc.Name() + "_sync_duration_seconds_bucket": b.Count},
+		}
+		tags := map[string]string{
+			"Node": node,
+			"Le":   strconv.FormatFloat(b.UpperBound, 'g', -1, 64),
+		}
+		err := kpc.metricClient.Push(metric, tags)
+		if err != nil {
+			khlog.ForCheck(kpc.Name()).Errorln("Error forwarding metrics", err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package kubeProxySync
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/metrics"
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	"k8s.io/client-go/kubernetes"
+)
+
+// fakeMetricClient records pushed metrics
+type fakeMetricClient struct {
+	pushed []map[string]string
+}
+
+// Push records the tags of each push
+func (f *fakeMetricClient) Push(points metrics.Metric, tags map[string]string) error {
+	f.pushed = append(f.pushed, tags)
+	return nil
+}
+
+// SetGlobalTags is implemented to satisfy metrics.Client but is not used
+func (f *fakeMetricClient) SetGlobalTags(tags map[string]string) {}
+
+// makeBuckets renders cumulative histogram buckets for a metric in
+// Prometheus text format
+func makeBuckets(metric string, counts map[string]float64) string {
+	var lines []string
+	for _, le := range []string{"0.1", "1", "5", "10", "+Inf"} {
+		lines = append(lines, fmt.Sprintf(`%s_bucket{le="%s"} %v`, metric, le, counts[le]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestDoChecks(t *testing.T) {
+	fastSync := makeBuckets(syncMetric, map[string]float64{"0.1": 90, "1": 100, "5": 100, "10": 100, "+Inf": 100})
+	slowSync := makeBuckets(syncMetric, map[string]float64{"0.1": 50, "1": 80, "5": 90, "10": 100, "+Inf": 100})
+	fastProgramming := makeBuckets(programmingMetric, map[string]float64{"0.1": 10, "1": 100, "5": 100, "10": 100, "+Inf": 100})
+	slowProgramming := makeBuckets(programmingMetric, map[string]float64{"0.1": 10, "1": 20, "5": 50, "10": 100, "+Inf": 100})
+
+	var tests = []struct {
+		description string
+		output      map[string]string
+		expected    int
+	}{
+		{"fast", map[string]string{"node-a": fastSync + "\n" + fastProgramming}, 0},
+		{"fast without network programming metric", map[string]string{"node-a": fastSync}, 0},
+		{"slow sync", map[string]string{"node-a": fastSync, "node-b": slowSync + "\n" + fastProgramming}, 1},
+		{"slow network programming", map[string]string{"node-a": fastSync + "\n" + slowProgramming}, 1},
+		{"missing sync metric", map[string]string{"node-a": fastProgramming}, 1},
+	}
+
+	for _, test := range tests {
+		metricClient := &fakeMetricClient{}
+		checker := New(time.Second*5, metricClient)
+		checker.runOnNodes = func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (map[string]string, error) {
+			if !script.HostNetwork {
+				t.Fatal("Test", test.description, "expected the metrics script to use the host network")
+			}
+			return test.output, nil
+		}
+
+		err := checker.doChecks()
+		if err != nil {
+			t.Fatal("Test", test.description, "returned an error:", err)
+		}
+		if len(checker.Errors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", checker.Errors)
+		}
+		t.Log(test.description, checker.Errors, metricClient.pushed)
+	}
+}
+
+func TestEvaluateNodesSincePreviousRun(t *testing.T) {
+	metricClient := &fakeMetricClient{}
+	checker := New(time.Second*5, metricClient)
+
+	// the first run has slow syncs from a previous incident
+	first := makeBuckets(syncMetric, map[string]float64{"0.1": 50, "1": 80, "5": 90, "10": 100, "+Inf": 100})
+	if syncErrors := checker.evaluateNodes(map[string]string{"node-a": first}); len(syncErrors) != 1 {
+		t.Fatal("Expected the first run to report slow syncs but got", syncErrors)
+	}
+	if len(metricClient.pushed) != 5 || metricClient.pushed[0]["Node"] != "node-a" || metricClient.pushed[0]["Le"] != "0.1" || metricClient.pushed[4]["Le"] != "+Inf" {
+		t.Fatal("Unexpected pushed metrics:", metricClient.pushed)
+	}
+
+	// only fast syncs happened since
+	second := makeBuckets(syncMetric, map[string]float64{"0.1": 150, "1": 180, "5": 190, "10": 200, "+Inf": 200})
+	if syncErrors := checker.evaluateNodes(map[string]string{"node-a": second}); len(syncErrors) != 0 {
+		t.Fatal("Expected only new syncs to be evaluated but got", syncErrors)
+	}
+}
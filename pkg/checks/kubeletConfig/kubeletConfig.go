@@ -0,0 +1,253 @@
+// Package kubeletConfig implements a checker that reads the running
+// configuration of every kubelet through the node proxy API and compares it
+// against expected values stored in a ConfigMap.
+package kubeletConfig // import "github.com/Comcast/kuberhealthy/pkg/checks/kubeletConfig"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// Checker validates that every kubelet is running the expected configuration
+type Checker struct {
+	Errors            []string
+	ExpectedConfigMap string
+	client            *kubernetes.Clientset
+	interval          time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that compares kubelet configuration against the
+// supplied ConfigMap, given as "name" in the kuberhealthy namespace or
+// "namespace/name".  Each key in the ConfigMap is the name of a
+// KubeletConfiguration field and each value is the expected setting.  Map
+// fields such as evictionHard are expressed as "key=value,key=value".
+func New(expectedConfigMap string) *Checker {
+	return &Checker{
+		Errors:            []string{},
+		ExpectedConfigMap: expectedConfigMap,
+	}
+}
+
+// Name returns the name of this checker
+func (kcc *Checker) Name() string {
+	return "KubeletConfigChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (kcc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (kcc *Checker) Interval() time.Duration {
+	if kcc.interval > 0 {
+		return kcc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (kcc *Checker) SetInterval(d time.Duration) {
+	kcc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Nodes and their proxy
+// are cluster scoped, and the expected configuration is read from its
+// ConfigMap.
+func (kcc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	configMapNamespace, configMapName := kcc.configMapName()
+	return map[string][]rbacv1.PolicyRule{
+		configMapNamespace: {
+			{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{configMapName}, Verbs: []string{"get"}},
+		},
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}},
+			{APIGroups: []string{""}, Resources: []string{"nodes/proxy"}, Verbs: []string{"get"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (kcc *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (kcc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (kcc *Checker) CurrentStatus() (bool, []string) {
+	if len(kcc.Errors) > 0 {
+		return false, kcc.Errors
+	}
+	return true, kcc.Errors
+}
+
+// clearErrors clears all errors
+func (kcc *Checker) clearErrors() {
+	kcc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (kcc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	kcc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := kcc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(kcc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + kcc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(kcc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + kcc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + kcc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// configMapName splits the kubelet configuration ConfigMap flag into a
+// namespace and name.  A bare name is looked up in the Kuberhealthy
+// namespace.
+func (kcc *Checker) configMapName() (string, string) {
+	if strings.Contains(kcc.ExpectedConfigMap, "/") {
+		parts := strings.SplitN(kcc.ExpectedConfigMap, "/", 2)
+		return parts[0], parts[1]
+	}
+	return namespace, kcc.ExpectedConfigMap
+}
+
+// doChecks compares the configuration of every node's kubelet against the
+// expected values and sets an error for each deviation
+func (kcc *Checker) doChecks() error {
+
+	configMapNamespace, configMapName := kcc.configMapName()
+
+	expected, err := kcc.client.CoreV1().ConfigMaps(configMapNamespace).Get(configMapName, metav1.GetOptions{})
+	if err != nil {
+		return errors.New("Error getting expected kubelet configuration " + configMapNamespace + "/" + configMapName + ": " + err.Error())
+	}
+
+	nodes, err := kcc.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	var configErrors []string
+	for _, n := range nodes.Items {
+		actual, err := fetchKubeletConfig(kcc.client, n.Name)
+		if err != nil {
+			khlog.ForCheck(kcc.Name()).Warningln("Error fetching kubelet configuration from node", n.Name+":", err)
+			configErrors = append(configErrors, "Error fetching kubelet configuration from node "+n.Name+": "+err.Error())
+			continue
+		}
+		for _, deviation := range compareConfig(expected.Data, actual) {
+			configErrors = append(configErrors, "Node "+n.Name+" "+deviation)
+		}
+	}
+
+	if len(configErrors) > 0 {
+		kcc.Errors = configErrors
+		return nil
+	}
+
+	kcc.clearErrors()
+	return nil
+}
+
+// fetchKubeletConfig reads the running KubeletConfiguration of a node from
+// the configz endpoint of its kubelet through the node proxy API
+func fetchKubeletConfig(client *kubernetes.Clientset, nodeName string) (map[string]interface{}, error) {
+	b, err := client.CoreV1().RESTClient().Get().AbsPath("/api/v1/nodes", nodeName, "proxy", "configz").DoRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	var configz struct {
+		KubeletConfig map[string]interface{} `json:"kubeletconfig"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	err = decoder.Decode(&configz)
+	if err != nil {
+		return nil, errors.New("Error decoding kubelet configuration: " + err.Error())
+	}
+	if configz.KubeletConfig == nil {
+		return nil, errors.New("configz response did not contain a kubeletconfig")
+	}
+	return configz.KubeletConfig, nil
+}
+
+// compareConfig returns a description of each expected setting that does not
+// match the actual kubelet configuration
+func compareConfig(expected map[string]string, actual map[string]interface{}) []string {
+	var deviations []string
+
+	var keys []string
+	for k := range expected {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		want := strings.TrimSpace(expected[key])
+		value, ok := actual[key]
+		if !ok {
+			deviations = append(deviations, "kubelet configuration is missing "+key+" (expected "+want+")")
+			continue
+		}
+
+		// map settings such as evictionHard are compared entry by entry
+		if actualMap, isMap := value.(map[string]interface{}); isMap {
+			for _, entry := range strings.Split(want, ",") {
+				kv := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+				if len(kv) != 2 {
+					deviations = append(deviations, "has an invalid expected value for "+key+": "+entry)
+					continue
+				}
+				got, found := actualMap[kv[0]]
+				if !found {
+					deviations = append(deviations, "has no "+key+" setting for "+kv[0]+" (expected "+kv[1]+")")
+					continue
+				}
+				if fmt.Sprint(got) != kv[1] {
+					deviations = append(deviations, "has "+key+" "+kv[0]+" set to "+fmt.Sprint(got)+" (expected "+kv[1]+")")
+				}
+			}
+			continue
+		}
+
+		if fmt.Sprint(value) != want {
+			deviations = append(deviations, "has "+key+" set to "+fmt.Sprint(value)+" (expected "+want+")")
+		}
+	}
+	return deviations
+}
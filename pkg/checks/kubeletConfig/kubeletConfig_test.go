@@ -0,0 +1,75 @@
+package kubeletConfig
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+var expected = map[string]string{
+	"eventRecordQPS":      "5",
+	"serializeImagePulls": "false",
+	"maxPods":             "110",
+	"evictionHard":        "memory.available=100Mi,nodefs.available=10%",
+}
+
+// kubelet configuration responses keyed by node proxy path
+var configzResponses = map[string]string{
+	"/api/v1/nodes/good/proxy/configz":       `{"kubeletconfig": {"eventRecordQPS": 5, "serializeImagePulls": false, "maxPods": 110, "evictionHard": {"memory.available": "100Mi", "nodefs.available": "10%"}}}`,
+	"/api/v1/nodes/deviant/proxy/configz":    `{"kubeletconfig": {"eventRecordQPS": 50, "serializeImagePulls": true, "maxPods": 110, "evictionHard": {"memory.available": "500Mi"}}}`,
+	"/api/v1/nodes/incomplete/proxy/configz": `{"kubeletconfig": {"eventRecordQPS": 5, "serializeImagePulls": false, "evictionHard": {"memory.available": "100Mi", "nodefs.available": "10%"}}}`,
+	"/api/v1/nodes/garbage/proxy/configz":    `{"something": "else"}`,
+}
+
+func TestKubeletConfig(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := configzResponses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(response))
+	}))
+	defer srv.Close()
+
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		node       string
+		deviations int
+		expectErr  bool
+	}{
+		{"good", 0, false},
+		// eventRecordQPS, serializeImagePulls, evictionHard memory and nodefs
+		{"deviant", 4, false},
+		{"incomplete", 1, false},
+		{"garbage", 0, true},
+		{"unreachable", 0, true},
+	}
+
+	for _, test := range tests {
+		actual, err := fetchKubeletConfig(client, test.node)
+		if test.expectErr {
+			if err == nil {
+				t.Fatal("Expected an error fetching configuration for node", test.node)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal("Error fetching configuration for node", test.node, err)
+		}
+
+		deviations := compareConfig(expected, actual)
+		if len(deviations) != test.deviations {
+			t.Fatal("Node", test.node, "expected", test.deviations, "deviations but got", deviations)
+		}
+		t.Log(test.node, deviations)
+	}
+}
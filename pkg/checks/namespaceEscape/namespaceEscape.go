@@ -0,0 +1,273 @@
+// Package namespaceEscape implements a checker that finds pods running
+// outside of the namespaces they are expected in.  In a multi-tenant cluster
+// a tenant's workload running in another namespace, such as kube-system,
+// escapes the isolation given to it by its own namespace.
+package namespaceEscape // import "github.com/Comcast/kuberhealthy/pkg/checks/namespaceEscape"
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// selectorSuffix and namespacesSuffix are appended to a rule name in the
+// ConfigMap to set the label selector of the rule and the namespaces pods
+// matching it are allowed in
+const (
+	selectorSuffix   = ".selector"
+	namespacesSuffix = ".namespaces"
+)
+
+// rule is the set of namespaces pods matching a label selector may run in
+type rule struct {
+	Name       string
+	Selector   labels.Selector
+	Namespaces []string
+}
+
+// Checker validates that pods only run in their expected namespaces
+type Checker struct {
+	Errors    []string
+	ConfigMap string
+	client    *kubernetes.Clientset
+	interval  time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reads the expected namespaces of pods from
+// the supplied ConfigMap, given as "name" in the kuberhealthy namespace or
+// "namespace/name".  Each rule is set with a key such as myapp.selector
+// holding a label selector and a key such as myapp.namespaces holding the
+// comma separated namespaces that matching pods may run in.
+func New(configMap string) *Checker {
+	return &Checker{
+		Errors:    []string{},
+		ConfigMap: configMap,
+	}
+}
+
+// Name returns the name of this checker
+func (nec *Checker) Name() string {
+	return "NamespaceEscapeChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (nec *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (nec *Checker) Interval() time.Duration {
+	if nec.interval > 0 {
+		return nec.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (nec *Checker) SetInterval(d time.Duration) {
+	nec.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to read its rules from
+// the ConfigMap and list the pods of every namespace
+func (nec *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	configMapNamespace, configMapName := nec.configMapName()
+	return map[string][]rbacv1.PolicyRule{
+		configMapNamespace: {
+			{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{configMapName}, Verbs: []string{"get"}},
+		},
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (nec *Checker) Timeout() time.Duration {
+	return time.Minute * 2
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (nec *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (nec *Checker) CurrentStatus() (bool, []string) {
+	if len(nec.Errors) > 0 {
+		return false, nec.Errors
+	}
+	return true, nec.Errors
+}
+
+// clearErrors clears all errors
+func (nec *Checker) clearErrors() {
+	nec.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (nec *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	nec.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := nec.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(nec.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + nec.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(nec.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + nec.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + nec.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// configMapName returns where the expected pod namespaces ConfigMap lives.
+// It defaults to the Kuberhealthy namespace when no namespace/ prefix is
+// given.
+func (nec *Checker) configMapName() (string, string) {
+	if strings.Contains(nec.ConfigMap, "/") {
+		parts := strings.SplitN(nec.ConfigMap, "/", 2)
+		return parts[0], parts[1]
+	}
+	return namespace, nec.ConfigMap
+}
+
+// doChecks loads the expected namespaces, lists pods in all namespaces, and
+// sets an error for every pod running outside of its expected namespaces
+func (nec *Checker) doChecks() error {
+
+	configMapNamespace, configMapName := nec.configMapName()
+	cm, err := nec.client.CoreV1().ConfigMaps(configMapNamespace).Get(configMapName, metav1.GetOptions{})
+	if err != nil {
+		return errors.New("Error getting expected pod namespaces " + configMapNamespace + "/" + configMapName + ": " + err.Error())
+	}
+	rules, err := parseRules(cm.Data)
+	if err != nil {
+		return err
+	}
+
+	pods, err := nec.client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing pods: " + err.Error())
+	}
+
+	escapeErrors := evaluatePods(rules, pods.Items)
+
+	if len(escapeErrors) > 0 {
+		for _, e := range escapeErrors {
+			khlog.ForCheck(nec.Name()).Warningln(e)
+		}
+		nec.Errors = escapeErrors
+		return nil
+	}
+
+	nec.clearErrors()
+	return nil
+}
+
+// parseRules builds expected namespace rules from ConfigMap data.  Every
+// rule must have both a selector and a list of namespaces.
+func parseRules(data map[string]string) ([]rule, error) {
+	var rules []rule
+
+	var names []string
+	for k := range data {
+		if strings.HasSuffix(k, selectorSuffix) {
+			names = append(names, strings.TrimSuffix(k, selectorSuffix))
+			continue
+		}
+		if !strings.HasSuffix(k, namespacesSuffix) {
+			return rules, errors.New("Unknown key " + k + " in the expected pod namespaces.  Keys must end in " + selectorSuffix + " or " + namespacesSuffix)
+		}
+		if _, ok := data[strings.TrimSuffix(k, namespacesSuffix)+selectorSuffix]; !ok {
+			return rules, errors.New("Expected pod namespaces " + k + " have no matching " + selectorSuffix + " key")
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		selector, err := labels.Parse(strings.TrimSpace(data[name+selectorSuffix]))
+		if err != nil {
+			return rules, errors.New("Invalid label selector for " + name + ": " + err.Error())
+		}
+		if selector.Empty() {
+			return rules, errors.New("The label selector for " + name + " must not be empty")
+		}
+
+		var namespaces []string
+		for _, ns := range strings.Split(data[name+namespacesSuffix], ",") {
+			ns = strings.TrimSpace(ns)
+			if len(ns) > 0 {
+				namespaces = append(namespaces, ns)
+			}
+		}
+		if len(namespaces) == 0 {
+			return rules, errors.New("No expected namespaces are set for " + name + " with a " + name + namespacesSuffix + " key")
+		}
+
+		rules = append(rules, rule{Name: name, Selector: selector, Namespaces: namespaces})
+	}
+	return rules, nil
+}
+
+// evaluatePods returns an error for every pod that matches the selector of a
+// rule and runs in a namespace the rule does not allow
+func evaluatePods(rules []rule, pods []apiv1.Pod) []string {
+	var escapeErrors []string
+
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		return pods[i].Name < pods[j].Name
+	})
+
+	for _, r := range rules {
+		for _, pod := range pods {
+			if !r.Selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			if allowedNamespace(pod.Namespace, r.Namespaces) {
+				continue
+			}
+			escapeErrors = append(escapeErrors, "Pod "+pod.Name+" in namespace "+pod.Namespace+" matches "+r.Name+" ("+r.Selector.String()+") which is only expected in namespaces "+strings.Join(r.Namespaces, ", "))
+		}
+	}
+	return escapeErrors
+}
+
+// allowedNamespace determines if a namespace is one of the allowed namespaces
+func allowedNamespace(ns string, allowed []string) bool {
+	for _, a := range allowed {
+		if ns == a {
+			return true
+		}
+	}
+	return false
+}
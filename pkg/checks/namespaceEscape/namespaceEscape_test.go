@@ -0,0 +1,84 @@
+package namespaceEscape
+
+import (
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := parseRules(map[string]string{
+		"myapp.selector":     "app=myapp",
+		"myapp.namespaces":   "default, staging",
+		"billing.selector":   "team in (billing)",
+		"billing.namespaces": "billing",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Fatal("Expected 2 rules but got", rules)
+	}
+	if rules[1].Name != "myapp" || len(rules[1].Namespaces) != 2 || rules[1].Namespaces[1] != "staging" {
+		t.Fatal("Unexpected myapp rule:", rules[1])
+	}
+
+	var tests = []struct {
+		description string
+		data        map[string]string
+	}{
+		{"invalid selector", map[string]string{"myapp.selector": "app in (", "myapp.namespaces": "default"}},
+		{"empty selector", map[string]string{"myapp.selector": " ", "myapp.namespaces": "default"}},
+		{"no namespaces", map[string]string{"myapp.selector": "app=myapp"}},
+		{"namespaces without selector", map[string]string{"myapp.namespaces": "default"}},
+		{"unknown key", map[string]string{"myapp": "app=myapp"}},
+	}
+
+	for _, test := range tests {
+		_, err := parseRules(test.data)
+		if err == nil {
+			t.Fatal("Test", test.description, "expected an error but got none")
+		}
+	}
+}
+
+func TestEvaluatePods(t *testing.T) {
+	rules, err := parseRules(map[string]string{
+		"myapp.selector":   "app=myapp",
+		"myapp.namespaces": "default",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	makePod := func(ns string, podLabels map[string]string) apiv1.Pod {
+		return apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "myapp-1", Namespace: ns, Labels: podLabels}}
+	}
+
+	var tests = []struct {
+		description   string
+		pod           apiv1.Pod
+		expectedError string
+	}{
+		{"expected namespace", makePod("default", map[string]string{"app": "myapp"}), ""},
+		{"unexpected namespace", makePod("kube-system", map[string]string{"app": "myapp"}), "Pod myapp-1 in namespace kube-system matches myapp (app=myapp) which is only expected in namespaces default"},
+		{"other app", makePod("kube-system", map[string]string{"app": "coredns"}), ""},
+		{"no labels", makePod("kube-system", nil), ""},
+		{"extra labels", makePod("tenant-b", map[string]string{"app": "myapp", "tier": "web"}), "in namespace tenant-b matches myapp"},
+	}
+
+	for _, test := range tests {
+		escapeErrors := evaluatePods(rules, []apiv1.Pod{test.pod})
+		if len(test.expectedError) == 0 {
+			if len(escapeErrors) != 0 {
+				t.Fatal("Test", test.description, "expected no errors but got", escapeErrors)
+			}
+			continue
+		}
+		if len(escapeErrors) != 1 || !strings.Contains(escapeErrors[0], test.expectedError) {
+			t.Fatal("Test", test.description, "expected error", test.expectedError, "but got", escapeErrors)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// Package namingConvention implements an advisory checker that validates
+// resource names against naming conventions configured per resource type in
+// a ConfigMap.
+package namingConvention // import "github.com/Comcast/kuberhealthy/pkg/checks/namingConvention"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// maxLengthSuffix is appended to a resource type in the ConfigMap to set the
+// maximum name length for that type
+const maxLengthSuffix = ".maxLength"
+
+// rule is the naming convention of a single resource type
+type rule struct {
+	Resource  string
+	Pattern   *regexp.Regexp
+	MaxLength int
+}
+
+// namedObject is the identity of an object checked against a rule
+type namedObject struct {
+	Name      string
+	Namespace string
+}
+
+// objectList is the subset of any list response used by this check
+type objectList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+	} `json:"items"`
+}
+
+// Checker validates resource names against naming conventions
+type Checker struct {
+	Errors    []string
+	ConfigMap string
+	client    *kubernetes.Clientset
+	interval  time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reads naming conventions from the supplied
+// ConfigMap, given as "name" in the kuberhealthy namespace or
+// "namespace/name".  Each key is a resource type such as deployments or
+// ingresses.extensions and each value is a regular expression that names
+// must match.  A maximum name length can be set with a key such as
+// deployments.maxLength.
+func New(configMap string) *Checker {
+	return &Checker{
+		Errors:    []string{},
+		ConfigMap: configMap,
+	}
+}
+
+// Name returns the name of this checker
+func (ncc *Checker) Name() string {
+	return "NamingConventionChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (ncc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (ncc *Checker) Interval() time.Duration {
+	if ncc.interval > 0 {
+		return ncc.interval
+	}
+	return time.Minute * 30
+}
+
+// SetInterval overrides the interval at which this check runs
+func (ncc *Checker) SetInterval(d time.Duration) {
+	ncc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  The resource types it
+// lists are read from the ConfigMap on every run and are not known in
+// advance, so listing is granted for every resource type.
+func (ncc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	configMapNamespace, configMapName := ncc.configMapName()
+	return map[string][]rbacv1.PolicyRule{
+		configMapNamespace: {
+			{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{configMapName}, Verbs: []string{"get"}},
+		},
+		"": {
+			{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (ncc *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (ncc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (ncc *Checker) CurrentStatus() (bool, []string) {
+	if len(ncc.Errors) > 0 {
+		return false, ncc.Errors
+	}
+	return true, ncc.Errors
+}
+
+// clearErrors clears all errors
+func (ncc *Checker) clearErrors() {
+	ncc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (ncc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	ncc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := ncc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(ncc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + ncc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(ncc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + ncc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + ncc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// configMapName returns the namespace and name of the naming conventions
+// ConfigMap, which may be given as namespace/name or as a name in the
+// Kuberhealthy namespace
+func (ncc *Checker) configMapName() (string, string) {
+	if strings.Contains(ncc.ConfigMap, "/") {
+		parts := strings.SplitN(ncc.ConfigMap, "/", 2)
+		return parts[0], parts[1]
+	}
+	return namespace, ncc.ConfigMap
+}
+
+// doChecks loads the naming conventions, lists the objects of each
+// configured resource type, and sets an error for every object that
+// violates its convention
+func (ncc *Checker) doChecks() error {
+
+	configMapNamespace, configMapName := ncc.configMapName()
+	cm, err := ncc.client.CoreV1().ConfigMaps(configMapNamespace).Get(configMapName, metav1.GetOptions{})
+	if err != nil {
+		return errors.New("Error getting naming conventions " + configMapNamespace + "/" + configMapName + ": " + err.Error())
+	}
+	rules, err := parseRules(cm.Data)
+	if err != nil {
+		return err
+	}
+
+	var violations []string
+	for _, r := range rules {
+		path, err := ncc.resourcePath(r.Resource)
+		if err != nil {
+			return err
+		}
+		b, err := ncc.client.CoreV1().RESTClient().Get().AbsPath(path).DoRaw()
+		if err != nil {
+			return errors.New("Error listing " + r.Resource + ": " + err.Error())
+		}
+		var list objectList
+		err = json.Unmarshal(b, &list)
+		if err != nil {
+			return errors.New("Error decoding " + r.Resource + ": " + err.Error())
+		}
+		var objects []namedObject
+		for _, item := range list.Items {
+			objects = append(objects, namedObject{Name: item.Metadata.Name, Namespace: item.Metadata.Namespace})
+		}
+		violations = append(violations, evaluateNames(r, objects)...)
+	}
+
+	if len(violations) > 0 {
+		for _, v := range violations {
+			khlog.ForCheck(ncc.Name()).Infoln(v)
+		}
+		ncc.Errors = violations
+		return nil
+	}
+
+	ncc.clearErrors()
+	return nil
+}
+
+// resourcePath uses discovery to find the list path of a resource type given
+// as "resource" or "resource.group"
+func (ncc *Checker) resourcePath(resource string) (string, error) {
+	parts := strings.SplitN(resource, ".", 2)
+	name := parts[0]
+	var group string
+	if len(parts) == 2 {
+		group = parts[1]
+	}
+
+	resourceLists, err := ncc.client.Discovery().ServerPreferredResources()
+	if err != nil && len(resourceLists) == 0 {
+		return "", errors.New("Error discovering API resources: " + err.Error())
+	}
+	for _, rl := range resourceLists {
+		gvGroup := ""
+		if strings.Contains(rl.GroupVersion, "/") {
+			gvGroup = strings.SplitN(rl.GroupVersion, "/", 2)[0]
+		}
+		if len(group) > 0 && gvGroup != group {
+			continue
+		}
+		for _, r := range rl.APIResources {
+			if r.Name != name {
+				continue
+			}
+			if len(gvGroup) == 0 {
+				return "/api/" + rl.GroupVersion + "/" + name, nil
+			}
+			return "/apis/" + rl.GroupVersion + "/" + name, nil
+		}
+	}
+	return "", errors.New("Unable to find resource type " + resource + " configured in the naming conventions")
+}
+
+// parseRules builds naming convention rules from ConfigMap data
+func parseRules(data map[string]string) ([]rule, error) {
+	var rules []rule
+
+	var keys []string
+	for k := range data {
+		if !strings.HasSuffix(k, maxLengthSuffix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		pattern, err := regexp.Compile(strings.TrimSpace(data[k]))
+		if err != nil {
+			return rules, errors.New("Invalid naming convention pattern for " + k + ": " + err.Error())
+		}
+		r := rule{Resource: k, Pattern: pattern}
+		if maxLength, ok := data[k+maxLengthSuffix]; ok {
+			r.MaxLength, err = strconv.Atoi(strings.TrimSpace(maxLength))
+			if err != nil {
+				return rules, errors.New("Invalid maximum name length for " + k + ": " + err.Error())
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// evaluateNames returns a violation for each object whose name does not
+// match the rule
+func evaluateNames(r rule, objects []namedObject) []string {
+	var violations []string
+	for _, o := range objects {
+		location := ""
+		if len(o.Namespace) > 0 {
+			location = " in namespace " + o.Namespace
+		}
+		if !r.Pattern.MatchString(o.Name) {
+			violations = append(violations, r.Resource+" "+o.Name+location+" does not match the naming convention "+r.Pattern.String())
+		}
+		if r.MaxLength > 0 && len(o.Name) >= r.MaxLength {
+			violations = append(violations, r.Resource+" "+o.Name+location+" has a name of "+strconv.Itoa(len(o.Name))+" characters which is not less than "+strconv.Itoa(r.MaxLength))
+		}
+	}
+	return violations
+}
@@ -0,0 +1,63 @@
+package namingConvention
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := parseRules(map[string]string{
+		"deployments":           "^[a-z][a-z0-9-]*$",
+		"deployments.maxLength": "63",
+		"ingresses.extensions":  "^ing-",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Fatal("Expected 2 rules but got", rules)
+	}
+	if rules[0].Resource != "deployments" || rules[0].MaxLength != 63 {
+		t.Fatal("Unexpected deployments rule:", rules[0])
+	}
+
+	_, err = parseRules(map[string]string{"services": "^[a-z"})
+	if err == nil {
+		t.Fatal("Expected an error for an invalid pattern")
+	}
+	_, err = parseRules(map[string]string{"services": "^[a-z]+$", "services.maxLength": "many"})
+	if err == nil {
+		t.Fatal("Expected an error for an invalid maximum length")
+	}
+}
+
+func TestEvaluateNames(t *testing.T) {
+	rules, err := parseRules(map[string]string{
+		"deployments":           "^[a-z][a-z0-9-]*$",
+		"deployments.maxLength": "63",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name     string
+		expected int
+	}{
+		{"web-frontend", 0},
+		{"api2", 0},
+		{"Web-Frontend", 1},
+		{"1-web", 1},
+		{"web_frontend", 1},
+		{strings.Repeat("a", 63), 1},
+		{strings.Repeat("A", 70), 2},
+	}
+
+	for _, test := range tests {
+		violations := evaluateNames(rules[0], []namedObject{{Name: test.name, Namespace: "default"}})
+		if len(violations) != test.expected {
+			t.Fatal("Name", test.name, "expected", test.expected, "violations but got", violations)
+		}
+		t.Log(violations)
+	}
+}
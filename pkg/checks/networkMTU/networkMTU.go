@@ -0,0 +1,404 @@
+// Package networkMTU implements a checker that ensures HTTP requests between
+// pods on different nodes succeed with payloads larger than a single packet.
+// When the pod network MTU is lower than the MTU pods believe they have and
+// path MTU discovery is broken, small requests succeed while larger requests
+// hang.  The MTU set in the CNI configuration of the nodes is compared
+// against the expected MTU as well.
+package networkMTU // import "github.com/Comcast/kuberhealthy/pkg/checks/networkMTU"
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+const (
+	// serverName is the base name of the server pod
+	serverName = "kuberhealthy-mtu-server"
+	// serverPort is the port the server pod listens on, which must match
+	// serverScript
+	serverPort = 8080
+	// headerBytes is the size of the IP and TCP headers sent with each packet
+	headerBytes = 40
+	// cniConfigPath is the directory on nodes that holds the CNI configuration
+	cniConfigPath = "/etc/cni/net.d"
+	// cniMountPath is where the CNI configuration is mounted in the client pod
+	cniMountPath = "/host/cni"
+)
+
+// serverScript answers every POST request after reading its whole body
+const serverScript = `import http.server
+
+class Handler(http.server.BaseHTTPRequestHandler):
+    def do_POST(self):
+        body = self.rfile.read(int(self.headers["Content-Length"]))
+        self.send_response(200)
+        self.end_headers()
+        self.wfile.write(str(len(body)).encode())
+
+http.server.HTTPServer(("", 8080), Handler).serve_forever()
+`
+
+// Checker validates that requests with large payloads can cross the pod
+// network between nodes
+type Checker struct {
+	Errors      []string
+	Image       string
+	ExpectedMTU int
+	client      *kubernetes.Clientset
+	// runPod is replaced in tests to inject client pod output
+	runPod   func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (string, error)
+	interval time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that expects the pod network to carry packets
+// of expectedMTU bytes
+func New(expectedMTU int) *Checker {
+	return &Checker{
+		Errors:      []string{},
+		Image:       "python:3.7-alpine",
+		ExpectedMTU: expectedMTU,
+		runPod:      podRunner.RunPod,
+	}
+}
+
+// Name returns the name of this checker
+func (nmc *Checker) Name() string {
+	return "NetworkMTUChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (nmc *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (nmc *Checker) Interval() time.Duration {
+	if nmc.interval > 0 {
+		return nmc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (nmc *Checker) SetInterval(d time.Duration) {
+	nmc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Nodes are listed to
+// place the server and client pods on different nodes, and both run in the
+// Kuberhealthy namespace.
+func (nmc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		namespace: podRunner.RunPodRBACRules(),
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (nmc *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (nmc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (nmc *Checker) CurrentStatus() (bool, []string) {
+	if len(nmc.Errors) > 0 {
+		return false, nmc.Errors
+	}
+	return true, nmc.Errors
+}
+
+// clearErrors clears all errors
+func (nmc *Checker) clearErrors() {
+	nmc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (nmc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	nmc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := nmc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(nmc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + nmc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(nmc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + nmc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + nmc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks starts a server pod on one node and sends requests of increasing
+// size to it from a client pod on another node
+func (nmc *Checker) doChecks() error {
+
+	nodes, err := nmc.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	names := schedulableNodeNames(nodes.Items)
+	if len(names) < 2 {
+		khlog.ForCheck(nmc.Name()).Debugln("fewer than two schedulable nodes. Skipping network MTU check.")
+		nmc.clearErrors()
+		return nil
+	}
+	serverNode, clientNode := names[0], names[1]
+
+	server, err := nmc.startServer(serverNode)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := nmc.client.CoreV1().Pods(namespace).Delete(server, &metav1.DeleteOptions{})
+		if err != nil {
+			khlog.ForCheck(nmc.Name()).Errorln("error deleting server pod", server+":", err)
+		}
+	}()
+
+	ip, err := nmc.waitForServer(server)
+	if err != nil {
+		return err
+	}
+
+	mtuErrors, err := nmc.probe(ip, serverNode, clientNode)
+	if err != nil {
+		return err
+	}
+
+	if len(mtuErrors) > 0 {
+		for _, e := range mtuErrors {
+			khlog.ForCheck(nmc.Name()).Warningln(e)
+		}
+		nmc.Errors = mtuErrors
+		return nil
+	}
+
+	nmc.clearErrors()
+	return nil
+}
+
+// probe runs a client pod on clientNode that reads the node's CNI MTU and
+// posts payloads of each size to the server, and returns an error for every
+// payload size or CNI MTU that does not match the expected MTU
+func (nmc *Checker) probe(ip string, serverNode string, clientNode string) ([]string, error) {
+	script := podRunner.Script{
+		Name:      "kuberhealthy-mtu-client",
+		Image:     nmc.Image,
+		Script:    clientScript("http://"+ip+":"+strconv.Itoa(serverPort)+"/", payloadSizes(nmc.ExpectedMTU)),
+		HostPaths: map[string]string{cniConfigPath: cniMountPath},
+		NodeName:  clientNode,
+	}
+	output, err := nmc.runPod(nmc.client, namespace, script, time.Minute*3)
+	if err != nil {
+		return nil, errors.New("Error running MTU client pod: " + err.Error())
+	}
+
+	results, cniMTUs := parseOutput(output)
+	return evaluateResults(results, cniMTUs, nmc.ExpectedMTU, serverNode, clientNode, khlog.ForCheck(nmc.Name())), nil
+}
+
+// startServer creates a pod on the node that accepts POST requests and
+// returns its name
+func (nmc *Checker) startServer(nodeName string) (string, error) {
+	terminationGracePeriod := int64(1)
+	pod := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   serverName + "-" + strconv.Itoa(int(time.Now().Unix())),
+			Labels: map[string]string{"app": serverName, "source": "kuberhealthy"},
+		},
+		Spec: apiv1.PodSpec{
+			NodeName:                      nodeName,
+			TerminationGracePeriodSeconds: &terminationGracePeriod,
+			Containers: []apiv1.Container{
+				{
+					Name:    "server",
+					Image:   nmc.Image,
+					Command: []string{"python", "-c", serverScript},
+				},
+			},
+		},
+	}
+	created, err := nmc.client.CoreV1().Pods(namespace).Create(pod)
+	if err != nil {
+		return "", errors.New("Error creating MTU server pod: " + err.Error())
+	}
+	return created.Name, nil
+}
+
+// waitForServer waits for the server pod to run and returns its IP
+func (nmc *Checker) waitForServer(name string) (string, error) {
+	deadline := time.Now().Add(time.Minute * 2)
+	for time.Now().Before(deadline) {
+		pod, err := nmc.client.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		if pod.Status.Phase == apiv1.PodRunning && len(pod.Status.PodIP) > 0 {
+			return pod.Status.PodIP, nil
+		}
+		time.Sleep(time.Second * 5)
+	}
+	return "", errors.New("MTU server pod " + name + " did not start running in time")
+}
+
+// payloadSizes returns the request body sizes to send in ascending order.
+// They include the largest payload that fits in a single packet of the
+// expected MTU and payloads that need several packets.
+func payloadSizes(expectedMTU int) []int {
+	sizes := []int{512, 1024, expectedMTU - headerBytes, expectedMTU, expectedMTU * 2, 9000}
+	sort.Ints(sizes)
+
+	var unique []int
+	for _, s := range sizes {
+		if s <= 0 || (len(unique) > 0 && unique[len(unique)-1] == s) {
+			continue
+		}
+		unique = append(unique, s)
+	}
+	return unique
+}
+
+// clientScript prints the MTU of every CNI configuration file and then the
+// result of posting a payload of each size to url, one per line
+func clientScript(url string, sizes []int) string {
+	var s []string
+	for _, size := range sizes {
+		s = append(s, strconv.Itoa(size))
+	}
+	return `for f in ` + cniMountPath + `/*; do
+  [ -f "$f" ] && grep -o '"mtu"[[:space:]]*:[[:space:]]*[0-9]*' "$f" | grep -o '[0-9]*$' | sed 's/^/cni-mtu /'
+done
+for size in ` + strings.Join(s, " ") + `; do
+  if python -c "import urllib.request; urllib.request.urlopen(urllib.request.Request('` + url + `', data=b'x' * $size), timeout=10).read()" 2>/dev/null; then
+    echo "$size ok"
+  else
+    echo "$size failed"
+  fi
+done`
+}
+
+// parseOutput returns whether the request for each payload size succeeded
+// and the MTUs found in the CNI configuration from the client pod output
+func parseOutput(output string) (map[int]bool, []int) {
+	results := make(map[int]bool)
+	var cniMTUs []int
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if fields[0] == "cni-mtu" {
+			mtu, err := strconv.Atoi(fields[1])
+			if err == nil {
+				cniMTUs = append(cniMTUs, mtu)
+			}
+			continue
+		}
+		size, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		results[size] = fields[1] == "ok"
+	}
+	return results, cniMTUs
+}
+
+// evaluateResults returns an error when a payload fails while a smaller one
+// succeeds, reporting the size above which requests fail, and an error for
+// every CNI MTU that differs from the expected MTU
+func evaluateResults(results map[int]bool, cniMTUs []int, expectedMTU int, serverNode string, clientNode string, checkLog *log.Entry) []string {
+	var mtuErrors []string
+
+	var sizes []int
+	for size := range results {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+
+	largestOK := 0
+	for _, size := range sizes {
+		if results[size] {
+			largestOK = size
+			continue
+		}
+		if largestOK == 0 {
+			mtuErrors = append(mtuErrors, "HTTP requests from a pod on node "+clientNode+" to a pod on node "+serverNode+" failed for every payload size")
+			break
+		}
+		e := "HTTP requests from a pod on node " + clientNode + " to a pod on node " + serverNode + " fail with payloads above " +
+			strconv.Itoa(largestOK) + " bytes. The first failing payload was " + strconv.Itoa(size) + " bytes."
+		if largestOK < expectedMTU-headerBytes {
+			e += " This is below the expected MTU of " + strconv.Itoa(expectedMTU) + " which suggests a lower path MTU and broken path MTU discovery."
+		} else {
+			e += " Packets of the expected MTU of " + strconv.Itoa(expectedMTU) + " pass but larger requests fail, which suggests broken path MTU discovery."
+		}
+		mtuErrors = append(mtuErrors, e)
+		break
+	}
+
+	if len(cniMTUs) == 0 {
+		checkLog.Debugln("No MTU found in the CNI configuration of node", clientNode)
+	}
+	for _, mtu := range cniMTUs {
+		if mtu != expectedMTU {
+			mtuErrors = append(mtuErrors, "CNI configuration on node "+clientNode+" sets an MTU of "+strconv.Itoa(mtu)+
+				" but the expected MTU is "+strconv.Itoa(expectedMTU))
+		}
+	}
+	return mtuErrors
+}
+
+// schedulableNodeNames returns the sorted names of ready nodes that accept
+// new pods
+func schedulableNodeNames(nodes []apiv1.Node) []string {
+	var names []string
+	for _, n := range nodes {
+		if n.Spec.Unschedulable {
+			continue
+		}
+		for _, c := range n.Status.Conditions {
+			if c.Type == apiv1.NodeReady && c.Status == apiv1.ConditionTrue {
+				names = append(names, n.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
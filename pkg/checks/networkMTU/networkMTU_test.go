@@ -0,0 +1,77 @@
+package networkMTU
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	"k8s.io/client-go/kubernetes"
+)
+
+// fakeTransport returns a runPod func whose output reports every payload
+// of up to limit bytes as delivered and every larger payload as failed
+func fakeTransport(limit int, expectedMTU int, cniMTUs ...int) func(*kubernetes.Clientset, string, podRunner.Script, time.Duration) (string, error) {
+	return func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (string, error) {
+		var lines []string
+		for _, mtu := range cniMTUs {
+			lines = append(lines, "cni-mtu "+strconv.Itoa(mtu))
+		}
+		for _, size := range payloadSizes(expectedMTU) {
+			if size <= limit {
+				lines = append(lines, strconv.Itoa(size)+" ok")
+			} else {
+				lines = append(lines, strconv.Itoa(size)+" failed")
+			}
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+}
+
+func TestPayloadSizes(t *testing.T) {
+	sizes := payloadSizes(1450)
+	expected := []int{512, 1024, 1410, 1450, 2900, 9000}
+	if len(sizes) != len(expected) {
+		t.Fatal("Expected payload sizes", expected, "but got", sizes)
+	}
+	for i := range sizes {
+		if sizes[i] != expected[i] {
+			t.Fatal("Expected payload sizes", expected, "but got", sizes)
+		}
+	}
+}
+
+func TestProbe(t *testing.T) {
+	var tests = []struct {
+		description   string
+		limit         int
+		cniMTUs       []int
+		expected      int
+		expectedError string
+	}{
+		{"all sizes delivered", 9000, []int{1450}, 0, ""},
+		{"no CNI MTU found", 9000, nil, 0, ""},
+		{"large requests fail", 1450, []int{1450}, 1, "payloads above 1450 bytes"},
+		{"path MTU below expected", 1024, []int{1450}, 1, "below the expected MTU of 1450"},
+		{"every request fails", 0, []int{1450}, 1, "failed for every payload size"},
+		{"CNI MTU mismatch", 9000, []int{1500}, 1, "sets an MTU of 1500"},
+	}
+
+	for _, test := range tests {
+		checker := New(1450)
+		checker.runPod = fakeTransport(test.limit, 1450, test.cniMTUs...)
+
+		mtuErrors, err := checker.probe("10.0.0.1", "node-a", "node-b")
+		if err != nil {
+			t.Fatal("Test", test.description, "unexpected error:", err)
+		}
+		if len(mtuErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", mtuErrors)
+		}
+		if test.expected > 0 && !strings.Contains(mtuErrors[0], test.expectedError) {
+			t.Fatal("Test", test.description, "expected an error containing", test.expectedError, "but got", mtuErrors)
+		}
+		t.Log(test.description, mtuErrors)
+	}
+}
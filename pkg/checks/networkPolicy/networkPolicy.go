@@ -0,0 +1,436 @@
+// Package networkPolicy implements a checker that verifies NetworkPolicies
+// allow and block traffic between namespaces as expected.  A misconfigured
+// policy can block traffic that should be allowed, or allow traffic that
+// should be blocked, without any other sign until an application breaks.
+// For every rule of an expected connectivity matrix, a server pod is started
+// in one namespace and a client pod in another namespace tries to connect
+// to it.
+package networkPolicy // import "github.com/Comcast/kuberhealthy/pkg/checks/networkPolicy"
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+const (
+	// serverName is the base name of the server pods
+	serverName = "kuberhealthy-netpol-server"
+	// clientName is the base name of the client pods
+	clientName = "kuberhealthy-netpol-client"
+	// defaultPort is the port servers listen on when a rule does not set one
+	defaultPort = 8080
+)
+
+// suffixes appended to a rule name in the ConfigMap to set each field of the
+// rule
+const (
+	fromSuffix       = ".from"
+	toSuffix         = ".to"
+	expectSuffix     = ".expect"
+	fromLabelsSuffix = ".fromLabels"
+	toLabelsSuffix   = ".toLabels"
+	portSuffix       = ".port"
+)
+
+// expected connectivity values of a rule
+const (
+	expectAllow = "allow"
+	expectDeny  = "deny"
+)
+
+// rule is a single entry of the expected connectivity matrix.  Clients in
+// the From namespace connecting to servers in the To namespace are expected
+// to succeed when Allow is set and fail otherwise.
+type rule struct {
+	Name       string
+	From       string
+	To         string
+	Allow      bool
+	FromLabels map[string]string
+	ToLabels   map[string]string
+	Port       int
+}
+
+// Checker validates that NetworkPolicies allow and block connections between
+// namespaces according to an expected connectivity matrix
+type Checker struct {
+	Errors    []string
+	ConfigMap string
+	Image     string
+	client    *kubernetes.Clientset
+	// runPod is replaced in tests to inject client pod output
+	runPod   func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (string, error)
+	interval time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reads the expected connectivity matrix from
+// the supplied ConfigMap, given as "name" in the kuberhealthy namespace or
+// "namespace/name".  Each rule is set with keys such as web.from, web.to and
+// web.expect, holding the client namespace, the server namespace and either
+// allow or deny.  The optional web.fromLabels and web.toLabels keys set
+// labels on the client and server pods as key=value pairs, and web.port
+// sets the port the server listens on.
+func New(configMap string) *Checker {
+	return &Checker{
+		Errors:    []string{},
+		ConfigMap: configMap,
+		Image:     "python:3.7-alpine",
+		runPod:    podRunner.RunPod,
+	}
+}
+
+// Name returns the name of this checker
+func (npc *Checker) Name() string {
+	return "NetworkPolicyChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (npc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (npc *Checker) Interval() time.Duration {
+	if npc.interval > 0 {
+		return npc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (npc *Checker) SetInterval(d time.Duration) {
+	npc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  The namespaces test
+// pods run in are read from the ConfigMap on every run, so pods are managed
+// cluster wide.
+func (npc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	configMapNamespace, configMapName := npc.configMapName()
+	return map[string][]rbacv1.PolicyRule{
+		configMapNamespace: {
+			{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{configMapName}, Verbs: []string{"get"}},
+		},
+		"": podRunner.RunPodRBACRules(),
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (npc *Checker) Timeout() time.Duration {
+	return time.Minute * 10
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (npc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (npc *Checker) CurrentStatus() (bool, []string) {
+	if len(npc.Errors) > 0 {
+		return false, npc.Errors
+	}
+	return true, npc.Errors
+}
+
+// clearErrors clears all errors
+func (npc *Checker) clearErrors() {
+	npc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (npc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	npc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := npc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(npc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + npc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(npc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + npc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + npc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// configMapName parses the expected network connectivity ConfigMap
+// reference, given either as namespace/name or as a name in the
+// Kuberhealthy namespace
+func (npc *Checker) configMapName() (string, string) {
+	if strings.Contains(npc.ConfigMap, "/") {
+		parts := strings.SplitN(npc.ConfigMap, "/", 2)
+		return parts[0], parts[1]
+	}
+	return namespace, npc.ConfigMap
+}
+
+// doChecks loads the expected connectivity matrix and tests every rule,
+// setting an error for every connection that does not match its rule
+func (npc *Checker) doChecks() error {
+
+	configMapNamespace, configMapName := npc.configMapName()
+	cm, err := npc.client.CoreV1().ConfigMaps(configMapNamespace).Get(configMapName, metav1.GetOptions{})
+	if err != nil {
+		return errors.New("Error getting expected network connectivity " + configMapNamespace + "/" + configMapName + ": " + err.Error())
+	}
+	rules, err := parseRules(cm.Data)
+	if err != nil {
+		return err
+	}
+
+	var policyErrors []string
+	for _, r := range rules {
+		connected, err := npc.testRule(r)
+		if err != nil {
+			return err
+		}
+		if e := evaluateRule(r, connected); len(e) > 0 {
+			policyErrors = append(policyErrors, e)
+		}
+	}
+
+	if len(policyErrors) > 0 {
+		for _, e := range policyErrors {
+			khlog.ForCheck(npc.Name()).Warningln(e)
+		}
+		npc.Errors = policyErrors
+		return nil
+	}
+
+	npc.clearErrors()
+	return nil
+}
+
+// testRule starts a server pod in the destination namespace of a rule and
+// returns whether a client pod in the source namespace could connect to it.
+// Both pods are removed before returning.
+func (npc *Checker) testRule(r rule) (bool, error) {
+	server, err := npc.startServer(r)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		err := npc.client.CoreV1().Pods(r.To).Delete(server, &metav1.DeleteOptions{})
+		if err != nil {
+			khlog.ForCheck(npc.Name()).Errorln("error deleting server pod", r.To+"/"+server+":", err)
+		}
+	}()
+
+	ip, err := npc.waitForServer(r.To, server)
+	if err != nil {
+		return false, err
+	}
+
+	script := podRunner.Script{
+		Name:   clientName,
+		Image:  npc.Image,
+		Script: clientScript(ip, r.Port),
+		Labels: r.FromLabels,
+	}
+	output, err := npc.runPod(npc.client, r.From, script, time.Minute*2)
+	if err != nil {
+		return false, errors.New("Error running network policy client pod for " + r.Name + " in namespace " + r.From + ": " + err.Error())
+	}
+
+	connected, ok := parseOutput(output)
+	if !ok {
+		return false, errors.New("Unexpected output from network policy client pod for " + r.Name + ": " + output)
+	}
+	return connected, nil
+}
+
+// startServer creates a pod that accepts TCP connections on the port of the
+// rule in its destination namespace and returns its name
+func (npc *Checker) startServer(r rule) (string, error) {
+	podLabels := map[string]string{}
+	for k, v := range r.ToLabels {
+		podLabels[k] = v
+	}
+	podLabels["app"] = serverName
+	podLabels["source"] = "kuberhealthy"
+
+	terminationGracePeriod := int64(1)
+	pod := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   serverName + "-" + strconv.Itoa(int(time.Now().Unix())),
+			Labels: podLabels,
+		},
+		Spec: apiv1.PodSpec{
+			TerminationGracePeriodSeconds: &terminationGracePeriod,
+			Containers: []apiv1.Container{
+				{
+					Name:    "server",
+					Image:   npc.Image,
+					Command: []string{"python", "-m", "http.server", strconv.Itoa(r.Port)},
+					Ports:   []apiv1.ContainerPort{{ContainerPort: int32(r.Port)}},
+				},
+			},
+		},
+	}
+	created, err := npc.client.CoreV1().Pods(r.To).Create(pod)
+	if err != nil {
+		return "", errors.New("Error creating network policy server pod in namespace " + r.To + ": " + err.Error())
+	}
+	return created.Name, nil
+}
+
+// waitForServer waits for the server pod to run and returns its IP
+func (npc *Checker) waitForServer(podNamespace string, name string) (string, error) {
+	deadline := time.Now().Add(time.Minute * 2)
+	for time.Now().Before(deadline) {
+		pod, err := npc.client.CoreV1().Pods(podNamespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		if pod.Status.Phase == apiv1.PodRunning && len(pod.Status.PodIP) > 0 {
+			return pod.Status.PodIP, nil
+		}
+		time.Sleep(time.Second * 5)
+	}
+	return "", errors.New("Network policy server pod " + podNamespace + "/" + name + " did not start running in time")
+}
+
+// clientScript prints connected when a TCP connection to the server can be
+// opened and blocked otherwise
+func clientScript(ip string, port int) string {
+	return `if python -c "import socket; socket.create_connection(('` + ip + `', ` + strconv.Itoa(port) + `), 10).close()" 2>/dev/null; then
+  echo connected
+else
+  echo blocked
+fi`
+}
+
+// parseOutput returns whether the client pod connected to the server.  The
+// second value is false when the output holds no result.
+func parseOutput(output string) (bool, bool) {
+	for _, line := range strings.Split(output, "\n") {
+		switch strings.TrimSpace(line) {
+		case "connected":
+			return true, true
+		case "blocked":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// evaluateRule returns an error when the result of a connection does not
+// match the expected connectivity of its rule, or an empty string when it
+// does
+func evaluateRule(r rule, connected bool) string {
+	description := "Connection from namespace " + r.From + " to namespace " + r.To + " on port " + strconv.Itoa(r.Port) + " (" + r.Name + ")"
+	if r.Allow && !connected {
+		return description + " is expected to be allowed but was blocked"
+	}
+	if !r.Allow && connected {
+		return description + " is expected to be denied but succeeded"
+	}
+	return ""
+}
+
+// parseRules builds the expected connectivity matrix from ConfigMap data.
+// Every rule must have a from, to and expect key.
+func parseRules(data map[string]string) ([]rule, error) {
+	var rules []rule
+
+	suffixes := []string{fromSuffix, toSuffix, expectSuffix, fromLabelsSuffix, toLabelsSuffix, portSuffix}
+	names := make(map[string]bool)
+	for k := range data {
+		known := false
+		for _, suffix := range suffixes {
+			if strings.HasSuffix(k, suffix) {
+				names[strings.TrimSuffix(k, suffix)] = true
+				known = true
+				break
+			}
+		}
+		if !known {
+			return rules, errors.New("Unknown key " + k + " in the expected network connectivity.  Keys must end in one of " + strings.Join(suffixes, ", "))
+		}
+	}
+
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		r := rule{
+			Name: name,
+			From: strings.TrimSpace(data[name+fromSuffix]),
+			To:   strings.TrimSpace(data[name+toSuffix]),
+			Port: defaultPort,
+		}
+		if len(r.From) == 0 || len(r.To) == 0 {
+			return rules, errors.New("Expected network connectivity " + name + " must set both " + name + fromSuffix + " and " + name + toSuffix)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(data[name+expectSuffix])) {
+		case expectAllow:
+			r.Allow = true
+		case expectDeny:
+			r.Allow = false
+		default:
+			return rules, errors.New("Expected network connectivity " + name + expectSuffix + " must be " + expectAllow + " or " + expectDeny)
+		}
+
+		var err error
+		r.FromLabels, err = parseLabels(data[name+fromLabelsSuffix])
+		if err != nil {
+			return rules, errors.New("Invalid labels for " + name + fromLabelsSuffix + ": " + err.Error())
+		}
+		r.ToLabels, err = parseLabels(data[name+toLabelsSuffix])
+		if err != nil {
+			return rules, errors.New("Invalid labels for " + name + toLabelsSuffix + ": " + err.Error())
+		}
+
+		if port, ok := data[name+portSuffix]; ok {
+			r.Port, err = strconv.Atoi(strings.TrimSpace(port))
+			if err != nil || r.Port < 1 || r.Port > 65535 {
+				return rules, errors.New("Invalid port for " + name + portSuffix + ": " + port)
+			}
+		}
+
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// parseLabels parses comma separated key=value pairs into a label set
+func parseLabels(s string) (map[string]string, error) {
+	if len(strings.TrimSpace(s)) == 0 {
+		return map[string]string{}, nil
+	}
+	return labels.ConvertSelectorToLabelsMap(s)
+}
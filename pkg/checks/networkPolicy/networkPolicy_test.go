@@ -0,0 +1,124 @@
+package networkPolicy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	var tests = []struct {
+		description   string
+		data          map[string]string
+		expectedRules []rule
+		expectedError string
+	}{
+		{
+			"allow and deny rules",
+			map[string]string{
+				"web.from":           "frontend",
+				"web.to":             "backend",
+				"web.expect":         "allow",
+				"web.fromLabels":     "role=web, tier=frontend",
+				"web.port":           "9090",
+				"sandbox.from":       "sandbox",
+				"sandbox.to":         "backend",
+				"sandbox.expect":     "Deny",
+				"sandbox.toLabels":   "role=api",
+				"sandbox.fromLabels": "",
+			},
+			[]rule{
+				{Name: "sandbox", From: "sandbox", To: "backend", Allow: false, ToLabels: map[string]string{"role": "api"}, Port: 8080},
+				{Name: "web", From: "frontend", To: "backend", Allow: true, FromLabels: map[string]string{"role": "web", "tier": "frontend"}, Port: 9090},
+			},
+			"",
+		},
+		{"unknown key", map[string]string{"web.source": "frontend"}, nil, "Unknown key web.source"},
+		{"missing to", map[string]string{"web.from": "frontend", "web.expect": "allow"}, nil, "must set both web.from and web.to"},
+		{"invalid expect", map[string]string{"web.from": "frontend", "web.to": "backend", "web.expect": "maybe"}, nil, "web.expect must be allow or deny"},
+		{"invalid port", map[string]string{"web.from": "frontend", "web.to": "backend", "web.expect": "allow", "web.port": "http"}, nil, "Invalid port for web.port"},
+		{"invalid labels", map[string]string{"web.from": "frontend", "web.to": "backend", "web.expect": "allow", "web.toLabels": "role"}, nil, "Invalid labels for web.toLabels"},
+	}
+
+	for _, test := range tests {
+		rules, err := parseRules(test.data)
+		if len(test.expectedError) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+				t.Fatal("Test", test.description, "expected error", test.expectedError, "but got", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal("Test", test.description, "expected no error but got", err)
+		}
+		if len(rules) != len(test.expectedRules) {
+			t.Fatal("Test", test.description, "expected", test.expectedRules, "but got", rules)
+		}
+		for i, r := range rules {
+			expected := test.expectedRules[i]
+			if r.Name != expected.Name || r.From != expected.From || r.To != expected.To || r.Allow != expected.Allow || r.Port != expected.Port {
+				t.Fatal("Test", test.description, "expected", expected, "but got", r)
+			}
+			if !sameLabels(r.FromLabels, expected.FromLabels) || !sameLabels(r.ToLabels, expected.ToLabels) {
+				t.Fatal("Test", test.description, "expected labels", expected.FromLabels, expected.ToLabels, "but got", r.FromLabels, r.ToLabels)
+			}
+		}
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	var tests = []struct {
+		output            string
+		expectedConnected bool
+		expectedOK        bool
+	}{
+		{"connected", true, true},
+		{"blocked\n", false, true},
+		{"Traceback (most recent call last):", false, false},
+		{"", false, false},
+	}
+
+	for _, test := range tests {
+		connected, ok := parseOutput(test.output)
+		if connected != test.expectedConnected || ok != test.expectedOK {
+			t.Fatal("Output", test.output, "expected", test.expectedConnected, test.expectedOK, "but got", connected, ok)
+		}
+	}
+}
+
+func TestEvaluateRule(t *testing.T) {
+	allow := rule{Name: "web", From: "frontend", To: "backend", Allow: true, Port: 8080}
+	deny := rule{Name: "sandbox", From: "sandbox", To: "backend", Allow: false, Port: 8080}
+
+	var tests = []struct {
+		description   string
+		r             rule
+		connected     bool
+		expectedError string
+	}{
+		{"allowed and connected", allow, true, ""},
+		{"denied and blocked", deny, false, ""},
+		{"allowed but blocked", allow, false, "Connection from namespace frontend to namespace backend on port 8080 (web) is expected to be allowed but was blocked"},
+		{"denied but connected", deny, true, "Connection from namespace sandbox to namespace backend on port 8080 (sandbox) is expected to be denied but succeeded"},
+	}
+
+	for _, test := range tests {
+		e := evaluateRule(test.r, test.connected)
+		if e != test.expectedError {
+			t.Fatal("Test", test.description, "expected", test.expectedError, "but got", e)
+		}
+	}
+}
+
+// sameLabels determines if two label sets hold the same labels, treating
+// nil and empty sets as equal
+func sameLabels(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
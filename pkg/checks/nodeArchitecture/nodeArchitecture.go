@@ -0,0 +1,260 @@
+// Package nodeArchitecture implements a checker that ensures nodes run the
+// expected CPU architectures.  On clusters intentionally running mixed
+// architectures, nodes must also carry an accurate architecture label and
+// workloads that require an architecture must be scheduled by it.
+package nodeArchitecture // import "github.com/Comcast/kuberhealthy/pkg/checks/nodeArchitecture"
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ArchLabel is the node label that holds the node's architecture
+	ArchLabel = "kubernetes.io/arch"
+	// betaArchLabel is the deprecated form of ArchLabel
+	betaArchLabel = "beta.kubernetes.io/arch"
+	// RequiredArchAnnotation marks a pod that only runs on one architecture
+	RequiredArchAnnotation = "kuberhealthy.io/required-architecture"
+)
+
+// Checker validates node architectures
+type Checker struct {
+	Errors        []string
+	Architectures []string
+	client        *kubernetes.Clientset
+	interval      time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that expects every node to run one of the
+// supplied architectures, or amd64 when none are supplied
+func New(architectures []string) *Checker {
+	if len(architectures) == 0 {
+		architectures = []string{"amd64"}
+	}
+	return &Checker{
+		Errors:        []string{},
+		Architectures: architectures,
+	}
+}
+
+// Name returns the name of this checker
+func (nac *Checker) Name() string {
+	return "NodeArchitectureChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (nac *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (nac *Checker) Interval() time.Duration {
+	if nac.interval > 0 {
+		return nac.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (nac *Checker) SetInterval(d time.Duration) {
+	nac.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Nodes are cluster
+// scoped and pods are listed in every namespace.
+func (nac *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes", "pods"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (nac *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (nac *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (nac *Checker) CurrentStatus() (bool, []string) {
+	if len(nac.Errors) > 0 {
+		return false, nac.Errors
+	}
+	return true, nac.Errors
+}
+
+// clearErrors clears all errors
+func (nac *Checker) clearErrors() {
+	nac.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (nac *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	nac.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := nac.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(nac.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + nac.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(nac.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + nac.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + nac.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists nodes and sets an error for every node with an unexpected
+// architecture.  When more than one architecture is expected, node labels
+// and the scheduling of pods that require an architecture are also checked.
+func (nac *Checker) doChecks() error {
+
+	nodes, err := nac.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	archErrors := nac.evaluateNodes(nodes.Items)
+	if len(nac.Architectures) > 1 {
+		pods, err := nac.client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		archErrors = append(archErrors, evaluatePods(pods.Items)...)
+	}
+
+	if len(archErrors) > 0 {
+		for _, e := range archErrors {
+			khlog.ForCheck(nac.Name()).Warningln(e)
+		}
+		nac.Errors = archErrors
+		return nil
+	}
+
+	nac.clearErrors()
+	return nil
+}
+
+// evaluateNodes returns an error for every node with an unexpected
+// architecture, and when more than one architecture is expected, for every
+// node whose architecture label is missing or wrong
+func (nac *Checker) evaluateNodes(nodes []apiv1.Node) []string {
+	var archErrors []string
+	for _, n := range nodes {
+		arch := n.Status.NodeInfo.Architecture
+		if !containsString(nac.Architectures, arch) {
+			archErrors = append(archErrors, "Node "+n.Name+" has architecture "+arch+" but one of "+strings.Join(nac.Architectures, ", ")+" is expected")
+			continue
+		}
+		if len(nac.Architectures) < 2 {
+			continue
+		}
+
+		label, ok := n.Labels[ArchLabel]
+		if !ok {
+			label, ok = n.Labels[betaArchLabel]
+		}
+		if !ok {
+			archErrors = append(archErrors, "Node "+n.Name+" with architecture "+arch+" has no "+ArchLabel+" label")
+			continue
+		}
+		if label != arch {
+			archErrors = append(archErrors, "Node "+n.Name+" with architecture "+arch+" is labeled "+ArchLabel+"="+label)
+		}
+	}
+	return archErrors
+}
+
+// evaluatePods returns an error for every pod annotated with a required
+// architecture that is not restricted to nodes of that architecture by its
+// node selector or required node affinity
+func evaluatePods(pods []apiv1.Pod) []string {
+	var archErrors []string
+	for _, p := range pods {
+		arch, ok := p.Annotations[RequiredArchAnnotation]
+		if !ok {
+			continue
+		}
+		if !restrictedTo(p.Spec, arch) {
+			archErrors = append(archErrors, "Pod "+p.Namespace+"/"+p.Name+" requires architecture "+arch+" but has no node selector or required node affinity on "+ArchLabel)
+		}
+	}
+	return archErrors
+}
+
+// restrictedTo returns true if a pod can only be scheduled on nodes of the
+// architecture
+func restrictedTo(spec apiv1.PodSpec, arch string) bool {
+	for _, label := range []string{ArchLabel, betaArchLabel} {
+		if spec.NodeSelector[label] == arch {
+			return true
+		}
+	}
+
+	if spec.Affinity == nil || spec.Affinity.NodeAffinity == nil || spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return false
+	}
+	terms := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) == 0 {
+		return false
+	}
+	// terms are ORed, so every term must restrict the architecture
+	for _, term := range terms {
+		if !termRestrictsTo(term, arch) {
+			return false
+		}
+	}
+	return true
+}
+
+// termRestrictsTo returns true if a node selector term only matches nodes of
+// the architecture
+func termRestrictsTo(term apiv1.NodeSelectorTerm, arch string) bool {
+	for _, e := range term.MatchExpressions {
+		if e.Key != ArchLabel && e.Key != betaArchLabel {
+			continue
+		}
+		if e.Operator == apiv1.NodeSelectorOpIn && len(e.Values) == 1 && e.Values[0] == arch {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString returns true if s is in list
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package nodeArchitecture
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateNodes(t *testing.T) {
+	makeNode := func(arch string, labels map[string]string) apiv1.Node {
+		return apiv1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-a", Labels: labels},
+			Status:     apiv1.NodeStatus{NodeInfo: apiv1.NodeSystemInfo{Architecture: arch}},
+		}
+	}
+
+	var tests = []struct {
+		description   string
+		architectures []string
+		node          apiv1.Node
+		expected      int
+	}{
+		{"expected architecture", nil, makeNode("amd64", nil), 0},
+		{"unexpected architecture", nil, makeNode("arm64", nil), 1},
+		{"mixed labeled", []string{"amd64", "arm64"}, makeNode("arm64", map[string]string{ArchLabel: "arm64"}), 0},
+		{"mixed beta label", []string{"amd64", "arm64"}, makeNode("arm64", map[string]string{betaArchLabel: "arm64"}), 0},
+		{"mixed unlabeled", []string{"amd64", "arm64"}, makeNode("arm64", nil), 1},
+		{"mixed mislabeled", []string{"amd64", "arm64"}, makeNode("arm64", map[string]string{ArchLabel: "amd64"}), 1},
+		{"mixed unexpected", []string{"amd64", "arm64"}, makeNode("ppc64le", map[string]string{ArchLabel: "ppc64le"}), 1},
+	}
+
+	for _, test := range tests {
+		checker := New(test.architectures)
+		archErrors := checker.evaluateNodes([]apiv1.Node{test.node})
+		if len(archErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", archErrors)
+		}
+		t.Log(test.description, archErrors)
+	}
+}
+
+func TestEvaluatePods(t *testing.T) {
+	makePod := func(annotations map[string]string, spec apiv1.PodSpec) apiv1.Pod {
+		return apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: annotations}, Spec: spec}
+	}
+	required := map[string]string{RequiredArchAnnotation: "arm64"}
+	affinity := func(terms ...apiv1.NodeSelectorTerm) apiv1.PodSpec {
+		return apiv1.PodSpec{Affinity: &apiv1.Affinity{NodeAffinity: &apiv1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &apiv1.NodeSelector{NodeSelectorTerms: terms},
+		}}}
+	}
+	archTerm := func(values ...string) apiv1.NodeSelectorTerm {
+		return apiv1.NodeSelectorTerm{MatchExpressions: []apiv1.NodeSelectorRequirement{
+			{Key: ArchLabel, Operator: apiv1.NodeSelectorOpIn, Values: values},
+		}}
+	}
+
+	var tests = []struct {
+		description string
+		pod         apiv1.Pod
+		expected    int
+	}{
+		{"no required architecture", makePod(nil, apiv1.PodSpec{}), 0},
+		{"node selector", makePod(required, apiv1.PodSpec{NodeSelector: map[string]string{ArchLabel: "arm64"}}), 0},
+		{"wrong node selector", makePod(required, apiv1.PodSpec{NodeSelector: map[string]string{ArchLabel: "amd64"}}), 1},
+		{"node affinity", makePod(required, affinity(archTerm("arm64"))), 0},
+		{"node affinity allowing any architecture", makePod(required, affinity(archTerm("arm64", "amd64"))), 1},
+		{"one unrestricted term", makePod(required, affinity(archTerm("arm64"), apiv1.NodeSelectorTerm{})), 1},
+		{"no scheduling constraints", makePod(required, apiv1.PodSpec{}), 1},
+	}
+
+	for _, test := range tests {
+		archErrors := evaluatePods([]apiv1.Pod{test.pod})
+		if len(archErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", archErrors)
+		}
+		t.Log(test.description, archErrors)
+	}
+}
@@ -0,0 +1,243 @@
+// Package nodeAutoRepair implements a checker that ensures node auto-repair
+// is enabled on GKE node pools and that no node is being repaired over and
+// over.  Repeated repairs of one node usually point to a problem that
+// recreating the node does not fix, such as a bad image or a workload that
+// exhausts the node.
+package nodeAutoRepair // import "github.com/Comcast/kuberhealthy/pkg/checks/nodeAutoRepair"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// NodePoolLabel is set on every node of a GKE cluster to the name of its
+	// node pool
+	NodePoolLabel = "cloud.google.com/gke-nodepool"
+	// AutoRepairAnnotation is set to true on nodes of node pools with
+	// auto-repair enabled
+	AutoRepairAnnotation = "cloud.google.com/gke-auto-repair"
+	// RepairHistoryAnnotation holds the comma separated RFC3339 times at
+	// which a node was repaired
+	RepairHistoryAnnotation = "cloud.google.com/gke-repair-history"
+)
+
+// Checker validates GKE node auto-repair
+type Checker struct {
+	Errors    []string
+	Threshold int
+	Window    time.Duration
+	client    *kubernetes.Clientset
+	interval  time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reports nodes repaired more than threshold
+// times within the window
+func New(threshold int, window time.Duration) *Checker {
+	return &Checker{
+		Errors:    []string{},
+		Threshold: threshold,
+		Window:    window,
+	}
+}
+
+// Name returns the name of this checker
+func (nac *Checker) Name() string {
+	return "NodeAutoRepairChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (nac *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (nac *Checker) Interval() time.Duration {
+	if nac.interval > 0 {
+		return nac.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (nac *Checker) SetInterval(d time.Duration) {
+	nac.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to list the cluster
+// scoped nodes
+func (nac *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (nac *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (nac *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (nac *Checker) CurrentStatus() (bool, []string) {
+	if len(nac.Errors) > 0 {
+		return false, nac.Errors
+	}
+	return true, nac.Errors
+}
+
+// clearErrors clears all errors
+func (nac *Checker) clearErrors() {
+	nac.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (nac *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	nac.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := nac.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(nac.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + nac.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(nac.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + nac.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + nac.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists nodes and sets an error for every node pool without
+// auto-repair and every node repaired too often.  Clusters that are not
+// running on GKE are skipped.
+func (nac *Checker) doChecks() error {
+
+	nodes, err := nac.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing nodes: " + err.Error())
+	}
+	if !isGKE(nodes.Items) {
+		khlog.ForCheck(nac.Name()).Debugln("no nodes have the", NodePoolLabel, "label. Skipping node auto-repair check.")
+		nac.clearErrors()
+		return nil
+	}
+
+	repairErrors := evaluateNodes(nodes.Items, nac.Threshold, nac.Window, time.Now())
+
+	if len(repairErrors) > 0 {
+		for _, e := range repairErrors {
+			khlog.ForCheck(nac.Name()).Warningln(e)
+		}
+		nac.Errors = repairErrors
+		return nil
+	}
+
+	nac.clearErrors()
+	return nil
+}
+
+// evaluateNodes returns an error for every node pool with nodes that do not
+// have auto-repair enabled, and for every node repaired more than threshold
+// times within the window before now
+func evaluateNodes(nodes []apiv1.Node, threshold int, window time.Duration, now time.Time) []string {
+	var repairErrors []string
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+
+	disabled := make(map[string][]string)
+	for _, n := range nodes {
+		pool, ok := n.Labels[NodePoolLabel]
+		if !ok {
+			continue
+		}
+		if n.Annotations[AutoRepairAnnotation] != "true" {
+			disabled[pool] = append(disabled[pool], n.Name)
+		}
+
+		history, ok := n.Annotations[RepairHistoryAnnotation]
+		if !ok {
+			continue
+		}
+		repairs, err := repairsSince(history, now.Add(-window))
+		if err != nil {
+			repairErrors = append(repairErrors, "Node "+n.Name+" has an invalid "+RepairHistoryAnnotation+" annotation: "+err.Error())
+			continue
+		}
+		if repairs > threshold {
+			repairErrors = append(repairErrors, "Node "+n.Name+" in node pool "+pool+" was repaired "+strconv.Itoa(repairs)+
+				" times in the last "+window.String()+" which is more than the threshold of "+strconv.Itoa(threshold))
+		}
+	}
+
+	var pools []string
+	for pool := range disabled {
+		pools = append(pools, pool)
+	}
+	sort.Strings(pools)
+	for _, pool := range pools {
+		repairErrors = append(repairErrors, "Node auto-repair is not enabled on node pool "+pool+" for nodes: "+strings.Join(disabled[pool], ", "))
+	}
+	return repairErrors
+}
+
+// repairsSince returns the number of repair times in a repair history that
+// are after since
+func repairsSince(history string, since time.Time) (int, error) {
+	var repairs int
+	for _, s := range strings.Split(history, ",") {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return 0, err
+		}
+		if t.After(since) {
+			repairs++
+		}
+	}
+	return repairs, nil
+}
+
+// isGKE determines if any node belongs to a GKE node pool
+func isGKE(nodes []apiv1.Node) bool {
+	for _, n := range nodes {
+		if _, ok := n.Labels[NodePoolLabel]; ok {
+			return true
+		}
+	}
+	return false
+}
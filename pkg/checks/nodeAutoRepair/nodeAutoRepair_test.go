@@ -0,0 +1,74 @@
+package nodeAutoRepair
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateNodes(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) string {
+		return now.Add(-d).Format(time.RFC3339)
+	}
+
+	makeNode := func(autoRepair string, history ...string) apiv1.Node {
+		n := apiv1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "gke-node-a",
+				Labels:      map[string]string{NodePoolLabel: "default-pool"},
+				Annotations: map[string]string{},
+			},
+		}
+		if len(autoRepair) > 0 {
+			n.Annotations[AutoRepairAnnotation] = autoRepair
+		}
+		if len(history) > 0 {
+			n.Annotations[RepairHistoryAnnotation] = strings.Join(history, ",")
+		}
+		return n
+	}
+
+	var tests = []struct {
+		description   string
+		node          apiv1.Node
+		expectedError string
+	}{
+		{"auto-repair enabled", makeNode("true"), ""},
+		{"auto-repair disabled", makeNode("false"), "not enabled on node pool default-pool for nodes: gke-node-a"},
+		{"auto-repair annotation missing", makeNode(""), "not enabled on node pool default-pool"},
+		{"repairs at threshold", makeNode("true", ago(time.Hour), ago(time.Hour*2), ago(time.Hour*3)), ""},
+		{"repairs above threshold", makeNode("true", ago(time.Hour), ago(time.Hour*2), ago(time.Hour*3), ago(time.Hour*4)), "repaired 4 times in the last 24h0m0s"},
+		{"old repairs outside window", makeNode("true", ago(time.Hour), ago(time.Hour*30), ago(time.Hour*40), ago(time.Hour*50)), ""},
+		{"invalid history", makeNode("true", "yesterday"), "invalid " + RepairHistoryAnnotation},
+		{"not a GKE node", apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}}, ""},
+	}
+
+	for _, test := range tests {
+		repairErrors := evaluateNodes([]apiv1.Node{test.node}, 3, time.Hour*24, now)
+		if len(test.expectedError) == 0 {
+			if len(repairErrors) != 0 {
+				t.Fatal("Test", test.description, "expected no errors but got", repairErrors)
+			}
+			continue
+		}
+		if len(repairErrors) != 1 || !strings.Contains(repairErrors[0], test.expectedError) {
+			t.Fatal("Test", test.description, "expected an error containing", test.expectedError, "but got", repairErrors)
+		}
+		t.Log(test.description, repairErrors)
+	}
+}
+
+func TestIsGKE(t *testing.T) {
+	gke := apiv1.Node{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{NodePoolLabel: "default-pool"}}}
+	other := apiv1.Node{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{}}}
+	if !isGKE([]apiv1.Node{other, gke}) {
+		t.Fatal("Expected a cluster with a node pool label to be GKE")
+	}
+	if isGKE([]apiv1.Node{other}) {
+		t.Fatal("Expected a cluster without node pool labels not to be GKE")
+	}
+}
@@ -0,0 +1,175 @@
+// Package nodeMemoryCapacity implements a checker that ensures cluster nodes
+// have at least the expected amount of memory.  This catches nodes that were
+// provisioned with the wrong instance type and can not run some workloads.
+package nodeMemoryCapacity // import "github.com/Comcast/kuberhealthy/pkg/checks/nodeMemoryCapacity"
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// gibibyte is the number of bytes in a Gi
+const gibibyte = 1024 * 1024 * 1024
+
+// Checker validates the memory capacity of nodes
+type Checker struct {
+	Errors        []string
+	MinMemoryGi   float64
+	LabelSelector string
+	client        *kubernetes.Clientset
+	interval      time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that requires nodes matching the label selector,
+// or all nodes when the selector is empty, to have at least minMemoryGi of
+// memory capacity
+func New(minMemoryGi float64, labelSelector string) *Checker {
+	return &Checker{
+		Errors:        []string{},
+		MinMemoryGi:   minMemoryGi,
+		LabelSelector: labelSelector,
+	}
+}
+
+// Name returns the name of this checker
+func (nmc *Checker) Name() string {
+	return "NodeMemoryCapacityChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (nmc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (nmc *Checker) Interval() time.Duration {
+	if nmc.interval > 0 {
+		return nmc.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (nmc *Checker) SetInterval(d time.Duration) {
+	nmc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Listing nodes, even
+// by label, needs cluster scoped access.
+func (nmc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (nmc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (nmc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (nmc *Checker) CurrentStatus() (bool, []string) {
+	if len(nmc.Errors) > 0 {
+		return false, nmc.Errors
+	}
+	return true, nmc.Errors
+}
+
+// clearErrors clears all errors
+func (nmc *Checker) clearErrors() {
+	nmc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (nmc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	nmc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := nmc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(nmc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + nmc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(nmc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + nmc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + nmc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists the nodes matching the label selector and sets an error for
+// every node with less memory than expected
+func (nmc *Checker) doChecks() error {
+
+	nodes, err := nmc.client.CoreV1().Nodes().List(metav1.ListOptions{
+		LabelSelector: nmc.LabelSelector,
+	})
+	if err != nil {
+		return err
+	}
+
+	memoryErrors := evaluateNodes(nodes.Items, nmc.MinMemoryGi)
+	if len(memoryErrors) > 0 {
+		for _, e := range memoryErrors {
+			khlog.ForCheck(nmc.Name()).Warningln(e)
+		}
+		nmc.Errors = memoryErrors
+		return nil
+	}
+
+	nmc.clearErrors()
+	return nil
+}
+
+// evaluateNodes returns an error for every node whose memory capacity is
+// below minMemoryGi
+func evaluateNodes(nodes []apiv1.Node, minMemoryGi float64) []string {
+	var memoryErrors []string
+
+	for _, n := range nodes {
+		memory, ok := n.Status.Capacity[apiv1.ResourceMemory]
+		if !ok {
+			memoryErrors = append(memoryErrors, "Node "+n.Name+" does not report its memory capacity")
+			continue
+		}
+
+		capacityGi := float64(memory.Value()) / gibibyte
+		if capacityGi < minMemoryGi {
+			memoryErrors = append(memoryErrors, "Node "+n.Name+" has "+formatGi(capacityGi)+" of memory capacity but at least "+formatGi(minMemoryGi)+" is expected")
+		}
+	}
+	return memoryErrors
+}
+
+// formatGi formats an amount of memory in Gi
+func formatGi(gi float64) string {
+	return strconv.FormatFloat(gi, 'f', 2, 64) + "Gi"
+}
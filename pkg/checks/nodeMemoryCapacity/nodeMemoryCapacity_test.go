@@ -0,0 +1,41 @@
+package nodeMemoryCapacity
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateNodes(t *testing.T) {
+	makeNode := func(memory string) apiv1.Node {
+		node := apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}}
+		if len(memory) > 0 {
+			node.Status.Capacity = apiv1.ResourceList{apiv1.ResourceMemory: resource.MustParse(memory)}
+		}
+		return node
+	}
+
+	var tests = []struct {
+		description string
+		node        apiv1.Node
+		minMemoryGi float64
+		expected    int
+	}{
+		{"more than expected", makeNode("16Gi"), 8, 0},
+		{"exactly expected", makeNode("8Gi"), 8, 0},
+		{"kibibytes above expected", makeNode("16393292Ki"), 15, 0},
+		{"less than expected", makeNode("4Gi"), 8, 1},
+		{"decimal units below expected", makeNode("8G"), 8, 1},
+		{"capacity not reported", makeNode(""), 8, 1},
+	}
+
+	for _, test := range tests {
+		memoryErrors := evaluateNodes([]apiv1.Node{test.node}, test.minMemoryGi)
+		if len(memoryErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", memoryErrors)
+		}
+		t.Log(test.description, memoryErrors)
+	}
+}
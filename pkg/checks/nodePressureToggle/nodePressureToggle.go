@@ -0,0 +1,257 @@
+// Package nodePressureToggle implements a checker that detects nodes whose
+// pressure conditions are repeatedly turning on and off.  Intermittent
+// pressure often resolves itself before other checks notice it.
+package nodePressureToggle // import "github.com/Comcast/kuberhealthy/pkg/checks/nodePressureToggle"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// watchedConditions are the node conditions tracked for transitions
+var watchedConditions = []v1.NodeConditionType{v1.NodeMemoryPressure, v1.NodeDiskPressure}
+
+// conditionObservation is the last observed state of a single node condition
+type conditionObservation struct {
+	Status             v1.ConditionStatus
+	LastTransitionTime time.Time
+}
+
+// Checker validates that node pressure conditions are not flapping
+type Checker struct {
+	Errors          []string
+	LastObserved    map[string]conditionObservation // keyed by node and condition type
+	Transitions     map[string][]time.Time          // keyed by node and condition type
+	ToggleThreshold int
+	ToggleWindow    time.Duration
+	client          *kubernetes.Clientset
+	interval        time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reports a node condition that transitions
+// more than toggleThreshold times within toggleWindow
+func New(toggleThreshold int, toggleWindow time.Duration) *Checker {
+	return &Checker{
+		Errors:          []string{},
+		LastObserved:    make(map[string]conditionObservation),
+		Transitions:     make(map[string][]time.Time),
+		ToggleThreshold: toggleThreshold,
+		ToggleWindow:    toggleWindow,
+	}
+}
+
+// Name returns the name of this checker
+func (npc *Checker) Name() string {
+	return "NodePressureToggleChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (npc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (npc *Checker) Interval() time.Duration {
+	if npc.interval > 0 {
+		return npc.interval
+	}
+	return time.Minute * 1
+}
+
+// SetInterval overrides the interval at which this check runs
+func (npc *Checker) SetInterval(d time.Duration) {
+	npc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Nodes are cluster
+// scoped.
+func (npc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (npc *Checker) Timeout() time.Duration {
+	return time.Second * 30
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (npc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (npc *Checker) CurrentStatus() (bool, []string) {
+	if len(npc.Errors) > 0 {
+		return false, npc.Errors
+	}
+	return true, npc.Errors
+}
+
+// clearErrors clears all errors
+func (npc *Checker) clearErrors() {
+	npc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (npc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	npc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := npc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(npc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + npc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(npc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + npc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + npc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks records node condition transitions and sets errors for any
+// conditions that are flapping
+func (npc *Checker) doChecks() error {
+
+	nodes, err := npc.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	npc.observe(nodes.Items, now)
+	npc.reapTransitions(nodes.Items, now)
+
+	flapping := npc.flappingConditions()
+	if len(flapping) > 0 {
+		for _, f := range flapping {
+			khlog.ForCheck(npc.Name()).Warningln(f)
+		}
+		npc.Errors = flapping
+		return nil
+	}
+
+	npc.clearErrors()
+	return nil
+}
+
+// observationKey makes the map key used to track a node condition
+func observationKey(nodeName string, conditionType v1.NodeConditionType) string {
+	return nodeName + "/" + string(conditionType)
+}
+
+// observe compares the current node conditions against the last observed
+// conditions and records a transition for each one that changed.  A change
+// in the last transition time counts as a transition even if the status
+// toggled back before this run.
+func (npc *Checker) observe(nodes []v1.Node, now time.Time) {
+	for _, n := range nodes {
+		for _, condition := range n.Status.Conditions {
+			if !isWatchedCondition(condition.Type) {
+				continue
+			}
+			key := observationKey(n.Name, condition.Type)
+			current := conditionObservation{
+				Status:             condition.Status,
+				LastTransitionTime: condition.LastTransitionTime.Time,
+			}
+			previous, exists := npc.LastObserved[key]
+			npc.LastObserved[key] = current
+			if !exists {
+				continue
+			}
+			if previous.Status != current.Status || !previous.LastTransitionTime.Equal(current.LastTransitionTime) {
+				khlog.ForCheck(npc.Name()).Debugln("observed transition of", key, "from", previous.Status, "to", current.Status)
+				npc.Transitions[key] = append(npc.Transitions[key], now)
+			}
+		}
+	}
+}
+
+// reapTransitions removes transitions older than the toggle window and
+// forgets nodes that no longer exist
+func (npc *Checker) reapTransitions(nodes []v1.Node, now time.Time) {
+	currentKeys := make(map[string]bool)
+	for _, n := range nodes {
+		for _, c := range watchedConditions {
+			currentKeys[observationKey(n.Name, c)] = true
+		}
+	}
+
+	for key := range npc.LastObserved {
+		if !currentKeys[key] {
+			delete(npc.LastObserved, key)
+		}
+	}
+
+	for key, transitions := range npc.Transitions {
+		if !currentKeys[key] {
+			delete(npc.Transitions, key)
+			continue
+		}
+		var kept []time.Time
+		for _, t := range transitions {
+			if t.After(now.Add(-npc.ToggleWindow)) {
+				kept = append(kept, t)
+			}
+		}
+		if len(kept) == 0 {
+			delete(npc.Transitions, key)
+			continue
+		}
+		npc.Transitions[key] = kept
+	}
+}
+
+// flappingConditions returns an error message for each node condition that
+// transitioned more than the toggle threshold within the toggle window
+func (npc *Checker) flappingConditions() []string {
+	var flapping []string
+	for key, transitions := range npc.Transitions {
+		if len(transitions) <= npc.ToggleThreshold {
+			continue
+		}
+		parts := strings.SplitN(key, "/", 2)
+		flapping = append(flapping, fmt.Sprintf("Node %s condition %s transitioned %d times in the last %s",
+			parts[0], parts[1], len(transitions), npc.ToggleWindow))
+	}
+	sort.Strings(flapping)
+	return flapping
+}
+
+// isWatchedCondition determines if a node condition type is tracked
+func isWatchedCondition(conditionType v1.NodeConditionType) bool {
+	for _, c := range watchedConditions {
+		if c == conditionType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package nodePressureToggle
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makeNode(name string, memoryPressure v1.ConditionStatus, transitionTime time.Time) v1.Node {
+	return v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{
+				{Type: v1.NodeMemoryPressure, Status: memoryPressure, LastTransitionTime: metav1.NewTime(transitionTime)},
+				{Type: v1.NodeDiskPressure, Status: v1.ConditionFalse},
+				{Type: v1.NodeReady, Status: v1.ConditionTrue},
+			},
+		},
+	}
+}
+
+func TestFlappingCondition(t *testing.T) {
+	c := New(3, time.Minute*30)
+	start := time.Now().Add(-time.Minute * 20)
+
+	// the node toggles memory pressure every minute for five minutes
+	statuses := []v1.ConditionStatus{v1.ConditionFalse, v1.ConditionTrue, v1.ConditionFalse, v1.ConditionTrue, v1.ConditionFalse}
+	for i, s := range statuses {
+		now := start.Add(time.Minute * time.Duration(i))
+		nodes := []v1.Node{
+			makeNode("flapping", s, now),
+			makeNode("stable", v1.ConditionFalse, start),
+		}
+		c.observe(nodes, now)
+		c.reapTransitions(nodes, now)
+	}
+
+	flapping := c.flappingConditions()
+	if len(flapping) != 1 {
+		t.Fatal("Expected exactly one flapping condition but got:", flapping)
+	}
+	t.Log(flapping)
+}
+
+func TestTransitionBetweenRuns(t *testing.T) {
+	c := New(0, time.Minute*30)
+	now := time.Now()
+
+	// the status is the same, but the transition time moved indicating the
+	// condition toggled twice between runs
+	c.observe([]v1.Node{makeNode("node", v1.ConditionFalse, now.Add(-time.Hour))}, now.Add(-time.Minute))
+	c.observe([]v1.Node{makeNode("node", v1.ConditionFalse, now.Add(-time.Second*10))}, now)
+
+	if len(c.flappingConditions()) != 1 {
+		t.Fatal("Expected a transition to be recorded when the last transition time changed")
+	}
+}
+
+func TestReapTransitions(t *testing.T) {
+	c := New(1, time.Minute*30)
+	now := time.Now()
+	key := observationKey("node", v1.NodeMemoryPressure)
+	c.Transitions[key] = []time.Time{now.Add(-time.Hour), now.Add(-time.Minute * 45), now.Add(-time.Minute)}
+	c.Transitions[observationKey("deleted", v1.NodeMemoryPressure)] = []time.Time{now}
+
+	c.reapTransitions([]v1.Node{makeNode("node", v1.ConditionFalse, now)}, now)
+
+	if len(c.Transitions[key]) != 1 {
+		t.Fatal("Expected old transitions to be reaped but found", c.Transitions[key])
+	}
+	if _, exists := c.Transitions[observationKey("deleted", v1.NodeMemoryPressure)]; exists {
+		t.Fatal("Expected transitions for deleted node to be reaped")
+	}
+}
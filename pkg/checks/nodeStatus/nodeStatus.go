@@ -0,0 +1,258 @@
+// Package nodeStatus implements a checker that detects nodes that have been
+// NotReady for longer than a grace period.  The likely root cause of each
+// NotReady node is reported so that operators can act without first
+// inspecting the node.
+package nodeStatus // import "github.com/Comcast/kuberhealthy/pkg/checks/nodeStatus"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// masterRoleLabel is set on master nodes
+const masterRoleLabel = "node-role.kubernetes.io/master"
+
+// pressureConditions are the node conditions that make the kubelet report
+// resource pressure
+var pressureConditions = []apiv1.NodeConditionType{apiv1.NodeMemoryPressure, apiv1.NodeDiskPressure, apiv1.NodePIDPressure}
+
+// notReadyTaints are applied by the node lifecycle controller as a result of
+// a node becoming NotReady and are not reported as causes
+var notReadyTaints = map[string]bool{
+	"node.kubernetes.io/not-ready":   true,
+	"node.kubernetes.io/unreachable": true,
+}
+
+// Checker validates that nodes are Ready
+type Checker struct {
+	Errors         []string
+	GracePeriod    time.Duration
+	ExcludeMasters bool
+	client         *kubernetes.Clientset
+	interval       time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reports nodes that have been NotReady for
+// longer than gracePeriod.  Master nodes are skipped when excludeMasters is
+// set.
+func New(gracePeriod time.Duration, excludeMasters bool) *Checker {
+	return &Checker{
+		Errors:         []string{},
+		GracePeriod:    gracePeriod,
+		ExcludeMasters: excludeMasters,
+	}
+}
+
+// Name returns the name of this checker
+func (nsc *Checker) Name() string {
+	return "NodeStatusChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (nsc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (nsc *Checker) Interval() time.Duration {
+	if nsc.interval > 0 {
+		return nsc.interval
+	}
+	return time.Minute * 1
+}
+
+// SetInterval overrides the interval at which this check runs
+func (nsc *Checker) SetInterval(d time.Duration) {
+	nsc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Node conditions are
+// read by listing the cluster scoped nodes.
+func (nsc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (nsc *Checker) Timeout() time.Duration {
+	return time.Second * 30
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (nsc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (nsc *Checker) CurrentStatus() (bool, []string) {
+	if len(nsc.Errors) > 0 {
+		return false, nsc.Errors
+	}
+	return true, nsc.Errors
+}
+
+// clearErrors clears all errors
+func (nsc *Checker) clearErrors() {
+	nsc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (nsc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	nsc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := nsc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(nsc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + nsc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(nsc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + nsc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + nsc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists nodes and sets an error for every node that has been
+// NotReady for longer than the grace period
+func (nsc *Checker) doChecks() error {
+
+	nodes, err := nsc.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing nodes: " + err.Error())
+	}
+
+	nodeErrors := evaluateNodes(nodes.Items, nsc.GracePeriod, nsc.ExcludeMasters, time.Now())
+
+	if len(nodeErrors) > 0 {
+		for _, e := range nodeErrors {
+			khlog.ForCheck(nsc.Name()).Warningln(e)
+		}
+		nsc.Errors = nodeErrors
+		return nil
+	}
+
+	nsc.clearErrors()
+	return nil
+}
+
+// evaluateNodes returns an error describing the root cause for every node
+// whose Ready condition has not been True for longer than the grace period
+func evaluateNodes(nodes []apiv1.Node, gracePeriod time.Duration, excludeMasters bool, now time.Time) []string {
+	var nodeErrors []string
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	for _, n := range nodes {
+		if _, ok := n.Labels[masterRoleLabel]; ok && excludeMasters {
+			continue
+		}
+
+		ready, ok := findCondition(n.Status.Conditions, apiv1.NodeReady)
+		if !ok {
+			nodeErrors = append(nodeErrors, "Node "+n.Name+" has not reported a Ready condition")
+			continue
+		}
+		if ready.Status == apiv1.ConditionTrue {
+			continue
+		}
+		notReadyFor := now.Sub(ready.LastTransitionTime.Time)
+		if notReadyFor < gracePeriod {
+			continue
+		}
+
+		nodeErrors = append(nodeErrors, "Node "+n.Name+" has been NotReady for "+notReadyFor.Round(time.Second).String()+": "+notReadyCause(n, ready, now))
+	}
+	return nodeErrors
+}
+
+// notReadyCause describes the root cause of a node being NotReady.  An
+// Unknown Ready condition means the kubelet stopped posting status, which is
+// most often a network partition.  Otherwise resource pressure, an
+// unavailable network, and taints are reported before falling back to the
+// reason given by the kubelet.
+func notReadyCause(n apiv1.Node, ready apiv1.NodeCondition, now time.Time) string {
+	if ready.Status == apiv1.ConditionUnknown {
+		cause := "no heartbeat from the kubelet"
+		if !ready.LastHeartbeatTime.IsZero() {
+			cause += " for " + now.Sub(ready.LastHeartbeatTime.Time).Round(time.Second).String()
+		}
+		return cause + ", the node may be partitioned from the network"
+	}
+
+	var pressure []string
+	for _, t := range pressureConditions {
+		if c, ok := findCondition(n.Status.Conditions, t); ok && c.Status == apiv1.ConditionTrue {
+			pressure = append(pressure, string(t))
+		}
+	}
+	if len(pressure) > 0 {
+		return "node has " + strings.Join(pressure, ", ")
+	}
+
+	if c, ok := findCondition(n.Status.Conditions, apiv1.NodeNetworkUnavailable); ok && c.Status == apiv1.ConditionTrue {
+		return "node network is unavailable: " + conditionDetail(c)
+	}
+
+	var taints []string
+	for _, t := range n.Spec.Taints {
+		if notReadyTaints[t.Key] || t.Effect == apiv1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		taints = append(taints, t.ToString())
+	}
+	if len(taints) > 0 {
+		return "node is tainted with " + strings.Join(taints, ", ") + ": " + conditionDetail(ready)
+	}
+
+	return conditionDetail(ready)
+}
+
+// conditionDetail formats the reason and message of a condition
+func conditionDetail(c apiv1.NodeCondition) string {
+	switch {
+	case len(c.Reason) > 0 && len(c.Message) > 0:
+		return c.Reason + " " + c.Message
+	case len(c.Reason) > 0:
+		return c.Reason
+	case len(c.Message) > 0:
+		return c.Message
+	}
+	return "no reason was given"
+}
+
+// findCondition returns the condition of a type from a list of conditions
+func findCondition(conditions []apiv1.NodeCondition, conditionType apiv1.NodeConditionType) (apiv1.NodeCondition, bool) {
+	for _, c := range conditions {
+		if c.Type == conditionType {
+			return c, true
+		}
+	}
+	return apiv1.NodeCondition{}, false
+}
@@ -0,0 +1,74 @@
+package nodeStatus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateNodes(t *testing.T) {
+	now := time.Now()
+
+	makeNode := func(readyStatus apiv1.ConditionStatus, notReadyFor time.Duration, conditions ...apiv1.NodeCondition) apiv1.Node {
+		ready := apiv1.NodeCondition{
+			Type:               apiv1.NodeReady,
+			Status:             readyStatus,
+			Reason:             "KubeletNotReady",
+			Message:            "container runtime is down",
+			LastHeartbeatTime:  metav1.NewTime(now.Add(-notReadyFor)),
+			LastTransitionTime: metav1.NewTime(now.Add(-notReadyFor)),
+		}
+		return apiv1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-a", Labels: map[string]string{}},
+			Status:     apiv1.NodeStatus{Conditions: append(conditions, ready)},
+		}
+	}
+	pressure := func(t apiv1.NodeConditionType) apiv1.NodeCondition {
+		return apiv1.NodeCondition{Type: t, Status: apiv1.ConditionTrue}
+	}
+
+	master := makeNode(apiv1.ConditionFalse, time.Minute*5)
+	master.Labels[masterRoleLabel] = ""
+
+	tainted := makeNode(apiv1.ConditionFalse, time.Minute*5)
+	tainted.Spec.Taints = []apiv1.Taint{
+		{Key: "node.kubernetes.io/not-ready", Effect: apiv1.TaintEffectNoSchedule},
+		{Key: "maintenance", Value: "true", Effect: apiv1.TaintEffectNoExecute},
+	}
+
+	var tests = []struct {
+		description    string
+		node           apiv1.Node
+		excludeMasters bool
+		expectedCause  string
+	}{
+		{"ready", makeNode(apiv1.ConditionTrue, time.Hour), false, ""},
+		{"not ready within grace period", makeNode(apiv1.ConditionFalse, time.Minute), false, ""},
+		{"no heartbeat", makeNode(apiv1.ConditionUnknown, time.Minute*5), false, "no heartbeat from the kubelet for 5m0s"},
+		{"memory pressure", makeNode(apiv1.ConditionFalse, time.Minute*5, pressure(apiv1.NodeMemoryPressure)), false, "node has MemoryPressure"},
+		{"memory and disk pressure", makeNode(apiv1.ConditionFalse, time.Minute*5, pressure(apiv1.NodeMemoryPressure), pressure(apiv1.NodeDiskPressure)), false, "node has MemoryPressure, DiskPressure"},
+		{"network unavailable", makeNode(apiv1.ConditionFalse, time.Minute*5, pressure(apiv1.NodeNetworkUnavailable)), false, "node network is unavailable"},
+		{"tainted", tainted, false, "node is tainted with maintenance=true:NoExecute"},
+		{"kubelet reason", makeNode(apiv1.ConditionFalse, time.Minute*5), false, "KubeletNotReady container runtime is down"},
+		{"master included", master, false, "KubeletNotReady"},
+		{"master excluded", master, true, ""},
+		{"no ready condition", apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}}, false, "has not reported a Ready condition"},
+	}
+
+	for _, test := range tests {
+		nodeErrors := evaluateNodes([]apiv1.Node{test.node}, time.Minute*2, test.excludeMasters, now)
+		if len(test.expectedCause) == 0 {
+			if len(nodeErrors) != 0 {
+				t.Fatal("Test", test.description, "expected no errors but got", nodeErrors)
+			}
+			continue
+		}
+		if len(nodeErrors) != 1 || !strings.Contains(nodeErrors[0], test.expectedCause) {
+			t.Fatal("Test", test.description, "expected an error containing", test.expectedCause, "but got", nodeErrors)
+		}
+		t.Log(test.description, nodeErrors)
+	}
+}
@@ -0,0 +1,215 @@
+// Package nodeSystemd implements a checker that ensures required systemd
+// services such as containerd and the kubelet are running on every node.  A
+// DaemonSet pod with host PID access is run on each node to query systemd.
+package nodeSystemd // import "github.com/Comcast/kuberhealthy/pkg/checks/nodeSystemd"
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// Checker validates that required systemd services are running on all nodes
+type Checker struct {
+	Errors   []string
+	Services []string
+	Image    string
+	client   *kubernetes.Clientset
+	// runOnNodes is replaced in tests to inject pod output
+	runOnNodes func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (map[string]string, error)
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that requires the supplied systemd services to
+// be active and running
+func New(services []string) *Checker {
+	return &Checker{
+		Errors:     []string{},
+		Services:   services,
+		Image:      "busybox:1.30",
+		runOnNodes: podRunner.RunOnNodes,
+	}
+}
+
+// Name returns the name of this checker
+func (nsc *Checker) Name() string {
+	return "NodeSystemdChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (nsc *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (nsc *Checker) Interval() time.Duration {
+	if nsc.interval > 0 {
+		return nsc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (nsc *Checker) SetInterval(d time.Duration) {
+	nsc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to inspect systemd on
+// every node with a DaemonSet in the Kuberhealthy namespace
+func (nsc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		namespace: podRunner.RunOnNodesRBACRules(),
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (nsc *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (nsc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (nsc *Checker) CurrentStatus() (bool, []string) {
+	if len(nsc.Errors) > 0 {
+		return false, nsc.Errors
+	}
+	return true, nsc.Errors
+}
+
+// clearErrors clears all errors
+func (nsc *Checker) clearErrors() {
+	nsc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (nsc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	nsc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := nsc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(nsc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + nsc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(nsc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + nsc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + nsc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks queries systemd on every node and sets an error for every
+// required service that is not active and running
+func (nsc *Checker) doChecks() error {
+
+	script := podRunner.Script{
+		Name:       "node-systemd",
+		Image:      nsc.Image,
+		Script:     statusScript(nsc.Services),
+		HostPID:    true,
+		Privileged: true,
+	}
+
+	// leave time to clean up the DaemonSet before the check times out
+	output, err := nsc.runOnNodes(nsc.client, namespace, script, nsc.Timeout()-time.Minute)
+	if err != nil && len(output) == 0 {
+		return err
+	}
+
+	var serviceErrors []string
+	if err != nil {
+		serviceErrors = append(serviceErrors, err.Error())
+	}
+
+	var nodes []string
+	for node := range output {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		for _, e := range evaluateServices(output[node], nsc.Services) {
+			serviceErrors = append(serviceErrors, "Node "+node+" "+e)
+		}
+	}
+
+	if len(serviceErrors) > 0 {
+		for _, e := range serviceErrors {
+			khlog.ForCheck(nsc.Name()).Warningln(e)
+		}
+		nsc.Errors = serviceErrors
+		return nil
+	}
+
+	nsc.clearErrors()
+	return nil
+}
+
+// statusScript builds a script that prints one line per service in the
+// form "<service> ActiveState=<state> SubState=<state>".  The script enters
+// the mount namespace of the host's init process to reach systemd.
+func statusScript(services []string) string {
+	return "for s in " + strings.Join(services, " ") + "; do " +
+		`echo "$s $(nsenter -t 1 -m -- systemctl show -p ActiveState -p SubState $s | tr '\n' ' ')"; ` +
+		"done"
+}
+
+// evaluateServices parses the output of the status script and returns an
+// error for every required service that is not active and running
+func evaluateServices(output string, services []string) []string {
+	var serviceErrors []string
+
+	states := make(map[string]map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		properties := make(map[string]string)
+		for _, f := range fields[1:] {
+			kv := strings.SplitN(f, "=", 2)
+			if len(kv) == 2 {
+				properties[kv[0]] = kv[1]
+			}
+		}
+		states[fields[0]] = properties
+	}
+
+	for _, s := range services {
+		state, ok := states[s]
+		if !ok || len(state["ActiveState"]) == 0 {
+			serviceErrors = append(serviceErrors, "did not report the status of service "+s)
+			continue
+		}
+		if state["ActiveState"] != "active" || state["SubState"] != "running" {
+			serviceErrors = append(serviceErrors, "service "+s+" is "+state["ActiveState"]+" ("+state["SubState"]+") instead of active (running)")
+		}
+	}
+	return serviceErrors
+}
@@ -0,0 +1,72 @@
+package nodeSystemd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestEvaluateServices(t *testing.T) {
+	services := []string{"containerd", "kubelet"}
+
+	var tests = []struct {
+		description string
+		output      string
+		expected    int
+	}{
+		{"all running", "containerd ActiveState=active SubState=running \nkubelet ActiveState=active SubState=running ", 0},
+		{"kubelet failed", "containerd ActiveState=active SubState=running \nkubelet ActiveState=failed SubState=failed ", 1},
+		{"kubelet exited", "containerd ActiveState=active SubState=running \nkubelet ActiveState=active SubState=exited ", 1},
+		{"missing containerd", "kubelet ActiveState=active SubState=running ", 1},
+		{"unknown units", "containerd \nkubelet ", 2},
+	}
+
+	for _, test := range tests {
+		serviceErrors := evaluateServices(test.output, services)
+		if len(serviceErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", serviceErrors)
+		}
+		t.Log(test.description, serviceErrors)
+	}
+}
+
+func TestDoChecks(t *testing.T) {
+	checker := New([]string{"containerd", "kubelet"})
+	checker.runOnNodes = func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (map[string]string, error) {
+		if !script.HostPID {
+			t.Fatal("Expected the script to run with host PID access")
+		}
+		return map[string]string{
+			"node-a": "containerd ActiveState=active SubState=running \nkubelet ActiveState=active SubState=running ",
+			"node-b": "containerd ActiveState=inactive SubState=dead \nkubelet ActiveState=active SubState=running ",
+		}, nil
+	}
+
+	err := checker.doChecks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, checkErrors := checker.CurrentStatus()
+	if ok || len(checkErrors) != 1 {
+		t.Fatal("Expected one error for node-b but got", checkErrors)
+	}
+	t.Log(checkErrors)
+
+	// a partial result reports the timeout along with the collected output
+	checker.runOnNodes = func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (map[string]string, error) {
+		return map[string]string{
+			"node-a": "containerd ActiveState=active SubState=running \nkubelet ActiveState=active SubState=running ",
+		}, errors.New("Timed out waiting for DaemonSet to report from all nodes")
+	}
+	err = checker.doChecks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, checkErrors = checker.CurrentStatus()
+	if len(checkErrors) != 1 {
+		t.Fatal("Expected the timeout to be reported but got", checkErrors)
+	}
+}
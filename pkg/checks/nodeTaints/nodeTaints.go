@@ -0,0 +1,266 @@
+// Package nodeTaints implements a checker that ensures node taints are
+// applied and respected.  Pods that do not tolerate a taint can still be found
+// on a tainted node when they were scheduled before the taint was applied or
+// when they bypassed the scheduler, and nodes that should be reserved for
+// particular workloads, such as GPU nodes, are only reserved when they carry
+// their taint.
+package nodeTaints // import "github.com/Comcast/kuberhealthy/pkg/checks/nodeTaints"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// mirrorPodAnnotation marks the API server copy of a static pod, which is
+// started by the kubelet without regard to taints
+const mirrorPodAnnotation = "kubernetes.io/config.mirror"
+
+// Checker validates node taints and the tolerations of the pods on tainted
+// nodes
+type Checker struct {
+	Errors         []string
+	RequiredTaints []apiv1.Taint
+	client         *kubernetes.Clientset
+	interval       time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker.  Required taints are given as key=value:Effect
+// and must be applied to every node labeled with the same key and value.
+func New(requiredTaints []string) (*Checker, error) {
+	var taints []apiv1.Taint
+	for _, s := range requiredTaints {
+		taint, err := parseTaint(s)
+		if err != nil {
+			return nil, err
+		}
+		taints = append(taints, taint)
+	}
+	return &Checker{
+		Errors:         []string{},
+		RequiredTaints: taints,
+	}, nil
+}
+
+// Name returns the name of this checker
+func (ntc *Checker) Name() string {
+	return "NodeTaintsChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (ntc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (ntc *Checker) Interval() time.Duration {
+	if ntc.interval > 0 {
+		return ntc.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (ntc *Checker) SetInterval(d time.Duration) {
+	ntc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to list nodes and the
+// pods scheduled to them in every namespace
+func (ntc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes", "pods"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (ntc *Checker) Timeout() time.Duration {
+	return time.Minute * 2
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (ntc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (ntc *Checker) CurrentStatus() (bool, []string) {
+	if len(ntc.Errors) > 0 {
+		return false, ntc.Errors
+	}
+	return true, ntc.Errors
+}
+
+// clearErrors clears all errors
+func (ntc *Checker) clearErrors() {
+	ntc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (ntc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	ntc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := ntc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(ntc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + ntc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(ntc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + ntc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + ntc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists nodes and pods and sets an error for every node missing a
+// required taint and every pod on a tainted node that does not tolerate the
+// taint
+func (ntc *Checker) doChecks() error {
+
+	nodes, err := ntc.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing nodes: " + err.Error())
+	}
+	pods, err := ntc.client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing pods: " + err.Error())
+	}
+
+	taintErrors := evaluateNodes(nodes.Items, ntc.RequiredTaints)
+	taintErrors = append(taintErrors, evaluatePods(nodes.Items, pods.Items)...)
+
+	if len(taintErrors) > 0 {
+		for _, e := range taintErrors {
+			khlog.ForCheck(ntc.Name()).Warningln(e)
+		}
+		ntc.Errors = taintErrors
+		return nil
+	}
+
+	ntc.clearErrors()
+	return nil
+}
+
+// evaluateNodes returns an error for every node labeled with the key and
+// value of a required taint that does not carry the taint
+func evaluateNodes(nodes []apiv1.Node, requiredTaints []apiv1.Taint) []string {
+	var taintErrors []string
+
+	for _, n := range nodes {
+		for i := range requiredTaints {
+			required := &requiredTaints[i]
+			value, ok := n.Labels[required.Key]
+			if !ok || value != required.Value {
+				continue
+			}
+
+			var applied bool
+			for j := range n.Spec.Taints {
+				if n.Spec.Taints[j].MatchTaint(required) && n.Spec.Taints[j].Value == required.Value {
+					applied = true
+					break
+				}
+			}
+			if !applied {
+				taintErrors = append(taintErrors, "Node "+n.Name+" is labeled "+required.Key+"="+required.Value+" but is missing taint "+required.ToString())
+			}
+		}
+	}
+	return taintErrors
+}
+
+// evaluatePods returns an error for every pod that is running on a node with
+// a NoSchedule or NoExecute taint it does not tolerate.  Completed pods and
+// static pods are skipped.
+func evaluatePods(nodes []apiv1.Node, pods []apiv1.Pod) []string {
+	var taintErrors []string
+
+	taints := make(map[string][]apiv1.Taint)
+	for _, n := range nodes {
+		for _, t := range n.Spec.Taints {
+			if t.Effect == apiv1.TaintEffectNoSchedule || t.Effect == apiv1.TaintEffectNoExecute {
+				taints[n.Name] = append(taints[n.Name], t)
+			}
+		}
+	}
+
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Namespace+"/"+pods[i].Name < pods[j].Namespace+"/"+pods[j].Name
+	})
+	for _, p := range pods {
+		if p.Status.Phase == apiv1.PodSucceeded || p.Status.Phase == apiv1.PodFailed {
+			continue
+		}
+		if _, ok := p.Annotations[mirrorPodAnnotation]; ok {
+			continue
+		}
+
+		for i := range taints[p.Spec.NodeName] {
+			taint := &taints[p.Spec.NodeName][i]
+			if !tolerates(p.Spec.Tolerations, taint) {
+				taintErrors = append(taintErrors, "Pod "+p.Namespace+"/"+p.Name+" is on node "+p.Spec.NodeName+" but does not tolerate taint "+taint.ToString())
+			}
+		}
+	}
+	return taintErrors
+}
+
+// tolerates determines if any of the tolerations tolerates a taint
+func tolerates(tolerations []apiv1.Toleration, taint *apiv1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTaint parses a taint given as key=value:Effect or key:Effect
+func parseTaint(s string) (apiv1.Taint, error) {
+	var taint apiv1.Taint
+
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return taint, errors.New("taint " + s + " must be given as key=value:Effect")
+	}
+	taint.Effect = apiv1.TaintEffect(s[i+1:])
+	switch taint.Effect {
+	case apiv1.TaintEffectNoSchedule, apiv1.TaintEffectPreferNoSchedule, apiv1.TaintEffectNoExecute:
+	default:
+		return taint, errors.New("taint " + s + " has unknown effect " + string(taint.Effect))
+	}
+
+	parts := strings.SplitN(s[:i], "=", 2)
+	taint.Key = parts[0]
+	if len(parts) == 2 {
+		taint.Value = parts[1]
+	}
+	if len(taint.Key) == 0 {
+		return taint, errors.New("taint " + s + " has no key")
+	}
+	return taint, nil
+}
@@ -0,0 +1,114 @@
+package nodeTaints
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var gpuTaint = apiv1.Taint{Key: "nvidia.com/gpu", Value: "true", Effect: apiv1.TaintEffectNoSchedule}
+
+func makeNode(name string, labels map[string]string, taints ...apiv1.Taint) apiv1.Node {
+	return apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec:       apiv1.NodeSpec{Taints: taints},
+	}
+}
+
+func makePod(name string, nodeName string, tolerations ...apiv1.Toleration) apiv1.Pod {
+	return apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       apiv1.PodSpec{NodeName: nodeName, Tolerations: tolerations},
+		Status:     apiv1.PodStatus{Phase: apiv1.PodRunning},
+	}
+}
+
+func TestEvaluatePods(t *testing.T) {
+	nodes := []apiv1.Node{
+		makeNode("gpu-node", nil, gpuTaint),
+		makeNode("master", nil, apiv1.Taint{Key: "node-role.kubernetes.io/master", Effect: apiv1.TaintEffectNoSchedule}),
+		makeNode("preferred", nil, apiv1.Taint{Key: "spot", Value: "true", Effect: apiv1.TaintEffectPreferNoSchedule}),
+		makeNode("worker", nil),
+	}
+
+	completed := makePod("job", "gpu-node")
+	completed.Status.Phase = apiv1.PodSucceeded
+
+	static := makePod("kube-apiserver-master", "master")
+	static.Annotations = map[string]string{mirrorPodAnnotation: "abc123"}
+
+	var tests = []struct {
+		description string
+		pod         apiv1.Pod
+		expected    int
+	}{
+		{"untainted node", makePod("web", "worker"), 0},
+		{"no toleration", makePod("web", "gpu-node"), 1},
+		{"equal toleration", makePod("train", "gpu-node", apiv1.Toleration{Key: "nvidia.com/gpu", Operator: apiv1.TolerationOpEqual, Value: "true", Effect: apiv1.TaintEffectNoSchedule}), 0},
+		{"wrong value toleration", makePod("train", "gpu-node", apiv1.Toleration{Key: "nvidia.com/gpu", Operator: apiv1.TolerationOpEqual, Value: "false", Effect: apiv1.TaintEffectNoSchedule}), 1},
+		{"exists toleration", makePod("train", "gpu-node", apiv1.Toleration{Key: "nvidia.com/gpu", Operator: apiv1.TolerationOpExists}), 0},
+		{"tolerate everything", makePod("agent", "master", apiv1.Toleration{Operator: apiv1.TolerationOpExists}), 0},
+		{"wrong effect toleration", makePod("web", "master", apiv1.Toleration{Key: "node-role.kubernetes.io/master", Operator: apiv1.TolerationOpExists, Effect: apiv1.TaintEffectNoExecute}), 1},
+		{"prefer no schedule", makePod("web", "preferred"), 0},
+		{"completed pod", completed, 0},
+		{"static pod", static, 0},
+	}
+
+	for _, test := range tests {
+		taintErrors := evaluatePods(nodes, []apiv1.Pod{test.pod})
+		if len(taintErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", taintErrors)
+		}
+		t.Log(test.description, taintErrors)
+	}
+}
+
+func TestEvaluateNodes(t *testing.T) {
+	gpuLabel := map[string]string{"nvidia.com/gpu": "true"}
+
+	var tests = []struct {
+		description string
+		node        apiv1.Node
+		expected    int
+	}{
+		{"labeled and tainted", makeNode("gpu-node", gpuLabel, gpuTaint), 0},
+		{"labeled without taint", makeNode("gpu-node", gpuLabel), 1},
+		{"labeled with wrong effect", makeNode("gpu-node", gpuLabel, apiv1.Taint{Key: "nvidia.com/gpu", Value: "true", Effect: apiv1.TaintEffectPreferNoSchedule}), 1},
+		{"labeled with wrong value", makeNode("gpu-node", gpuLabel, apiv1.Taint{Key: "nvidia.com/gpu", Value: "false", Effect: apiv1.TaintEffectNoSchedule}), 1},
+		{"different label value", makeNode("cpu-node", map[string]string{"nvidia.com/gpu": "false"}), 0},
+		{"unlabeled", makeNode("worker", nil), 0},
+	}
+
+	for _, test := range tests {
+		taintErrors := evaluateNodes([]apiv1.Node{test.node}, []apiv1.Taint{gpuTaint})
+		if len(taintErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", taintErrors)
+		}
+		t.Log(test.description, taintErrors)
+	}
+}
+
+func TestParseTaint(t *testing.T) {
+	var tests = []struct {
+		taint     string
+		expected  apiv1.Taint
+		expectErr bool
+	}{
+		{"nvidia.com/gpu=true:NoSchedule", gpuTaint, false},
+		{"dedicated:NoExecute", apiv1.Taint{Key: "dedicated", Effect: apiv1.TaintEffectNoExecute}, false},
+		{"nvidia.com/gpu=true", apiv1.Taint{}, true},
+		{"nvidia.com/gpu=true:Sometimes", apiv1.Taint{}, true},
+		{"=true:NoSchedule", apiv1.Taint{}, true},
+	}
+
+	for _, test := range tests {
+		taint, err := parseTaint(test.taint)
+		if (err != nil) != test.expectErr {
+			t.Fatal("Test", test.taint, "expected error", test.expectErr, "but got", err)
+		}
+		if err == nil && taint != test.expected {
+			t.Fatal("Test", test.taint, "expected", test.expected, "but got", taint)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Package ntpSync implements a checker that ensures the clock of every node
+// is synchronized with NTP.  Nodes with drifting clocks cause certificate
+// validation, lease and log correlation problems.  A DaemonSet pod with host
+// PID access is run on each node to query the host's time sync service.
+package ntpSync // import "github.com/Comcast/kuberhealthy/pkg/checks/ntpSync"
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// syncScript prints the time sync status of the host.  The script enters
+// the mount namespace of the host's init process to reach timedatectl and
+// chronyc.  It prints the NTP and NTPSynchronized properties, whether the
+// systemd-timesyncd synchronized flag file exists, the offset line of
+// timedatectl timesync-status and the system time and leap status lines of
+// chronyc tracking.
+// Commands that are not available print nothing.
+const syncScript = `echo "SynchronizedFile=$(if nsenter -t 1 -m -- test -e /run/systemd/timesync/synchronized; then echo yes; else echo no; fi)"; ` +
+	`nsenter -t 1 -m -- timedatectl show -p NTP -p NTPSynchronized 2>/dev/null; ` +
+	`nsenter -t 1 -m -- timedatectl timesync-status 2>/dev/null | grep 'Offset:'; ` +
+	`nsenter -t 1 -m -- chronyc tracking 2>/dev/null | grep -E '^(System time|Leap status)'; ` +
+	`true`
+
+// Checker validates that the clocks of all nodes are synchronized with NTP
+type Checker struct {
+	Errors    []string
+	MaxOffset time.Duration
+	Image     string
+	client    *kubernetes.Clientset
+	// runOnNodes is replaced in tests to inject pod output
+	runOnNodes func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (map[string]string, error)
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reports nodes not synchronized with NTP or
+// with a clock offset larger than maxOffset
+func New(maxOffset time.Duration) *Checker {
+	return &Checker{
+		Errors:     []string{},
+		MaxOffset:  maxOffset,
+		Image:      "busybox:1.30",
+		runOnNodes: podRunner.RunOnNodes,
+	}
+}
+
+// Name returns the name of this checker
+func (ntc *Checker) Name() string {
+	return "NTPSyncChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (ntc *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (ntc *Checker) Interval() time.Duration {
+	if ntc.interval > 0 {
+		return ntc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (ntc *Checker) SetInterval(d time.Duration) {
+	ntc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  The time sync status
+// is read on each node by a DaemonSet in the Kuberhealthy namespace.
+func (ntc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		namespace: podRunner.RunOnNodesRBACRules(),
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (ntc *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (ntc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (ntc *Checker) CurrentStatus() (bool, []string) {
+	if len(ntc.Errors) > 0 {
+		return false, ntc.Errors
+	}
+	return true, ntc.Errors
+}
+
+// clearErrors clears all errors
+func (ntc *Checker) clearErrors() {
+	ntc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (ntc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	ntc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := ntc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(ntc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + ntc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(ntc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + ntc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + ntc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks runs the sync script on every node and sets an error for every
+// node that is not synchronized with NTP or has too large a clock offset
+func (ntc *Checker) doChecks() error {
+
+	script := podRunner.Script{
+		Name:       "ntp-sync",
+		Image:      ntc.Image,
+		Script:     syncScript,
+		HostPID:    true,
+		Privileged: true,
+	}
+
+	// leave time to clean up the DaemonSet before the check times out
+	output, err := ntc.runOnNodes(ntc.client, namespace, script, ntc.Timeout()-time.Minute)
+	if err != nil && len(output) == 0 {
+		return err
+	}
+
+	var syncErrors []string
+	if err != nil {
+		syncErrors = append(syncErrors, err.Error())
+	}
+
+	var nodes []string
+	for node := range output {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		for _, e := range evaluateSync(output[node], ntc.MaxOffset) {
+			syncErrors = append(syncErrors, "Node "+node+" "+e)
+		}
+	}
+
+	if len(syncErrors) > 0 {
+		for _, e := range syncErrors {
+			khlog.ForCheck(ntc.Name()).Warningln(e)
+		}
+		ntc.Errors = syncErrors
+		return nil
+	}
+
+	ntc.clearErrors()
+	return nil
+}
+
+// evaluateSync parses the output of the sync script and returns an error
+// when NTP is disabled, the clock is not synchronized, or the clock offset
+// is larger than maxOffset.  The offset is only checked when the host
+// reports it, which requires systemd-timesyncd or chrony.
+func evaluateSync(output string, maxOffset time.Duration) []string {
+	var syncErrors []string
+
+	properties := make(map[string]string)
+	var offset time.Duration
+	var offsetFound bool
+	var leapStatus string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "Offset:"):
+			d, err := parseTimesyncOffset(strings.TrimPrefix(line, "Offset:"))
+			if err != nil {
+				syncErrors = append(syncErrors, "reported an invalid clock offset: "+err.Error())
+				continue
+			}
+			offset, offsetFound = d, true
+		case strings.HasPrefix(line, "System time"):
+			d, err := parseChronyOffset(line)
+			if err != nil {
+				syncErrors = append(syncErrors, "reported an invalid clock offset: "+err.Error())
+				continue
+			}
+			offset, offsetFound = d, true
+		case strings.HasPrefix(line, "Leap status"):
+			parts := strings.SplitN(line, ":", 2)
+			if len(parts) == 2 {
+				leapStatus = strings.TrimSpace(parts[1])
+			}
+		default:
+			kv := strings.SplitN(line, "=", 2)
+			if len(kv) == 2 {
+				properties[kv[0]] = kv[1]
+			}
+		}
+	}
+
+	if len(properties["SynchronizedFile"]) == 0 {
+		return append(syncErrors, "did not report its time synchronization status")
+	}
+	// timedatectl, systemd-timesyncd and chrony each report synchronization
+	// and any of them is enough
+	synchronized := properties["NTPSynchronized"] == "yes" || properties["SynchronizedFile"] == "yes" || leapStatus == "Normal"
+	if properties["NTP"] == "no" {
+		syncErrors = append(syncErrors, "has NTP time synchronization disabled")
+	} else if !synchronized {
+		syncErrors = append(syncErrors, "clock is not synchronized with NTP")
+	}
+
+	if offset < 0 {
+		offset = -offset
+	}
+	if offsetFound && offset > maxOffset {
+		syncErrors = append(syncErrors, "clock is offset from NTP by "+offset.String()+" which is more than the maximum of "+maxOffset.String())
+	}
+	return syncErrors
+}
+
+// parseTimesyncOffset parses an offset printed by timedatectl
+// timesync-status, such as "+1.234ms", "-56us" or "+1min 2.5s"
+func parseTimesyncOffset(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "+")
+	s = strings.Replace(s, "min", "m", -1)
+	s = strings.Replace(s, " ", "", -1)
+	return time.ParseDuration(s)
+}
+
+// parseChronyOffset parses the system time line of chronyc tracking, such
+// as "System time     : 0.000012345 seconds fast of NTP time"
+func parseChronyOffset(line string) (time.Duration, error) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return 0, errors.New("unexpected chronyc output: " + line)
+	}
+	fields := strings.Fields(parts[1])
+	if len(fields) == 0 {
+		return 0, errors.New("unexpected chronyc output: " + line)
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,67 @@
+package ntpSync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestEvaluateSync(t *testing.T) {
+	var tests = []struct {
+		description   string
+		output        string
+		expectedError string
+	}{
+		{"timesyncd synchronized", "SynchronizedFile=yes\nNTP=yes\nNTPSynchronized=yes\n       Offset: +1.234ms\n", ""},
+		{"timesyncd flag file only", "SynchronizedFile=yes\n", ""},
+		{"chrony synchronized", "SynchronizedFile=no\nNTP=yes\nNTPSynchronized=yes\nSystem time     : 0.000012345 seconds fast of NTP time\nLeap status     : Normal\n", ""},
+		{"chrony without timedatectl", "SynchronizedFile=no\nSystem time     : 0.000012345 seconds slow of NTP time\nLeap status     : Normal\n", ""},
+		{"chrony not synchronized", "SynchronizedFile=no\nSystem time     : 0.000000000 seconds fast of NTP time\nLeap status     : Not synchronised\n", "clock is not synchronized with NTP"},
+		{"not synchronized", "SynchronizedFile=no\nNTP=yes\nNTPSynchronized=no\n", "clock is not synchronized with NTP"},
+		{"NTP disabled", "SynchronizedFile=no\nNTP=no\nNTPSynchronized=no\n", "has NTP time synchronization disabled"},
+		{"timesyncd offset too large", "SynchronizedFile=yes\nNTP=yes\nNTPSynchronized=yes\n       Offset: -1min 2.5s\n", "clock is offset from NTP by 1m2.5s which is more than the maximum of 1s"},
+		{"chrony offset too large", "SynchronizedFile=no\nNTPSynchronized=yes\nSystem time     : 2.500000000 seconds slow of NTP time\nLeap status     : Normal\n", "clock is offset from NTP by 2.5s"},
+		{"invalid offset", "SynchronizedFile=yes\n       Offset: soon\n", "reported an invalid clock offset"},
+		{"no status", "nsenter: can't open '/proc/1/ns/mnt': Permission denied\n", "did not report its time synchronization status"},
+	}
+
+	for _, test := range tests {
+		syncErrors := evaluateSync(test.output, time.Second)
+		if len(test.expectedError) == 0 {
+			if len(syncErrors) != 0 {
+				t.Fatal("Test", test.description, "expected no errors but got", syncErrors)
+			}
+			continue
+		}
+		if len(syncErrors) != 1 || !strings.Contains(syncErrors[0], test.expectedError) {
+			t.Fatal("Test", test.description, "expected an error containing", test.expectedError, "but got", syncErrors)
+		}
+		t.Log(test.description, syncErrors)
+	}
+}
+
+func TestDoChecks(t *testing.T) {
+	checker := New(time.Second)
+	checker.runOnNodes = func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (map[string]string, error) {
+		if !script.HostPID || !script.Privileged {
+			t.Fatal("Expected the script to run privileged with host PID access")
+		}
+		return map[string]string{
+			"node-a": "SynchronizedFile=yes\nNTP=yes\nNTPSynchronized=yes\n",
+			"node-b": "SynchronizedFile=no\nNTP=yes\nNTPSynchronized=no\n",
+		}, nil
+	}
+
+	err := checker.doChecks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, checkErrors := checker.CurrentStatus()
+	if ok || len(checkErrors) != 1 || !strings.HasPrefix(checkErrors[0], "Node node-b ") {
+		t.Fatal("Expected one error for node-b but got", checkErrors)
+	}
+	t.Log(checkErrors)
+}
@@ -0,0 +1,241 @@
+// Package pdbValidity implements a checker that finds PodDisruptionBudgets
+// that provide no protection.  This includes budgets that allow every pod to
+// be evicted at once and budgets whose selector matches no pods.
+package pdbValidity // import "github.com/Comcast/kuberhealthy/pkg/checks/pdbValidity"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Checker validates that PodDisruptionBudgets protect their pods
+type Checker struct {
+	Errors     []string
+	Namespaces []string
+	client     *kubernetes.Clientset
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied
+func New(namespaces []string) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		Errors:     []string{},
+		Namespaces: namespaces,
+	}
+}
+
+// Name returns the name of this checker
+func (pvc *Checker) Name() string {
+	return "PDBValidityChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (pvc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (pvc *Checker) Interval() time.Duration {
+	if pvc.interval > 0 {
+		return pvc.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (pvc *Checker) SetInterval(d time.Duration) {
+	pvc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to match disruption
+// budgets with the pods they select in each checked namespace
+func (pvc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range pvc.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{"policy"}, Resources: []string{"poddisruptionbudgets"}, Verbs: []string{"list"}},
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		}
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (pvc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (pvc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (pvc *Checker) CurrentStatus() (bool, []string) {
+	if len(pvc.Errors) > 0 {
+		return false, pvc.Errors
+	}
+	return true, pvc.Errors
+}
+
+// clearErrors clears all errors
+func (pvc *Checker) clearErrors() {
+	pvc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (pvc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	pvc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := pvc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(pvc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + pvc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(pvc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + pvc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + pvc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists PodDisruptionBudgets and pods in each namespace and sets an
+// error for every budget that provides no protection
+func (pvc *Checker) doChecks() error {
+
+	var pdbErrors []string
+	for _, ns := range pvc.Namespaces {
+		pdbs, err := pvc.client.PolicyV1beta1().PodDisruptionBudgets(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		if len(pdbs.Items) == 0 {
+			continue
+		}
+		pods, err := pvc.client.CoreV1().Pods(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		e, err := evaluatePDBs(pdbs.Items, pods.Items)
+		if err != nil {
+			return err
+		}
+		pdbErrors = append(pdbErrors, e...)
+	}
+
+	if len(pdbErrors) > 0 {
+		for _, e := range pdbErrors {
+			khlog.ForCheck(pvc.Name()).Warningln(e)
+		}
+		pvc.Errors = pdbErrors
+		return nil
+	}
+
+	pvc.clearErrors()
+	return nil
+}
+
+// evaluatePDBs returns an error for each PodDisruptionBudget that matches no
+// pods or allows all of its pods to be disrupted at once
+func evaluatePDBs(pdbs []policyv1beta1.PodDisruptionBudget, pods []apiv1.Pod) ([]string, error) {
+	var pdbErrors []string
+
+	for _, pdb := range pdbs {
+		description := "PodDisruptionBudget " + pdb.Namespace + "/" + pdb.Name
+		if pdb.Spec.Selector == nil {
+			pdbErrors = append(pdbErrors, description+" has no selector and matches no pods")
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil {
+			return pdbErrors, errors.New("Error parsing selector of " + description + ": " + err.Error())
+		}
+
+		expected := countMatchingPods(pdb.Namespace, selector, pods)
+		if expected == 0 {
+			pdbErrors = append(pdbErrors, description+" selector "+selector.String()+" matches no pods")
+			continue
+		}
+
+		allowed, calculation, err := allowedDisruptions(pdb.Spec, expected)
+		if err != nil {
+			return pdbErrors, errors.New("Error calculating allowed disruptions of " + description + ": " + err.Error())
+		}
+		if allowed >= expected {
+			pdbErrors = append(pdbErrors, description+" allows all "+fmt.Sprint(expected)+" pods to be evicted simultaneously ("+calculation+")")
+		}
+	}
+	return pdbErrors, nil
+}
+
+// countMatchingPods counts the pods in a namespace matched by a selector
+// that have not completed
+func countMatchingPods(namespace string, selector labels.Selector, pods []apiv1.Pod) int {
+	var count int
+	for _, p := range pods {
+		if p.Namespace != namespace || p.Status.Phase == apiv1.PodSucceeded || p.Status.Phase == apiv1.PodFailed {
+			continue
+		}
+		if selector.Matches(labels.Set(p.Labels)) {
+			count++
+		}
+	}
+	return count
+}
+
+// allowedDisruptions calculates how many of the expected pods may be
+// disrupted the same way the disruption controller does, along with a
+// description of the calculation
+func allowedDisruptions(spec policyv1beta1.PodDisruptionBudgetSpec, expected int) (int, string, error) {
+	if spec.MaxUnavailable != nil {
+		maxUnavailable, err := intstr.GetValueFromIntOrPercent(spec.MaxUnavailable, expected, true)
+		if err != nil {
+			return 0, "", err
+		}
+		return maxUnavailable, fmt.Sprintf("maxUnavailable %s of %d pods allows %d disruptions", spec.MaxUnavailable.String(), expected, maxUnavailable), nil
+	}
+
+	if spec.MinAvailable != nil {
+		minAvailable, err := intstr.GetValueFromIntOrPercent(spec.MinAvailable, expected, true)
+		if err != nil {
+			return 0, "", err
+		}
+		allowed := expected - minAvailable
+		if allowed < 0 {
+			allowed = 0
+		}
+		return allowed, fmt.Sprintf("minAvailable %s of %d pods allows %d disruptions", spec.MinAvailable.String(), expected, allowed), nil
+	}
+
+	// a budget without either field defaults to minAvailable of 1
+	return expected - 1, fmt.Sprintf("default minAvailable 1 of %d pods allows %d disruptions", expected, expected-1), nil
+}
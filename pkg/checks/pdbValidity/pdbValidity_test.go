@@ -0,0 +1,70 @@
+package pdbValidity
+
+import (
+	"strconv"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func makePods(app string, count int) []apiv1.Pod {
+	var pods []apiv1.Pod
+	for i := 0; i < count; i++ {
+		pods = append(pods, apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: app + "-" + strconv.Itoa(i), Labels: map[string]string{"app": app}},
+			Status:     apiv1.PodStatus{Phase: apiv1.PodRunning},
+		})
+	}
+	return pods
+}
+
+func makePDB(name string, app string, minAvailable *intstr.IntOrString, maxUnavailable *intstr.IntOrString) policyv1beta1.PodDisruptionBudget {
+	return policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			Selector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}},
+			MinAvailable:   minAvailable,
+			MaxUnavailable: maxUnavailable,
+		},
+	}
+}
+
+func intOrString(s string) *intstr.IntOrString {
+	v := intstr.Parse(s)
+	return &v
+}
+
+func TestEvaluatePDBs(t *testing.T) {
+	pods := append(makePods("web", 3), makePods("db", 1)...)
+
+	var tests = []struct {
+		description string
+		pdb         policyv1beta1.PodDisruptionBudget
+		expected    int
+	}{
+		{"minAvailable 2 of 3", makePDB("web", "web", intOrString("2"), nil), 0},
+		{"minAvailable 0", makePDB("web", "web", intOrString("0"), nil), 1},
+		{"minAvailable 0%", makePDB("web", "web", intOrString("0%"), nil), 1},
+		{"minAvailable 50%", makePDB("web", "web", intOrString("50%"), nil), 0},
+		{"maxUnavailable 1", makePDB("web", "web", nil, intOrString("1")), 0},
+		{"maxUnavailable 100%", makePDB("web", "web", nil, intOrString("100%")), 1},
+		{"maxUnavailable 5 of 3", makePDB("web", "web", nil, intOrString("5")), 1},
+		{"maxUnavailable 1 of 1", makePDB("db", "db", nil, intOrString("1")), 1},
+		{"minAvailable 1 of 1", makePDB("db", "db", intOrString("1"), nil), 0},
+		{"orphaned", makePDB("cache", "cache", intOrString("1"), nil), 1},
+	}
+
+	for _, test := range tests {
+		pdbErrors, err := evaluatePDBs([]policyv1beta1.PodDisruptionBudget{test.pdb}, pods)
+		if err != nil {
+			t.Fatal("Test", test.description, "returned an error:", err)
+		}
+		if len(pdbErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", pdbErrors)
+		}
+		t.Log(test.description, pdbErrors)
+	}
+}
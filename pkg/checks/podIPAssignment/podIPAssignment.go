@@ -0,0 +1,181 @@
+// Package podIPAssignment implements a checker that finds running pods that
+// have not been assigned an IP address.  This catches cases where IPAM
+// allocated an address but the pod status was never updated, leaving the pod
+// unable to communicate.
+package podIPAssignment // import "github.com/Comcast/kuberhealthy/pkg/checks/podIPAssignment"
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Checker validates that running pods have IP addresses
+type Checker struct {
+	Errors      []string
+	Namespaces  []string
+	GracePeriod time.Duration
+	client      *kubernetes.Clientset
+	interval    time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied.  Pods that started within the grace period are not
+// checked.
+func New(namespaces []string, gracePeriod time.Duration) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		Errors:      []string{},
+		Namespaces:  namespaces,
+		GracePeriod: gracePeriod,
+	}
+}
+
+// Name returns the name of this checker
+func (pic *Checker) Name() string {
+	return "PodIPAssignmentChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (pic *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (pic *Checker) Interval() time.Duration {
+	if pic.interval > 0 {
+		return pic.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (pic *Checker) SetInterval(d time.Duration) {
+	pic.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to list pods and their
+// IPs in the checked namespaces
+func (pic *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range pic.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		}
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (pic *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (pic *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (pic *Checker) CurrentStatus() (bool, []string) {
+	if len(pic.Errors) > 0 {
+		return false, pic.Errors
+	}
+	return true, pic.Errors
+}
+
+// clearErrors clears all errors
+func (pic *Checker) clearErrors() {
+	pic.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (pic *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	pic.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := pic.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(pic.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + pic.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(pic.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + pic.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + pic.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists running pods in each namespace and sets an error for every
+// pod without an IP address
+func (pic *Checker) doChecks() error {
+
+	var ipErrors []string
+	for _, ns := range pic.Namespaces {
+		pods, err := pic.client.CoreV1().Pods(ns).List(metav1.ListOptions{
+			FieldSelector: "status.phase=" + string(apiv1.PodRunning),
+		})
+		if err != nil {
+			return err
+		}
+		ipErrors = append(ipErrors, evaluatePods(pods.Items, pic.GracePeriod, time.Now())...)
+	}
+
+	if len(ipErrors) > 0 {
+		for _, e := range ipErrors {
+			khlog.ForCheck(pic.Name()).Warningln(e)
+		}
+		pic.Errors = ipErrors
+		return nil
+	}
+
+	pic.clearErrors()
+	return nil
+}
+
+// evaluatePods returns an error for every running pod that has no IP address
+// and started longer than the grace period ago.  The client API predates the
+// status.podIPs field, but its first entry is always the same as
+// status.podIP, so an empty podIP also covers an empty podIPs list.
+func evaluatePods(pods []apiv1.Pod, gracePeriod time.Duration, now time.Time) []string {
+	var ipErrors []string
+
+	for _, p := range pods {
+		if p.Status.Phase != apiv1.PodRunning || len(p.Status.PodIP) > 0 {
+			continue
+		}
+
+		started := p.CreationTimestamp.Time
+		if p.Status.StartTime != nil {
+			started = p.Status.StartTime.Time
+		}
+		age := now.Sub(started)
+		if age < gracePeriod {
+			continue
+		}
+
+		ipErrors = append(ipErrors, "Pod "+p.Namespace+"/"+p.Name+" on node "+p.Spec.NodeName+" has been running for "+age.Round(time.Second).String()+" without an IP address")
+	}
+	return ipErrors
+}
@@ -0,0 +1,46 @@
+package podIPAssignment
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluatePods(t *testing.T) {
+	now := time.Now()
+
+	makePod := func(phase apiv1.PodPhase, podIP string, age time.Duration) apiv1.Pod {
+		started := metav1.NewTime(now.Add(-age))
+		return apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", CreationTimestamp: started},
+			Spec:       apiv1.PodSpec{NodeName: "node-a"},
+			Status:     apiv1.PodStatus{Phase: phase, PodIP: podIP, StartTime: &started},
+		}
+	}
+
+	noStartTime := makePod(apiv1.PodRunning, "", time.Minute*10)
+	noStartTime.Status.StartTime = nil
+
+	var tests = []struct {
+		description string
+		pod         apiv1.Pod
+		expected    int
+	}{
+		{"running with ip", makePod(apiv1.PodRunning, "10.244.1.5", time.Minute*10), 0},
+		{"running without ip", makePod(apiv1.PodRunning, "", time.Minute*10), 1},
+		{"running without ip within grace period", makePod(apiv1.PodRunning, "", time.Second*30), 0},
+		{"pending without ip", makePod(apiv1.PodPending, "", time.Minute*10), 0},
+		{"succeeded without ip", makePod(apiv1.PodSucceeded, "", time.Minute*10), 0},
+		{"no start time uses creation time", noStartTime, 1},
+	}
+
+	for _, test := range tests {
+		ipErrors := evaluatePods([]apiv1.Pod{test.pod}, time.Minute*2, now)
+		if len(ipErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", ipErrors)
+		}
+		t.Log(test.description, ipErrors)
+	}
+}
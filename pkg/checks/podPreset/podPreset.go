@@ -0,0 +1,273 @@
+// Package podPreset implements a checker that finds PodPresets which inject
+// conflicting environment variables or volume mounts into the same pods.
+// Clusters that do not serve the deprecated PodPreset API are skipped.
+package podPreset // import "github.com/Comcast/kuberhealthy/pkg/checks/podPreset"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	settingsv1alpha1 "k8s.io/api/settings/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Checker validates that PodPresets do not conflict with each other
+type Checker struct {
+	Errors   []string
+	client   *kubernetes.Clientset
+	interval time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker
+func New() *Checker {
+	return &Checker{
+		Errors: []string{},
+	}
+}
+
+// Name returns the name of this checker
+func (ppc *Checker) Name() string {
+	return "PodPresetChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (ppc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (ppc *Checker) Interval() time.Duration {
+	if ppc.interval > 0 {
+		return ppc.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (ppc *Checker) SetInterval(d time.Duration) {
+	ppc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to list PodPresets in
+// every namespace
+func (ppc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{settingsv1alpha1.GroupName}, Resources: []string{"podpresets"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (ppc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (ppc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (ppc *Checker) CurrentStatus() (bool, []string) {
+	if len(ppc.Errors) > 0 {
+		return false, ppc.Errors
+	}
+	return true, ppc.Errors
+}
+
+// clearErrors clears all errors
+func (ppc *Checker) clearErrors() {
+	ppc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (ppc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	ppc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := ppc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(ppc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + ppc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(ppc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + ppc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + ppc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists all PodPresets and sets errors for any that conflict
+func (ppc *Checker) doChecks() error {
+
+	if !ppc.podPresetsServed() {
+		khlog.ForCheck(ppc.Name()).Debugln("PodPreset API is not enabled. Skipping check.")
+		ppc.clearErrors()
+		return nil
+	}
+
+	presets, err := ppc.client.SettingsV1alpha1().PodPresets(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	conflicts, err := findConflicts(presets.Items)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		for _, c := range conflicts {
+			khlog.ForCheck(ppc.Name()).Warningln(c)
+		}
+		ppc.Errors = conflicts
+		return nil
+	}
+
+	ppc.clearErrors()
+	return nil
+}
+
+// podPresetsServed uses discovery to determine if the api server serves
+// the PodPreset API
+func (ppc *Checker) podPresetsServed() bool {
+	resources, err := ppc.client.Discovery().ServerResourcesForGroupVersion(settingsv1alpha1.SchemeGroupVersion.String())
+	if err != nil {
+		return false
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == "podpresets" {
+			return true
+		}
+	}
+	return false
+}
+
+// findConflicts compares every pair of PodPresets in the same namespace.  If
+// their selectors can match the same pod, any environment variable name or
+// volume mount path injected by both is reported as a conflict.
+func findConflicts(presets []settingsv1alpha1.PodPreset) ([]string, error) {
+	var conflicts []string
+
+	// sort so that conflicts are reported in a stable order
+	sort.Slice(presets, func(i, j int) bool {
+		if presets[i].Namespace != presets[j].Namespace {
+			return presets[i].Namespace < presets[j].Namespace
+		}
+		return presets[i].Name < presets[j].Name
+	})
+
+	for i := range presets {
+		for j := i + 1; j < len(presets); j++ {
+			a := presets[i]
+			b := presets[j]
+			if a.Namespace != b.Namespace {
+				continue
+			}
+			overlap, err := selectorsOverlap(a.Spec.Selector, b.Spec.Selector)
+			if err != nil {
+				return conflicts, errors.New("Error parsing selector for PodPresets " + a.Namespace + "/" + a.Name + " and " + b.Name + ": " + err.Error())
+			}
+			if !overlap {
+				continue
+			}
+
+			prefix := "PodPresets " + a.Namespace + "/" + a.Name + " and " + a.Namespace + "/" + b.Name
+			for _, name := range sharedEnvNames(a, b) {
+				conflicts = append(conflicts, prefix+" both inject environment variable "+name)
+			}
+			for _, path := range sharedMountPaths(a, b) {
+				conflicts = append(conflicts, prefix+" both inject a volume mount at "+path)
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// selectorsOverlap determines if both selectors can match the same pod.  This
+// is the case when the requirements of one selector are a subset of the
+// requirements of the other, since any pod matching the larger selector
+// will then also match the smaller one.
+func selectorsOverlap(a metav1.LabelSelector, b metav1.LabelSelector) (bool, error) {
+	aReqs, err := selectorRequirements(a)
+	if err != nil {
+		return false, err
+	}
+	bReqs, err := selectorRequirements(b)
+	if err != nil {
+		return false, err
+	}
+	return isSubset(aReqs, bReqs) || isSubset(bReqs, aReqs), nil
+}
+
+// selectorRequirements returns the set of requirements of a label selector
+func selectorRequirements(ls metav1.LabelSelector) (map[string]bool, error) {
+	reqs := make(map[string]bool)
+	selector, err := metav1.LabelSelectorAsSelector(&ls)
+	if err != nil {
+		return reqs, err
+	}
+	requirements, _ := selector.Requirements()
+	for _, r := range requirements {
+		reqs[r.String()] = true
+	}
+	return reqs, nil
+}
+
+// isSubset determines if every key in a is also in b
+func isSubset(a map[string]bool, b map[string]bool) bool {
+	for k := range a {
+		if !b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// sharedEnvNames returns the environment variable names injected by both presets
+func sharedEnvNames(a settingsv1alpha1.PodPreset, b settingsv1alpha1.PodPreset) []string {
+	var shared []string
+	names := make(map[string]bool)
+	for _, e := range a.Spec.Env {
+		names[e.Name] = true
+	}
+	for _, e := range b.Spec.Env {
+		if names[e.Name] {
+			shared = append(shared, e.Name)
+		}
+	}
+	return shared
+}
+
+// sharedMountPaths returns the volume mount paths injected by both presets
+func sharedMountPaths(a settingsv1alpha1.PodPreset, b settingsv1alpha1.PodPreset) []string {
+	var shared []string
+	paths := make(map[string]bool)
+	for _, m := range a.Spec.VolumeMounts {
+		paths[m.MountPath] = true
+	}
+	for _, m := range b.Spec.VolumeMounts {
+		if paths[m.MountPath] {
+			shared = append(shared, m.MountPath)
+		}
+	}
+	return shared
+}
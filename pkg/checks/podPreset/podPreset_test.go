@@ -0,0 +1,94 @@
+package podPreset
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	settingsv1alpha1 "k8s.io/api/settings/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makePreset(namespace string, name string, labels map[string]string, env []string, mounts []string) settingsv1alpha1.PodPreset {
+	pp := settingsv1alpha1.PodPreset{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: settingsv1alpha1.PodPresetSpec{
+			Selector: metav1.LabelSelector{MatchLabels: labels},
+		},
+	}
+	for _, e := range env {
+		pp.Spec.Env = append(pp.Spec.Env, apiv1.EnvVar{Name: e, Value: name})
+	}
+	for _, m := range mounts {
+		pp.Spec.VolumeMounts = append(pp.Spec.VolumeMounts, apiv1.VolumeMount{Name: name, MountPath: m})
+	}
+	return pp
+}
+
+func TestFindConflicts(t *testing.T) {
+
+	var tests = []struct {
+		description string
+		presets     []settingsv1alpha1.PodPreset
+		expected    int
+	}{
+		{
+			"same selector with different injections",
+			[]settingsv1alpha1.PodPreset{
+				makePreset("default", "a", map[string]string{"app": "web"}, []string{"DB_HOST"}, []string{"/etc/db"}),
+				makePreset("default", "b", map[string]string{"app": "web"}, []string{"CACHE_HOST"}, []string{"/etc/cache"}),
+			},
+			0,
+		},
+		{
+			"same selector with the same env var and mount path",
+			[]settingsv1alpha1.PodPreset{
+				makePreset("default", "a", map[string]string{"app": "web"}, []string{"DB_HOST"}, []string{"/etc/db"}),
+				makePreset("default", "b", map[string]string{"app": "web"}, []string{"DB_HOST"}, []string{"/etc/db"}),
+			},
+			2,
+		},
+		{
+			"broader selector overlaps narrower selector",
+			[]settingsv1alpha1.PodPreset{
+				makePreset("default", "a", map[string]string{"app": "web"}, []string{"DB_HOST"}, nil),
+				makePreset("default", "b", map[string]string{"app": "web", "tier": "frontend"}, []string{"DB_HOST"}, nil),
+			},
+			1,
+		},
+		{
+			"disjoint selectors",
+			[]settingsv1alpha1.PodPreset{
+				makePreset("default", "a", map[string]string{"app": "web"}, []string{"DB_HOST"}, nil),
+				makePreset("default", "b", map[string]string{"app": "worker"}, []string{"DB_HOST"}, nil),
+			},
+			0,
+		},
+		{
+			"same selector in different namespaces",
+			[]settingsv1alpha1.PodPreset{
+				makePreset("default", "a", map[string]string{"app": "web"}, []string{"DB_HOST"}, nil),
+				makePreset("other", "b", map[string]string{"app": "web"}, []string{"DB_HOST"}, nil),
+			},
+			0,
+		},
+		{
+			"empty selector matches everything",
+			[]settingsv1alpha1.PodPreset{
+				makePreset("default", "a", nil, nil, []string{"/etc/ca"}),
+				makePreset("default", "b", map[string]string{"app": "worker"}, nil, []string{"/etc/ca"}),
+			},
+			1,
+		},
+	}
+
+	for _, test := range tests {
+		conflicts, err := findConflicts(test.presets)
+		if err != nil {
+			t.Fatal("Test", test.description, "returned an error:", err)
+		}
+		if len(conflicts) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "conflicts but got", conflicts)
+		}
+		t.Log(test.description, conflicts)
+	}
+}
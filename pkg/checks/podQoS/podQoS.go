@@ -0,0 +1,250 @@
+// Package podQoS implements a checker that ensures pods run in a quality of
+// service class suited to their workload.  BestEffort pods have neither
+// requests nor limits and are the first to be evicted when a node is under
+// pressure.  Namespaces can be annotated to require the Guaranteed class for
+// all of their pods.
+package podQoS // import "github.com/Comcast/kuberhealthy/pkg/checks/podQoS"
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// RequireGuaranteedAnnotation requires every pod in a namespace to be in the
+// Guaranteed QoS class when set to "true" on the namespace
+const RequireGuaranteedAnnotation = "kuberhealthy.io/require-guaranteed-qos"
+
+// qosResources are the resources that determine the QoS class of a pod
+var qosResources = []apiv1.ResourceName{apiv1.ResourceCPU, apiv1.ResourceMemory}
+
+// Checker validates the QoS class of pods
+type Checker struct {
+	Errors     []string
+	Namespaces []string
+	client     *kubernetes.Clientset
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied
+func New(namespaces []string) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		Errors:     []string{},
+		Namespaces: namespaces,
+	}
+}
+
+// Name returns the name of this checker
+func (pqc *Checker) Name() string {
+	return "PodQoSChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (pqc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (pqc *Checker) Interval() time.Duration {
+	if pqc.interval > 0 {
+		return pqc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (pqc *Checker) SetInterval(d time.Duration) {
+	pqc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Namespaces are listed
+// cluster wide for their annotations, and pods in each checked namespace.
+func (pqc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"list"}},
+		},
+	}
+	for _, ns := range pqc.Namespaces {
+		rules[ns] = append(rules[ns],
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		)
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (pqc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (pqc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (pqc *Checker) CurrentStatus() (bool, []string) {
+	if len(pqc.Errors) > 0 {
+		return false, pqc.Errors
+	}
+	return true, pqc.Errors
+}
+
+// clearErrors clears all errors
+func (pqc *Checker) clearErrors() {
+	pqc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (pqc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	pqc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := pqc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(pqc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + pqc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(pqc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + pqc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + pqc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists namespaces and pods and sets an error for every pod in an
+// unsuitable QoS class
+func (pqc *Checker) doChecks() error {
+
+	namespaces, err := pqc.client.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing namespaces: " + err.Error())
+	}
+	guaranteed := make(map[string]bool)
+	for _, ns := range namespaces.Items {
+		if ns.Annotations[RequireGuaranteedAnnotation] == "true" {
+			guaranteed[ns.Name] = true
+		}
+	}
+
+	var qosErrors []string
+	for _, ns := range pqc.Namespaces {
+		pods, err := pqc.client.CoreV1().Pods(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		qosErrors = append(qosErrors, evaluatePods(pods.Items, guaranteed)...)
+	}
+
+	if len(qosErrors) > 0 {
+		for _, e := range qosErrors {
+			khlog.ForCheck(pqc.Name()).Warningln(e)
+		}
+		pqc.Errors = qosErrors
+		return nil
+	}
+
+	pqc.clearErrors()
+	return nil
+}
+
+// evaluatePods returns an error for every pod that is BestEffort, or that is
+// not Guaranteed in a namespace that requires it.  Completed pods are
+// skipped, as are pods created by Jobs and DaemonSets everywhere other than
+// namespaces that require Guaranteed pods.
+func evaluatePods(pods []apiv1.Pod, guaranteedNamespaces map[string]bool) []string {
+	var qosErrors []string
+
+	for _, p := range pods {
+		if p.Status.Phase == apiv1.PodSucceeded || p.Status.Phase == apiv1.PodFailed {
+			continue
+		}
+
+		class := qosClass(p)
+		switch {
+		case guaranteedNamespaces[p.Namespace] && class != apiv1.PodQOSGuaranteed:
+			qosErrors = append(qosErrors, "Pod "+p.Namespace+"/"+p.Name+" has QoS class "+string(class)+" but namespace "+p.Namespace+" requires "+string(apiv1.PodQOSGuaranteed))
+		case class == apiv1.PodQOSBestEffort && !ownedBy(p, "Job", "DaemonSet"):
+			qosErrors = append(qosErrors, "Pod "+p.Namespace+"/"+p.Name+" has QoS class "+string(class)+" because none of its containers set resource requests or limits")
+		}
+	}
+	return qosErrors
+}
+
+// qosClass derives the QoS class of a pod from its container resources the
+// same way the API server does.  Requests that are not set default to their
+// limits.
+func qosClass(p apiv1.Pod) apiv1.PodQOSClass {
+	var hasResources bool
+	guaranteed := true
+
+	containers := append(append([]apiv1.Container{}, p.Spec.InitContainers...), p.Spec.Containers...)
+	for _, c := range containers {
+		for _, name := range qosResources {
+			request, hasRequest := nonZero(c.Resources.Requests, name)
+			limit, hasLimit := nonZero(c.Resources.Limits, name)
+			if hasRequest || hasLimit {
+				hasResources = true
+			}
+			if !hasLimit || (hasRequest && request.Cmp(limit) != 0) {
+				guaranteed = false
+			}
+		}
+	}
+
+	switch {
+	case !hasResources:
+		return apiv1.PodQOSBestEffort
+	case guaranteed:
+		return apiv1.PodQOSGuaranteed
+	}
+	return apiv1.PodQOSBurstable
+}
+
+// nonZero returns a resource quantity from a resource list if it is set to a
+// value other than zero
+func nonZero(list apiv1.ResourceList, name apiv1.ResourceName) (resource.Quantity, bool) {
+	q, ok := list[name]
+	if !ok || q.IsZero() {
+		return q, false
+	}
+	return q, true
+}
+
+// ownedBy determines if a pod was created by a controller of one of the
+// supplied kinds
+func ownedBy(p apiv1.Pod, kinds ...string) bool {
+	for _, ref := range p.OwnerReferences {
+		for _, kind := range kinds {
+			if ref.Kind == kind {
+				return true
+			}
+		}
+	}
+	return false
+}
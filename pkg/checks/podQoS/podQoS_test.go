@@ -0,0 +1,100 @@
+package podQoS
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// makeResources builds requirements from cpu and memory requests and limits,
+// leaving out any that are empty
+func makeResources(cpuRequest, memoryRequest, cpuLimit, memoryLimit string) apiv1.ResourceRequirements {
+	list := func(cpu string, memory string) apiv1.ResourceList {
+		l := apiv1.ResourceList{}
+		if len(cpu) > 0 {
+			l[apiv1.ResourceCPU] = resource.MustParse(cpu)
+		}
+		if len(memory) > 0 {
+			l[apiv1.ResourceMemory] = resource.MustParse(memory)
+		}
+		return l
+	}
+	return apiv1.ResourceRequirements{Requests: list(cpuRequest, memoryRequest), Limits: list(cpuLimit, memoryLimit)}
+}
+
+func makePod(ns string, resources ...apiv1.ResourceRequirements) apiv1.Pod {
+	pod := apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "web"},
+		Status:     apiv1.PodStatus{Phase: apiv1.PodRunning},
+	}
+	for _, r := range resources {
+		pod.Spec.Containers = append(pod.Spec.Containers, apiv1.Container{Name: "app", Resources: r})
+	}
+	return pod
+}
+
+func TestQoSClass(t *testing.T) {
+	withInit := makePod("default", makeResources("", "", "500m", "256Mi"))
+	withInit.Spec.InitContainers = []apiv1.Container{{Name: "init", Resources: makeResources("100m", "", "", "")}}
+
+	var tests = []struct {
+		description string
+		pod         apiv1.Pod
+		expected    apiv1.PodQOSClass
+	}{
+		{"no resources", makePod("default", makeResources("", "", "", "")), apiv1.PodQOSBestEffort},
+		{"zero requests", makePod("default", makeResources("0", "0", "", "")), apiv1.PodQOSBestEffort},
+		{"requests only", makePod("default", makeResources("100m", "128Mi", "", "")), apiv1.PodQOSBurstable},
+		{"requests below limits", makePod("default", makeResources("100m", "128Mi", "500m", "256Mi")), apiv1.PodQOSBurstable},
+		{"requests equal limits", makePod("default", makeResources("500m", "256Mi", "0.5", "256Mi")), apiv1.PodQOSGuaranteed},
+		{"limits only", makePod("default", makeResources("", "", "500m", "256Mi")), apiv1.PodQOSGuaranteed},
+		{"cpu limit only", makePod("default", makeResources("", "", "500m", "")), apiv1.PodQOSBurstable},
+		{"one container without resources", makePod("default", makeResources("", "", "500m", "256Mi"), makeResources("", "", "", "")), apiv1.PodQOSBurstable},
+		{"init container requests", withInit, apiv1.PodQOSBurstable},
+	}
+
+	for _, test := range tests {
+		class := qosClass(test.pod)
+		if class != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "but got", class)
+		}
+	}
+}
+
+func TestEvaluatePods(t *testing.T) {
+	owned := func(pod apiv1.Pod, kind string) apiv1.Pod {
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: kind, Name: "owner"}}
+		return pod
+	}
+	completed := makePod("default")
+	completed.Status.Phase = apiv1.PodSucceeded
+
+	burstable := makeResources("100m", "128Mi", "", "")
+	guaranteed := makeResources("", "", "500m", "256Mi")
+
+	var tests = []struct {
+		description string
+		pod         apiv1.Pod
+		expected    int
+	}{
+		{"best effort", makePod("default"), 1},
+		{"burstable", makePod("default", burstable), 0},
+		{"best effort job", owned(makePod("default"), "Job"), 0},
+		{"best effort daemonset", owned(makePod("default"), "DaemonSet"), 0},
+		{"best effort replicaset", owned(makePod("default"), "ReplicaSet"), 1},
+		{"completed best effort", completed, 0},
+		{"burstable in critical namespace", makePod("payments", burstable), 1},
+		{"best effort job in critical namespace", owned(makePod("payments"), "Job"), 1},
+		{"guaranteed in critical namespace", makePod("payments", guaranteed), 0},
+	}
+
+	for _, test := range tests {
+		qosErrors := evaluatePods([]apiv1.Pod{test.pod}, map[string]bool{"payments": true})
+		if len(qosErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", qosErrors)
+		}
+		t.Log(test.description, qosErrors)
+	}
+}
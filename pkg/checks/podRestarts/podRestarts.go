@@ -6,6 +6,7 @@
 package podRestarts // import "github.com/Comcast/kuberhealthy/pkg/checks/podRestarts"
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -15,6 +16,8 @@ import (
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 
+	"github.com/Comcast/kuberhealthy/pkg/checks/componentStatus"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -27,6 +30,7 @@ type Checker struct {
 	Namespace           string
 	MaxFailuresAllowed  int
 	client              *kubernetes.Clientset
+	interval            time.Duration // overrides the default interval when set
 }
 
 // RestartCountObservation keeps track of the number of restarts for a given pod
@@ -57,9 +61,26 @@ func (prc *Checker) CheckNamespace() string {
 
 // Interval returns the interval at which this check runs
 func (prc *Checker) Interval() time.Duration {
+	if prc.interval > 0 {
+		return prc.interval
+	}
 	return time.Minute * 5
 }
 
+// SetInterval overrides the interval at which this check runs
+func (prc *Checker) SetInterval(d time.Duration) {
+	prc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs in its namespace
+func (prc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		prc.Namespace: {
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
 // Timeout returns the maximum run time for this check before it times out
 func (prc *Checker) Timeout() time.Duration {
 	return time.Minute * 3
@@ -74,7 +95,7 @@ func (prc *Checker) CurrentStatus() (bool, []string) {
 }
 
 // Run implements the entrypoint for check execution
-func (prc *Checker) Run(client *kubernetes.Clientset) error {
+func (prc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
 	doneChan := make(chan error)
 
 	prc.client = client
@@ -94,11 +115,19 @@ func (prc *Checker) Run(client *kubernetes.Clientset) error {
 	case <-time.After(prc.Timeout()):
 		// The check has timed out after its specified timeout period
 		return errors.New("Failed to complete checks for " + prc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + prc.Name() + ".  The check was cancelled.")
 	case err := <-doneChan:
 		return err
 	}
 }
 
+// DependsOn returns the component status checker
+func (prc *Checker) DependsOn() []string {
+	return []string{componentStatus.CheckName}
+}
+
 // Shutdown is implemented to satsify the KuberhealthyCheck interface, but
 // no action is necessary.
 func (prc *Checker) Shutdown() error {
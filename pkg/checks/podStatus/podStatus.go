@@ -4,15 +4,18 @@
 package podStatus // import "github.com/Comcast/kuberhealthy/pkg/checks/podStatus"
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
 
 	// required for oidc kubectl testing
-	log "github.com/sirupsen/logrus"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 
+	"github.com/Comcast/kuberhealthy/pkg/checks/componentStatus"
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
 	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -24,6 +27,7 @@ type Checker struct {
 	Namespace        string
 	MaxTimeInFailure float64 // TODO - make configurable
 	client           *kubernetes.Clientset
+	interval         time.Duration // overrides the default interval when set
 }
 
 // New returns a new Checker
@@ -48,14 +52,44 @@ func (psc *Checker) CheckNamespace() string {
 
 // Interval returns the interval at which this check runs
 func (psc *Checker) Interval() time.Duration {
+	if psc.interval > 0 {
+		return psc.interval
+	}
 	return time.Minute * 2
 }
 
+// SetInterval overrides the interval at which this check runs
+func (psc *Checker) SetInterval(d time.Duration) {
+	psc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs in its namespace
+func (psc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		psc.Namespace: {
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
 // Timeout returns the maximum run time for this check before it times out
 func (psc *Checker) Timeout() time.Duration {
 	return time.Minute * 1
 }
 
+// RetryPolicy returns the attempts and base retry delay of a failing run.
+// Listing pods fails while the API server is briefly unavailable.
+func (psc *Checker) RetryPolicy() (int, time.Duration) {
+	return 3, time.Second * 5
+}
+
+// DependsOn returns the component status checker.  Pods are not rescheduled
+// without a healthy scheduler and controller manager, so their statuses are
+// not checked then.
+func (psc *Checker) DependsOn() []string {
+	return []string{componentStatus.CheckName}
+}
+
 // Shutdown is implemented to satisfy KuberhealthyCheck but is not used
 func (psc *Checker) Shutdown() error {
 	return nil
@@ -75,7 +109,7 @@ func (psc *Checker) clearErrors() {
 }
 
 // Run implements the entrypoint for check execution
-func (psc *Checker) Run(client *kubernetes.Clientset) error {
+func (psc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
 	doneChan := make(chan error)
 
 	psc.client = client
@@ -94,6 +128,9 @@ func (psc *Checker) Run(client *kubernetes.Clientset) error {
 	case <-time.After(psc.Timeout()):
 		// The check has timed out after its specified timeout period
 		return errors.New("Failed to complete checks for " + psc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + psc.Name() + ".  The check was cancelled.")
 	case err := <-doneChan:
 		return err
 	}
@@ -114,7 +151,7 @@ func (psc *Checker) doChecks() error {
 	if len(podStatus) > 0 {
 		var newErrorSet []string
 		for _, p := range podStatus {
-			log.Errorln(psc.Name(), "Error found when checking pods: "+p)
+			khlog.ForCheck(psc.Name()).Errorln("Error found when checking pods: " + p)
 			newErrorSet = append(newErrorSet, p)
 		}
 		psc.Errors = newErrorSet
@@ -0,0 +1,253 @@
+// Package privilegedJustification implements a checker that ensures every
+// pod running privileged containers documents why it needs to.  Privileged
+// containers have full access to their node, so each one should be a
+// deliberate decision that can be reviewed.
+package privilegedJustification // import "github.com/Comcast/kuberhealthy/pkg/checks/privilegedJustification"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ReasonAnnotation is the pod annotation that explains why the pod runs
+// privileged containers
+const ReasonAnnotation = "kuberhealthy.io/privileged-reason"
+
+// eventReason is the reason of the Events created for pods without a
+// justification
+const eventReason = "PrivilegedWithoutJustification"
+
+// Checker validates that privileged pods are justified
+type Checker struct {
+	Errors      []string
+	Namespaces  []string
+	CreateEvent bool
+	client      *kubernetes.Clientset
+	// createEvent is replaced in tests to record created Events
+	createEvent func(event *apiv1.Event) error
+	interval    time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied.  When createEvent is true, a warning Event is
+// created on every privileged pod without a justification.
+func New(namespaces []string, createEvent bool) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	pjc := &Checker{
+		Errors:      []string{},
+		Namespaces:  namespaces,
+		CreateEvent: createEvent,
+	}
+	pjc.createEvent = pjc.apiCreateEvent
+	return pjc
+}
+
+// Name returns the name of this checker
+func (pjc *Checker) Name() string {
+	return "PrivilegedJustificationChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (pjc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (pjc *Checker) Interval() time.Duration {
+	if pjc.interval > 0 {
+		return pjc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (pjc *Checker) SetInterval(d time.Duration) {
+	pjc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to list pods in the
+// checked namespaces, and to create events on unjustified pods when that is
+// enabled
+func (pjc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range pjc.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		}
+		if pjc.CreateEvent {
+			rules[ns] = append(rules[ns], rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create"}})
+		}
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (pjc *Checker) Timeout() time.Duration {
+	return time.Minute * 2
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (pjc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (pjc *Checker) CurrentStatus() (bool, []string) {
+	if len(pjc.Errors) > 0 {
+		return false, pjc.Errors
+	}
+	return true, pjc.Errors
+}
+
+// clearErrors clears all errors
+func (pjc *Checker) clearErrors() {
+	pjc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (pjc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	pjc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := pjc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(pjc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + pjc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(pjc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + pjc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + pjc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists pods and sets an error for every privileged container in a
+// pod without a justification
+func (pjc *Checker) doChecks() error {
+
+	var pods []apiv1.Pod
+	for _, ns := range pjc.Namespaces {
+		podList, err := pjc.client.CoreV1().Pods(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return errors.New("Error listing pods: " + err.Error())
+		}
+		pods = append(pods, podList.Items...)
+	}
+
+	justificationErrors := pjc.evaluatePods(pods, time.Now())
+
+	if len(justificationErrors) > 0 {
+		for _, e := range justificationErrors {
+			khlog.ForCheck(pjc.Name()).Warningln(e)
+		}
+		pjc.Errors = justificationErrors
+		return nil
+	}
+
+	pjc.clearErrors()
+	return nil
+}
+
+// evaluatePods returns an error for every privileged container in a pod
+// without a non-empty justification annotation, and creates a warning Event
+// on each of those pods when enabled
+func (pjc *Checker) evaluatePods(pods []apiv1.Pod, now time.Time) []string {
+	var justificationErrors []string
+
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Namespace+"/"+pods[i].Name < pods[j].Namespace+"/"+pods[j].Name
+	})
+	for _, p := range pods {
+		if len(strings.TrimSpace(p.Annotations[ReasonAnnotation])) > 0 {
+			continue
+		}
+		containers := privilegedContainers(p)
+		if len(containers) == 0 {
+			continue
+		}
+
+		for _, c := range containers {
+			justificationErrors = append(justificationErrors, "Container "+c+" in pod "+p.Namespace+"/"+p.Name+
+				" runs privileged without a justification in the "+ReasonAnnotation+" annotation")
+		}
+
+		if !pjc.CreateEvent {
+			continue
+		}
+		err := pjc.createEvent(findingEvent(p, containers, now))
+		if err != nil && !k8sErrors.IsAlreadyExists(err) {
+			khlog.ForCheck(pjc.Name()).Errorln("error creating Event for pod", p.Namespace+"/"+p.Name+":", err)
+		}
+	}
+	return justificationErrors
+}
+
+// apiCreateEvent creates an Event with the Kubernetes API
+func (pjc *Checker) apiCreateEvent(event *apiv1.Event) error {
+	_, err := pjc.client.CoreV1().Events(event.Namespace).Create(event)
+	return err
+}
+
+// findingEvent returns a warning Event on a pod that names its privileged
+// containers.  The Event name is derived from the pod so that an Event is
+// only created once per pod.
+func findingEvent(p apiv1.Pod, containers []string, now time.Time) *apiv1.Event {
+	return &apiv1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      p.Name + ".privileged-justification",
+			Namespace: p.Namespace,
+		},
+		InvolvedObject: apiv1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Namespace:  p.Namespace,
+			Name:       p.Name,
+			UID:        p.UID,
+		},
+		Reason:         eventReason,
+		Message:        "Privileged containers " + strings.Join(containers, ", ") + " have no justification in the " + ReasonAnnotation + " annotation",
+		Type:           apiv1.EventTypeWarning,
+		Source:         apiv1.EventSource{Component: "kuberhealthy"},
+		FirstTimestamp: metav1.NewTime(now),
+		LastTimestamp:  metav1.NewTime(now),
+		Count:          1,
+	}
+}
+
+// privilegedContainers returns the names of the init containers and
+// containers of a pod that run privileged
+func privilegedContainers(p apiv1.Pod) []string {
+	var names []string
+	containers := append(append([]apiv1.Container{}, p.Spec.InitContainers...), p.Spec.Containers...)
+	for _, c := range containers {
+		if c.SecurityContext != nil && c.SecurityContext.Privileged != nil && *c.SecurityContext.Privileged {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
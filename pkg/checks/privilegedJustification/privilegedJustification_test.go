@@ -0,0 +1,93 @@
+package privilegedJustification
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluatePods(t *testing.T) {
+	privileged := true
+	unprivileged := false
+
+	makePod := func(reason string, containers ...apiv1.Container) apiv1.Pod {
+		p := apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "agent", Annotations: map[string]string{}},
+			Spec:       apiv1.PodSpec{Containers: containers},
+		}
+		if len(reason) > 0 {
+			p.Annotations[ReasonAnnotation] = reason
+		}
+		return p
+	}
+	container := func(name string, p *bool) apiv1.Container {
+		c := apiv1.Container{Name: name}
+		if p != nil {
+			c.SecurityContext = &apiv1.SecurityContext{Privileged: p}
+		}
+		return c
+	}
+
+	initContainer := makePod("", container("agent", nil))
+	initContainer.Spec.InitContainers = []apiv1.Container{container("setup", &privileged)}
+
+	var tests = []struct {
+		description    string
+		pod            apiv1.Pod
+		expected       int
+		expectedEvents int
+	}{
+		{"no security context", makePod("", container("agent", nil)), 0, 0},
+		{"unprivileged", makePod("", container("agent", &unprivileged)), 0, 0},
+		{"privileged with reason", makePod("loads kernel modules", container("agent", &privileged)), 0, 0},
+		{"privileged without reason", makePod("", container("agent", &privileged)), 1, 1},
+		{"privileged with blank reason", makePod("  ", container("agent", &privileged)), 1, 1},
+		{"two privileged containers", makePod("", container("agent", &privileged), container("sidecar", &privileged)), 2, 1},
+		{"privileged init container", initContainer, 1, 1},
+	}
+
+	for _, test := range tests {
+		checker := New(nil, true)
+		var events []*apiv1.Event
+		checker.createEvent = func(event *apiv1.Event) error {
+			events = append(events, event)
+			return nil
+		}
+
+		justificationErrors := checker.evaluatePods([]apiv1.Pod{test.pod}, time.Now())
+		if len(justificationErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", justificationErrors)
+		}
+		if len(events) != test.expectedEvents {
+			t.Fatal("Test", test.description, "expected", test.expectedEvents, "events but got", len(events))
+		}
+		for _, e := range events {
+			if e.InvolvedObject.Name != test.pod.Name || e.Namespace != test.pod.Namespace || e.Type != apiv1.EventTypeWarning {
+				t.Fatal("Test", test.description, "expected a warning event on the pod but got", e)
+			}
+		}
+		t.Log(test.description, justificationErrors)
+	}
+}
+
+func TestEvaluatePodsWithoutEvents(t *testing.T) {
+	privileged := true
+	checker := New(nil, false)
+	checker.createEvent = func(event *apiv1.Event) error {
+		t.Fatal("Expected no events to be created but got", event)
+		return nil
+	}
+
+	pod := apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "agent"},
+		Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{{Name: "agent", SecurityContext: &apiv1.SecurityContext{Privileged: &privileged}}},
+		},
+	}
+	justificationErrors := checker.evaluatePods([]apiv1.Pod{pod}, time.Now())
+	if len(justificationErrors) != 1 {
+		t.Fatal("Expected 1 error but got", justificationErrors)
+	}
+}
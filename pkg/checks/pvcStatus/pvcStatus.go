@@ -0,0 +1,172 @@
+// Package pvcStatus implements a PersistentVolumeClaim binding checker for
+// Kuberhealthy.  Claims that stay Pending block the pods that mount them
+// from being scheduled without the pods themselves reporting an error.
+package pvcStatus // import "github.com/Comcast/kuberhealthy/pkg/checks/pvcStatus"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// IgnoreAnnotation excludes a claim from the check when set to true, such as
+// for claims that are intentionally left unbound
+const IgnoreAnnotation = "kuberhealthy.io/ignore"
+
+// Checker validates that PersistentVolumeClaims within a namespace are bound
+type Checker struct {
+	Errors           []string
+	Namespace        string
+	PendingThreshold time.Duration
+	client           *kubernetes.Clientset
+	interval         time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reports claims in the namespace that have
+// been Pending for longer than pendingThreshold
+func New(namespace string, pendingThreshold time.Duration) *Checker {
+	return &Checker{
+		Errors:           []string{},
+		Namespace:        namespace,
+		PendingThreshold: pendingThreshold,
+	}
+}
+
+// Name returns the name of this checker
+func (pvc *Checker) Name() string {
+	return fmt.Sprintf("PVCStatusChecker namespace %s", pvc.Namespace)
+}
+
+// CheckNamespace returns the namespace of this checker
+func (pvc *Checker) CheckNamespace() string {
+	return pvc.Namespace
+}
+
+// Interval returns the interval at which this check runs
+func (pvc *Checker) Interval() time.Duration {
+	if pvc.interval > 0 {
+		return pvc.interval
+	}
+	return time.Minute * 2
+}
+
+// SetInterval overrides the interval at which this check runs
+func (pvc *Checker) SetInterval(d time.Duration) {
+	pvc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs in its namespace
+func (pvc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		pvc.Namespace: {
+			{APIGroups: []string{""}, Resources: []string{"persistentvolumeclaims"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (pvc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (pvc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (pvc *Checker) CurrentStatus() (bool, []string) {
+	if len(pvc.Errors) > 0 {
+		return false, pvc.Errors
+	}
+	return true, pvc.Errors
+}
+
+// clearErrors clears all errors
+func (pvc *Checker) clearErrors() {
+	pvc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (pvc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	pvc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := pvc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(pvc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + pvc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(pvc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + pvc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + pvc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists the claims in the namespace and sets an error listing the
+// claims that have been Pending for too long
+func (pvc *Checker) doChecks() error {
+
+	claims, err := pvc.client.CoreV1().PersistentVolumeClaims(pvc.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing persistent volume claims in namespace " + pvc.Namespace + ": " + err.Error())
+	}
+
+	pending := pendingClaims(claims.Items, time.Now().Add(-pvc.PendingThreshold))
+
+	if len(pending) > 0 {
+		pvcError := "Persistent volume claims in namespace " + pvc.Namespace + " have been Pending for more than " + pvc.PendingThreshold.String() + ": " + strings.Join(pending, ", ")
+		khlog.ForCheck(pvc.Name()).Warningln(pvcError)
+		pvc.Errors = []string{pvcError}
+		return nil
+	}
+
+	pvc.clearErrors()
+	return nil
+}
+
+// pendingClaims returns the names of the claims that are Pending and were
+// created before the cutoff.  A claim is Pending from its creation until it
+// is bound, so its age is how long it has been Pending.  Claims with the
+// ignore annotation are skipped.
+func pendingClaims(claims []apiv1.PersistentVolumeClaim, cutoff time.Time) []string {
+	var pending []string
+
+	for _, c := range claims {
+		if c.Status.Phase != apiv1.ClaimPending {
+			continue
+		}
+		if c.Annotations[IgnoreAnnotation] == "true" {
+			continue
+		}
+		if c.CreationTimestamp.Time.After(cutoff) {
+			continue
+		}
+		pending = append(pending, c.Name)
+	}
+	sort.Strings(pending)
+	return pending
+}
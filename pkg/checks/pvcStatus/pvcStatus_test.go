@@ -0,0 +1,46 @@
+package pvcStatus
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPendingClaims(t *testing.T) {
+	now := time.Now()
+
+	makeClaim := func(name string, phase apiv1.PersistentVolumeClaimPhase, age time.Duration, annotations map[string]string) apiv1.PersistentVolumeClaim {
+		return apiv1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "default",
+				Name:              name,
+				Annotations:       annotations,
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			},
+			Status: apiv1.PersistentVolumeClaimStatus{Phase: phase},
+		}
+	}
+
+	var tests = []struct {
+		description string
+		claim       apiv1.PersistentVolumeClaim
+		expected    int
+	}{
+		{"bound", makeClaim("data", apiv1.ClaimBound, time.Hour, nil), 0},
+		{"pending past threshold", makeClaim("data", apiv1.ClaimPending, time.Minute*10, nil), 1},
+		{"pending within threshold", makeClaim("data", apiv1.ClaimPending, time.Minute, nil), 0},
+		{"pending and ignored", makeClaim("data", apiv1.ClaimPending, time.Hour, map[string]string{IgnoreAnnotation: "true"}), 0},
+		{"pending and not ignored", makeClaim("data", apiv1.ClaimPending, time.Hour, map[string]string{IgnoreAnnotation: "false"}), 1},
+		{"lost", makeClaim("data", apiv1.ClaimLost, time.Hour, nil), 0},
+	}
+
+	for _, test := range tests {
+		pending := pendingClaims([]apiv1.PersistentVolumeClaim{test.claim}, now.Add(-time.Minute*5))
+		if len(pending) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "pending claims but got", pending)
+		}
+		t.Log(test.description, pending)
+	}
+}
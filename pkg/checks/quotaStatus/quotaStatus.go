@@ -0,0 +1,206 @@
+// Package quotaStatus implements a checker that warns when ResourceQuotas
+// are close to being exhausted.  Pods that would exceed a quota are rejected
+// when they are created, which shows up as pods of a ReplicaSet or Job that
+// never appear rather than as an obvious failure.
+package quotaStatus // import "github.com/Comcast/kuberhealthy/pkg/checks/quotaStatus"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// quotaResources are the CPU, memory, and pod count resources of a quota
+// that are checked
+var quotaResources = []apiv1.ResourceName{
+	apiv1.ResourceCPU,
+	apiv1.ResourceRequestsCPU,
+	apiv1.ResourceLimitsCPU,
+	apiv1.ResourceMemory,
+	apiv1.ResourceRequestsMemory,
+	apiv1.ResourceLimitsMemory,
+	apiv1.ResourcePods,
+}
+
+// Checker validates that ResourceQuotas are not close to being exhausted
+type Checker struct {
+	Errors     []string
+	Namespaces []string
+	Threshold  float64
+	client     *kubernetes.Clientset
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied.  Quotas are reported once the used amount of a
+// resource reaches the threshold, given as a fraction of the hard limit.
+func New(namespaces []string, threshold float64) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		Errors:     []string{},
+		Namespaces: namespaces,
+		Threshold:  threshold,
+	}
+}
+
+// Name returns the name of this checker
+func (qsc *Checker) Name() string {
+	return "QuotaStatusChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (qsc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (qsc *Checker) Interval() time.Duration {
+	if qsc.interval > 0 {
+		return qsc.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (qsc *Checker) SetInterval(d time.Duration) {
+	qsc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to list the
+// ResourceQuotas of each checked namespace, which is cluster wide when all
+// namespaces are checked
+func (qsc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range qsc.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"resourcequotas"}, Verbs: []string{"list"}},
+		}
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (qsc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (qsc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (qsc *Checker) CurrentStatus() (bool, []string) {
+	if len(qsc.Errors) > 0 {
+		return false, qsc.Errors
+	}
+	return true, qsc.Errors
+}
+
+// clearErrors clears all errors
+func (qsc *Checker) clearErrors() {
+	qsc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (qsc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	qsc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := qsc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(qsc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + qsc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(qsc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + qsc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + qsc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists ResourceQuotas in each namespace and sets an error for
+// every quota resource used at or above the threshold
+func (qsc *Checker) doChecks() error {
+
+	var quotas []apiv1.ResourceQuota
+	for _, ns := range qsc.Namespaces {
+		list, err := qsc.client.CoreV1().ResourceQuotas(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return errors.New("Error listing ResourceQuotas in namespace " + ns + ": " + err.Error())
+		}
+		quotas = append(quotas, list.Items...)
+	}
+
+	quotaErrors := evaluateQuotas(quotas, qsc.Threshold)
+
+	if len(quotaErrors) > 0 {
+		for _, e := range quotaErrors {
+			khlog.ForCheck(qsc.Name()).Warningln(e)
+		}
+		qsc.Errors = quotaErrors
+		return nil
+	}
+
+	qsc.clearErrors()
+	return nil
+}
+
+// evaluateQuotas returns an error for every CPU, memory, and pod count
+// resource of a quota whose used amount is at or above threshold times its
+// hard limit.  Usage is taken from the quota status, and resources without
+// a hard limit are skipped.
+func evaluateQuotas(quotas []apiv1.ResourceQuota, threshold float64) []string {
+	var quotaErrors []string
+
+	sort.Slice(quotas, func(i, j int) bool {
+		if quotas[i].Namespace != quotas[j].Namespace {
+			return quotas[i].Namespace < quotas[j].Namespace
+		}
+		return quotas[i].Name < quotas[j].Name
+	})
+
+	for _, q := range quotas {
+		for _, r := range quotaResources {
+			hard, ok := q.Status.Hard[r]
+			if !ok || hard.IsZero() {
+				continue
+			}
+			used, ok := q.Status.Used[r]
+			if !ok {
+				continue
+			}
+
+			// CPU is compared in millicores so that fractional cores count
+			ratio := float64(used.MilliValue()) / float64(hard.MilliValue())
+			if ratio < threshold {
+				continue
+			}
+			quotaErrors = append(quotaErrors, "ResourceQuota "+q.Name+" in namespace "+q.Namespace+" has used "+used.String()+" of its "+hard.String()+" "+string(r)+" limit ("+strconv.Itoa(int(ratio*100))+"%)")
+		}
+	}
+	return quotaErrors
+}
@@ -0,0 +1,51 @@
+package quotaStatus
+
+import (
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateQuotas(t *testing.T) {
+
+	makeQuota := func(r apiv1.ResourceName, used string, hard string) apiv1.ResourceQuota {
+		return apiv1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "compute", Namespace: "team-a"},
+			Status: apiv1.ResourceQuotaStatus{
+				Hard: apiv1.ResourceList{r: resource.MustParse(hard)},
+				Used: apiv1.ResourceList{r: resource.MustParse(used)},
+			},
+		}
+	}
+
+	var tests = []struct {
+		description   string
+		quota         apiv1.ResourceQuota
+		expectedError string
+	}{
+		{"pods below threshold", makeQuota(apiv1.ResourcePods, "8", "10"), ""},
+		{"pods at threshold", makeQuota(apiv1.ResourcePods, "9", "10"), "ResourceQuota compute in namespace team-a has used 9 of its 10 pods limit (90%)"},
+		{"pods exhausted", makeQuota(apiv1.ResourcePods, "10", "10"), "(100%)"},
+		{"fractional cpu below threshold", makeQuota(apiv1.ResourceRequestsCPU, "1500m", "2"), ""},
+		{"fractional cpu above threshold", makeQuota(apiv1.ResourceRequestsCPU, "1900m", "2"), "has used 1900m of its 2 requests.cpu limit (95%)"},
+		{"memory above threshold", makeQuota(apiv1.ResourceLimitsMemory, "15Gi", "16Gi"), "has used 15Gi of its 16Gi limits.memory limit (93%)"},
+		{"zero hard limit", makeQuota(apiv1.ResourcePods, "0", "0"), ""},
+		{"unchecked resource", makeQuota(apiv1.ResourceServices, "10", "10"), ""},
+	}
+
+	for _, test := range tests {
+		quotaErrors := evaluateQuotas([]apiv1.ResourceQuota{test.quota}, 0.9)
+		if len(test.expectedError) == 0 {
+			if len(quotaErrors) != 0 {
+				t.Fatal("Test", test.description, "expected no errors but got", quotaErrors)
+			}
+			continue
+		}
+		if len(quotaErrors) != 1 || !strings.Contains(quotaErrors[0], test.expectedError) {
+			t.Fatal("Test", test.description, "expected error", test.expectedError, "but got", quotaErrors)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Package rateLimitConfig implements a checker that ensures internet facing
+// Ingresses are rate limited.  An Ingress is internet facing when it has an
+// internet facing ingress class or is annotated as internet facing.
+package rateLimitConfig // import "github.com/Comcast/kuberhealthy/pkg/checks/rateLimitConfig"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// InternetFacingAnnotation marks an Ingress as internet facing when set
+	// to "true"
+	InternetFacingAnnotation = "kuberhealthy.io/internet-facing"
+	// IngressClassAnnotation selects the ingress controller of an Ingress
+	IngressClassAnnotation = "kubernetes.io/ingress.class"
+)
+
+// rateLimitAnnotations are the annotations that rate limit an Ingress,
+// mapped to the number of seconds their limit is given per
+var rateLimitAnnotations = map[string]float64{
+	"nginx.ingress.kubernetes.io/limit-rps": 1,
+	"nginx.ingress.kubernetes.io/limit-rpm": 60,
+}
+
+// Checker validates that internet facing Ingresses are rate limited
+type Checker struct {
+	Errors                []string
+	InternetFacingClasses []string
+	MaxRequestsPerSecond  float64
+	client                *kubernetes.Clientset
+	interval              time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that treats Ingresses of the supplied ingress
+// classes as internet facing and reports those without a rate limit or with
+// a limit above maxRequestsPerSecond
+func New(internetFacingClasses []string, maxRequestsPerSecond float64) *Checker {
+	return &Checker{
+		Errors:                []string{},
+		InternetFacingClasses: internetFacingClasses,
+		MaxRequestsPerSecond:  maxRequestsPerSecond,
+	}
+}
+
+// Name returns the name of this checker
+func (rlc *Checker) Name() string {
+	return "RateLimitConfigChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (rlc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (rlc *Checker) Interval() time.Duration {
+	if rlc.interval > 0 {
+		return rlc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (rlc *Checker) SetInterval(d time.Duration) {
+	rlc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Ingresses are listed
+// in every namespace.
+func (rlc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{"extensions"}, Resources: []string{"ingresses"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (rlc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (rlc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (rlc *Checker) CurrentStatus() (bool, []string) {
+	if len(rlc.Errors) > 0 {
+		return false, rlc.Errors
+	}
+	return true, rlc.Errors
+}
+
+// clearErrors clears all errors
+func (rlc *Checker) clearErrors() {
+	rlc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (rlc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	rlc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := rlc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(rlc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + rlc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(rlc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + rlc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + rlc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists Ingresses and sets an error for every internet facing
+// Ingress without an acceptable rate limit
+func (rlc *Checker) doChecks() error {
+
+	ingresses, err := rlc.client.ExtensionsV1beta1().Ingresses(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing Ingresses: " + err.Error())
+	}
+
+	rateLimitErrors := evaluateIngresses(ingresses.Items, rlc.InternetFacingClasses, rlc.MaxRequestsPerSecond)
+
+	if len(rateLimitErrors) > 0 {
+		for _, e := range rateLimitErrors {
+			khlog.ForCheck(rlc.Name()).Warningln(e)
+		}
+		rlc.Errors = rateLimitErrors
+		return nil
+	}
+
+	rlc.clearErrors()
+	return nil
+}
+
+// evaluateIngresses returns an error for every internet facing Ingress that
+// has no rate limit annotation, has an invalid one, or allows more than
+// maxRequestsPerSecond.  Limits per minute are converted to per second.
+func evaluateIngresses(ingresses []extensionsv1beta1.Ingress, internetFacingClasses []string, maxRequestsPerSecond float64) []string {
+	var rateLimitErrors []string
+
+	internetFacing := make(map[string]bool)
+	for _, c := range internetFacingClasses {
+		internetFacing[c] = true
+	}
+
+	var annotations []string
+	for a := range rateLimitAnnotations {
+		annotations = append(annotations, a)
+	}
+	sort.Strings(annotations)
+
+	sort.Slice(ingresses, func(i, j int) bool {
+		if ingresses[i].Namespace != ingresses[j].Namespace {
+			return ingresses[i].Namespace < ingresses[j].Namespace
+		}
+		return ingresses[i].Name < ingresses[j].Name
+	})
+
+	for _, ing := range ingresses {
+		if ing.Annotations[InternetFacingAnnotation] != "true" && !internetFacing[ing.Annotations[IngressClassAnnotation]] {
+			continue
+		}
+		name := "Internet facing Ingress " + ing.Name + " in namespace " + ing.Namespace
+
+		var limited bool
+		for _, a := range annotations {
+			v, ok := ing.Annotations[a]
+			if !ok {
+				continue
+			}
+			limited = true
+			limit, err := strconv.ParseFloat(v, 64)
+			if err != nil || limit <= 0 {
+				rateLimitErrors = append(rateLimitErrors, name+" has an invalid "+a+" annotation: "+v)
+				continue
+			}
+			perSecond := limit / rateLimitAnnotations[a]
+			if perSecond > maxRequestsPerSecond {
+				rateLimitErrors = append(rateLimitErrors, name+" has a rate limit of "+strconv.FormatFloat(perSecond, 'f', -1, 64)+
+					" requests per second from "+a+" which is more than the maximum of "+strconv.FormatFloat(maxRequestsPerSecond, 'f', -1, 64))
+			}
+		}
+		if !limited {
+			rateLimitErrors = append(rateLimitErrors, name+" does not have a rate limit annotation")
+		}
+	}
+	return rateLimitErrors
+}
@@ -0,0 +1,44 @@
+package rateLimitConfig
+
+import (
+	"strings"
+	"testing"
+
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateIngresses(t *testing.T) {
+	makeIngress := func(annotations map[string]string) extensionsv1beta1.Ingress {
+		return extensionsv1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: annotations}}
+	}
+
+	var tests = []struct {
+		description   string
+		ingress       extensionsv1beta1.Ingress
+		expectedError string
+	}{
+		{"internal Ingress without a limit", makeIngress(map[string]string{IngressClassAnnotation: "nginx-internal"}), ""},
+		{"internet facing class with a limit", makeIngress(map[string]string{IngressClassAnnotation: "nginx-public", "nginx.ingress.kubernetes.io/limit-rps": "100"}), ""},
+		{"internet facing annotation with a per minute limit", makeIngress(map[string]string{InternetFacingAnnotation: "true", "nginx.ingress.kubernetes.io/limit-rpm": "6000"}), ""},
+		{"internet facing class without a limit", makeIngress(map[string]string{IngressClassAnnotation: "nginx-public"}), "Internet facing Ingress web in namespace default does not have a rate limit annotation"},
+		{"internet facing annotation without a limit", makeIngress(map[string]string{InternetFacingAnnotation: "true"}), "does not have a rate limit annotation"},
+		{"limit too high", makeIngress(map[string]string{InternetFacingAnnotation: "true", "nginx.ingress.kubernetes.io/limit-rps": "5000"}), "has a rate limit of 5000 requests per second from nginx.ingress.kubernetes.io/limit-rps which is more than the maximum of 1000"},
+		{"per minute limit too high", makeIngress(map[string]string{InternetFacingAnnotation: "true", "nginx.ingress.kubernetes.io/limit-rpm": "120000"}), "has a rate limit of 2000 requests per second"},
+		{"invalid limit", makeIngress(map[string]string{InternetFacingAnnotation: "true", "nginx.ingress.kubernetes.io/limit-rps": "lots"}), "has an invalid nginx.ingress.kubernetes.io/limit-rps annotation: lots"},
+	}
+
+	for _, test := range tests {
+		rateLimitErrors := evaluateIngresses([]extensionsv1beta1.Ingress{test.ingress}, []string{"nginx-public"}, 1000)
+		if len(test.expectedError) == 0 {
+			if len(rateLimitErrors) != 0 {
+				t.Fatal("Test", test.description, "expected no errors but got", rateLimitErrors)
+			}
+			continue
+		}
+		if len(rateLimitErrors) != 1 || !strings.Contains(rateLimitErrors[0], test.expectedError) {
+			t.Fatal("Test", test.description, "expected an error containing", test.expectedError, "but got", rateLimitErrors)
+		}
+		t.Log(test.description, rateLimitErrors)
+	}
+}
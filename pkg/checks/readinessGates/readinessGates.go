@@ -0,0 +1,184 @@
+// Package readinessGates implements a checker that finds pods whose
+// readiness gates are not being honored.  A pod with readiness gates never
+// becomes Ready until a controller sets a status condition for each gate, so
+// a missing controller leaves the pod unready forever.
+package readinessGates // import "github.com/Comcast/kuberhealthy/pkg/checks/readinessGates"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Checker validates that the readiness gates of pods have their conditions
+// set
+type Checker struct {
+	Errors      []string
+	GateTimeout time.Duration
+	client      *kubernetes.Clientset
+	interval    time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reports pods with a readiness gate
+// condition missing for longer than gateTimeout
+func New(gateTimeout time.Duration) *Checker {
+	return &Checker{
+		Errors:      []string{},
+		GateTimeout: gateTimeout,
+	}
+}
+
+// Name returns the name of this checker
+func (rgc *Checker) Name() string {
+	return "ReadinessGateChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (rgc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (rgc *Checker) Interval() time.Duration {
+	if rgc.interval > 0 {
+		return rgc.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (rgc *Checker) SetInterval(d time.Duration) {
+	rgc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to list the pods of
+// every namespace
+func (rgc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (rgc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (rgc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (rgc *Checker) CurrentStatus() (bool, []string) {
+	if len(rgc.Errors) > 0 {
+		return false, rgc.Errors
+	}
+	return true, rgc.Errors
+}
+
+// clearErrors clears all errors
+func (rgc *Checker) clearErrors() {
+	rgc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (rgc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	rgc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := rgc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(rgc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + rgc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(rgc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + rgc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + rgc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists pods in all namespaces and sets an error for every pod
+// with a readiness gate condition that has been missing for too long
+func (rgc *Checker) doChecks() error {
+
+	pods, err := rgc.client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return errors.New("Error listing pods: " + err.Error())
+	}
+
+	gateErrors := evaluatePods(pods.Items, rgc.GateTimeout, time.Now())
+
+	if len(gateErrors) > 0 {
+		for _, e := range gateErrors {
+			khlog.ForCheck(rgc.Name()).Warningln(e)
+		}
+		rgc.Errors = gateErrors
+		return nil
+	}
+
+	rgc.clearErrors()
+	return nil
+}
+
+// evaluatePods returns an error for every readiness gate of a running pod
+// whose condition is missing from the pod status more than gateTimeout
+// after the pod was created.  Pods that have finished are skipped.
+func evaluatePods(pods []apiv1.Pod, gateTimeout time.Duration, now time.Time) []string {
+	var gateErrors []string
+
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		return pods[i].Name < pods[j].Name
+	})
+
+	for _, p := range pods {
+		if len(p.Spec.ReadinessGates) == 0 {
+			continue
+		}
+		if p.Status.Phase == apiv1.PodSucceeded || p.Status.Phase == apiv1.PodFailed {
+			continue
+		}
+		missingFor := now.Sub(p.CreationTimestamp.Time)
+		if missingFor <= gateTimeout {
+			continue
+		}
+
+		conditions := make(map[apiv1.PodConditionType]bool)
+		for _, c := range p.Status.Conditions {
+			conditions[c.Type] = true
+		}
+		for _, g := range p.Spec.ReadinessGates {
+			if !conditions[g.ConditionType] {
+				gateErrors = append(gateErrors, "Pod "+p.Name+" in namespace "+p.Namespace+" has been missing the "+string(g.ConditionType)+
+					" readiness gate condition for "+missingFor.Round(time.Second).String())
+			}
+		}
+	}
+	return gateErrors
+}
@@ -0,0 +1,58 @@
+package readinessGates
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluatePods(t *testing.T) {
+	now := time.Now()
+
+	makePod := func(age time.Duration, phase apiv1.PodPhase, gates []string, conditions []string) apiv1.Pod {
+		p := apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "web",
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			},
+			Status: apiv1.PodStatus{Phase: phase},
+		}
+		for _, g := range gates {
+			p.Spec.ReadinessGates = append(p.Spec.ReadinessGates, apiv1.PodReadinessGate{ConditionType: apiv1.PodConditionType(g)})
+		}
+		for _, c := range conditions {
+			p.Status.Conditions = append(p.Status.Conditions, apiv1.PodCondition{Type: apiv1.PodConditionType(c), Status: apiv1.ConditionFalse})
+		}
+		return p
+	}
+
+	var tests = []struct {
+		description    string
+		pod            apiv1.Pod
+		expectedErrors []string
+	}{
+		{"no readiness gates", makePod(time.Hour, apiv1.PodRunning, nil, nil), nil},
+		{"gate condition set", makePod(time.Hour, apiv1.PodRunning, []string{"example.com/ready"}, []string{"Ready", "example.com/ready"}), nil},
+		{"gate condition missing", makePod(time.Hour, apiv1.PodRunning, []string{"example.com/ready"}, []string{"Ready"}), []string{"Pod web in namespace default has been missing the example.com/ready readiness gate condition for 1h0m0s"}},
+		{"one of two gate conditions missing", makePod(time.Hour, apiv1.PodRunning, []string{"example.com/ready", "example.com/synced"}, []string{"example.com/ready"}), []string{"example.com/synced"}},
+		{"new pod", makePod(time.Minute, apiv1.PodPending, []string{"example.com/ready"}, nil), nil},
+		{"finished pod", makePod(time.Hour, apiv1.PodSucceeded, []string{"example.com/ready"}, nil), nil},
+	}
+
+	for _, test := range tests {
+		gateErrors := evaluatePods([]apiv1.Pod{test.pod}, time.Minute*5, now)
+		if len(gateErrors) != len(test.expectedErrors) {
+			t.Fatal("Test", test.description, "expected", len(test.expectedErrors), "errors but got", gateErrors)
+		}
+		for i, expected := range test.expectedErrors {
+			if !strings.Contains(gateErrors[i], expected) {
+				t.Fatal("Test", test.description, "expected an error containing", expected, "but got", gateErrors[i])
+			}
+		}
+		t.Log(test.description, gateErrors)
+	}
+}
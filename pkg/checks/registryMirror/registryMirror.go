@@ -0,0 +1,308 @@
+// Package registryMirror implements a checker that ensures a pull-through
+// registry mirror is reachable and caching.  A small image is fetched from
+// both the upstream registry and the mirror.  Their manifest digests must
+// match and the mirror must serve a cached image layer faster than the
+// upstream registry.
+package registryMirror // import "github.com/Comcast/kuberhealthy/pkg/checks/registryMirror"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/registry"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// manifest media types requested from registries
+const (
+	mediaTypeV2          = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeV2List      = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex    = "application/vnd.oci.image.index.v1+json"
+)
+
+// manifestAccept is the Accept header of manifest requests
+var manifestAccept = strings.Join([]string{mediaTypeV2, mediaTypeV2List, mediaTypeOCIManifest, mediaTypeOCIIndex}, ", ")
+
+// manifest is the subset of an image manifest or manifest list used to find
+// a layer
+type manifest struct {
+	MediaType string `json:"mediaType"`
+	Layers    []struct {
+		Digest string `json:"digest"`
+		Size   int64  `json:"size"`
+	} `json:"layers"`
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			Architecture string `json:"architecture"`
+			OS           string `json:"os"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+// Checker validates that a registry mirror serves the same images as the
+// upstream registry faster than it
+type Checker struct {
+	Errors      []string
+	MirrorURL   string
+	UpstreamURL string
+	Repository  string
+	Tag         string
+	client      *kubernetes.Clientset
+	httpClient  *http.Client
+	interval    time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the mirror at mirrorURL.  Docker Hub is used
+// as the upstream registry.
+func New(mirrorURL string) (*Checker, error) {
+	u, err := url.Parse(mirrorURL)
+	if err != nil {
+		return nil, errors.New("invalid registry mirror URL " + mirrorURL + ": " + err.Error())
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return nil, errors.New("invalid registry mirror URL " + mirrorURL + ": an http or https URL is required")
+	}
+
+	return &Checker{
+		Errors:      []string{},
+		MirrorURL:   strings.TrimSuffix(mirrorURL, "/"),
+		UpstreamURL: "https://registry-1.docker.io",
+		Repository:  "library/busybox",
+		Tag:         "1.30",
+		httpClient:  &http.Client{Timeout: time.Minute * 1},
+	}, nil
+}
+
+// Name returns the name of this checker
+func (rmc *Checker) Name() string {
+	return "RegistryMirrorChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (rmc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (rmc *Checker) Interval() time.Duration {
+	if rmc.interval > 0 {
+		return rmc.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (rmc *Checker) SetInterval(d time.Duration) {
+	rmc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Only the registries
+// are queried, so none are needed.
+func (rmc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (rmc *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (rmc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (rmc *Checker) CurrentStatus() (bool, []string) {
+	if len(rmc.Errors) > 0 {
+		return false, rmc.Errors
+	}
+	return true, rmc.Errors
+}
+
+// clearErrors clears all errors
+func (rmc *Checker) clearErrors() {
+	rmc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (rmc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	rmc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := rmc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(rmc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + rmc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(rmc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + rmc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + rmc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks compares the image manifest digests of the mirror and upstream
+// registry, then times a layer download from each.  The mirror is asked for
+// the layer once before it is timed so that it is cached.
+func (rmc *Checker) doChecks() error {
+
+	image := rmc.Repository + ":" + rmc.Tag
+	upstreamDigest, layer, err := rmc.fetchManifest(rmc.UpstreamURL)
+	if err != nil {
+		return errors.New("Error fetching " + image + " from the upstream registry: " + err.Error())
+	}
+
+	var mirrorErrors []string
+	var upstreamDuration time.Duration
+	mirrorDigest, mirrorDuration, err := rmc.probeMirror(layer)
+	if err != nil {
+		mirrorErrors = append(mirrorErrors, "Registry mirror "+rmc.MirrorURL+" is unreachable: "+err.Error())
+	} else {
+		upstreamDuration, err = rmc.timeBlob(rmc.UpstreamURL, layer)
+		if err != nil {
+			return errors.New("Error fetching a layer of " + image + " from the upstream registry: " + err.Error())
+		}
+		mirrorErrors = evaluateMirror(image, upstreamDigest, mirrorDigest, upstreamDuration, mirrorDuration)
+	}
+
+	if len(mirrorErrors) > 0 {
+		for _, e := range mirrorErrors {
+			khlog.ForCheck(rmc.Name()).Warningln(e)
+		}
+		rmc.Errors = mirrorErrors
+		return nil
+	}
+
+	khlog.ForCheck(rmc.Name()).Debugln("mirror served", image, "layer in", mirrorDuration, "and upstream in", upstreamDuration)
+	rmc.clearErrors()
+	return nil
+}
+
+// probeMirror returns the manifest digest served by the mirror and how long
+// it took to serve the layer once it was cached
+func (rmc *Checker) probeMirror(layer string) (string, time.Duration, error) {
+	digest, _, err := rmc.fetchManifest(rmc.MirrorURL)
+	if err != nil {
+		return "", 0, err
+	}
+	_, err = rmc.timeBlob(rmc.MirrorURL, layer)
+	if err != nil {
+		return "", 0, err
+	}
+	duration, err := rmc.timeBlob(rmc.MirrorURL, layer)
+	return digest, duration, err
+}
+
+// evaluateMirror returns an error if the mirror serves a different manifest
+// than the upstream registry or is not faster than it
+func evaluateMirror(image string, upstreamDigest string, mirrorDigest string, upstreamDuration time.Duration, mirrorDuration time.Duration) []string {
+	var mirrorErrors []string
+	if mirrorDigest != upstreamDigest {
+		mirrorErrors = append(mirrorErrors, "Registry mirror serves "+image+" with digest "+mirrorDigest+" but the upstream registry serves digest "+upstreamDigest)
+	}
+	if mirrorDuration >= upstreamDuration {
+		mirrorErrors = append(mirrorErrors, "Registry mirror served a cached layer of "+image+" in "+mirrorDuration.String()+", no faster than the upstream registry in "+upstreamDuration.String())
+	}
+	return mirrorErrors
+}
+
+// fetchManifest returns the digest of the image manifest served by a
+// registry along with its smallest layer.  Manifest lists are resolved to
+// their linux/amd64 manifest to find the layer.
+func (rmc *Checker) fetchManifest(registryURL string) (string, string, error) {
+	m, digest, err := rmc.getManifest(registryURL, rmc.Tag)
+	if err != nil {
+		return "", "", err
+	}
+
+	if len(m.Manifests) > 0 {
+		platformDigest := m.Manifests[0].Digest
+		for _, pm := range m.Manifests {
+			if pm.Platform.OS == "linux" && pm.Platform.Architecture == "amd64" {
+				platformDigest = pm.Digest
+				break
+			}
+		}
+		m, _, err = rmc.getManifest(registryURL, platformDigest)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	if len(m.Layers) == 0 {
+		return "", "", errors.New("manifest of " + rmc.Repository + " has no layers")
+	}
+	smallest := m.Layers[0]
+	for _, l := range m.Layers[1:] {
+		if l.Size < smallest.Size {
+			smallest = l
+		}
+	}
+	return digest, smallest.Digest, nil
+}
+
+// getManifest fetches and decodes a manifest by tag or digest and returns it
+// with the digest the registry reported for it
+func (rmc *Checker) getManifest(registryURL string, reference string) (manifest, string, error) {
+	var m manifest
+	manifestURL := registryURL + "/v2/" + rmc.Repository + "/manifests/" + reference
+	resp, err := registry.Get(rmc.httpClient, manifestURL, manifestAccept, nil)
+	if err != nil {
+		return m, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return m, "", errors.New("registry returned status " + strconv.Itoa(resp.StatusCode) + " for " + manifestURL)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&m)
+	if err != nil {
+		return m, "", errors.New("Error decoding manifest from " + manifestURL + ": " + err.Error())
+	}
+	return m, resp.Header.Get("Docker-Content-Digest"), nil
+}
+
+// timeBlob downloads a blob from a registry and returns how long it took
+func (rmc *Checker) timeBlob(registryURL string, digest string) (time.Duration, error) {
+	blobURL := registryURL + "/v2/" + rmc.Repository + "/blobs/" + digest
+	start := time.Now()
+	resp, err := registry.Get(rmc.httpClient, blobURL, "", nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, errors.New("registry returned status " + strconv.Itoa(resp.StatusCode) + " for " + blobURL)
+	}
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
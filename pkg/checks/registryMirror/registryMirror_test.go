@@ -0,0 +1,95 @@
+package registryMirror
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// registryServer returns a registry that serves busybox as a manifest list
+// with the supplied digest and serves its layer after a delay
+func registryServer(digest string, layerDelay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/library/busybox/manifests/1.30":
+			w.Header().Set("Content-Type", mediaTypeV2List)
+			w.Header().Set("Docker-Content-Digest", digest)
+			w.Write([]byte(`{"mediaType": "` + mediaTypeV2List + `", "manifests": [
+				{"digest": "sha256:arm", "platform": {"architecture": "arm64", "os": "linux"}},
+				{"digest": "sha256:amd", "platform": {"architecture": "amd64", "os": "linux"}}]}`))
+		case "/v2/library/busybox/manifests/sha256:amd":
+			w.Header().Set("Content-Type", mediaTypeV2)
+			w.Write([]byte(`{"mediaType": "` + mediaTypeV2 + `", "layers": [
+				{"digest": "sha256:large", "size": 5000000},
+				{"digest": "sha256:small", "size": 700000}]}`))
+		case "/v2/library/busybox/blobs/sha256:small":
+			time.Sleep(layerDelay)
+			w.Write([]byte("layer"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestDoChecks(t *testing.T) {
+	var tests = []struct {
+		description  string
+		mirrorDigest string
+		mirrorDelay  time.Duration
+		mirrorDown   bool
+		expected     int
+	}{
+		{"caching mirror", "sha256:list", 0, false, 0},
+		{"slow mirror", "sha256:list", time.Millisecond * 100, false, 1},
+		{"stale mirror", "sha256:stale", 0, false, 1},
+		{"unreachable mirror", "sha256:list", 0, true, 1},
+	}
+
+	upstream := registryServer("sha256:list", time.Millisecond*50)
+	defer upstream.Close()
+
+	for _, test := range tests {
+		mirror := registryServer(test.mirrorDigest, test.mirrorDelay)
+		if test.mirrorDown {
+			mirror.Close()
+		}
+
+		checker, err := New(mirror.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checker.UpstreamURL = upstream.URL
+		err = checker.doChecks()
+		mirror.Close()
+		if err != nil {
+			t.Fatal("Test", test.description, "returned an error:", err)
+		}
+		if len(checker.Errors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", checker.Errors)
+		}
+		t.Log(test.description, checker.Errors)
+	}
+
+	// an unreachable upstream registry can not be compared against
+	checker, err := New(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker.UpstreamURL = "http://127.0.0.1:1"
+	if err := checker.doChecks(); err == nil {
+		t.Fatal("Expected an error when the upstream registry is unreachable")
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, mirrorURL := range []string{"registry-mirror.local:5000", "ftp://mirror", "https://"} {
+		if _, err := New(mirrorURL); err == nil {
+			t.Fatal("Expected an error for mirror URL", mirrorURL)
+		}
+	}
+	checker, err := New("https://mirror.example.com/")
+	if err != nil || checker.MirrorURL != "https://mirror.example.com" {
+		t.Fatal("Unexpected checker for a valid mirror URL:", checker, err)
+	}
+}
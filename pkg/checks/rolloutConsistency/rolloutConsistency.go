@@ -0,0 +1,219 @@
+// Package rolloutConsistency implements a checker that finds Deployments
+// stuck in the middle of a rolling update.  A paused or stalled rollout
+// leaves pods from more than one pod template running at the same time.
+package rolloutConsistency // import "github.com/Comcast/kuberhealthy/pkg/checks/rolloutConsistency"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// templateHashLabel is set on every pod by the ReplicaSet of its Deployment
+const templateHashLabel = "pod-template-hash"
+
+// Checker validates that Deployments do not run pods from more than one pod
+// template for too long
+type Checker struct {
+	Errors         []string
+	RolloutTimeout time.Duration
+	client         *kubernetes.Clientset
+	interval       time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reports Deployments that have run pods
+// from more than one pod template for longer than rolloutTimeout
+func New(rolloutTimeout time.Duration) *Checker {
+	return &Checker{
+		Errors:         []string{},
+		RolloutTimeout: rolloutTimeout,
+	}
+}
+
+// Name returns the name of this checker
+func (rcc *Checker) Name() string {
+	return "RolloutConsistencyChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (rcc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (rcc *Checker) Interval() time.Duration {
+	if rcc.interval > 0 {
+		return rcc.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (rcc *Checker) SetInterval(d time.Duration) {
+	rcc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Deployments and their
+// pods are listed in every namespace.
+func (rcc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"list"}},
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (rcc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (rcc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (rcc *Checker) CurrentStatus() (bool, []string) {
+	if len(rcc.Errors) > 0 {
+		return false, rcc.Errors
+	}
+	return true, rcc.Errors
+}
+
+// clearErrors clears all errors
+func (rcc *Checker) clearErrors() {
+	rcc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (rcc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	rcc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := rcc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(rcc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + rcc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(rcc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + rcc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + rcc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists Deployments and pods in all namespaces and sets an error for
+// every Deployment that has run pods from more than one pod template for
+// longer than the rollout timeout
+func (rcc *Checker) doChecks() error {
+
+	deployments, err := rcc.client.AppsV1().Deployments(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	// only pods created by a Deployment have a template hash
+	pods, err := rcc.client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{
+		LabelSelector: templateHashLabel,
+	})
+	if err != nil {
+		return err
+	}
+
+	rolloutErrors, err := evaluateDeployments(deployments.Items, pods.Items, rcc.RolloutTimeout, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if len(rolloutErrors) > 0 {
+		for _, e := range rolloutErrors {
+			khlog.ForCheck(rcc.Name()).Warningln(e)
+		}
+		rcc.Errors = rolloutErrors
+		return nil
+	}
+
+	rcc.clearErrors()
+	return nil
+}
+
+// evaluateDeployments returns an error for every Deployment with active pods
+// from more than one pod template when the newest template's first pod was
+// created longer than rolloutTimeout ago
+func evaluateDeployments(deployments []appsv1.Deployment, pods []apiv1.Pod, rolloutTimeout time.Duration, now time.Time) ([]string, error) {
+	var rolloutErrors []string
+
+	for _, d := range deployments {
+		selector, err := metav1.LabelSelectorAsSelector(d.Spec.Selector)
+		if err != nil {
+			return nil, errors.New("Error parsing selector of Deployment " + d.Namespace + "/" + d.Name + ": " + err.Error())
+		}
+
+		// the earliest creation time of the active pods of each template
+		firstCreated := make(map[string]time.Time)
+		for _, p := range pods {
+			if p.Namespace != d.Namespace || !selector.Matches(labels.Set(p.Labels)) || !podActive(p) {
+				continue
+			}
+			hash := p.Labels[templateHashLabel]
+			if created, ok := firstCreated[hash]; !ok || p.CreationTimestamp.Time.Before(created) {
+				firstCreated[hash] = p.CreationTimestamp.Time
+			}
+		}
+		if len(firstCreated) < 2 {
+			continue
+		}
+
+		// the rollout started when the first pod of the newest template was
+		// created
+		var hashes []string
+		var rolloutStarted time.Time
+		for hash, created := range firstCreated {
+			hashes = append(hashes, hash)
+			if created.After(rolloutStarted) {
+				rolloutStarted = created
+			}
+		}
+		sort.Strings(hashes)
+
+		age := now.Sub(rolloutStarted)
+		if age < rolloutTimeout {
+			continue
+		}
+		rolloutErrors = append(rolloutErrors, "Deployment "+d.Namespace+"/"+d.Name+" has been running pods from pod templates "+strings.Join(hashes, ", ")+" for "+age.Round(time.Second).String())
+	}
+	return rolloutErrors, nil
+}
+
+// podActive returns true if a pod has not finished and is not terminating
+func podActive(pod apiv1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+	return pod.Status.Phase != apiv1.PodSucceeded && pod.Status.Phase != apiv1.PodFailed
+}
@@ -0,0 +1,80 @@
+package rolloutConsistency
+
+import (
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateDeployments(t *testing.T) {
+	now := time.Now()
+
+	deployment := appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+	}
+
+	makePod := func(namespace string, app string, hash string, age time.Duration) apiv1.Pod {
+		return apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         namespace,
+				Name:              app + "-" + hash,
+				Labels:            map[string]string{"app": app, templateHashLabel: hash},
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			},
+			Status: apiv1.PodStatus{Phase: apiv1.PodRunning},
+		}
+	}
+
+	terminating := makePod("default", "web", "5d8f7c9b4", time.Hour)
+	deleted := metav1.NewTime(now)
+	terminating.DeletionTimestamp = &deleted
+
+	var tests = []struct {
+		description string
+		pods        []apiv1.Pod
+		expected    int
+	}{
+		{"single template", []apiv1.Pod{
+			makePod("default", "web", "7c6d9f8b5", time.Hour),
+			makePod("default", "web", "7c6d9f8b5", time.Minute*30),
+		}, 0},
+		{"rollout in progress", []apiv1.Pod{
+			makePod("default", "web", "5d8f7c9b4", time.Hour*24),
+			makePod("default", "web", "7c6d9f8b5", time.Minute*5),
+		}, 0},
+		{"stuck rollout", []apiv1.Pod{
+			makePod("default", "web", "5d8f7c9b4", time.Hour*24),
+			makePod("default", "web", "7c6d9f8b5", time.Minute*30),
+			makePod("default", "web", "7c6d9f8b5", time.Minute*2),
+		}, 1},
+		{"old template terminating", []apiv1.Pod{
+			terminating,
+			makePod("default", "web", "7c6d9f8b5", time.Minute*30),
+		}, 0},
+		{"other deployment's pods", []apiv1.Pod{
+			makePod("default", "api", "5d8f7c9b4", time.Hour*24),
+			makePod("default", "web", "7c6d9f8b5", time.Minute*30),
+		}, 0},
+		{"other namespace's pods", []apiv1.Pod{
+			makePod("staging", "web", "5d8f7c9b4", time.Hour*24),
+			makePod("default", "web", "7c6d9f8b5", time.Minute*30),
+		}, 0},
+	}
+
+	for _, test := range tests {
+		rolloutErrors, err := evaluateDeployments([]appsv1.Deployment{deployment}, test.pods, time.Minute*15, now)
+		if err != nil {
+			t.Fatal("Test", test.description, "returned an error:", err)
+		}
+		if len(rolloutErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", rolloutErrors)
+		}
+		t.Log(test.description, rolloutErrors)
+	}
+}
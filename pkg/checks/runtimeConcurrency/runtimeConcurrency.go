@@ -0,0 +1,268 @@
+// Package runtimeConcurrency implements a checker that detects container
+// runtimes that are saturated with concurrent operations.  When a runtime
+// reaches its limit, operations queue and the slowest operations take much
+// longer than typical ones.  A DaemonSet pod on each node reads the kubelet's
+// runtime operation latency histograms and the ratio of their p99 and p50
+// latency is compared against a threshold.
+package runtimeConcurrency // import "github.com/Comcast/kuberhealthy/pkg/checks/runtimeConcurrency"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/metrics"
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	"github.com/Comcast/kuberhealthy/pkg/promParser"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// operationsMetric is the kubelet histogram of container runtime operation
+// latency
+const operationsMetric = "kubelet_runtime_operations_duration_seconds"
+
+// metricsScript fetches the runtime operation buckets from the kubelet on the
+// node the pod runs on, authenticating with the pod's service account
+const metricsScript = `curl -sk -H "Authorization: Bearer $(cat /var/run/secrets/kubernetes.io/serviceaccount/token)" https://127.0.0.1:10250/metrics | grep '^` + operationsMetric + `_bucket'`
+
+// Checker validates that container runtime latency does not indicate
+// saturation
+type Checker struct {
+	Errors       []string
+	LatencyRatio float64
+	Image        string
+	client       *kubernetes.Clientset
+	metricClient metrics.Client
+	// previous holds the histograms of the last run by node and operation so
+	// that each run evaluates only new operations
+	previous map[string]map[string]promParser.Histogram
+	// runOnNodes is replaced in tests to inject pod output
+	runOnNodes func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (map[string]string, error)
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reports nodes where the ratio of p99 to p50
+// runtime operation latency exceeds latencyRatio.  Latencies are pushed to
+// the metric client when it is not nil.
+func New(latencyRatio float64, metricClient metrics.Client) *Checker {
+	return &Checker{
+		Errors:       []string{},
+		LatencyRatio: latencyRatio,
+		Image:        "curlimages/curl:7.66.0",
+		metricClient: metricClient,
+		previous:     make(map[string]map[string]promParser.Histogram),
+		runOnNodes:   podRunner.RunOnNodes,
+	}
+}
+
+// Name returns the name of this checker
+func (rcc *Checker) Name() string {
+	return "RuntimeConcurrencyChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (rcc *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (rcc *Checker) Interval() time.Duration {
+	if rcc.interval > 0 {
+		return rcc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (rcc *Checker) SetInterval(d time.Duration) {
+	rcc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  The kubelet authorizes
+// the metrics requests made with Kuberhealthy's service account against the
+// metrics of nodes, which are cluster scoped.
+func (rcc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		namespace: append(podRunner.RunOnNodesRBACRules(),
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+		),
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes/metrics"}, Verbs: []string{"get"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (rcc *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (rcc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (rcc *Checker) CurrentStatus() (bool, []string) {
+	if len(rcc.Errors) > 0 {
+		return false, rcc.Errors
+	}
+	return true, rcc.Errors
+}
+
+// clearErrors clears all errors
+func (rcc *Checker) clearErrors() {
+	rcc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (rcc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	rcc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := rcc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(rcc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + rcc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(rcc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + rcc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + rcc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks reads the runtime operation histograms of every node and sets an
+// error for every node with saturated runtime operations
+func (rcc *Checker) doChecks() error {
+
+	// the kubelet authorizes the metrics request with kuberhealthy's own
+	// service account
+	serviceAccount, err := podRunner.CurrentServiceAccount(rcc.client, namespace)
+	if err != nil {
+		return err
+	}
+
+	script := podRunner.Script{
+		Name:           "runtime-concurrency",
+		Image:          rcc.Image,
+		Script:         metricsScript,
+		HostNetwork:    true,
+		ServiceAccount: serviceAccount,
+	}
+
+	// leave time to clean up the DaemonSet before the check times out
+	output, err := rcc.runOnNodes(rcc.client, namespace, script, rcc.Timeout()-time.Minute)
+	if err != nil && len(output) == 0 {
+		return err
+	}
+
+	var latencyErrors []string
+	if err != nil {
+		latencyErrors = append(latencyErrors, err.Error())
+	}
+	latencyErrors = append(latencyErrors, rcc.evaluateNodes(output)...)
+
+	if len(latencyErrors) > 0 {
+		for _, e := range latencyErrors {
+			khlog.ForCheck(rcc.Name()).Warningln(e)
+		}
+		rcc.Errors = latencyErrors
+		return nil
+	}
+
+	rcc.clearErrors()
+	return nil
+}
+
+// evaluateNodes calculates the p50 and p99 latency of each runtime operation
+// on each node since the previous run, pushes them to the metric client, and
+// returns an error for every operation whose p99 to p50 ratio exceeds the
+// threshold
+func (rcc *Checker) evaluateNodes(output map[string]string) []string {
+	var latencyErrors []string
+
+	var nodes []string
+	for node := range output {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	for _, node := range nodes {
+		samples, err := promParser.Parse(strings.NewReader(output[node]))
+		if err != nil {
+			latencyErrors = append(latencyErrors, "Error parsing kubelet metrics of node "+node+": "+err.Error())
+			continue
+		}
+		histograms := promParser.ParseHistograms(promParser.Filter(samples, operationsMetric+"_bucket"), "operation_type")
+		if len(histograms) == 0 {
+			latencyErrors = append(latencyErrors, "Node "+node+" kubelet did not report "+operationsMetric)
+			continue
+		}
+
+		var operations []string
+		for operation := range histograms {
+			operations = append(operations, operation)
+		}
+		sort.Strings(operations)
+
+		for _, operation := range operations {
+			h := histograms[operation].Since(rcc.previous[node][operation])
+			p50 := h.Quantile(0.5)
+			p99 := h.Quantile(0.99)
+			if math.IsNaN(p50) || math.IsNaN(p99) || p50 <= 0 {
+				continue
+			}
+
+			rcc.pushLatency(node, operation, p50, p99)
+			ratio := p99 / p50
+			if ratio > rcc.LatencyRatio {
+				latencyErrors = append(latencyErrors, fmt.Sprintf("Node %s container runtime %s operations may be queuing: p99 latency %.3fs is %.1fx the p50 latency %.3fs",
+					node, operation, p99, ratio, p50))
+			}
+		}
+		rcc.previous[node] = histograms
+	}
+	return latencyErrors
+}
+
+// pushLatency sends the latency of a runtime operation to the metric client
+func (rcc *Checker) pushLatency(node string, operation string, p50 float64, p99 float64) {
+	if rcc.metricClient == nil {
+		return
+	}
+	metric := metrics.Metric{
+		{rcc.Name() + "_p50_seconds": p50},
+		{rcc.Name() + "_p99_seconds": p99},
+	}
+	tags := map[string]string{
+		"Node":      node,
+		"Operation": operation,
+	}
+	err := rcc.metricClient.Push(metric, tags)
+	if err != nil {
+		khlog.ForCheck(rcc.Name()).Errorln("Error forwarding metrics", err)
+	}
+}
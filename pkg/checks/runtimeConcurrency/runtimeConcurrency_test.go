@@ -0,0 +1,78 @@
+package runtimeConcurrency
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/kuberhealthy/pkg/metrics"
+)
+
+// fakeMetricClient records pushed metrics
+type fakeMetricClient struct {
+	pushed []map[string]string
+}
+
+// Push records the tags of each push
+func (f *fakeMetricClient) Push(points metrics.Metric, tags map[string]string) error {
+	f.pushed = append(f.pushed, tags)
+	return nil
+}
+
+// SetGlobalTags is implemented to satisfy metrics.Client but is not used
+func (f *fakeMetricClient) SetGlobalTags(tags map[string]string) {}
+
+// makeBuckets renders cumulative histogram buckets for an operation in
+// Prometheus text format
+func makeBuckets(operation string, counts map[string]float64) string {
+	var lines []string
+	for _, le := range []string{"0.005", "0.01", "0.1", "1", "10", "+Inf"} {
+		lines = append(lines, fmt.Sprintf(`kubelet_runtime_operations_duration_seconds_bucket{operation_type="%s",le="%s"} %v`, operation, le, counts[le]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestEvaluateNodes(t *testing.T) {
+	healthy := makeBuckets("create_container", map[string]float64{"0.005": 10, "0.01": 100, "0.1": 100, "1": 100, "10": 100, "+Inf": 100})
+	saturated := makeBuckets("create_container", map[string]float64{"0.005": 10, "0.01": 60, "0.1": 80, "1": 90, "10": 100, "+Inf": 100})
+
+	var tests = []struct {
+		description string
+		output      map[string]string
+		expected    int
+	}{
+		{"healthy", map[string]string{"node-a": healthy}, 0},
+		{"saturated", map[string]string{"node-a": healthy, "node-b": saturated}, 1},
+		{"missing metric", map[string]string{"node-a": ""}, 1},
+	}
+
+	for _, test := range tests {
+		metricClient := &fakeMetricClient{}
+		checker := New(5, metricClient)
+		latencyErrors := checker.evaluateNodes(test.output)
+		if len(latencyErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", latencyErrors)
+		}
+		t.Log(test.description, latencyErrors, metricClient.pushed)
+	}
+}
+
+func TestEvaluateNodesSincePreviousRun(t *testing.T) {
+	metricClient := &fakeMetricClient{}
+	checker := New(5, metricClient)
+
+	// the first run has slow operations from a previous incident
+	first := makeBuckets("pull_image", map[string]float64{"0.005": 10, "0.01": 50, "0.1": 60, "1": 70, "10": 100, "+Inf": 100})
+	if latencyErrors := checker.evaluateNodes(map[string]string{"node-a": first}); len(latencyErrors) != 1 {
+		t.Fatal("Expected the first run to report saturation but got", latencyErrors)
+	}
+	if len(metricClient.pushed) != 1 || metricClient.pushed[0]["Node"] != "node-a" || metricClient.pushed[0]["Operation"] != "pull_image" {
+		t.Fatal("Unexpected pushed metrics:", metricClient.pushed)
+	}
+
+	// only fast operations happened since
+	second := makeBuckets("pull_image", map[string]float64{"0.005": 20, "0.01": 150, "0.1": 160, "1": 170, "10": 200, "+Inf": 200})
+	if latencyErrors := checker.evaluateNodes(map[string]string{"node-a": second}); len(latencyErrors) != 0 {
+		t.Fatal("Expected only new operations to be evaluated but got", latencyErrors)
+	}
+}
@@ -0,0 +1,244 @@
+// Package schedulingBalance implements a checker that detects nodes running
+// significantly more pods than their peers.  Imbalanced scheduling results
+// in hot nodes that are more likely to suffer from resource pressure.
+package schedulingBalance // import "github.com/Comcast/kuberhealthy/pkg/checks/schedulingBalance"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// reportedNodeCount is the number of most loaded nodes shown in errors
+const reportedNodeCount = 3
+
+// nodePodCount is the number of pods counted on a single node
+type nodePodCount struct {
+	Node  string
+	Count int
+}
+
+// Checker validates that pods are spread evenly across nodes
+type Checker struct {
+	Errors             []string
+	ImbalanceThreshold float64
+	client             *kubernetes.Clientset
+	interval           time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reports an error when the coefficient of
+// variation of pods per node exceeds the supplied threshold
+func New(imbalanceThreshold float64) *Checker {
+	return &Checker{
+		Errors:             []string{},
+		ImbalanceThreshold: imbalanceThreshold,
+	}
+}
+
+// Name returns the name of this checker
+func (sbc *Checker) Name() string {
+	return "SchedulingBalanceChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (sbc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (sbc *Checker) Interval() time.Duration {
+	if sbc.interval > 0 {
+		return sbc.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (sbc *Checker) SetInterval(d time.Duration) {
+	sbc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Nodes and the pods of
+// every namespace are listed to count the pods on each node.
+func (sbc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes", "pods"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (sbc *Checker) Timeout() time.Duration {
+	return time.Minute * 2
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (sbc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (sbc *Checker) CurrentStatus() (bool, []string) {
+	if len(sbc.Errors) > 0 {
+		return false, sbc.Errors
+	}
+	return true, sbc.Errors
+}
+
+// clearErrors clears all errors
+func (sbc *Checker) clearErrors() {
+	sbc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (sbc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	sbc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := sbc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(sbc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + sbc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(sbc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + sbc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + sbc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks counts the pods on every node and sets an error when the pod
+// distribution is more uneven than the configured threshold
+func (sbc *Checker) doChecks() error {
+
+	nodes, err := sbc.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	pods, err := sbc.client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	counts := countPodsPerNode(nodes.Items, pods.Items)
+	cv, mean := coefficientOfVariation(counts)
+	khlog.ForCheck(sbc.Name()).Debugln("pod count coefficient of variation across", len(counts), "nodes is", cv)
+
+	if cv > sbc.ImbalanceThreshold {
+		var loaded []string
+		for _, n := range mostLoadedNodes(counts, reportedNodeCount) {
+			loaded = append(loaded, fmt.Sprintf("%s (%d pods)", n.Node, n.Count))
+		}
+		sbc.Errors = []string{fmt.Sprintf("Pod scheduling is imbalanced with a coefficient of variation of %.2f which exceeds %.2f. Most loaded nodes compared to an average of %.1f pods: %s",
+			cv, sbc.ImbalanceThreshold, mean, strings.Join(loaded, ", "))}
+		return nil
+	}
+
+	sbc.clearErrors()
+	return nil
+}
+
+// countPodsPerNode counts the non-terminal pods scheduled to each schedulable
+// node.  Pods owned by daemonsets are skipped because they are intentionally
+// present on every node.
+func countPodsPerNode(nodes []v1.Node, pods []v1.Pod) map[string]int {
+	counts := make(map[string]int)
+	for _, n := range nodes {
+		if n.Spec.Unschedulable {
+			continue
+		}
+		counts[n.Name] = 0
+	}
+
+	for _, p := range pods {
+		if p.Status.Phase == v1.PodSucceeded || p.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if ownedByDaemonSet(p) {
+			continue
+		}
+		if _, ok := counts[p.Spec.NodeName]; !ok {
+			continue
+		}
+		counts[p.Spec.NodeName]++
+	}
+	return counts
+}
+
+// ownedByDaemonSet determines if a pod was created by a daemonset
+func ownedByDaemonSet(p v1.Pod) bool {
+	for _, ref := range p.OwnerReferences {
+		if ref.Kind == "DaemonSet" {
+			return true
+		}
+	}
+	return false
+}
+
+// coefficientOfVariation returns the coefficient of variation and the mean
+// of the supplied pod counts
+func coefficientOfVariation(counts map[string]int) (float64, float64) {
+	if len(counts) == 0 {
+		return 0, 0
+	}
+
+	var total float64
+	for _, c := range counts {
+		total += float64(c)
+	}
+	mean := total / float64(len(counts))
+	if mean == 0 {
+		return 0, 0
+	}
+
+	var variance float64
+	for _, c := range counts {
+		variance += math.Pow(float64(c)-mean, 2)
+	}
+	variance = variance / float64(len(counts))
+
+	return math.Sqrt(variance) / mean, mean
+}
+
+// mostLoadedNodes returns up to n nodes with the highest pod counts
+func mostLoadedNodes(counts map[string]int, n int) []nodePodCount {
+	var sorted []nodePodCount
+	for node, count := range counts {
+		sorted = append(sorted, nodePodCount{Node: node, Count: count})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count == sorted[j].Count {
+			return sorted[i].Node < sorted[j].Node
+		}
+		return sorted[i].Count > sorted[j].Count
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
@@ -0,0 +1,85 @@
+package schedulingBalance
+
+import (
+	"strconv"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makeNodes(names ...string) []v1.Node {
+	var nodes []v1.Node
+	for _, n := range names {
+		nodes = append(nodes, v1.Node{ObjectMeta: metav1.ObjectMeta{Name: n}})
+	}
+	return nodes
+}
+
+func makePods(node string, count int, ownerKind string) []v1.Pod {
+	var pods []v1.Pod
+	for i := 0; i < count; i++ {
+		p := v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: node + "-pod-" + strconv.Itoa(i)},
+			Spec:       v1.PodSpec{NodeName: node},
+			Status:     v1.PodStatus{Phase: v1.PodRunning},
+		}
+		if len(ownerKind) > 0 {
+			p.OwnerReferences = []metav1.OwnerReference{{Kind: ownerKind, Name: "owner"}}
+		}
+		pods = append(pods, p)
+	}
+	return pods
+}
+
+func TestCountPodsPerNode(t *testing.T) {
+	nodes := makeNodes("a", "b", "c")
+	var pods []v1.Pod
+	pods = append(pods, makePods("a", 5, "ReplicaSet")...)
+	pods = append(pods, makePods("b", 3, "")...)
+	pods = append(pods, makePods("c", 10, "DaemonSet")...)
+
+	completed := makePods("c", 2, "Job")
+	for i := range completed {
+		completed[i].Status.Phase = v1.PodSucceeded
+	}
+	pods = append(pods, completed...)
+
+	counts := countPodsPerNode(nodes, pods)
+	if counts["a"] != 5 || counts["b"] != 3 || counts["c"] != 0 {
+		t.Fatal("Unexpected pod counts:", counts)
+	}
+}
+
+func TestCoefficientOfVariation(t *testing.T) {
+	var tests = []struct {
+		description string
+		counts      map[string]int
+		imbalanced  bool
+	}{
+		{"even distribution", map[string]int{"a": 10, "b": 10, "c": 10}, false},
+		{"slightly uneven distribution", map[string]int{"a": 12, "b": 10, "c": 8}, false},
+		{"one hot node", map[string]int{"a": 40, "b": 2, "c": 3, "d": 1}, true},
+		{"no pods", map[string]int{"a": 0, "b": 0}, false},
+		{"no nodes", map[string]int{}, false},
+	}
+
+	for _, test := range tests {
+		cv, mean := coefficientOfVariation(test.counts)
+		t.Log(test.description, "cv:", cv, "mean:", mean)
+		if (cv > 0.5) != test.imbalanced {
+			t.Fatal("Unexpected imbalance result for", test.description, "cv:", cv)
+		}
+	}
+}
+
+func TestMostLoadedNodes(t *testing.T) {
+	counts := map[string]int{"a": 1, "b": 20, "c": 5, "d": 30, "e": 5}
+	loaded := mostLoadedNodes(counts, 3)
+	if len(loaded) != 3 {
+		t.Fatal("Expected 3 nodes but got", len(loaded))
+	}
+	if loaded[0].Node != "d" || loaded[1].Node != "b" || loaded[2].Node != "c" {
+		t.Fatal("Unexpected node order:", loaded)
+	}
+}
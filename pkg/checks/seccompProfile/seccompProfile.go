@@ -0,0 +1,284 @@
+// Package seccompProfile implements a checker that ensures pods run with a
+// seccomp profile.  Containers without a profile run unconfined on many
+// Kubernetes versions, which allows them to make any system call.
+package seccompProfile // import "github.com/Comcast/kuberhealthy/pkg/checks/seccompProfile"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SkipAnnotation excludes a pod from the check when set to "true"
+const SkipAnnotation = "kuberhealthy.io/skip-seccomp-check"
+
+// Seccomp profile types as they are named by the securityContext field
+const (
+	ProfileRuntimeDefault = "RuntimeDefault"
+	ProfileLocalhost      = "Localhost"
+	ProfileUnconfined     = "Unconfined"
+)
+
+// systemNamespaces are excluded from the check
+var systemNamespaces = []string{metav1.NamespaceSystem, metav1.NamespacePublic, "kube-node-lease"}
+
+// pod holds the fields of a pod used by this check.  Pods are decoded from
+// raw API responses because the securityContext.seccompProfile field is newer
+// than the client's API types.
+type pod struct {
+	Metadata metav1.ObjectMeta `json:"metadata"`
+	Spec     struct {
+		SecurityContext *securityContext `json:"securityContext"`
+		Containers      []container      `json:"containers"`
+		InitContainers  []container      `json:"initContainers"`
+	} `json:"spec"`
+}
+
+// podList is a list of pods decoded from a raw API response
+type podList struct {
+	Items []pod `json:"items"`
+}
+
+// container holds the fields of a container used by this check
+type container struct {
+	Name            string           `json:"name"`
+	SecurityContext *securityContext `json:"securityContext"`
+}
+
+// securityContext holds the seccomp fields of a pod or container security
+// context
+type securityContext struct {
+	SeccompProfile *struct {
+		Type string `json:"type"`
+	} `json:"seccompProfile"`
+}
+
+// Checker validates that pods run with a required seccomp profile
+type Checker struct {
+	Errors          []string
+	Namespaces      []string
+	RequiredProfile string
+	client          *kubernetes.Clientset
+	interval        time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied.  The required profile must be RuntimeDefault, which
+// also accepts Localhost profiles, or Localhost.
+func New(namespaces []string, requiredProfile string) (*Checker, error) {
+	if requiredProfile != ProfileRuntimeDefault && requiredProfile != ProfileLocalhost {
+		return nil, errors.New("required seccomp profile must be " + ProfileRuntimeDefault + " or " + ProfileLocalhost + " but was " + requiredProfile)
+	}
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		Errors:          []string{},
+		Namespaces:      namespaces,
+		RequiredProfile: requiredProfile,
+	}, nil
+}
+
+// Name returns the name of this checker
+func (spc *Checker) Name() string {
+	return "SeccompProfileChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (spc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (spc *Checker) Interval() time.Duration {
+	if spc.interval > 0 {
+		return spc.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (spc *Checker) SetInterval(d time.Duration) {
+	spc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to list pods in each
+// checked namespace.  They are cluster wide when all namespaces are checked.
+func (spc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range spc.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		}
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (spc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (spc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (spc *Checker) CurrentStatus() (bool, []string) {
+	if len(spc.Errors) > 0 {
+		return false, spc.Errors
+	}
+	return true, spc.Errors
+}
+
+// clearErrors clears all errors
+func (spc *Checker) clearErrors() {
+	spc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (spc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	spc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := spc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(spc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + spc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(spc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + spc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + spc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists pods in each namespace and sets an error for every
+// container that does not run with the required seccomp profile
+func (spc *Checker) doChecks() error {
+
+	var profileErrors []string
+	for _, ns := range spc.Namespaces {
+		request := spc.client.CoreV1().RESTClient().Get().Resource("pods")
+		if ns != metav1.NamespaceAll {
+			request = request.Namespace(ns)
+		}
+		b, err := request.DoRaw()
+		if err != nil {
+			return errors.New("Error listing pods: " + err.Error())
+		}
+
+		pods := podList{}
+		err = json.Unmarshal(b, &pods)
+		if err != nil {
+			return errors.New("Error decoding pod list: " + err.Error())
+		}
+		profileErrors = append(profileErrors, evaluatePods(pods.Items, spc.RequiredProfile)...)
+	}
+
+	if len(profileErrors) > 0 {
+		for _, e := range profileErrors {
+			khlog.ForCheck(spc.Name()).Warningln(e)
+		}
+		spc.Errors = profileErrors
+		return nil
+	}
+
+	spc.clearErrors()
+	return nil
+}
+
+// evaluatePods returns an error for every container that does not run with
+// the required profile.  Pods in system namespaces and pods with the skip
+// annotation are excluded.
+func evaluatePods(pods []pod, requiredProfile string) []string {
+	var profileErrors []string
+
+	for _, p := range pods {
+		if isSystemNamespace(p.Metadata.Namespace) || p.Metadata.Annotations[SkipAnnotation] == "true" {
+			continue
+		}
+
+		containers := append(append([]container{}, p.Spec.InitContainers...), p.Spec.Containers...)
+		for _, c := range containers {
+			profile := effectiveProfile(p, c)
+			if profile == requiredProfile || (requiredProfile == ProfileRuntimeDefault && profile == ProfileLocalhost) {
+				continue
+			}
+
+			description := "Pod " + p.Metadata.Namespace + "/" + p.Metadata.Name + " container " + c.Name
+			if len(profile) == 0 {
+				profileErrors = append(profileErrors, description+" has no seccomp profile set")
+				continue
+			}
+			profileErrors = append(profileErrors, description+" has seccomp profile "+profile+" instead of "+requiredProfile)
+		}
+	}
+	return profileErrors
+}
+
+// effectiveProfile returns the seccomp profile type applied to a container,
+// or an empty string if none is set.  Security context fields take precedence
+// over the deprecated seccomp annotations, and container settings take
+// precedence over pod settings.
+func effectiveProfile(p pod, c container) string {
+	if c.SecurityContext != nil && c.SecurityContext.SeccompProfile != nil {
+		return c.SecurityContext.SeccompProfile.Type
+	}
+	if annotation, ok := p.Metadata.Annotations[apiv1.SeccompContainerAnnotationKeyPrefix+c.Name]; ok {
+		return annotationProfile(annotation)
+	}
+	if p.Spec.SecurityContext != nil && p.Spec.SecurityContext.SeccompProfile != nil {
+		return p.Spec.SecurityContext.SeccompProfile.Type
+	}
+	if annotation, ok := p.Metadata.Annotations[apiv1.SeccompPodAnnotationKey]; ok {
+		return annotationProfile(annotation)
+	}
+	return ""
+}
+
+// annotationProfile converts a deprecated seccomp annotation value to the
+// profile type used by the securityContext field
+func annotationProfile(annotation string) string {
+	switch {
+	case annotation == apiv1.SeccompProfileRuntimeDefault || annotation == apiv1.DeprecatedSeccompProfileDockerDefault:
+		return ProfileRuntimeDefault
+	case strings.HasPrefix(annotation, "localhost/"):
+		return ProfileLocalhost
+	case annotation == "unconfined":
+		return ProfileUnconfined
+	}
+	return annotation
+}
+
+// isSystemNamespace determines if a namespace is excluded from the check
+func isSystemNamespace(namespace string) bool {
+	for _, ns := range systemNamespaces {
+		if namespace == ns {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package seccompProfile
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func decodePod(t *testing.T, podJSON string) pod {
+	p := pod{}
+	err := json.Unmarshal([]byte(podJSON), &p)
+	if err != nil {
+		t.Fatal("Unable to decode test pod:", err)
+	}
+	return p
+}
+
+func TestEvaluatePods(t *testing.T) {
+	var tests = []struct {
+		description string
+		pod         string
+		required    string
+		expected    int
+	}{
+		{"pod runtime default field",
+			`{"metadata":{"namespace":"default","name":"web"},"spec":{"securityContext":{"seccompProfile":{"type":"RuntimeDefault"}},"containers":[{"name":"web"}]}}`,
+			ProfileRuntimeDefault, 0},
+		{"container localhost field",
+			`{"metadata":{"namespace":"default","name":"web"},"spec":{"containers":[{"name":"web","securityContext":{"seccompProfile":{"type":"Localhost","localhostProfile":"web.json"}}}]}}`,
+			ProfileRuntimeDefault, 0},
+		{"container unconfined overrides pod",
+			`{"metadata":{"namespace":"default","name":"web"},"spec":{"securityContext":{"seccompProfile":{"type":"RuntimeDefault"}},"containers":[{"name":"web","securityContext":{"seccompProfile":{"type":"Unconfined"}}},{"name":"sidecar"}]}}`,
+			ProfileRuntimeDefault, 1},
+		{"no profile",
+			`{"metadata":{"namespace":"default","name":"web"},"spec":{"initContainers":[{"name":"init"}],"containers":[{"name":"web"}]}}`,
+			ProfileRuntimeDefault, 2},
+		{"pod annotation",
+			`{"metadata":{"namespace":"default","name":"web","annotations":{"seccomp.security.alpha.kubernetes.io/pod":"docker/default"}},"spec":{"containers":[{"name":"web"}]}}`,
+			ProfileRuntimeDefault, 0},
+		{"container annotation unconfined",
+			`{"metadata":{"namespace":"default","name":"web","annotations":{"seccomp.security.alpha.kubernetes.io/pod":"runtime/default","container.seccomp.security.alpha.kubernetes.io/web":"unconfined"}},"spec":{"containers":[{"name":"web"}]}}`,
+			ProfileRuntimeDefault, 1},
+		{"runtime default when localhost required",
+			`{"metadata":{"namespace":"default","name":"web"},"spec":{"securityContext":{"seccompProfile":{"type":"RuntimeDefault"}},"containers":[{"name":"web"}]}}`,
+			ProfileLocalhost, 1},
+		{"localhost annotation when localhost required",
+			`{"metadata":{"namespace":"default","name":"web","annotations":{"seccomp.security.alpha.kubernetes.io/pod":"localhost/web.json"}},"spec":{"containers":[{"name":"web"}]}}`,
+			ProfileLocalhost, 0},
+		{"system namespace",
+			`{"metadata":{"namespace":"kube-system","name":"kube-proxy"},"spec":{"containers":[{"name":"kube-proxy"}]}}`,
+			ProfileRuntimeDefault, 0},
+		{"skip annotation",
+			`{"metadata":{"namespace":"default","name":"web","annotations":{"kuberhealthy.io/skip-seccomp-check":"true"}},"spec":{"containers":[{"name":"web"}]}}`,
+			ProfileRuntimeDefault, 0},
+	}
+
+	for _, test := range tests {
+		profileErrors := evaluatePods([]pod{decodePod(t, test.pod)}, test.required)
+		if len(profileErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", profileErrors)
+		}
+		t.Log(test.description, profileErrors)
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil, ProfileRuntimeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = New(nil, ProfileUnconfined)
+	if err == nil {
+		t.Fatal("Expected an error when requiring the Unconfined profile")
+	}
+}
@@ -0,0 +1,240 @@
+// Package secretRBAC implements a checker that finds RBAC roles granting
+// overly broad access to secrets.  Default roles created by Kubernetes are
+// not evaluated.
+package secretRBAC // import "github.com/Comcast/kuberhealthy/pkg/checks/secretRBAC"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AuditAnnotation is the annotation that must be present with a non-empty
+// value on roles that are allowed to create or delete secrets
+const AuditAnnotation = "kuberhealthy.io/secret-write-audit"
+
+// bootstrapLabel marks the default roles created by the api server
+const bootstrapLabel = "kubernetes.io/bootstrapping"
+
+var readVerbs = []string{"get", "list", "watch"}
+var writeVerbs = []string{"create", "delete"}
+
+// Checker validates that roles do not grant broad access to secrets
+type Checker struct {
+	Errors     []string
+	Namespaces []string
+	client     *kubernetes.Clientset
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker.  Roles are checked in the supplied namespaces,
+// or all namespaces when none are supplied.  ClusterRoles are always checked.
+func New(namespaces []string) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		Errors:     []string{},
+		Namespaces: namespaces,
+	}
+}
+
+// Name returns the name of this checker
+func (src *Checker) Name() string {
+	return "SecretRBACChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (src *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (src *Checker) Interval() time.Duration {
+	if src.interval > 0 {
+		return src.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (src *Checker) SetInterval(d time.Duration) {
+	src.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  ClusterRoles are
+// always listed, and Roles in each checked namespace.
+func (src *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{rbacv1.GroupName}, Resources: []string{"clusterroles"}, Verbs: []string{"list"}},
+		},
+	}
+	for _, ns := range src.Namespaces {
+		rules[ns] = append(rules[ns],
+			rbacv1.PolicyRule{APIGroups: []string{rbacv1.GroupName}, Resources: []string{"roles"}, Verbs: []string{"list"}},
+		)
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (src *Checker) Timeout() time.Duration {
+	return time.Minute * 2
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (src *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (src *Checker) CurrentStatus() (bool, []string) {
+	if len(src.Errors) > 0 {
+		return false, src.Errors
+	}
+	return true, src.Errors
+}
+
+// clearErrors clears all errors
+func (src *Checker) clearErrors() {
+	src.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (src *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	src.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := src.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(src.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + src.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(src.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + src.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + src.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists cluster roles and roles and sets a security finding for
+// every role granting broad secret access
+func (src *Checker) doChecks() error {
+
+	var findings []string
+
+	clusterRoles, err := src.client.RbacV1().ClusterRoles().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, cr := range clusterRoles.Items {
+		findings = append(findings, evaluateRole("ClusterRole "+cr.Name, cr.ObjectMeta, cr.Rules)...)
+	}
+
+	for _, ns := range src.Namespaces {
+		roles, err := src.client.RbacV1().Roles(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for _, r := range roles.Items {
+			findings = append(findings, evaluateRole("Role "+r.Namespace+"/"+r.Name, r.ObjectMeta, r.Rules)...)
+		}
+	}
+
+	if len(findings) > 0 {
+		for _, f := range findings {
+			khlog.ForCheck(src.Name()).Warningln(f)
+		}
+		src.Errors = findings
+		return nil
+	}
+
+	src.clearErrors()
+	return nil
+}
+
+// evaluateRole returns a security finding for each way the role's rules
+// grant broad access to secrets
+func evaluateRole(description string, meta metav1.ObjectMeta, rules []rbacv1.PolicyRule) []string {
+	var findings []string
+
+	// skip the default roles created by kubernetes
+	if strings.HasPrefix(meta.Name, "system:") || meta.Labels[bootstrapLabel] == "rbac-defaults" {
+		return findings
+	}
+
+	for _, rule := range rules {
+		if !matchesSecrets(rule) {
+			continue
+		}
+		if len(rule.ResourceNames) == 0 {
+			verbs := matchingVerbs(rule.Verbs, readVerbs)
+			if len(verbs) > 0 {
+				findings = append(findings, description+" grants "+strings.Join(verbs, ",")+" on all secrets")
+			}
+		}
+		if len(strings.TrimSpace(meta.Annotations[AuditAnnotation])) == 0 {
+			verbs := matchingVerbs(rule.Verbs, writeVerbs)
+			if len(verbs) > 0 {
+				findings = append(findings, description+" grants "+strings.Join(verbs, ",")+" on secrets without the "+AuditAnnotation+" annotation")
+			}
+		}
+	}
+	return findings
+}
+
+// matchesSecrets determines if a policy rule applies to core secrets
+func matchesSecrets(rule rbacv1.PolicyRule) bool {
+	var coreGroup bool
+	for _, g := range rule.APIGroups {
+		if g == "" || g == rbacv1.APIGroupAll {
+			coreGroup = true
+		}
+	}
+	if !coreGroup {
+		return false
+	}
+	for _, r := range rule.Resources {
+		if r == "secrets" || r == rbacv1.ResourceAll {
+			return true
+		}
+	}
+	return false
+}
+
+// matchingVerbs returns which of the wanted verbs are granted by the rule
+// verbs, accounting for wildcards
+func matchingVerbs(ruleVerbs []string, wanted []string) []string {
+	var found []string
+	for _, w := range wanted {
+		for _, v := range ruleVerbs {
+			if v == w || v == rbacv1.VerbAll {
+				found = append(found, w)
+				break
+			}
+		}
+	}
+	sort.Strings(found)
+	return found
+}
@@ -0,0 +1,75 @@
+package secretRBAC
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateRole(t *testing.T) {
+
+	var tests = []struct {
+		description string
+		meta        metav1.ObjectMeta
+		rule        rbacv1.PolicyRule
+		expected    int
+	}{
+		{
+			"read all secrets",
+			metav1.ObjectMeta{Name: "reader"},
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get", "list"}},
+			1,
+		},
+		{
+			"read named secret",
+			metav1.ObjectMeta{Name: "named-reader"},
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}, ResourceNames: []string{"my-secret"}},
+			0,
+		},
+		{
+			"wildcard everything",
+			metav1.ObjectMeta{Name: "admin-ish"},
+			rbacv1.PolicyRule{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+			2,
+		},
+		{
+			"write secrets with audit annotation",
+			metav1.ObjectMeta{Name: "writer", Annotations: map[string]string{AuditAnnotation: "JIRA-1234"}},
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"create", "delete"}, ResourceNames: []string{"a"}},
+			0,
+		},
+		{
+			"write secrets without audit annotation",
+			metav1.ObjectMeta{Name: "writer"},
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"create"}},
+			1,
+		},
+		{
+			"secrets in another api group",
+			metav1.ObjectMeta{Name: "vault"},
+			rbacv1.PolicyRule{APIGroups: []string{"vault.example.com"}, Resources: []string{"secrets"}, Verbs: []string{"*"}},
+			0,
+		},
+		{
+			"default system role",
+			metav1.ObjectMeta{Name: "system:controller:token-cleaner"},
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"*"}},
+			0,
+		},
+		{
+			"bootstrapped default role",
+			metav1.ObjectMeta{Name: "admin", Labels: map[string]string{bootstrapLabel: "rbac-defaults"}},
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"*"}},
+			0,
+		},
+	}
+
+	for _, test := range tests {
+		findings := evaluateRole("Role "+test.meta.Name, test.meta, []rbacv1.PolicyRule{test.rule})
+		if len(findings) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "findings but got", findings)
+		}
+		t.Log(test.description, findings)
+	}
+}
@@ -0,0 +1,189 @@
+// Package selfNamespace implements a checker that ensures the namespace
+// Kuberhealthy runs in exists and is not terminating.  When the namespace is
+// terminating, Kuberhealthy can no longer create the resources its checks
+// depend on, so a graceful shutdown is started.
+package selfNamespace // import "github.com/Comcast/kuberhealthy/pkg/checks/selfNamespace"
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// Checker validates that the Kuberhealthy namespace is active
+type Checker struct {
+	Errors []string
+	client *kubernetes.Clientset
+	// shutdown is called once when the namespace is found to be terminating
+	shutdown     func()
+	shutdownSent bool
+	interval     time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that calls shutdown when the Kuberhealthy
+// namespace is terminating
+func New(shutdown func()) *Checker {
+	return &Checker{
+		Errors:   []string{},
+		shutdown: shutdown,
+	}
+}
+
+// Name returns the name of this checker
+func (snc *Checker) Name() string {
+	return "SelfNamespaceChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (snc *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (snc *Checker) Interval() time.Duration {
+	if snc.interval > 0 {
+		return snc.interval
+	}
+	return time.Minute * 1
+}
+
+// SetInterval overrides the interval at which this check runs
+func (snc *Checker) SetInterval(d time.Duration) {
+	snc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Namespaces are cluster
+// scoped, so getting its own namespace needs a cluster role limited to it.
+func (snc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"namespaces"}, ResourceNames: []string{namespace}, Verbs: []string{"get"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (snc *Checker) Timeout() time.Duration {
+	return time.Second * 30
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (snc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (snc *Checker) CurrentStatus() (bool, []string) {
+	if len(snc.Errors) > 0 {
+		return false, snc.Errors
+	}
+	return true, snc.Errors
+}
+
+// clearErrors clears all errors
+func (snc *Checker) clearErrors() {
+	snc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (snc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	snc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := snc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(snc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + snc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(snc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + snc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + snc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks fetches the Kuberhealthy namespace and sets an error if it is
+// missing or not active
+func (snc *Checker) doChecks() error {
+
+	if len(namespace) == 0 {
+		return errors.New("POD_NAMESPACE environment variable is not set so the Kuberhealthy namespace is unknown")
+	}
+
+	ns, err := snc.client.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		return snc.evaluate(nil)
+	}
+	if err != nil {
+		return err
+	}
+	return snc.evaluate(ns)
+}
+
+// evaluate sets errors for a namespace that is missing or not active and
+// starts a shutdown if it is terminating.  A nil namespace means that it was
+// not found.
+func (snc *Checker) evaluate(ns *apiv1.Namespace) error {
+	namespaceErrors, terminating := evaluateNamespace(namespace, ns)
+
+	if terminating && !snc.shutdownSent {
+		khlog.ForCheck(snc.Name()).Errorln(namespaceErrors[0] + ". Starting shutdown.")
+		snc.shutdownSent = true
+		if snc.shutdown != nil {
+			snc.shutdown()
+		}
+	}
+
+	if len(namespaceErrors) > 0 {
+		for _, e := range namespaceErrors {
+			khlog.ForCheck(snc.Name()).Warningln(e)
+		}
+		snc.Errors = namespaceErrors
+		return nil
+	}
+
+	snc.clearErrors()
+	return nil
+}
+
+// evaluateNamespace returns an error if the namespace is missing, not
+// active, or being deleted, and whether Kuberhealthy should shut down because
+// the namespace is missing or terminating
+func evaluateNamespace(name string, ns *apiv1.Namespace) ([]string, bool) {
+	if ns == nil {
+		return []string{"Kuberhealthy namespace " + name + " does not exist"}, true
+	}
+	if ns.DeletionTimestamp != nil {
+		return []string{"Kuberhealthy namespace " + name + " is being deleted since " + ns.DeletionTimestamp.String()}, true
+	}
+	if ns.Status.Phase == apiv1.NamespaceTerminating {
+		return []string{"Kuberhealthy namespace " + name + " is terminating"}, true
+	}
+	if ns.Status.Phase != apiv1.NamespaceActive {
+		return []string{"Kuberhealthy namespace " + name + " is in phase " + string(ns.Status.Phase) + " instead of " + string(apiv1.NamespaceActive)}, false
+	}
+	return nil, false
+}
@@ -0,0 +1,69 @@
+package selfNamespace
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makeNamespace(phase apiv1.NamespacePhase, deleting bool) *apiv1.Namespace {
+	ns := &apiv1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "kuberhealthy"},
+		Status:     apiv1.NamespaceStatus{Phase: phase},
+	}
+	if deleting {
+		now := metav1.Now()
+		ns.DeletionTimestamp = &now
+	}
+	return ns
+}
+
+func TestEvaluateNamespace(t *testing.T) {
+	var tests = []struct {
+		description string
+		namespace   *apiv1.Namespace
+		errors      int
+		terminating bool
+	}{
+		{"active", makeNamespace(apiv1.NamespaceActive, false), 0, false},
+		{"terminating", makeNamespace(apiv1.NamespaceTerminating, false), 1, true},
+		{"deletion timestamp", makeNamespace(apiv1.NamespaceActive, true), 1, true},
+		{"unknown phase", makeNamespace("", false), 1, false},
+		{"missing", nil, 1, true},
+	}
+
+	for _, test := range tests {
+		namespaceErrors, terminating := evaluateNamespace("kuberhealthy", test.namespace)
+		if len(namespaceErrors) != test.errors || terminating != test.terminating {
+			t.Fatal("Test", test.description, "expected", test.errors, "errors and terminating", test.terminating, "but got", namespaceErrors, terminating)
+		}
+		t.Log(test.description, namespaceErrors)
+	}
+}
+
+func TestEvaluateShutdown(t *testing.T) {
+	var shutdowns int
+	checker := New(func() {
+		shutdowns++
+	})
+
+	err := checker.evaluate(makeNamespace(apiv1.NamespaceActive, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := checker.CurrentStatus(); !ok || shutdowns != 0 {
+		t.Fatal("Expected an active namespace to pass without shutting down")
+	}
+
+	// shutdown is only started once even if the check runs again
+	for i := 0; i < 2; i++ {
+		err = checker.evaluate(makeNamespace(apiv1.NamespaceTerminating, true))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ok, _ := checker.CurrentStatus(); ok || shutdowns != 1 {
+		t.Fatal("Expected a terminating namespace to fail and shut down once but got", shutdowns, "shutdowns")
+	}
+}
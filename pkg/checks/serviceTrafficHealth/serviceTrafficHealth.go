@@ -0,0 +1,295 @@
+// Package serviceTrafficHealth implements a checker that validates ClusterIP
+// services end to end.  Each service with a selector must have at least one
+// ready endpoint, and a test pod must be able to open a TCP connection to each
+// of the service's ports on its cluster IP.  The connection test validates
+// that kube-proxy rules have been applied on the node, not just that the
+// control plane has recorded endpoints.
+package serviceTrafficHealth // import "github.com/Comcast/kuberhealthy/pkg/checks/serviceTrafficHealth"
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// probeTarget is a cluster IP and port that the test pod connects to
+type probeTarget struct {
+	Service string
+	Address string
+}
+
+// Checker validates that ClusterIP services have ready endpoints and accept
+// TCP connections
+type Checker struct {
+	Errors      []string
+	Namespaces  []string
+	DialTimeout time.Duration
+	Image       string
+	client      *kubernetes.Clientset
+	// runPod is replaced in tests to inject test pod output
+	runPod   func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (string, error)
+	interval time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied.  Each TCP connection attempt gives up after the
+// dial timeout.
+func New(namespaces []string, dialTimeout time.Duration) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		Errors:      []string{},
+		Namespaces:  namespaces,
+		DialTimeout: dialTimeout,
+		Image:       "busybox:1.30",
+		runPod:      podRunner.RunPod,
+	}
+}
+
+// Name returns the name of this checker
+func (stc *Checker) Name() string {
+	return "ServiceTrafficHealthChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (stc *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (stc *Checker) Interval() time.Duration {
+	if stc.interval > 0 {
+		return stc.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (stc *Checker) SetInterval(d time.Duration) {
+	stc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Services and
+// endpoints are listed in each checked namespace, or cluster wide when all
+// are checked, and the test pod runs in the Kuberhealthy namespace.
+func (stc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := map[string][]rbacv1.PolicyRule{
+		namespace: podRunner.RunPodRBACRules(),
+	}
+	for _, ns := range stc.Namespaces {
+		rules[ns] = append(rules[ns],
+			rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"services", "endpoints"}, Verbs: []string{"list"}},
+		)
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (stc *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (stc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (stc *Checker) CurrentStatus() (bool, []string) {
+	if len(stc.Errors) > 0 {
+		return false, stc.Errors
+	}
+	return true, stc.Errors
+}
+
+// clearErrors clears all errors
+func (stc *Checker) clearErrors() {
+	stc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (stc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	stc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := stc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(stc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + stc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(stc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + stc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + stc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks verifies the endpoints of every ClusterIP service and then
+// connects to each service with ready endpoints from a test pod
+func (stc *Checker) doChecks() error {
+
+	var serviceErrors []string
+	var targets []probeTarget
+	for _, ns := range stc.Namespaces {
+		services, err := stc.client.CoreV1().Services(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		endpoints, err := stc.client.CoreV1().Endpoints(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		e, t := evaluateEndpoints(services.Items, endpoints.Items)
+		serviceErrors = append(serviceErrors, e...)
+		targets = append(targets, t...)
+	}
+
+	probeErrors, err := stc.probeServices(targets)
+	if err != nil {
+		return err
+	}
+	serviceErrors = append(serviceErrors, probeErrors...)
+
+	if len(serviceErrors) > 0 {
+		for _, e := range serviceErrors {
+			khlog.ForCheck(stc.Name()).Warningln(e)
+		}
+		stc.Errors = serviceErrors
+		return nil
+	}
+
+	stc.clearErrors()
+	return nil
+}
+
+// probeServices connects to each target from a test pod and returns an error
+// for every target that did not accept a connection
+func (stc *Checker) probeServices(targets []probeTarget) ([]string, error) {
+	if len(targets) == 0 {
+		return nil, nil
+	}
+
+	script := podRunner.Script{
+		Name:   "service-traffic",
+		Image:  stc.Image,
+		Script: probeScript(targets, stc.DialTimeout),
+	}
+	// leave time to clean up the test pod before the check times out
+	output, err := stc.runPod(stc.client, namespace, script, stc.Timeout()-time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	return evaluateProbes(output, targets), nil
+}
+
+// evaluateEndpoints returns an error for every ClusterIP service with a
+// selector that has no ready endpoints, along with the TCP ports of the
+// remaining services to connect to.  Headless services are skipped since they
+// have no cluster IP to connect to.
+func evaluateEndpoints(services []apiv1.Service, endpoints []apiv1.Endpoints) ([]string, []probeTarget) {
+	var serviceErrors []string
+	var targets []probeTarget
+
+	ready := make(map[string]int)
+	for _, ep := range endpoints {
+		for _, subset := range ep.Subsets {
+			ready[ep.Namespace+"/"+ep.Name] += len(subset.Addresses)
+		}
+	}
+
+	for _, svc := range services {
+		if svc.Spec.Type != apiv1.ServiceTypeClusterIP || len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		if svc.Spec.ClusterIP == apiv1.ClusterIPNone || len(svc.Spec.ClusterIP) == 0 {
+			continue
+		}
+
+		description := svc.Namespace + "/" + svc.Name
+		if ready[description] == 0 {
+			serviceErrors = append(serviceErrors, "Service "+description+" has no ready endpoints")
+			continue
+		}
+
+		for _, port := range svc.Spec.Ports {
+			if port.Protocol != apiv1.ProtocolTCP && len(port.Protocol) != 0 {
+				continue
+			}
+			targets = append(targets, probeTarget{
+				Service: description,
+				Address: svc.Spec.ClusterIP + ":" + strconv.Itoa(int(port.Port)),
+			})
+		}
+	}
+	return serviceErrors, targets
+}
+
+// probeScript builds a script that attempts a TCP connection to each target
+// and prints one line per target in the form "<ip>:<port> ok" or
+// "<ip>:<port> failed"
+func probeScript(targets []probeTarget, dialTimeout time.Duration) string {
+	var addresses []string
+	for _, t := range targets {
+		addresses = append(addresses, t.Address)
+	}
+	seconds := int(dialTimeout.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	return "for t in " + strings.Join(addresses, " ") + "; do " +
+		`if nc -z -w ` + strconv.Itoa(seconds) + ` ${t%:*} ${t##*:}; then echo "$t ok"; else echo "$t failed"; fi; ` +
+		"done"
+}
+
+// evaluateProbes parses the output of the probe script and returns an error
+// for every target that did not accept a connection
+func evaluateProbes(output string, targets []probeTarget) []string {
+	var probeErrors []string
+
+	results := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		results[fields[0]] = fields[1]
+	}
+
+	for _, t := range targets {
+		result, ok := results[t.Address]
+		if !ok {
+			probeErrors = append(probeErrors, "Service "+t.Service+" was not probed at "+t.Address)
+			continue
+		}
+		if result != "ok" {
+			probeErrors = append(probeErrors, "Service "+t.Service+" did not accept a TCP connection at "+t.Address+" from the test pod")
+		}
+	}
+	return probeErrors
+}
@@ -0,0 +1,133 @@
+package serviceTrafficHealth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func makeService(name string, clusterIP string, selector map[string]string, ports ...apiv1.ServicePort) apiv1.Service {
+	return apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: apiv1.ServiceSpec{
+			Type:      apiv1.ServiceTypeClusterIP,
+			ClusterIP: clusterIP,
+			Selector:  selector,
+			Ports:     ports,
+		},
+	}
+}
+
+func makeEndpoints(name string, ready int, notReady int) apiv1.Endpoints {
+	subset := apiv1.EndpointSubset{}
+	for i := 0; i < ready; i++ {
+		subset.Addresses = append(subset.Addresses, apiv1.EndpointAddress{IP: "10.0.0.1"})
+	}
+	for i := 0; i < notReady; i++ {
+		subset.NotReadyAddresses = append(subset.NotReadyAddresses, apiv1.EndpointAddress{IP: "10.0.0.2"})
+	}
+	return apiv1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Subsets:    []apiv1.EndpointSubset{subset},
+	}
+}
+
+func TestEvaluateEndpoints(t *testing.T) {
+	selector := map[string]string{"app": "web"}
+	tcp := apiv1.ServicePort{Port: 80, Protocol: apiv1.ProtocolTCP}
+	udp := apiv1.ServicePort{Port: 53, Protocol: apiv1.ProtocolUDP}
+
+	var tests = []struct {
+		description string
+		service     apiv1.Service
+		endpoints   apiv1.Endpoints
+		errors      int
+		targets     int
+	}{
+		{"ready endpoint", makeService("web", "10.96.0.10", selector, tcp), makeEndpoints("web", 1, 0), 0, 1},
+		{"only not ready endpoints", makeService("web", "10.96.0.10", selector, tcp), makeEndpoints("web", 0, 2), 1, 0},
+		{"no endpoints object", makeService("web", "10.96.0.10", selector, tcp), makeEndpoints("other", 1, 0), 1, 0},
+		{"no selector", makeService("web", "10.96.0.10", nil, tcp), makeEndpoints("other", 0, 0), 0, 0},
+		{"headless", makeService("web", apiv1.ClusterIPNone, selector, tcp), makeEndpoints("web", 0, 0), 0, 0},
+		{"udp port skipped", makeService("web", "10.96.0.10", selector, tcp, udp), makeEndpoints("web", 1, 0), 0, 1},
+	}
+
+	for _, test := range tests {
+		serviceErrors, targets := evaluateEndpoints([]apiv1.Service{test.service}, []apiv1.Endpoints{test.endpoints})
+		if len(serviceErrors) != test.errors || len(targets) != test.targets {
+			t.Fatal("Test", test.description, "expected", test.errors, "errors and", test.targets, "targets but got", serviceErrors, targets)
+		}
+		t.Log(test.description, serviceErrors, targets)
+	}
+}
+
+func TestEvaluateProbes(t *testing.T) {
+	targets := []probeTarget{
+		{Service: "default/web", Address: "10.96.0.10:80"},
+		{Service: "default/web", Address: "10.96.0.10:443"},
+	}
+
+	var tests = []struct {
+		description string
+		output      string
+		expected    int
+	}{
+		{"all connected", "10.96.0.10:80 ok\n10.96.0.10:443 ok", 0},
+		{"one refused", "10.96.0.10:80 ok\n10.96.0.10:443 failed", 1},
+		{"missing result", "10.96.0.10:80 ok", 1},
+		{"no output", "", 2},
+	}
+
+	for _, test := range tests {
+		probeErrors := evaluateProbes(test.output, targets)
+		if len(probeErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", probeErrors)
+		}
+		t.Log(test.description, probeErrors)
+	}
+}
+
+func TestProbeScript(t *testing.T) {
+	script := probeScript([]probeTarget{{Service: "default/web", Address: "10.96.0.10:80"}}, time.Second*3)
+	if !strings.Contains(script, "10.96.0.10:80") || !strings.Contains(script, "nc -z -w 3") {
+		t.Fatal("Unexpected probe script:", script)
+	}
+}
+
+func TestProbeServices(t *testing.T) {
+	checker := New([]string{"default"}, time.Second*3)
+	checker.runPod = func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (string, error) {
+		if script.HostNetwork {
+			t.Fatal("Expected the test pod to use the pod network")
+		}
+		return "10.96.0.10:80 ok\n10.96.0.11:80 failed", nil
+	}
+
+	targets := []probeTarget{
+		{Service: "default/web", Address: "10.96.0.10:80"},
+		{Service: "default/api", Address: "10.96.0.11:80"},
+	}
+	probeErrors, err := checker.probeServices(targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(probeErrors) != 1 {
+		t.Fatal("Expected the refused connection to be reported but got", probeErrors)
+	}
+	t.Log(probeErrors)
+
+	// a test pod that fails to run is a system error
+	checker.runPod = func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (string, error) {
+		return "", errors.New("Timed out waiting for pod to complete its script")
+	}
+	_, err = checker.probeServices(targets)
+	if err == nil {
+		t.Fatal("Expected an error when the test pod fails to run")
+	}
+}
@@ -0,0 +1,206 @@
+// Package statefulSetStatus implements a checker that ensures all replicas of
+// StatefulSets are Ready.  A StatefulSet stuck waiting on a PersistentVolumeClaim
+// simply has fewer ready replicas than desired without any pod failing.
+package statefulSetStatus // import "github.com/Comcast/kuberhealthy/pkg/checks/statefulSetStatus"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	appsv1 "k8s.io/api/apps/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Checker validates that StatefulSets have all of their replicas Ready
+type Checker struct {
+	FailureTimeStamp map[string]time.Time
+	Errors           []string
+	Namespaces       []string
+	GracePeriod      time.Duration
+	client           *kubernetes.Clientset
+	interval         time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied.  StatefulSets are reported once they have had
+// fewer ready replicas than desired for longer than gracePeriod.
+func New(namespaces []string, gracePeriod time.Duration) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		FailureTimeStamp: make(map[string]time.Time),
+		Errors:           []string{},
+		Namespaces:       namespaces,
+		GracePeriod:      gracePeriod,
+	}
+}
+
+// Name returns the name of this checker
+func (ssc *Checker) Name() string {
+	return "StatefulSetStatusChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (ssc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (ssc *Checker) Interval() time.Duration {
+	if ssc.interval > 0 {
+		return ssc.interval
+	}
+	return time.Minute * 2
+}
+
+// SetInterval overrides the interval at which this check runs
+func (ssc *Checker) SetInterval(d time.Duration) {
+	ssc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to list StatefulSets in
+// each checked namespace, or cluster wide when all namespaces are checked
+func (ssc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range ssc.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{"apps"}, Resources: []string{"statefulsets"}, Verbs: []string{"list"}},
+		}
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (ssc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (ssc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (ssc *Checker) CurrentStatus() (bool, []string) {
+	if len(ssc.Errors) > 0 {
+		return false, ssc.Errors
+	}
+	return true, ssc.Errors
+}
+
+// clearErrors clears all errors
+func (ssc *Checker) clearErrors() {
+	ssc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (ssc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	ssc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := ssc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(ssc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + ssc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(ssc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + ssc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + ssc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists StatefulSets in each namespace and sets an error for every
+// StatefulSet that has had fewer ready replicas than desired for longer
+// than the grace period
+func (ssc *Checker) doChecks() error {
+
+	var statefulSets []appsv1.StatefulSet
+	for _, ns := range ssc.Namespaces {
+		list, err := ssc.client.AppsV1().StatefulSets(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return errors.New("Error listing StatefulSets in namespace " + ns + ": " + err.Error())
+		}
+		statefulSets = append(statefulSets, list.Items...)
+	}
+
+	statusErrors := evaluateStatefulSets(statefulSets, ssc.FailureTimeStamp, ssc.GracePeriod, time.Now())
+
+	if len(statusErrors) > 0 {
+		for _, e := range statusErrors {
+			khlog.ForCheck(ssc.Name()).Warningln(e)
+		}
+		ssc.Errors = statusErrors
+		return nil
+	}
+
+	ssc.clearErrors()
+	return nil
+}
+
+// evaluateStatefulSets returns an error for every StatefulSet that has had
+// fewer ready replicas than desired for longer than gracePeriod.  The time
+// each StatefulSet was first seen without all of its replicas ready is
+// tracked in failureTimeStamp, and StatefulSets that recovered or no longer
+// exist are removed from it.
+func evaluateStatefulSets(statefulSets []appsv1.StatefulSet, failureTimeStamp map[string]time.Time, gracePeriod time.Duration, now time.Time) []string {
+	var statusErrors []string
+
+	sort.Slice(statefulSets, func(i, j int) bool {
+		if statefulSets[i].Namespace != statefulSets[j].Namespace {
+			return statefulSets[i].Namespace < statefulSets[j].Namespace
+		}
+		return statefulSets[i].Name < statefulSets[j].Name
+	})
+
+	unready := make(map[string]bool)
+	for _, s := range statefulSets {
+		// replicas defaults to 1 when it is not set
+		var desired int32 = 1
+		if s.Spec.Replicas != nil {
+			desired = *s.Spec.Replicas
+		}
+		if s.Status.ReadyReplicas >= desired {
+			continue
+		}
+
+		key := s.Namespace + "/" + s.Name
+		unready[key] = true
+		if _, ok := failureTimeStamp[key]; !ok {
+			failureTimeStamp[key] = now
+		}
+		unreadyFor := now.Sub(failureTimeStamp[key])
+		if unreadyFor <= gracePeriod {
+			continue
+		}
+		statusErrors = append(statusErrors, "StatefulSet "+s.Name+" in namespace "+s.Namespace+" has "+strconv.Itoa(int(s.Status.ReadyReplicas))+
+			" of "+strconv.Itoa(int(desired))+" desired replicas ready and has not had all replicas ready for "+unreadyFor.Round(time.Second).String())
+	}
+
+	for key := range failureTimeStamp {
+		if !unready[key] {
+			delete(failureTimeStamp, key)
+		}
+	}
+	return statusErrors
+}
@@ -0,0 +1,86 @@
+package statefulSetStatus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateStatefulSets(t *testing.T) {
+	now := time.Now()
+
+	makeStatefulSet := func(replicas *int32, ready int32) appsv1.StatefulSet {
+		return appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "database", Namespace: "default"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: replicas},
+			Status:     appsv1.StatefulSetStatus{ReadyReplicas: ready},
+		}
+	}
+	three := int32(3)
+
+	var tests = []struct {
+		description   string
+		statefulSet   appsv1.StatefulSet
+		unreadyFor    time.Duration // how long the StatefulSet was already tracked as unready
+		expectedError string
+	}{
+		{"all replicas ready", makeStatefulSet(&three, 3), 0, ""},
+		{"replicas not set", makeStatefulSet(nil, 1), 0, ""},
+		{"newly unready", makeStatefulSet(&three, 2), 0, ""},
+		{"unready within grace period", makeStatefulSet(&three, 2), time.Minute, ""},
+		{"unready past grace period", makeStatefulSet(&three, 2), time.Minute * 10, "StatefulSet database in namespace default has 2 of 3 desired replicas ready and has not had all replicas ready for 10m0s"},
+		{"replicas not set and none ready", makeStatefulSet(nil, 0), time.Minute * 10, "has 0 of 1 desired replicas ready"},
+	}
+
+	for _, test := range tests {
+		failureTimeStamp := make(map[string]time.Time)
+		if test.unreadyFor > 0 {
+			failureTimeStamp["default/database"] = now.Add(-test.unreadyFor)
+		}
+		statusErrors := evaluateStatefulSets([]appsv1.StatefulSet{test.statefulSet}, failureTimeStamp, time.Minute*5, now)
+		if len(test.expectedError) == 0 {
+			if len(statusErrors) != 0 {
+				t.Fatal("Test", test.description, "expected no errors but got", statusErrors)
+			}
+			continue
+		}
+		if len(statusErrors) != 1 || !strings.Contains(statusErrors[0], test.expectedError) {
+			t.Fatal("Test", test.description, "expected an error containing", test.expectedError, "but got", statusErrors)
+		}
+		t.Log(test.description, statusErrors)
+	}
+}
+
+func TestFailureTimeStamp(t *testing.T) {
+	now := time.Now()
+	three := int32(3)
+	unready := appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "database", Namespace: "default"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &three},
+		Status:     appsv1.StatefulSetStatus{ReadyReplicas: 2},
+	}
+	failureTimeStamp := map[string]time.Time{"default/deleted": now.Add(-time.Hour)}
+
+	evaluateStatefulSets([]appsv1.StatefulSet{unready}, failureTimeStamp, time.Minute*5, now)
+	if !failureTimeStamp["default/database"].Equal(now) {
+		t.Fatal("Expected an unready StatefulSet to be tracked from now but got", failureTimeStamp)
+	}
+	if _, ok := failureTimeStamp["default/deleted"]; ok {
+		t.Fatal("Expected a StatefulSet that no longer exists to stop being tracked")
+	}
+
+	// the first time is kept while the StatefulSet stays unready
+	evaluateStatefulSets([]appsv1.StatefulSet{unready}, failureTimeStamp, time.Minute*5, now.Add(time.Minute))
+	if !failureTimeStamp["default/database"].Equal(now) {
+		t.Fatal("Expected the first unready time to be kept but got", failureTimeStamp)
+	}
+
+	unready.Status.ReadyReplicas = 3
+	evaluateStatefulSets([]appsv1.StatefulSet{unready}, failureTimeStamp, time.Minute*5, now.Add(time.Minute*2))
+	if len(failureTimeStamp) != 0 {
+		t.Fatal("Expected a recovered StatefulSet to stop being tracked but got", failureTimeStamp)
+	}
+}
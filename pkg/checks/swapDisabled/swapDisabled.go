@@ -0,0 +1,281 @@
+// Package swapDisabled implements a checker that ensures swap is disabled on
+// every node.  Kubernetes has historically required swap to be disabled, and
+// swap can only be used from Kubernetes 1.28 when the kubelet is configured
+// to allow it.  A DaemonSet pod is run on each node to read /proc/swaps.
+package swapDisabled // import "github.com/Comcast/kuberhealthy/pkg/checks/swapDisabled"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/podRunner"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// swapSupportedMinor is the first minor version of Kubernetes 1.x that
+// supports running nodes with swap
+const swapSupportedMinor = 28
+
+// Checker validates that swap is disabled on all nodes
+type Checker struct {
+	Errors    []string
+	AllowSwap bool
+	Image     string
+	client    *kubernetes.Clientset
+	// runOnNodes is replaced in tests to inject pod output
+	runOnNodes func(client *kubernetes.Clientset, namespace string, script podRunner.Script, timeout time.Duration) (map[string]string, error)
+	// failSwapOn is replaced in tests to inject kubelet configuration
+	failSwapOn func(client *kubernetes.Clientset, nodeName string) (bool, error)
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker.  When allowSwap is true, swap is permitted on
+// clusters that support it as long as the kubelet is configured to run with
+// swap.
+func New(allowSwap bool) *Checker {
+	return &Checker{
+		Errors:     []string{},
+		AllowSwap:  allowSwap,
+		Image:      "busybox:1.30",
+		runOnNodes: podRunner.RunOnNodes,
+		failSwapOn: fetchFailSwapOn,
+	}
+}
+
+// Name returns the name of this checker
+func (sdc *Checker) Name() string {
+	return "SwapDisabledChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (sdc *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (sdc *Checker) Interval() time.Duration {
+	if sdc.interval > 0 {
+		return sdc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (sdc *Checker) SetInterval(d time.Duration) {
+	sdc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  The swap of each node
+// is read by a DaemonSet in the Kuberhealthy namespace and the kubelet
+// configuration through the cluster scoped node proxy.
+func (sdc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		namespace: podRunner.RunOnNodesRBACRules(),
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes/proxy"}, Verbs: []string{"get"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (sdc *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (sdc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (sdc *Checker) CurrentStatus() (bool, []string) {
+	if len(sdc.Errors) > 0 {
+		return false, sdc.Errors
+	}
+	return true, sdc.Errors
+}
+
+// clearErrors clears all errors
+func (sdc *Checker) clearErrors() {
+	sdc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (sdc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	sdc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := sdc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(sdc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + sdc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(sdc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + sdc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + sdc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks reads /proc/swaps on every node and sets an error for every node
+// with swap that is not allowed
+func (sdc *Checker) doChecks() error {
+
+	version, err := sdc.client.Discovery().ServerVersion()
+	if err != nil {
+		return errors.New("Error getting server version: " + err.Error())
+	}
+	swapSupported := supportsSwap(version.Major, version.Minor)
+
+	script := podRunner.Script{
+		Name:   "swap-disabled",
+		Image:  sdc.Image,
+		Script: "cat /proc/swaps",
+	}
+
+	// leave time to clean up the DaemonSet before the check times out
+	output, err := sdc.runOnNodes(sdc.client, namespace, script, sdc.Timeout()-time.Minute)
+	if err != nil && len(output) == 0 {
+		return err
+	}
+
+	var swapErrors []string
+	if err != nil {
+		swapErrors = append(swapErrors, err.Error())
+	}
+	nodeErrors, err := sdc.evaluateNodes(output, swapSupported)
+	if err != nil {
+		return err
+	}
+	swapErrors = append(swapErrors, nodeErrors...)
+
+	if len(swapErrors) > 0 {
+		for _, e := range swapErrors {
+			khlog.ForCheck(sdc.Name()).Warningln(e)
+		}
+		sdc.Errors = swapErrors
+		return nil
+	}
+
+	sdc.clearErrors()
+	return nil
+}
+
+// evaluateNodes returns an error for every node whose swap configuration is
+// not allowed.  On clusters that support swap, the kubelet configuration of
+// each node is also compared with the swap that is active.
+func (sdc *Checker) evaluateNodes(output map[string]string, swapSupported bool) ([]string, error) {
+	var swapErrors []string
+
+	var nodes []string
+	for node := range output {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	for _, node := range nodes {
+		devices := parseProcSwaps(output[node])
+		active := len(devices) > 0
+
+		if !swapSupported {
+			if active && sdc.AllowSwap {
+				swapErrors = append(swapErrors, "Node "+node+" has active swap on "+strings.Join(devices, ", ")+" but swap is not supported before Kubernetes 1.28")
+			} else if active {
+				swapErrors = append(swapErrors, "Node "+node+" has active swap on "+strings.Join(devices, ", "))
+			}
+			continue
+		}
+
+		failSwapOn, err := sdc.failSwapOn(sdc.client, node)
+		if err != nil {
+			return swapErrors, errors.New("Error reading kubelet configuration of node " + node + ": " + err.Error())
+		}
+
+		switch {
+		case active && !sdc.AllowSwap:
+			swapErrors = append(swapErrors, "Node "+node+" has active swap on "+strings.Join(devices, ", "))
+		case active && failSwapOn:
+			swapErrors = append(swapErrors, "Node "+node+" has active swap on "+strings.Join(devices, ", ")+" but the kubelet is configured with failSwapOn true")
+		case !active && !sdc.AllowSwap && !failSwapOn:
+			swapErrors = append(swapErrors, "Node "+node+" kubelet is configured with failSwapOn false but swap is not allowed")
+		}
+	}
+	return swapErrors, nil
+}
+
+// parseProcSwaps returns the swap devices listed in the content of
+// /proc/swaps.  The first line is a header.
+func parseProcSwaps(procSwaps string) []string {
+	var devices []string
+	for _, line := range strings.Split(procSwaps, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] == "Filename" {
+			continue
+		}
+		devices = append(devices, fields[0])
+	}
+	return devices
+}
+
+// supportsSwap determines if a Kubernetes version supports running nodes
+// with swap.  Minor versions may have a suffix such as "28+".
+func supportsSwap(major string, minor string) bool {
+	majorVersion, err := strconv.Atoi(strings.TrimRight(major, "+"))
+	if err != nil {
+		return false
+	}
+	minorVersion, err := strconv.Atoi(strings.TrimRight(minor, "+"))
+	if err != nil {
+		return false
+	}
+	return majorVersion > 1 || (majorVersion == 1 && minorVersion >= swapSupportedMinor)
+}
+
+// fetchFailSwapOn reads the failSwapOn setting of a node's kubelet through
+// the API server node proxy.  The kubelet defaults to true when unset.
+func fetchFailSwapOn(client *kubernetes.Clientset, nodeName string) (bool, error) {
+	b, err := client.CoreV1().RESTClient().Get().AbsPath("/api/v1/nodes", nodeName, "proxy", "configz").DoRaw()
+	if err != nil {
+		return false, err
+	}
+
+	var configz struct {
+		KubeletConfig *struct {
+			FailSwapOn *bool `json:"failSwapOn"`
+		} `json:"kubeletconfig"`
+	}
+	err = json.Unmarshal(b, &configz)
+	if err != nil {
+		return false, errors.New("Error decoding kubelet configuration: " + err.Error())
+	}
+	if configz.KubeletConfig == nil {
+		return false, errors.New("configz response did not contain a kubeletconfig")
+	}
+	if configz.KubeletConfig.FailSwapOn == nil {
+		return true, nil
+	}
+	return *configz.KubeletConfig.FailSwapOn, nil
+}
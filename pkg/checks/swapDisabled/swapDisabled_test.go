@@ -0,0 +1,80 @@
+package swapDisabled
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+const noSwap = "Filename\t\t\t\tType\t\tSize\tUsed\tPriority\n"
+
+const activeSwap = "Filename\t\t\t\tType\t\tSize\tUsed\tPriority\n" +
+	"/dev/sda2                               partition\t8388604\t0\t-2\n" +
+	"/swapfile                               file\t\t2097148\t0\t-3\n"
+
+func TestParseProcSwaps(t *testing.T) {
+	if devices := parseProcSwaps(noSwap); len(devices) != 0 {
+		t.Fatal("Expected no swap devices but got", devices)
+	}
+	devices := parseProcSwaps(activeSwap)
+	if len(devices) != 2 || devices[0] != "/dev/sda2" || devices[1] != "/swapfile" {
+		t.Fatal("Unexpected swap devices:", devices)
+	}
+}
+
+func TestSupportsSwap(t *testing.T) {
+	var tests = []struct {
+		major    string
+		minor    string
+		expected bool
+	}{
+		{"1", "27", false},
+		{"1", "28", true},
+		{"1", "29+", true},
+		{"1", "13", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		if supportsSwap(test.major, test.minor) != test.expected {
+			t.Fatal("Test", test.major+"."+test.minor, "expected", test.expected)
+		}
+	}
+}
+
+func TestEvaluateNodes(t *testing.T) {
+	var tests = []struct {
+		description   string
+		procSwaps     string
+		allowSwap     bool
+		swapSupported bool
+		failSwapOn    bool
+		expected      int
+	}{
+		{"no swap before 1.28", noSwap, false, false, true, 0},
+		{"swap before 1.28", activeSwap, false, false, true, 1},
+		{"swap allowed before 1.28", activeSwap, true, false, false, 1},
+		{"no swap with failSwapOn", noSwap, false, true, true, 0},
+		{"no swap without failSwapOn", noSwap, false, true, false, 1},
+		{"swap not allowed", activeSwap, false, true, false, 1},
+		{"swap allowed", activeSwap, true, true, false, 0},
+		{"swap allowed with failSwapOn", activeSwap, true, true, true, 1},
+		{"swap allowed but unused", noSwap, true, true, false, 0},
+	}
+
+	for _, test := range tests {
+		checker := New(test.allowSwap)
+		failSwapOn := test.failSwapOn
+		checker.failSwapOn = func(client *kubernetes.Clientset, nodeName string) (bool, error) {
+			return failSwapOn, nil
+		}
+		swapErrors, err := checker.evaluateNodes(map[string]string{"node-a": test.procSwaps}, test.swapSupported)
+		if err != nil {
+			t.Fatal("Test", test.description, "returned an error:", err)
+		}
+		if len(swapErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", swapErrors)
+		}
+		t.Log(test.description, swapErrors)
+	}
+}
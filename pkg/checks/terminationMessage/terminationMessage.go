@@ -0,0 +1,188 @@
+// Package terminationMessage implements a checker that finds critical
+// containers using the default terminationMessagePolicy of File.  With that
+// policy, a container that crashes without writing its termination message
+// file reports nothing, while FallbackToLogsOnError reports the tail of its
+// logs.  Pods are marked critical with an annotation.
+package terminationMessage // import "github.com/Comcast/kuberhealthy/pkg/checks/terminationMessage"
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// RequireFallbackAnnotation marks a pod as critical when set to "true"
+const RequireFallbackAnnotation = "kuberhealthy.io/require-fallback-termination"
+
+// Checker validates the termination message policy of critical containers
+type Checker struct {
+	Errors     []string
+	Namespaces []string
+	Enforcing  bool
+	client     *kubernetes.Clientset
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied namespaces, or all namespaces
+// when none are supplied.  Unless enforcing is true, violations are only
+// logged and do not fail the check.
+func New(namespaces []string, enforcing bool) *Checker {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &Checker{
+		Errors:     []string{},
+		Namespaces: namespaces,
+		Enforcing:  enforcing,
+	}
+}
+
+// Name returns the name of this checker
+func (tmc *Checker) Name() string {
+	return "TerminationMessageChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (tmc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (tmc *Checker) Interval() time.Duration {
+	if tmc.interval > 0 {
+		return tmc.interval
+	}
+	return time.Minute * 15
+}
+
+// SetInterval overrides the interval at which this check runs
+func (tmc *Checker) SetInterval(d time.Duration) {
+	tmc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Pods are listed in
+// each checked namespace, which needs a cluster role when all namespaces are
+// checked.
+func (tmc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range tmc.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		}
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (tmc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (tmc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (tmc *Checker) CurrentStatus() (bool, []string) {
+	if len(tmc.Errors) > 0 {
+		return false, tmc.Errors
+	}
+	return true, tmc.Errors
+}
+
+// clearErrors clears all errors
+func (tmc *Checker) clearErrors() {
+	tmc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (tmc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	tmc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := tmc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(tmc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + tmc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(tmc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + tmc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + tmc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists pods in each namespace and reports every critical container
+// using the File termination message policy.  Violations only set errors
+// when the check is enforcing.
+func (tmc *Checker) doChecks() error {
+
+	var violations []string
+	for _, ns := range tmc.Namespaces {
+		pods, err := tmc.client.CoreV1().Pods(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		violations = append(violations, evaluatePods(pods.Items)...)
+	}
+
+	if len(violations) > 0 && tmc.Enforcing {
+		for _, v := range violations {
+			khlog.ForCheck(tmc.Name()).Warningln(v)
+		}
+		tmc.Errors = violations
+		return nil
+	}
+
+	for _, v := range violations {
+		khlog.ForCheck(tmc.Name()).Infoln(v)
+	}
+	tmc.clearErrors()
+	return nil
+}
+
+// evaluatePods returns a violation for every container of an annotated pod
+// that does not use the FallbackToLogsOnError termination message policy.
+// An empty policy is defaulted to File by the API server.
+func evaluatePods(pods []apiv1.Pod) []string {
+	var violations []string
+
+	for _, p := range pods {
+		if p.Annotations[RequireFallbackAnnotation] != "true" {
+			continue
+		}
+
+		containers := append(append([]apiv1.Container{}, p.Spec.InitContainers...), p.Spec.Containers...)
+		for _, c := range containers {
+			if c.TerminationMessagePolicy == apiv1.TerminationMessageFallbackToLogsOnError {
+				continue
+			}
+			policy := c.TerminationMessagePolicy
+			if len(policy) == 0 {
+				policy = apiv1.TerminationMessageReadFile
+			}
+			violations = append(violations, "Pod "+p.Namespace+"/"+p.Name+" container "+c.Name+" uses terminationMessagePolicy "+string(policy)+" instead of "+string(apiv1.TerminationMessageFallbackToLogsOnError))
+		}
+	}
+	return violations
+}
@@ -0,0 +1,57 @@
+package terminationMessage
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluatePods(t *testing.T) {
+	makePod := func(critical bool, policies ...apiv1.TerminationMessagePolicy) apiv1.Pod {
+		pod := apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: map[string]string{}},
+		}
+		if critical {
+			pod.Annotations[RequireFallbackAnnotation] = "true"
+		}
+		for i, policy := range policies {
+			pod.Spec.Containers = append(pod.Spec.Containers, apiv1.Container{
+				Name:                     "container-" + string(rune('a'+i)),
+				TerminationMessagePolicy: policy,
+			})
+		}
+		return pod
+	}
+
+	withInit := makePod(true, apiv1.TerminationMessageFallbackToLogsOnError)
+	withInit.Spec.InitContainers = []apiv1.Container{{Name: "init", TerminationMessagePolicy: apiv1.TerminationMessageReadFile}}
+
+	var tests = []struct {
+		description string
+		pod         apiv1.Pod
+		expected    int
+	}{
+		{"critical with fallback", makePod(true, apiv1.TerminationMessageFallbackToLogsOnError), 0},
+		{"critical with file", makePod(true, apiv1.TerminationMessageReadFile), 1},
+		{"critical with unset policy", makePod(true, ""), 1},
+		{"critical with mixed containers", makePod(true, apiv1.TerminationMessageFallbackToLogsOnError, apiv1.TerminationMessageReadFile), 1},
+		{"critical init container with file", withInit, 1},
+		{"not critical with file", makePod(false, apiv1.TerminationMessageReadFile), 0},
+	}
+
+	for _, test := range tests {
+		violations := evaluatePods([]apiv1.Pod{test.pod})
+		if len(violations) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "violations but got", violations)
+		}
+		t.Log(test.description, violations)
+	}
+
+	// an annotation that is not "true" does not mark a pod critical
+	pod := makePod(false, apiv1.TerminationMessageReadFile)
+	pod.Annotations[RequireFallbackAnnotation] = "false"
+	if violations := evaluatePods([]apiv1.Pod{pod}); len(violations) != 0 {
+		t.Fatal("Expected no violations for a pod annotated false but got", violations)
+	}
+}
@@ -0,0 +1,245 @@
+// Package tlsCertExpiry implements a checker that warns before the TLS
+// certificates served by endpoints such as cluster ingresses expire.
+package tlsCertExpiry // import "github.com/Comcast/kuberhealthy/pkg/checks/tlsCertExpiry"
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/metrics"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Checker validates that the certificates served by TLS endpoints are not
+// about to expire
+type Checker struct {
+	Errors       []string
+	Endpoints    []string
+	WarningDays  int
+	DialTimeout  time.Duration
+	metricClient metrics.Client
+	// dial is replaced in tests to inject the certificates an endpoint
+	// presents
+	dial     func(address string, serverName string, timeout time.Duration) ([]*x509.Certificate, error)
+	interval time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reports endpoints given as host:port whose
+// certificates expire within warningDays.  The days until each endpoint's
+// certificate expires are pushed to the metric client when it is not nil.
+func New(endpoints []string, warningDays int, dialTimeout time.Duration, metricClient metrics.Client) (*Checker, error) {
+	for _, e := range endpoints {
+		if _, _, err := net.SplitHostPort(e); err != nil {
+			return nil, errors.New("TLS endpoint " + e + " must be given as host:port: " + err.Error())
+		}
+	}
+	return &Checker{
+		Errors:       []string{},
+		Endpoints:    endpoints,
+		WarningDays:  warningDays,
+		DialTimeout:  dialTimeout,
+		metricClient: metricClient,
+		dial:         dialTLS,
+	}, nil
+}
+
+// Name returns the name of this checker
+func (tcc *Checker) Name() string {
+	return "TLSCertExpiryChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (tcc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (tcc *Checker) Interval() time.Duration {
+	if tcc.interval > 0 {
+		return tcc.interval
+	}
+	return time.Hour * 1
+}
+
+// SetInterval overrides the interval at which this check runs
+func (tcc *Checker) SetInterval(d time.Duration) {
+	tcc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Endpoints are dialed
+// directly, so none are needed.
+func (tcc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (tcc *Checker) Timeout() time.Duration {
+	return time.Minute * 5
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (tcc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (tcc *Checker) CurrentStatus() (bool, []string) {
+	if len(tcc.Errors) > 0 {
+		return false, tcc.Errors
+	}
+	return true, tcc.Errors
+}
+
+// clearErrors clears all errors
+func (tcc *Checker) clearErrors() {
+	tcc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (tcc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := tcc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(tcc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + tcc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(tcc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + tcc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + tcc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks dials every endpoint and sets an error for every endpoint that
+// can not be reached or whose certificate expires within the warning window
+func (tcc *Checker) doChecks() error {
+
+	certErrors := tcc.checkEndpoints(time.Now())
+
+	if len(certErrors) > 0 {
+		for _, e := range certErrors {
+			khlog.ForCheck(tcc.Name()).Warningln(e)
+		}
+		tcc.Errors = certErrors
+		return nil
+	}
+
+	tcc.clearErrors()
+	return nil
+}
+
+// checkEndpoints dials every endpoint with its host as the SNI server name
+// and returns an error for every endpoint that can not be reached or whose
+// certificate chain expires within the warning window.  The chain expires
+// when the first certificate in it expires.
+func (tcc *Checker) checkEndpoints(now time.Time) []string {
+	var certErrors []string
+
+	for _, endpoint := range tcc.Endpoints {
+		host, _, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			certErrors = append(certErrors, "TLS endpoint "+endpoint+" is not a valid host:port: "+err.Error())
+			continue
+		}
+
+		chain, err := tcc.dial(endpoint, host, tcc.DialTimeout)
+		if err != nil {
+			certErrors = append(certErrors, "Unable to connect to TLS endpoint "+endpoint+": "+err.Error())
+			continue
+		}
+		if len(chain) == 0 {
+			certErrors = append(certErrors, "TLS endpoint "+endpoint+" presented no certificate")
+			continue
+		}
+
+		expiring := chain[0]
+		for _, c := range chain[1:] {
+			if c.NotAfter.Before(expiring.NotAfter) {
+				expiring = c
+			}
+		}
+		days := daysUntil(expiring.NotAfter, now)
+
+		khlog.ForCheck(tcc.Name()).WithFields(log.Fields{
+			"endpoint":   endpoint,
+			"subject":    expiring.Subject.CommonName,
+			"issuer":     expiring.Issuer.CommonName,
+			"expiry":     expiring.NotAfter.Format(time.RFC3339),
+			"expiryDays": days,
+		}).Infoln("TLS endpoint certificate expiry")
+		tcc.pushExpiry(endpoint, days)
+
+		switch {
+		case days < 0:
+			certErrors = append(certErrors, "TLS endpoint "+endpoint+" certificate "+expiring.Subject.CommonName+" issued by "+expiring.Issuer.CommonName+
+				" expired "+strconv.Itoa(-days)+" days ago on "+expiring.NotAfter.Format(time.RFC3339))
+		case days < tcc.WarningDays:
+			certErrors = append(certErrors, "TLS endpoint "+endpoint+" certificate "+expiring.Subject.CommonName+" issued by "+expiring.Issuer.CommonName+
+				" expires in "+strconv.Itoa(days)+" days on "+expiring.NotAfter.Format(time.RFC3339))
+		}
+	}
+	return certErrors
+}
+
+// pushExpiry sends the days until an endpoint's certificate expires to the
+// metric client
+func (tcc *Checker) pushExpiry(endpoint string, days int) {
+	if tcc.metricClient == nil {
+		return
+	}
+	metric := metrics.Metric{
+		{tcc.Name() + "_expiry_days": days},
+	}
+	tags := map[string]string{
+		"Endpoint": endpoint,
+	}
+	err := tcc.metricClient.Push(metric, tags)
+	if err != nil {
+		khlog.ForCheck(tcc.Name()).Errorln("Error forwarding metrics", err)
+	}
+}
+
+// daysUntil returns the whole days from now until a time, which are negative
+// once the time has passed
+func daysUntil(t time.Time, now time.Time) int {
+	return int(math.Floor(t.Sub(now).Hours() / 24))
+}
+
+// dialTLS connects to a server and returns the certificates it presents.
+// The certificates are not verified so that the expiry of certificates that
+// are already invalid is still reported.
+func dialTLS(address string, serverName string, timeout time.Duration) ([]*x509.Certificate, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates, nil
+}
@@ -0,0 +1,92 @@
+package tlsCertExpiry
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/metrics"
+)
+
+// fakeMetricClient records pushed metrics
+type fakeMetricClient struct {
+	pushed []metrics.Metric
+}
+
+// Push records each pushed metric
+func (f *fakeMetricClient) Push(points metrics.Metric, tags map[string]string) error {
+	f.pushed = append(f.pushed, points)
+	return nil
+}
+
+// SetGlobalTags is implemented to satisfy metrics.Client but is not used
+func (f *fakeMetricClient) SetGlobalTags(tags map[string]string) {}
+
+func makeCert(commonName string, validFor time.Duration, now time.Time) *x509.Certificate {
+	return &x509.Certificate{
+		Subject:  pkix.Name{CommonName: commonName},
+		Issuer:   pkix.Name{CommonName: "Example CA"},
+		NotAfter: now.Add(validFor),
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New([]string{"example.com:443", "[::1]:8443"}, 14, time.Second*10, nil)
+	if err != nil {
+		t.Fatal("Unexpected error for valid endpoints:", err)
+	}
+	_, err = New([]string{"example.com"}, 14, time.Second*10, nil)
+	if err == nil {
+		t.Fatal("Expected an error for an endpoint without a port")
+	}
+}
+
+func TestCheckEndpoints(t *testing.T) {
+	now := time.Now()
+	day := time.Hour * 24
+
+	var tests = []struct {
+		description string
+		chain       []*x509.Certificate
+		dialErr     error
+		expected    int
+		expectedDay int
+	}{
+		{"valid", []*x509.Certificate{makeCert("example.com", day*90+time.Hour, now)}, nil, 0, 90},
+		{"expiring", []*x509.Certificate{makeCert("example.com", day*10+time.Hour, now)}, nil, 1, 10},
+		{"expired", []*x509.Certificate{makeCert("example.com", -day*2, now)}, nil, 1, -2},
+		{"intermediate expiring", []*x509.Certificate{makeCert("example.com", day*90, now), makeCert("Intermediate", day*5+time.Hour, now)}, nil, 1, 5},
+		{"unreachable", nil, errors.New("connection refused"), 1, 0},
+	}
+
+	for _, test := range tests {
+		metricClient := &fakeMetricClient{}
+		checker, err := New([]string{"example.com:443"}, 14, time.Second*10, metricClient)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chain := test.chain
+		dialErr := test.dialErr
+		var serverName string
+		checker.dial = func(address string, name string, timeout time.Duration) ([]*x509.Certificate, error) {
+			serverName = name
+			return chain, dialErr
+		}
+
+		certErrors := checker.checkEndpoints(now)
+		if len(certErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", certErrors)
+		}
+		if serverName != "example.com" {
+			t.Fatal("Test", test.description, "expected the SNI server name example.com but got", serverName)
+		}
+		if test.dialErr == nil {
+			if len(metricClient.pushed) != 1 || metricClient.pushed[0][0][checker.Name()+"_expiry_days"] != test.expectedDay {
+				t.Fatal("Test", test.description, "expected", test.expectedDay, "days to expiry to be pushed but got", metricClient.pushed)
+			}
+		}
+		t.Log(test.description, certErrors)
+	}
+}
@@ -0,0 +1,278 @@
+// Package uidRanges implements a checker that ensures the UID and
+// supplemental group ranges assigned to namespaces do not overlap.  These
+// ranges are assigned with namespace annotations by OpenShift and similar
+// distributions so that workloads of different tenants run as different
+// users.  The check is skipped on clusters that do not use the annotations.
+package uidRanges // import "github.com/Comcast/kuberhealthy/pkg/checks/uidRanges"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// UIDRangeAnnotation holds the UID range assigned to a namespace
+const UIDRangeAnnotation = "openshift.io/sa.scc.uid-range"
+
+// SupplementalGroupsAnnotation holds the supplemental group ranges assigned
+// to a namespace
+const SupplementalGroupsAnnotation = "openshift.io/sa.scc.supplemental-groups"
+
+// idRange is an inclusive range of IDs assigned to a namespace
+type idRange struct {
+	Namespace string
+	Start     uint64
+	End       uint64
+}
+
+// String formats the range as it is written in annotations
+func (r idRange) String() string {
+	return strconv.FormatUint(r.Start, 10) + "-" + strconv.FormatUint(r.End, 10)
+}
+
+// Checker validates that namespace UID and group ranges do not overlap
+type Checker struct {
+	Errors   []string
+	client   *kubernetes.Clientset
+	interval time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker
+func New() *Checker {
+	return &Checker{
+		Errors: []string{},
+	}
+}
+
+// Name returns the name of this checker
+func (urc *Checker) Name() string {
+	return "UIDRangeChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (urc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (urc *Checker) Interval() time.Duration {
+	if urc.interval > 0 {
+		return urc.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (urc *Checker) SetInterval(d time.Duration) {
+	urc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs to list the cluster
+// scoped namespaces and their UID range annotations
+func (urc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (urc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (urc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (urc *Checker) CurrentStatus() (bool, []string) {
+	if len(urc.Errors) > 0 {
+		return false, urc.Errors
+	}
+	return true, urc.Errors
+}
+
+// clearErrors clears all errors
+func (urc *Checker) clearErrors() {
+	urc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (urc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	urc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := urc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(urc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + urc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(urc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + urc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + urc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists all namespaces and sets an error for every pair of
+// namespaces with overlapping UID or supplemental group ranges
+func (urc *Checker) doChecks() error {
+
+	namespaces, err := urc.client.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	if !rangesAssigned(namespaces.Items) {
+		khlog.ForCheck(urc.Name()).Debugln("No namespaces have UID range annotations. Skipping check.")
+		urc.clearErrors()
+		return nil
+	}
+
+	var rangeErrors []string
+	for _, annotation := range []string{UIDRangeAnnotation, SupplementalGroupsAnnotation} {
+		rangeErrors = append(rangeErrors, findOverlaps(namespaces.Items, annotation)...)
+	}
+
+	if len(rangeErrors) > 0 {
+		for _, e := range rangeErrors {
+			khlog.ForCheck(urc.Name()).Warningln(e)
+		}
+		urc.Errors = rangeErrors
+		return nil
+	}
+
+	urc.clearErrors()
+	return nil
+}
+
+// rangesAssigned determines if any namespace has a UID range or
+// supplemental group annotation
+func rangesAssigned(namespaces []apiv1.Namespace) bool {
+	for _, ns := range namespaces {
+		if len(ns.Annotations[UIDRangeAnnotation]) > 0 || len(ns.Annotations[SupplementalGroupsAnnotation]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// findOverlaps returns an error for every pair of namespaces whose ranges in
+// the supplied annotation overlap, and for every annotation that can not be
+// parsed
+func findOverlaps(namespaces []apiv1.Namespace, annotation string) []string {
+	var rangeErrors []string
+
+	var ranges []idRange
+	for _, ns := range namespaces {
+		value := ns.Annotations[annotation]
+		if len(value) == 0 {
+			continue
+		}
+		r, err := parseRanges(ns.Name, value)
+		if err != nil {
+			rangeErrors = append(rangeErrors, "Namespace "+ns.Name+" has an invalid "+annotation+" annotation: "+err.Error())
+			continue
+		}
+		ranges = append(ranges, r...)
+	}
+
+	// sort by start so that only following ranges can overlap a range
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].Start != ranges[j].Start {
+			return ranges[i].Start < ranges[j].Start
+		}
+		return ranges[i].Namespace < ranges[j].Namespace
+	})
+
+	reported := make(map[string]bool)
+	for i := range ranges {
+		for j := i + 1; j < len(ranges) && ranges[j].Start <= ranges[i].End; j++ {
+			if ranges[i].Namespace == ranges[j].Namespace {
+				continue
+			}
+			pair := []string{ranges[i].Namespace, ranges[j].Namespace}
+			sort.Strings(pair)
+			key := pair[0] + "/" + pair[1]
+			if reported[key] {
+				continue
+			}
+			reported[key] = true
+			rangeErrors = append(rangeErrors, fmt.Sprintf("Namespaces %s and %s have overlapping %s ranges %s and %s",
+				ranges[i].Namespace, ranges[j].Namespace, annotation, ranges[i].String(), ranges[j].String()))
+		}
+	}
+	return rangeErrors
+}
+
+// parseRanges parses a comma separated list of ranges in the form
+// "<start>/<size>" or "<start>-<end>"
+func parseRanges(namespace string, value string) ([]idRange, error) {
+	var ranges []idRange
+	for _, block := range strings.Split(value, ",") {
+		block = strings.TrimSpace(block)
+		if len(block) == 0 {
+			continue
+		}
+
+		separator := "/"
+		if !strings.Contains(block, separator) {
+			separator = "-"
+		}
+		parts := strings.SplitN(block, separator, 2)
+		if len(parts) != 2 {
+			return nil, errors.New("range " + block + " is not in the form <start>/<size> or <start>-<end>")
+		}
+		start, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return nil, errors.New("range " + block + " has an invalid start: " + err.Error())
+		}
+		n, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			return nil, errors.New("range " + block + " has an invalid end or size: " + err.Error())
+		}
+
+		end := n
+		if separator == "/" {
+			if n == 0 {
+				return nil, errors.New("range " + block + " has a size of zero")
+			}
+			end = start + n - 1
+		}
+		if end < start {
+			return nil, errors.New("range " + block + " ends before it starts")
+		}
+		ranges = append(ranges, idRange{Namespace: namespace, Start: start, End: end})
+	}
+
+	if len(ranges) == 0 {
+		return nil, errors.New("no ranges found")
+	}
+	return ranges, nil
+}
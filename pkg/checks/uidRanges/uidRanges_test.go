@@ -0,0 +1,105 @@
+package uidRanges
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makeNamespace(name string, annotations map[string]string) apiv1.Namespace {
+	return apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+}
+
+func TestFindOverlaps(t *testing.T) {
+	var tests = []struct {
+		description string
+		namespaces  []apiv1.Namespace
+		expected    int
+	}{
+		{"disjoint", []apiv1.Namespace{
+			makeNamespace("a", map[string]string{UIDRangeAnnotation: "1000000000/10000"}),
+			makeNamespace("b", map[string]string{UIDRangeAnnotation: "1000010000/10000"}),
+		}, 0},
+		{"identical", []apiv1.Namespace{
+			makeNamespace("a", map[string]string{UIDRangeAnnotation: "1000000000/10000"}),
+			makeNamespace("b", map[string]string{UIDRangeAnnotation: "1000000000/10000"}),
+		}, 1},
+		{"partial overlap", []apiv1.Namespace{
+			makeNamespace("a", map[string]string{UIDRangeAnnotation: "1000000000/10000"}),
+			makeNamespace("b", map[string]string{UIDRangeAnnotation: "1000009999/10000"}),
+		}, 1},
+		{"dash format", []apiv1.Namespace{
+			makeNamespace("a", map[string]string{UIDRangeAnnotation: "5000-5999"}),
+			makeNamespace("b", map[string]string{UIDRangeAnnotation: "5500/100"}),
+		}, 1},
+		{"three way", []apiv1.Namespace{
+			makeNamespace("a", map[string]string{UIDRangeAnnotation: "1000/1000"}),
+			makeNamespace("b", map[string]string{UIDRangeAnnotation: "1500/1000"}),
+			makeNamespace("c", map[string]string{UIDRangeAnnotation: "1999/10"}),
+		}, 3},
+		{"overlapping blocks reported once", []apiv1.Namespace{
+			makeNamespace("a", map[string]string{UIDRangeAnnotation: "1000/10,2000/10"}),
+			makeNamespace("b", map[string]string{UIDRangeAnnotation: "1005/10,2005/10"}),
+		}, 1},
+		{"own blocks overlap", []apiv1.Namespace{
+			makeNamespace("a", map[string]string{UIDRangeAnnotation: "1000/10,1005/10"}),
+		}, 0},
+		{"invalid annotation", []apiv1.Namespace{
+			makeNamespace("a", map[string]string{UIDRangeAnnotation: "not-a-range"}),
+			makeNamespace("b", map[string]string{UIDRangeAnnotation: "1000/10"}),
+		}, 1},
+		{"unannotated", []apiv1.Namespace{
+			makeNamespace("a", nil),
+			makeNamespace("b", map[string]string{UIDRangeAnnotation: "1000/10"}),
+		}, 0},
+	}
+
+	for _, test := range tests {
+		rangeErrors := findOverlaps(test.namespaces, UIDRangeAnnotation)
+		if len(rangeErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", rangeErrors)
+		}
+		t.Log(test.description, rangeErrors)
+	}
+}
+
+func TestParseRanges(t *testing.T) {
+	var tests = []struct {
+		value    string
+		expected []idRange
+		valid    bool
+	}{
+		{"1000000000/10000", []idRange{{Start: 1000000000, End: 1000009999}}, true},
+		{"1000-1999", []idRange{{Start: 1000, End: 1999}}, true},
+		{"1000/10, 2000/10", []idRange{{Start: 1000, End: 1009}, {Start: 2000, End: 2009}}, true},
+		{"1000/0", nil, false},
+		{"2000-1000", nil, false},
+		{"1000", nil, false},
+		{"", nil, false},
+	}
+
+	for _, test := range tests {
+		ranges, err := parseRanges("", test.value)
+		if test.valid != (err == nil) {
+			t.Fatal("Test", test.value, "expected valid to be", test.valid, "but got error", err)
+		}
+		if len(ranges) != len(test.expected) {
+			t.Fatal("Test", test.value, "expected", test.expected, "but got", ranges)
+		}
+		for i := range ranges {
+			if ranges[i] != test.expected[i] {
+				t.Fatal("Test", test.value, "expected", test.expected, "but got", ranges)
+			}
+		}
+	}
+}
+
+func TestRangesAssigned(t *testing.T) {
+	if rangesAssigned([]apiv1.Namespace{makeNamespace("default", nil), makeNamespace("kube-system", map[string]string{"other": "value"})}) {
+		t.Fatal("Expected a cluster without range annotations to be skipped")
+	}
+	if !rangesAssigned([]apiv1.Namespace{makeNamespace("a", map[string]string{SupplementalGroupsAnnotation: "1000/10"})}) {
+		t.Fatal("Expected a cluster with supplemental group annotations to be checked")
+	}
+}
@@ -0,0 +1,204 @@
+// Package upgradePDBs implements a checker that finds PodDisruptionBudgets
+// in critical namespaces that would block a node drain.  Cluster upgrades
+// drain nodes one at a time, and a budget that allows no disruptions while
+// all of its pods are healthy stops the drain until it is changed by hand.
+package upgradePDBs // import "github.com/Comcast/kuberhealthy/pkg/checks/upgradePDBs"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Checker validates that PodDisruptionBudgets in critical namespaces allow
+// nodes to be drained
+type Checker struct {
+	Errors     []string
+	Namespaces []string
+	client     *kubernetes.Clientset
+	interval   time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker for the supplied critical namespaces
+func New(namespaces []string) *Checker {
+	return &Checker{
+		Errors:     []string{},
+		Namespaces: namespaces,
+	}
+}
+
+// Name returns the name of this checker
+func (upc *Checker) Name() string {
+	return "UpgradePDBChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (upc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (upc *Checker) Interval() time.Duration {
+	if upc.interval > 0 {
+		return upc.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (upc *Checker) SetInterval(d time.Duration) {
+	upc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs in each critical
+// namespace
+func (upc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, ns := range upc.Namespaces {
+		rules[ns] = []rbacv1.PolicyRule{
+			{APIGroups: []string{"policy"}, Resources: []string{"poddisruptionbudgets"}, Verbs: []string{"list"}},
+		}
+	}
+	return rules
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (upc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (upc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (upc *Checker) CurrentStatus() (bool, []string) {
+	if len(upc.Errors) > 0 {
+		return false, upc.Errors
+	}
+	return true, upc.Errors
+}
+
+// clearErrors clears all errors
+func (upc *Checker) clearErrors() {
+	upc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (upc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	upc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := upc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(upc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + upc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(upc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + upc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + upc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists PodDisruptionBudgets in each critical namespace and sets an
+// error for every budget that would block a node drain
+func (upc *Checker) doChecks() error {
+
+	var pdbs []policyv1beta1.PodDisruptionBudget
+	for _, ns := range upc.Namespaces {
+		list, err := upc.client.PolicyV1beta1().PodDisruptionBudgets(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return errors.New("Error listing PodDisruptionBudgets in namespace " + ns + ": " + err.Error())
+		}
+		pdbs = append(pdbs, list.Items...)
+	}
+
+	pdbErrors := evaluatePDBs(pdbs)
+
+	if len(pdbErrors) > 0 {
+		for _, e := range pdbErrors {
+			khlog.ForCheck(upc.Name()).Warningln(e)
+		}
+		upc.Errors = pdbErrors
+		return nil
+	}
+
+	upc.clearErrors()
+	return nil
+}
+
+// evaluatePDBs returns an error for every PodDisruptionBudget that allows no
+// disruptions while all of its expected pods are healthy.  Such a budget
+// blocks the eviction of its pods during a node drain.  Budgets that allow
+// no disruption of any pod by design, with a minAvailable of every expected
+// pod or a maxUnavailable of 0, are reported as such.  Budgets with
+// unhealthy pods are skipped because they block evictions only until their
+// pods recover.
+func evaluatePDBs(pdbs []policyv1beta1.PodDisruptionBudget) []string {
+	var pdbErrors []string
+
+	sort.Slice(pdbs, func(i, j int) bool {
+		if pdbs[i].Namespace != pdbs[j].Namespace {
+			return pdbs[i].Namespace < pdbs[j].Namespace
+		}
+		return pdbs[i].Name < pdbs[j].Name
+	})
+
+	for _, pdb := range pdbs {
+		expected := pdb.Status.ExpectedPods
+		if expected == 0 || pdb.Status.PodDisruptionsAllowed > 0 || pdb.Status.CurrentHealthy < expected {
+			continue
+		}
+
+		description := "PodDisruptionBudget " + pdb.Name + " in namespace " + pdb.Namespace
+		if reason := noDisruptionReason(pdb.Spec, int(expected)); len(reason) > 0 {
+			pdbErrors = append(pdbErrors, description+" "+reason+" and will block every node drain")
+			continue
+		}
+		pdbErrors = append(pdbErrors, description+" allows no disruptions with all "+strconv.Itoa(int(expected))+" expected pods healthy and will block node drains")
+	}
+	return pdbErrors
+}
+
+// noDisruptionReason describes why a budget spec allows no disruptions for
+// the expected number of pods, or returns an empty string when it allows
+// some
+func noDisruptionReason(spec policyv1beta1.PodDisruptionBudgetSpec, expected int) string {
+	if spec.MinAvailable != nil {
+		minAvailable, err := intstr.GetValueFromIntOrPercent(spec.MinAvailable, expected, true)
+		if err == nil && minAvailable >= expected {
+			return "requires minAvailable " + spec.MinAvailable.String() + " of its " + strconv.Itoa(expected) + " expected pods"
+		}
+	}
+	if spec.MaxUnavailable != nil {
+		maxUnavailable, err := intstr.GetValueFromIntOrPercent(spec.MaxUnavailable, expected, true)
+		if err == nil && maxUnavailable == 0 {
+			return "sets maxUnavailable " + spec.MaxUnavailable.String() + " for its " + strconv.Itoa(expected) + " expected pods"
+		}
+	}
+	return ""
+}
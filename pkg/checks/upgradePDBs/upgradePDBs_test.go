@@ -0,0 +1,64 @@
+package upgradePDBs
+
+import (
+	"strings"
+	"testing"
+
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestEvaluatePDBs(t *testing.T) {
+
+	makePDB := func(minAvailable *intstr.IntOrString, maxUnavailable *intstr.IntOrString, allowed int32, healthy int32, expected int32) policyv1beta1.PodDisruptionBudget {
+		return policyv1beta1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Spec: policyv1beta1.PodDisruptionBudgetSpec{
+				MinAvailable:   minAvailable,
+				MaxUnavailable: maxUnavailable,
+			},
+			Status: policyv1beta1.PodDisruptionBudgetStatus{
+				PodDisruptionsAllowed: allowed,
+				CurrentHealthy:        healthy,
+				ExpectedPods:          expected,
+			},
+		}
+	}
+	intValue := func(i int) *intstr.IntOrString {
+		v := intstr.FromInt(i)
+		return &v
+	}
+	percent := func(s string) *intstr.IntOrString {
+		v := intstr.FromString(s)
+		return &v
+	}
+
+	var tests = []struct {
+		description   string
+		pdb           policyv1beta1.PodDisruptionBudget
+		expectedError string
+	}{
+		{"allows a disruption", makePDB(intValue(1), nil, 1, 2, 2), ""},
+		{"unhealthy pods", makePDB(intValue(1), nil, 0, 1, 2), ""},
+		{"no expected pods", makePDB(intValue(1), nil, 0, 0, 0), ""},
+		{"minAvailable of every pod", makePDB(intValue(2), nil, 0, 2, 2), "PodDisruptionBudget coredns in namespace kube-system requires minAvailable 2 of its 2 expected pods and will block every node drain"},
+		{"minAvailable of 100%", makePDB(percent("100%"), nil, 0, 3, 3), "requires minAvailable 100% of its 3 expected pods"},
+		{"maxUnavailable of 0", makePDB(nil, intValue(0), 0, 3, 3), "sets maxUnavailable 0 for its 3 expected pods"},
+		{"no disruptions allowed", makePDB(intValue(1), nil, 0, 1, 1), "requires minAvailable 1 of its 1 expected pods"},
+		{"blocked without a spec reason", makePDB(percent("50%"), nil, 0, 2, 2), "allows no disruptions with all 2 expected pods healthy and will block node drains"},
+	}
+
+	for _, test := range tests {
+		pdbErrors := evaluatePDBs([]policyv1beta1.PodDisruptionBudget{test.pdb})
+		if len(test.expectedError) == 0 {
+			if len(pdbErrors) != 0 {
+				t.Fatal("Test", test.description, "expected no errors but got", pdbErrors)
+			}
+			continue
+		}
+		if len(pdbErrors) != 1 || !strings.Contains(pdbErrors[0], test.expectedError) {
+			t.Fatal("Test", test.description, "expected error", test.expectedError, "but got", pdbErrors)
+		}
+	}
+}
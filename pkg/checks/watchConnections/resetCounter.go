@@ -0,0 +1,122 @@
+package watchConnections
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// resets records every watch connection reset seen by reflectors in this
+// process.  Reflector metrics are global in client-go, so there is only one
+// counter.
+var resets = newResetCounter()
+
+var registerMetricsProvider sync.Once
+
+// resetCounter keeps the times of recent watch connection resets
+type resetCounter struct {
+	sync.Mutex
+	times []time.Time
+}
+
+// newResetCounter returns an empty resetCounter
+func newResetCounter() *resetCounter {
+	return &resetCounter{}
+}
+
+// record stores a reset at the supplied time
+func (rc *resetCounter) record(t time.Time) {
+	rc.Lock()
+	defer rc.Unlock()
+	rc.times = append(rc.times, t)
+}
+
+// countSince prunes resets older than the supplied time and returns the
+// number that remain
+func (rc *resetCounter) countSince(t time.Time) int {
+	rc.Lock()
+	defer rc.Unlock()
+	var kept []time.Time
+	for _, r := range rc.times {
+		if r.After(t) {
+			kept = append(kept, r)
+		}
+	}
+	rc.times = kept
+	return len(kept)
+}
+
+// resetMetric is a reflector counter that records a reset on every
+// increment after the first skip increments
+type resetMetric struct {
+	sync.Mutex
+	counter *resetCounter
+	skip    int
+}
+
+// Inc implements cache.CounterMetric
+func (rm *resetMetric) Inc() {
+	rm.Lock()
+	defer rm.Unlock()
+	if rm.skip > 0 {
+		rm.skip--
+		return
+	}
+	rm.counter.record(time.Now())
+}
+
+// noopMetric satisfies the reflector metrics that are not used
+type noopMetric struct{}
+
+func (noopMetric) Inc()            {}
+func (noopMetric) Dec()            {}
+func (noopMetric) Observe(float64) {}
+func (noopMetric) Set(float64)     {}
+
+// resetMetricsProvider is a cache.MetricsProvider that counts a reset each
+// time a reflector has a very short watch or has to relist after its
+// initial list
+type resetMetricsProvider struct {
+	counter *resetCounter
+}
+
+// NewListsMetric counts every list after the initial one as a reset
+func (p resetMetricsProvider) NewListsMetric(name string) cache.CounterMetric {
+	return &resetMetric{counter: p.counter, skip: 1}
+}
+
+// NewShortWatchesMetric counts every very short watch as a reset
+func (p resetMetricsProvider) NewShortWatchesMetric(name string) cache.CounterMetric {
+	return &resetMetric{counter: p.counter}
+}
+
+// NewListDurationMetric is not used
+func (p resetMetricsProvider) NewListDurationMetric(name string) cache.SummaryMetric {
+	return noopMetric{}
+}
+
+// NewItemsInListMetric is not used
+func (p resetMetricsProvider) NewItemsInListMetric(name string) cache.SummaryMetric {
+	return noopMetric{}
+}
+
+// NewWatchesMetric is not used
+func (p resetMetricsProvider) NewWatchesMetric(name string) cache.CounterMetric {
+	return noopMetric{}
+}
+
+// NewWatchDurationMetric is not used
+func (p resetMetricsProvider) NewWatchDurationMetric(name string) cache.SummaryMetric {
+	return noopMetric{}
+}
+
+// NewItemsInWatchMetric is not used
+func (p resetMetricsProvider) NewItemsInWatchMetric(name string) cache.SummaryMetric {
+	return noopMetric{}
+}
+
+// NewLastResourceVersionMetric is not used
+func (p resetMetricsProvider) NewLastResourceVersionMetric(name string) cache.GaugeMetric {
+	return noopMetric{}
+}
@@ -0,0 +1,217 @@
+// Package watchConnections implements a checker that watches for unstable
+// watch connections to the API server.  Unstable watch connections cause
+// informers to miss events.  The checker runs a reflector of its own and
+// counts how often its watch connection is reset.  API server watch cache
+// metrics are also collected for troubleshooting.
+package watchConnections // import "github.com/Comcast/kuberhealthy/pkg/checks/watchConnections"
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	"github.com/Comcast/kuberhealthy/pkg/promParser"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// Checker validates that watch connections to the API server are stable
+type Checker struct {
+	Errors         []string
+	ResetThreshold int
+	ResetWindow    time.Duration
+	client         *kubernetes.Clientset
+	stopChan       chan struct{}
+	interval       time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that reports an error when the watch connection
+// is reset more than resetThreshold times within resetWindow
+func New(resetThreshold int, resetWindow time.Duration) *Checker {
+	registerMetricsProvider.Do(func() {
+		cache.SetReflectorMetricsProvider(resetMetricsProvider{counter: resets})
+	})
+	return &Checker{
+		Errors:         []string{},
+		ResetThreshold: resetThreshold,
+		ResetWindow:    resetWindow,
+	}
+}
+
+// Name returns the name of this checker
+func (wcc *Checker) Name() string {
+	return "WatchConnectionsChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (wcc *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (wcc *Checker) Interval() time.Duration {
+	if wcc.interval > 0 {
+		return wcc.interval
+	}
+	return time.Minute * 1
+}
+
+// SetInterval overrides the interval at which this check runs
+func (wcc *Checker) SetInterval(d time.Duration) {
+	wcc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Pods in the
+// Kuberhealthy namespace are watched, and the API server metrics endpoint is
+// not a resource, so it can only be granted cluster wide.
+func (wcc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		namespace: {
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list", "watch"}},
+		},
+		"": {
+			{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (wcc *Checker) Timeout() time.Duration {
+	return time.Second * 30
+}
+
+// Shutdown stops the reflector used to observe watch connection resets
+func (wcc *Checker) Shutdown() error {
+	if wcc.stopChan != nil {
+		close(wcc.stopChan)
+		wcc.stopChan = nil
+	}
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (wcc *Checker) CurrentStatus() (bool, []string) {
+	if len(wcc.Errors) > 0 {
+		return false, wcc.Errors
+	}
+	return true, wcc.Errors
+}
+
+// clearErrors clears all errors
+func (wcc *Checker) clearErrors() {
+	wcc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (wcc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	wcc.client = client
+
+	// start watching on the first run so that resets can be counted
+	if wcc.stopChan == nil {
+		wcc.stopChan = make(chan struct{})
+		lw := cache.NewListWatchFromClient(client.CoreV1().RESTClient(), "pods", namespace, fields.Everything())
+		reflector := cache.NewNamedReflector(wcc.Name(), lw, &apiv1.Pod{}, cache.NewStore(cache.MetaNamespaceKeyFunc), 0)
+		go reflector.Run(wcc.stopChan)
+	}
+
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := wcc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(wcc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + wcc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(wcc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + wcc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + wcc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks counts the recent watch connection resets and sets an error if
+// they exceed the threshold.  API server watch metrics are logged.
+func (wcc *Checker) doChecks() error {
+
+	b, err := wcc.client.CoreV1().RESTClient().Get().AbsPath("/metrics").DoRaw()
+	if err != nil {
+		khlog.ForCheck(wcc.Name()).Warningln("Error fetching API server metrics:", err)
+	} else {
+		samples, err := promParser.Parse(bytes.NewReader(b))
+		if err != nil {
+			khlog.ForCheck(wcc.Name()).Warningln("Error parsing API server metrics:", err)
+		}
+		for _, line := range summarizeWatchMetrics(samples) {
+			khlog.ForCheck(wcc.Name()).Debugln(line)
+		}
+	}
+
+	resetCount := resets.countSince(time.Now().Add(-wcc.ResetWindow))
+	khlog.ForCheck(wcc.Name()).Debugln("Watch connection was reset", resetCount, "times in the last", wcc.ResetWindow)
+	if resetCount > wcc.ResetThreshold {
+		wcc.Errors = []string{"Watch connection to the API server was reset " + strconv.Itoa(resetCount) + " times in the last " + wcc.ResetWindow.String() +
+			" which exceeds the threshold of " + strconv.Itoa(wcc.ResetThreshold)}
+		return nil
+	}
+
+	wcc.clearErrors()
+	return nil
+}
+
+// summarizeWatchMetrics describes the watch cache capacity of each resource
+// and the average watch event size of each kind
+func summarizeWatchMetrics(samples []promParser.Sample) []string {
+	var lines []string
+
+	for _, s := range promParser.FilterPrefix(samples, "watch_cache_capacity") {
+		lines = append(lines, fmt.Sprintf("%s for %s is %v", s.Name, s.Labels["resource"], s.Value))
+	}
+
+	sums := make(map[string]float64)
+	for _, s := range promParser.Filter(samples, "apiserver_watch_events_sizes_sum") {
+		sums[watchEventKind(s.Labels)] = s.Value
+	}
+	var kinds []string
+	for _, s := range promParser.Filter(samples, "apiserver_watch_events_sizes_count") {
+		if s.Value == 0 {
+			continue
+		}
+		kind := watchEventKind(s.Labels)
+		kinds = append(kinds, kind)
+		sums[kind] = sums[kind] / s.Value
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		lines = append(lines, fmt.Sprintf("Average watch event size for %s is %.0f bytes", kind, sums[kind]))
+	}
+	return lines
+}
+
+// watchEventKind builds a group/version/kind string from metric labels
+func watchEventKind(labels map[string]string) string {
+	return strings.TrimPrefix(labels["group"]+"/"+labels["version"]+"/"+labels["kind"], "/")
+}
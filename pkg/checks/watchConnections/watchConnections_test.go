@@ -0,0 +1,79 @@
+package watchConnections
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/promParser"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestResetCounter(t *testing.T) {
+	rc := newResetCounter()
+	now := time.Now()
+	rc.record(now.Add(-time.Minute * 20))
+	rc.record(now.Add(-time.Minute * 5))
+	rc.record(now.Add(-time.Minute))
+
+	count := rc.countSince(now.Add(-time.Minute * 10))
+	if count != 2 {
+		t.Fatal("Expected 2 resets in the window but got", count)
+	}
+	if len(rc.times) != 2 {
+		t.Fatal("Expected old resets to be pruned")
+	}
+}
+
+// TestWatchDisconnections runs a reflector against a watch that disconnects
+// immediately and ensures the resets are counted
+func TestWatchDisconnections(t *testing.T) {
+	checker := New(5, time.Minute*10)
+
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &apiv1.PodList{ListMeta: metav1.ListMeta{ResourceVersion: "1"}}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			w := watch.NewFake()
+			w.Stop()
+			return w, nil
+		},
+	}
+
+	stopChan := make(chan struct{})
+	reflector := cache.NewNamedReflector(checker.Name(), lw, &apiv1.Pod{}, cache.NewStore(cache.MetaNamespaceKeyFunc), 0)
+	go reflector.Run(stopChan)
+	time.Sleep(time.Millisecond * 2500)
+	close(stopChan)
+
+	count := resets.countSince(time.Now().Add(-checker.ResetWindow))
+	if count < 2 {
+		t.Fatal("Expected watch disconnections to be counted as resets but got", count)
+	}
+	t.Log("Counted", count, "resets")
+}
+
+func TestSummarizeWatchMetrics(t *testing.T) {
+	metrics := `watch_cache_capacity{resource="pods"} 100
+apiserver_watch_events_sizes_sum{group="",kind="Pod",version="v1"} 9000
+apiserver_watch_events_sizes_count{group="",kind="Pod",version="v1"} 12
+apiserver_watch_events_sizes_sum{group="apps",kind="Deployment",version="v1"} 0
+apiserver_watch_events_sizes_count{group="apps",kind="Deployment",version="v1"} 0
+`
+	samples, err := promParser.Parse(strings.NewReader(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := summarizeWatchMetrics(samples)
+	if len(lines) != 2 {
+		t.Fatal("Expected 2 summary lines but got", lines)
+	}
+	if lines[1] != "Average watch event size for v1/Pod is 750 bytes" {
+		t.Fatal("Unexpected summary:", lines[1])
+	}
+}
@@ -0,0 +1,270 @@
+// Package webhookIdempotency implements a checker that ensures the mutating
+// admission webhook chain is idempotent.  Webhooks that mutate an object
+// differently when it is submitted again break retries and webhook
+// reinvocation.  A canary pod is submitted through the admission chain with
+// a dry run, the mutated result is submitted again as a retry would, and the
+// two results are compared.
+package webhookIdempotency // import "github.com/Comcast/kuberhealthy/pkg/checks/webhookIdempotency"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// canaryName is the name of the canary pod
+const canaryName = "kuberhealthy-webhook-idempotency-canary"
+
+// serverAssignedFields are metadata fields the API server sets on every
+// submission and that are not compared
+var serverAssignedFields = []string{"uid", "resourceVersion", "creationTimestamp", "selfLink", "generation"}
+
+// Checker validates that the mutating admission webhook chain is idempotent
+type Checker struct {
+	Errors []string
+	Image  string
+	client *kubernetes.Clientset
+	// dryRunCreate is replaced in tests to inject admission chain mutations
+	dryRunCreate func(object []byte) ([]byte, error)
+	interval     time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker
+func New() *Checker {
+	wic := &Checker{
+		Errors: []string{},
+		Image:  "gcr.io/google_containers/pause:0.8.0",
+	}
+	wic.dryRunCreate = wic.apiDryRunCreate
+	return wic
+}
+
+// Name returns the name of this checker
+func (wic *Checker) Name() string {
+	return "WebhookIdempotencyChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (wic *Checker) CheckNamespace() string {
+	return namespace
+}
+
+// Interval returns the interval at which this check runs
+func (wic *Checker) Interval() time.Duration {
+	if wic.interval > 0 {
+		return wic.interval
+	}
+	return time.Minute * 10
+}
+
+// SetInterval overrides the interval at which this check runs
+func (wic *Checker) SetInterval(d time.Duration) {
+	wic.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  A dry run create of
+// the canary pod is authorized like a real one.
+func (wic *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		namespace: {
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"create"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (wic *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (wic *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (wic *Checker) CurrentStatus() (bool, []string) {
+	if len(wic.Errors) > 0 {
+		return false, wic.Errors
+	}
+	return true, wic.Errors
+}
+
+// clearErrors clears all errors
+func (wic *Checker) clearErrors() {
+	wic.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (wic *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	wic.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := wic.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(wic.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + wic.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(wic.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + wic.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + wic.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks submits the canary pod through the admission chain twice and sets
+// an error for every field that the second submission mutated differently
+func (wic *Checker) doChecks() error {
+
+	canary, err := json.Marshal(wic.canaryPod())
+	if err != nil {
+		return err
+	}
+
+	first, err := wic.admit(canary)
+	if err != nil {
+		return errors.New("Error submitting canary pod: " + err.Error())
+	}
+	retry, err := json.Marshal(first)
+	if err != nil {
+		return err
+	}
+	second, err := wic.admit(retry)
+	if err != nil {
+		return errors.New("Error resubmitting canary pod: " + err.Error())
+	}
+
+	var idempotencyErrors []string
+	for _, field := range diffFields(first, second, "") {
+		idempotencyErrors = append(idempotencyErrors, "Mutating admission webhooks are not idempotent: field "+field+" of the canary pod changed when it was resubmitted")
+	}
+
+	if len(idempotencyErrors) > 0 {
+		for _, e := range idempotencyErrors {
+			khlog.ForCheck(wic.Name()).Warningln(e)
+		}
+		wic.Errors = idempotencyErrors
+		return nil
+	}
+
+	wic.clearErrors()
+	return nil
+}
+
+// admit submits an object through the admission chain with a dry run and
+// returns the mutated object without its server assigned metadata
+func (wic *Checker) admit(object []byte) (map[string]interface{}, error) {
+	b, err := wic.dryRunCreate(object)
+	if err != nil {
+		return nil, err
+	}
+	var mutated map[string]interface{}
+	err = json.Unmarshal(b, &mutated)
+	if err != nil {
+		return nil, errors.New("Error decoding admitted object: " + err.Error())
+	}
+	if metadata, ok := mutated["metadata"].(map[string]interface{}); ok {
+		for _, field := range serverAssignedFields {
+			delete(metadata, field)
+		}
+	}
+	return mutated, nil
+}
+
+// apiDryRunCreate creates a pod in the Kuberhealthy namespace with a dry run
+// so that it passes through admission without being persisted
+func (wic *Checker) apiDryRunCreate(object []byte) ([]byte, error) {
+	return wic.client.CoreV1().RESTClient().Post().
+		Namespace(namespace).
+		Resource("pods").
+		Param("dryRun", "All").
+		SetHeader("Content-Type", "application/json").
+		Body(object).
+		DoRaw()
+}
+
+// canaryPod returns the canary pod submitted through the admission chain
+func (wic *Checker) canaryPod() *apiv1.Pod {
+	return &apiv1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      canaryName,
+			Namespace: namespace,
+			Labels:    map[string]string{"app": "kuberhealthy", "source": "kuberhealthy"},
+		},
+		Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{
+				{Name: "canary", Image: wic.Image},
+			},
+		},
+	}
+}
+
+// diffFields returns the path of every field that differs between two
+// decoded JSON objects.  Lists of the same length are compared item by item.
+func diffFields(a interface{}, b interface{}, path string) []string {
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+
+	aMap, aIsMap := a.(map[string]interface{})
+	bMap, bIsMap := b.(map[string]interface{})
+	if aIsMap && bIsMap {
+		keys := make(map[string]bool)
+		for k := range aMap {
+			keys[k] = true
+		}
+		for k := range bMap {
+			keys[k] = true
+		}
+		var sorted []string
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		var fields []string
+		for _, k := range sorted {
+			fields = append(fields, diffFields(aMap[k], bMap[k], path+"."+k)...)
+		}
+		return fields
+	}
+
+	aList, aIsList := a.([]interface{})
+	bList, bIsList := b.([]interface{})
+	if aIsList && bIsList && len(aList) == len(bList) {
+		var fields []string
+		for i := range aList {
+			fields = append(fields, diffFields(aList[i], bList[i], path+"["+strconv.Itoa(i)+"]")...)
+		}
+		return fields
+	}
+
+	return []string{path}
+}
@@ -0,0 +1,91 @@
+package webhookIdempotency
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// admissionChain returns a dry run create that applies a mutation to the
+// submitted pod and assigns server metadata like the API server does
+func admissionChain(t *testing.T, mutate func(pod *apiv1.Pod)) func(object []byte) ([]byte, error) {
+	var submissions int
+	return func(object []byte) ([]byte, error) {
+		submissions++
+		var pod apiv1.Pod
+		err := json.Unmarshal(object, &pod)
+		if err != nil {
+			t.Fatal("Unable to decode submitted pod:", err)
+		}
+		mutate(&pod)
+		pod.ResourceVersion = strconv.Itoa(submissions)
+		pod.UID = types.UID("uid-" + strconv.Itoa(submissions))
+		return json.Marshal(pod)
+	}
+}
+
+func TestDoChecks(t *testing.T) {
+	var tests = []struct {
+		description string
+		mutate      func(pod *apiv1.Pod)
+		expected    int
+	}{
+		{"no webhooks", func(pod *apiv1.Pod) {}, 0},
+		{"idempotent label", func(pod *apiv1.Pod) {
+			pod.Labels["injected"] = "true"
+		}, 0},
+		{"idempotent sidecar", func(pod *apiv1.Pod) {
+			for _, c := range pod.Spec.Containers {
+				if c.Name == "sidecar" {
+					return
+				}
+			}
+			pod.Spec.Containers = append(pod.Spec.Containers, apiv1.Container{Name: "sidecar", Image: "envoy"})
+		}, 0},
+		{"sidecar injected on every submission", func(pod *apiv1.Pod) {
+			pod.Spec.Containers = append(pod.Spec.Containers, apiv1.Container{Name: "sidecar-" + strconv.Itoa(len(pod.Spec.Containers)), Image: "envoy"})
+		}, 1},
+		{"annotation counter", func(pod *apiv1.Pod) {
+			if pod.Annotations == nil {
+				pod.Annotations = map[string]string{}
+			}
+			pod.Annotations["admitted"] += "x"
+			pod.Labels["injected"] = "true"
+		}, 1},
+	}
+
+	for _, test := range tests {
+		checker := New()
+		checker.dryRunCreate = admissionChain(t, test.mutate)
+		err := checker.doChecks()
+		if err != nil {
+			t.Fatal("Test", test.description, "returned an error:", err)
+		}
+		if len(checker.Errors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", checker.Errors)
+		}
+		t.Log(test.description, checker.Errors)
+	}
+}
+
+func TestDiffFields(t *testing.T) {
+	a := map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "web"}},
+		"spec":     map[string]interface{}{"containers": []interface{}{map[string]interface{}{"image": "nginx:1"}}},
+	}
+	b := map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "web", "extra": "1"}},
+		"spec":     map[string]interface{}{"containers": []interface{}{map[string]interface{}{"image": "nginx:2"}}},
+	}
+
+	fields := diffFields(a, b, "")
+	if len(fields) != 2 || fields[0] != ".metadata.labels.extra" || fields[1] != ".spec.containers[0].image" {
+		t.Fatal("Unexpected differing fields:", fields)
+	}
+	if fields := diffFields(a, a, ""); len(fields) != 0 {
+		t.Fatal("Expected no differing fields but got", fields)
+	}
+}
@@ -0,0 +1,259 @@
+// Package webhookNamespaceScope implements a checker that ensures mutating
+// admission webhooks can not block objects created in the kuberhealthy
+// namespace.
+package webhookNamespaceScope // import "github.com/Comcast/kuberhealthy/pkg/checks/webhookNamespaceScope"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	log "github.com/sirupsen/logrus"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespace = os.Getenv("POD_NAMESPACE")
+
+// interceptedResources are the resources kuberhealthy creates or updates
+// while running checks.  Webhook rules for any other resource are ignored.
+var interceptedResources = []string{"*", "pods", "daemonsets", "khstates"}
+
+// objectLabelSets are the label sets found on objects kuberhealthy creates
+var objectLabelSets = []labels.Set{
+	{"app": "kuberhealthy"},
+	{"source": "kuberhealthy"},
+}
+
+// webhookConfigurationList is a list of mutating webhook configurations as
+// returned by the API server.  These are decoded by hand so that the
+// objectSelector field is available.
+type webhookConfigurationList struct {
+	Items []webhookConfiguration `json:"items"`
+}
+
+// webhookConfiguration is a single mutating webhook configuration
+type webhookConfiguration struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Webhooks          []webhook `json:"webhooks,omitempty"`
+}
+
+// webhook is a single webhook with its object selector
+type webhook struct {
+	admissionv1beta1.Webhook
+	ObjectSelector *metav1.LabelSelector `json:"objectSelector,omitempty"`
+}
+
+// Checker validates that mutating webhooks do not intercept the kuberhealthy namespace
+type Checker struct {
+	Errors    []string
+	Namespace string
+	client    *kubernetes.Clientset
+	interval  time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker
+func New() *Checker {
+	return &Checker{
+		Errors:    []string{},
+		Namespace: namespace,
+	}
+}
+
+// Name returns the name of this checker
+func (wc *Checker) Name() string {
+	return "WebhookNamespaceScopeChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (wc *Checker) CheckNamespace() string {
+	return wc.Namespace
+}
+
+// Interval returns the interval at which this check runs
+func (wc *Checker) Interval() time.Duration {
+	if wc.interval > 0 {
+		return wc.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (wc *Checker) SetInterval(d time.Duration) {
+	wc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  Its namespace and the
+// mutating webhook configurations are both cluster scoped.
+func (wc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"namespaces"}, ResourceNames: []string{wc.Namespace}, Verbs: []string{"get"}},
+			{APIGroups: []string{"admissionregistration.k8s.io"}, Resources: []string{"mutatingwebhookconfigurations"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (wc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (wc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (wc *Checker) CurrentStatus() (bool, []string) {
+	if len(wc.Errors) > 0 {
+		return false, wc.Errors
+	}
+	return true, wc.Errors
+}
+
+// clearErrors clears all errors
+func (wc *Checker) clearErrors() {
+	wc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (wc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	wc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := wc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(wc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + wc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(wc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + wc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + wc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks fetches the kuberhealthy namespace and all mutating webhook
+// configurations, then logs a warning for every webhook that would block
+// kuberhealthy objects when it fails.
+func (wc *Checker) doChecks() error {
+
+	ns, err := wc.client.CoreV1().Namespaces().Get(wc.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	b, err := wc.client.AdmissionregistrationV1beta1().RESTClient().Get().Resource("mutatingwebhookconfigurations").DoRaw()
+	if err != nil {
+		return err
+	}
+	var configs webhookConfigurationList
+	err = json.Unmarshal(b, &configs)
+	if err != nil {
+		return errors.New("Error decoding mutating webhook configurations: " + err.Error())
+	}
+
+	// intercepting webhooks are only a risk while their backend is down, so
+	// they are logged as warnings rather than failing the check
+	for _, w := range findInterceptingWebhooks(configs.Items, ns.Name, ns.Labels, khlog.ForCheck(wc.Name())) {
+		khlog.ForCheck(wc.Name()).Warningln(w)
+	}
+
+	wc.clearErrors()
+	return nil
+}
+
+// findInterceptingWebhooks returns a warning for every webhook with a
+// failure policy of Fail that matches objects in the specified namespace
+func findInterceptingWebhooks(configs []webhookConfiguration, nsName string, nsLabels map[string]string, checkLog *log.Entry) []string {
+	var warnings []string
+	for _, config := range configs {
+		for _, wh := range config.Webhooks {
+			if wh.FailurePolicy == nil || *wh.FailurePolicy != admissionv1beta1.Fail {
+				continue
+			}
+			if !selectorMatches(wh.NamespaceSelector, []labels.Set{nsLabels}, checkLog) {
+				continue
+			}
+			if !selectorMatches(wh.ObjectSelector, objectLabelSets, checkLog) {
+				continue
+			}
+			for _, rule := range wh.Rules {
+				if !ruleMatchesResources(rule) {
+					continue
+				}
+				warnings = append(warnings, "Mutating webhook "+wh.Name+" in configuration "+config.Name+
+					" has failurePolicy Fail and intercepts namespace "+nsName+" with rule: "+describeRule(rule))
+			}
+		}
+	}
+	return warnings
+}
+
+// selectorMatches determines if a label selector matches any of the supplied
+// label sets.  A nil selector matches everything.
+func selectorMatches(selector *metav1.LabelSelector, labelSets []labels.Set, checkLog *log.Entry) bool {
+	if selector == nil {
+		return true
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		// an invalid selector is rejected by the api server, but if we get
+		// one assume it can match so that it is reported
+		checkLog.Warningln("Unable to parse webhook label selector:", err)
+		return true
+	}
+	for _, set := range labelSets {
+		if s.Matches(set) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleMatchesResources determines if a webhook rule covers any resource
+// kuberhealthy creates
+func ruleMatchesResources(rule admissionv1beta1.RuleWithOperations) bool {
+	for _, r := range rule.Resources {
+		// a rule on a subresource such as pods/status matches its resource,
+		// because a failing webhook on the status of kuberhealthy's pods
+		// breaks its checks as well
+		resource := strings.Split(r, "/")[0]
+		for _, ir := range interceptedResources {
+			if resource == ir {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// describeRule formats a webhook rule for use in warnings
+func describeRule(rule admissionv1beta1.RuleWithOperations) string {
+	var operations []string
+	for _, o := range rule.Operations {
+		operations = append(operations, string(o))
+	}
+	return strings.Join(operations, ",") + " on " + strings.Join(rule.Resources, ",")
+}
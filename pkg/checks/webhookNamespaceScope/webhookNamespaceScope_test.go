@@ -0,0 +1,77 @@
+package webhookNamespaceScope
+
+import (
+	"testing"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makeWebhook(name string, policy admissionv1beta1.FailurePolicyType, nsSelector *metav1.LabelSelector, resources ...string) webhook {
+	wh := webhook{}
+	wh.Name = name
+	wh.FailurePolicy = &policy
+	wh.NamespaceSelector = nsSelector
+	wh.Rules = []admissionv1beta1.RuleWithOperations{
+		{
+			Operations: []admissionv1beta1.OperationType{admissionv1beta1.Create},
+			Rule:       admissionv1beta1.Rule{Resources: resources},
+		},
+	}
+	return wh
+}
+
+func TestFindInterceptingWebhooks(t *testing.T) {
+
+	nsLabels := map[string]string{"name": "kuberhealthy"}
+
+	var tests = []struct {
+		description string
+		webhook     webhook
+		expected    int
+	}{
+		{"nil namespace selector with fail policy", makeWebhook("all", admissionv1beta1.Fail, nil, "pods"), 1},
+		{"nil namespace selector with ignore policy", makeWebhook("ignore", admissionv1beta1.Ignore, nil, "pods"), 0},
+		{"selector matching kuberhealthy", makeWebhook("match", admissionv1beta1.Fail, &metav1.LabelSelector{
+			MatchLabels: map[string]string{"name": "kuberhealthy"},
+		}, "*"), 1},
+		{"selector excluding kuberhealthy", makeWebhook("exclude", admissionv1beta1.Fail, &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "name", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"kuberhealthy"}},
+			},
+		}, "pods"), 0},
+		{"selector for other namespace", makeWebhook("other", admissionv1beta1.Fail, &metav1.LabelSelector{
+			MatchLabels: map[string]string{"name": "istio-system"},
+		}, "pods"), 0},
+		{"rule for unrelated resource", makeWebhook("services", admissionv1beta1.Fail, nil, "services"), 0},
+		{"rule for pod subresource", makeWebhook("podstatus", admissionv1beta1.Fail, nil, "pods/status"), 1},
+	}
+
+	for _, test := range tests {
+		config := webhookConfiguration{Webhooks: []webhook{test.webhook}}
+		config.Name = "config"
+		found := findInterceptingWebhooks([]webhookConfiguration{config}, "kuberhealthy", nsLabels, khlog.ForCheck("test"))
+		if len(found) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", len(found), found)
+		}
+		t.Log(test.description, found)
+	}
+}
+
+func TestObjectSelector(t *testing.T) {
+	wh := makeWebhook("objects", admissionv1beta1.Fail, nil, "pods")
+	wh.ObjectSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	config := webhookConfiguration{Webhooks: []webhook{wh}}
+	found := findInterceptingWebhooks([]webhookConfiguration{config}, "kuberhealthy", map[string]string{}, khlog.ForCheck("test"))
+	if len(found) != 0 {
+		t.Fatal("Webhook with object selector not matching kuberhealthy objects was reported:", found)
+	}
+
+	wh.ObjectSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"source": "kuberhealthy"}}
+	config = webhookConfiguration{Webhooks: []webhook{wh}}
+	found = findInterceptingWebhooks([]webhookConfiguration{config}, "kuberhealthy", map[string]string{}, khlog.ForCheck("test"))
+	if len(found) != 1 {
+		t.Fatal("Webhook with object selector matching kuberhealthy objects was not reported")
+	}
+}
@@ -0,0 +1,183 @@
+// Package zoneLabels implements a checker that ensures every node has the
+// topology labels needed for zone-aware scheduling and that the zone names
+// follow a consistent pattern.
+package zoneLabels // import "github.com/Comcast/kuberhealthy/pkg/checks/zoneLabels"
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/pkg/khlog"
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// topology labels and the deprecated labels they replace
+const (
+	zoneLabel             = "topology.kubernetes.io/zone"
+	regionLabel           = "topology.kubernetes.io/region"
+	deprecatedZoneLabel   = "failure-domain.beta.kubernetes.io/zone"
+	deprecatedRegionLabel = "failure-domain.beta.kubernetes.io/region"
+)
+
+// Checker validates the topology labels of all nodes
+type Checker struct {
+	Errors      []string
+	ZonePattern *regexp.Regexp
+	client      *kubernetes.Clientset
+	interval    time.Duration // overrides the default interval when set
+}
+
+// New returns a new Checker that requires zone names to fully match
+// zonePattern
+func New(zonePattern string) (*Checker, error) {
+	pattern, err := regexp.Compile("^(?:" + zonePattern + ")$")
+	if err != nil {
+		return nil, errors.New("Invalid zone pattern " + zonePattern + ": " + err.Error())
+	}
+	return &Checker{
+		Errors:      []string{},
+		ZonePattern: pattern,
+	}, nil
+}
+
+// Name returns the name of this checker
+func (zlc *Checker) Name() string {
+	return "ZoneLabelsChecker"
+}
+
+// CheckNamespace returns the namespace of this checker
+func (zlc *Checker) CheckNamespace() string {
+	return ""
+}
+
+// Interval returns the interval at which this check runs
+func (zlc *Checker) Interval() time.Duration {
+	if zlc.interval > 0 {
+		return zlc.interval
+	}
+	return time.Minute * 5
+}
+
+// SetInterval overrides the interval at which this check runs
+func (zlc *Checker) SetInterval(d time.Duration) {
+	zlc.interval = d
+}
+
+// RBACRules returns the RBAC rules this check needs.  The zone labels are
+// read from the cluster scoped nodes.
+func (zlc *Checker) RBACRules() map[string][]rbacv1.PolicyRule {
+	return map[string][]rbacv1.PolicyRule{
+		"": {
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}},
+		},
+	}
+}
+
+// Timeout returns the maximum run time for this check before it times out
+func (zlc *Checker) Timeout() time.Duration {
+	return time.Minute * 1
+}
+
+// Shutdown is implemented to satisfy KuberhealthyCheck but is not used
+func (zlc *Checker) Shutdown() error {
+	return nil
+}
+
+// CurrentStatus returns the status of the check as of right now
+func (zlc *Checker) CurrentStatus() (bool, []string) {
+	if len(zlc.Errors) > 0 {
+		return false, zlc.Errors
+	}
+	return true, zlc.Errors
+}
+
+// clearErrors clears all errors
+func (zlc *Checker) clearErrors() {
+	zlc.Errors = []string{}
+}
+
+// Run implements the entrypoint for check execution
+func (zlc *Checker) Run(ctx context.Context, client *kubernetes.Clientset) error {
+	doneChan := make(chan error)
+
+	zlc.client = client
+	// run the check in a goroutine and notify the doneChan when completed
+	go func(doneChan chan error) {
+		err := zlc.doChecks()
+		doneChan <- err
+	}(doneChan)
+
+	// wait for either a timeout or job completion
+	select {
+	case <-time.After(zlc.Interval()):
+		// The check has timed out because its time to run again
+		return errors.New("Failed to complete checks for " + zlc.Name() + " in time!  Next run came up but check was still running.")
+	case <-time.After(zlc.Timeout()):
+		// The check has timed out after its specified timeout period
+		return errors.New("Failed to complete checks for " + zlc.Name() + " in time!  Timeout was reached.")
+	case <-ctx.Done():
+		// The check was cancelled, such as when Kuberhealthy is shutting down
+		return errors.New("Failed to complete checks for " + zlc.Name() + ".  The check was cancelled.")
+	case err := <-doneChan:
+		return err
+	}
+}
+
+// doChecks lists all nodes and sets an error for each node with missing or
+// inconsistent topology labels
+func (zlc *Checker) doChecks() error {
+
+	nodes, err := zlc.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	labelErrors := evaluateNodes(nodes.Items, zlc.ZonePattern)
+	if len(labelErrors) > 0 {
+		for _, e := range labelErrors {
+			khlog.ForCheck(zlc.Name()).Warningln(e)
+		}
+		zlc.Errors = labelErrors
+		return nil
+	}
+
+	zlc.clearErrors()
+	return nil
+}
+
+// evaluateNodes returns an error for each node missing a zone or region
+// label, or with a zone that does not match the pattern
+func evaluateNodes(nodes []apiv1.Node, zonePattern *regexp.Regexp) []string {
+	var labelErrors []string
+	for _, n := range nodes {
+		zone := labelValue(n.Labels, zoneLabel, deprecatedZoneLabel)
+		region := labelValue(n.Labels, regionLabel, deprecatedRegionLabel)
+
+		if len(zone) == 0 {
+			labelErrors = append(labelErrors, "Node "+n.Name+" is missing the "+zoneLabel+" label")
+		} else if !zonePattern.MatchString(zone) {
+			labelErrors = append(labelErrors, "Node "+n.Name+" has zone "+zone+" which does not match the pattern "+zonePattern.String())
+		}
+		if len(region) == 0 {
+			labelErrors = append(labelErrors, "Node "+n.Name+" is missing the "+regionLabel+" label")
+		}
+	}
+	return labelErrors
+}
+
+// labelValue returns the value of a label, falling back to its deprecated
+// equivalent
+func labelValue(labels map[string]string, label string, deprecatedLabel string) string {
+	if v := labels[label]; len(v) > 0 {
+		return v
+	}
+	return labels[deprecatedLabel]
+}
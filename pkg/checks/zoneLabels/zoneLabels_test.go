@@ -0,0 +1,43 @@
+package zoneLabels
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateNodes(t *testing.T) {
+	checker, err := New("[a-z]+-[a-z]+-[0-9][a-z]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		description string
+		labels      map[string]string
+		expected    int
+	}{
+		{"topology labels", map[string]string{zoneLabel: "us-east-1a", regionLabel: "us-east-1"}, 0},
+		{"deprecated labels", map[string]string{deprecatedZoneLabel: "us-west-2b", deprecatedRegionLabel: "us-west-2"}, 0},
+		{"mixed labels", map[string]string{zoneLabel: "eu-central-1c", deprecatedRegionLabel: "eu-central-1"}, 0},
+		{"missing region", map[string]string{zoneLabel: "us-east-1a"}, 1},
+		{"missing everything", map[string]string{"kubernetes.io/hostname": "node"}, 2},
+		{"inconsistent zone", map[string]string{zoneLabel: "zone-a", regionLabel: "us-east-1"}, 1},
+		{"zone with suffix", map[string]string{zoneLabel: "us-east-1a-extra", regionLabel: "us-east-1"}, 1},
+	}
+
+	for _, test := range tests {
+		node := apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node", Labels: test.labels}}
+		labelErrors := evaluateNodes([]apiv1.Node{node}, checker.ZonePattern)
+		if len(labelErrors) != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "errors but got", labelErrors)
+		}
+		t.Log(test.description, labelErrors)
+	}
+
+	_, err = New("[a-z")
+	if err == nil {
+		t.Fatal("Expected an error for an invalid zone pattern")
+	}
+}
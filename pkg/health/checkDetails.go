@@ -19,7 +19,11 @@ type CheckDetails struct {
 	Errors           []string
 	Namespace        string
 	LastRun          time.Time // the time the check last was last run
+	NextRun          time.Time // the time the check runs next
 	AuthoritativePod string    // the pod that last ran the check
+	RunInterval      string    // the interval at which the check runs
+	Skipped          bool      // the check was not run because a check it depends on is failing
+	SkipReason       string    // the dependency that caused the check to be skipped
 }
 
 // NewCheckDetails creates a new CheckDetails struct
@@ -0,0 +1,93 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// CheckResult is the result of a single run of a check
+type CheckResult struct {
+	Check           string
+	Timestamp       time.Time // the time the run completed
+	DurationSeconds float64
+	OK              bool
+	Errors          []string
+}
+
+// History keeps the most recent results of each check in memory.  Results
+// are lost when Kuberhealthy restarts.
+type History struct {
+	sync.RWMutex
+	depth   int
+	results map[string][]CheckResult // a ring buffer of results for each check
+	next    map[string]int           // the index in each ring buffer written next
+}
+
+// NewHistory creates a History that keeps the last depth results of each
+// check
+func NewHistory(depth int) *History {
+	if depth < 1 {
+		depth = 1
+	}
+	return &History{
+		depth:   depth,
+		results: make(map[string][]CheckResult),
+		next:    make(map[string]int),
+	}
+}
+
+// Add records a check result, replacing the oldest result of the check when
+// its history is full
+func (h *History) Add(r CheckResult) {
+	h.Lock()
+	defer h.Unlock()
+
+	if len(h.results[r.Check]) < h.depth {
+		h.results[r.Check] = append(h.results[r.Check], r)
+		return
+	}
+	h.results[r.Check][h.next[r.Check]] = r
+	h.next[r.Check] = (h.next[r.Check] + 1) % h.depth
+}
+
+// Checks returns the names of all checks with results in alphabetical order
+func (h *History) Checks() []string {
+	h.RLock()
+	defer h.RUnlock()
+
+	var checks []string
+	for c := range h.results {
+		checks = append(checks, c)
+	}
+	sort.Strings(checks)
+	return checks
+}
+
+// Results returns the results of a check from newest to oldest, skipping
+// the newest offset results and returning at most limit results.  A limit
+// below one returns all remaining results.
+func (h *History) Results(check string, offset int, limit int) []CheckResult {
+	h.RLock()
+	defer h.RUnlock()
+
+	buf := h.results[check]
+	results := []CheckResult{}
+	for i := offset; i < len(buf) && (limit < 1 || len(results) < limit); i++ {
+		// the newest result is the one before the next write
+		idx := (h.next[check] - 1 - i + 2*len(buf)) % len(buf)
+		results = append(results, buf[idx])
+	}
+	return results
+}
@@ -0,0 +1,50 @@
+package health
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	h := NewHistory(3)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		h.Add(CheckResult{Check: "DaemonSetChecker", Timestamp: start.Add(time.Duration(i) * time.Minute), OK: i%2 == 0, Errors: []string{strconv.Itoa(i)}})
+	}
+	h.Add(CheckResult{Check: "ComponentStatusChecker", Timestamp: start, OK: true})
+
+	checks := h.Checks()
+	if len(checks) != 2 || checks[0] != "ComponentStatusChecker" || checks[1] != "DaemonSetChecker" {
+		t.Fatal("Expected both checks in alphabetical order but got", checks)
+	}
+
+	var tests = []struct {
+		description string
+		offset      int
+		limit       int
+		expected    []string
+	}{
+		{"all results", 0, 0, []string{"4", "3", "2"}},
+		{"first page", 0, 2, []string{"4", "3"}},
+		{"second page", 2, 2, []string{"2"}},
+		{"past the end", 5, 2, []string{}},
+	}
+
+	for _, test := range tests {
+		results := h.Results("DaemonSetChecker", test.offset, test.limit)
+		if len(results) != len(test.expected) {
+			t.Fatal("Test", test.description, "expected", test.expected, "but got", results)
+		}
+		for i, r := range results {
+			if r.Errors[0] != test.expected[i] {
+				t.Fatal("Test", test.description, "expected", test.expected, "but got", results)
+			}
+		}
+		t.Log(test.description, results)
+	}
+
+	if len(h.Results("UnknownChecker", 0, 0)) != 0 {
+		t.Fatal("Expected no results for an unknown check")
+	}
+}
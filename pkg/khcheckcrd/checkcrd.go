@@ -0,0 +1,63 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package khcheckcrd
+
+import (
+	"encoding/json"
+
+	"github.com/sirupsen/logrus"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// KuberhealthyCheck is an external check that Kuberhealthy runs as a Job.
+// The name of the resource is used as the name of the check.
+type KuberhealthyCheck struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              CheckConfig `json:"spec"`
+}
+
+// CheckConfig configures how often an external check runs and the Job that
+// runs it
+type CheckConfig struct {
+	RunInterval string                       `json:"runInterval"`        // a duration such as 10m
+	Schedule    string                       `json:"schedule,omitempty"` // a cron expression run on instead of the interval, such as 0 9-17 * * 1-5
+	JobTemplate batchv1beta1.JobTemplateSpec `json:"jobTemplate"`
+}
+
+// String satisfies the stringer interface for cleaner output when printing
+func (h KuberhealthyCheck) String() string {
+	b, err := json.MarshalIndent(&h, "", "\t")
+	if err != nil {
+		logrus.Errorln("Failed to marshal KuberhealthyCheck in a nice format:", err)
+	}
+	return string(b)
+}
+
+// DeepCopyInto copies all properties of this object into another object of the
+// same type that is provided as a pointer.
+func (h KuberhealthyCheck) DeepCopyInto(out *KuberhealthyCheck) {
+	out.TypeMeta = h.TypeMeta
+	h.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec.RunInterval = h.Spec.RunInterval
+	out.Spec.Schedule = h.Spec.Schedule
+	h.Spec.JobTemplate.DeepCopyInto(&out.Spec.JobTemplate)
+}
+
+// DeepCopyObject returns a generically typed copy of an object
+func (h KuberhealthyCheck) DeepCopyObject() runtime.Object {
+	out := KuberhealthyCheck{}
+	h.DeepCopyInto(&out)
+	return &out
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package khcheckcrd
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+type KuberhealthyCheckClient struct {
+	restClient rest.Interface
+	ns         string
+}
+
+func (c *KuberhealthyCheckClient) Get(opts metav1.GetOptions, resource string, name string) (*KuberhealthyCheck, error) {
+	result := KuberhealthyCheck{}
+	err := c.restClient.
+		Get().
+		Namespace(c.ns).
+		Resource(resource).
+		Name(name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(&result)
+	return &result, err
+}
+
+func (c *KuberhealthyCheckClient) List(opts metav1.ListOptions, resource string) (*KuberhealthyCheckList, error) {
+	result := KuberhealthyCheckList{}
+	err := c.restClient.
+		Get().
+		Namespace(c.ns).
+		Resource(resource).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(&result)
+	return &result, err
+}
+
+func (c *KuberhealthyCheckClient) Watch(opts metav1.ListOptions, resource string) (watch.Interface, error) {
+	opts.Watch = true
+	return c.restClient.
+		Get().
+		Namespace(c.ns).
+		Resource(resource).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Watch()
+}
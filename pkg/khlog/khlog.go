@@ -0,0 +1,28 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package khlog holds the logging helpers shared by Kuberhealthy and its
+// checks, so that every log made while running a check carries the name of
+// the check in the same field and logs can be filtered by check.
+package khlog // import "github.com/Comcast/kuberhealthy/pkg/khlog"
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// CheckField is the log field that holds the name of the check a log was
+// made by
+const CheckField = "check"
+
+// ForCheck returns a log entry for logs made while running the named check
+func ForCheck(checkName string) *log.Entry {
+	return log.WithField(CheckField, checkName)
+}
@@ -0,0 +1,35 @@
+package khlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestForCheck(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.StandardLogger()
+	out, formatter := logger.Out, logger.Formatter
+	defer func() {
+		logger.SetOutput(out)
+		logger.SetFormatter(formatter)
+	}()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&log.JSONFormatter{})
+
+	ForCheck("dns-status").Warningln("lookup failed")
+
+	entry := make(map[string]interface{})
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	if err != nil {
+		t.Fatal("Unable to decode log entry:", err)
+	}
+	if entry[CheckField] != "dns-status" {
+		t.Fatal("Expected check field of dns-status but got", entry[CheckField])
+	}
+	if entry["msg"] != "lookup failed" {
+		t.Fatal("Expected message of lookup failed but got", entry["msg"])
+	}
+}
@@ -42,6 +42,13 @@ func Client(GroupName string, GroupVersion string, kubeConfig string) (*Kuberhea
 		return &KuberhealthyStateClient{}, err
 	}
 
+	return ClientForConfig(c, GroupName, GroupVersion, namespace)
+}
+
+// ClientForConfig creates a rest client to use for interacting with CRDs in
+// a namespace from a rest config, such as one pointing at a test server
+func ClientForConfig(c *rest.Config, GroupName string, GroupVersion string, ns string) (*KuberhealthyStateClient, error) {
+
 	ConfigureScheme(GroupName, GroupVersion)
 
 	config := *c
@@ -51,5 +58,5 @@ func Client(GroupName string, GroupVersion string, kubeConfig string) (*Kuberhea
 	config.UserAgent = rest.DefaultKubernetesUserAgent()
 
 	client, err := rest.RESTClientFor(&config)
-	return &KuberhealthyStateClient{restClient: client, ns: namespace}, err
+	return &KuberhealthyStateClient{restClient: client, ns: ns}, err
 }
@@ -0,0 +1,232 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	log "github.com/sirupsen/logrus"
+)
+
+// DatadogFlushInterval is how often metrics pushed to a DatadogClient are
+// submitted to Datadog in a single batch
+var DatadogFlushInterval = time.Second * 15
+
+// datadogPrefix is prepended to the name of every metric submitted
+const datadogPrefix = "kuberhealthy."
+
+// datadogServiceCheck is the name of the service check submitted for every
+// check status
+const datadogServiceCheck = "kuberhealthy.check"
+
+// datadogStatusMetric is the metric holding a check status, which is also
+// submitted as a service check
+const datadogStatusMetric = "kuberhealthy.check.status"
+
+// ignoredDatadogTags are tags that are not submitted because their values
+// are unbounded.  Errors are sent as the service check message instead.
+var ignoredDatadogTags = map[string]bool{
+	"Errors": true,
+}
+
+// DatadogClient batches metrics pushed to it and submits them to the
+// Datadog API as gauges, with check statuses also submitted as service
+// checks
+type DatadogClient struct {
+	sync.Mutex
+	api           *datadog.APIClient
+	ctx           context.Context // carries the API key of every request
+	url           string
+	tags          []string
+	globalTags    map[string]string
+	series        []datadog.Series
+	serviceChecks []datadog.ServiceCheck
+}
+
+// NewDatadogClient creates a DatadogClient that submits metrics to the
+// Datadog site, such as datadoghq.com or datadoghq.eu, with the API key.
+// A site given as an http or https URL is used as the API address, such as
+// for a proxy.  The tags are added to every metric in key:value form.
+// Pushed metrics are submitted every DatadogFlushInterval.
+func NewDatadogClient(apiKey string, site string, tags []string) (*DatadogClient, error) {
+	d, err := newDatadogClient(apiKey, site, tags)
+	if err != nil {
+		return nil, err
+	}
+	go d.flushLoop(DatadogFlushInterval)
+	return d, nil
+}
+
+// newDatadogClient creates a DatadogClient without starting its flush loop
+func newDatadogClient(apiKey string, site string, tags []string) (*DatadogClient, error) {
+	if len(apiKey) == 0 {
+		return nil, errors.New("a Datadog API key is required")
+	}
+	site = strings.TrimSuffix(strings.TrimSpace(site), "/")
+	if len(site) == 0 {
+		return nil, errors.New("a Datadog site is required")
+	}
+	url := site
+	if !strings.HasPrefix(site, "http://") && !strings.HasPrefix(site, "https://") {
+		url = "https://api." + site
+	}
+
+	// the API client is pointed at the one address, since the server
+	// variables of the client only allow known Datadog sites
+	cfg := datadog.NewConfiguration()
+	cfg.Servers = datadog.ServerConfigurations{{URL: url}}
+	cfg.HTTPClient = &http.Client{Timeout: time.Second * 30}
+	ctx := context.WithValue(context.Background(), datadog.ContextAPIKeys, map[string]datadog.APIKey{
+		"apiKeyAuth": {Key: apiKey},
+	})
+
+	return &DatadogClient{
+		api:  datadog.NewAPIClient(cfg),
+		ctx:  ctx,
+		url:  url,
+		tags: tags,
+	}, nil
+}
+
+// Push accepts a list of metrics, with a metric being defined as a map of
+// string (name) to interface (value), and queues them for the next flush.
+// Metrics named after the check in the Name tag are submitted as
+// kuberhealthy.check.<suffix> with check and namespace tags, and a check
+// status is also submitted as the kuberhealthy.check service check.  Other
+// metrics are tagged with their tags.  Every metric is also tagged with the
+// global tags.
+func (d *DatadogClient) Push(points Metric, tags map[string]string) error {
+	now := time.Now()
+	d.Lock()
+	globalTags := d.globalTags
+	d.Unlock()
+
+	var series []datadog.Series
+	var serviceChecks []datadog.ServiceCheck
+
+	for _, point := range points {
+		for key, val := range point {
+			value, err := toFloat(val)
+			if err != nil {
+				return errors.New("Unable to push metric " + key + ": " + err.Error())
+			}
+			name, metricTags := datadogMetric(key, tags, globalTags)
+			metricTags = append(metricTags, d.tags...)
+
+			s := datadog.NewSeries(name, [][]float64{{float64(now.Unix()), value}})
+			s.SetTags(metricTags)
+			series = append(series, *s)
+
+			if name != datadogStatusMetric {
+				continue
+			}
+			status := datadog.SERVICECHECKSTATUS_OK
+			if value == 0 {
+				status = datadog.SERVICECHECKSTATUS_CRITICAL
+			}
+			sc := datadog.NewServiceCheck(datadogServiceCheck, tags["KuberhealthyPod"], status, metricTags)
+			sc.SetTimestamp(now.Unix())
+			if len(tags["Errors"]) > 0 {
+				sc.SetMessage(tags["Errors"])
+			}
+			serviceChecks = append(serviceChecks, *sc)
+		}
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	d.series = append(d.series, series...)
+	d.serviceChecks = append(d.serviceChecks, serviceChecks...)
+	return nil
+}
+
+// SetGlobalTags sets tags added to every metric and service check submitted
+// in key:value form
+func (d *DatadogClient) SetGlobalTags(tags map[string]string) {
+	d.Lock()
+	defer d.Unlock()
+	d.globalTags = copyTags(tags)
+}
+
+// Flush submits all queued metrics and service checks to Datadog through
+// the metrics and service check APIs, in one request each.  The two are
+// submitted independently, so that check statuses still reach Datadog when
+// the metrics can not be submitted, and the errors of both are returned.
+// Queued metrics are dropped when they can not be submitted so that an
+// unreachable Datadog does not grow the queue without bound.
+func (d *DatadogClient) Flush() error {
+	d.Lock()
+	series := d.series
+	serviceChecks := d.serviceChecks
+	d.series = nil
+	d.serviceChecks = nil
+	d.Unlock()
+
+	var errs []string
+	if len(series) > 0 {
+		_, _, err := d.api.MetricsApi.SubmitMetrics(d.ctx, *datadog.NewMetricsPayload(series))
+		if err != nil {
+			errs = append(errs, "Error submitting "+strconv.Itoa(len(series))+" metrics to Datadog: "+err.Error())
+		}
+	}
+	if len(serviceChecks) > 0 {
+		_, _, err := d.api.ServiceChecksApi.SubmitServiceCheck(d.ctx, serviceChecks)
+		if err != nil {
+			errs = append(errs, "Error submitting "+strconv.Itoa(len(serviceChecks))+" service checks to Datadog: "+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// flushLoop flushes queued metrics on an interval forever
+func (d *DatadogClient) flushLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		err := d.Flush()
+		if err != nil {
+			log.Errorln(err)
+		}
+	}
+}
+
+// datadogMetric returns the Datadog metric name and sorted tags of a pushed
+// metric with its tags and the global tags.  Global tags are used as tag
+// names as they are given.
+func datadogMetric(key string, tags map[string]string, globalTags map[string]string) (string, []string) {
+	tagValues := make(map[string]string)
+	for k, v := range globalTags {
+		tagValues[k] = v
+	}
+
+	var name string
+	checkName := tags["Name"]
+	if len(checkName) > 0 && strings.HasPrefix(key, checkName+"_") {
+		name = datadogPrefix + "check." + sanitizeName(strings.TrimPrefix(key, checkName+"_"))
+		tagValues["check"] = checkName
+		tagValues["namespace"] = tags["Namespace"]
+	} else {
+		name = datadogPrefix + sanitizeName(key)
+		for k, v := range tags {
+			if ignoredDatadogTags[k] {
+				continue
+			}
+			tagValues[labelName(k)] = v
+		}
+	}
+
+	var metricTags []string
+	for k, v := range tagValues {
+		metricTags = append(metricTags, k+":"+v)
+	}
+	sort.Strings(metricTags)
+	return name, metricTags
+}
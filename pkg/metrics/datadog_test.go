@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+)
+
+// datadogServer records the series and service checks submitted to it.
+// Series are rejected when rejectSeries is set.
+type datadogServer struct {
+	sync.Mutex
+	requests      int
+	apiKey        string
+	rejectSeries  bool
+	series        []datadog.Series
+	serviceChecks []datadog.ServiceCheck
+}
+
+// ServeHTTP records a submission
+func (s *datadogServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	s.requests++
+	s.apiKey = r.Header.Get("DD-API-KEY")
+
+	b, _ := ioutil.ReadAll(r.Body)
+	switch r.URL.Path {
+	case "/api/v1/series":
+		if s.rejectSeries {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		var body struct {
+			Series []datadog.Series `json:"series"`
+		}
+		json.Unmarshal(b, &body)
+		s.series = append(s.series, body.Series...)
+	case "/api/v1/check_run":
+		var checks []datadog.ServiceCheck
+		json.Unmarshal(b, &checks)
+		s.serviceChecks = append(s.serviceChecks, checks...)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+func TestNewDatadogClient(t *testing.T) {
+	_, err := newDatadogClient("", "datadoghq.com", nil)
+	if err == nil {
+		t.Fatal("Expected an error without an API key")
+	}
+	d, err := newDatadogClient("key", "datadoghq.eu", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.url != "https://api.datadoghq.eu" {
+		t.Fatal("Expected the API address of the site but got", d.url)
+	}
+}
+
+func TestDatadogMetricGlobalTags(t *testing.T) {
+	globalTags := map[string]string{"cluster": "prod-east", "namespace": "ignored"}
+
+	name, tags := datadogMetric("DaemonSetChecker_status", map[string]string{"Name": "DaemonSetChecker", "Namespace": "kuberhealthy"}, globalTags)
+	expected := []string{"check:DaemonSetChecker", "cluster:prod-east", "namespace:kuberhealthy"}
+	if name != datadogStatusMetric || !reflect.DeepEqual(tags, expected) {
+		t.Fatal("Expected", datadogStatusMetric, "with tags", expected, "but got", name, tags)
+	}
+
+	_, tags = datadogMetric("master", map[string]string{"KuberhealthyPod": "kuberhealthy-abc"}, globalTags)
+	expected = []string{"cluster:prod-east", "kuberhealthy_pod:kuberhealthy-abc", "namespace:ignored"}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Fatal("Expected tags", expected, "but got", tags)
+	}
+}
+
+func TestDatadogClientFlush(t *testing.T) {
+	server := &datadogServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	client, err := newDatadogClient("key", ts.URL, []string{"env:test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkTags := map[string]string{
+		"KuberhealthyPod": "kuberhealthy-abc",
+		"Namespace":       "kuberhealthy",
+		"Name":            "DaemonSetChecker",
+		"Errors":          "error one",
+	}
+	err = client.Push(Metric{
+		{"DaemonSetChecker_status": 0},
+		{"DaemonSetChecker_duration_seconds": 3.2},
+	}, checkTags)
+	if err != nil {
+		t.Fatal("Error pushing check metrics:", err)
+	}
+	err = client.Push(Metric{{"master": 1}}, map[string]string{"KuberhealthyPod": "kuberhealthy-abc"})
+	if err != nil {
+		t.Fatal("Error pushing master metrics:", err)
+	}
+	err = client.Push(Metric{{"bad": "value"}}, map[string]string{})
+	if err == nil {
+		t.Fatal("Expected an error pushing a metric that is not a number")
+	}
+
+	// nothing is submitted until a flush
+	if server.requests != 0 {
+		t.Fatal("Expected metrics to be batched but", server.requests, "requests were made")
+	}
+	err = client.Flush()
+	if err != nil {
+		t.Fatal("Error flushing metrics:", err)
+	}
+
+	if server.requests != 2 || server.apiKey != "key" {
+		t.Fatal("Expected one series and one service check request with the API key but got", server.requests, "requests with key", server.apiKey)
+	}
+	expected := map[string]float64{
+		"kuberhealthy.check.status":           0,
+		"kuberhealthy.check.duration_seconds": 3.2,
+		"kuberhealthy.master":                 1,
+	}
+	if len(server.series) != len(expected) {
+		t.Fatal("Expected", len(expected), "series but got", server.series)
+	}
+	for _, s := range server.series {
+		if v, ok := expected[s.Metric]; !ok || s.Points[0][1] != v || s.GetType() != "gauge" {
+			t.Fatal("Unexpected series", s)
+		}
+		if tags := s.GetTags(); tags[len(tags)-1] != "env:test" {
+			t.Fatal("Expected the client tags on series", s)
+		}
+	}
+
+	if len(server.serviceChecks) != 1 {
+		t.Fatal("Expected 1 service check but got", server.serviceChecks)
+	}
+	sc := server.serviceChecks[0]
+	if sc.Check != "kuberhealthy.check" || sc.Status != datadog.SERVICECHECKSTATUS_CRITICAL || sc.GetMessage() != "error one" || sc.Tags[0] != "check:DaemonSetChecker" {
+		t.Fatal("Unexpected service check", sc)
+	}
+
+	// the queue is empty after a flush
+	err = client.Flush()
+	if err != nil || server.requests != 2 {
+		t.Fatal("Expected no requests for an empty queue but got", server.requests, err)
+	}
+}
+
+func TestDatadogClientFlushSeriesRejected(t *testing.T) {
+	server := &datadogServer{rejectSeries: true}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	client, err := newDatadogClient("key", ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Push(Metric{{"DaemonSetChecker_status": 1}}, map[string]string{"Name": "DaemonSetChecker", "Namespace": "kuberhealthy"})
+	if err != nil {
+		t.Fatal("Error pushing check metrics:", err)
+	}
+
+	// the service check is submitted even though the series are rejected
+	err = client.Flush()
+	if err == nil || !strings.Contains(err.Error(), "metrics to Datadog") {
+		t.Fatal("Expected an error submitting the metrics but got", err)
+	}
+	if server.requests != 2 || len(server.serviceChecks) != 1 || server.serviceChecks[0].Status != datadog.SERVICECHECKSTATUS_OK {
+		t.Fatal("Expected the OK service check to be submitted but got", server.requests, "requests and", server.serviceChecks)
+	}
+}
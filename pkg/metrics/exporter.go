@@ -20,8 +20,9 @@ import (
 	"github.com/Comcast/kuberhealthy/pkg/health"
 )
 
-//GenerateMetrics takes the state and returns it in the Prometheus format
-func GenerateMetrics(state health.State) string {
+//GenerateMetrics takes the state and returns it in the Prometheus format.
+// The global tags are added as labels to every gauge.
+func GenerateMetrics(state health.State, globalTags map[string]string) string {
 	metricsOutput := ""
 	healthStatus := "0"
 	if state.OK {
@@ -29,13 +30,13 @@ func GenerateMetrics(state health.State) string {
 	}
 	metricsOutput += "# HELP kuberhealthy_running Shows if kuberhealthy is running error free\n"
 	metricsOutput += "# TYPE kuberhealthy_running gauge\n"
-	metricsOutput += fmt.Sprintf("kuberhealthy_running{currentMaster=\"%s\"} 1\n", state.CurrentMaster)
+	metricsOutput += fmt.Sprintf("kuberhealthy_running%s 1\n", stateLabels(globalTags, map[string]string{"currentMaster": state.CurrentMaster}))
 	metricsOutput += "# HELP kuberhealthy_cluster_state Shows the status of the cluster\n"
 	metricsOutput += "# TYPE kuberhealthy_cluster_state gauge\n"
-	metricsOutput += fmt.Sprintf("kuberhealthy_cluster_state %s\n", healthStatus)
+	metricsOutput += fmt.Sprintf("kuberhealthy_cluster_state%s %s\n", stateLabels(globalTags, nil), healthStatus)
 	checkMetricState := map[string]string{}
 	for c, d := range state.CheckDetails {
-		metricName := fmt.Sprintf("kuberhealthy_check{check=\"%s\",namespace=\"%s\"}", c, d.Namespace)
+		metricName := "kuberhealthy_check" + stateLabels(globalTags, map[string]string{"check": c, "namespace": d.Namespace})
 		checkStatus := "0"
 		if d.OK {
 			checkStatus = "1"
@@ -51,17 +52,30 @@ func GenerateMetrics(state health.State) string {
 }
 
 //ErrorStateMetrics is a Prometheus metric meant to show Kuberhealthy has error
-func ErrorStateMetrics(state health.State) string {
+func ErrorStateMetrics(state health.State, globalTags map[string]string) string {
 	errorOutput := ""
 	errorOutput += "# HELP kuberhealthy_running Shows if kuberhealthy is running error free\n"
 	errorOutput += "# TYPE kuberhealthy_running gauge\n"
-	errorOutput += fmt.Sprintf(`kuberhealthy_running{currentMaster="%s"} 0`, state.CurrentMaster)
+	errorOutput += fmt.Sprintf(`kuberhealthy_running%s 0`, stateLabels(globalTags, map[string]string{"currentMaster": state.CurrentMaster}))
 	return errorOutput
 }
 
+// stateLabels renders the labels of a state gauge with the global tags.
+// Labels of the gauge take precedence over global tags of the same name.
+func stateLabels(globalTags map[string]string, labels map[string]string) string {
+	all := make(map[string]string)
+	for k, v := range globalTags {
+		all[sanitizeName(k)] = v
+	}
+	for k, v := range labels {
+		all[k] = v
+	}
+	return wrapLabels(renderLabels(all))
+}
+
 // WriteMetricError handles errors in delivering metrics
-func WriteMetricError(w http.ResponseWriter, state health.State) error {
-	metricDefaultError := ErrorStateMetrics(state)
+func WriteMetricError(w http.ResponseWriter, state health.State, globalTags map[string]string) error {
+	metricDefaultError := ErrorStateMetrics(state, globalTags)
 	_, err := w.Write([]byte(metricDefaultError))
 	if err != nil {
 		log.Warningln("Error writing health check results to caller:", err)
@@ -34,7 +34,7 @@ func parseMetrics(metricOutput string) map[string]string {
 func TestGenerateMetrics(t *testing.T) {
 	// Test Empty State
 	state := health.State{}
-	result := GenerateMetrics(state)
+	result := GenerateMetrics(state, nil)
 	metrics := parseMetrics(result)
 	if metrics[`kuberhealthy_running{currentMaster=""}`] != "1" {
 		t.Fatal("Kuberhealthy is not shown as running")
@@ -46,7 +46,7 @@ func TestGenerateMetrics(t *testing.T) {
 	state = health.State{
 		OK: true,
 	}
-	result = GenerateMetrics(state)
+	result = GenerateMetrics(state, nil)
 	metrics = parseMetrics(result)
 	if metrics[`kuberhealthy_running{currentMaster=""}`] != "1" {
 		t.Fatal("Kuberhealthy is not shown as running")
@@ -58,7 +58,7 @@ func TestGenerateMetrics(t *testing.T) {
 	state = health.State{
 		OK: false,
 	}
-	result = GenerateMetrics(state)
+	result = GenerateMetrics(state, nil)
 	metrics = parseMetrics(result)
 	if metrics[`kuberhealthy_running{currentMaster=""}`] != "1" {
 		t.Fatal("Kuberhealthy is not shown as running")
@@ -70,7 +70,7 @@ func TestGenerateMetrics(t *testing.T) {
 	state = health.State{
 		CurrentMaster: "testMaster",
 	}
-	result = GenerateMetrics(state)
+	result = GenerateMetrics(state, nil)
 	metrics = parseMetrics(result)
 	if metrics[`kuberhealthy_running{currentMaster="testMaster"}`] != "1" {
 		t.Fatal("Kuberhealthy is not shown as running")
@@ -92,7 +92,7 @@ func TestGenerateMetrics(t *testing.T) {
 			},
 		},
 	}
-	result = GenerateMetrics(state)
+	result = GenerateMetrics(state, nil)
 	metrics = parseMetrics(result)
 	if metrics[`kuberhealthy_running{currentMaster=""}`] != "1" {
 		t.Fatal("Kuberhealthy is not shown as running")
@@ -111,11 +111,37 @@ func TestGenerateMetrics(t *testing.T) {
 	}
 }
 
+func TestGenerateMetricsGlobalTags(t *testing.T) {
+	state := health.State{
+		OK:            true,
+		CurrentMaster: "testMaster",
+		CheckDetails: map[string]health.CheckDetails{
+			"good": {OK: true, Namespace: "kuberhealthy"},
+		},
+	}
+	globalTags := map[string]string{"cluster": "prod-east"}
+	metrics := parseMetrics(GenerateMetrics(state, globalTags))
+	if metrics[`kuberhealthy_running{cluster="prod-east",currentMaster="testMaster"}`] != "1" {
+		t.Fatal("Expected kuberhealthy_running to have the cluster label but got", metrics)
+	}
+	if metrics[`kuberhealthy_cluster_state{cluster="prod-east"}`] != "1" {
+		t.Fatal("Expected kuberhealthy_cluster_state to have the cluster label but got", metrics)
+	}
+	if metrics[`kuberhealthy_check{check="good",cluster="prod-east",namespace="kuberhealthy"}`] != "1" {
+		t.Fatal("Expected kuberhealthy_check to have the cluster label but got", metrics)
+	}
+
+	errorState := ErrorStateMetrics(state, globalTags)
+	if !strings.HasSuffix(errorState, `kuberhealthy_running{cluster="prod-east",currentMaster="testMaster"} 0`) {
+		t.Fatal("Expected the error state metric to have the cluster label but got", errorState)
+	}
+}
+
 func TestErrorStateMetrics(t *testing.T) {
 	state := health.State{
 		CurrentMaster: "testMaster",
 	}
-	errorState := ErrorStateMetrics(state)
+	errorState := ErrorStateMetrics(state, nil)
 	lines := strings.Split(errorState, "\n")
 	metricValue := strings.Split(lines[2], " ")
 	if lines[2] != `kuberhealthy_running{currentMaster="testMaster"} 0` {
@@ -125,7 +151,7 @@ func TestErrorStateMetrics(t *testing.T) {
 		t.Fatal("Error State Metric is not 0")
 	}
 	state = health.State{}
-	errorState = ErrorStateMetrics(state)
+	errorState = ErrorStateMetrics(state, nil)
 	lines = strings.Split(errorState, "\n")
 	metricValue = strings.Split(lines[2], " ")
 	if lines[2] != `kuberhealthy_running{currentMaster=""} 0` {
@@ -140,20 +166,20 @@ func TestWriteMetricError(t *testing.T) {
 	state := health.State{
 		CurrentMaster: "testMaster",
 	}
-	err := WriteMetricError(recorder, state)
+	err := WriteMetricError(recorder, state, nil)
 	if err != nil {
 		t.Fatal("Error occurred writing metric error: ", err)
 	}
-	if recorder.Body.String() != ErrorStateMetrics(state) {
+	if recorder.Body.String() != ErrorStateMetrics(state, nil) {
 		t.Fatal("Error Metric does not match actual error metric function")
 	}
 	recorder = httptest.NewRecorder()
 	state = health.State{}
-	err = WriteMetricError(recorder, state)
+	err = WriteMetricError(recorder, state, nil)
 	if err != nil {
 		t.Fatal("Error occurred writing metric error: ", err)
 	}
-	if recorder.Body.String() != ErrorStateMetrics(state) {
+	if recorder.Body.String() != ErrorStateMetrics(state, nil) {
 		t.Fatal("Error Metric does not match actual error metric function")
 	}
 }
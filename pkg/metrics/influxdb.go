@@ -13,8 +13,9 @@ import (
 
 // InfluxClient defines values needed to push to InfluxDB
 type InfluxClient struct {
-	client *influx.Client
-	db     string
+	client     *influx.Client
+	db         string
+	globalTags map[string]string
 }
 
 // InfluxClientInput defines values needed to push to InfluxDB
@@ -64,11 +65,20 @@ func (i *InfluxClient) Push(points Metric, tags map[string]string) error {
 			})
 		}
 	}
+	batchTags := copyTags(i.globalTags)
+	for k, v := range tags {
+		batchTags[k] = v
+	}
 	batch := influx.BatchPoints{
 		Database: i.db,
-		Tags:     tags,
+		Tags:     batchTags,
 		Points:   influxPoints,
 	}
 	_, err := i.client.Write(batch)
 	return err
 }
+
+// SetGlobalTags sets tags added to every point written to InfluxDB
+func (i *InfluxClient) SetGlobalTags(tags map[string]string) {
+	i.globalTags = copyTags(tags)
+}
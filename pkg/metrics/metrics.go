@@ -6,4 +6,42 @@ type Metric []map[string]interface{}
 // Client is an abstraction for pushing metrics to custom providers
 type Client interface {
 	Push(points Metric, tags map[string]string) error
+	// SetGlobalTags sets tags applied to every metric pushed, such as the
+	// cluster the metrics come from.  It is called before any metrics are
+	// pushed.  Tags pushed with a metric take precedence over global tags of
+	// the same name.
+	SetGlobalTags(tags map[string]string)
+}
+
+// MultiClient pushes metrics to every client it holds
+type MultiClient []Client
+
+// Push pushes metrics to every client and returns the first error
+// encountered.  A failing client does not prevent pushes to the others.
+func (m MultiClient) Push(points Metric, tags map[string]string) error {
+	var firstErr error
+	for _, c := range m {
+		err := c.Push(points, tags)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// SetGlobalTags sets the global tags of every client
+func (m MultiClient) SetGlobalTags(tags map[string]string) {
+	for _, c := range m {
+		c.SetGlobalTags(tags)
+	}
+}
+
+// copyTags returns a copy of tags so that later changes to the map passed to
+// SetGlobalTags do not change the tags of a client
+func copyTags(tags map[string]string) map[string]string {
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
 }
@@ -0,0 +1,298 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// PrometheusBuckets are the upper bounds of the histogram buckets durations
+// are observed into.  Checks commonly run for several minutes, so the
+// buckets extend further than the usual Prometheus defaults.
+var PrometheusBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+
+// prometheusPrefix is prepended to the name of every metric exposed
+const prometheusPrefix = "kuberhealthy_"
+
+// histogramSuffix marks pushed metrics that are observed into a histogram
+// instead of set as a gauge
+const histogramSuffix = "_duration_seconds"
+
+// ignoredPrometheusTags are tags that are not turned into labels because
+// their values are unbounded
+var ignoredPrometheusTags = map[string]bool{
+	"Errors": true,
+}
+
+// PrometheusClient holds metrics pushed to it in memory and exposes them in
+// the Prometheus text format to be scraped
+type PrometheusClient struct {
+	sync.Mutex
+	gauges     map[string]map[string]float64
+	histograms map[string]map[string]*histogram
+	globalTags map[string]string
+}
+
+// histogram is a single histogram series
+type histogram struct {
+	buckets []uint64 // the count of observations in each bucket, not cumulative
+	count   uint64
+	sum     float64
+}
+
+// NewPrometheusClient creates a PrometheusClient that can be used to expose
+// metrics for scraping
+func NewPrometheusClient() *PrometheusClient {
+	return &PrometheusClient{
+		gauges:     make(map[string]map[string]float64),
+		histograms: make(map[string]map[string]*histogram),
+	}
+}
+
+// Push accepts a list of metrics, with a metric being defined as a map of
+// string (name) to interface (value).  Metrics named after the check in the
+// Name tag, such as the check status pushed by Kuberhealthy, are exposed as
+// kuberhealthy_check_<suffix> with check and namespace labels.  Other
+// metrics are labeled with their tags.  Every metric is also labeled with
+// the global tags.  Metrics ending in _duration_seconds
+// are observed into a histogram and all others are set as gauges.
+func (p *PrometheusClient) Push(points Metric, tags map[string]string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, point := range points {
+		for key, val := range point {
+			value, err := toFloat(val)
+			if err != nil {
+				return errors.New("Unable to push metric " + key + ": " + err.Error())
+			}
+			name, labels := prometheusSeries(key, tags, p.globalTags)
+
+			if strings.HasSuffix(name, histogramSuffix) {
+				series, ok := p.histograms[name]
+				if !ok {
+					series = make(map[string]*histogram)
+					p.histograms[name] = series
+				}
+				h, ok := series[labels]
+				if !ok {
+					h = &histogram{buckets: make([]uint64, len(PrometheusBuckets))}
+					series[labels] = h
+				}
+				h.observe(value)
+				continue
+			}
+
+			series, ok := p.gauges[name]
+			if !ok {
+				series = make(map[string]float64)
+				p.gauges[name] = series
+			}
+			series[labels] = value
+		}
+	}
+	return nil
+}
+
+// SetGlobalTags sets tags added as labels to every exposed metric
+func (p *PrometheusClient) SetGlobalTags(tags map[string]string) {
+	p.Lock()
+	defer p.Unlock()
+	p.globalTags = copyTags(tags)
+}
+
+// GenerateMetrics returns all pushed metrics in the Prometheus format
+func (p *PrometheusClient) GenerateMetrics() string {
+	p.Lock()
+	defer p.Unlock()
+
+	metricsOutput := ""
+	gaugeNames := make([]string, 0, len(p.gauges))
+	for name := range p.gauges {
+		gaugeNames = append(gaugeNames, name)
+	}
+	sort.Strings(gaugeNames)
+	for _, name := range gaugeNames {
+		metricsOutput += "# TYPE " + name + " gauge\n"
+		series := p.gauges[name]
+		labels := make([]string, 0, len(series))
+		for l := range series {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		for _, l := range labels {
+			metricsOutput += name + wrapLabels(l) + " " + formatFloat(series[l]) + "\n"
+		}
+	}
+
+	histogramNames := make([]string, 0, len(p.histograms))
+	for name := range p.histograms {
+		histogramNames = append(histogramNames, name)
+	}
+	sort.Strings(histogramNames)
+	for _, name := range histogramNames {
+		metricsOutput += "# TYPE " + name + " histogram\n"
+		series := p.histograms[name]
+		labels := make([]string, 0, len(series))
+		for l := range series {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		for _, l := range labels {
+			h := series[l]
+			var cumulative uint64
+			for i, upperBound := range PrometheusBuckets {
+				cumulative += h.buckets[i]
+				metricsOutput += name + "_bucket" + wrapLabels(joinLabels(l, `le="`+formatFloat(upperBound)+`"`)) + " " + strconv.FormatUint(cumulative, 10) + "\n"
+			}
+			metricsOutput += name + "_bucket" + wrapLabels(joinLabels(l, `le="+Inf"`)) + " " + strconv.FormatUint(h.count, 10) + "\n"
+			metricsOutput += name + "_sum" + wrapLabels(l) + " " + formatFloat(h.sum) + "\n"
+			metricsOutput += name + "_count" + wrapLabels(l) + " " + strconv.FormatUint(h.count, 10) + "\n"
+		}
+	}
+	return metricsOutput
+}
+
+// observe adds a value to the histogram
+func (h *histogram) observe(value float64) {
+	for i, upperBound := range PrometheusBuckets {
+		if value <= upperBound {
+			h.buckets[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// prometheusSeries returns the metric name and rendered labels for a pushed
+// metric key, its tags and the global tags.  Global tags are used as label
+// names as they are given.
+func prometheusSeries(key string, tags map[string]string, globalTags map[string]string) (string, string) {
+	labels := make(map[string]string)
+	for k, v := range globalTags {
+		labels[sanitizeName(k)] = v
+	}
+
+	checkName := tags["Name"]
+	if len(checkName) > 0 && strings.HasPrefix(key, checkName+"_") {
+		name := prometheusPrefix + "check_" + sanitizeName(strings.TrimPrefix(key, checkName+"_"))
+		labels["check"] = checkName
+		labels["namespace"] = tags["Namespace"]
+		return name, renderLabels(labels)
+	}
+
+	name := sanitizeName(key)
+	if !strings.HasPrefix(name, prometheusPrefix) {
+		name = prometheusPrefix + name
+	}
+	for k, v := range tags {
+		if ignoredPrometheusTags[k] {
+			continue
+		}
+		labels[labelName(k)] = v
+	}
+	return name, renderLabels(labels)
+}
+
+// renderLabels renders labels sorted by name without the surrounding braces
+func renderLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for n := range labels {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	rendered := make([]string, 0, len(names))
+	for _, n := range names {
+		rendered = append(rendered, n+`="`+escapeLabelValue(labels[n])+`"`)
+	}
+	return strings.Join(rendered, ",")
+}
+
+// joinLabels appends a rendered label to rendered labels
+func joinLabels(labels string, label string) string {
+	if len(labels) == 0 {
+		return label
+	}
+	return labels + "," + label
+}
+
+// wrapLabels surrounds rendered labels with braces when there are any
+func wrapLabels(labels string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+// escapeLabelValue escapes a label value for the Prometheus text format
+func escapeLabelValue(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	return strings.Replace(value, "\n", `\n`, -1)
+}
+
+// sanitizeName replaces characters that are not valid in a Prometheus metric
+// name with underscores
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == ':') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// labelName converts a tag such as KuberhealthyPod to a label name such as
+// kuberhealthy_pod
+func labelName(tag string) string {
+	var name []rune
+	for i, r := range sanitizeName(tag) {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				name = append(name, '_')
+			}
+			r = unicode.ToLower(r)
+		}
+		name = append(name, r)
+	}
+	return string(name)
+}
+
+// toFloat converts a pushed metric value to a float
+func toFloat(val interface{}) (float64, error) {
+	switch v := val.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("value %v of type %T is not numeric", val, val)
+}
+
+// formatFloat formats a value for the Prometheus text format
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusClientPush(t *testing.T) {
+	client := NewPrometheusClient()
+	checkTags := map[string]string{
+		"KuberhealthyPod": "kuberhealthy-abc",
+		"Namespace":       "kuberhealthy",
+		"Name":            "DaemonSetChecker",
+		"Errors":          "error one,error two",
+	}
+
+	err := client.Push(Metric{
+		{"DaemonSetChecker_status": 1},
+		{"DaemonSetChecker_duration_seconds": 3.2},
+	}, checkTags)
+	if err != nil {
+		t.Fatal("Error pushing check metrics:", err)
+	}
+	err = client.Push(Metric{{"DaemonSetChecker_duration_seconds": 45.0}}, checkTags)
+	if err != nil {
+		t.Fatal("Error pushing check metrics:", err)
+	}
+	err = client.Push(Metric{{"master": 0}}, map[string]string{})
+	if err != nil {
+		t.Fatal("Error pushing master metrics:", err)
+	}
+	err = client.Push(Metric{{"master": 1}}, map[string]string{})
+	if err != nil {
+		t.Fatal("Error pushing master metrics:", err)
+	}
+	err = client.Push(Metric{{"RuntimeConcurrencyChecker p99": int64(12)}}, map[string]string{"Node": "node-a", "Operation": "create_container"})
+	if err != nil {
+		t.Fatal("Error pushing check metrics:", err)
+	}
+
+	output := client.GenerateMetrics()
+	t.Log(output)
+	metrics := parseMetrics(output)
+
+	var tests = []struct {
+		description string
+		series      string
+		expected    string
+	}{
+		{"check status", `kuberhealthy_check_status{check="DaemonSetChecker",namespace="kuberhealthy"}`, "1"},
+		{"master", `kuberhealthy_master`, "1"},
+		{"tagged metric", `kuberhealthy_RuntimeConcurrencyChecker_p99{node="node-a",operation="create_container"}`, "12"},
+		{"duration bucket", `kuberhealthy_check_duration_seconds_bucket{check="DaemonSetChecker",namespace="kuberhealthy",le="5"}`, "1"},
+		{"duration cumulative bucket", `kuberhealthy_check_duration_seconds_bucket{check="DaemonSetChecker",namespace="kuberhealthy",le="60"}`, "2"},
+		{"duration inf bucket", `kuberhealthy_check_duration_seconds_bucket{check="DaemonSetChecker",namespace="kuberhealthy",le="+Inf"}`, "2"},
+		{"duration sum", `kuberhealthy_check_duration_seconds_sum{check="DaemonSetChecker",namespace="kuberhealthy"}`, "48.2"},
+		{"duration count", `kuberhealthy_check_duration_seconds_count{check="DaemonSetChecker",namespace="kuberhealthy"}`, "2"},
+	}
+	for _, test := range tests {
+		if metrics[test.series] != test.expected {
+			t.Fatal("Test", test.description, "expected", test.expected, "but got", metrics[test.series])
+		}
+	}
+
+	if !strings.Contains(output, "# TYPE kuberhealthy_check_duration_seconds histogram\n") {
+		t.Fatal("Duration is not exposed as a histogram")
+	}
+	if strings.Contains(output, "error one") {
+		t.Fatal("Errors tag was exposed as a label")
+	}
+}
+
+func TestPrometheusClientGlobalTags(t *testing.T) {
+	client := NewPrometheusClient()
+	client.SetGlobalTags(map[string]string{"cluster": "prod-east", "region": "us-east-1"})
+
+	err := client.Push(Metric{{"DaemonSetChecker_status": 1}}, map[string]string{"Name": "DaemonSetChecker", "Namespace": "kuberhealthy"})
+	if err != nil {
+		t.Fatal("Error pushing check metrics:", err)
+	}
+	err = client.Push(Metric{{"master": 1}}, map[string]string{"KuberhealthyPod": "kuberhealthy-abc", "Region": "us-west-2"})
+	if err != nil {
+		t.Fatal("Error pushing master metrics:", err)
+	}
+
+	metrics := parseMetrics(client.GenerateMetrics())
+	var tests = []struct {
+		description string
+		series      string
+	}{
+		{"check status", `kuberhealthy_check_status{check="DaemonSetChecker",cluster="prod-east",namespace="kuberhealthy",region="us-east-1"}`},
+		{"pushed tags take precedence", `kuberhealthy_master{cluster="prod-east",kuberhealthy_pod="kuberhealthy-abc",region="us-west-2"}`},
+	}
+	for _, test := range tests {
+		if metrics[test.series] != "1" {
+			t.Fatal("Test", test.description, "expected series", test.series, "but got", metrics)
+		}
+	}
+}
+
+func TestPrometheusClientPushNonNumeric(t *testing.T) {
+	client := NewPrometheusClient()
+	err := client.Push(Metric{{"DaemonSetChecker_status": "ok"}}, map[string]string{"Name": "DaemonSetChecker"})
+	if err == nil {
+		t.Fatal("Expected an error pushing a non-numeric metric")
+	}
+}
+
+func TestLabelName(t *testing.T) {
+	var tests = []struct {
+		tag      string
+		expected string
+	}{
+		{"KuberhealthyPod", "kuberhealthy_pod"},
+		{"Le", "le"},
+		{"node", "node"},
+	}
+	for _, test := range tests {
+		if name := labelName(test.tag); name != test.expected {
+			t.Fatal("Test", test.tag, "expected", test.expected, "but got", name)
+		}
+	}
+}
+
+// failingClient is a metrics client that always fails to push
+type failingClient struct {
+	pushes int
+}
+
+func (f *failingClient) Push(points Metric, tags map[string]string) error {
+	f.pushes++
+	return errors.New("push failed")
+}
+
+func (f *failingClient) SetGlobalTags(tags map[string]string) {}
+
+func TestMultiClientPush(t *testing.T) {
+	failing := &failingClient{}
+	prometheus := NewPrometheusClient()
+	client := MultiClient{failing, prometheus}
+
+	err := client.Push(Metric{{"master": 1}}, map[string]string{})
+	if err == nil {
+		t.Fatal("Expected the failing client error to be returned")
+	}
+	if failing.pushes != 1 {
+		t.Fatal("Expected the failing client to be pushed to once but got", failing.pushes)
+	}
+	if parseMetrics(prometheus.GenerateMetrics())["kuberhealthy_master"] != "1" {
+		t.Fatal("Metrics were not pushed to the client after the failing client")
+	}
+}
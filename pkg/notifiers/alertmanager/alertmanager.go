@@ -0,0 +1,196 @@
+// Package alertmanager implements a notifier that raises Prometheus
+// Alertmanager alerts for failing checks through the Alertmanager v2 API.
+package alertmanager // import "github.com/Comcast/kuberhealthy/pkg/notifiers/alertmanager"
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FlushInterval is how often queued alerts are sent to Alertmanager in a
+// single batch
+var FlushInterval = time.Second * 15
+
+// alertsPath is the Alertmanager API path alerts are posted to
+const alertsPath = "/api/v2/alerts"
+
+// AlertName is the alertname label of every alert raised
+const AlertName = "KuberhealthyCheckFailed"
+
+// alert is an alert as accepted by the Alertmanager v2 API
+type alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      *time.Time        `json:"endsAt,omitempty"`
+}
+
+// Notifier raises an Alertmanager alert when a check starts failing and
+// resolves it when the check recovers.  While a check keeps failing its
+// alert is sent again unchanged so that Alertmanager does not resolve it
+// after its resolve_timeout.  Alertmanager deduplicates the repeated alert,
+// so no new notification is sent until the check recovers and fails again.
+type Notifier struct {
+	sync.Mutex
+	url        string
+	labels     map[string]string
+	httpClient *http.Client
+	firing     map[string]alert // the alert of every failing check
+	queue      map[string]alert // the alerts waiting for the next flush by check
+}
+
+// New creates a Notifier that sends alerts to the Alertmanager at url, such
+// as http://alertmanager.monitoring:9093.  The labels, given in key=value
+// form, are added to every alert.  Alerts are sent every FlushInterval.
+func New(url string, labels []string) (*Notifier, error) {
+	n, err := newNotifier(url, labels)
+	if err != nil {
+		return nil, err
+	}
+	go n.flushLoop(FlushInterval)
+	return n, nil
+}
+
+// newNotifier creates a Notifier without starting its flush loop
+func newNotifier(url string, labels []string) (*Notifier, error) {
+	url = strings.TrimSuffix(strings.TrimSpace(url), "/")
+	url = strings.TrimSuffix(url, alertsPath)
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, errors.New("the Alertmanager URL must be an http or https URL")
+	}
+
+	labelMap := make(map[string]string)
+	for _, l := range labels {
+		parts := strings.SplitN(l, "=", 2)
+		if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+			return nil, errors.New("Alertmanager label " + l + " is not in key=value form")
+		}
+		labelMap[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return &Notifier{
+		url:        url,
+		labels:     labelMap,
+		httpClient: &http.Client{Timeout: time.Second * 30},
+		firing:     make(map[string]alert),
+		queue:      make(map[string]alert),
+	}, nil
+}
+
+// Notify queues an alert for the next flush when a check is failing, and
+// queues the resolution of its alert when a check that was failing is OK
+func (n *Notifier) Notify(checkName string, namespace string, ok bool, errs []string) error {
+	n.Lock()
+	defer n.Unlock()
+
+	a, firing := n.firing[checkName]
+	if ok {
+		if !firing {
+			return nil
+		}
+		now := time.Now()
+		a.EndsAt = &now
+		delete(n.firing, checkName)
+		n.queue[checkName] = a
+		return nil
+	}
+
+	if !firing {
+		a = n.newAlert(checkName, namespace, time.Now())
+		log.Infoln("Raising Alertmanager alert for failing check", checkName)
+	}
+	a.Annotations = map[string]string{
+		"summary":     "Kuberhealthy check " + checkName + " is failing",
+		"description": strings.Join(errs, "\n"),
+	}
+	n.firing[checkName] = a
+	n.queue[checkName] = a
+	return nil
+}
+
+// newAlert makes the alert of a check that started failing at startsAt
+func (n *Notifier) newAlert(checkName string, namespace string, startsAt time.Time) alert {
+	labels := map[string]string{}
+	for k, v := range n.labels {
+		labels[k] = v
+	}
+	labels["alertname"] = AlertName
+	labels["check"] = checkName
+	if len(namespace) > 0 {
+		labels["namespace"] = namespace
+	}
+	return alert{
+		Labels:   labels,
+		StartsAt: startsAt,
+	}
+}
+
+// Flush sends all queued alerts to Alertmanager in one request.  Queued
+// alerts are dropped when they can not be sent.  The alert of a check that
+// is still failing is queued again by its next run.
+func (n *Notifier) Flush() error {
+	n.Lock()
+	var checkNames []string
+	for checkName := range n.queue {
+		checkNames = append(checkNames, checkName)
+	}
+	sort.Strings(checkNames)
+	var alerts []alert
+	for _, checkName := range checkNames {
+		alerts = append(alerts, n.queue[checkName])
+	}
+	n.queue = make(map[string]alert)
+	n.Unlock()
+
+	if len(alerts) == 0 {
+		return nil
+	}
+	err := n.post(alerts)
+	if err != nil {
+		return errors.New("Error sending " + strconv.Itoa(len(alerts)) + " alerts to Alertmanager: " + err.Error())
+	}
+	return nil
+}
+
+// flushLoop flushes queued alerts on an interval forever
+func (n *Notifier) flushLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		err := n.Flush()
+		if err != nil {
+			log.Errorln(err)
+		}
+	}
+}
+
+// post sends alerts to the Alertmanager alerts API
+func (n *Notifier) post(alerts []alert) error {
+	b, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, n.url+alertsPath, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("Alertmanager returned status " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// alertmanagerServer records the alerts posted to it
+type alertmanagerServer struct {
+	sync.Mutex
+	requests int
+	alerts   []alert
+}
+
+// ServeHTTP records a post of alerts
+func (s *alertmanagerServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	if r.URL.Path != alertsPath {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.requests++
+	var alerts []alert
+	json.NewDecoder(r.Body).Decode(&alerts)
+	s.alerts = append(s.alerts, alerts...)
+}
+
+func TestNewNotifier(t *testing.T) {
+	_, err := newNotifier("alertmanager:9093", nil)
+	if err == nil {
+		t.Fatal("Expected an error for a URL without a scheme")
+	}
+	_, err = newNotifier("http://alertmanager:9093", []string{"team"})
+	if err == nil {
+		t.Fatal("Expected an error for a label without a value")
+	}
+	n, err := newNotifier("http://alertmanager:9093/api/v2/alerts/", []string{"team=platform"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.url != "http://alertmanager:9093" || n.labels["team"] != "platform" {
+		t.Fatal("Unexpected URL or labels", n.url, n.labels)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	server := &alertmanagerServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	n, err := newNotifier(ts.URL, []string{"severity=page"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a check that is OK raises nothing
+	n.Notify("DaemonSetChecker", "kuberhealthy", true, []string{})
+	n.Notify("DNSChecker", "", false, []string{"lookup failed"})
+	n.Notify("PodStatusChecker", "kube-system", false, []string{"pod not ready"})
+	err = n.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.requests != 1 || len(server.alerts) != 2 {
+		t.Fatal("Expected 2 alerts in one request but got", server.requests, "requests with", server.alerts)
+	}
+	firing := server.alerts[1]
+	if firing.Labels["alertname"] != AlertName || firing.Labels["check"] != "PodStatusChecker" || firing.Labels["namespace"] != "kube-system" || firing.Labels["severity"] != "page" {
+		t.Fatal("Unexpected alert labels", firing.Labels)
+	}
+	if firing.Annotations["description"] != "pod not ready" || firing.EndsAt != nil {
+		t.Fatal("Unexpected firing alert", firing)
+	}
+
+	// a check that keeps failing sends the same alert again
+	n.Notify("PodStatusChecker", "kube-system", false, []string{"pod not ready"})
+	n.Flush()
+	repeated := server.alerts[2]
+	if !repeated.StartsAt.Equal(firing.StartsAt) || repeated.EndsAt != nil {
+		t.Fatal("Expected the firing alert to be sent again unchanged but got", repeated)
+	}
+
+	// a check that recovers resolves its alert once
+	n.Notify("PodStatusChecker", "kube-system", true, []string{})
+	n.Flush()
+	resolved := server.alerts[3]
+	if resolved.Labels["check"] != "PodStatusChecker" || resolved.EndsAt == nil {
+		t.Fatal("Expected a resolved alert but got", resolved)
+	}
+	n.Notify("PodStatusChecker", "kube-system", true, []string{})
+	n.Flush()
+	if len(server.alerts) != 4 || server.requests != 3 {
+		t.Fatal("Expected no alerts for a check that stays OK but got", server.alerts[4:])
+	}
+
+	// a check that fails again raises a new alert
+	n.Notify("PodStatusChecker", "kube-system", false, []string{"pod not ready"})
+	n.Flush()
+	refired := server.alerts[4]
+	if refired.EndsAt != nil || refired.StartsAt.Before(resolved.StartsAt) || refired.StartsAt.Equal(firing.StartsAt) {
+		t.Fatal("Expected a new firing alert but got", refired)
+	}
+}
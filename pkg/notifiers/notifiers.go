@@ -0,0 +1,10 @@
+// Package notifiers holds the interface of the notifiers that check results
+// are sent to so that failing checks can raise alerts.  Each notifier is
+// implemented in its own package.
+package notifiers // import "github.com/Comcast/kuberhealthy/pkg/notifiers"
+
+// Notifier is sent the result of every check run.  Notifiers decide from
+// the results when a check has started or stopped failing.
+type Notifier interface {
+	Notify(checkName string, namespace string, ok bool, errors []string) error
+}
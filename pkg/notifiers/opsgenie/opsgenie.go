@@ -0,0 +1,307 @@
+// Package opsgenie implements a notifier that creates an OpsGenie alert
+// through the Alerts v2 API when a check starts failing and closes it when
+// the check recovers.
+package opsgenie // import "github.com/Comcast/kuberhealthy/pkg/notifiers/opsgenie"
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultAPIURL is the OpsGenie API alerts are sent to.  Accounts in the EU
+// instance use https://api.eu.opsgenie.com instead.
+const DefaultAPIURL = "https://api.opsgenie.com"
+
+// maxMessageLength is the longest alert message accepted by the Alerts API
+const maxMessageLength = 130
+
+// maxDescriptionLength is the longest alert description accepted by the
+// Alerts API
+const maxDescriptionLength = 15000
+
+// errRateLimited is returned when OpsGenie rejects a request because too
+// many requests were sent
+var errRateLimited = errors.New("OpsGenie returned status 429")
+
+// alert is the body of a request to create an alert
+type alert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description"`
+	Responders  []responder       `json:"responders,omitempty"`
+	Tags        []string          `json:"tags"`
+	Details     map[string]string `json:"details"`
+	Entity      string            `json:"entity"`
+	Source      string            `json:"source"`
+}
+
+// responder is a team an alert is routed to
+type responder struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// closeRequest is the body of a request to close an alert
+type closeRequest struct {
+	Source string `json:"source"`
+	Note   string `json:"note"`
+}
+
+// request is a request to the Alerts API for a check
+type request struct {
+	checkName string
+	path      string
+	body      interface{}
+}
+
+// Notifier creates an OpsGenie alert when a check starts failing and closes
+// it when the check recovers.  Alerts are deduplicated by an alias made from
+// the cluster name and check name, so repeated failures of a check never
+// create more than one alert and clusters can share an API key.  Requests
+// that are rate limited by OpsGenie are queued and retried in the order they
+// were made.
+type Notifier struct {
+	sync.Mutex
+	url           string
+	apiKey        string
+	team          string
+	clusterName   string
+	statusURL     string
+	httpClient    *http.Client
+	failing       map[string]bool // the last state sent for each check
+	queue         []request       // requests waiting to be retried after being rate limited
+	retryDelay    time.Duration   // the first delay before retrying a rate limited request
+	maxRetryDelay time.Duration
+}
+
+// New creates a Notifier that sends alerts with the API key of an OpsGenie
+// API integration to the OpsGenie API at apiURL, or the default API when
+// apiURL is empty.  Alerts are routed to team when it is set.  The cluster
+// name is included in the alias and message of every alert, and a link to
+// the Kuberhealthy status page at statusURL in its description.
+func New(apiURL string, apiKey string, team string, clusterName string, statusURL string) (*Notifier, error) {
+	apiKey = strings.TrimSpace(apiKey)
+	if len(apiKey) == 0 {
+		return nil, errors.New("an OpsGenie API key is required")
+	}
+	if len(apiURL) == 0 {
+		apiURL = DefaultAPIURL
+	}
+	if !strings.HasPrefix(apiURL, "https://") && !strings.HasPrefix(apiURL, "http://") {
+		return nil, errors.New("the OpsGenie API URL must be an http or https URL")
+	}
+
+	return &Notifier{
+		url:           strings.TrimSuffix(apiURL, "/"),
+		apiKey:        apiKey,
+		team:          team,
+		clusterName:   clusterName,
+		statusURL:     statusURL,
+		httpClient:    &http.Client{Timeout: time.Second * 10},
+		failing:       make(map[string]bool),
+		retryDelay:    time.Second * 5,
+		maxRetryDelay: time.Minute * 5,
+	}, nil
+}
+
+// Notify creates an alert when a check starts failing and closes it when the
+// check recovers.  A check that is OK the first time it is seen closes its
+// alert, so that an alert left open by a restart is closed.  When the request
+// can not be sent, the state of the check is not changed so that the next run
+// sends it again.  Rate limited requests are queued and retried in the
+// background instead, and requests made while the queue is not empty are
+// queued behind them so that alerts are never closed before they are
+// created.
+func (n *Notifier) Notify(checkName string, namespace string, ok bool, errs []string) error {
+	n.Lock()
+	wasFailing, seen := n.failing[checkName]
+	if seen && wasFailing == !ok {
+		n.Unlock()
+		return nil
+	}
+	n.failing[checkName] = !ok
+
+	alias := n.alias(checkName)
+	r := request{
+		checkName: checkName,
+		path:      "/v2/alerts/" + url.PathEscape(alias) + "/close?identifierType=alias",
+		body:      closeRequest{Source: "kuberhealthy", Note: "Kuberhealthy check " + checkName + " has recovered"},
+	}
+	if !ok {
+		log.Infoln("Creating OpsGenie alert for failing check", checkName)
+		r.path = "/v2/alerts"
+		r.body = n.newAlert(checkName, namespace, alias, errs)
+	} else if seen {
+		log.Infoln("Closing OpsGenie alert for recovered check", checkName)
+	}
+
+	if len(n.queue) > 0 {
+		n.queue = append(n.queue, r)
+		n.Unlock()
+		return nil
+	}
+	n.Unlock()
+
+	err := n.send(r)
+	if err == errRateLimited {
+		log.Warningln("OpsGenie rate limited the request for check", checkName+".  Retrying in the background.")
+		n.enqueue(r)
+		return nil
+	}
+	if err != nil {
+		n.Lock()
+		if seen {
+			n.failing[checkName] = wasFailing
+		} else {
+			delete(n.failing, checkName)
+		}
+		n.Unlock()
+		return errors.New("Error sending OpsGenie request for check " + checkName + ": " + err.Error())
+	}
+	return nil
+}
+
+// enqueue queues a rate limited request and starts retrying the queue when
+// it is not being retried already
+func (n *Notifier) enqueue(r request) {
+	n.Lock()
+	defer n.Unlock()
+	n.queue = append(n.queue, r)
+	if len(n.queue) == 1 {
+		go n.retryQueue()
+	}
+}
+
+// retryQueue sends the queued requests in order until the queue is empty.
+// The delay between attempts doubles while OpsGenie keeps rate limiting
+// requests.  A queued request that fails for another reason is dropped, and
+// the check is forgotten so that its next run sends its state again.
+func (n *Notifier) retryQueue() {
+	delay := n.retryDelay
+	for {
+		time.Sleep(delay)
+
+		n.Lock()
+		r := n.queue[0]
+		n.Unlock()
+
+		err := n.send(r)
+		if err == errRateLimited {
+			delay *= 2
+			if delay > n.maxRetryDelay {
+				delay = n.maxRetryDelay
+			}
+			log.Warningln("OpsGenie rate limited the request for check", r.checkName+".  Retrying in", delay)
+			continue
+		}
+		delay = n.retryDelay
+
+		n.Lock()
+		if err != nil {
+			log.Errorln("Error sending queued OpsGenie request for check", r.checkName+":", err)
+			delete(n.failing, r.checkName)
+		}
+		n.queue = n.queue[1:]
+		if len(n.queue) == 0 {
+			n.Unlock()
+			return
+		}
+		n.Unlock()
+	}
+}
+
+// alias returns the alias that identifies the alert of a check in the form
+// kuberhealthy-{clusterName}-{checkName}.  The cluster name is left out when
+// it is not set.
+func (n *Notifier) alias(checkName string) string {
+	if len(n.clusterName) == 0 {
+		return "kuberhealthy-" + checkName
+	}
+	return "kuberhealthy-" + n.clusterName + "-" + checkName
+}
+
+// newAlert makes the alert of a failing check
+func (n *Notifier) newAlert(checkName string, namespace string, alias string, errs []string) alert {
+	message := "Kuberhealthy check " + checkName + " is failing"
+	if len(n.clusterName) > 0 {
+		message += " in cluster " + n.clusterName
+	}
+
+	description := strings.Join(errs, "\n")
+	if len(n.statusURL) > 0 {
+		description += "\n\nStatus page: " + n.statusURL
+	}
+
+	details := map[string]string{
+		"check": checkName,
+	}
+	if len(namespace) > 0 {
+		details["namespace"] = namespace
+	}
+	if len(n.clusterName) > 0 {
+		details["cluster"] = n.clusterName
+	}
+	if len(n.statusURL) > 0 {
+		details["statusPage"] = n.statusURL
+	}
+
+	a := alert{
+		Message:     truncate(message, maxMessageLength),
+		Alias:       alias,
+		Description: truncate(description, maxDescriptionLength),
+		Tags:        []string{"kuberhealthy"},
+		Details:     details,
+		Entity:      checkName,
+		Source:      "kuberhealthy",
+	}
+	if len(n.team) > 0 {
+		a.Responders = []responder{{Name: n.team, Type: "team"}}
+	}
+	return a
+}
+
+// truncate shortens s to at most max characters, marking it as truncated
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max-3]) + "..."
+}
+
+// send sends a request to the OpsGenie Alerts API.  errRateLimited is
+// returned when OpsGenie rate limits the request.
+func (n *Notifier) send(r request) error {
+	b, err := json.Marshal(r.body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, n.url+r.path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+n.apiKey)
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return errRateLimited
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("OpsGenie returned status " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package opsgenie
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sentRequest is a request received by the alert server
+type sentRequest struct {
+	path          string
+	authorization string
+	alert         alert
+}
+
+// alertServer records the requests sent to it.  The first rateLimited
+// requests are rejected with status 429.
+type alertServer struct {
+	sync.Mutex
+	status      int
+	rateLimited int
+	requests    []sentRequest
+}
+
+// ServeHTTP records a sent request
+func (s *alertServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	if s.rateLimited > 0 {
+		s.rateLimited--
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	sent := sentRequest{path: r.URL.RequestURI(), authorization: r.Header.Get("Authorization")}
+	json.NewDecoder(r.Body).Decode(&sent.alert)
+	s.requests = append(s.requests, sent)
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sent returns the requests recorded by the alert server
+func (s *alertServer) sent() []sentRequest {
+	s.Lock()
+	defer s.Unlock()
+	return append([]sentRequest{}, s.requests...)
+}
+
+// newTestNotifier creates a Notifier that sends requests to an alert server
+func newTestNotifier(t *testing.T, server *alertServer, clusterName string) *Notifier {
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	n, err := New(ts.URL, "4P1K3Y", "platform", clusterName, "http://kuberhealthy.kuberhealthy/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.retryDelay = time.Millisecond
+	return n
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("", " ", "", "", "")
+	if err == nil {
+		t.Fatal("Expected an error without an API key")
+	}
+	_, err = New("api.opsgenie.com", "4P1K3Y", "", "", "")
+	if err == nil {
+		t.Fatal("Expected an error for an API URL without a scheme")
+	}
+	n, err := New("", "4P1K3Y", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.url != DefaultAPIURL {
+		t.Fatal("Expected the API URL to default to", DefaultAPIURL, "but got", n.url)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	server := &alertServer{}
+	n := newTestNotifier(t, server, "prod-east")
+
+	// a check that is OK the first time it is seen closes any open alert
+	n.Notify("DaemonSetChecker", "kuberhealthy", true, []string{})
+	n.Notify("DaemonSetChecker", "kuberhealthy", true, []string{})
+	sent := server.sent()
+	if len(sent) != 1 || sent[0].path != "/v2/alerts/kuberhealthy-prod-east-DaemonSetChecker/close?identifierType=alias" {
+		t.Fatal("Expected 1 close request for an OK check but got", sent)
+	}
+
+	// a check that starts failing creates one alert while it keeps failing
+	err := n.Notify("PodStatusChecker", "kube-system", false, []string{"pod not ready"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Notify("PodStatusChecker", "kube-system", false, []string{"pod not ready"})
+	sent = server.sent()
+	if len(sent) != 2 {
+		t.Fatal("Expected 1 create request for a failing check but got", sent)
+	}
+	create := sent[1]
+	if create.path != "/v2/alerts" || create.authorization != "GenieKey 4P1K3Y" {
+		t.Fatal("Unexpected create request", create)
+	}
+	a := create.alert
+	if a.Alias != "kuberhealthy-prod-east-PodStatusChecker" || a.Message != "Kuberhealthy check PodStatusChecker is failing in cluster prod-east" {
+		t.Fatal("Unexpected alert", a)
+	}
+	if a.Description != "pod not ready\n\nStatus page: http://kuberhealthy.kuberhealthy/status" {
+		t.Fatal("Unexpected alert description", a.Description)
+	}
+	if len(a.Responders) != 1 || a.Responders[0].Name != "platform" || a.Responders[0].Type != "team" {
+		t.Fatal("Expected the alert to be routed to the platform team but got", a.Responders)
+	}
+	if a.Details["namespace"] != "kube-system" || a.Details["cluster"] != "prod-east" {
+		t.Fatal("Unexpected alert details", a.Details)
+	}
+
+	// a recovered check closes its alert once
+	n.Notify("PodStatusChecker", "kube-system", true, []string{})
+	n.Notify("PodStatusChecker", "kube-system", true, []string{})
+	sent = server.sent()
+	if len(sent) != 3 || sent[2].path != "/v2/alerts/kuberhealthy-prod-east-PodStatusChecker/close?identifierType=alias" {
+		t.Fatal("Expected a close request after recovery but got", sent)
+	}
+}
+
+func TestNotifySendFailure(t *testing.T) {
+	server := &alertServer{status: http.StatusUnauthorized}
+	n := newTestNotifier(t, server, "")
+
+	err := n.Notify("DNSChecker", "", false, []string{"lookup failed"})
+	if err == nil {
+		t.Fatal("Expected an error when OpsGenie rejects the request")
+	}
+
+	// the alert is created by the next run once OpsGenie accepts it
+	server.Lock()
+	server.status = 0
+	server.Unlock()
+	err = n.Notify("DNSChecker", "", false, []string{"lookup failed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := server.sent()
+	if len(sent) != 2 || sent[1].alert.Alias != "kuberhealthy-DNSChecker" {
+		t.Fatal("Expected the alert to be created again but got", sent)
+	}
+}
+
+func TestNotifyRateLimited(t *testing.T) {
+	server := &alertServer{rateLimited: 3}
+	n := newTestNotifier(t, server, "")
+
+	// rate limited requests are retried in the background in order
+	err := n.Notify("DNSChecker", "", false, []string{"lookup failed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Notify("DNSChecker", "", true, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sent []sentRequest
+	for i := 0; i < 100; i++ {
+		sent = server.sent()
+		if len(sent) == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if len(sent) != 2 || sent[0].path != "/v2/alerts" || !strings.HasSuffix(sent[1].path, "/close?identifierType=alias") {
+		t.Fatal("Expected the alert to be created and then closed but got", sent)
+	}
+}
+
+func TestMessageLength(t *testing.T) {
+	n, err := New("", "4P1K3Y", "", strings.Repeat("x", maxMessageLength), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := n.newAlert("DNSChecker", "", n.alias("DNSChecker"), []string{strings.Repeat("x", maxDescriptionLength)})
+	if len([]rune(a.Message)) != maxMessageLength || !strings.HasSuffix(a.Message, "...") {
+		t.Fatal("Expected the message to be truncated to", maxMessageLength, "characters but got", len(a.Message))
+	}
+	if len([]rune(a.Description)) != maxDescriptionLength {
+		t.Fatal("Expected the description to be truncated to", maxDescriptionLength, "characters but got", len(a.Description))
+	}
+}
@@ -0,0 +1,187 @@
+// Package pagerduty implements a notifier that opens a PagerDuty incident
+// through the Events v2 API when a check starts failing and resolves it when
+// the check recovers.
+package pagerduty // import "github.com/Comcast/kuberhealthy/pkg/notifiers/pagerduty"
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// eventsURL is the PagerDuty Events v2 API endpoint events are sent to
+const eventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// maxSummaryLength is the longest summary accepted by the Events v2 API
+const maxSummaryLength = 1024
+
+// event is an event as accepted by the PagerDuty Events v2 API
+type event struct {
+	RoutingKey  string   `json:"routing_key"`
+	EventAction string   `json:"event_action"`
+	DedupKey    string   `json:"dedup_key"`
+	Payload     *payload `json:"payload,omitempty"`
+}
+
+// payload holds the details of a trigger event
+type payload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Component     string            `json:"component"`
+	Group         string            `json:"group,omitempty"`
+	CustomDetails map[string]string `json:"custom_details"`
+}
+
+// Notifier opens a PagerDuty incident when a check starts failing and
+// resolves it when the check recovers.  Incidents are deduplicated by a key
+// made from the cluster name and check name, so repeated failures of a check
+// never open more than one incident and clusters can share a routing key.
+type Notifier struct {
+	sync.Mutex
+	url         string
+	routingKey  string
+	serviceName string
+	clusterName string
+	httpClient  *http.Client
+	failing     map[string]bool // the last state sent for each check
+}
+
+// New creates a Notifier that sends events with the integration routing key
+// of a PagerDuty service.  The service name is the source of every incident
+// and defaults to kuberhealthy.  The cluster name is included in the dedup
+// key and summary of every incident.
+func New(routingKey string, serviceName string, clusterName string) (*Notifier, error) {
+	routingKey = strings.TrimSpace(routingKey)
+	if len(routingKey) == 0 {
+		return nil, errors.New("a PagerDuty routing key is required")
+	}
+	if len(serviceName) == 0 {
+		serviceName = "kuberhealthy"
+	}
+
+	return &Notifier{
+		url:         eventsURL,
+		routingKey:  routingKey,
+		serviceName: serviceName,
+		clusterName: clusterName,
+		httpClient:  &http.Client{Timeout: time.Second * 10},
+		failing:     make(map[string]bool),
+	}, nil
+}
+
+// Notify opens an incident when a check starts failing and resolves it when
+// the check recovers.  A check that is OK the first time it is seen resolves
+// its incident, so that an incident left open by a restart is resolved.
+// When the event can not be sent, the state of the check is not changed so
+// that the next run sends it again.
+func (n *Notifier) Notify(checkName string, namespace string, ok bool, errs []string) error {
+	n.Lock()
+	wasFailing, seen := n.failing[checkName]
+	if seen && wasFailing == !ok {
+		n.Unlock()
+		return nil
+	}
+	n.failing[checkName] = !ok
+	n.Unlock()
+
+	e := event{
+		RoutingKey:  n.routingKey,
+		EventAction: "resolve",
+		DedupKey:    n.dedupKey(checkName),
+	}
+	if !ok {
+		log.Infoln("Opening PagerDuty incident for failing check", checkName)
+		e.EventAction = "trigger"
+		e.Payload = n.newPayload(checkName, namespace, errs)
+	} else if seen {
+		log.Infoln("Resolving PagerDuty incident for recovered check", checkName)
+	}
+
+	err := n.send(e)
+	if err != nil {
+		n.Lock()
+		if seen {
+			n.failing[checkName] = wasFailing
+		} else {
+			delete(n.failing, checkName)
+		}
+		n.Unlock()
+		return errors.New("Error sending PagerDuty " + e.EventAction + " event for check " + checkName + ": " + err.Error())
+	}
+	return nil
+}
+
+// dedupKey returns the key that identifies the incident of a check in the
+// form kuberhealthy-{clusterName}-{checkName}.  The cluster name is left out
+// when it is not set.
+func (n *Notifier) dedupKey(checkName string) string {
+	if len(n.clusterName) == 0 {
+		return "kuberhealthy-" + checkName
+	}
+	return "kuberhealthy-" + n.clusterName + "-" + checkName
+}
+
+// newPayload makes the payload of the incident of a failing check
+func (n *Notifier) newPayload(checkName string, namespace string, errs []string) *payload {
+	summary := "Kuberhealthy check " + checkName + " is failing"
+	if len(n.clusterName) > 0 {
+		summary += " in cluster " + n.clusterName
+	}
+	if len(errs) > 0 {
+		summary += ": " + strings.Join(errs, "; ")
+	}
+	if r := []rune(summary); len(r) > maxSummaryLength {
+		summary = string(r[:maxSummaryLength-3]) + "..."
+	}
+
+	details := map[string]string{
+		"check":  checkName,
+		"errors": strings.Join(errs, "\n"),
+	}
+	if len(namespace) > 0 {
+		details["namespace"] = namespace
+	}
+	if len(n.clusterName) > 0 {
+		details["cluster"] = n.clusterName
+	}
+
+	return &payload{
+		Summary:       summary,
+		Source:        n.serviceName,
+		Severity:      "critical",
+		Component:     checkName,
+		Group:         n.clusterName,
+		CustomDetails: details,
+	}
+}
+
+// send sends an event to the PagerDuty Events v2 API
+func (n *Notifier) send(e event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("PagerDuty returned status " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package pagerduty
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// eventServer records the events sent to it
+type eventServer struct {
+	sync.Mutex
+	status int
+	events []event
+}
+
+// ServeHTTP records a sent event
+func (s *eventServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	var e event
+	json.NewDecoder(r.Body).Decode(&e)
+	s.events = append(s.events, e)
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// newTestNotifier creates a Notifier that sends events to an event server
+func newTestNotifier(t *testing.T, server *eventServer, clusterName string) *Notifier {
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	n, err := New("R0UT1NGK3Y", "", clusterName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.url = ts.URL
+	return n
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(" ", "", "")
+	if err == nil {
+		t.Fatal("Expected an error without a routing key")
+	}
+	n, err := New("R0UT1NGK3Y", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.serviceName != "kuberhealthy" {
+		t.Fatal("Expected the service name to default to kuberhealthy but got", n.serviceName)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	server := &eventServer{}
+	n := newTestNotifier(t, server, "prod-east")
+
+	// a check that is OK the first time it is seen resolves any open incident
+	n.Notify("DaemonSetChecker", "kuberhealthy", true, []string{})
+	n.Notify("DaemonSetChecker", "kuberhealthy", true, []string{})
+	if len(server.events) != 1 || server.events[0].EventAction != "resolve" || server.events[0].Payload != nil {
+		t.Fatal("Expected 1 resolve event for an OK check but got", server.events)
+	}
+
+	// a check that starts failing opens one incident while it keeps failing
+	err := n.Notify("PodStatusChecker", "kube-system", false, []string{"pod not ready"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Notify("PodStatusChecker", "kube-system", false, []string{"pod not ready"})
+	if len(server.events) != 2 {
+		t.Fatal("Expected 1 trigger event for a failing check but got", server.events)
+	}
+	trigger := server.events[1]
+	if trigger.EventAction != "trigger" || trigger.RoutingKey != "R0UT1NGK3Y" || trigger.DedupKey != "kuberhealthy-prod-east-PodStatusChecker" {
+		t.Fatal("Unexpected trigger event", trigger)
+	}
+	p := trigger.Payload
+	if p == nil || p.Summary != "Kuberhealthy check PodStatusChecker is failing in cluster prod-east: pod not ready" {
+		t.Fatal("Unexpected trigger event payload", p)
+	}
+	if p.Source != "kuberhealthy" || p.Severity != "critical" || p.CustomDetails["namespace"] != "kube-system" {
+		t.Fatal("Unexpected trigger event payload", p)
+	}
+
+	// a recovered check resolves its incident once
+	n.Notify("PodStatusChecker", "kube-system", true, []string{})
+	n.Notify("PodStatusChecker", "kube-system", true, []string{})
+	if len(server.events) != 3 {
+		t.Fatal("Expected 3 events after recovery but got", server.events)
+	}
+	resolve := server.events[2]
+	if resolve.EventAction != "resolve" || resolve.DedupKey != trigger.DedupKey {
+		t.Fatal("Unexpected resolve event", resolve)
+	}
+}
+
+func TestNotifySendFailure(t *testing.T) {
+	server := &eventServer{status: http.StatusTooManyRequests}
+	n := newTestNotifier(t, server, "")
+
+	err := n.Notify("DNSChecker", "", false, []string{"lookup failed"})
+	if err == nil {
+		t.Fatal("Expected an error when PagerDuty rejects the event")
+	}
+
+	// the event is sent again by the next run once PagerDuty accepts it
+	server.status = 0
+	err = n.Notify("DNSChecker", "", false, []string{"lookup failed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(server.events) != 2 || server.events[1].DedupKey != "kuberhealthy-DNSChecker" {
+		t.Fatal("Expected the trigger event to be sent again but got", server.events)
+	}
+}
+
+func TestSummaryLength(t *testing.T) {
+	n, err := New("R0UT1NGK3Y", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := n.newPayload("DNSChecker", "", []string{strings.Repeat("x", maxSummaryLength)})
+	if len([]rune(p.Summary)) != maxSummaryLength || !strings.HasSuffix(p.Summary, "...") {
+		t.Fatal("Expected the summary to be truncated to", maxSummaryLength, "characters but got", len(p.Summary))
+	}
+}
@@ -0,0 +1,181 @@
+// Package slack implements a notifier that posts a message to a Slack
+// incoming webhook when a check starts failing or recovers.
+package slack // import "github.com/Comcast/kuberhealthy/pkg/notifiers/slack"
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxErrorLength is the number of characters of check errors included in a
+// message before they are truncated
+const maxErrorLength = 500
+
+// message is a message as accepted by Slack incoming webhooks
+type message struct {
+	Channel     string       `json:"channel,omitempty"`
+	Username    string       `json:"username"`
+	Text        string       `json:"text"`
+	Attachments []attachment `json:"attachments"`
+}
+
+// attachment holds the details of a message
+type attachment struct {
+	Color  string  `json:"color"`
+	Text   string  `json:"text,omitempty"`
+	Fields []field `json:"fields"`
+}
+
+// field is a short titled value shown in an attachment
+type field struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// Notifier posts a message to Slack when a check changes between OK and
+// failing.  Runs of a check that do not change its state post nothing, so
+// a check that keeps failing is only reported once.
+type Notifier struct {
+	sync.Mutex
+	url         string
+	channel     string
+	clusterName string
+	statusURL   string
+	httpClient  *http.Client
+	failing     map[string]bool // the last state reported for each check
+}
+
+// New creates a Notifier that posts to the Slack incoming webhook at url.
+// When channel is set, messages are posted to it instead of the webhook's
+// default channel.  The cluster name and a link to the Kuberhealthy status
+// page at statusURL are included in every message.
+func New(url string, channel string, clusterName string, statusURL string) (*Notifier, error) {
+	url = strings.TrimSpace(url)
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil, errors.New("the Slack webhook URL must be an http or https URL")
+	}
+
+	return &Notifier{
+		url:         url,
+		channel:     channel,
+		clusterName: clusterName,
+		statusURL:   statusURL,
+		httpClient:  &http.Client{Timeout: time.Second * 10},
+		failing:     make(map[string]bool),
+	}, nil
+}
+
+// Notify posts a message when a check starts failing or recovers.  A check
+// that is OK the first time it is seen posts nothing.  When the message can
+// not be posted, the state of the check is not changed so that the next run
+// posts it again.
+func (n *Notifier) Notify(checkName string, namespace string, ok bool, errs []string) error {
+	n.Lock()
+	wasFailing := n.failing[checkName]
+	if wasFailing == !ok {
+		n.Unlock()
+		return nil
+	}
+	n.failing[checkName] = !ok
+	n.Unlock()
+
+	if ok {
+		log.Infoln("Posting Slack message for recovered check", checkName)
+	} else {
+		log.Infoln("Posting Slack message for failing check", checkName)
+	}
+
+	err := n.post(n.newMessage(checkName, namespace, ok, errs))
+	if err != nil {
+		n.Lock()
+		n.failing[checkName] = wasFailing
+		n.Unlock()
+		return errors.New("Error posting Slack message for check " + checkName + ": " + err.Error())
+	}
+	return nil
+}
+
+// newMessage makes the message for a check that changed state
+func (n *Notifier) newMessage(checkName string, namespace string, ok bool, errs []string) message {
+	status := "Failing"
+	color := "danger"
+	text := "Kuberhealthy check *" + checkName + "* is failing"
+	if ok {
+		status = "OK"
+		color = "good"
+		text = "Kuberhealthy check *" + checkName + "* has recovered"
+	}
+	if len(n.clusterName) > 0 {
+		text += " in cluster *" + n.clusterName + "*"
+	}
+
+	fields := []field{
+		{Title: "Check", Value: checkName, Short: true},
+		{Title: "Status", Value: status, Short: true},
+	}
+	if len(namespace) > 0 {
+		fields = append(fields, field{Title: "Namespace", Value: namespace, Short: true})
+	}
+	if len(n.clusterName) > 0 {
+		fields = append(fields, field{Title: "Cluster", Value: n.clusterName, Short: true})
+	}
+	if len(n.statusURL) > 0 {
+		fields = append(fields, field{Title: "Status Page", Value: "<" + n.statusURL + ">", Short: false})
+	}
+
+	a := attachment{
+		Color:  color,
+		Fields: fields,
+	}
+	if !ok {
+		a.Text = truncate(strings.Join(errs, "\n"), maxErrorLength)
+	}
+
+	return message{
+		Channel:     n.channel,
+		Username:    "Kuberhealthy",
+		Text:        text,
+		Attachments: []attachment{a},
+	}
+}
+
+// truncate shortens s to at most max characters, marking it as truncated
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max]) + "... (truncated)"
+}
+
+// post sends a message to the Slack webhook
+func (n *Notifier) post(m message) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("Slack returned status " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
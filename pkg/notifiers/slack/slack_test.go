@@ -0,0 +1,129 @@
+package slack
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// slackServer records the messages posted to it
+type slackServer struct {
+	sync.Mutex
+	status   int
+	messages []message
+}
+
+// ServeHTTP records a posted message
+func (s *slackServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	var m message
+	json.NewDecoder(r.Body).Decode(&m)
+	s.messages = append(s.messages, m)
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("hooks.slack.com/services/T0/B0/X", "", "", "")
+	if err == nil {
+		t.Fatal("Expected an error for a URL without a scheme")
+	}
+	_, err = New("https://hooks.slack.com/services/T0/B0/X", "#ops", "prod", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	server := &slackServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	n, err := New(ts.URL, "#ops", "prod-east", "http://kuberhealthy.kuberhealthy/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a check that is OK the first time it is seen posts nothing
+	n.Notify("DaemonSetChecker", "kuberhealthy", true, []string{})
+	if len(server.messages) != 0 {
+		t.Fatal("Expected no messages for an OK check but got", server.messages)
+	}
+
+	// a check that starts failing posts once while it keeps failing
+	err = n.Notify("PodStatusChecker", "kube-system", false, []string{"pod not ready"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Notify("PodStatusChecker", "kube-system", false, []string{"pod not ready"})
+	if len(server.messages) != 1 {
+		t.Fatal("Expected 1 message for a failing check but got", server.messages)
+	}
+	failing := server.messages[0]
+	if failing.Channel != "#ops" || !strings.Contains(failing.Text, "PodStatusChecker* is failing in cluster *prod-east*") {
+		t.Fatal("Unexpected failing message", failing)
+	}
+	if failing.Attachments[0].Color != "danger" || failing.Attachments[0].Text != "pod not ready" {
+		t.Fatal("Unexpected failing message attachment", failing.Attachments)
+	}
+	fields := map[string]string{}
+	for _, f := range failing.Attachments[0].Fields {
+		fields[f.Title] = f.Value
+	}
+	if fields["Namespace"] != "kube-system" || fields["Cluster"] != "prod-east" || fields["Status Page"] != "<http://kuberhealthy.kuberhealthy/status>" {
+		t.Fatal("Unexpected failing message fields", fields)
+	}
+
+	// a recovered check posts once
+	n.Notify("PodStatusChecker", "kube-system", true, []string{})
+	n.Notify("PodStatusChecker", "kube-system", true, []string{})
+	if len(server.messages) != 2 {
+		t.Fatal("Expected 2 messages after recovery but got", server.messages)
+	}
+	recovered := server.messages[1]
+	if recovered.Attachments[0].Color != "good" || !strings.Contains(recovered.Text, "has recovered") {
+		t.Fatal("Unexpected recovered message", recovered)
+	}
+}
+
+func TestNotifyPostFailure(t *testing.T) {
+	server := &slackServer{status: http.StatusInternalServerError}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	n, err := New(ts.URL, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = n.Notify("DNSChecker", "", false, []string{"lookup failed"})
+	if err == nil {
+		t.Fatal("Expected an error when Slack rejects the message")
+	}
+
+	// the failure is posted again by the next run once Slack accepts it
+	server.status = 0
+	err = n.Notify("DNSChecker", "", false, []string{"lookup failed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(server.messages) != 2 {
+		t.Fatal("Expected the failing message to be posted again but got", server.messages)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	long := strings.Repeat("x", maxErrorLength+10)
+	truncated := truncate(long, maxErrorLength)
+	if truncated != strings.Repeat("x", maxErrorLength)+"... (truncated)" {
+		t.Fatal("Unexpected truncated errors", truncated)
+	}
+	if truncate("short", maxErrorLength) != "short" {
+		t.Fatal("Expected short errors to be unchanged")
+	}
+}
@@ -0,0 +1,320 @@
+// Package podRunner runs short lived scripts in a single pod or on every node
+// of the cluster with a DaemonSet and collects the output of each pod from its
+// logs.  Checks use this to inspect state that is not exposed through the
+// Kubernetes API.
+package podRunner // import "github.com/Comcast/kuberhealthy/pkg/podRunner"
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DoneMarker is written to the log of each pod after its script completes
+const DoneMarker = "kuberhealthy-podrunner-done"
+
+// pollInterval is how often pods are checked for completed output
+const pollInterval = time.Second * 5
+
+// Script describes a script to run in a pod
+type Script struct {
+	// Name is used as the base name of the pod or DaemonSet
+	Name string
+	// Image is the container image the script runs in.  It must provide sh.
+	Image string
+	// Script is run with sh -c
+	Script string
+	// HostPID runs the pod in the host's PID namespace
+	HostPID bool
+	// HostNetwork runs the pod in the host's network namespace
+	HostNetwork bool
+	// Privileged runs the container as privileged
+	Privileged bool
+	// HostPaths maps paths on the host to read only mount paths in the container
+	HostPaths map[string]string
+	// ServiceAccount runs the pod as a service account other than the
+	// namespace default
+	ServiceAccount string
+	// NodeName pins a pod run with RunPod to a node
+	NodeName string
+	// Labels are added to the pod labels, such as to match the pod selector
+	// of a NetworkPolicy.  They can not replace the labels used to find the
+	// pods.
+	Labels map[string]string
+}
+
+// RunOnNodes deploys a DaemonSet that runs the script on every node,
+// including tainted nodes, and returns the output of each node's pod keyed by
+// node name.  The DaemonSet is removed before returning.  If the timeout is
+// reached, the output collected so far is returned along with an error.
+func RunOnNodes(client *kubernetes.Clientset, namespace string, script Script, timeout time.Duration) (map[string]string, error) {
+	results := make(map[string]string)
+
+	ds := daemonSetSpec(script)
+	dsClient := client.AppsV1().DaemonSets(namespace)
+	_, err := dsClient.Create(ds)
+	if err != nil {
+		return results, errors.New("Error creating DaemonSet " + ds.Name + ": " + err.Error())
+	}
+	log.Debugln("Created DaemonSet", ds.Name, "in namespace", namespace)
+
+	defer func() {
+		propagationForeground := metav1.DeletePropagationForeground
+		err := dsClient.Delete(ds.Name, &metav1.DeleteOptions{PropagationPolicy: &propagationForeground})
+		if err != nil {
+			log.Errorln("Error removing DaemonSet", ds.Name+":", err)
+		}
+	}()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		current, err := dsClient.Get(ds.Name, metav1.GetOptions{})
+		if err != nil {
+			return results, errors.New("Error getting DaemonSet " + ds.Name + ": " + err.Error())
+		}
+
+		pods, err := client.CoreV1().Pods(namespace).List(metav1.ListOptions{
+			LabelSelector: "app=" + ds.Name,
+		})
+		if err != nil {
+			return results, errors.New("Error listing pods of DaemonSet " + ds.Name + ": " + err.Error())
+		}
+
+		for _, p := range pods.Items {
+			if _, done := results[p.Spec.NodeName]; done || p.Status.Phase != apiv1.PodRunning {
+				continue
+			}
+			b, err := client.CoreV1().Pods(namespace).GetLogs(p.Name, &apiv1.PodLogOptions{}).DoRaw()
+			if err != nil {
+				log.Debugln("Error fetching logs of pod", p.Name+":", err)
+				continue
+			}
+			output, done := ParseOutput(string(b))
+			if done {
+				results[p.Spec.NodeName] = output
+			}
+		}
+
+		desired := int(current.Status.DesiredNumberScheduled)
+		if desired > 0 && len(results) >= desired {
+			return results, nil
+		}
+
+		if time.Now().After(deadline) {
+			return results, errors.New("Timed out waiting for DaemonSet " + ds.Name + " to report from all nodes. " +
+				strconv.Itoa(len(results)) + " of " + strconv.Itoa(desired) + " nodes reported: " + strings.Join(nodeNames(results), ", "))
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// RunPod runs the script in a single pod and returns its output.  The pod is
+// removed before returning.
+func RunPod(client *kubernetes.Clientset, namespace string, script Script, timeout time.Duration) (string, error) {
+	pod := podSpec(script)
+	podClient := client.CoreV1().Pods(namespace)
+	_, err := podClient.Create(pod)
+	if err != nil {
+		return "", errors.New("Error creating pod " + pod.Name + ": " + err.Error())
+	}
+	log.Debugln("Created pod", pod.Name, "in namespace", namespace)
+
+	defer func() {
+		err := podClient.Delete(pod.Name, &metav1.DeleteOptions{})
+		if err != nil {
+			log.Errorln("Error removing pod", pod.Name+":", err)
+		}
+	}()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		current, err := podClient.Get(pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", errors.New("Error getting pod " + pod.Name + ": " + err.Error())
+		}
+
+		switch current.Status.Phase {
+		case apiv1.PodFailed, apiv1.PodSucceeded:
+			return "", errors.New("Pod " + pod.Name + " exited before its script completed with phase " + string(current.Status.Phase))
+		case apiv1.PodRunning:
+			b, err := podClient.GetLogs(pod.Name, &apiv1.PodLogOptions{}).DoRaw()
+			if err != nil {
+				log.Debugln("Error fetching logs of pod", pod.Name+":", err)
+				break
+			}
+			output, done := ParseOutput(string(b))
+			if done {
+				return output, nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return "", errors.New("Timed out waiting for pod " + pod.Name + " to complete its script. Pod phase is " + string(current.Status.Phase))
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// ParseOutput returns the script output from a pod log and whether the
+// script has completed
+func ParseOutput(podLog string) (string, bool) {
+	i := strings.Index(podLog, DoneMarker)
+	if i < 0 {
+		return podLog, false
+	}
+	return strings.TrimSpace(podLog[:i]), true
+}
+
+// RunPodRBACRules returns the RBAC rules RunPod needs in the namespace its
+// pod runs in
+func RunPodRBACRules() []rbacv1.PolicyRule {
+	return []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"create", "delete", "get"}},
+		{APIGroups: []string{""}, Resources: []string{"pods/log"}, Verbs: []string{"get"}},
+	}
+}
+
+// RunOnNodesRBACRules returns the RBAC rules RunOnNodes needs in the
+// namespace its DaemonSet runs in
+func RunOnNodesRBACRules() []rbacv1.PolicyRule {
+	return []rbacv1.PolicyRule{
+		{APIGroups: []string{"apps"}, Resources: []string{"daemonsets"}, Verbs: []string{"create", "delete", "get"}},
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		{APIGroups: []string{""}, Resources: []string{"pods/log"}, Verbs: []string{"get"}},
+	}
+}
+
+// daemonSetSpec builds the DaemonSet that runs the script on every node,
+// including tainted nodes
+func daemonSetSpec(script Script) *appsv1.DaemonSet {
+	pod := podSpec(script)
+	// the DaemonSet controller places a pod on every node
+	pod.Spec.NodeName = ""
+	pod.Spec.Tolerations = []apiv1.Toleration{
+		{Operator: apiv1.TolerationOpExists},
+	}
+
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   pod.Name,
+			Labels: pod.Labels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: pod.Labels,
+			},
+			Template: apiv1.PodTemplateSpec{
+				ObjectMeta: pod.ObjectMeta,
+				Spec:       pod.Spec,
+			},
+		},
+	}
+}
+
+// podSpec builds a pod that runs the script.  The container sleeps after the
+// script completes so that it is not restarted.
+func podSpec(script Script) *apiv1.Pod {
+	hostname := getHostname()
+	name := script.Name + "-" + strconv.Itoa(int(time.Now().Unix()))
+	terminationGracePeriod := int64(1)
+
+	labels := map[string]string{}
+	for k, v := range script.Labels {
+		labels[k] = v
+	}
+	labels["app"] = name
+	labels["source"] = "kuberhealthy"
+	labels["creatingInstance"] = hostname
+
+	var volumes []apiv1.Volume
+	var mounts []apiv1.VolumeMount
+	var hostPaths []string
+	for hostPath := range script.HostPaths {
+		hostPaths = append(hostPaths, hostPath)
+	}
+	sort.Strings(hostPaths)
+	for i, hostPath := range hostPaths {
+		volumeName := "host-" + strconv.Itoa(i)
+		volumes = append(volumes, apiv1.Volume{
+			Name:         volumeName,
+			VolumeSource: apiv1.VolumeSource{HostPath: &apiv1.HostPathVolumeSource{Path: hostPath}},
+		})
+		mounts = append(mounts, apiv1.VolumeMount{Name: volumeName, MountPath: script.HostPaths[hostPath], ReadOnly: true})
+	}
+
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: labels,
+			Name:   name,
+		},
+		Spec: apiv1.PodSpec{
+			TerminationGracePeriodSeconds: &terminationGracePeriod,
+			HostPID:                       script.HostPID,
+			HostNetwork:                   script.HostNetwork,
+			ServiceAccountName:            script.ServiceAccount,
+			NodeName:                      script.NodeName,
+			Volumes:                       volumes,
+			Containers: []apiv1.Container{
+				{
+					Name:         "runner",
+					Image:        script.Image,
+					Command:      []string{"sh", "-c", script.Script + "\necho " + DoneMarker + "\nsleep 3600"},
+					VolumeMounts: mounts,
+					SecurityContext: &apiv1.SecurityContext{
+						Privileged: &script.Privileged,
+					},
+					Resources: apiv1.ResourceRequirements{
+						Requests: apiv1.ResourceList{
+							apiv1.ResourceCPU:    resource.MustParse("0"),
+							apiv1.ResourceMemory: resource.MustParse("0"),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// nodeNames returns the sorted node names of results
+func nodeNames(results map[string]string) []string {
+	var names []string
+	for n := range results {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CurrentServiceAccount returns the service account of the running
+// kuberhealthy pod so that scripts can call APIs with its permissions.  It
+// needs the get verb on pods in namespace.
+func CurrentServiceAccount(client *kubernetes.Clientset, namespace string) (string, error) {
+	pod, err := client.CoreV1().Pods(namespace).Get(getHostname(), metav1.GetOptions{})
+	if err != nil {
+		return "", errors.New("Error getting the kuberhealthy pod to find its service account: " + err.Error())
+	}
+	return pod.Spec.ServiceAccountName, nil
+}
+
+// getHostname returns the hostname of the running kuberhealthy pod
+func getHostname() string {
+	defaultHostname := "kuberhealthy"
+	host, err := os.Hostname()
+	if len(host) == 0 || err != nil {
+		log.Warningln("Unable to determine hostname! Using default placeholder:", defaultHostname)
+		return defaultHostname // default if no hostname can be found
+	}
+	return strings.ToLower(host)
+}
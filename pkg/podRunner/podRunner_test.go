@@ -0,0 +1,61 @@
+package podRunner
+
+import (
+	"testing"
+)
+
+func TestParseOutput(t *testing.T) {
+	output, done := ParseOutput("containerd active running\nkubelet active running\n" + DoneMarker + "\n")
+	if !done {
+		t.Fatal("Expected output to be complete")
+	}
+	if output != "containerd active running\nkubelet active running" {
+		t.Fatal("Unexpected output:", output)
+	}
+
+	_, done = ParseOutput("containerd active running\n")
+	if done {
+		t.Fatal("Expected output without the done marker to be incomplete")
+	}
+}
+
+func TestDaemonSetSpec(t *testing.T) {
+	ds := daemonSetSpec(Script{
+		Name:      "node-test",
+		Image:     "busybox",
+		Script:    "echo hello",
+		HostPID:   true,
+		HostPaths: map[string]string{"/var/lib/kubelet": "/host/kubelet", "/etc": "/host/etc"},
+	})
+
+	if ds.Labels["source"] != "kuberhealthy" || ds.Spec.Selector.MatchLabels["app"] != ds.Name {
+		t.Fatal("DaemonSet is missing kuberhealthy labels:", ds.Labels)
+	}
+	spec := ds.Spec.Template.Spec
+	if !spec.HostPID || spec.HostNetwork {
+		t.Fatal("Unexpected host namespaces on DaemonSet pod spec")
+	}
+	if len(spec.Tolerations) != 1 || spec.Tolerations[0].Operator != "Exists" {
+		t.Fatal("Expected DaemonSet to tolerate all taints but got", spec.Tolerations)
+	}
+	if len(spec.Volumes) != 2 || spec.Volumes[0].HostPath.Path != "/etc" || spec.Containers[0].VolumeMounts[0].MountPath != "/host/etc" {
+		t.Fatal("Unexpected host path volumes:", spec.Volumes, spec.Containers[0].VolumeMounts)
+	}
+	t.Log(spec.Containers[0].Command)
+}
+
+func TestPodSpecLabels(t *testing.T) {
+	pod := podSpec(Script{
+		Name:   "label-test",
+		Image:  "busybox",
+		Script: "echo hello",
+		Labels: map[string]string{"role": "frontend", "app": "other"},
+	})
+
+	if pod.Labels["role"] != "frontend" {
+		t.Fatal("Expected script labels on pod but got", pod.Labels)
+	}
+	if pod.Labels["app"] != pod.Name || pod.Labels["source"] != "kuberhealthy" {
+		t.Fatal("Script labels replaced the kuberhealthy labels of the pod:", pod.Labels)
+	}
+}
@@ -0,0 +1,97 @@
+package promParser
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Bucket is a cumulative histogram bucket
+type Bucket struct {
+	UpperBound float64
+	Count      float64
+}
+
+// Histogram is the cumulative buckets of one histogram series sorted by upper
+// bound
+type Histogram []Bucket
+
+// ParseHistograms groups the bucket samples of a histogram metric by the
+// value of a label
+func ParseHistograms(samples []Sample, label string) map[string]Histogram {
+	histograms := make(map[string]Histogram)
+	for _, s := range samples {
+		upperBound, err := strconv.ParseFloat(s.Labels["le"], 64)
+		if err != nil {
+			continue
+		}
+		key := s.Labels[label]
+		histograms[key] = append(histograms[key], Bucket{UpperBound: upperBound, Count: s.Value})
+	}
+	for _, h := range histograms {
+		sort.Slice(h, func(i, j int) bool {
+			return h[i].UpperBound < h[j].UpperBound
+		})
+	}
+	return histograms
+}
+
+// Count returns the total number of observations in the histogram
+func (h Histogram) Count() float64 {
+	if len(h) == 0 {
+		return 0
+	}
+	return h[len(h)-1].Count
+}
+
+// Since returns the observations made since a previous reading of the same
+// histogram.  The current histogram is returned when the previous reading
+// does not match, such as after a component restart resets its counters.
+func (h Histogram) Since(previous Histogram) Histogram {
+	if len(previous) != len(h) || previous.Count() > h.Count() {
+		return h
+	}
+	delta := make(Histogram, len(h))
+	for i := range h {
+		if h[i].UpperBound != previous[i].UpperBound {
+			return h
+		}
+		delta[i] = Bucket{UpperBound: h[i].UpperBound, Count: h[i].Count - previous[i].Count}
+	}
+	return delta
+}
+
+// Quantile estimates a quantile of the histogram by linear interpolation
+// within the bucket that contains it, the same way Prometheus'
+// histogram_quantile does.  NaN is returned for an empty histogram.
+func (h Histogram) Quantile(q float64) float64 {
+	total := h.Count()
+	if total == 0 {
+		return math.NaN()
+	}
+
+	rank := q * total
+	for i, b := range h {
+		if b.Count < rank {
+			continue
+		}
+		// the quantile is above the highest finite bucket
+		if math.IsInf(b.UpperBound, 1) {
+			if i == 0 {
+				return math.NaN()
+			}
+			return h[i-1].UpperBound
+		}
+
+		var lowerBound, lowerCount float64
+		if i > 0 {
+			lowerBound = h[i-1].UpperBound
+			lowerCount = h[i-1].Count
+		}
+		if b.Count == lowerCount {
+			return b.UpperBound
+		}
+		return lowerBound + (b.UpperBound-lowerBound)*(rank-lowerCount)/(b.Count-lowerCount)
+	}
+	return h[len(h)-1].UpperBound
+}
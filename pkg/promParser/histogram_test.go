@@ -0,0 +1,52 @@
+package promParser
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuantile(t *testing.T) {
+	h := Histogram{
+		{UpperBound: 1, Count: 50},
+		{UpperBound: 2, Count: 100},
+		{UpperBound: math.Inf(1), Count: 100},
+	}
+	if q := h.Quantile(0.5); q != 1 {
+		t.Fatal("Expected p50 of 1 but got", q)
+	}
+	if q := h.Quantile(0.75); q != 1.5 {
+		t.Fatal("Expected p75 of 1.5 but got", q)
+	}
+	if q := h.Quantile(0.25); q != 0.5 {
+		t.Fatal("Expected p25 of 0.5 but got", q)
+	}
+
+	// observations above the highest finite bucket report its bound
+	h = Histogram{
+		{UpperBound: 1, Count: 50},
+		{UpperBound: math.Inf(1), Count: 100},
+	}
+	if q := h.Quantile(0.99); q != 1 {
+		t.Fatal("Expected p99 of 1 but got", q)
+	}
+
+	if q := (Histogram{}).Quantile(0.5); !math.IsNaN(q) {
+		t.Fatal("Expected NaN for an empty histogram but got", q)
+	}
+}
+
+func TestSince(t *testing.T) {
+	previous := Histogram{{UpperBound: 1, Count: 10}, {UpperBound: math.Inf(1), Count: 20}}
+	current := Histogram{{UpperBound: 1, Count: 15}, {UpperBound: math.Inf(1), Count: 40}}
+
+	delta := current.Since(previous)
+	if delta[0].Count != 5 || delta[1].Count != 20 {
+		t.Fatal("Unexpected histogram delta:", delta)
+	}
+
+	// a counter reset uses the current histogram
+	reset := Histogram{{UpperBound: 1, Count: 1}, {UpperBound: math.Inf(1), Count: 2}}
+	if delta := reset.Since(previous); delta.Count() != 2 {
+		t.Fatal("Expected the current histogram after a reset but got", delta)
+	}
+}
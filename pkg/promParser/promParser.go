@@ -0,0 +1,153 @@
+// Package promParser parses the Prometheus text exposition format served on
+// the /metrics endpoints of Kubernetes components.
+package promParser // import "github.com/Comcast/kuberhealthy/pkg/promParser"
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Sample is a single metric sample
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// Parse reads every sample from Prometheus text format.  Comments, type
+// hints, and timestamps are ignored.
+func Parse(r io.Reader) ([]Sample, error) {
+	var samples []Sample
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var lineNumber int
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sample, err := parseLine(line)
+		if err != nil {
+			return samples, errors.New("Error parsing metrics line " + strconv.Itoa(lineNumber) + ": " + err.Error())
+		}
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+// Filter returns the samples with the supplied metric name
+func Filter(samples []Sample, name string) []Sample {
+	var filtered []Sample
+	for _, s := range samples {
+		if s.Name == name {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+// FilterPrefix returns the samples with metric names beginning with prefix
+func FilterPrefix(samples []Sample, prefix string) []Sample {
+	var filtered []Sample
+	for _, s := range samples {
+		if strings.HasPrefix(s.Name, prefix) {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+// parseLine parses a single sample line such as
+// apiserver_request_count{verb="GET",code="200"} 42 1549400000000
+func parseLine(line string) (Sample, error) {
+	sample := Sample{Labels: make(map[string]string)}
+
+	var rest string
+	brace := strings.Index(line, "{")
+	space := strings.IndexAny(line, " \t")
+	if brace >= 0 && (space < 0 || brace < space) {
+		sample.Name = line[:brace]
+		var err error
+		rest, err = parseLabels(line[brace+1:], sample.Labels)
+		if err != nil {
+			return sample, err
+		}
+	} else {
+		if space < 0 {
+			return sample, errors.New("no value found")
+		}
+		sample.Name = line[:space]
+		rest = line[space:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return sample, errors.New("no value found for " + sample.Name)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, errors.New("invalid value for " + sample.Name + ": " + err.Error())
+	}
+	sample.Value = value
+	return sample, nil
+}
+
+// parseLabels reads label pairs into labels until the closing brace and
+// returns the remainder of the line
+func parseLabels(s string, labels map[string]string) (string, error) {
+	i := 0
+	for {
+		// skip separators
+		for i < len(s) && (s[i] == ',' || s[i] == ' ') {
+			i++
+		}
+		if i >= len(s) {
+			return "", errors.New("unterminated label set")
+		}
+		if s[i] == '}' {
+			return s[i+1:], nil
+		}
+
+		eq := strings.Index(s[i:], "=")
+		if eq < 0 {
+			return "", errors.New("label without value")
+		}
+		key := strings.TrimSpace(s[i : i+eq])
+		i += eq + 1
+		if i >= len(s) || s[i] != '"' {
+			return "", errors.New("label " + key + " value is not quoted")
+		}
+		i++
+
+		var value strings.Builder
+		for {
+			if i >= len(s) {
+				return "", errors.New("unterminated value for label " + key)
+			}
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				i++
+				continue
+			}
+			if c == '"' {
+				i++
+				break
+			}
+			value.WriteByte(c)
+			i++
+		}
+		labels[key] = value.String()
+	}
+}
@@ -0,0 +1,56 @@
+package promParser
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const metricsText = `# HELP apiserver_watch_events_sizes [ALPHA] Watch event size distribution in bytes
+# TYPE apiserver_watch_events_sizes histogram
+apiserver_watch_events_sizes_bucket{group="",kind="Pod",version="v1",le="1024"} 10
+apiserver_watch_events_sizes_bucket{group="",kind="Pod",version="v1",le="+Inf"} 12
+apiserver_watch_events_sizes_sum{group="",kind="Pod",version="v1"} 9000
+apiserver_watch_events_sizes_count{group="",kind="Pod",version="v1"} 12
+watch_cache_capacity{resource="pods"} 100
+process_start_time_seconds 1.54940000e+09
+weird_labels{path="/a,b",msg="say \"hi\"\n"} NaN 1549400000000
+`
+
+func TestParse(t *testing.T) {
+	samples, err := Parse(strings.NewReader(metricsText))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 7 {
+		t.Fatal("Expected 7 samples but got", len(samples))
+	}
+
+	buckets := Filter(samples, "apiserver_watch_events_sizes_bucket")
+	if len(buckets) != 2 || buckets[1].Labels["le"] != "+Inf" || buckets[1].Value != 12 {
+		t.Fatal("Unexpected buckets parsed:", buckets)
+	}
+
+	if len(FilterPrefix(samples, "apiserver_watch_events_sizes")) != 4 {
+		t.Fatal("Expected 4 apiserver_watch_events_sizes samples")
+	}
+
+	start := Filter(samples, "process_start_time_seconds")
+	if len(start) != 1 || start[0].Value != 1549400000 {
+		t.Fatal("Unexpected unlabeled sample parsed:", start)
+	}
+
+	weird := Filter(samples, "weird_labels")
+	if len(weird) != 1 || weird[0].Labels["path"] != "/a,b" || weird[0].Labels["msg"] != "say \"hi\"\n" || !math.IsNaN(weird[0].Value) {
+		t.Fatal("Unexpected escaped labels parsed:", weird)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, line := range []string{"no_value", `bad_label{a=b} 1`, `unterminated{a="b" 1`, "bad_value abc"} {
+		_, err := Parse(strings.NewReader(line))
+		if err == nil {
+			t.Fatal("Expected an error parsing", line)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package registry performs authenticated requests against container image
+// registries that implement the Docker registry HTTP API V2.
+package registry // import "github.com/Comcast/kuberhealthy/pkg/registry"
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Auth is a single entry of a docker config json file
+type Auth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Auth     string `json:"auth"`
+}
+
+// Get requests a registry URL with the supplied Accept header.  When the
+// registry answers with an authentication challenge, a bearer token or basic
+// authentication is obtained with the credentials, when supplied, and the
+// request is retried.
+func Get(httpClient *http.Client, registryURL string, accept string, auth *Auth) (*http.Response, error) {
+	resp, err := get(httpClient, registryURL, accept, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	var authorization string
+	scheme, params := ParseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		token, err := FetchToken(httpClient, params, auth)
+		if err != nil {
+			return nil, err
+		}
+		authorization = "Bearer " + token
+	case "basic":
+		if auth == nil {
+			return nil, errors.New("registry " + hostOf(registryURL) + " requires credentials")
+		}
+		authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(auth.Username+":"+auth.Password))
+	default:
+		return nil, errors.New("registry " + hostOf(registryURL) + " returned an unsupported authentication challenge: " + challenge)
+	}
+
+	return get(httpClient, registryURL, accept, authorization)
+}
+
+// get performs a single request with optional Accept and Authorization
+// headers
+func get(httpClient *http.Client, registryURL string, accept string, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, registryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", accept)
+	}
+	if len(authorization) > 0 {
+		req.Header.Set("Authorization", authorization)
+	}
+	return httpClient.Do(req)
+}
+
+// hostOf returns the host of a URL, or the URL itself when it can not be
+// parsed
+func hostOf(registryURL string) string {
+	u, err := url.Parse(registryURL)
+	if err != nil {
+		return registryURL
+	}
+	return u.Host
+}
+
+// FetchToken requests a bearer token from the realm of an auth challenge,
+// using basic authentication when credentials are supplied
+func FetchToken(httpClient *http.Client, params map[string]string, auth *Auth) (string, error) {
+	realm, ok := params["realm"]
+	if !ok {
+		return "", errors.New("bearer challenge has no realm")
+	}
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", errors.New("Error parsing token realm " + realm + ": " + err.Error())
+	}
+	query := tokenURL.Query()
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+	if scope, ok := params["scope"]; ok {
+		query.Set("scope", scope)
+	}
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if auth != nil {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("token endpoint returned status " + strconv.Itoa(resp.StatusCode))
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return "", errors.New("Error decoding token response: " + err.Error())
+	}
+	if len(token.Token) > 0 {
+		return token.Token, nil
+	}
+	if len(token.AccessToken) > 0 {
+		return token.AccessToken, nil
+	}
+	return "", errors.New("token endpoint returned no token")
+}
+
+// ParseChallenge parses a WWW-Authenticate header such as
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+// into its scheme and parameters
+func ParseChallenge(header string) (string, map[string]string) {
+	params := make(map[string]string)
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	scheme := parts[0]
+	if len(parts) < 2 {
+		return scheme, params
+	}
+
+	var key, value strings.Builder
+	var inValue, inQuotes bool
+	flush := func() {
+		if key.Len() > 0 {
+			params[strings.ToLower(strings.TrimSpace(key.String()))] = value.String()
+		}
+		key.Reset()
+		value.Reset()
+		inValue = false
+	}
+	for _, r := range parts[1] {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == ',' && !inQuotes:
+			flush()
+		case r == '=' && !inValue && !inQuotes:
+			inValue = true
+		case inValue:
+			value.WriteRune(r)
+		default:
+			key.WriteRune(r)
+		}
+	}
+	flush()
+	return scheme, params
+}